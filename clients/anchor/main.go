@@ -0,0 +1,145 @@
+// Package anchor provides clients for the APIs anchors use to move assets on
+// and off the Stellar network: SEP-6 programmatic deposits and withdrawals,
+// SEP-24 interactive deposits and withdrawals, and SEP-38 quotes. The
+// endpoints are discovered from the stellar.toml of the anchor and requests
+// are authenticated with SEP-10 tokens.
+// https://github.com/stellar/stellar-protocol/blob/master/ecosystem/sep-0006.md
+// https://github.com/stellar/stellar-protocol/blob/master/ecosystem/sep-0024.md
+// https://github.com/stellar/stellar-protocol/blob/master/ecosystem/sep-0038.md
+package anchor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/stellar/go/clients/stellartoml"
+	"github.com/stellar/go/clients/webauth"
+	"github.com/stellar/go/support/errors"
+)
+
+// ResponseMaxSize is the maximum size of a response from an anchor.
+const ResponseMaxSize = 1024 * 1024
+
+// DefaultTimeout is the timeout of a request used when Client.Timeout is
+// zero.
+const DefaultTimeout = 30 * time.Second
+
+var (
+	// ErrNoTransferServer is returned when the stellar.toml of the anchor
+	// has no TRANSFER_SERVER.
+	ErrNoTransferServer = errors.New("stellar.toml does not specify a TRANSFER_SERVER")
+
+	// ErrNoInteractiveTransferServer is returned when the stellar.toml of
+	// the anchor has no TRANSFER_SERVER_SEP0024.
+	ErrNoInteractiveTransferServer = errors.New("stellar.toml does not specify a TRANSFER_SERVER_SEP0024")
+
+	// ErrNoQuoteServer is returned when the stellar.toml of the anchor has
+	// no ANCHOR_QUOTE_SERVER.
+	ErrNoQuoteServer = errors.New("stellar.toml does not specify an ANCHOR_QUOTE_SERVER")
+
+	// ErrNoAuth is returned by requests which must be authenticated when
+	// Client.Auth is nil.
+	ErrNoAuth = errors.New("request requires authentication but the client has no token source")
+)
+
+// HTTP represents the http client that an anchor client uses to make http
+// requests.
+type HTTP interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// TokenSource returns SEP-10 tokens to authenticate requests. It is
+// implemented by webauth.TokenSource. If it also has an Invalidate method,
+// it is called when the anchor rejects a token, before retrying once with a
+// new token.
+type TokenSource interface {
+	Token(ctx context.Context) (*webauth.Token, error)
+}
+
+// Client is a client for the APIs of an anchor. Use SEP6, SEP24 and SEP38 to
+// access the API of each protocol.
+type Client struct {
+	// HomeDomain is the domain whose stellar.toml lists the endpoints of the
+	// anchor.
+	HomeDomain string
+
+	// HTTP is the http client used to query the anchor.
+	HTTP HTTP
+
+	// StellarTOML resolves the stellar.toml of HomeDomain. It is loaded once
+	// and cached by the client.
+	StellarTOML stellartoml.ClientInterface
+
+	// Auth authenticates requests. Requests which the protocols allow
+	// without authentication are made anonymously if it is nil.
+	Auth TokenSource
+
+	// Timeout bounds every request. DefaultTimeout is used if it is zero.
+	Timeout time.Duration
+
+	// AllowHTTP allows endpoints using plain HTTP. Useful for debugging.
+	AllowHTTP bool
+
+	lock sync.Mutex
+	toml *stellartoml.Response
+}
+
+// Error is returned when an anchor responds with an error status.
+type Error struct {
+	StatusCode int
+	// Message is the error message of the response, if any.
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("anchor request failed with status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("anchor request failed with status %d: %s", e.StatusCode, e.Message)
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+	Type  string `json:"type"`
+
+	// Fields and Status are set by SEP-6 customer information responses.
+	Fields      []string `json:"fields"`
+	Status      string   `json:"status"`
+	MoreInfoURL string   `json:"more_info_url"`
+	Eta         int64    `json:"eta"`
+}
+
+// stellarTOML returns the stellar.toml of the anchor, loading it on first
+// use.
+func (c *Client) stellarTOML() (*stellartoml.Response, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.toml != nil {
+		return c.toml, nil
+	}
+	toml, err := c.StellarTOML.GetStellarToml(c.HomeDomain)
+	if err != nil {
+		return nil, errors.Wrap(err, "get stellar.toml failed")
+	}
+	c.toml = toml
+	return toml, nil
+}
+
+// SEP6 returns a client for the SEP-6 API of the anchor.
+func (c *Client) SEP6() *SEP6Client {
+	return &SEP6Client{client: c}
+}
+
+// SEP24 returns a client for the SEP-24 API of the anchor.
+func (c *Client) SEP24() *SEP24Client {
+	return &SEP24Client{client: c}
+}
+
+// SEP38 returns a client for the SEP-38 API of the anchor.
+func (c *Client) SEP38() *SEP38Client {
+	return &SEP38Client{client: c}
+}
@@ -0,0 +1,172 @@
+package anchor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stellar/go/clients/stellartoml"
+	"github.com/stellar/go/clients/webauth"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ExampleClient starts an interactive SEP-24 deposit, authenticating with
+// SEP-10, and waits until it completes.
+func ExampleClient() {
+	account := keypair.MustRandom()
+	client := &Client{
+		HomeDomain:  "testanchor.stellar.org",
+		HTTP:        http.DefaultClient,
+		StellarTOML: stellartoml.DefaultClient,
+		Auth: &webauth.TokenSource{
+			Client:     webauth.DefaultTestNetClient,
+			HomeDomain: "testanchor.stellar.org",
+			Request: webauth.Request{
+				Account: account.Address(),
+				Signers: []txnbuild.HashSigner{txnbuild.KeypairSigner{Full: account}},
+			},
+		},
+	}
+
+	ctx := context.Background()
+	deposit, err := client.SEP24().Deposit(ctx, InteractiveRequest{AssetCode: "SRT"})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Open", deposit.URL)
+
+	tx, err := client.SEP24().WatchTransaction(ctx, deposit.ID, 0, func(tx *Transaction) {
+		fmt.Println("Status", tx.Status)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Received", tx.AmountOut)
+}
+
+// fakeTokenSource returns stale tokens until it is invalidated.
+type fakeTokenSource struct {
+	lock        sync.Mutex
+	token       string
+	invalidated int
+}
+
+func (s *fakeTokenSource) Token(context.Context) (*webauth.Token, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return &webauth.Token{Raw: s.token}, nil
+}
+
+func (s *fakeTokenSource) Invalidate() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.invalidated++
+	s.token = "valid"
+}
+
+// fakeAnchor is a stand-in anchor serving SEP-6 under /sep6, SEP-24 under
+// /sep24 and SEP-38 under /sep38. Handlers are registered by the tests.
+type fakeAnchor struct {
+	*httptest.Server
+	mux *http.ServeMux
+}
+
+func newFakeAnchor(t *testing.T) *fakeAnchor {
+	a := &fakeAnchor{mux: http.NewServeMux()}
+	a.Server = httptest.NewServer(a.mux)
+	t.Cleanup(a.Close)
+	return a
+}
+
+// handle registers a handler for pattern. Authenticated handlers reject
+// requests without the token "valid".
+func (a *fakeAnchor) handle(pattern string, authenticated bool, handler func(w http.ResponseWriter, r *http.Request)) {
+	a.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		if authenticated && r.Header.Get("Authorization") != "Bearer valid" {
+			w.WriteHeader(http.StatusUnauthorized)
+			writeJSON(w, map[string]string{"error": "invalid token"})
+			return
+		}
+		handler(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (a *fakeAnchor) client(auth TokenSource) *Client {
+	toml := &stellartoml.MockClient{}
+	toml.On("GetStellarToml", "anchor.com").Return(&stellartoml.Response{
+		TransferServer:     a.URL + "/sep6",
+		TransferServer0024: a.URL + "/sep24/",
+		AnchorQuoteServer:  a.URL + "/sep38",
+	}, nil).Once()
+	return &Client{
+		HomeDomain:  "anchor.com",
+		HTTP:        a.Client(),
+		StellarTOML: toml,
+		Auth:        auth,
+		AllowHTTP:   true,
+	}
+}
+
+func TestClientRequests(t *testing.T) {
+	anchor := newFakeAnchor(t)
+	anchor.handle("/sep6/transactions", true, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, transactionsResponse{})
+	})
+	anchor.handle("/sep6/transaction", false, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		writeJSON(w, map[string]string{"error": "transaction not found"})
+	})
+	anchor.handle("/sep6/info", false, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	// An invalidated token is replaced before retrying.
+	auth := &fakeTokenSource{token: "stale"}
+	client := anchor.client(auth)
+	_, err := client.SEP6().Transactions(context.Background(), TransactionsRequest{AssetCode: "USD"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, auth.invalidated)
+
+	// The stellar.toml is cached by the client.
+	_, err = client.SEP6().Transaction(context.Background(), TransactionRequest{ID: "1"})
+	assert.Equal(t, &Error{StatusCode: http.StatusNotFound, Message: "transaction not found"}, err)
+	assert.EqualError(t, err, "anchor request failed with status 404: transaction not found")
+
+	_, err = client.SEP6().Info(context.Background(), "")
+	assert.EqualError(t, err, "anchor request failed with status 502")
+
+	client = anchor.client(nil)
+	_, err = client.SEP6().Transactions(context.Background(), TransactionsRequest{AssetCode: "USD"})
+	assert.Equal(t, ErrNoAuth, err)
+
+	client = anchor.client(nil)
+	client.AllowHTTP = false
+	_, err = client.SEP6().Info(context.Background(), "")
+	assert.EqualError(t, err, "endpoint "+anchor.URL+"/sep6 does not use https")
+}
+
+func TestClientDiscovery(t *testing.T) {
+	toml := &stellartoml.MockClient{}
+	toml.On("GetStellarToml", "anchor.com").Return(&stellartoml.Response{}, nil).Once()
+	client := &Client{HomeDomain: "anchor.com", StellarTOML: toml}
+
+	_, err := client.SEP6().Info(context.Background(), "")
+	assert.Equal(t, ErrNoTransferServer, err)
+	_, err = client.SEP24().Info(context.Background(), "")
+	assert.Equal(t, ErrNoInteractiveTransferServer, err)
+	_, err = client.SEP38().Info(context.Background())
+	assert.Equal(t, ErrNoQuoteServer, err)
+	toml.AssertExpectations(t)
+}
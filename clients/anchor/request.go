@@ -0,0 +1,182 @@
+package anchor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/stellar/go/support/errors"
+)
+
+type authMode int
+
+const (
+	// authNone sends the request anonymously.
+	authNone authMode = iota
+	// authOptional authenticates the request if the client has a token
+	// source.
+	authOptional
+	// authRequired fails with ErrNoAuth if the client has no token source.
+	authRequired
+)
+
+// request is a request to an anchor. At most one of form and body is set.
+type request struct {
+	method string
+	// server is the endpoint from the stellar.toml, like TRANSFER_SERVER,
+	// and path the path of the API relative to it.
+	server string
+	path   string
+	query  url.Values
+	form   url.Values
+	body   interface{}
+	auth   authMode
+}
+
+// do sends a request to the anchor and decodes its json response into v.
+func (c *Client) do(ctx context.Context, r request, v interface{}) error {
+	if r.auth == authRequired && c.Auth == nil {
+		return ErrNoAuth
+	}
+	target, err := c.url(r)
+	if err != nil {
+		return err
+	}
+
+	var body []byte
+	contentType := ""
+	switch {
+	case r.form != nil:
+		body = []byte(r.form.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	case r.body != nil:
+		body, err = json.Marshal(r.body)
+		if err != nil {
+			return errors.Wrap(err, "encode request failed")
+		}
+		contentType = "application/json"
+	}
+
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	authenticate := r.auth != authNone && c.Auth != nil
+	status, data, err := c.send(ctx, r.method, target, contentType, body, authenticate)
+	if err != nil {
+		return err
+	}
+	if status == http.StatusUnauthorized && authenticate {
+		if source, ok := c.Auth.(interface{ Invalidate() }); ok {
+			source.Invalidate()
+			status, data, err = c.send(ctx, r.method, target, contentType, body, authenticate)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if !(status >= 200 && status < 300) {
+		return responseError(status, data)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return errors.Wrap(err, "json decode failed")
+	}
+	return nil
+}
+
+func (c *Client) url(r request) (string, error) {
+	server, err := url.Parse(r.server)
+	if err != nil {
+		return "", errors.Wrapf(err, "parse endpoint %s failed", r.server)
+	}
+	if server.Scheme != "https" && !(c.AllowHTTP && server.Scheme == "http") {
+		return "", errors.Errorf("endpoint %s does not use https", r.server)
+	}
+	target := strings.TrimSuffix(r.server, "/") + r.path
+	if len(r.query) > 0 {
+		target += "?" + r.query.Encode()
+	}
+	return target, nil
+}
+
+func (c *Client) send(ctx context.Context, method, target, contentType string, body []byte, authenticate bool) (int, []byte, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "build request failed")
+	}
+	req.Header.Set("Accept", "application/json")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if authenticate {
+		token, err := c.Auth.Token(ctx)
+		if err != nil {
+			return 0, nil, errors.Wrap(err, "web auth failed")
+		}
+		req.Header.Set("Authorization", "Bearer "+token.Raw)
+	}
+
+	hresp, err := c.HTTP.Do(req)
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "http request errored")
+	}
+	defer hresp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(hresp.Body, ResponseMaxSize+1))
+	if err != nil {
+		return 0, nil, errors.Wrap(err, "read response failed")
+	}
+	if len(data) > ResponseMaxSize {
+		return 0, nil, errors.Errorf("anchor response exceeds %d bytes limit", ResponseMaxSize)
+	}
+	return hresp.StatusCode, data, nil
+}
+
+// responseError returns the error of an anchor response with an error
+// status.
+func responseError(status int, data []byte) error {
+	var problem errorResponse
+	_ = json.Unmarshal(data, &problem)
+	if status == http.StatusForbidden {
+		switch problem.Type {
+		case customerInfoNeededType:
+			return &CustomerInfoNeededError{Fields: problem.Fields}
+		case customerInfoStatusType:
+			return &CustomerInfoStatusError{
+				Status:      problem.Status,
+				MoreInfoURL: problem.MoreInfoURL,
+				Eta:         problem.Eta,
+			}
+		}
+	}
+	return &Error{StatusCode: status, Message: problem.Error}
+}
+
+// setQuery sets key in query if value is not empty.
+func setQuery(query url.Values, key, value string) {
+	if value != "" {
+		query.Set(key, value)
+	}
+}
+
+// setExtra adds the extra fields of a request, like SEP-9 KYC fields, to
+// query.
+func setExtra(query url.Values, extra url.Values) {
+	for key, values := range extra {
+		for _, value := range values {
+			query.Add(key, value)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package anchor
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// SEP24Client is a client for the SEP-24 API of an anchor, at the
+// TRANSFER_SERVER_SEP0024 of its stellar.toml.
+type SEP24Client struct {
+	client *Client
+}
+
+func (c *SEP24Client) server() (string, error) {
+	toml, err := c.client.stellarTOML()
+	if err != nil {
+		return "", err
+	}
+	if toml.TransferServer0024 == "" {
+		return "", ErrNoInteractiveTransferServer
+	}
+	return toml.TransferServer0024, nil
+}
+
+func (c *SEP24Client) do(ctx context.Context, r request, v interface{}) error {
+	server, err := c.server()
+	if err != nil {
+		return err
+	}
+	r.server = server
+	return c.client.do(ctx, r, v)
+}
+
+// SEP24Info describes the assets and features supported by a SEP-24 anchor.
+type SEP24Info struct {
+	Deposit  map[string]SEP24Asset `json:"deposit"`
+	Withdraw map[string]SEP24Asset `json:"withdraw"`
+	Fee      EndpointInfo          `json:"fee"`
+	Features Features              `json:"features"`
+}
+
+// SEP24Asset describes the deposits or withdrawals of an asset.
+type SEP24Asset struct {
+	Enabled    bool    `json:"enabled"`
+	MinAmount  float64 `json:"min_amount"`
+	MaxAmount  float64 `json:"max_amount"`
+	FeeFixed   float64 `json:"fee_fixed"`
+	FeePercent float64 `json:"fee_percent"`
+	FeeMinimum float64 `json:"fee_minimum"`
+}
+
+// Info returns the assets and features supported by the anchor.
+func (c *SEP24Client) Info(ctx context.Context, lang string) (*SEP24Info, error) {
+	query := url.Values{}
+	setQuery(query, "lang", lang)
+	var info SEP24Info
+	err := c.do(ctx, request{method: http.MethodGet, path: "/info", query: query}, &info)
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// InteractiveRequest is a request to start an interactive SEP-24 deposit or
+// withdrawal.
+type InteractiveRequest struct {
+	AssetCode   string
+	AssetIssuer string
+	Amount      string
+	// Account is the account that receives the deposit or sends the
+	// withdrawal. It defaults to the authenticated account.
+	Account  string
+	Memo     string
+	MemoType string
+	// QuoteID is the SEP-38 quote of the exchange of SourceAsset, for
+	// deposits, or DestinationAsset, for withdrawals, with the asset.
+	QuoteID                   string
+	SourceAsset               string
+	DestinationAsset          string
+	WalletName                string
+	WalletURL                 string
+	Lang                      string
+	ClaimableBalanceSupported bool
+	CustomerID                string
+	// Extra are additional fields, like SEP-9 fields to prefill the
+	// interactive flow.
+	Extra url.Values
+}
+
+func (r InteractiveRequest) values() url.Values {
+	form := url.Values{}
+	form.Set("asset_code", r.AssetCode)
+	setQuery(form, "asset_issuer", r.AssetIssuer)
+	setQuery(form, "amount", r.Amount)
+	setQuery(form, "account", r.Account)
+	setQuery(form, "memo", r.Memo)
+	setQuery(form, "memo_type", r.MemoType)
+	setQuery(form, "quote_id", r.QuoteID)
+	setQuery(form, "source_asset", r.SourceAsset)
+	setQuery(form, "destination_asset", r.DestinationAsset)
+	setQuery(form, "wallet_name", r.WalletName)
+	setQuery(form, "wallet_url", r.WalletURL)
+	setQuery(form, "lang", r.Lang)
+	if r.ClaimableBalanceSupported {
+		form.Set("claimable_balance_supported", "true")
+	}
+	setQuery(form, "customer_id", r.CustomerID)
+	setExtra(form, r.Extra)
+	return form
+}
+
+// InteractiveResponse is the interactive flow of a SEP-24 transaction.
+type InteractiveResponse struct {
+	// Type is interactive_customer_info_needed.
+	Type string `json:"type"`
+	// URL is the page of the interactive flow, to open in a browser or
+	// webview.
+	URL string `json:"url"`
+	// ID is the ID of the transaction.
+	ID string `json:"id"`
+}
+
+// Deposit starts an interactive deposit.
+func (c *SEP24Client) Deposit(ctx context.Context, req InteractiveRequest) (*InteractiveResponse, error) {
+	return c.interactive(ctx, "/transactions/deposit/interactive", req)
+}
+
+// Withdraw starts an interactive withdrawal.
+func (c *SEP24Client) Withdraw(ctx context.Context, req InteractiveRequest) (*InteractiveResponse, error) {
+	return c.interactive(ctx, "/transactions/withdraw/interactive", req)
+}
+
+func (c *SEP24Client) interactive(ctx context.Context, path string, req InteractiveRequest) (*InteractiveResponse, error) {
+	var response InteractiveResponse
+	err := c.do(ctx, request{method: http.MethodPost, path: path, form: req.values(), auth: authRequired}, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Transaction returns a transaction of the authenticated account.
+func (c *SEP24Client) Transaction(ctx context.Context, req TransactionRequest) (*Transaction, error) {
+	var response transactionResponse
+	err := c.do(ctx, request{method: http.MethodGet, path: "/transaction", query: req.values(), auth: authRequired}, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response.Transaction, nil
+}
+
+// Transactions returns the transactions of the authenticated account for
+// req.AssetCode, newest first.
+func (c *SEP24Client) Transactions(ctx context.Context, req TransactionsRequest) ([]Transaction, error) {
+	var response transactionsResponse
+	err := c.do(ctx, request{method: http.MethodGet, path: "/transactions", query: req.values(), auth: authRequired}, &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.Transactions, nil
+}
+
+// WatchTransaction polls the transaction with id every interval, or
+// DefaultWatchInterval if it is zero, and calls fn whenever its status
+// changes. It returns the transaction once its status is final. Otherwise
+// it returns the last transaction loaded, if any, with the error of ctx or
+// of the request which stopped the polling.
+func (c *SEP24Client) WatchTransaction(ctx context.Context, id string, interval time.Duration, fn func(*Transaction)) (*Transaction, error) {
+	return watch(ctx, interval, func(ctx context.Context) (*Transaction, error) {
+		return c.Transaction(ctx, TransactionRequest{ID: id})
+	}, fn)
+}
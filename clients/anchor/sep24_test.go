@@ -0,0 +1,93 @@
+package anchor
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSEP24(t *testing.T) {
+	anchor := newFakeAnchor(t)
+	anchor.handle("/sep24/info", false, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{
+			"deposit": {"USDC": {"enabled": true, "fee_minimum": 1}},
+			"withdraw": {"USDC": {"enabled": false}},
+			"fee": {"enabled": false},
+			"features": {"claimable_balances": true}
+		}`))
+	})
+	anchor.handle("/sep24/transactions/deposit/interactive", true, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/x-www-form-urlencoded", r.Header.Get("Content-Type"))
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "USDC", r.PostForm.Get("asset_code"))
+		assert.Equal(t, "GABC", r.PostForm.Get("account"))
+		assert.Equal(t, "jane@example.com", r.PostForm.Get("email_address"))
+		writeJSON(w, InteractiveResponse{
+			Type: "interactive_customer_info_needed",
+			URL:  "https://anchor.com/deposit?token=abc",
+			ID:   "1",
+		})
+	})
+	anchor.handle("/sep24/transactions", true, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		assert.Equal(t, "USDC", query.Get("asset_code"))
+		assert.Equal(t, "10", query.Get("limit"))
+		assert.Equal(t, "2020-01-02T03:04:05Z", query.Get("no_older_than"))
+		_, _ = w.Write([]byte(`{"transactions": [{
+			"id": "1",
+			"kind": "deposit",
+			"status": "completed",
+			"amount_in": "100",
+			"fee_details": {"total": "1", "asset": "iso4217:USD", "details": [{"name": "Service fee", "amount": "1"}]},
+			"started_at": "2020-01-02T03:04:05Z",
+			"completed_at": "2020-01-02T04:04:05Z",
+			"kyc_verified": true
+		}]}`))
+	})
+	anchor.handle("/sep24/transaction", true, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, transactionResponse{Transaction: Transaction{ID: "2", Status: StatusIncomplete}})
+	})
+
+	client := anchor.client(&fakeTokenSource{token: "valid"}).SEP24()
+	ctx := context.Background()
+
+	info, err := client.Info(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, info.Deposit["USDC"].FeeMinimum)
+	assert.False(t, info.Withdraw["USDC"].Enabled)
+	assert.True(t, info.Features.ClaimableBalances)
+
+	interactive, err := client.Deposit(ctx, InteractiveRequest{
+		AssetCode: "USDC",
+		Account:   "GABC",
+		Extra:     map[string][]string{"email_address": {"jane@example.com"}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "https://anchor.com/deposit?token=abc", interactive.URL)
+	assert.Equal(t, "1", interactive.ID)
+
+	txs, err := client.Transactions(ctx, TransactionsRequest{
+		AssetCode:   "USDC",
+		Limit:       10,
+		NoOlderThan: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+	})
+	require.NoError(t, err)
+	require.Len(t, txs, 1)
+	assert.Equal(t, StatusCompleted, txs[0].Status)
+	assert.True(t, txs[0].Status.Final())
+	assert.Equal(t, "Service fee", txs[0].FeeDetails.Details[0].Name)
+	assert.Equal(t, time.Date(2020, 1, 2, 4, 4, 5, 0, time.UTC), *txs[0].CompletedAt)
+	assert.True(t, txs[0].KYCVerified)
+
+	// Watching stops when the context is done.
+	ctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	tx, err := client.WatchTransaction(ctx, "2", time.Millisecond, nil)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, StatusIncomplete, tx.Status)
+}
@@ -0,0 +1,226 @@
+package anchor
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Contexts in which SEP-38 prices and quotes are used.
+const (
+	ContextSEP6  = "sep6"
+	ContextSEP24 = "sep24"
+	ContextSEP31 = "sep31"
+)
+
+// StellarAsset returns the SEP-38 name of a Stellar asset, like
+// stellar:USDC:G..., or stellar:native for lumens.
+func StellarAsset(code, issuer string) string {
+	if issuer == "" {
+		return "stellar:native"
+	}
+	return "stellar:" + code + ":" + issuer
+}
+
+// SEP38Client is a client for the SEP-38 API of an anchor, at the
+// ANCHOR_QUOTE_SERVER of its stellar.toml.
+type SEP38Client struct {
+	client *Client
+}
+
+func (c *SEP38Client) server() (string, error) {
+	toml, err := c.client.stellarTOML()
+	if err != nil {
+		return "", err
+	}
+	if toml.AnchorQuoteServer == "" {
+		return "", ErrNoQuoteServer
+	}
+	return toml.AnchorQuoteServer, nil
+}
+
+func (c *SEP38Client) do(ctx context.Context, r request, v interface{}) error {
+	server, err := c.server()
+	if err != nil {
+		return err
+	}
+	r.server = server
+	return c.client.do(ctx, r, v)
+}
+
+// SEP38Info lists the assets a SEP-38 anchor quotes.
+type SEP38Info struct {
+	Assets []SEP38Asset `json:"assets"`
+}
+
+// SEP38Asset describes an asset a SEP-38 anchor quotes.
+type SEP38Asset struct {
+	// Asset is the SEP-38 name of the asset, like iso4217:USD.
+	Asset               string           `json:"asset"`
+	SellDeliveryMethods []DeliveryMethod `json:"sell_delivery_methods,omitempty"`
+	BuyDeliveryMethods  []DeliveryMethod `json:"buy_delivery_methods,omitempty"`
+	CountryCodes        []string         `json:"country_codes,omitempty"`
+}
+
+// DeliveryMethod is a method to deliver an off-chain asset to or from the
+// anchor.
+type DeliveryMethod struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Info returns the assets the anchor quotes.
+func (c *SEP38Client) Info(ctx context.Context) (*SEP38Info, error) {
+	var info SEP38Info
+	err := c.do(ctx, request{method: http.MethodGet, path: "/info", auth: authOptional}, &info)
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// PricesRequest asks for the indicative prices of the assets which can be
+// bought with SellAsset, or sold for BuyAsset. Exactly one of the two, with
+// its amount, must be set.
+type PricesRequest struct {
+	SellAsset          string
+	SellAmount         string
+	SellDeliveryMethod string
+	BuyAsset           string
+	BuyAmount          string
+	BuyDeliveryMethod  string
+	CountryCode        string
+}
+
+// AssetPrice is the indicative price of an asset.
+type AssetPrice struct {
+	Asset    string `json:"asset"`
+	Price    string `json:"price"`
+	Decimals int    `json:"decimals"`
+}
+
+// PricesResponse has BuyAssets if the request had a SellAsset and
+// SellAssets if it had a BuyAsset.
+type PricesResponse struct {
+	BuyAssets  []AssetPrice `json:"buy_assets,omitempty"`
+	SellAssets []AssetPrice `json:"sell_assets,omitempty"`
+}
+
+// Prices returns indicative prices for exchanging an asset with the other
+// assets the anchor quotes.
+func (c *SEP38Client) Prices(ctx context.Context, req PricesRequest) (*PricesResponse, error) {
+	query := url.Values{}
+	setQuery(query, "sell_asset", req.SellAsset)
+	setQuery(query, "sell_amount", req.SellAmount)
+	setQuery(query, "sell_delivery_method", req.SellDeliveryMethod)
+	setQuery(query, "buy_asset", req.BuyAsset)
+	setQuery(query, "buy_amount", req.BuyAmount)
+	setQuery(query, "buy_delivery_method", req.BuyDeliveryMethod)
+	setQuery(query, "country_code", req.CountryCode)
+	var response PricesResponse
+	err := c.do(ctx, request{method: http.MethodGet, path: "/prices", query: query, auth: authOptional}, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// PriceRequest asks for the price of exchanging SellAsset for BuyAsset.
+// Exactly one of SellAmount and BuyAmount must be set.
+type PriceRequest struct {
+	// Context is one of the Context constants.
+	Context            string `json:"context"`
+	SellAsset          string `json:"sell_asset"`
+	SellAmount         string `json:"sell_amount,omitempty"`
+	SellDeliveryMethod string `json:"sell_delivery_method,omitempty"`
+	BuyAsset           string `json:"buy_asset"`
+	BuyAmount          string `json:"buy_amount,omitempty"`
+	BuyDeliveryMethod  string `json:"buy_delivery_method,omitempty"`
+	CountryCode        string `json:"country_code,omitempty"`
+}
+
+// Price is the indicative price of an exchange.
+type Price struct {
+	// TotalPrice is the price including fees, Price the price excluding
+	// fees, in units of the sell asset per unit of the buy asset.
+	TotalPrice string `json:"total_price"`
+	Price      string `json:"price"`
+	SellAmount string `json:"sell_amount"`
+	BuyAmount  string `json:"buy_amount"`
+	Fee        Fee    `json:"fee"`
+}
+
+// Price returns the indicative price of an exchange.
+func (c *SEP38Client) Price(ctx context.Context, req PriceRequest) (*Price, error) {
+	query := url.Values{}
+	query.Set("context", req.Context)
+	query.Set("sell_asset", req.SellAsset)
+	setQuery(query, "sell_amount", req.SellAmount)
+	setQuery(query, "sell_delivery_method", req.SellDeliveryMethod)
+	query.Set("buy_asset", req.BuyAsset)
+	setQuery(query, "buy_amount", req.BuyAmount)
+	setQuery(query, "buy_delivery_method", req.BuyDeliveryMethod)
+	setQuery(query, "country_code", req.CountryCode)
+	var response Price
+	err := c.do(ctx, request{method: http.MethodGet, path: "/price", query: query, auth: authOptional}, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// QuoteRequest asks for a firm quote.
+type QuoteRequest struct {
+	PriceRequest
+	// ExpireAfter is the earliest time the quote may expire. The anchor
+	// chooses the expiration if it is zero.
+	ExpireAfter time.Time
+}
+
+type quoteRequest struct {
+	PriceRequest
+	ExpireAfter *time.Time `json:"expire_after,omitempty"`
+}
+
+// Quote is a firm quote. The anchor commits to exchange the amounts until
+// ExpiresAt.
+type Quote struct {
+	ID                 string    `json:"id"`
+	ExpiresAt          time.Time `json:"expires_at"`
+	TotalPrice         string    `json:"total_price"`
+	Price              string    `json:"price"`
+	SellAsset          string    `json:"sell_asset"`
+	SellAmount         string    `json:"sell_amount"`
+	SellDeliveryMethod string    `json:"sell_delivery_method,omitempty"`
+	BuyAsset           string    `json:"buy_asset"`
+	BuyAmount          string    `json:"buy_amount"`
+	BuyDeliveryMethod  string    `json:"buy_delivery_method,omitempty"`
+	Fee                Fee       `json:"fee"`
+}
+
+// CreateQuote requests a firm quote for the authenticated account. Its ID
+// is used in SEP-6 and SEP-24 exchanges.
+func (c *SEP38Client) CreateQuote(ctx context.Context, req QuoteRequest) (*Quote, error) {
+	body := quoteRequest{PriceRequest: req.PriceRequest}
+	if !req.ExpireAfter.IsZero() {
+		expireAfter := req.ExpireAfter.UTC()
+		body.ExpireAfter = &expireAfter
+	}
+	var quote Quote
+	err := c.do(ctx, request{method: http.MethodPost, path: "/quote", body: body, auth: authRequired}, &quote)
+	if err != nil {
+		return nil, err
+	}
+	return &quote, nil
+}
+
+// Quote returns a quote of the authenticated account.
+func (c *SEP38Client) Quote(ctx context.Context, id string) (*Quote, error) {
+	var quote Quote
+	err := c.do(ctx, request{method: http.MethodGet, path: "/quote/" + url.PathEscape(id), auth: authRequired}, &quote)
+	if err != nil {
+		return nil, err
+	}
+	return &quote, nil
+}
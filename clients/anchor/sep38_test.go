@@ -0,0 +1,92 @@
+package anchor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSEP38(t *testing.T) {
+	usdc := StellarAsset("USDC", "GA5ZSEJYB37JRC5AVCIA5MOP4RHTM335X2KGX3IHOJAPP5RE34K4KZVN")
+	expiresAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	quote := Quote{
+		ID:         "quote-1",
+		ExpiresAt:  expiresAt,
+		TotalPrice: "1.02",
+		Price:      "1.01",
+		SellAsset:  "iso4217:USD",
+		SellAmount: "102",
+		BuyAsset:   usdc,
+		BuyAmount:  "100",
+		Fee:        Fee{Total: "1", Asset: "iso4217:USD"},
+	}
+
+	anchor := newFakeAnchor(t)
+	anchor.handle("/sep38/info", false, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, SEP38Info{Assets: []SEP38Asset{
+			{Asset: usdc},
+			{Asset: "iso4217:USD", SellDeliveryMethods: []DeliveryMethod{{Name: "WIRE"}}, CountryCodes: []string{"US"}},
+		}})
+	})
+	anchor.handle("/sep38/prices", false, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "iso4217:USD", r.URL.Query().Get("sell_asset"))
+		assert.Equal(t, "100", r.URL.Query().Get("sell_amount"))
+		writeJSON(w, PricesResponse{BuyAssets: []AssetPrice{{Asset: usdc, Price: "1.01", Decimals: 7}}})
+	})
+	anchor.handle("/sep38/price", false, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, ContextSEP24, r.URL.Query().Get("context"))
+		assert.Equal(t, usdc, r.URL.Query().Get("buy_asset"))
+		writeJSON(w, Price{TotalPrice: "1.02", Price: "1.01", SellAmount: "102", BuyAmount: "100"})
+	})
+	anchor.handle("/sep38/quote", true, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, map[string]interface{}{
+			"context":      ContextSEP24,
+			"sell_asset":   "iso4217:USD",
+			"buy_asset":    usdc,
+			"buy_amount":   "100",
+			"expire_after": "2029-12-31T23:00:00Z",
+		}, body)
+		writeJSON(w, quote)
+	})
+	anchor.handle("/sep38/quote/quote-1", true, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, quote)
+	})
+
+	client := anchor.client(&fakeTokenSource{token: "valid"}).SEP38()
+	ctx := context.Background()
+
+	info, err := client.Info(ctx)
+	require.NoError(t, err)
+	require.Len(t, info.Assets, 2)
+	assert.Equal(t, "WIRE", info.Assets[1].SellDeliveryMethods[0].Name)
+
+	prices, err := client.Prices(ctx, PricesRequest{SellAsset: "iso4217:USD", SellAmount: "100"})
+	require.NoError(t, err)
+	assert.Equal(t, []AssetPrice{{Asset: usdc, Price: "1.01", Decimals: 7}}, prices.BuyAssets)
+
+	request := PriceRequest{Context: ContextSEP24, SellAsset: "iso4217:USD", BuyAsset: usdc, BuyAmount: "100"}
+	price, err := client.Price(ctx, request)
+	require.NoError(t, err)
+	assert.Equal(t, "102", price.SellAmount)
+
+	created, err := client.CreateQuote(ctx, QuoteRequest{
+		PriceRequest: request,
+		ExpireAfter:  expiresAt.Add(-time.Hour),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, quote, *created)
+
+	loaded, err := client.Quote(ctx, "quote-1")
+	require.NoError(t, err)
+	assert.Equal(t, quote, *loaded)
+
+	assert.Equal(t, "stellar:native", StellarAsset("XLM", ""))
+}
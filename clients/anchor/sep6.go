@@ -0,0 +1,363 @@
+package anchor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	customerInfoNeededType = "non_interactive_customer_info_needed"
+	customerInfoStatusType = "customer_info_status"
+)
+
+// CustomerInfoNeededError is returned by SEP-6 deposits and withdrawals when
+// the anchor needs more information about the customer, which is usually
+// provided with SEP-12.
+type CustomerInfoNeededError struct {
+	// Fields are the SEP-9 fields the anchor needs.
+	Fields []string
+}
+
+func (e *CustomerInfoNeededError) Error() string {
+	return "anchor needs customer information: " + strings.Join(e.Fields, ", ")
+}
+
+// CustomerInfoStatusError is returned by SEP-6 deposits and withdrawals when
+// the customer information has been submitted but is pending or denied.
+type CustomerInfoStatusError struct {
+	// Status is pending or denied.
+	Status      string
+	MoreInfoURL string
+	// Eta is the estimated number of seconds until the status changes.
+	Eta int64
+}
+
+func (e *CustomerInfoStatusError) Error() string {
+	return fmt.Sprintf("customer information status is %s", e.Status)
+}
+
+// SEP6Client is a client for the SEP-6 API of an anchor, at the
+// TRANSFER_SERVER of its stellar.toml.
+type SEP6Client struct {
+	client *Client
+}
+
+func (c *SEP6Client) server() (string, error) {
+	toml, err := c.client.stellarTOML()
+	if err != nil {
+		return "", err
+	}
+	if toml.TransferServer == "" {
+		return "", ErrNoTransferServer
+	}
+	return toml.TransferServer, nil
+}
+
+func (c *SEP6Client) do(ctx context.Context, r request, v interface{}) error {
+	server, err := c.server()
+	if err != nil {
+		return err
+	}
+	r.server = server
+	return c.client.do(ctx, r, v)
+}
+
+// SEP6Info describes the assets and features supported by a SEP-6 anchor.
+type SEP6Info struct {
+	Deposit          map[string]SEP6DepositAsset  `json:"deposit"`
+	DepositExchange  map[string]SEP6DepositAsset  `json:"deposit-exchange"`
+	Withdraw         map[string]SEP6WithdrawAsset `json:"withdraw"`
+	WithdrawExchange map[string]SEP6WithdrawAsset `json:"withdraw-exchange"`
+	Fee              EndpointInfo                 `json:"fee"`
+	Transaction      EndpointInfo                 `json:"transaction"`
+	Transactions     EndpointInfo                 `json:"transactions"`
+	Features         Features                     `json:"features"`
+}
+
+// SEP6DepositAsset describes the deposits of an asset.
+type SEP6DepositAsset struct {
+	Enabled                bool             `json:"enabled"`
+	AuthenticationRequired bool             `json:"authentication_required"`
+	MinAmount              float64          `json:"min_amount"`
+	MaxAmount              float64          `json:"max_amount"`
+	FeeFixed               float64          `json:"fee_fixed"`
+	FeePercent             float64          `json:"fee_percent"`
+	Fields                 map[string]Field `json:"fields"`
+}
+
+// SEP6WithdrawAsset describes the withdrawals of an asset.
+type SEP6WithdrawAsset struct {
+	Enabled                bool    `json:"enabled"`
+	AuthenticationRequired bool    `json:"authentication_required"`
+	MinAmount              float64 `json:"min_amount"`
+	MaxAmount              float64 `json:"max_amount"`
+	FeeFixed               float64 `json:"fee_fixed"`
+	FeePercent             float64 `json:"fee_percent"`
+	// Types are the types of withdrawal, like bank_account, and the fields
+	// they require.
+	Types map[string]SEP6WithdrawType `json:"types"`
+}
+
+// SEP6WithdrawType describes a type of withdrawal.
+type SEP6WithdrawType struct {
+	Fields map[string]Field `json:"fields"`
+}
+
+// EndpointInfo describes whether an optional endpoint is supported.
+type EndpointInfo struct {
+	Enabled                bool `json:"enabled"`
+	AuthenticationRequired bool `json:"authentication_required"`
+}
+
+// Features are the optional features supported by an anchor.
+type Features struct {
+	AccountCreation   bool `json:"account_creation"`
+	ClaimableBalances bool `json:"claimable_balances"`
+}
+
+// Info returns the assets and features supported by the anchor.
+func (c *SEP6Client) Info(ctx context.Context, lang string) (*SEP6Info, error) {
+	query := url.Values{}
+	setQuery(query, "lang", lang)
+	var info SEP6Info
+	err := c.do(ctx, request{method: http.MethodGet, path: "/info", query: query}, &info)
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// DepositRequest is a request to deposit an asset with SEP-6.
+type DepositRequest struct {
+	AssetCode string
+	// Account is the account that receives the deposit.
+	Account  string
+	Memo     string
+	MemoType string
+	// Type is the type of deposit, like SEPA or SWIFT.
+	Type   string
+	Amount string
+	// QuoteID, SourceAsset and Amount are used by DepositExchange instead
+	// of AssetCode.
+	QuoteID                   string
+	SourceAsset               string
+	EmailAddress              string
+	WalletName                string
+	WalletURL                 string
+	Lang                      string
+	OnChangeCallback          string
+	CountryCode               string
+	ClaimableBalanceSupported bool
+	CustomerID                string
+	LocationID                string
+	// Extra are additional fields, like the SEP-9 fields the anchor
+	// requires.
+	Extra url.Values
+}
+
+func (r DepositRequest) values() url.Values {
+	query := url.Values{}
+	setQuery(query, "account", r.Account)
+	setQuery(query, "memo", r.Memo)
+	setQuery(query, "memo_type", r.MemoType)
+	setQuery(query, "type", r.Type)
+	setQuery(query, "amount", r.Amount)
+	setQuery(query, "quote_id", r.QuoteID)
+	setQuery(query, "email_address", r.EmailAddress)
+	setQuery(query, "wallet_name", r.WalletName)
+	setQuery(query, "wallet_url", r.WalletURL)
+	setQuery(query, "lang", r.Lang)
+	setQuery(query, "on_change_callback", r.OnChangeCallback)
+	setQuery(query, "country_code", r.CountryCode)
+	if r.ClaimableBalanceSupported {
+		query.Set("claimable_balance_supported", "true")
+	}
+	setQuery(query, "customer_id", r.CustomerID)
+	setQuery(query, "location_id", r.LocationID)
+	setExtra(query, r.Extra)
+	return query
+}
+
+// DepositInstruction is an instruction to send the deposit off-chain.
+type DepositInstruction struct {
+	Value       string `json:"value"`
+	Description string `json:"description"`
+}
+
+// DepositResponse describes how to send a SEP-6 deposit to the anchor.
+type DepositResponse struct {
+	ID string `json:"id"`
+	// Instructions are keyed by SEP-9 financial account fields, like
+	// organization.bank_account_number.
+	Instructions map[string]DepositInstruction `json:"instructions"`
+	// How is the deprecated free text form of Instructions.
+	How        string     `json:"how"`
+	Eta        int64      `json:"eta"`
+	MinAmount  float64    `json:"min_amount"`
+	MaxAmount  float64    `json:"max_amount"`
+	FeeFixed   float64    `json:"fee_fixed"`
+	FeePercent float64    `json:"fee_percent"`
+	ExtraInfo  *ExtraInfo `json:"extra_info"`
+}
+
+// ExtraInfo is additional information about a deposit or withdrawal.
+type ExtraInfo struct {
+	Message string `json:"message"`
+}
+
+// Deposit asks the anchor how to deposit an asset. It fails with a
+// *CustomerInfoNeededError or *CustomerInfoStatusError if the anchor needs
+// more information about the customer.
+func (c *SEP6Client) Deposit(ctx context.Context, req DepositRequest) (*DepositResponse, error) {
+	query := req.values()
+	query.Set("asset_code", req.AssetCode)
+	var response DepositResponse
+	err := c.do(ctx, request{method: http.MethodGet, path: "/deposit", query: query, auth: authOptional}, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// DepositExchange asks the anchor how to deposit an off-chain asset,
+// req.SourceAsset, in exchange for the Stellar asset req.AssetCode, at the
+// price of the SEP-38 quote req.QuoteID if it is set.
+func (c *SEP6Client) DepositExchange(ctx context.Context, req DepositRequest) (*DepositResponse, error) {
+	query := req.values()
+	query.Set("destination_asset", req.AssetCode)
+	query.Set("source_asset", req.SourceAsset)
+	var response DepositResponse
+	err := c.do(ctx, request{method: http.MethodGet, path: "/deposit-exchange", query: query, auth: authOptional}, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// WithdrawRequest is a request to withdraw an asset with SEP-6.
+type WithdrawRequest struct {
+	AssetCode string
+	// Type is the type of withdrawal, like bank_account.
+	Type string
+	// Account is the account that sends the withdrawal.
+	Account  string
+	Memo     string
+	MemoType string
+	Amount   string
+	// QuoteID, DestinationAsset and Amount are used by WithdrawExchange
+	// instead of AssetCode.
+	QuoteID          string
+	DestinationAsset string
+	WalletName       string
+	WalletURL        string
+	Lang             string
+	OnChangeCallback string
+	CountryCode      string
+	RefundMemo       string
+	RefundMemoType   string
+	CustomerID       string
+	LocationID       string
+	// Extra are additional fields, like the SEP-9 fields the anchor
+	// requires.
+	Extra url.Values
+}
+
+func (r WithdrawRequest) values() url.Values {
+	query := url.Values{}
+	setQuery(query, "type", r.Type)
+	setQuery(query, "account", r.Account)
+	setQuery(query, "memo", r.Memo)
+	setQuery(query, "memo_type", r.MemoType)
+	setQuery(query, "amount", r.Amount)
+	setQuery(query, "quote_id", r.QuoteID)
+	setQuery(query, "wallet_name", r.WalletName)
+	setQuery(query, "wallet_url", r.WalletURL)
+	setQuery(query, "lang", r.Lang)
+	setQuery(query, "on_change_callback", r.OnChangeCallback)
+	setQuery(query, "country_code", r.CountryCode)
+	setQuery(query, "refund_memo", r.RefundMemo)
+	setQuery(query, "refund_memo_type", r.RefundMemoType)
+	setQuery(query, "customer_id", r.CustomerID)
+	setQuery(query, "location_id", r.LocationID)
+	setExtra(query, r.Extra)
+	return query
+}
+
+// WithdrawResponse describes where to send a SEP-6 withdrawal.
+type WithdrawResponse struct {
+	ID         string     `json:"id"`
+	AccountID  string     `json:"account_id"`
+	MemoType   string     `json:"memo_type"`
+	Memo       string     `json:"memo"`
+	Eta        int64      `json:"eta"`
+	MinAmount  float64    `json:"min_amount"`
+	MaxAmount  float64    `json:"max_amount"`
+	FeeFixed   float64    `json:"fee_fixed"`
+	FeePercent float64    `json:"fee_percent"`
+	ExtraInfo  *ExtraInfo `json:"extra_info"`
+}
+
+// Withdraw asks the anchor how to withdraw an asset. It fails with a
+// *CustomerInfoNeededError or *CustomerInfoStatusError if the anchor needs
+// more information about the customer.
+func (c *SEP6Client) Withdraw(ctx context.Context, req WithdrawRequest) (*WithdrawResponse, error) {
+	query := req.values()
+	query.Set("asset_code", req.AssetCode)
+	var response WithdrawResponse
+	err := c.do(ctx, request{method: http.MethodGet, path: "/withdraw", query: query, auth: authOptional}, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// WithdrawExchange asks the anchor how to withdraw the Stellar asset
+// req.AssetCode in exchange for the off-chain asset req.DestinationAsset, at
+// the price of the SEP-38 quote req.QuoteID if it is set.
+func (c *SEP6Client) WithdrawExchange(ctx context.Context, req WithdrawRequest) (*WithdrawResponse, error) {
+	query := req.values()
+	query.Set("source_asset", req.AssetCode)
+	query.Set("destination_asset", req.DestinationAsset)
+	var response WithdrawResponse
+	err := c.do(ctx, request{method: http.MethodGet, path: "/withdraw-exchange", query: query, auth: authOptional}, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// Transaction returns a transaction of the authenticated account.
+func (c *SEP6Client) Transaction(ctx context.Context, req TransactionRequest) (*Transaction, error) {
+	var response transactionResponse
+	err := c.do(ctx, request{method: http.MethodGet, path: "/transaction", query: req.values(), auth: authRequired}, &response)
+	if err != nil {
+		return nil, err
+	}
+	return &response.Transaction, nil
+}
+
+// Transactions returns the transactions of the authenticated account,
+// newest first.
+func (c *SEP6Client) Transactions(ctx context.Context, req TransactionsRequest) ([]Transaction, error) {
+	var response transactionsResponse
+	err := c.do(ctx, request{method: http.MethodGet, path: "/transactions", query: req.values(), auth: authRequired}, &response)
+	if err != nil {
+		return nil, err
+	}
+	return response.Transactions, nil
+}
+
+// WatchTransaction polls the transaction with id every interval, or
+// DefaultWatchInterval if it is zero, and calls fn whenever its status
+// changes. It returns the transaction once its status is final. Otherwise
+// it returns the last transaction loaded, if any, with the error of ctx or
+// of the request which stopped the polling.
+func (c *SEP6Client) WatchTransaction(ctx context.Context, id string, interval time.Duration, fn func(*Transaction)) (*Transaction, error) {
+	return watch(ctx, interval, func(ctx context.Context) (*Transaction, error) {
+		return c.Transaction(ctx, TransactionRequest{ID: id})
+	}, fn)
+}
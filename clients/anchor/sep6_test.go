@@ -0,0 +1,127 @@
+package anchor
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSEP6(t *testing.T) {
+	anchor := newFakeAnchor(t)
+	anchor.handle("/sep6/info", false, func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{
+			"deposit": {"USD": {"enabled": true, "authentication_required": true, "min_amount": 0.1,
+				"fields": {"type": {"description": "type of deposit", "choices": ["SEPA", "SWIFT"]}}}},
+			"withdraw": {"USD": {"enabled": true, "fee_fixed": 5,
+				"types": {"bank_account": {"fields": {"dest": {"description": "IBAN"}}}}}},
+			"transaction": {"enabled": true},
+			"features": {"account_creation": true}
+		}`))
+	})
+	anchor.handle("/sep6/deposit", true, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		switch {
+		case query.Get("first_name") == "":
+			w.WriteHeader(http.StatusForbidden)
+			writeJSON(w, map[string]interface{}{
+				"type":   "non_interactive_customer_info_needed",
+				"fields": []string{"first_name", "last_name"},
+			})
+		case query.Get("first_name") == "Pending":
+			w.WriteHeader(http.StatusForbidden)
+			writeJSON(w, map[string]interface{}{
+				"type":          "customer_info_status",
+				"status":        "pending",
+				"more_info_url": "https://anchor.com/kyc",
+				"eta":           3600,
+			})
+		default:
+			assert.Equal(t, url.Values{
+				"asset_code":                  {"USD"},
+				"account":                     {"GABC"},
+				"type":                        {"SEPA"},
+				"amount":                      {"100"},
+				"claimable_balance_supported": {"true"},
+				"first_name":                  {"Jane"},
+			}, query)
+			writeJSON(w, DepositResponse{
+				ID:           "1",
+				Instructions: map[string]DepositInstruction{"organization.bank_account_number": {Value: "DE89", Description: "IBAN"}},
+				Eta:          60,
+			})
+		}
+	})
+	anchor.handle("/sep6/withdraw-exchange", true, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		assert.Equal(t, "USDC", query.Get("source_asset"))
+		assert.Equal(t, "iso4217:EUR", query.Get("destination_asset"))
+		assert.Equal(t, "quote-1", query.Get("quote_id"))
+		writeJSON(w, WithdrawResponse{ID: "2", AccountID: "GANCHOR", MemoType: "id", Memo: "42"})
+	})
+	var calls int
+	anchor.handle("/sep6/transaction", true, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "1", r.URL.Query().Get("id"))
+		statuses := []TransactionStatus{StatusPendingUserTransferStart, StatusPendingUserTransferStart, StatusPendingAnchor, StatusCompleted}
+		tx := Transaction{ID: "1", Kind: KindDeposit, Status: statuses[calls], StartedAt: time.Unix(0, 0).UTC()}
+		if calls < len(statuses)-1 {
+			calls++
+		}
+		writeJSON(w, transactionResponse{Transaction: tx})
+	})
+
+	client := anchor.client(&fakeTokenSource{token: "valid"}).SEP6()
+	ctx := context.Background()
+
+	info, err := client.Info(ctx, "en")
+	require.NoError(t, err)
+	assert.True(t, info.Deposit["USD"].AuthenticationRequired)
+	assert.Equal(t, 0.1, info.Deposit["USD"].MinAmount)
+	assert.Equal(t, []string{"SEPA", "SWIFT"}, info.Deposit["USD"].Fields["type"].Choices)
+	assert.Equal(t, "IBAN", info.Withdraw["USD"].Types["bank_account"].Fields["dest"].Description)
+	assert.True(t, info.Transaction.Enabled)
+	assert.True(t, info.Features.AccountCreation)
+
+	req := DepositRequest{
+		AssetCode:                 "USD",
+		Account:                   "GABC",
+		Type:                      "SEPA",
+		Amount:                    "100",
+		ClaimableBalanceSupported: true,
+	}
+	_, err = client.Deposit(ctx, req)
+	assert.Equal(t, &CustomerInfoNeededError{Fields: []string{"first_name", "last_name"}}, err)
+	assert.EqualError(t, err, "anchor needs customer information: first_name, last_name")
+
+	req.Extra = url.Values{"first_name": {"Pending"}}
+	_, err = client.Deposit(ctx, req)
+	assert.Equal(t, &CustomerInfoStatusError{Status: "pending", MoreInfoURL: "https://anchor.com/kyc", Eta: 3600}, err)
+
+	req.Extra = url.Values{"first_name": {"Jane"}}
+	deposit, err := client.Deposit(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, "1", deposit.ID)
+	assert.Equal(t, "DE89", deposit.Instructions["organization.bank_account_number"].Value)
+
+	withdraw, err := client.WithdrawExchange(ctx, WithdrawRequest{
+		AssetCode:        "USDC",
+		DestinationAsset: "iso4217:EUR",
+		QuoteID:          "quote-1",
+		Type:             "bank_account",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "GANCHOR", withdraw.AccountID)
+	assert.Equal(t, "42", withdraw.Memo)
+
+	var seen []TransactionStatus
+	tx, err := client.WatchTransaction(ctx, "1", time.Millisecond, func(tx *Transaction) {
+		seen = append(seen, tx.Status)
+	})
+	require.NoError(t, err)
+	assert.Equal(t, StatusCompleted, tx.Status)
+	assert.Equal(t, []TransactionStatus{StatusPendingUserTransferStart, StatusPendingAnchor, StatusCompleted}, seen)
+}
@@ -0,0 +1,237 @@
+package anchor
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// TransactionStatus is the status of a SEP-6 or SEP-24 transaction.
+type TransactionStatus string
+
+// Statuses of SEP-6 and SEP-24 transactions.
+const (
+	StatusIncomplete                   TransactionStatus = "incomplete"
+	StatusPendingUserTransferStart     TransactionStatus = "pending_user_transfer_start"
+	StatusPendingUserTransferComplete  TransactionStatus = "pending_user_transfer_complete"
+	StatusPendingExternal              TransactionStatus = "pending_external"
+	StatusPendingAnchor                TransactionStatus = "pending_anchor"
+	StatusOnHold                       TransactionStatus = "on_hold"
+	StatusPendingStellar               TransactionStatus = "pending_stellar"
+	StatusPendingTrust                 TransactionStatus = "pending_trust"
+	StatusPendingUser                  TransactionStatus = "pending_user"
+	StatusPendingCustomerInfoUpdate    TransactionStatus = "pending_customer_info_update"
+	StatusPendingTransactionInfoUpdate TransactionStatus = "pending_transaction_info_update"
+	StatusCompleted                    TransactionStatus = "completed"
+	StatusRefunded                     TransactionStatus = "refunded"
+	StatusExpired                      TransactionStatus = "expired"
+	StatusNoMarket                     TransactionStatus = "no_market"
+	StatusTooSmall                     TransactionStatus = "too_small"
+	StatusTooLarge                     TransactionStatus = "too_large"
+	StatusError                        TransactionStatus = "error"
+)
+
+// Final returns true if a transaction with the status will not change
+// anymore.
+func (s TransactionStatus) Final() bool {
+	switch s {
+	case StatusCompleted, StatusRefunded, StatusExpired, StatusNoMarket,
+		StatusTooSmall, StatusTooLarge, StatusError:
+		return true
+	default:
+		return false
+	}
+}
+
+// Kinds of SEP-6 and SEP-24 transactions.
+const (
+	KindDeposit          = "deposit"
+	KindWithdrawal       = "withdrawal"
+	KindDepositExchange  = "deposit-exchange"
+	KindWithdrawExchange = "withdrawal-exchange"
+)
+
+// Transaction is a SEP-6 or SEP-24 transaction. Fields which only one of the
+// protocols defines are empty in transactions of the other.
+type Transaction struct {
+	ID                    string            `json:"id"`
+	Kind                  string            `json:"kind"`
+	Status                TransactionStatus `json:"status"`
+	StatusEta             int64             `json:"status_eta,omitempty"`
+	MoreInfoURL           string            `json:"more_info_url,omitempty"`
+	AmountIn              string            `json:"amount_in,omitempty"`
+	AmountInAsset         string            `json:"amount_in_asset,omitempty"`
+	AmountOut             string            `json:"amount_out,omitempty"`
+	AmountOutAsset        string            `json:"amount_out_asset,omitempty"`
+	AmountFee             string            `json:"amount_fee,omitempty"`
+	AmountFeeAsset        string            `json:"amount_fee_asset,omitempty"`
+	FeeDetails            *Fee              `json:"fee_details,omitempty"`
+	QuoteID               string            `json:"quote_id,omitempty"`
+	From                  string            `json:"from,omitempty"`
+	To                    string            `json:"to,omitempty"`
+	StartedAt             time.Time         `json:"started_at"`
+	UpdatedAt             *time.Time        `json:"updated_at,omitempty"`
+	CompletedAt           *time.Time        `json:"completed_at,omitempty"`
+	UserActionRequiredBy  *time.Time        `json:"user_action_required_by,omitempty"`
+	StellarTransactionID  string            `json:"stellar_transaction_id,omitempty"`
+	ExternalTransactionID string            `json:"external_transaction_id,omitempty"`
+	Message               string            `json:"message,omitempty"`
+	Refunded              bool              `json:"refunded,omitempty"`
+	Refunds               *Refunds          `json:"refunds,omitempty"`
+	ClaimableBalanceID    string            `json:"claimable_balance_id,omitempty"`
+
+	// Deposits.
+	DepositMemo     string `json:"deposit_memo,omitempty"`
+	DepositMemoType string `json:"deposit_memo_type,omitempty"`
+
+	// Withdrawals.
+	WithdrawAnchorAccount string `json:"withdraw_anchor_account,omitempty"`
+	WithdrawMemo          string `json:"withdraw_memo,omitempty"`
+	WithdrawMemoType      string `json:"withdraw_memo_type,omitempty"`
+
+	// SEP-24 only.
+	KYCVerified bool `json:"kyc_verified,omitempty"`
+
+	// SEP-6 only.
+	RequiredInfoMessage string                        `json:"required_info_message,omitempty"`
+	RequiredInfoUpdates map[string]Field              `json:"required_info_updates,omitempty"`
+	Instructions        map[string]DepositInstruction `json:"instructions,omitempty"`
+}
+
+// Fee is the fee of a transaction or quote.
+type Fee struct {
+	Total   string       `json:"total"`
+	Asset   string       `json:"asset"`
+	Details []FeeDetails `json:"details,omitempty"`
+}
+
+// FeeDetails is a component of a Fee.
+type FeeDetails struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Amount      string `json:"amount"`
+}
+
+// Refunds describes the refunds of a transaction.
+type Refunds struct {
+	AmountRefunded string          `json:"amount_refunded"`
+	AmountFee      string          `json:"amount_fee"`
+	Payments       []RefundPayment `json:"payments"`
+}
+
+// RefundPayment is a payment refunding a transaction.
+type RefundPayment struct {
+	ID     string `json:"id"`
+	IDType string `json:"id_type"`
+	Amount string `json:"amount"`
+	Fee    string `json:"fee"`
+}
+
+// Field describes a field the user must provide.
+type Field struct {
+	Description string   `json:"description"`
+	Optional    bool     `json:"optional,omitempty"`
+	Choices     []string `json:"choices,omitempty"`
+}
+
+// TransactionRequest identifies a transaction. Exactly one of ID,
+// StellarTransactionID and ExternalTransactionID must be set.
+type TransactionRequest struct {
+	ID                    string
+	StellarTransactionID  string
+	ExternalTransactionID string
+	Lang                  string
+}
+
+func (r TransactionRequest) values() url.Values {
+	query := url.Values{}
+	setQuery(query, "id", r.ID)
+	setQuery(query, "stellar_transaction_id", r.StellarTransactionID)
+	setQuery(query, "external_transaction_id", r.ExternalTransactionID)
+	setQuery(query, "lang", r.Lang)
+	return query
+}
+
+// TransactionsRequest filters the transactions of the authenticated account.
+type TransactionsRequest struct {
+	AssetCode string
+	// Kind is one of the Kind constants. SEP-24 only defines KindDeposit
+	// and KindWithdrawal.
+	Kind        string
+	Limit       int
+	NoOlderThan time.Time
+	// PagingID is the ID of the last transaction of the previous page.
+	PagingID string
+	Lang     string
+}
+
+func (r TransactionsRequest) values() url.Values {
+	query := url.Values{}
+	setQuery(query, "asset_code", r.AssetCode)
+	setQuery(query, "kind", r.Kind)
+	if r.Limit > 0 {
+		query.Set("limit", strconv.Itoa(r.Limit))
+	}
+	if !r.NoOlderThan.IsZero() {
+		query.Set("no_older_than", r.NoOlderThan.UTC().Format(time.RFC3339))
+	}
+	setQuery(query, "paging_id", r.PagingID)
+	setQuery(query, "lang", r.Lang)
+	return query
+}
+
+type transactionResponse struct {
+	Transaction Transaction `json:"transaction"`
+}
+
+type transactionsResponse struct {
+	Transactions []Transaction `json:"transactions"`
+}
+
+// DefaultWatchInterval is the polling interval of WatchTransaction used when
+// the interval is zero.
+const DefaultWatchInterval = 5 * time.Second
+
+// watch polls a transaction with get every interval and calls fn with the
+// transaction whenever its status changes, until its status is final or ctx
+// is done. It returns the last transaction loaded, with the error of ctx or
+// get if polling stopped early.
+func watch(
+	ctx context.Context,
+	interval time.Duration,
+	get func(ctx context.Context) (*Transaction, error),
+	fn func(*Transaction),
+) (*Transaction, error) {
+	if interval == 0 {
+		interval = DefaultWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last *Transaction
+	for {
+		tx, err := get(ctx)
+		if ctx.Err() != nil {
+			return last, ctx.Err()
+		}
+		if err != nil {
+			return last, err
+		}
+		if last == nil || tx.Status != last.Status {
+			if fn != nil {
+				fn(tx)
+			}
+		}
+		last = tx
+		if tx.Status.Final() {
+			return tx, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return last, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package approval
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/stellar/go/clients/stellartoml"
+	proto "github.com/stellar/go/protocols/approval"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+)
+
+// RegulatedAssets returns the regulated assets used by the operations of tx.
+// An asset is regulated if the stellar.toml of the home domain of its
+// issuer lists it with regulated = true.
+func (c *Client) RegulatedAssets(tx *txnbuild.Transaction) ([]RegulatedAsset, error) {
+	seen := map[string]bool{}
+	tomls := map[string]*stellartoml.Response{}
+	var regulated []RegulatedAsset
+	for _, asset := range operationAssets(tx.ToXDR().Operations()) {
+		if asset.IsNative() || seen[asset.StringCanonical()] {
+			continue
+		}
+		seen[asset.StringCanonical()] = true
+		var typ, code, issuer string
+		if err := asset.Extract(&typ, &code, &issuer); err != nil {
+			return nil, errors.Wrap(err, "could not extract asset")
+		}
+
+		homeDomain, err := c.Accounts.HomeDomain(issuer)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not load home domain of %s", issuer)
+		}
+		if homeDomain == "" {
+			continue
+		}
+		toml, ok := tomls[homeDomain]
+		if !ok {
+			toml, err = c.StellarTOML.GetStellarToml(homeDomain)
+			if err != nil {
+				return nil, errors.Wrapf(err, "get stellar.toml of %s failed", homeDomain)
+			}
+			tomls[homeDomain] = toml
+		}
+
+		for _, currency := range toml.Currencies {
+			if currency.Code != code || currency.Issuer != issuer || currency.Regulated != "true" {
+				continue
+			}
+			if currency.ApprovalServer == "" {
+				return nil, errors.Wrapf(ErrNoApprovalServer, "asset %s", asset.StringCanonical())
+			}
+			regulated = append(regulated, RegulatedAsset{
+				Code:             code,
+				Issuer:           issuer,
+				HomeDomain:       homeDomain,
+				ApprovalServer:   currency.ApprovalServer,
+				ApprovalCriteria: currency.ApprovalCriteria,
+			})
+			break
+		}
+	}
+	return regulated, nil
+}
+
+// operationAssets returns the assets sent, received, traded or trusted by
+// ops.
+func operationAssets(ops []xdr.Operation) []xdr.Asset {
+	var assets []xdr.Asset
+	for _, op := range ops {
+		switch op.Body.Type {
+		case xdr.OperationTypePayment:
+			assets = append(assets, op.Body.MustPaymentOp().Asset)
+		case xdr.OperationTypePathPaymentStrictReceive:
+			payment := op.Body.MustPathPaymentStrictReceiveOp()
+			assets = append(assets, payment.SendAsset, payment.DestAsset)
+			assets = append(assets, payment.Path...)
+		case xdr.OperationTypePathPaymentStrictSend:
+			payment := op.Body.MustPathPaymentStrictSendOp()
+			assets = append(assets, payment.SendAsset, payment.DestAsset)
+			assets = append(assets, payment.Path...)
+		case xdr.OperationTypeManageSellOffer:
+			offer := op.Body.MustManageSellOfferOp()
+			assets = append(assets, offer.Selling, offer.Buying)
+		case xdr.OperationTypeManageBuyOffer:
+			offer := op.Body.MustManageBuyOfferOp()
+			assets = append(assets, offer.Selling, offer.Buying)
+		case xdr.OperationTypeCreatePassiveSellOffer:
+			offer := op.Body.MustCreatePassiveSellOfferOp()
+			assets = append(assets, offer.Selling, offer.Buying)
+		case xdr.OperationTypeChangeTrust:
+			line := op.Body.MustChangeTrustOp().Line
+			if line.Type != xdr.AssetTypeAssetTypePoolShare {
+				assets = append(assets, line.ToAsset())
+			}
+		case xdr.OperationTypeCreateClaimableBalance:
+			assets = append(assets, op.Body.MustCreateClaimableBalanceOp().Asset)
+		}
+	}
+	return assets
+}
+
+// ApproveAndSign requests the approval of tx from the approval server of
+// each of its regulated assets with Approve, and signs the approved
+// transaction with signers. Transactions without regulated assets are
+// signed as is.
+func (c *Client) ApproveAndSign(ctx context.Context, tx *txnbuild.Transaction, signers ...txnbuild.HashSigner) (*txnbuild.Transaction, error) {
+	assets, err := c.RegulatedAssets(tx)
+	if err != nil {
+		return nil, err
+	}
+	for _, asset := range assets {
+		tx, err = c.Approve(ctx, asset, tx)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tx.SignWith(ctx, c.NetworkPassphrase, signers...)
+}
+
+// Approve requests the approval of tx from the approval server of asset
+// and returns the approved transaction, which is a revision of tx verified
+// with VerifyRevision if the server revised it. Pending transactions are
+// submitted again after the wait requested by the server until ctx is done.
+// It fails with a *RejectedError if the server rejects the transaction and
+// an *ActionRequiredError if the user must complete an action first.
+func (c *Client) Approve(ctx context.Context, asset RegulatedAsset, tx *txnbuild.Transaction) (*txnbuild.Transaction, error) {
+	for {
+		response, err := c.Request(ctx, asset.ApprovalServer, tx)
+		if err != nil {
+			return nil, err
+		}
+
+		switch response.Status {
+		case proto.StatusSuccess:
+			approved, err := parseTransaction(response.Tx)
+			if err != nil {
+				return nil, err
+			}
+			// The server may only add its signatures to the transaction.
+			hash, err := tx.Hash(c.NetworkPassphrase)
+			if err != nil {
+				return nil, errors.Wrap(err, "hash transaction failed")
+			}
+			approvedHash, err := approved.Hash(c.NetworkPassphrase)
+			if err != nil {
+				return nil, errors.Wrap(err, "hash approved transaction failed")
+			}
+			if hash != approvedHash {
+				return nil, errors.New("approved transaction differs from the submitted transaction")
+			}
+			return approved, nil
+		case proto.StatusRevised:
+			revised, err := parseTransaction(response.Tx)
+			if err != nil {
+				return nil, err
+			}
+			if err := VerifyRevision(tx, revised, asset.Issuer); err != nil {
+				return nil, errors.Wrap(err, "invalid revision")
+			}
+			return revised, nil
+		case proto.StatusPending:
+			wait := time.Duration(response.Timeout) * time.Millisecond
+			if wait <= 0 {
+				wait = DefaultPendingWait
+			}
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			case <-timer.C:
+			}
+		case proto.StatusActionRequired:
+			return nil, &ActionRequiredError{
+				Message:      response.Message,
+				ActionURL:    response.ActionURL,
+				ActionMethod: response.ActionMethod,
+				ActionFields: response.ActionFields,
+			}
+		case proto.StatusRejected:
+			return nil, &RejectedError{Message: response.Error}
+		default:
+			return nil, errors.Errorf("approval server responded with unknown status %q", response.Status)
+		}
+	}
+}
+
+func parseTransaction(envelope string) (*txnbuild.Transaction, error) {
+	parsed, err := txnbuild.TransactionFromXDR(envelope)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse approved transaction")
+	}
+	tx, ok := parsed.Transaction()
+	if !ok {
+		return nil, errors.New("approved transaction is a fee bump transaction")
+	}
+	return tx, nil
+}
+
+// Request submits tx to an approval server once and returns its response,
+// without acting on it.
+func (c *Client) Request(ctx context.Context, approvalServer string, tx *txnbuild.Transaction) (*proto.Response, error) {
+	envelope, err := tx.Base64()
+	if err != nil {
+		return nil, errors.Wrap(err, "encode transaction failed")
+	}
+	var response proto.Response
+	status, err := c.post(ctx, approvalServer, proto.Request{Tx: envelope}, &response)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusBadRequest && response.Status == proto.StatusRejected {
+		return &response, nil
+	}
+	if !(status >= 200 && status < 300) {
+		return nil, errors.Errorf("approval request failed with status %d", status)
+	}
+	return &response, nil
+}
+
+// Action completes the action of an action_required response by posting
+// fields to its action URL. Actions with the GET method must be completed
+// by the user in a browser instead.
+func (c *Client) Action(ctx context.Context, action *ActionRequiredError, fields map[string]string) (*proto.ActionResponse, error) {
+	if action.ActionMethod != http.MethodPost {
+		return nil, errors.Errorf("action with method %s must be completed in a browser at %s", action.ActionMethod, action.ActionURL)
+	}
+	var response proto.ActionResponse
+	status, err := c.post(ctx, action.ActionURL, fields, &response)
+	if err != nil {
+		return nil, err
+	}
+	if !(status >= 200 && status < 300) {
+		return nil, errors.Errorf("action request failed with status %d", status)
+	}
+	return &response, nil
+}
+
+// post posts the json encoding of body to target and decodes the json
+// response into v, whatever its status, which is returned.
+func (c *Client) post(ctx context.Context, target string, body, v interface{}) (int, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return 0, errors.Wrapf(err, "parse url %s failed", target)
+	}
+	if u.Scheme != "https" && !(c.AllowHTTP && u.Scheme == "http") {
+		return 0, errors.Errorf("url %s does not use https", target)
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return 0, errors.Wrap(err, "encode request failed")
+	}
+
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(data))
+	if err != nil {
+		return 0, errors.Wrap(err, "build request failed")
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+
+	hresp, err := c.HTTP.Do(req)
+	if err != nil {
+		return 0, errors.Wrap(err, "http request errored")
+	}
+	defer hresp.Body.Close()
+
+	data, err = io.ReadAll(io.LimitReader(hresp.Body, ResponseMaxSize+1))
+	if err != nil {
+		return 0, errors.Wrap(err, "read response failed")
+	}
+	if len(data) > ResponseMaxSize {
+		return 0, errors.Errorf("approval server response exceeds %d bytes limit", ResponseMaxSize)
+	}
+	if err := json.Unmarshal(data, v); err != nil && hresp.StatusCode >= 200 && hresp.StatusCode < 300 {
+		return 0, errors.Wrap(err, "json decode failed")
+	}
+	return hresp.StatusCode, nil
+}
@@ -0,0 +1,308 @@
+package approval
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stellar/go/clients/stellartoml"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	proto "github.com/stellar/go/protocols/approval"
+	"github.com/stellar/go/support/errors"
+	approvalhttp "github.com/stellar/go/support/http/approval"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mapAccounts map[string]string
+
+func (m mapAccounts) HomeDomain(accountID string) (string, error) {
+	return m[accountID], nil
+}
+
+type testIssuer struct {
+	keys   *keypair.Full
+	asset  txnbuild.CreditAsset
+	server *httptest.Server
+	client *Client
+}
+
+// newTestIssuer starts an approval server for a regulated USD asset using
+// policy, and returns a client resolving the asset to it.
+func newTestIssuer(t *testing.T, policy func(issuer *keypair.Full, tx *txnbuild.Transaction) (*proto.Response, error)) *testIssuer {
+	issuer := &testIssuer{keys: keypair.MustRandom()}
+	issuer.asset = txnbuild.CreditAsset{Code: "USD", Issuer: issuer.keys.Address()}
+	issuer.server = httptest.NewServer(&approvalhttp.Handler{
+		Policy: approvalhttp.PolicyFunc(func(_ context.Context, tx *txnbuild.Transaction) (*proto.Response, error) {
+			return policy(issuer.keys, tx)
+		}),
+	})
+	t.Cleanup(issuer.server.Close)
+
+	toml := &stellartoml.MockClient{}
+	toml.On("GetStellarToml", "issuer.example.com").Return(&stellartoml.Response{
+		Currencies: []stellartoml.Currency{
+			{Code: "EUR", Issuer: issuer.keys.Address()},
+			{Code: "USD", Issuer: issuer.keys.Address(), Regulated: "true", ApprovalServer: issuer.server.URL + "/tx_approve", ApprovalCriteria: "KYC"},
+		},
+	}, nil)
+	issuer.client = &Client{
+		HTTP:              issuer.server.Client(),
+		StellarTOML:       toml,
+		Accounts:          mapAccounts{issuer.keys.Address(): "issuer.example.com"},
+		NetworkPassphrase: network.TestNetworkPassphrase,
+		AllowHTTP:         true,
+	}
+	return issuer
+}
+
+func signedResponse(status string, issuer *keypair.Full, tx *txnbuild.Transaction) (*proto.Response, error) {
+	signed, err := tx.Sign(network.TestNetworkPassphrase, issuer)
+	if err != nil {
+		return nil, err
+	}
+	envelope, err := signed.Base64()
+	if err != nil {
+		return nil, err
+	}
+	return &proto.Response{Status: status, Tx: envelope}, nil
+}
+
+func paymentTransaction(t *testing.T, source string, assets ...txnbuild.Asset) *txnbuild.Transaction {
+	var ops []txnbuild.Operation
+	for _, asset := range assets {
+		ops = append(ops, &txnbuild.Payment{
+			Destination: keypair.MustRandom().Address(),
+			Amount:      "10",
+			Asset:       asset,
+		})
+	}
+	tx, err := txnbuild.NewTransaction(txnbuild.TransactionParams{
+		SourceAccount:        &txnbuild.SimpleAccount{AccountID: source, Sequence: 41},
+		IncrementSequenceNum: true,
+		Operations:           ops,
+		BaseFee:              txnbuild.MinBaseFee,
+		Preconditions:        txnbuild.Preconditions{TimeBounds: txnbuild.NewInfiniteTimeout()},
+	})
+	require.NoError(t, err)
+	return tx
+}
+
+func TestClientRegulatedAssets(t *testing.T) {
+	issuer := newTestIssuer(t, nil)
+	user := keypair.MustRandom()
+	unknown := txnbuild.CreditAsset{Code: "USD", Issuer: keypair.MustRandom().Address()}
+	eur := txnbuild.CreditAsset{Code: "EUR", Issuer: issuer.keys.Address()}
+
+	tx := paymentTransaction(t, user.Address(), txnbuild.NativeAsset{}, unknown, eur, issuer.asset, issuer.asset)
+	assets, err := issuer.client.RegulatedAssets(tx)
+	require.NoError(t, err)
+	assert.Equal(t, []RegulatedAsset{{
+		Code:             "USD",
+		Issuer:           issuer.keys.Address(),
+		HomeDomain:       "issuer.example.com",
+		ApprovalServer:   issuer.server.URL + "/tx_approve",
+		ApprovalCriteria: "KYC",
+	}}, assets)
+
+	tx = paymentTransaction(t, user.Address(), eur)
+	assets, err = issuer.client.RegulatedAssets(tx)
+	require.NoError(t, err)
+	assert.Empty(t, assets)
+}
+
+func TestClientApproveAndSign(t *testing.T) {
+	user := keypair.MustRandom()
+
+	t.Run("success", func(t *testing.T) {
+		issuer := newTestIssuer(t, func(issuer *keypair.Full, tx *txnbuild.Transaction) (*proto.Response, error) {
+			return signedResponse(proto.StatusSuccess, issuer, tx)
+		})
+		tx := paymentTransaction(t, user.Address(), issuer.asset)
+		approved, err := issuer.client.ApproveAndSign(context.Background(), tx, txnbuild.KeypairSigner{Full: user})
+		require.NoError(t, err)
+		assert.Len(t, approved.Signatures(), 2)
+		assert.Equal(t, tx.ToXDR().Operations(), approved.ToXDR().Operations())
+	})
+
+	t.Run("revised", func(t *testing.T) {
+		issuer := newTestIssuer(t, func(issuer *keypair.Full, tx *txnbuild.Transaction) (*proto.Response, error) {
+			revised, err := approvalhttp.Revise(tx, txnbuild.CreditAsset{Code: "USD", Issuer: issuer.Address()}, tx.SourceAccount().AccountID)
+			if err != nil {
+				return nil, err
+			}
+			return signedResponse(proto.StatusRevised, issuer, revised)
+		})
+		tx := paymentTransaction(t, user.Address(), issuer.asset)
+		approved, err := issuer.client.ApproveAndSign(context.Background(), tx, txnbuild.KeypairSigner{Full: user})
+		require.NoError(t, err)
+		assert.Len(t, approved.Signatures(), 2)
+		assert.Len(t, approved.Operations(), 3)
+	})
+
+	t.Run("revised with other operations", func(t *testing.T) {
+		issuer := newTestIssuer(t, func(issuer *keypair.Full, tx *txnbuild.Transaction) (*proto.Response, error) {
+			revised, err := txnbuild.NewTransaction(txnbuild.TransactionParams{
+				SourceAccount: &txnbuild.SimpleAccount{AccountID: tx.SourceAccount().AccountID, Sequence: tx.SequenceNumber()},
+				Operations: append(tx.Operations(), &txnbuild.Payment{
+					Destination:   issuer.Address(),
+					Amount:        "1",
+					Asset:         txnbuild.NativeAsset{},
+					SourceAccount: tx.SourceAccount().AccountID,
+				}),
+				BaseFee:       tx.BaseFee(),
+				Preconditions: txnbuild.Preconditions{TimeBounds: tx.Timebounds()},
+			})
+			if err != nil {
+				return nil, err
+			}
+			return signedResponse(proto.StatusRevised, issuer, revised)
+		})
+		tx := paymentTransaction(t, user.Address(), issuer.asset)
+		_, err := issuer.client.ApproveAndSign(context.Background(), tx, txnbuild.KeypairSigner{Full: user})
+		assert.EqualError(t, err, "invalid revision: revised transaction operation 1: is not from the issuer "+issuer.keys.Address())
+	})
+
+	t.Run("success with changes", func(t *testing.T) {
+		issuer := newTestIssuer(t, func(issuer *keypair.Full, tx *txnbuild.Transaction) (*proto.Response, error) {
+			revised, err := approvalhttp.Revise(tx, txnbuild.CreditAsset{Code: "USD", Issuer: issuer.Address()}, tx.SourceAccount().AccountID)
+			if err != nil {
+				return nil, err
+			}
+			return signedResponse(proto.StatusSuccess, issuer, revised)
+		})
+		tx := paymentTransaction(t, user.Address(), issuer.asset)
+		_, err := issuer.client.ApproveAndSign(context.Background(), tx, txnbuild.KeypairSigner{Full: user})
+		assert.EqualError(t, err, "approved transaction differs from the submitted transaction")
+	})
+
+	t.Run("unregulated", func(t *testing.T) {
+		issuer := newTestIssuer(t, nil)
+		tx := paymentTransaction(t, user.Address(), txnbuild.NativeAsset{})
+		signed, err := issuer.client.ApproveAndSign(context.Background(), tx, txnbuild.KeypairSigner{Full: user})
+		require.NoError(t, err)
+		assert.Len(t, signed.Signatures(), 1)
+	})
+}
+
+func TestClientApprove(t *testing.T) {
+	user := keypair.MustRandom()
+
+	t.Run("pending", func(t *testing.T) {
+		requests := 0
+		issuer := newTestIssuer(t, func(issuer *keypair.Full, tx *txnbuild.Transaction) (*proto.Response, error) {
+			requests++
+			if requests < 3 {
+				return &proto.Response{Status: proto.StatusPending, Timeout: 1}, nil
+			}
+			return signedResponse(proto.StatusSuccess, issuer, tx)
+		})
+		tx := paymentTransaction(t, user.Address(), issuer.asset)
+		assets, err := issuer.client.RegulatedAssets(tx)
+		require.NoError(t, err)
+		approved, err := issuer.client.Approve(context.Background(), assets[0], tx)
+		require.NoError(t, err)
+		assert.Len(t, approved.Signatures(), 1)
+		assert.Equal(t, 3, requests)
+	})
+
+	t.Run("pending until done", func(t *testing.T) {
+		issuer := newTestIssuer(t, func(issuer *keypair.Full, tx *txnbuild.Transaction) (*proto.Response, error) {
+			return &proto.Response{Status: proto.StatusPending, Timeout: 1}, nil
+		})
+		tx := paymentTransaction(t, user.Address(), issuer.asset)
+		assets, err := issuer.client.RegulatedAssets(tx)
+		require.NoError(t, err)
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err = issuer.client.Approve(ctx, assets[0], tx)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		issuer := newTestIssuer(t, func(issuer *keypair.Full, tx *txnbuild.Transaction) (*proto.Response, error) {
+			return &proto.Response{Status: proto.StatusRejected, Error: "limit exceeded"}, nil
+		})
+		tx := paymentTransaction(t, user.Address(), issuer.asset)
+		assets, err := issuer.client.RegulatedAssets(tx)
+		require.NoError(t, err)
+		_, err = issuer.client.Approve(context.Background(), assets[0], tx)
+		assert.Equal(t, &RejectedError{Message: "limit exceeded"}, err)
+	})
+
+	t.Run("action required", func(t *testing.T) {
+		issuer := newTestIssuer(t, func(issuer *keypair.Full, tx *txnbuild.Transaction) (*proto.Response, error) {
+			return &proto.Response{
+				Status:       proto.StatusActionRequired,
+				Message:      "provide your email",
+				ActionURL:    "https://issuer.example.com/action",
+				ActionMethod: http.MethodPost,
+				ActionFields: []string{"email_address"},
+			}, nil
+		})
+		tx := paymentTransaction(t, user.Address(), issuer.asset)
+		assets, err := issuer.client.RegulatedAssets(tx)
+		require.NoError(t, err)
+		_, err = issuer.client.Approve(context.Background(), assets[0], tx)
+		assert.Equal(t, &ActionRequiredError{
+			Message:      "provide your email",
+			ActionURL:    "https://issuer.example.com/action",
+			ActionMethod: http.MethodPost,
+			ActionFields: []string{"email_address"},
+		}, err)
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		issuer := newTestIssuer(t, func(issuer *keypair.Full, tx *txnbuild.Transaction) (*proto.Response, error) {
+			return nil, errors.New("database is down")
+		})
+		tx := paymentTransaction(t, user.Address(), issuer.asset)
+		assets, err := issuer.client.RegulatedAssets(tx)
+		require.NoError(t, err)
+		_, err = issuer.client.Approve(context.Background(), assets[0], tx)
+		assert.EqualError(t, err, "approval request failed with status 500")
+	})
+
+	t.Run("http", func(t *testing.T) {
+		issuer := newTestIssuer(t, nil)
+		issuer.client.AllowHTTP = false
+		tx := paymentTransaction(t, user.Address(), issuer.asset)
+		assets, err := issuer.client.RegulatedAssets(tx)
+		require.NoError(t, err)
+		_, err = issuer.client.Approve(context.Background(), assets[0], tx)
+		assert.EqualError(t, err, "url "+assets[0].ApprovalServer+" does not use https")
+	})
+}
+
+func TestClientAction(t *testing.T) {
+	var fields map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&fields))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"result":"no_further_action_required"}`))
+	}))
+	defer server.Close()
+	client := &Client{HTTP: server.Client(), AllowHTTP: true}
+
+	response, err := client.Action(context.Background(), &ActionRequiredError{
+		ActionURL:    server.URL,
+		ActionMethod: http.MethodPost,
+		ActionFields: []string{"email_address"},
+	}, map[string]string{"email_address": "user@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, &proto.ActionResponse{Result: proto.ActionResultNoFurtherActionRequired}, response)
+	assert.Equal(t, map[string]string{"email_address": "user@example.com"}, fields)
+
+	_, err = client.Action(context.Background(), &ActionRequiredError{
+		ActionURL:    server.URL,
+		ActionMethod: http.MethodGet,
+	}, nil)
+	assert.EqualError(t, err, "action with method GET must be completed in a browser at "+server.URL)
+}
@@ -0,0 +1,132 @@
+// Package approval provides a client for SEP-8 regulated assets. It detects
+// the regulated assets of a transaction from the stellar.toml of their
+// issuers, requests the approval of the issuers' approval servers, verifies
+// revised transactions only add authorization operations, and signs the
+// approved transaction.
+// https://github.com/stellar/stellar-protocol/blob/master/ecosystem/sep-0008.md
+package approval
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/stellar/go/clients/horizonclient"
+	"github.com/stellar/go/clients/stellartoml"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/support/errors"
+)
+
+// ResponseMaxSize is the maximum size of a response from an approval server.
+const ResponseMaxSize = 100 * 1024
+
+// DefaultTimeout is the timeout of a request to an approval server used
+// when Client.Timeout is zero.
+const DefaultTimeout = 30 * time.Second
+
+// DefaultPendingWait is how long Approve waits before submitting a pending
+// transaction again when the approval server does not specify it.
+const DefaultPendingWait = 10 * time.Second
+
+// ErrNoApprovalServer is returned when the stellar.toml of an issuer lists
+// a regulated asset without an APPROVAL_SERVER.
+var ErrNoApprovalServer = errors.New("regulated asset does not specify an APPROVAL_SERVER")
+
+// HTTP represents the http client that an approval client uses to make http
+// requests.
+type HTTP interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Client requests the approval of transactions with regulated assets.
+type Client struct {
+	// HTTP is the http client used to query approval servers.
+	HTTP HTTP
+
+	// StellarTOML resolves the stellar.toml of issuers to find their
+	// regulated assets.
+	StellarTOML stellartoml.ClientInterface
+
+	// Accounts loads the home domain of issuers.
+	Accounts stellartoml.AccountLookup
+
+	// NetworkPassphrase is the passphrase of the network the transactions
+	// are signed for.
+	NetworkPassphrase string
+
+	// Timeout bounds every request to an approval server. DefaultTimeout is
+	// used if it is zero.
+	Timeout time.Duration
+
+	// AllowHTTP allows approval servers using plain HTTP. Useful for
+	// debugging.
+	AllowHTTP bool
+}
+
+// DefaultPublicNetClient is a default client for the public network.
+var DefaultPublicNetClient = &Client{
+	HTTP:              http.DefaultClient,
+	StellarTOML:       stellartoml.DefaultClient,
+	Accounts:          HorizonAccounts{Client: horizonclient.DefaultPublicNetClient},
+	NetworkPassphrase: network.PublicNetworkPassphrase,
+}
+
+// DefaultTestNetClient is a default client for the test network.
+var DefaultTestNetClient = &Client{
+	HTTP:              http.DefaultClient,
+	StellarTOML:       stellartoml.DefaultClient,
+	Accounts:          HorizonAccounts{Client: horizonclient.DefaultTestNetClient},
+	NetworkPassphrase: network.TestNetworkPassphrase,
+}
+
+// HorizonAccounts loads the home domain of accounts from Horizon.
+type HorizonAccounts struct {
+	Client horizonclient.ClientInterface
+}
+
+// HomeDomain implements stellartoml.AccountLookup.
+func (h HorizonAccounts) HomeDomain(accountID string) (string, error) {
+	account, err := h.Client.AccountDetail(horizonclient.AccountRequest{AccountID: accountID})
+	if err != nil {
+		return "", errors.Wrapf(err, "load account %s failed", accountID)
+	}
+	return account.HomeDomain, nil
+}
+
+// RegulatedAsset is an asset whose issuer must approve the transactions
+// using it.
+type RegulatedAsset struct {
+	Code   string
+	Issuer string
+	// HomeDomain is the home domain of the issuer.
+	HomeDomain string
+	// ApprovalServer is the URL transactions are submitted to for
+	// approval.
+	ApprovalServer string
+	// ApprovalCriteria describes the transactions the issuer approves.
+	ApprovalCriteria string
+}
+
+// RejectedError is returned when an approval server rejects a transaction.
+type RejectedError struct {
+	Message string
+}
+
+func (e *RejectedError) Error() string {
+	return "transaction rejected by approval server: " + e.Message
+}
+
+// ActionRequiredError is returned when the user must complete an action
+// before the approval server approves a transaction. With the POST method
+// the action can be completed with Client.Action; with GET the user must
+// open ActionURL in a browser.
+type ActionRequiredError struct {
+	Message      string
+	ActionURL    string
+	ActionMethod string
+	ActionFields []string
+}
+
+func (e *ActionRequiredError) Error() string {
+	return fmt.Sprintf("approval server requires an action at %s: %s", e.ActionURL, e.Message)
+}
@@ -0,0 +1,105 @@
+package approval
+
+import (
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+)
+
+// authorizationFlags are the trust line flags a revised transaction may set
+// or clear.
+const authorizationFlags = xdr.TrustLineFlagsAuthorizedFlag | xdr.TrustLineFlagsAuthorizedToMaintainLiabilitiesFlag
+
+// VerifyRevision checks that revised only differs from original by
+// authorization operations of issuer added before and after the operations
+// of original, as approval servers do to authorize the accounts of a
+// transaction for its duration. The source account, sequence number, memo
+// and preconditions must be unchanged, and the fee per operation must not be
+// higher.
+func VerifyRevision(original, revised *txnbuild.Transaction, issuer string) error {
+	originalEnv, revisedEnv := original.ToXDR(), revised.ToXDR()
+
+	for _, field := range []struct {
+		name string
+		a, b interface{}
+	}{
+		{"source account", originalEnv.SourceAccount(), revisedEnv.SourceAccount()},
+		{"sequence number", originalEnv.SeqNum(), revisedEnv.SeqNum()},
+		{"memo", originalEnv.Memo(), revisedEnv.Memo()},
+		{"preconditions", originalEnv.Preconditions(), revisedEnv.Preconditions()},
+	} {
+		same, err := xdrEqual(field.a, field.b)
+		if err != nil {
+			return errors.Wrapf(err, "could not compare %s", field.name)
+		}
+		if !same {
+			return errors.Errorf("revised transaction changes the %s", field.name)
+		}
+	}
+
+	originalOps, revisedOps := originalEnv.Operations(), revisedEnv.Operations()
+	if revised.MaxFee() > original.BaseFee()*int64(len(revisedOps)) {
+		return errors.Errorf("revised transaction fee %d exceeds %d per operation", revised.MaxFee(), original.BaseFee())
+	}
+
+	start := -1
+	for i := 0; i+len(originalOps) <= len(revisedOps); i++ {
+		same, err := xdrEqual(originalOps, revisedOps[i:i+len(originalOps)])
+		if err != nil {
+			return errors.Wrap(err, "could not compare operations")
+		}
+		if same {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return errors.New("revised transaction does not contain the original operations")
+	}
+
+	for i, op := range revisedOps {
+		if i >= start && i < start+len(originalOps) {
+			continue
+		}
+		if err := verifyAuthorization(op, issuer); err != nil {
+			return errors.Wrapf(err, "revised transaction operation %d", i)
+		}
+	}
+	return nil
+}
+
+// verifyAuthorization checks that op only changes the authorization of a
+// trust line to an asset of issuer.
+func verifyAuthorization(op xdr.Operation, issuer string) error {
+	if op.SourceAccount == nil || op.SourceAccount.ToAccountId().Address() != issuer {
+		return errors.Errorf("is not from the issuer %s", issuer)
+	}
+	switch op.Body.Type {
+	case xdr.OperationTypeAllowTrust:
+		// The asset of an AllowTrust is always issued by its source.
+		return nil
+	case xdr.OperationTypeSetTrustLineFlags:
+		flags := op.Body.MustSetTrustLineFlagsOp()
+		if flags.Asset.GetIssuer() != issuer {
+			return errors.Errorf("changes the flags of %s which is not issued by %s", flags.Asset.StringCanonical(), issuer)
+		}
+		if xdr.TrustLineFlags(flags.SetFlags|flags.ClearFlags)&^authorizationFlags != 0 {
+			return errors.New("changes trust line flags other than authorization")
+		}
+		return nil
+	default:
+		return errors.Errorf("is a %s operation instead of an authorization", op.Body.Type)
+	}
+}
+
+func xdrEqual(a, b interface{}) (bool, error) {
+	aXDR, err := xdr.MarshalBase64(a)
+	if err != nil {
+		return false, err
+	}
+	bXDR, err := xdr.MarshalBase64(b)
+	if err != nil {
+		return false, err
+	}
+	return aXDR == bXDR, nil
+}
@@ -0,0 +1,121 @@
+package approval
+
+import (
+	"testing"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyRevision(t *testing.T) {
+	issuer := keypair.MustRandom().Address()
+	user := keypair.MustRandom().Address()
+	usd := txnbuild.CreditAsset{Code: "USD", Issuer: issuer}
+	payment := &txnbuild.Payment{Destination: issuer, Amount: "10", Asset: usd}
+	authorize := &txnbuild.SetTrustLineFlags{
+		Trustor:       user,
+		Asset:         usd,
+		SetFlags:      []txnbuild.TrustLineFlag{txnbuild.TrustLineAuthorized},
+		SourceAccount: issuer,
+	}
+	deauthorize := &txnbuild.SetTrustLineFlags{
+		Trustor:       user,
+		Asset:         usd,
+		SetFlags:      []txnbuild.TrustLineFlag{txnbuild.TrustLineAuthorizedToMaintainLiabilities},
+		ClearFlags:    []txnbuild.TrustLineFlag{txnbuild.TrustLineAuthorized},
+		SourceAccount: issuer,
+	}
+
+	build := func(sequence int64, baseFee int64, memo txnbuild.Memo, ops ...txnbuild.Operation) *txnbuild.Transaction {
+		tx, err := txnbuild.NewTransaction(txnbuild.TransactionParams{
+			SourceAccount: &txnbuild.SimpleAccount{AccountID: user, Sequence: sequence},
+			Operations:    ops,
+			BaseFee:       baseFee,
+			Memo:          memo,
+			Preconditions: txnbuild.Preconditions{TimeBounds: txnbuild.NewTimebounds(0, 1000)},
+		})
+		require.NoError(t, err)
+		return tx
+	}
+	original := build(42, 100, txnbuild.MemoID(1), payment)
+
+	for _, tc := range []struct {
+		name    string
+		revised *txnbuild.Transaction
+		err     string
+	}{
+		{
+			name:    "unchanged",
+			revised: build(42, 100, txnbuild.MemoID(1), payment),
+		},
+		{
+			name:    "authorized",
+			revised: build(42, 100, txnbuild.MemoID(1), authorize, payment, deauthorize),
+		},
+		{
+			name: "allow trust",
+			revised: build(42, 100, txnbuild.MemoID(1), &txnbuild.AllowTrust{
+				Trustor:       user,
+				Type:          usd,
+				Authorize:     true,
+				SourceAccount: issuer,
+			}, payment),
+		},
+		{
+			name:    "sequence number",
+			revised: build(43, 100, txnbuild.MemoID(1), payment),
+			err:     "revised transaction changes the sequence number",
+		},
+		{
+			name:    "memo",
+			revised: build(42, 100, txnbuild.MemoID(2), payment),
+			err:     "revised transaction changes the memo",
+		},
+		{
+			name:    "fee",
+			revised: build(42, 200, txnbuild.MemoID(1), authorize, payment),
+			err:     "revised transaction fee 400 exceeds 100 per operation",
+		},
+		{
+			name:    "missing operations",
+			revised: build(42, 100, txnbuild.MemoID(1), authorize),
+			err:     "revised transaction does not contain the original operations",
+		},
+		{
+			name: "other issuer",
+			revised: build(42, 100, txnbuild.MemoID(1), &txnbuild.SetTrustLineFlags{
+				Trustor:       user,
+				Asset:         txnbuild.CreditAsset{Code: "USD", Issuer: user},
+				SetFlags:      []txnbuild.TrustLineFlag{txnbuild.TrustLineAuthorized},
+				SourceAccount: issuer,
+			}, payment),
+			err: "revised transaction operation 0: changes the flags of USD:" + user + " which is not issued by " + issuer,
+		},
+		{
+			name: "clawback flag",
+			revised: build(42, 100, txnbuild.MemoID(1), payment, &txnbuild.SetTrustLineFlags{
+				Trustor:       user,
+				Asset:         usd,
+				ClearFlags:    []txnbuild.TrustLineFlag{txnbuild.TrustLineClawbackEnabled},
+				SourceAccount: issuer,
+			}),
+			err: "revised transaction operation 1: changes trust line flags other than authorization",
+		},
+		{
+			name:    "other operation",
+			revised: build(42, 100, txnbuild.MemoID(1), payment, &txnbuild.BumpSequence{SourceAccount: issuer}),
+			err:     "revised transaction operation 1: is a OperationTypeBumpSequence operation instead of an authorization",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := VerifyRevision(original, tc.revised, issuer)
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.err)
+			}
+		})
+	}
+}
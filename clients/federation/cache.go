@@ -0,0 +1,68 @@
+package federation
+
+import (
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+	proto "github.com/stellar/go/protocols/federation"
+)
+
+const (
+	// DefaultCacheSize is the number of lookups kept by the cache of the
+	// default clients.
+	DefaultCacheSize = 1000
+	// DefaultCacheTTL is the time lookups are kept by the cache of the
+	// default clients.
+	DefaultCacheTTL = 10 * time.Minute
+)
+
+// Cache is a size bounded cache of federation lookups, each kept for a
+// fixed time. It is safe for concurrent use.
+type Cache struct {
+	entries *lru.Cache
+	ttl     time.Duration
+	now     func() time.Time
+}
+
+type cacheEntry struct {
+	response proto.Response
+	expires  time.Time
+}
+
+// NewCache returns a Cache holding at most size lookups for ttl each.
+func NewCache(size int, ttl time.Duration) *Cache {
+	entries, err := lru.New(size)
+	if err != nil {
+		panic(err)
+	}
+	return &Cache{entries: entries, ttl: ttl, now: time.Now}
+}
+
+func (c *Cache) get(key string) (*proto.Response, bool) {
+	if c == nil {
+		return nil, false
+	}
+	value, ok := c.entries.Get(key)
+	if !ok {
+		return nil, false
+	}
+	entry := value.(cacheEntry)
+	if !c.now().Before(entry.expires) {
+		c.entries.Remove(key)
+		return nil, false
+	}
+	response := entry.response
+	return &response, true
+}
+
+func (c *Cache) add(key string, response *proto.Response) {
+	if c == nil {
+		return
+	}
+	c.entries.Add(key, cacheEntry{response: *response, expires: c.now().Add(c.ttl)})
+}
+
+// Purge removes all lookups from the cache.
+func (c *Cache) Purge() {
+	c.entries.Purge()
+}
@@ -0,0 +1,226 @@
+package federation
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/stellar/go/address"
+	"github.com/stellar/go/clients/horizonclient"
+	proto "github.com/stellar/go/protocols/federation"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/txnbuild"
+)
+
+// maxTextMemoSize is the maximum size in bytes of a text memo.
+const maxTextMemoSize = 28
+
+// LookupByAddress resolves a stellar address of the form name*domain with
+// the federation server of the domain.
+func (c *Client) LookupByAddress(ctx context.Context, addr string) (*proto.Response, error) {
+	_, domain, err := address.Split(addr)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse address failed")
+	}
+
+	cacheKey := proto.TypeName + ":" + addr
+	if response, ok := c.Cache.get(cacheKey); ok {
+		return response, nil
+	}
+
+	response, err := c.lookup(ctx, domain, url.Values{
+		"type": []string{proto.TypeName},
+		"q":    []string{addr},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if response.StellarAddress == "" {
+		response.StellarAddress = addr
+	}
+
+	c.Cache.add(cacheKey, response)
+	return response, nil
+}
+
+// LookupByAccountID resolves the stellar address of an account with the
+// federation server of domain. If domain is empty the home domain of the
+// account is loaded from Horizon.
+func (c *Client) LookupByAccountID(ctx context.Context, accountID, domain string) (*proto.Response, error) {
+	if !strkey.IsValidEd25519PublicKey(accountID) {
+		return nil, errors.Errorf("%s is not a valid account ID", accountID)
+	}
+	if domain == "" {
+		homeDomain, err := c.homeDomain(accountID)
+		if err != nil {
+			return nil, err
+		}
+		domain = homeDomain
+	}
+
+	cacheKey := proto.TypeID + ":" + domain + ":" + accountID
+	if response, ok := c.Cache.get(cacheKey); ok {
+		return response, nil
+	}
+
+	response, err := c.lookup(ctx, domain, url.Values{
+		"type": []string{proto.TypeID},
+		"q":    []string{accountID},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if response.AccountID != accountID {
+		return nil, errors.Errorf("federation server returned account %s instead of %s", response.AccountID, accountID)
+	}
+	if response.StellarAddress == "" {
+		return nil, errors.New("federation response has no stellar_address")
+	}
+
+	c.Cache.add(cacheKey, response)
+	return response, nil
+}
+
+func (c *Client) homeDomain(accountID string) (string, error) {
+	if c.Horizon == nil {
+		return "", ErrNoHomeDomain
+	}
+	account, err := c.Horizon.AccountDetail(horizonclient.AccountRequest{AccountID: accountID})
+	if err != nil {
+		return "", errors.Wrap(err, "load account failed")
+	}
+	if account.HomeDomain == "" {
+		return "", ErrNoHomeDomain
+	}
+	return account.HomeDomain, nil
+}
+
+// lookup queries the federation server of domain and validates its
+// response.
+func (c *Client) lookup(ctx context.Context, domain string, query url.Values) (*proto.Response, error) {
+	server, err := c.federationServer(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "build request failed")
+	}
+	hresp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "http request errored")
+	}
+	defer hresp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(hresp.Body, FederationResponseMaxSize+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "read response failed")
+	}
+	if len(body) > FederationResponseMaxSize {
+		return nil, errors.Errorf("federation response exceeds %d bytes limit", FederationResponseMaxSize)
+	}
+
+	if hresp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if !(hresp.StatusCode >= 200 && hresp.StatusCode < 300) {
+		var problem proto.ErrorResponse
+		if json.Unmarshal(body, &problem) == nil && problem.Detail != "" {
+			return nil, errors.Errorf("federation request failed with status %d: %s", hresp.StatusCode, problem.Detail)
+		}
+		return nil, errors.Errorf("federation request failed with status %d", hresp.StatusCode)
+	}
+
+	var response proto.Response
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, errors.Wrap(err, "json decode failed")
+	}
+	if err := validate(&response); err != nil {
+		return nil, errors.Wrap(err, "invalid federation response")
+	}
+	return &response, nil
+}
+
+func (c *Client) federationServer(domain string) (string, error) {
+	toml, err := c.StellarTOML.GetStellarToml(domain)
+	if err != nil {
+		return "", errors.Wrap(err, "get stellar.toml failed")
+	}
+	if toml.FederationServer == "" {
+		return "", ErrNoFederationServer
+	}
+
+	server, err := url.Parse(toml.FederationServer)
+	if err != nil {
+		return "", errors.Wrap(err, "parse FEDERATION_SERVER failed")
+	}
+	if server.Scheme != "https" && !(c.AllowHTTP && server.Scheme == "http") {
+		return "", errors.Errorf("FEDERATION_SERVER %s does not use https", toml.FederationServer)
+	}
+	if server.RawQuery != "" {
+		return "", errors.Errorf("FEDERATION_SERVER %s has a query", toml.FederationServer)
+	}
+	return toml.FederationServer, nil
+}
+
+// validate checks the account and memo of a federation response.
+func validate(response *proto.Response) error {
+	if !strkey.IsValidEd25519PublicKey(response.AccountID) &&
+		!strkey.IsValidMuxedAccountEd25519PublicKey(response.AccountID) {
+		return errors.Errorf("account_id %q is not a valid account", response.AccountID)
+	}
+	if response.StellarAddress != "" {
+		if _, _, err := address.Split(response.StellarAddress); err != nil {
+			return errors.Wrapf(err, "stellar_address %q", response.StellarAddress)
+		}
+	}
+	if response.MemoType == "" && response.Memo.Value != "" {
+		return errors.New("memo has no memo_type")
+	}
+	_, err := Memo(response)
+	return err
+}
+
+// Memo returns the memo of a federation response, or nil if it has none.
+func Memo(response *proto.Response) (txnbuild.Memo, error) {
+	value := response.Memo.Value
+	switch strings.ToLower(response.MemoType) {
+	case "":
+		return nil, nil
+	case proto.MemoTypeText:
+		if len(value) > maxTextMemoSize {
+			return nil, errors.Errorf("text memo is longer than %d bytes", maxTextMemoSize)
+		}
+		return txnbuild.MemoText(value), nil
+	case proto.MemoTypeID:
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("id memo %q is not a uint64", value)
+		}
+		return txnbuild.MemoID(id), nil
+	case proto.MemoTypeHash:
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(decoded) != 32 {
+			return nil, errors.Errorf("hash memo %q is not 32 base64 encoded bytes", value)
+		}
+		var hash txnbuild.MemoHash
+		copy(hash[:], decoded)
+		return hash, nil
+	default:
+		return nil, errors.Errorf("unknown memo_type %q", response.MemoType)
+	}
+}
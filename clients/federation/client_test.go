@@ -0,0 +1,200 @@
+package federation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stellar/go/clients/horizonclient"
+	"github.com/stellar/go/clients/stellartoml"
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAccountID = "GCCOBXW2XQNUSL467IEILE6MMCNRR66SSVL4YQADUNYYNUVREF3FIV2Z"
+
+// newTestClient returns a client whose example.com federation server
+// responds with the given status and body.
+func newTestClient(t *testing.T, handler http.HandlerFunc) (*Client, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	toml := &stellartoml.MockClient{}
+	toml.On("GetStellarToml", "example.com").
+		Return(&stellartoml.Response{FederationServer: server.URL + "/federation"}, nil)
+	toml.On("GetStellarToml", "notoml.com").
+		Return(&stellartoml.Response{}, nil)
+
+	return &Client{
+		HTTP:        http.DefaultClient,
+		StellarTOML: toml,
+		Cache:       NewCache(10, time.Minute),
+		AllowHTTP:   true,
+	}, &requests
+}
+
+func respond(status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}
+}
+
+func TestLookupByAddress(t *testing.T) {
+	client, requests := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "name", r.URL.Query().Get("type"))
+		assert.Equal(t, "alice*example.com", r.URL.Query().Get("q"))
+		_, _ = w.Write([]byte(`{"account_id":"` + testAccountID + `","memo_type":"id","memo":123}`))
+	})
+
+	response, err := client.LookupByAddress(context.Background(), "alice*example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "alice*example.com", response.StellarAddress)
+	assert.Equal(t, testAccountID, response.AccountID)
+	memo, err := Memo(response)
+	require.NoError(t, err)
+	assert.Equal(t, txnbuild.MemoID(123), memo)
+
+	// The second lookup is served from the cache.
+	_, err = client.LookupByAddress(context.Background(), "alice*example.com")
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+
+	_, err = client.LookupByAddress(context.Background(), "alice")
+	assert.EqualError(t, err, "parse address failed: invalid address")
+
+	_, err = client.LookupByAddress(context.Background(), "alice*notoml.com")
+	assert.Equal(t, ErrNoFederationServer, err)
+}
+
+func TestLookupErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		handler http.HandlerFunc
+		err     string
+	}{
+		{
+			name:    "not found",
+			handler: respond(http.StatusNotFound, `{"detail":"not found"}`),
+			err:     ErrNotFound.Error(),
+		},
+		{
+			name:    "server error",
+			handler: respond(http.StatusInternalServerError, `{"detail":"database is down"}`),
+			err:     "federation request failed with status 500: database is down",
+		},
+		{
+			name:    "too large",
+			handler: respond(http.StatusOK, strings.Repeat(" ", FederationResponseMaxSize+1)),
+			err:     "federation response exceeds 102400 bytes limit",
+		},
+		{
+			name:    "invalid account",
+			handler: respond(http.StatusOK, `{"account_id":"GABC"}`),
+			err:     `invalid federation response: account_id "GABC" is not a valid account`,
+		},
+		{
+			name:    "long text memo",
+			handler: respond(http.StatusOK, `{"account_id":"`+testAccountID+`","memo_type":"text","memo":"`+strings.Repeat("a", 29)+`"}`),
+			err:     "invalid federation response: text memo is longer than 28 bytes",
+		},
+		{
+			name:    "bad hash memo",
+			handler: respond(http.StatusOK, `{"account_id":"`+testAccountID+`","memo_type":"hash","memo":"abc"}`),
+			err:     `invalid federation response: hash memo "abc" is not 32 base64 encoded bytes`,
+		},
+		{
+			name:    "memo without type",
+			handler: respond(http.StatusOK, `{"account_id":"`+testAccountID+`","memo":"abc"}`),
+			err:     "invalid federation response: memo has no memo_type",
+		},
+		{
+			name: "timeout",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+			},
+			err: "http request errored",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			client, _ := newTestClient(t, tc.handler)
+			client.Timeout = 50 * time.Millisecond
+			_, err := client.LookupByAddress(context.Background(), "alice*example.com")
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.err)
+		})
+	}
+}
+
+func TestLookupRequiresHTTPS(t *testing.T) {
+	client, requests := newTestClient(t, respond(http.StatusOK, `{}`))
+	client.AllowHTTP = false
+	_, err := client.LookupByAddress(context.Background(), "alice*example.com")
+	assert.ErrorContains(t, err, "does not use https")
+	assert.Equal(t, int32(0), atomic.LoadInt32(requests))
+}
+
+func TestLookupByAccountID(t *testing.T) {
+	client, requests := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "id", r.URL.Query().Get("type"))
+		assert.Equal(t, testAccountID, r.URL.Query().Get("q"))
+		_, _ = w.Write([]byte(`{"stellar_address":"alice*example.com","account_id":"` + testAccountID + `"}`))
+	})
+
+	_, err := client.LookupByAccountID(context.Background(), testAccountID, "")
+	assert.Equal(t, ErrNoHomeDomain, err)
+
+	horizon := &horizonclient.MockClient{}
+	horizon.On("AccountDetail", horizonclient.AccountRequest{AccountID: testAccountID}).
+		Return(hProtocol.Account{AccountID: testAccountID, HomeDomain: "example.com"}, nil)
+	client.Horizon = horizon
+
+	response, err := client.LookupByAccountID(context.Background(), testAccountID, "")
+	require.NoError(t, err)
+	assert.Equal(t, "alice*example.com", response.StellarAddress)
+
+	response, err = client.LookupByAccountID(context.Background(), testAccountID, "example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "alice*example.com", response.StellarAddress)
+	assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+
+	_, err = client.LookupByAccountID(context.Background(), "GABC", "example.com")
+	assert.EqualError(t, err, "GABC is not a valid account ID")
+}
+
+func TestCacheExpires(t *testing.T) {
+	cache := NewCache(1, time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	client, requests := newTestClient(t, respond(http.StatusOK, `{"account_id":"`+testAccountID+`"}`))
+	client.Cache = cache
+
+	_, err := client.LookupByAddress(context.Background(), "alice*example.com")
+	require.NoError(t, err)
+	_, err = client.LookupByAddress(context.Background(), "alice*example.com")
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+
+	now = now.Add(time.Minute)
+	_, err = client.LookupByAddress(context.Background(), "alice*example.com")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(requests))
+
+	// The cache holds a single lookup.
+	_, err = client.LookupByAddress(context.Background(), "bob*example.com")
+	require.NoError(t, err)
+	_, err = client.LookupByAddress(context.Background(), "alice*example.com")
+	require.NoError(t, err)
+	assert.Equal(t, int32(4), atomic.LoadInt32(requests))
+}
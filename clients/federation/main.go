@@ -0,0 +1,95 @@
+// Package federation provides a client for the SEP-2 federation protocol,
+// which resolves stellar addresses of the form name*domain to account IDs
+// and memos, and account IDs back to stellar addresses.
+// https://github.com/stellar/stellar-protocol/blob/master/ecosystem/sep-0002.md
+package federation
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/stellar/go/clients/horizonclient"
+	"github.com/stellar/go/clients/stellartoml"
+	proto "github.com/stellar/go/protocols/federation"
+	"github.com/stellar/go/support/errors"
+)
+
+// FederationResponseMaxSize is the maximum size of a response from a
+// federation server.
+const FederationResponseMaxSize = 100 * 1024
+
+// DefaultTimeout is the timeout of a federation request used when
+// Client.Timeout is zero.
+const DefaultTimeout = 10 * time.Second
+
+var (
+	// ErrNotFound is returned when the federation server does not know the
+	// requested address or account.
+	ErrNotFound = errors.New("federation record not found")
+
+	// ErrNoFederationServer is returned when the stellar.toml of a domain
+	// has no FEDERATION_SERVER.
+	ErrNoFederationServer = errors.New("stellar.toml does not specify a FEDERATION_SERVER")
+
+	// ErrNoHomeDomain is returned by LookupByAccountID when no domain is
+	// given and the home domain of the account is not set or cannot be
+	// loaded.
+	ErrNoHomeDomain = errors.New("account has no home domain")
+)
+
+// HTTP represents the http client that a federation client uses to make
+// http requests.
+type HTTP interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Client resolves stellar addresses and account IDs with the federation
+// server of their domain.
+type Client struct {
+	// HTTP is the http client used to query federation servers.
+	HTTP HTTP
+
+	// StellarTOML resolves the stellar.toml of a domain to find its
+	// FEDERATION_SERVER.
+	StellarTOML stellartoml.ClientInterface
+
+	// Horizon is used by LookupByAccountID to load the home domain of an
+	// account when no domain is given. It is optional.
+	Horizon horizonclient.ClientInterface
+
+	// Cache stores successful lookups. Results are not cached if it is nil.
+	Cache *Cache
+
+	// Timeout bounds every federation request. DefaultTimeout is used if it
+	// is zero.
+	Timeout time.Duration
+
+	// AllowHTTP allows federation servers using plain HTTP. Useful for
+	// debugging.
+	AllowHTTP bool
+}
+
+// ClientInterface is the interface implemented by Client.
+type ClientInterface interface {
+	LookupByAddress(ctx context.Context, address string) (*proto.Response, error)
+	LookupByAccountID(ctx context.Context, accountID, domain string) (*proto.Response, error)
+}
+
+// DefaultPublicNetClient is a default client for the public network.
+var DefaultPublicNetClient = &Client{
+	HTTP:        http.DefaultClient,
+	StellarTOML: stellartoml.DefaultClient,
+	Horizon:     horizonclient.DefaultPublicNetClient,
+	Cache:       NewCache(DefaultCacheSize, DefaultCacheTTL),
+}
+
+// DefaultTestNetClient is a default client for the test network.
+var DefaultTestNetClient = &Client{
+	HTTP:        http.DefaultClient,
+	StellarTOML: stellartoml.DefaultClient,
+	Horizon:     horizonclient.DefaultTestNetClient,
+	Cache:       NewCache(DefaultCacheSize, DefaultCacheTTL),
+}
+
+var _ ClientInterface = &Client{}
@@ -0,0 +1,271 @@
+package stellarcore
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	proto "github.com/stellar/go/protocols/stellarcore"
+	"github.com/stellar/go/support/errors"
+)
+
+// Peers calls the `peers` command on the connected stellar core and returns
+// its authenticated and pending peers.
+func (c *Client) Peers(ctx context.Context, request proto.PeersRequest) (*proto.PeersResponse, error) {
+	q := url.Values{}
+	q.Set("fullkeys", strconv.FormatBool(request.FullKeys))
+	q.Set("compact", strconv.FormatBool(request.Compact))
+
+	var resp proto.PeersResponse
+	if err := c.getJSON(ctx, "peers", q, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Quorum calls the `quorum` command on the connected stellar core and
+// returns the state of the quorum set of a node, with the transitive quorum
+// intersection analysis of the network if requested.
+func (c *Client) Quorum(ctx context.Context, request proto.QuorumRequest) (*proto.QuorumResponse, error) {
+	q := url.Values{}
+	if request.Node != "" {
+		q.Set("node", request.Node)
+	}
+	q.Set("compact", "false")
+	q.Set("fullkeys", strconv.FormatBool(request.FullKeys))
+	q.Set("transitive", strconv.FormatBool(request.Transitive))
+
+	var resp proto.QuorumResponse
+	if err := c.getJSON(ctx, "quorum", q, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// SCP calls the `scp` command on the connected stellar core and returns the
+// SCP state of its most recent slots.
+func (c *Client) SCP(ctx context.Context, request proto.SCPRequest) (*proto.SCPResponse, error) {
+	q := url.Values{}
+	if request.Limit > 0 {
+		q.Set("limit", strconv.Itoa(request.Limit))
+	}
+	q.Set("fullkeys", strconv.FormatBool(request.FullKeys))
+
+	var resp proto.SCPResponse
+	if err := c.getJSON(ctx, "scp", q, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Metrics calls the `metrics` command on the connected stellar core.
+func (c *Client) Metrics(ctx context.Context) (*proto.MetricsResponse, error) {
+	var resp proto.MetricsResponse
+	if err := c.getJSON(ctx, "metrics", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ClearMetrics calls the `clearmetrics` command on the connected stellar
+// core, resetting the metrics of domain, or all metrics if domain is empty.
+func (c *Client) ClearMetrics(ctx context.Context, domain string) error {
+	q := url.Values{}
+	if domain != "" {
+		q.Set("domain", domain)
+	}
+	return c.command(ctx, "clearmetrics", q)
+}
+
+// Maintenance calls the `maintenance` command on the connected stellar core,
+// deleting up to count old history rows. stellar-core's default is used if
+// count is zero.
+func (c *Client) Maintenance(ctx context.Context, count uint32) error {
+	q := url.Values{}
+	q.Set("queue", "true")
+	if count > 0 {
+		q.Set("count", strconv.FormatUint(uint64(count), 10))
+	}
+	return c.command(ctx, "maintenance", q)
+}
+
+// LogRotate calls the `logrotate` command on the connected stellar core,
+// reopening its log file.
+func (c *Client) LogRotate(ctx context.Context) error {
+	return c.command(ctx, "logrotate", nil)
+}
+
+// LogLevels calls the `ll` command on the connected stellar core and
+// returns the log level of each partition.
+func (c *Client) LogLevels(ctx context.Context) (proto.LogLevelsResponse, error) {
+	var resp proto.LogLevelsResponse
+	if err := c.getJSON(ctx, "ll", nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// SetLogLevel calls the `ll` command on the connected stellar core to change
+// the log level of a partition, or of all partitions.
+func (c *Client) SetLogLevel(ctx context.Context, request proto.LogLevelRequest) error {
+	if request.Level == "" {
+		return errors.New("log level is required")
+	}
+	q := url.Values{}
+	q.Set("level", request.Level)
+	if request.Partition != "" {
+		q.Set("partition", request.Partition)
+	}
+	return c.command(ctx, "ll", q)
+}
+
+// SurveyTopology calls the legacy `surveytopology` command on the connected
+// stellar core, adding a node to the running survey.
+func (c *Client) SurveyTopology(ctx context.Context, request proto.SurveyTopologyRequest) error {
+	q := url.Values{}
+	q.Set("node", request.Node)
+	q.Set("duration", strconv.Itoa(request.Duration))
+	return c.command(ctx, "surveytopology", q)
+}
+
+// StopSurvey calls the legacy `stopsurvey` command on the connected stellar
+// core.
+func (c *Client) StopSurvey(ctx context.Context) error {
+	return c.command(ctx, "stopsurvey", nil)
+}
+
+// StartSurveyCollecting calls the `startsurveycollecting` command on the
+// connected stellar core, starting the collecting phase of a time sliced
+// survey identified by nonce.
+func (c *Client) StartSurveyCollecting(ctx context.Context, nonce uint32) error {
+	q := url.Values{}
+	q.Set("nonce", strconv.FormatUint(uint64(nonce), 10))
+	return c.command(ctx, "startsurveycollecting", q)
+}
+
+// StopSurveyCollecting calls the `stopsurveycollecting` command on the
+// connected stellar core, ending the collecting phase of the running time
+// sliced survey and starting its reporting phase.
+func (c *Client) StopSurveyCollecting(ctx context.Context) error {
+	return c.command(ctx, "stopsurveycollecting", nil)
+}
+
+// SurveyTopologyTimeSliced calls the `surveytopologytimesliced` command on
+// the connected stellar core, requesting the survey data of a node during
+// the reporting phase of a time sliced survey.
+func (c *Client) SurveyTopologyTimeSliced(ctx context.Context, request proto.SurveyTopologyTimeSlicedRequest) error {
+	q := url.Values{}
+	q.Set("node", request.Node)
+	q.Set("inboundpeerindex", strconv.Itoa(request.InboundPeerIndex))
+	q.Set("outboundpeerindex", strconv.Itoa(request.OutboundPeerIndex))
+	return c.command(ctx, "surveytopologytimesliced", q)
+}
+
+// GetSurveyResult calls the `getsurveyresult` command on the connected
+// stellar core and returns the results of the survey collected so far.
+func (c *Client) GetSurveyResult(ctx context.Context) (*proto.SurveyResultResponse, error) {
+	var resp proto.SurveyResultResponse
+	if err := c.getJSON(ctx, "getsurveyresult", nil, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// SorobanInfo calls the `sorobaninfo` command on the connected stellar core
+// and returns the Soroban network settings.
+func (c *Client) SorobanInfo(ctx context.Context) (*proto.SorobanInfoResponse, error) {
+	q := url.Values{}
+	q.Set("format", "basic")
+
+	var resp proto.SorobanInfoResponse
+	if err := c.getJSON(ctx, "sorobaninfo", q, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GenerateLoad calls the `generateload` command on the connected stellar
+// core.
+func (c *Client) GenerateLoad(ctx context.Context, request proto.GenerateLoadRequest) (*proto.GenerateLoadResponse, error) {
+	q := url.Values{}
+	if request.Mode != "" {
+		q.Set("mode", request.Mode)
+	}
+	for name, value := range map[string]uint32{
+		"accounts":      request.Accounts,
+		"offset":        request.Offset,
+		"txs":           request.Txs,
+		"txrate":        request.TxRate,
+		"spikesize":     request.SpikeSize,
+		"spikeinterval": request.SpikeInterval,
+		"maxfeerate":    request.MaxFeeRate,
+	} {
+		if value > 0 {
+			q.Set(name, strconv.FormatUint(uint64(value), 10))
+		}
+	}
+	if request.SkipLowFeeTxs {
+		q.Set("skiplowfeetxs", "true")
+	}
+
+	var resp proto.GenerateLoadResponse
+	if err := c.getJSON(ctx, "generateload", q, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// command calls a stellar-core command which responds with a plain text
+// message on success, and returns an error if the response is an exception.
+func (c *Client) command(ctx context.Context, command string, query url.Values) error {
+	_, err := c.get(ctx, command, query)
+	return err
+}
+
+// getJSON calls a stellar-core command and decodes its json response into
+// target.
+func (c *Client) getJSON(ctx context.Context, command string, query url.Values, target interface{}) error {
+	raw, err := c.get(ctx, command, query)
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(json.Unmarshal(raw, target), "json decode failed")
+}
+
+// get calls a stellar-core command and returns its response body. stellar-core
+// reports failed commands with a json exception and a 200 status code, which
+// get turns into an error.
+func (c *Client) get(ctx context.Context, command string, query url.Values) (raw []byte, err error) {
+	var req *http.Request
+	req, err = c.simpleGet(ctx, command, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+
+	var hresp *http.Response
+	hresp, err = c.http().Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "http request errored")
+	}
+	defer drainReponse(hresp, true, &err) //nolint:errcheck
+
+	if hresp.StatusCode < 200 || hresp.StatusCode >= 300 {
+		return nil, errors.Errorf("http request failed with non-200 status code (%d)", hresp.StatusCode)
+	}
+
+	raw, err = io.ReadAll(hresp.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read response")
+	}
+
+	exception := struct {
+		Exception string `json:"exception"`
+	}{}
+	if json.Unmarshal(raw, &exception) == nil && exception.Exception != "" {
+		return nil, errors.Errorf("exception in response: %s", exception.Exception)
+	}
+	return raw, nil
+}
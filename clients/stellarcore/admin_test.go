@@ -0,0 +1,245 @@
+package stellarcore
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	proto "github.com/stellar/go/protocols/stellarcore"
+	"github.com/stellar/go/support/http/httptest"
+)
+
+func TestPeers(t *testing.T) {
+	hmock := httptest.NewClient()
+	c := &Client{HTTP: hmock, URL: "http://localhost:11626"}
+
+	hmock.On("GET", "http://localhost:11626/peers?compact=true&fullkeys=true").
+		ReturnString(http.StatusOK, `{
+			"authenticated_peers": {
+				"inbound": [{"address": "1.2.3.4:11625", "elapsed": 12, "id": "GA", "latency": 80, "olver": 35, "ver": "v21.0.0"}],
+				"outbound": null
+			},
+			"pending_peers": {"inbound": null, "outbound": ["5.6.7.8:11625"]}
+		}`)
+
+	resp, err := c.Peers(context.Background(), proto.PeersRequest{FullKeys: true, Compact: true})
+	require.NoError(t, err)
+	assert.Equal(t, []proto.Peer{{
+		Address:        "1.2.3.4:11625",
+		ID:             "GA",
+		Elapsed:        12,
+		Latency:        80,
+		Version:        "v21.0.0",
+		OverlayVersion: 35,
+	}}, resp.AuthenticatedPeers.Inbound)
+	assert.Equal(t, []string{"5.6.7.8:11625"}, resp.PendingPeers.Outbound)
+}
+
+func TestQuorum(t *testing.T) {
+	hmock := httptest.NewClient()
+	c := &Client{HTTP: hmock, URL: "http://localhost:11626"}
+
+	hmock.On("GET", "http://localhost:11626/quorum?compact=false&fullkeys=false&node=GB&transitive=true").
+		ReturnString(http.StatusOK, `{
+			"node": "GB",
+			"qset": {
+				"agree": 3,
+				"delayed": null,
+				"disagree": null,
+				"fail_at": 1,
+				"fail_with": ["GC"],
+				"hash": "abc",
+				"lag_ms": {"GB": 0, "GC": 120, "GE": 35},
+				"ledger": 42,
+				"missing": ["GD"],
+				"phase": "EXTERNALIZE",
+				"validated": true,
+				"value": {"t": 2, "v": ["GB", "GC", {"t": 1, "v": ["GD", "GE"]}]}
+			},
+			"transitive": {
+				"intersection": false, "node_count": 5, "last_check_ledger": 40, "last_good_ledger": 30,
+				"last_check_inputs_hash": "def", "potential_split": [["GB"], ["GC"]]
+			}
+		}`)
+
+	resp, err := c.Quorum(context.Background(), proto.QuorumRequest{Node: "GB", Transitive: true})
+	require.NoError(t, err)
+	assert.Equal(t, "GB", resp.Node)
+	assert.Equal(t, uint32(42), resp.QSet.Ledger)
+	assert.Equal(t, []string{"GD"}, resp.QSet.Missing)
+	assert.Equal(t, map[string]int64{"GB": 0, "GC": 120, "GE": 35}, resp.QSet.LagMs)
+	assert.Equal(t, proto.QuorumSet{
+		Threshold:  2,
+		Validators: []string{"GB", "GC"},
+		InnerSets:  []proto.QuorumSet{{Threshold: 1, Validators: []string{"GD", "GE"}}},
+	}, resp.QSet.Value)
+	require.NotNil(t, resp.Transitive)
+	assert.False(t, resp.Transitive.Intersection)
+	assert.Equal(t, [][]string{{"GB"}, {"GC"}}, resp.Transitive.PotentialSplit)
+}
+
+func TestSCP(t *testing.T) {
+	hmock := httptest.NewClient()
+	c := &Client{HTTP: hmock, URL: "http://localhost:11626"}
+
+	hmock.On("GET", "http://localhost:11626/scp?fullkeys=false&limit=1").
+		ReturnString(http.StatusOK, `{"you": "GB", "42": {"index": 42, "validated": true, "ballotProtocol": {"phase": "EXTERNALIZE"}}}`)
+
+	resp, err := c.SCP(context.Background(), proto.SCPRequest{Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "GB", resp.You)
+	require.Contains(t, resp.Slots, uint32(42))
+	assert.True(t, resp.Slots[42].Validated)
+	assert.JSONEq(t, `{"phase": "EXTERNALIZE"}`, string(resp.Slots[42].BallotProtocol))
+}
+
+func TestMetrics(t *testing.T) {
+	hmock := httptest.NewClient()
+	c := &Client{HTTP: hmock, URL: "http://localhost:11626"}
+
+	hmock.On("GET", "http://localhost:11626/metrics").
+		ReturnString(http.StatusOK, `{"metrics": {
+			"ledger.ledger.close": {"type": "timer", "count": 10, "mean": 4.5, "99%": 9.1, "1_min_rate": 0.2},
+			"overlay.memory.flood-known": {"type": "counter", "count": 3}
+		}}`)
+
+	resp, err := c.Metrics(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, proto.Metric{Type: proto.MetricTypeTimer, Count: 10, Mean: 4.5, P99: 9.1, OneMinRate: 0.2}, resp.Metrics["ledger.ledger.close"])
+	assert.Equal(t, proto.Metric{Type: proto.MetricTypeCounter, Count: 3}, resp.Metrics["overlay.memory.flood-known"])
+}
+
+func TestLogLevels(t *testing.T) {
+	hmock := httptest.NewClient()
+	c := &Client{HTTP: hmock, URL: "http://localhost:11626"}
+
+	hmock.On("GET", "http://localhost:11626/ll").
+		ReturnString(http.StatusOK, `{"Global": "INFO", "Overlay": "DEBUG"}`)
+	hmock.On("GET", "http://localhost:11626/ll?level=DEBUG&partition=Overlay").
+		ReturnString(http.StatusOK, `{"Overlay": "DEBUG"}`)
+
+	levels, err := c.LogLevels(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, proto.LogLevelsResponse{"Global": proto.LogLevelInfo, "Overlay": proto.LogLevelDebug}, levels)
+
+	err = c.SetLogLevel(context.Background(), proto.LogLevelRequest{Level: proto.LogLevelDebug, Partition: "Overlay"})
+	assert.NoError(t, err)
+
+	err = c.SetLogLevel(context.Background(), proto.LogLevelRequest{})
+	assert.EqualError(t, err, "log level is required")
+}
+
+func TestCommands(t *testing.T) {
+	hmock := httptest.NewClient()
+	c := &Client{HTTP: hmock, URL: "http://localhost:11626"}
+	ctx := context.Background()
+
+	hmock.On("GET", "http://localhost:11626/clearmetrics?domain=overlay").
+		ReturnString(http.StatusOK, "Cleared overlay metrics!")
+	hmock.On("GET", "http://localhost:11626/maintenance?count=100&queue=true").
+		ReturnString(http.StatusOK, "Done")
+	hmock.On("GET", "http://localhost:11626/logrotate").
+		ReturnString(http.StatusOK, "Log rotate...")
+	hmock.On("GET", "http://localhost:11626/startsurveycollecting?nonce=7").
+		ReturnString(http.StatusOK, "Requested network to start survey collecting.")
+	hmock.On("GET", "http://localhost:11626/stopsurveycollecting").
+		ReturnString(http.StatusOK, "Requested network to stop survey collecting.")
+	hmock.On("GET", "http://localhost:11626/surveytopologytimesliced?inboundpeerindex=0&node=GB&outboundpeerindex=25").
+		ReturnString(http.StatusOK, "Adding node.")
+	hmock.On("GET", "http://localhost:11626/surveytopology?duration=60&node=GB").
+		ReturnString(http.StatusOK, `{"exception": "survey already running"}`)
+	hmock.On("GET", "http://localhost:11626/stopsurvey").
+		ReturnString(http.StatusInternalServerError, "")
+
+	assert.NoError(t, c.ClearMetrics(ctx, "overlay"))
+	assert.NoError(t, c.Maintenance(ctx, 100))
+	assert.NoError(t, c.LogRotate(ctx))
+	assert.NoError(t, c.StartSurveyCollecting(ctx, 7))
+	assert.NoError(t, c.StopSurveyCollecting(ctx))
+	assert.NoError(t, c.SurveyTopologyTimeSliced(ctx, proto.SurveyTopologyTimeSlicedRequest{Node: "GB", OutboundPeerIndex: 25}))
+	assert.EqualError(t, c.SurveyTopology(ctx, proto.SurveyTopologyRequest{Node: "GB", Duration: 60}),
+		"exception in response: survey already running")
+	assert.EqualError(t, c.StopSurvey(ctx), "http request failed with non-200 status code (500)")
+}
+
+func TestGetSurveyResult(t *testing.T) {
+	hmock := httptest.NewClient()
+	c := &Client{HTTP: hmock, URL: "http://localhost:11626"}
+
+	hmock.On("GET", "http://localhost:11626/getsurveyresult").
+		ReturnString(http.StatusOK, `{
+			"backlog": ["GD"],
+			"badResponseNodes": null,
+			"surveyInProgress": true,
+			"topology": {
+				"GB": {
+					"inboundPeers": [{"nodeId": "GC", "version": "v21.0.0", "averageLatencyMs": 90, "bytesRead": 100}],
+					"outboundPeers": [],
+					"totalInboundPeerCount": 1,
+					"isValidator": true,
+					"p75SCPFirstToSelfLatencyMs": 300
+				}
+			}
+		}`)
+
+	resp, err := c.GetSurveyResult(context.Background())
+	require.NoError(t, err)
+	assert.True(t, resp.SurveyInProgress)
+	assert.Equal(t, []string{"GD"}, resp.Backlog)
+	assert.Equal(t, proto.SurveyNode{
+		InboundPeers:               []proto.SurveyPeer{{NodeID: "GC", Version: "v21.0.0", AverageLatencyMs: 90, BytesRead: 100}},
+		OutboundPeers:              []proto.SurveyPeer{},
+		TotalInboundPeerCount:      1,
+		IsValidator:                true,
+		P75SCPFirstToSelfLatencyMs: 300,
+	}, resp.Topology["GB"])
+}
+
+func TestSorobanInfo(t *testing.T) {
+	hmock := httptest.NewClient()
+	c := &Client{HTTP: hmock, URL: "http://localhost:11626"}
+
+	hmock.On("GET", "http://localhost:11626/sorobaninfo?format=basic").
+		ReturnString(http.StatusOK, `{
+			"max_contract_size": 65536,
+			"tx": {"max_instructions": 100000000, "memory_limit": 41943040},
+			"ledger": {"max_tx_count": 100},
+			"fee_read_1kb": 1786,
+			"state_archival": {"max_entry_ttl": 3110400}
+		}`)
+
+	resp, err := c.SorobanInfo(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, uint32(65536), resp.MaxContractSize)
+	assert.Equal(t, int64(100000000), resp.Tx.MaxInstructions)
+	assert.Equal(t, uint32(100), resp.Ledger.MaxTxCount)
+	assert.Equal(t, int64(1786), resp.FeeRead1KB)
+	assert.Equal(t, uint32(3110400), resp.StateArchival.MaxEntryTTL)
+}
+
+func TestGenerateLoad(t *testing.T) {
+	hmock := httptest.NewClient()
+	c := &Client{HTTP: hmock, URL: "http://localhost:11626"}
+
+	hmock.On("GET", "http://localhost:11626/generateload?accounts=1000&mode=create&txrate=10").
+		ReturnString(http.StatusOK, `{"status": "Running load generation"}`)
+	hmock.On("GET", "http://localhost:11626/generateload?mode=pay&skiplowfeetxs=true").
+		ReturnString(http.StatusOK, `{"exception": "Set ARTIFICIALLY_GENERATE_LOAD_FOR_TESTING=true"}`)
+
+	resp, err := c.GenerateLoad(context.Background(), proto.GenerateLoadRequest{
+		Mode:     proto.GenerateLoadModeCreate,
+		Accounts: 1000,
+		TxRate:   10,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Running load generation", resp.Status)
+
+	_, err = c.GenerateLoad(context.Background(), proto.GenerateLoadRequest{
+		Mode:          proto.GenerateLoadModePay,
+		SkipLowFeeTxs: true,
+	})
+	assert.EqualError(t, err, "exception in response: Set ARTIFICIALLY_GENERATE_LOAD_FOR_TESTING=true")
+}
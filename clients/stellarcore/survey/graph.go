@@ -0,0 +1,260 @@
+package survey
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	proto "github.com/stellar/go/protocols/stellarcore"
+)
+
+// Graph is the peer graph of the network assembled from survey results.
+type Graph struct {
+	nodes map[string]*Node
+	edges map[edgeKey]*Edge
+}
+
+// Node is a node of the network. Nodes which did not respond to the survey
+// are only known from the reports of their peers.
+type Node struct {
+	ID string `json:"id"`
+	// Version is the stellar-core version reported by the peers of the
+	// node.
+	Version string `json:"version,omitempty"`
+	// Responded reports whether the node responded to the survey. The
+	// following statistics are only set for nodes which responded.
+	Responded                  bool   `json:"responded"`
+	IsValidator                bool   `json:"is_validator"`
+	TotalInboundPeers          int    `json:"total_inbound_peers"`
+	TotalOutboundPeers         int    `json:"total_outbound_peers"`
+	LostSyncCount              int    `json:"lost_sync_count"`
+	P75SCPFirstToSelfLatencyMs uint64 `json:"p75_scp_first_to_self_latency_ms"`
+	P75SCPSelfToOtherLatencyMs uint64 `json:"p75_scp_self_to_other_latency_ms"`
+
+	// Degree is the number of peers of the node in the graph.
+	Degree int `json:"degree"`
+	// AverageLatencyMs is the average latency of the connections of the
+	// node which reported one.
+	AverageLatencyMs uint64 `json:"average_latency_ms,omitempty"`
+}
+
+// Edge is a connection between two nodes, from the node which initiated it.
+type Edge struct {
+	From             string `json:"from"`
+	To               string `json:"to"`
+	AverageLatencyMs uint64 `json:"average_latency_ms,omitempty"`
+	SecondsConnected uint64 `json:"seconds_connected"`
+}
+
+type edgeKey struct {
+	from, to string
+}
+
+// NewGraph returns an empty graph.
+func NewGraph() *Graph {
+	return &Graph{nodes: map[string]*Node{}, edges: map[edgeKey]*Edge{}}
+}
+
+// AddResult adds the nodes and connections of a survey result to the graph
+// and reports whether the graph changed. Results are cumulative, so the
+// latest result of a survey supersedes the previous ones.
+func (g *Graph) AddResult(result *proto.SurveyResultResponse) bool {
+	changed := false
+	for _, id := range sortedKeys(result.Topology) {
+		data := result.Topology[id]
+		node := g.node(id, &changed)
+		updated := *node
+		updated.Responded = true
+		updated.IsValidator = data.IsValidator
+		updated.TotalInboundPeers = max(data.TotalInboundPeerCount, data.NumTotalInboundPeers)
+		updated.TotalOutboundPeers = max(data.TotalOutboundPeerCount, data.NumTotalOutboundPeers)
+		updated.LostSyncCount = data.LostSyncCount
+		updated.P75SCPFirstToSelfLatencyMs = data.P75SCPFirstToSelfLatencyMs
+		updated.P75SCPSelfToOtherLatencyMs = data.P75SCPSelfToOtherLatencyMs
+		if updated != *node {
+			*node = updated
+			changed = true
+		}
+
+		for _, peer := range data.InboundPeers {
+			g.addPeer(peer, edgeKey{from: peer.NodeID, to: id}, &changed)
+		}
+		for _, peer := range data.OutboundPeers {
+			g.addPeer(peer, edgeKey{from: id, to: peer.NodeID}, &changed)
+		}
+	}
+	if changed {
+		g.updateStats()
+	}
+	return changed
+}
+
+func (g *Graph) node(id string, changed *bool) *Node {
+	node, ok := g.nodes[id]
+	if !ok {
+		node = &Node{ID: id}
+		g.nodes[id] = node
+		*changed = true
+	}
+	return node
+}
+
+func (g *Graph) addPeer(peer proto.SurveyPeer, key edgeKey, changed *bool) {
+	node := g.node(peer.NodeID, changed)
+	if peer.Version != "" && node.Version != peer.Version {
+		node.Version = peer.Version
+		*changed = true
+	}
+	edge := Edge{
+		From:             key.from,
+		To:               key.to,
+		AverageLatencyMs: peer.AverageLatencyMs,
+		SecondsConnected: peer.SecondsConnected,
+	}
+	// Both ends of a connection may report it; keep the first report.
+	if _, ok := g.edges[key]; !ok {
+		g.edges[key] = &edge
+		*changed = true
+	}
+}
+
+func (g *Graph) updateStats() {
+	latencySum := map[string]uint64{}
+	latencyCount := map[string]uint64{}
+	peers := map[string]map[string]bool{}
+	for key, edge := range g.edges {
+		for _, pair := range [][2]string{{key.from, key.to}, {key.to, key.from}} {
+			if peers[pair[0]] == nil {
+				peers[pair[0]] = map[string]bool{}
+			}
+			peers[pair[0]][pair[1]] = true
+			if edge.AverageLatencyMs > 0 {
+				latencySum[pair[0]] += edge.AverageLatencyMs
+				latencyCount[pair[0]]++
+			}
+		}
+	}
+	for id, node := range g.nodes {
+		node.Degree = len(peers[id])
+		node.AverageLatencyMs = 0
+		if latencyCount[id] > 0 {
+			node.AverageLatencyMs = latencySum[id] / latencyCount[id]
+		}
+	}
+}
+
+// Nodes returns the nodes of the graph sorted by id.
+func (g *Graph) Nodes() []Node {
+	nodes := make([]Node, 0, len(g.nodes))
+	for _, id := range sortedKeys(g.nodes) {
+		nodes = append(nodes, *g.nodes[id])
+	}
+	return nodes
+}
+
+// Edges returns the connections of the graph sorted by their ends.
+func (g *Graph) Edges() []Edge {
+	edges := make([]Edge, 0, len(g.edges))
+	for _, edge := range g.edges {
+		edges = append(edges, *edge)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+	return edges
+}
+
+// Stats summarizes the graph.
+type Stats struct {
+	Nodes          int `json:"nodes"`
+	RespondedNodes int `json:"responded_nodes"`
+	Validators     int `json:"validators"`
+	Edges          int `json:"edges"`
+	// Versions counts the nodes by stellar-core version.
+	Versions map[string]int `json:"versions"`
+}
+
+// Stats returns a summary of the graph.
+func (g *Graph) Stats() Stats {
+	stats := Stats{Nodes: len(g.nodes), Edges: len(g.edges), Versions: map[string]int{}}
+	for _, node := range g.nodes {
+		if node.Responded {
+			stats.RespondedNodes++
+		}
+		if node.IsValidator {
+			stats.Validators++
+		}
+		if node.Version != "" {
+			stats.Versions[node.Version]++
+		}
+	}
+	return stats
+}
+
+// WriteJSON writes the graph as a json object with its stats, nodes and
+// edges.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Stats Stats  `json:"stats"`
+		Nodes []Node `json:"nodes"`
+		Edges []Edge `json:"edges"`
+	}{g.Stats(), g.Nodes(), g.Edges()})
+}
+
+// WriteDOT writes the graph in the GraphViz DOT language. Validators are
+// drawn as boxes and nodes which did not respond to the survey are dashed.
+// Edges are labeled with their latency.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "digraph survey {"); err != nil {
+		return err
+	}
+	for _, node := range g.Nodes() {
+		shape, style := "ellipse", "solid"
+		if node.IsValidator {
+			shape = "box"
+		}
+		if !node.Responded {
+			style = "dashed"
+		}
+		label := shortID(node.ID)
+		if node.Version != "" {
+			label += "\n" + node.Version
+		}
+		if _, err := fmt.Fprintf(w, "  %q [label=%q, shape=%s, style=%s];\n", node.ID, label, shape, style); err != nil {
+			return err
+		}
+	}
+	for _, edge := range g.Edges() {
+		attrs := ""
+		if edge.AverageLatencyMs > 0 {
+			attrs = fmt.Sprintf(" [label=\"%dms\"]", edge.AverageLatencyMs)
+		}
+		if _, err := fmt.Fprintf(w, "  %q -> %q%s;\n", edge.From, edge.To, attrs); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+func shortID(id string) string {
+	if len(id) <= 8 {
+		return id
+	}
+	return id[:5] + ".." + id[len(id)-3:]
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,73 @@
+package survey
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	proto "github.com/stellar/go/protocols/stellarcore"
+)
+
+func TestGraph(t *testing.T) {
+	result := &proto.SurveyResultResponse{
+		Topology: map[string]proto.SurveyNode{
+			"GAVALIDATOR": {
+				IsValidator:            true,
+				TotalInboundPeerCount:  1,
+				TotalOutboundPeerCount: 1,
+				InboundPeers:           []proto.SurveyPeer{{NodeID: "GBWATCHER", Version: "v20.0.0", AverageLatencyMs: 40}},
+				OutboundPeers:          []proto.SurveyPeer{{NodeID: "GCOTHER", Version: "v21.0.0", AverageLatencyMs: 80}},
+			},
+			"GCOTHER": {
+				InboundPeers: []proto.SurveyPeer{{NodeID: "GAVALIDATOR", Version: "v21.1.0", AverageLatencyMs: 90}},
+			},
+		},
+	}
+
+	graph := NewGraph()
+	assert.True(t, graph.AddResult(result))
+	assert.False(t, graph.AddResult(result))
+
+	assert.Equal(t, []Node{
+		{ID: "GAVALIDATOR", Version: "v21.1.0", Responded: true, IsValidator: true, TotalInboundPeers: 1, TotalOutboundPeers: 1, Degree: 2, AverageLatencyMs: 60},
+		{ID: "GBWATCHER", Version: "v20.0.0", Degree: 1, AverageLatencyMs: 40},
+		{ID: "GCOTHER", Version: "v21.0.0", Responded: true, Degree: 1, AverageLatencyMs: 80},
+	}, graph.Nodes())
+	assert.Equal(t, []Edge{
+		{From: "GAVALIDATOR", To: "GCOTHER", AverageLatencyMs: 80},
+		{From: "GBWATCHER", To: "GAVALIDATOR", AverageLatencyMs: 40},
+	}, graph.Edges())
+	assert.Equal(t, Stats{
+		Nodes:          3,
+		RespondedNodes: 2,
+		Validators:     1,
+		Edges:          2,
+		Versions:       map[string]int{"v20.0.0": 1, "v21.0.0": 1, "v21.1.0": 1},
+	}, graph.Stats())
+
+	var out strings.Builder
+	require.NoError(t, graph.WriteDOT(&out))
+	assert.Equal(t, `digraph survey {
+  "GAVALIDATOR" [label="GAVAL..TOR\nv21.1.0", shape=box, style=solid];
+  "GBWATCHER" [label="GBWAT..HER\nv20.0.0", shape=ellipse, style=dashed];
+  "GCOTHER" [label="GCOTHER\nv21.0.0", shape=ellipse, style=solid];
+  "GAVALIDATOR" -> "GCOTHER" [label="80ms"];
+  "GBWATCHER" -> "GAVALIDATOR" [label="40ms"];
+}
+`, out.String())
+
+	out.Reset()
+	require.NoError(t, graph.WriteJSON(&out))
+	var decoded struct {
+		Stats Stats
+		Nodes []Node
+		Edges []Edge
+	}
+	require.NoError(t, json.Unmarshal([]byte(out.String()), &decoded))
+	assert.Equal(t, graph.Stats(), decoded.Stats)
+	assert.Equal(t, graph.Nodes(), decoded.Nodes)
+	assert.Equal(t, graph.Edges(), decoded.Edges)
+}
@@ -0,0 +1,206 @@
+// Package survey drives stellar-core's time sliced overlay survey and
+// assembles its results into a graph of the peers of the network.
+//
+// A survey has a collecting phase, during which the nodes of the network
+// record statistics about their connections, and a reporting phase, during
+// which the surveyor requests those statistics from each node it discovers.
+// Surveyor runs both phases through the HTTP port of a stellar-core node.
+package survey
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	proto "github.com/stellar/go/protocols/stellarcore"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/support/log"
+)
+
+// DefaultCollectDuration is how long the collecting phase runs when
+// Surveyor.CollectDuration is zero.
+const DefaultCollectDuration = 30 * time.Minute
+
+// DefaultPollInterval is how often survey results are fetched during the
+// reporting phase when Surveyor.PollInterval is zero.
+const DefaultPollInterval = 15 * time.Second
+
+// DefaultSettle is how long the reporting phase goes on without new results
+// before it ends when Surveyor.Settle is zero.
+const DefaultSettle = 2 * time.Minute
+
+// Core is the part of the stellar-core client used by Surveyor. It is
+// implemented by *stellarcore.Client.
+type Core interface {
+	Peers(ctx context.Context, request proto.PeersRequest) (*proto.PeersResponse, error)
+	StartSurveyCollecting(ctx context.Context, nonce uint32) error
+	StopSurveyCollecting(ctx context.Context) error
+	SurveyTopologyTimeSliced(ctx context.Context, request proto.SurveyTopologyTimeSlicedRequest) error
+	GetSurveyResult(ctx context.Context) (*proto.SurveyResultResponse, error)
+}
+
+// Surveyor runs a time sliced survey from a stellar-core node.
+type Surveyor struct {
+	Core Core
+
+	// Nonce identifies the survey. A random nonce is used if it is zero.
+	Nonce uint32
+
+	// CollectDuration is how long the collecting phase runs.
+	// DefaultCollectDuration is used if it is zero.
+	CollectDuration time.Duration
+
+	// PollInterval is how often results are fetched during the reporting
+	// phase. DefaultPollInterval is used if it is zero.
+	PollInterval time.Duration
+
+	// Settle is how long the reporting phase goes on without new results
+	// before it ends. DefaultSettle is used if it is zero.
+	Settle time.Duration
+}
+
+// Run runs the collecting phase and then the reporting phase of a survey,
+// returning the resulting graph.
+func (s *Surveyor) Run(ctx context.Context) (*Graph, error) {
+	if err := s.Collect(ctx); err != nil {
+		return nil, err
+	}
+	return s.Report(ctx)
+}
+
+// Collect runs the collecting phase of a survey: it asks the network to
+// start collecting, waits for CollectDuration and asks it to stop.
+func (s *Surveyor) Collect(ctx context.Context) error {
+	nonce := s.Nonce
+	if nonce == 0 {
+		nonce = rand.Uint32()
+	}
+	if err := s.Core.StartSurveyCollecting(ctx, nonce); err != nil {
+		return errors.Wrap(err, "start survey collecting failed")
+	}
+	log.Ctx(ctx).WithField("nonce", nonce).Info("survey collecting started")
+
+	duration := s.CollectDuration
+	if duration == 0 {
+		duration = DefaultCollectDuration
+	}
+	if err := wait(ctx, duration); err != nil {
+		return err
+	}
+
+	if err := s.Core.StopSurveyCollecting(ctx); err != nil {
+		return errors.Wrap(err, "stop survey collecting failed")
+	}
+	log.Ctx(ctx).Info("survey collecting stopped")
+	return nil
+}
+
+// Report runs the reporting phase of a survey whose collecting phase is
+// over. It requests the results of the peers of the local node, then of
+// every node discovered in the results, including the further pages of
+// peers of nodes with many connections, until no new result arrives for
+// Settle. If ctx is done first, the graph assembled so far is returned with
+// the error of ctx.
+func (s *Surveyor) Report(ctx context.Context) (*Graph, error) {
+	peers, err := s.Core.Peers(ctx, proto.PeersRequest{FullKeys: true, Compact: true})
+	if err != nil {
+		return nil, errors.Wrap(err, "peers request failed")
+	}
+
+	pollInterval := s.PollInterval
+	if pollInterval == 0 {
+		pollInterval = DefaultPollInterval
+	}
+	settle := s.Settle
+	if settle == 0 {
+		settle = DefaultSettle
+	}
+
+	r := &reporter{core: s.Core, requested: map[string]page{}}
+	for _, peer := range append(peers.AuthenticatedPeers.Inbound, peers.AuthenticatedPeers.Outbound...) {
+		r.request(ctx, peer.ID, page{})
+	}
+
+	graph := NewGraph()
+	lastProgress := time.Now()
+	for {
+		if err := wait(ctx, pollInterval); err != nil {
+			return graph, err
+		}
+		result, err := s.Core.GetSurveyResult(ctx)
+		if err != nil {
+			return graph, errors.Wrap(err, "get survey result failed")
+		}
+
+		// Both must run on every poll: nodes discovered in a result which
+		// also changed the graph still need to be requested.
+		added := graph.AddResult(result)
+		requested := r.requestMissing(ctx, result)
+		if added || requested || len(result.Backlog) > 0 {
+			lastProgress = time.Now()
+		} else if time.Since(lastProgress) >= settle {
+			return graph, nil
+		}
+	}
+}
+
+// page is the index of the first inbound and outbound peers requested from
+// a node.
+type page struct {
+	inbound, outbound int
+}
+
+type reporter struct {
+	core      Core
+	requested map[string]page
+}
+
+// request requests a page of peers of node unless it was requested already.
+func (r *reporter) request(ctx context.Context, node string, p page) bool {
+	if last, ok := r.requested[node]; ok && (p.inbound <= last.inbound && p.outbound <= last.outbound) {
+		return false
+	}
+	r.requested[node] = p
+	err := r.core.SurveyTopologyTimeSliced(ctx, proto.SurveyTopologyTimeSlicedRequest{
+		Node:              node,
+		InboundPeerIndex:  p.inbound,
+		OutboundPeerIndex: p.outbound,
+	})
+	if err != nil {
+		// Some nodes cannot be surveyed, like the local node; the survey
+		// goes on without them.
+		log.Ctx(ctx).WithField("node", node).WithError(err).Warn("survey request failed")
+	}
+	return true
+}
+
+// requestMissing requests the nodes discovered in result and the next pages
+// of peers of the nodes which reported only part of them.
+func (r *reporter) requestMissing(ctx context.Context, result *proto.SurveyResultResponse) bool {
+	requested := false
+	for _, id := range sortedKeys(result.Topology) {
+		node := result.Topology[id]
+		for _, peer := range append(node.InboundPeers, node.OutboundPeers...) {
+			if r.request(ctx, peer.NodeID, page{}) {
+				requested = true
+			}
+		}
+		if node.TotalInboundPeerCount > len(node.InboundPeers) || node.TotalOutboundPeerCount > len(node.OutboundPeers) {
+			if r.request(ctx, id, page{len(node.InboundPeers), len(node.OutboundPeers)}) {
+				requested = true
+			}
+		}
+	}
+	return requested
+}
+
+func wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,194 @@
+package survey
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	proto "github.com/stellar/go/protocols/stellarcore"
+	"github.com/stellar/go/support/errors"
+)
+
+// peersPerPage is how many peers of each direction stellar-core reports in
+// a page of survey results.
+const peersPerPage = 25
+
+// fakeCore simulates the survey of a network from the node local. Nodes
+// without an entry in outbound do not respond to the survey. The lost sync
+// count of the node churn, once it responded, changes in every result.
+type fakeCore struct {
+	local     string
+	outbound  map[string][]string
+	churn     string
+	nonce     uint32
+	collected bool
+	requests  []proto.SurveyTopologyTimeSlicedRequest
+	result    proto.SurveyResultResponse
+}
+
+func (c *fakeCore) inbound(node string) []string {
+	var inbound []string
+	for _, from := range sortedKeys(c.outbound) {
+		for _, to := range c.outbound[from] {
+			if to == node {
+				inbound = append(inbound, from)
+			}
+		}
+	}
+	return inbound
+}
+
+func (c *fakeCore) Peers(ctx context.Context, request proto.PeersRequest) (*proto.PeersResponse, error) {
+	resp := &proto.PeersResponse{}
+	for _, id := range c.outbound[c.local] {
+		resp.AuthenticatedPeers.Outbound = append(resp.AuthenticatedPeers.Outbound, proto.Peer{ID: id})
+	}
+	for _, id := range c.inbound(c.local) {
+		resp.AuthenticatedPeers.Inbound = append(resp.AuthenticatedPeers.Inbound, proto.Peer{ID: id})
+	}
+	return resp, nil
+}
+
+func (c *fakeCore) StartSurveyCollecting(ctx context.Context, nonce uint32) error {
+	c.nonce = nonce
+	return nil
+}
+
+func (c *fakeCore) StopSurveyCollecting(ctx context.Context) error {
+	if c.nonce == 0 {
+		return errors.New("survey is not collecting")
+	}
+	c.collected = true
+	return nil
+}
+
+func (c *fakeCore) SurveyTopologyTimeSliced(ctx context.Context, request proto.SurveyTopologyTimeSlicedRequest) error {
+	if request.Node == c.local {
+		return errors.New("exception in response: Cannot survey self")
+	}
+	c.requests = append(c.requests, request)
+	return nil
+}
+
+func peerPage(ids []string, index int) []proto.SurveyPeer {
+	peers := []proto.SurveyPeer{}
+	for i := index; i < len(ids) && i < index+peersPerPage; i++ {
+		peers = append(peers, proto.SurveyPeer{NodeID: ids[i], Version: "v21.0.0", AverageLatencyMs: 100})
+	}
+	return peers
+}
+
+// GetSurveyResult responds to one pending request per call, like
+// stellar-core which rate limits survey requests.
+func (c *fakeCore) GetSurveyResult(ctx context.Context) (*proto.SurveyResultResponse, error) {
+	if c.result.Topology == nil {
+		c.result.Topology = map[string]proto.SurveyNode{}
+	}
+	if len(c.requests) > 0 {
+		request := c.requests[0]
+		c.requests = c.requests[1:]
+		if outbound, ok := c.outbound[request.Node]; ok {
+			inbound := c.inbound(request.Node)
+			node := c.result.Topology[request.Node]
+			node.InboundPeers = append(node.InboundPeers, peerPage(inbound, request.InboundPeerIndex)...)
+			node.OutboundPeers = append(node.OutboundPeers, peerPage(outbound, request.OutboundPeerIndex)...)
+			node.TotalInboundPeerCount = len(inbound)
+			node.TotalOutboundPeerCount = len(outbound)
+			node.IsValidator = true
+			c.result.Topology[request.Node] = node
+		}
+	}
+	if node, ok := c.result.Topology[c.churn]; ok {
+		node.LostSyncCount++
+		c.result.Topology[c.churn] = node
+	}
+	c.result.Backlog = nil
+	for _, request := range c.requests {
+		c.result.Backlog = append(c.result.Backlog, request.Node)
+	}
+	c.result.SurveyInProgress = true
+	result := c.result
+	return &result, nil
+}
+
+func TestSurveyorRun(t *testing.T) {
+	var leaves []string
+	for i := 0; i < 30; i++ {
+		leaves = append(leaves, fmt.Sprintf("leaf%02d", i))
+	}
+	core := &fakeCore{
+		local: "local",
+		outbound: map[string][]string{
+			"local": {"a"},
+			"a":     {"b"},
+			"b":     {"local", "c"},
+			"c":     leaves,
+		},
+	}
+	surveyor := &Surveyor{
+		Core:            core,
+		Nonce:           7,
+		CollectDuration: time.Millisecond,
+		PollInterval:    time.Millisecond,
+		Settle:          20 * time.Millisecond,
+	}
+
+	graph, err := surveyor.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, uint32(7), core.nonce)
+	assert.True(t, core.collected)
+
+	stats := graph.Stats()
+	assert.Equal(t, 34, stats.Nodes)
+	assert.Equal(t, 3, stats.RespondedNodes)
+	assert.Equal(t, 34, stats.Edges)
+
+	nodes := map[string]Node{}
+	for _, node := range graph.Nodes() {
+		nodes[node.ID] = node
+	}
+	assert.Equal(t, 31, nodes["c"].Degree)
+	assert.Equal(t, 30, nodes["c"].TotalOutboundPeers)
+	assert.True(t, nodes["c"].Responded)
+	assert.False(t, nodes["leaf29"].Responded)
+	assert.Equal(t, "v21.0.0", nodes["leaf29"].Version)
+	assert.Equal(t, uint64(100), nodes["leaf29"].AverageLatencyMs)
+	assert.Contains(t, graph.Edges(), Edge{From: "b", To: "local", AverageLatencyMs: 100})
+}
+
+func TestSurveyorReportCanceled(t *testing.T) {
+	core := &fakeCore{
+		local:    "local",
+		outbound: map[string][]string{"local": {"a"}, "a": {"b"}, "b": {}},
+	}
+	surveyor := &Surveyor{Core: core, PollInterval: time.Millisecond, Settle: time.Hour}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	graph, err := surveyor.Report(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	require.NotNil(t, graph)
+	assert.Equal(t, 2, graph.Stats().RespondedNodes)
+}
+
+func TestSurveyorReportWhileGraphChanges(t *testing.T) {
+	// The graph changes in every result once a responded, so b is only
+	// discovered in results which also change the graph.
+	core := &fakeCore{
+		local:    "local",
+		outbound: map[string][]string{"local": {"a"}, "a": {"b"}, "b": {}},
+		churn:    "a",
+	}
+	surveyor := &Surveyor{Core: core, PollInterval: time.Millisecond, Settle: time.Hour}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	graph, err := surveyor.Report(ctx)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	require.NotNil(t, graph)
+	assert.Equal(t, 2, graph.Stats().RespondedNodes)
+}
@@ -30,11 +30,15 @@ func TestClient(t *testing.T) {
 	h.
 		On("GET", "https://stellar.org/.well-known/stellar.toml").
 		ReturnString(http.StatusOK,
-			`FEDERATION_SERVER="https://localhost/federation"`,
+			`FEDERATION_SERVER="https://localhost/federation"
+TRANSFER_SERVER_SEP0024="https://localhost/sep24"
+ANCHOR_QUOTE_SERVER="https://localhost/sep38"`,
 		)
 	stoml, err := c.GetStellarToml("stellar.org")
 	require.NoError(t, err)
 	assert.Equal(t, "https://localhost/federation", stoml.FederationServer)
+	assert.Equal(t, "https://localhost/sep24", stoml.TransferServer0024)
+	assert.Equal(t, "https://localhost/sep38", stoml.AnchorQuoteServer)
 
 	// stellar.toml exceeds limit
 	h.
@@ -85,9 +85,12 @@ type Response struct {
 	FederationServer              string      `toml:"FEDERATION_SERVER"`
 	AuthServer                    string      `toml:"AUTH_SERVER"`
 	TransferServer                string      `toml:"TRANSFER_SERVER"`
-	TransferServer0024            string      `toml:"TRANSFER_SERVER_0024"`
+	TransferServer0024            string      `toml:"TRANSFER_SERVER_SEP0024"`
 	KycServer                     string      `toml:"KYC_SERVER"`
+	AnchorQuoteServer             string      `toml:"ANCHOR_QUOTE_SERVER"`
 	WebAuthEndpoint               string      `toml:"WEB_AUTH_ENDPOINT"`
+	WebAuthForContractsEndpoint   string      `toml:"WEB_AUTH_FOR_CONTRACTS_ENDPOINT"`
+	WebAuthContractID             string      `toml:"WEB_AUTH_CONTRACT_ID"`
 	SigningKey                    string      `toml:"SIGNING_KEY"`
 	HorizonUrl                    string      `toml:"HORIZON_URL"`
 	Accounts                      []string    `toml:"ACCOUNTS"`
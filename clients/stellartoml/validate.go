@@ -0,0 +1,274 @@
+package stellartoml
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/support/errors"
+)
+
+// Severity is the severity of an Issue.
+type Severity string
+
+const (
+	// SeverityError marks a violation of SEP-1.
+	SeverityError Severity = "error"
+	// SeverityWarning marks a deviation from SEP-1 recommendations.
+	SeverityWarning Severity = "warning"
+)
+
+// Issue is a problem found in a stellar.toml file.
+type Issue struct {
+	Severity Severity `json:"severity"`
+	// Field is the path of the field, like CURRENCIES[0].issuer, or empty
+	// for issues about the file itself.
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+func (i Issue) String() string {
+	if i.Field == "" {
+		return fmt.Sprintf("%s: %s", i.Severity, i.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", i.Severity, i.Field, i.Message)
+}
+
+// Report is the result of validating a stellar.toml file.
+type Report struct {
+	Domain string  `json:"domain"`
+	URL    string  `json:"url,omitempty"`
+	Issues []Issue `json:"issues"`
+}
+
+// Valid returns true if the report has no errors.
+func (r *Report) Valid() bool {
+	return len(r.Errors()) == 0
+}
+
+// Errors returns the issues with SeverityError.
+func (r *Report) Errors() []Issue {
+	return r.filter(SeverityError)
+}
+
+// Warnings returns the issues with SeverityWarning.
+func (r *Report) Warnings() []Issue {
+	return r.filter(SeverityWarning)
+}
+
+func (r *Report) filter(severity Severity) []Issue {
+	var issues []Issue
+	for _, issue := range r.Issues {
+		if issue.Severity == severity {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+func (r *Report) errorf(field, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, Issue{Severity: SeverityError, Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (r *Report) warnf(field, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, Issue{Severity: SeverityWarning, Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// AccountLookup loads the home domain of Stellar accounts, for example from
+// Horizon.
+type AccountLookup interface {
+	HomeDomain(accountID string) (string, error)
+}
+
+// Validate fetches the stellar.toml of domain and validates it with
+// ValidateFile, after checking the transport rules of SEP-1: the file must
+// be served with a 2xx status and an Access-Control-Allow-Origin: * header.
+// lookup may be nil, in which case issuers' home domains are not checked. An
+// error is only returned if the file could not be fetched.
+func (c *Client) Validate(domain string, lookup AccountLookup) (*Report, error) {
+	report := &Report{Domain: domain, URL: c.url(domain)}
+
+	hresp, err := c.HTTP.Get(report.URL)
+	if err != nil {
+		return nil, errors.Wrap(err, "http request errored")
+	}
+	defer hresp.Body.Close()
+
+	if !(hresp.StatusCode >= 200 && hresp.StatusCode < 300) {
+		report.errorf("", "http request failed with status %d", hresp.StatusCode)
+		return report, nil
+	}
+	if hresp.Header.Get("Access-Control-Allow-Origin") != "*" {
+		report.errorf("", "response must have the header Access-Control-Allow-Origin: *")
+	}
+	if contentType := hresp.Header.Get("Content-Type"); contentType != "" && !textPlain.MatchString(contentType) {
+		report.warnf("", "response should have the Content-Type text/plain, not %s", contentType)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(hresp.Body, StellarTomlMaxSize+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "read response failed")
+	}
+	report.Issues = append(report.Issues, ValidateFile(domain, body, lookup)...)
+	return report, nil
+}
+
+// ValidateFile decodes the contents of the stellar.toml of domain and
+// validates it with ValidateResponse, after checking that it is within
+// StellarTomlMaxSize bytes.
+func ValidateFile(domain string, data []byte, lookup AccountLookup) []Issue {
+	report := &Report{}
+	if len(data) > StellarTomlMaxSize {
+		report.errorf("", "stellar.toml exceeds %d bytes limit", StellarTomlMaxSize)
+		return report.Issues
+	}
+
+	var resp Response
+	if _, err := toml.Decode(string(data), &resp); err != nil {
+		report.errorf("", "toml decode failed: %s", err)
+		return report.Issues
+	}
+	return ValidateResponse(domain, &resp, lookup)
+}
+
+var (
+	textPlain        = regexp.MustCompile(`^text/plain(;|$)`)
+	assetCode        = regexp.MustCompile(`^[a-zA-Z0-9]{1,12}$`)
+	currencyStatuses = map[string]bool{"live": true, "dead": true, "test": true, "private": true}
+)
+
+// ValidateResponse checks a decoded stellar.toml of domain against the rules
+// of SEP-1 and returns the issues found. lookup may be nil, in which case
+// issuers' home domains are not checked.
+func ValidateResponse(domain string, resp *Response, lookup AccountLookup) []Issue {
+	report := &Report{}
+
+	if resp.Version == "" {
+		report.warnf("VERSION", "should specify the SEP-1 version the file follows")
+	}
+	if resp.NetworkPassphrase == "" {
+		report.warnf("NETWORK_PASSPHRASE", "should specify the network passphrase")
+	}
+
+	for i, account := range resp.Accounts {
+		if !strkey.IsValidEd25519PublicKey(account) {
+			report.errorf(fmt.Sprintf("ACCOUNTS[%d]", i), "%q is not a valid account ID", account)
+		}
+	}
+	validateAccount(report, "SIGNING_KEY", resp.SigningKey)
+	validateAccount(report, "URI_REQUEST_SIGNING_KEY", resp.UriRequestSigningKey)
+	if resp.WebAuthContractID != "" && !strkey.IsValidContractAddress(resp.WebAuthContractID) {
+		report.errorf("WEB_AUTH_CONTRACT_ID", "%q is not a valid contract address", resp.WebAuthContractID)
+	}
+	if resp.WebAuthEndpoint != "" && resp.SigningKey == "" {
+		report.errorf("SIGNING_KEY", "is required when WEB_AUTH_ENDPOINT is set")
+	}
+	if resp.WebAuthForContractsEndpoint != "" && (resp.SigningKey == "" || resp.WebAuthContractID == "") {
+		report.errorf("WEB_AUTH_FOR_CONTRACTS_ENDPOINT", "requires SIGNING_KEY and WEB_AUTH_CONTRACT_ID")
+	}
+
+	for _, endpoint := range []struct{ field, value string }{
+		{"FEDERATION_SERVER", resp.FederationServer},
+		{"AUTH_SERVER", resp.AuthServer},
+		{"TRANSFER_SERVER", resp.TransferServer},
+		{"TRANSFER_SERVER_SEP0024", resp.TransferServer0024},
+		{"KYC_SERVER", resp.KycServer},
+		{"ANCHOR_QUOTE_SERVER", resp.AnchorQuoteServer},
+		{"WEB_AUTH_ENDPOINT", resp.WebAuthEndpoint},
+		{"WEB_AUTH_FOR_CONTRACTS_ENDPOINT", resp.WebAuthForContractsEndpoint},
+		{"HORIZON_URL", resp.HorizonUrl},
+		{"DIRECT_PAYMENT_SERVER", resp.DirectPaymentServer},
+		{"ORG_URL", resp.OrgUrl},
+		{"ORG_LOGO", resp.OrgLogo},
+	} {
+		validateHTTPS(report, endpoint.field, endpoint.value)
+	}
+
+	issuers := map[string][]string{}
+	for i, currency := range resp.Currencies {
+		field := fmt.Sprintf("CURRENCIES[%d]", i)
+		switch {
+		case currency.Code == "" && currency.CodeTemplate == "":
+			report.errorf(field+".code", "code or code_template is required")
+		case currency.Code != "" && !assetCode.MatchString(currency.Code):
+			report.errorf(field+".code", "%q is not a valid asset code", currency.Code)
+		}
+		if currency.Issuer == "" {
+			report.errorf(field+".issuer", "is required")
+		} else if !strkey.IsValidEd25519PublicKey(currency.Issuer) {
+			report.errorf(field+".issuer", "%q is not a valid account ID", currency.Issuer)
+		} else {
+			issuers[currency.Issuer] = append(issuers[currency.Issuer], field+".issuer")
+		}
+		if currency.Status != "" && !currencyStatuses[currency.Status] {
+			report.errorf(field+".status", "%q is not one of live, dead, test or private", currency.Status)
+		}
+		if currency.DisplayDecimals < 0 || currency.DisplayDecimals > 7 {
+			report.errorf(field+".display_decimals", "must be between 0 and 7")
+		}
+		validateHTTPS(report, field+".image", currency.Image)
+		validateHTTPS(report, field+".APPROVAL_SERVER", currency.ApprovalServer)
+		if currency.Regulated == "true" && currency.ApprovalServer == "" {
+			report.errorf(field+".APPROVAL_SERVER", "is required for regulated assets")
+		}
+	}
+
+	for i, validator := range resp.Validators {
+		field := fmt.Sprintf("VALIDATORS[%d]", i)
+		if !strkey.IsValidEd25519PublicKey(validator.PublicKey) {
+			report.errorf(field+".PUBLIC_KEY", "%q is not a valid account ID", validator.PublicKey)
+		}
+		if validator.History != "" && !isHTTPS(validator.History) {
+			report.warnf(field+".HISTORY", "should use https")
+		}
+	}
+
+	if lookup != nil {
+		accounts := make([]string, 0, len(issuers))
+		for account := range issuers {
+			accounts = append(accounts, account)
+		}
+		sort.Strings(accounts)
+		for _, account := range accounts {
+			homeDomain, err := lookup.HomeDomain(account)
+			for _, field := range issuers[account] {
+				switch {
+				case err != nil:
+					report.errorf(field, "could not load issuer %s: %s", account, err)
+				case homeDomain != domain:
+					report.errorf(field, "issuer home_domain is %q instead of %q", homeDomain, domain)
+				}
+			}
+		}
+	}
+
+	return report.Issues
+}
+
+func validateAccount(report *Report, field, value string) {
+	if value != "" && !strkey.IsValidEd25519PublicKey(value) {
+		report.errorf(field, "%q is not a valid account ID", value)
+	}
+}
+
+func validateHTTPS(report *Report, field, value string) {
+	if value == "" {
+		return
+	}
+	if _, err := url.ParseRequestURI(value); err != nil {
+		report.errorf(field, "%q is not a valid URL", value)
+		return
+	}
+	if !isHTTPS(value) {
+		report.errorf(field, "%q must use https", value)
+	}
+}
+
+func isHTTPS(value string) bool {
+	u, err := url.Parse(value)
+	return err == nil && u.Scheme == "https" && u.Host != ""
+}
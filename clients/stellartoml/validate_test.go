@@ -0,0 +1,163 @@
+package stellartoml
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stellar/go/support/http/httptest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	validIssuer = "GCZJM35NKGVK47BB4SPBDV25477PZYIYPVVG453LPYFNXLS3FGHDXOCM"
+	otherIssuer = "GBRPYHIL2CI3FNQ4BXLFMNDLFJUNPU2HY3ZMFSHONUCEOASW7QC7OX2H"
+	validToml   = `
+VERSION="2.0.0"
+NETWORK_PASSPHRASE="Public Global Stellar Network ; September 2015"
+ACCOUNTS=["` + validIssuer + `"]
+SIGNING_KEY="` + validIssuer + `"
+WEB_AUTH_ENDPOINT="https://example.com/auth"
+TRANSFER_SERVER="https://example.com/sep6"
+
+[[CURRENCIES]]
+code="USD"
+issuer="` + validIssuer + `"
+status="live"
+display_decimals=2
+`
+)
+
+type lookupFunc func(string) (string, error)
+
+func (f lookupFunc) HomeDomain(accountID string) (string, error) {
+	return f(accountID)
+}
+
+func TestValidateResponse(t *testing.T) {
+	issues := ValidateFile("example.com", []byte(validToml), lookupFunc(func(string) (string, error) {
+		return "example.com", nil
+	}))
+	assert.Empty(t, issues)
+
+	resp := &Response{
+		Accounts:             []string{validIssuer, "GBAD"},
+		SigningKey:           "SBAD",
+		UriRequestSigningKey: "GBAD",
+		WebAuthContractID:    validIssuer,
+		WebAuthEndpoint:      "https://example.com/auth",
+		FederationServer:     "http://example.com/federation",
+		TransferServer:       "example.com",
+		Currencies: []Currency{
+			{Code: "USD", Issuer: validIssuer},
+			{Code: "TOOLONGASSETCODE", Issuer: "GBAD", Status: "unknown", DisplayDecimals: 8},
+			{Issuer: otherIssuer, Regulated: "true"},
+			{CodeTemplate: "USD????", Issuer: otherIssuer},
+		},
+		Validators: []Validator{{PublicKey: "GBAD", History: "http://history.example.com"}},
+	}
+	lookups := 0
+	issues = ValidateResponse("example.com", resp, lookupFunc(func(accountID string) (string, error) {
+		lookups++
+		if accountID == otherIssuer {
+			return "", errors.New("not found")
+		}
+		return "other.com", nil
+	}))
+	assert.Equal(t, 2, lookups, "issuers are looked up once")
+
+	var got []string
+	for _, issue := range issues {
+		got = append(got, issue.String())
+	}
+	assert.Equal(t, []string{
+		"warning: VERSION: should specify the SEP-1 version the file follows",
+		"warning: NETWORK_PASSPHRASE: should specify the network passphrase",
+		`error: ACCOUNTS[1]: "GBAD" is not a valid account ID`,
+		`error: SIGNING_KEY: "SBAD" is not a valid account ID`,
+		`error: URI_REQUEST_SIGNING_KEY: "GBAD" is not a valid account ID`,
+		`error: WEB_AUTH_CONTRACT_ID: "` + validIssuer + `" is not a valid contract address`,
+		`error: FEDERATION_SERVER: "http://example.com/federation" must use https`,
+		`error: TRANSFER_SERVER: "example.com" is not a valid URL`,
+		`error: CURRENCIES[1].code: "TOOLONGASSETCODE" is not a valid asset code`,
+		`error: CURRENCIES[1].issuer: "GBAD" is not a valid account ID`,
+		`error: CURRENCIES[1].status: "unknown" is not one of live, dead, test or private`,
+		"error: CURRENCIES[1].display_decimals: must be between 0 and 7",
+		"error: CURRENCIES[2].code: code or code_template is required",
+		"error: CURRENCIES[2].APPROVAL_SERVER: is required for regulated assets",
+		`error: VALIDATORS[0].PUBLIC_KEY: "GBAD" is not a valid account ID`,
+		"warning: VALIDATORS[0].HISTORY: should use https",
+		"error: CURRENCIES[2].issuer: could not load issuer " + otherIssuer + ": not found",
+		"error: CURRENCIES[3].issuer: could not load issuer " + otherIssuer + ": not found",
+		`error: CURRENCIES[0].issuer: issuer home_domain is "other.com" instead of "example.com"`,
+	}, got)
+
+	// Without a lookup, issuers' home domains are not checked.
+	issues = ValidateResponse("example.com", &Response{
+		Version:           "2.0.0",
+		NetworkPassphrase: "Test SDF Network ; September 2015",
+		Currencies:        []Currency{{Code: "USD", Issuer: validIssuer}},
+	}, nil)
+	assert.Empty(t, issues)
+}
+
+func TestValidateFile(t *testing.T) {
+	issues := ValidateFile("example.com", []byte(`VERSION=`), nil)
+	require.Len(t, issues, 1)
+	assert.Contains(t, issues[0].Message, "toml decode failed")
+
+	issues = ValidateFile("example.com", []byte(strings.Repeat(" ", StellarTomlMaxSize+1)), nil)
+	require.Len(t, issues, 1)
+	assert.Equal(t, "stellar.toml exceeds 102400 bytes limit", issues[0].Message)
+}
+
+func TestClientValidate(t *testing.T) {
+	h := httptest.NewClient()
+	c := &Client{HTTP: h}
+
+	h.
+		On("GET", "https://example.com/.well-known/stellar.toml").
+		ReturnStringWithHeader(http.StatusOK, validToml, http.Header{
+			"Access-Control-Allow-Origin": []string{"*"},
+			"Content-Type":                []string{"text/plain; charset=utf-8"},
+		})
+	report, err := c.Validate("example.com", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/.well-known/stellar.toml", report.URL)
+	assert.True(t, report.Valid())
+	assert.Empty(t, report.Issues)
+
+	h.
+		On("GET", "https://nocors.org/.well-known/stellar.toml").
+		ReturnStringWithHeader(http.StatusOK, `VERSION="2.0.0"`+"\n"+`ACCOUNTS=["GBAD"]`, http.Header{
+			"Content-Type": []string{"text/html"},
+		})
+	report, err = c.Validate("nocors.org", nil)
+	require.NoError(t, err)
+	assert.False(t, report.Valid())
+	assert.Equal(t, []Issue{
+		{Severity: SeverityError, Message: "response must have the header Access-Control-Allow-Origin: *"},
+		{Severity: SeverityError, Field: "ACCOUNTS[0]", Message: `"GBAD" is not a valid account ID`},
+	}, report.Errors())
+	assert.Equal(t, []Issue{
+		{Severity: SeverityWarning, Message: "response should have the Content-Type text/plain, not text/html"},
+		{Severity: SeverityWarning, Field: "NETWORK_PASSPHRASE", Message: "should specify the network passphrase"},
+	}, report.Warnings())
+
+	h.
+		On("GET", "https://missing.org/.well-known/stellar.toml").
+		ReturnNotFound()
+	report, err = c.Validate("missing.org", nil)
+	require.NoError(t, err)
+	assert.Equal(t, []Issue{
+		{Severity: SeverityError, Message: "http request failed with status 404"},
+	}, report.Issues)
+
+	h.
+		On("GET", "https://down.org/.well-known/stellar.toml").
+		ReturnError("connection refused")
+	_, err = c.Validate("down.org", nil)
+	assert.ErrorContains(t, err, "http request errored")
+}
@@ -0,0 +1,203 @@
+package webauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/txnbuild"
+)
+
+// Discover returns the web auth endpoint listed in the stellar.toml of
+// homeDomain.
+func (c *Client) Discover(homeDomain string) (Endpoint, error) {
+	toml, err := c.StellarTOML.GetStellarToml(homeDomain)
+	if err != nil {
+		return Endpoint{}, errors.Wrap(err, "get stellar.toml failed")
+	}
+	if toml.WebAuthEndpoint == "" {
+		return Endpoint{}, ErrNoWebAuthEndpoint
+	}
+	if toml.SigningKey == "" {
+		return Endpoint{}, ErrNoSigningKey
+	}
+	if !strkey.IsValidEd25519PublicKey(toml.SigningKey) {
+		return Endpoint{}, errors.Errorf("SIGNING_KEY %s is not a valid account ID", toml.SigningKey)
+	}
+	if toml.NetworkPassphrase != "" && toml.NetworkPassphrase != c.NetworkPassphrase {
+		return Endpoint{}, errors.Errorf("stellar.toml is for network %q", toml.NetworkPassphrase)
+	}
+	return Endpoint{
+		HomeDomain: homeDomain,
+		URL:        toml.WebAuthEndpoint,
+		SigningKey: toml.SigningKey,
+	}, nil
+}
+
+// Authenticate discovers the web auth endpoint of homeDomain, fetches a
+// challenge for the account, signs it and returns the token issued by the
+// server.
+func (c *Client) Authenticate(ctx context.Context, homeDomain string, req Request) (*Token, error) {
+	endpoint, err := c.Discover(homeDomain)
+	if err != nil {
+		return nil, err
+	}
+	return c.AuthenticateWithEndpoint(ctx, endpoint, req)
+}
+
+// AuthenticateWithEndpoint fetches a challenge for the account from
+// endpoint, signs it and returns the token issued by the server.
+func (c *Client) AuthenticateWithEndpoint(ctx context.Context, endpoint Endpoint, req Request) (*Token, error) {
+	if len(req.Signers) == 0 {
+		return nil, errors.New("at least one signer is required")
+	}
+
+	challenge, err := c.Challenge(ctx, endpoint, req)
+	if err != nil {
+		return nil, err
+	}
+	signed, err := challenge.SignWith(ctx, c.NetworkPassphrase, req.Signers...)
+	if err != nil {
+		return nil, errors.Wrap(err, "sign challenge failed")
+	}
+	encoded, err := signed.Base64()
+	if err != nil {
+		return nil, errors.Wrap(err, "encode challenge failed")
+	}
+
+	body, err := json.Marshal(tokenRequest{Transaction: encoded})
+	if err != nil {
+		return nil, errors.Wrap(err, "encode request failed")
+	}
+	var response tokenResponse
+	if err := c.do(ctx, http.MethodPost, endpoint.URL, body, &response); err != nil {
+		return nil, err
+	}
+	if response.Token == "" {
+		return nil, errors.New("web auth response has no token")
+	}
+	return ParseToken(response.Token)
+}
+
+// Challenge fetches a challenge for the account from endpoint. The challenge
+// is validated with txnbuild.ReadChallengeTx: it must be signed by the
+// endpoint's SIGNING_KEY, be valid now, and name the home domain, the web
+// auth domain, the account and the memo of the request.
+func (c *Client) Challenge(ctx context.Context, endpoint Endpoint, req Request) (*txnbuild.Transaction, error) {
+	server, err := c.endpointURL(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("account", req.Account)
+	query.Set("home_domain", endpoint.HomeDomain)
+	if req.Memo != nil {
+		query.Set("memo", strconv.FormatUint(uint64(*req.Memo), 10))
+	}
+	challengeURL := *server
+	challengeURL.RawQuery = query.Encode()
+
+	var response challengeResponse
+	if err := c.do(ctx, http.MethodGet, challengeURL.String(), nil, &response); err != nil {
+		return nil, err
+	}
+	if response.NetworkPassphrase != "" && response.NetworkPassphrase != c.NetworkPassphrase {
+		return nil, errors.Errorf("challenge is for network %q", response.NetworkPassphrase)
+	}
+
+	tx, account, _, memo, err := txnbuild.ReadChallengeTx(
+		response.Transaction,
+		endpoint.SigningKey,
+		c.NetworkPassphrase,
+		server.Host,
+		[]string{endpoint.HomeDomain},
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid challenge")
+	}
+	if account != req.Account {
+		return nil, errors.Errorf("challenge is for account %s instead of %s", account, req.Account)
+	}
+	if !sameMemo(memo, req.Memo) {
+		return nil, errors.New("challenge memo does not match the request")
+	}
+	return tx, nil
+}
+
+func (c *Client) endpointURL(endpoint Endpoint) (*url.URL, error) {
+	server, err := url.Parse(endpoint.URL)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse WEB_AUTH_ENDPOINT failed")
+	}
+	if server.Scheme != "https" && !(c.AllowHTTP && server.Scheme == "http") {
+		return nil, errors.Errorf("WEB_AUTH_ENDPOINT %s does not use https", endpoint.URL)
+	}
+	return server, nil
+}
+
+// do sends a request to the web auth server and decodes its json response
+// into v.
+func (c *Client) do(ctx context.Context, method, target string, body []byte, v interface{}) error {
+	if _, err := c.endpointURL(Endpoint{URL: target}); err != nil {
+		return err
+	}
+
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return errors.Wrap(err, "build request failed")
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	hresp, err := c.HTTP.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "http request errored")
+	}
+	defer hresp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(hresp.Body, ResponseMaxSize+1))
+	if err != nil {
+		return errors.Wrap(err, "read response failed")
+	}
+	if len(data) > ResponseMaxSize {
+		return errors.Errorf("web auth response exceeds %d bytes limit", ResponseMaxSize)
+	}
+	if !(hresp.StatusCode >= 200 && hresp.StatusCode < 300) {
+		var problem errorResponse
+		if json.Unmarshal(data, &problem) == nil && problem.Error != "" {
+			return errors.Errorf("web auth request failed with status %d: %s", hresp.StatusCode, problem.Error)
+		}
+		return errors.Errorf("web auth request failed with status %d", hresp.StatusCode)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return errors.Wrap(err, "json decode failed")
+	}
+	return nil
+}
+
+func sameMemo(a, b *txnbuild.MemoID) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,346 @@
+package webauth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stellar/go/clients/stellartoml"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeToken(t *testing.T, claims tokenClaims) string {
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	return "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9." +
+		base64.RawURLEncoding.EncodeToString(payload) + ".c2lnbmF0dXJl"
+}
+
+// testServer is a SEP-10 server which issues tokens valid for ttl.
+type testServer struct {
+	*httptest.Server
+	signingKey *keypair.Full
+	ttl        time.Duration
+	tokens     int32
+	// challenge rewrites the challenge before it is sent, if set.
+	challenge func(tx *txnbuild.Transaction) (*txnbuild.Transaction, error)
+}
+
+func newTestServer(t *testing.T) *testServer {
+	s := &testServer{signingKey: keypair.MustRandom(), ttl: time.Hour}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP(t)))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testServer) host() string {
+	u, _ := url.Parse(s.URL)
+	return u.Host
+}
+
+func (s *testServer) serveHTTP(t *testing.T) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			var memo *txnbuild.MemoID
+			if value := r.URL.Query().Get("memo"); value != "" {
+				id, err := strconv.ParseUint(value, 10, 64)
+				require.NoError(t, err)
+				memoID := txnbuild.MemoID(id)
+				memo = &memoID
+			}
+			tx, err := txnbuild.BuildChallengeTx(
+				s.signingKey.Seed(), r.URL.Query().Get("account"), s.host(),
+				r.URL.Query().Get("home_domain"), network.TestNetworkPassphrase, 5*time.Minute, memo,
+			)
+			require.NoError(t, err)
+			if s.challenge != nil {
+				tx, err = s.challenge(tx)
+				require.NoError(t, err)
+			}
+			encoded, err := tx.Base64()
+			require.NoError(t, err)
+			_ = json.NewEncoder(w).Encode(challengeResponse{
+				Transaction:       encoded,
+				NetworkPassphrase: network.TestNetworkPassphrase,
+			})
+		case http.MethodPost:
+			var request tokenRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			tx, account, _, _, err := txnbuild.ReadChallengeTx(
+				request.Transaction, s.signingKey.Address(), network.TestNetworkPassphrase,
+				s.host(), []string{"example.com"},
+			)
+			require.NoError(t, err)
+			if _, err := txnbuild.VerifyChallengeTxSigners(
+				request.Transaction, s.signingKey.Address(), network.TestNetworkPassphrase,
+				s.host(), []string{"example.com"}, account,
+			); err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				_ = json.NewEncoder(w).Encode(errorResponse{Error: err.Error()})
+				return
+			}
+			n := atomic.AddInt32(&s.tokens, 1)
+			now := time.Now().Unix()
+			hash, _ := tx.HashHex(network.TestNetworkPassphrase)
+			_ = json.NewEncoder(w).Encode(tokenResponse{Token: encodeToken(t, tokenClaims{
+				Issuer:     s.URL,
+				Subject:    account,
+				IssuedAt:   now + int64(n),
+				ExpiresAt:  now + int64(s.ttl/time.Second),
+				HomeDomain: "example.com",
+				// The challenge hash makes every token unique.
+				ClientDomain: hash[:8],
+			})})
+		}
+	}
+}
+
+func (s *testServer) client() *Client {
+	toml := &stellartoml.MockClient{}
+	toml.On("GetStellarToml", "example.com").Return(&stellartoml.Response{
+		WebAuthEndpoint:   s.URL + "/auth",
+		SigningKey:        s.signingKey.Address(),
+		NetworkPassphrase: network.TestNetworkPassphrase,
+	}, nil)
+	toml.On("GetStellarToml", "noauth.com").Return(&stellartoml.Response{}, nil)
+	return &Client{
+		HTTP:              http.DefaultClient,
+		StellarTOML:       toml,
+		NetworkPassphrase: network.TestNetworkPassphrase,
+		AllowHTTP:         true,
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	server := newTestServer(t)
+	client := server.client()
+	account := keypair.MustRandom()
+	signers := []txnbuild.HashSigner{txnbuild.KeypairSigner{Full: account}}
+
+	token, err := client.Authenticate(context.Background(), "example.com", Request{
+		Account: account.Address(),
+		Signers: signers,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, account.Address(), token.Subject)
+	assert.Equal(t, "example.com", token.HomeDomain)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), token.ExpiresAt, 5*time.Second)
+	assert.False(t, token.Expired(time.Now(), time.Minute))
+	assert.True(t, token.Expired(time.Now().Add(time.Hour), 0))
+
+	memo := txnbuild.MemoID(42)
+	token, err = client.Authenticate(context.Background(), "example.com", Request{
+		Account: account.Address(),
+		Memo:    &memo,
+		Signers: signers,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, account.Address(), token.Subject)
+
+	// The server rejects challenges signed by the wrong key.
+	_, err = client.Authenticate(context.Background(), "example.com", Request{
+		Account: account.Address(),
+		Signers: []txnbuild.HashSigner{txnbuild.KeypairSigner{Full: keypair.MustRandom()}},
+	})
+	assert.ErrorContains(t, err, "web auth request failed with status 401")
+
+	_, err = client.Authenticate(context.Background(), "example.com", Request{Account: account.Address()})
+	assert.EqualError(t, err, "at least one signer is required")
+
+	_, err = client.Authenticate(context.Background(), "noauth.com", Request{
+		Account: account.Address(),
+		Signers: signers,
+	})
+	assert.Equal(t, ErrNoWebAuthEndpoint, err)
+
+	client.AllowHTTP = false
+	_, err = client.Authenticate(context.Background(), "example.com", Request{
+		Account: account.Address(),
+		Signers: signers,
+	})
+	assert.ErrorContains(t, err, "does not use https")
+}
+
+func TestChallengeValidation(t *testing.T) {
+	server := newTestServer(t)
+	client := server.client()
+	account := keypair.MustRandom()
+	endpoint, err := client.Discover("example.com")
+	require.NoError(t, err)
+
+	// A challenge signed by another key is rejected.
+	endpoint.SigningKey = keypair.MustRandom().Address()
+	_, err = client.Challenge(context.Background(), endpoint, Request{Account: account.Address()})
+	assert.ErrorContains(t, err, "invalid challenge: transaction source account is not equal to server's account")
+
+	endpoint, err = client.Discover("example.com")
+	require.NoError(t, err)
+
+	// A challenge for another account is rejected.
+	other := keypair.MustRandom()
+	server.challenge = func(*txnbuild.Transaction) (*txnbuild.Transaction, error) {
+		return txnbuild.BuildChallengeTx(server.signingKey.Seed(), other.Address(), server.host(),
+			"example.com", network.TestNetworkPassphrase, 5*time.Minute, nil)
+	}
+	_, err = client.Challenge(context.Background(), endpoint, Request{Account: account.Address()})
+	assert.EqualError(t, err, fmt.Sprintf("challenge is for account %s instead of %s", other.Address(), account.Address()))
+
+	// A challenge for another memo is rejected.
+	memo := txnbuild.MemoID(7)
+	server.challenge = func(*txnbuild.Transaction) (*txnbuild.Transaction, error) {
+		return txnbuild.BuildChallengeTx(server.signingKey.Seed(), account.Address(), server.host(),
+			"example.com", network.TestNetworkPassphrase, 5*time.Minute, &memo)
+	}
+	_, err = client.Challenge(context.Background(), endpoint, Request{Account: account.Address()})
+	assert.EqualError(t, err, "challenge memo does not match the request")
+
+	// A challenge for another home domain is rejected.
+	server.challenge = func(*txnbuild.Transaction) (*txnbuild.Transaction, error) {
+		return txnbuild.BuildChallengeTx(server.signingKey.Seed(), account.Address(), server.host(),
+			"evil.com", network.TestNetworkPassphrase, 5*time.Minute, nil)
+	}
+	_, err = client.Challenge(context.Background(), endpoint, Request{Account: account.Address()})
+	assert.ErrorContains(t, err, "operation key does not match any homeDomains passed")
+
+	// A challenge for another web auth domain is rejected.
+	server.challenge = func(*txnbuild.Transaction) (*txnbuild.Transaction, error) {
+		return txnbuild.BuildChallengeTx(server.signingKey.Seed(), account.Address(), "evil.com",
+			"example.com", network.TestNetworkPassphrase, 5*time.Minute, nil)
+	}
+	_, err = client.Challenge(context.Background(), endpoint, Request{Account: account.Address()})
+	assert.ErrorContains(t, err, "web auth domain operation value is")
+}
+
+func TestParseToken(t *testing.T) {
+	token, err := ParseToken(encodeToken(t, tokenClaims{
+		Issuer:    "https://example.com/auth",
+		Subject:   "GA",
+		IssuedAt:  1700000000,
+		ExpiresAt: 1700003600,
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/auth", token.Issuer)
+	assert.Equal(t, "GA", token.Subject)
+	assert.Equal(t, time.Unix(1700000000, 0).UTC(), token.IssuedAt)
+	assert.Equal(t, time.Unix(1700003600, 0).UTC(), token.ExpiresAt)
+
+	_, err = ParseToken("abc")
+	assert.EqualError(t, err, "token is not a JWT")
+	_, err = ParseToken("a.!!.c")
+	assert.ErrorContains(t, err, "decode token payload failed")
+	_, err = ParseToken(encodeToken(t, tokenClaims{Subject: "GA"}))
+	assert.EqualError(t, err, "token has no exp claim")
+}
+
+func TestTokenSource(t *testing.T) {
+	server := newTestServer(t)
+	account := keypair.MustRandom()
+	source := &TokenSource{
+		Client:     server.client(),
+		HomeDomain: "example.com",
+		Request: Request{
+			Account: account.Address(),
+			Signers: []txnbuild.HashSigner{txnbuild.KeypairSigner{Full: account}},
+		},
+	}
+
+	var authorization atomic.Value
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization.Store(r.Header.Get("Authorization"))
+	}))
+	defer api.Close()
+
+	apiURL, err := url.Parse(api.URL)
+	require.NoError(t, err)
+	httpClient := source.HTTPClient(apiURL.Host)
+	resp, err := httpClient.Get(api.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	first, err := source.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer "+first.Raw, authorization.Load())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&server.tokens))
+
+	// The token is refreshed once it is about to expire.
+	source.now = func() time.Time { return time.Now().Add(time.Hour - 30*time.Second) }
+	resp, err = httpClient.Get(api.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&server.tokens))
+	assert.NotEqual(t, "Bearer "+first.Raw, authorization.Load())
+
+	source.now = nil
+	source.Invalidate()
+	_, err = source.Token(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&server.tokens))
+}
+
+func TestTransportHosts(t *testing.T) {
+	server := newTestServer(t)
+	account := keypair.MustRandom()
+	source := &TokenSource{
+		Client:     server.client(),
+		HomeDomain: "example.com",
+		Request: Request{
+			Account: account.Address(),
+			Signers: []txnbuild.HashSigner{txnbuild.KeypairSigner{Full: account}},
+		},
+	}
+
+	var authorization atomic.Value
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization.Store(r.Header.Get("Authorization"))
+	}))
+	defer other.Close()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL, http.StatusFound)
+	}))
+	defer api.Close()
+	apiURL, err := url.Parse(api.URL)
+	require.NoError(t, err)
+
+	// The token is not sent to the host the api redirects to.
+	resp, err := source.HTTPClient(apiURL.Host).Get(api.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "", authorization.Load())
+
+	// By default the token is only sent to the web auth endpoint's host.
+	resp, err = source.HTTPClient().Get(other.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "", authorization.Load())
+
+	token, err := source.Token(context.Background())
+	require.NoError(t, err)
+	var sent string
+	transport := &Transport{Source: source, Base: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		sent = req.Header.Get("Authorization")
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})}
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	_, err = transport.RoundTrip(req)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer "+token.Raw, sent)
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
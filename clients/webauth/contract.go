@@ -0,0 +1,196 @@
+package webauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/txnbuild/sep45"
+)
+
+// ErrNoContractWebAuth is returned when the stellar.toml of a domain has no
+// WEB_AUTH_FOR_CONTRACTS_ENDPOINT or WEB_AUTH_CONTRACT_ID.
+var ErrNoContractWebAuth = errors.New("stellar.toml does not specify WEB_AUTH_FOR_CONTRACTS_ENDPOINT and WEB_AUTH_CONTRACT_ID")
+
+// ContractEndpoint is the SEP-45 web auth server of a home domain.
+type ContractEndpoint struct {
+	Endpoint
+	// WebAuthContract is the WEB_AUTH_CONTRACT_ID.
+	WebAuthContract string
+}
+
+// ContractRequest describes a contract account to authenticate with SEP-45.
+type ContractRequest struct {
+	// Account is the C... address of the contract account.
+	Account string
+
+	// Signers sign the account's authorization entry in the signature
+	// format of Stellar accounts. Sign is used instead if it is set.
+	Signers []txnbuild.HashSigner
+
+	// Sign signs the account's authorization entry of the challenge, for
+	// account contracts with their own signature format. See
+	// sep45.AuthorizationHash.
+	Sign func(ctx context.Context, challenge *sep45.Challenge) error
+
+	// ClientDomain is the domain of the client application. When it is
+	// set, ClientDomainSigners sign the entry of its SIGNING_KEY.
+	ClientDomain        string
+	ClientDomainSigners []txnbuild.HashSigner
+
+	// ValidUntilLedger is the last ledger in which the signatures are valid.
+	// It is required unless Sign signs the account's entry and there is no
+	// ClientDomain.
+	ValidUntilLedger uint32
+}
+
+type contractChallengeResponse struct {
+	AuthorizationEntries string `json:"authorization_entries"`
+	NetworkPassphrase    string `json:"network_passphrase"`
+}
+
+type contractTokenRequest struct {
+	AuthorizationEntries string `json:"authorization_entries"`
+}
+
+// DiscoverContract returns the SEP-45 web auth endpoint listed in the
+// stellar.toml of homeDomain.
+func (c *Client) DiscoverContract(homeDomain string) (ContractEndpoint, error) {
+	toml, err := c.StellarTOML.GetStellarToml(homeDomain)
+	if err != nil {
+		return ContractEndpoint{}, errors.Wrap(err, "get stellar.toml failed")
+	}
+	if toml.WebAuthForContractsEndpoint == "" || toml.WebAuthContractID == "" {
+		return ContractEndpoint{}, ErrNoContractWebAuth
+	}
+	if toml.SigningKey == "" {
+		return ContractEndpoint{}, ErrNoSigningKey
+	}
+	if !strkey.IsValidEd25519PublicKey(toml.SigningKey) {
+		return ContractEndpoint{}, errors.Errorf("SIGNING_KEY %s is not a valid account ID", toml.SigningKey)
+	}
+	if !strkey.IsValidContractAddress(toml.WebAuthContractID) {
+		return ContractEndpoint{}, errors.Errorf("WEB_AUTH_CONTRACT_ID %s is not a contract address", toml.WebAuthContractID)
+	}
+	if toml.NetworkPassphrase != "" && toml.NetworkPassphrase != c.NetworkPassphrase {
+		return ContractEndpoint{}, errors.Errorf("stellar.toml is for network %q", toml.NetworkPassphrase)
+	}
+	return ContractEndpoint{
+		Endpoint: Endpoint{
+			HomeDomain: homeDomain,
+			URL:        toml.WebAuthForContractsEndpoint,
+			SigningKey: toml.SigningKey,
+		},
+		WebAuthContract: toml.WebAuthContractID,
+	}, nil
+}
+
+// AuthenticateContract discovers the SEP-45 web auth endpoint of
+// homeDomain, fetches a challenge for the contract account, signs it and
+// returns the token issued by the server.
+func (c *Client) AuthenticateContract(ctx context.Context, homeDomain string, req ContractRequest) (*Token, error) {
+	endpoint, err := c.DiscoverContract(homeDomain)
+	if err != nil {
+		return nil, err
+	}
+	return c.AuthenticateContractWithEndpoint(ctx, endpoint, req)
+}
+
+// AuthenticateContractWithEndpoint fetches a challenge for the contract
+// account from endpoint, signs it and returns the token issued by the
+// server.
+func (c *Client) AuthenticateContractWithEndpoint(ctx context.Context, endpoint ContractEndpoint, req ContractRequest) (*Token, error) {
+	if len(req.Signers) == 0 && req.Sign == nil {
+		return nil, errors.New("signers or a sign function are required")
+	}
+	if req.ClientDomain != "" && len(req.ClientDomainSigners) == 0 {
+		return nil, errors.New("client domain signers are required")
+	}
+	if (req.Sign == nil || req.ClientDomain != "") && req.ValidUntilLedger == 0 {
+		return nil, errors.New("valid until ledger is required")
+	}
+
+	challenge, err := c.ContractChallenge(ctx, endpoint, req)
+	if err != nil {
+		return nil, err
+	}
+	if req.Sign != nil {
+		err = req.Sign(ctx, challenge)
+	} else {
+		err = sep45.SignChallenge(ctx, challenge, c.NetworkPassphrase, req.Account, req.ValidUntilLedger, req.Signers...)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "sign challenge failed")
+	}
+	if req.ClientDomain != "" {
+		err = sep45.SignChallenge(ctx, challenge, c.NetworkPassphrase, challenge.ClientDomainAccount,
+			req.ValidUntilLedger, req.ClientDomainSigners...)
+		if err != nil {
+			return nil, errors.Wrap(err, "sign client domain entry failed")
+		}
+	}
+
+	encoded, err := challenge.Encode()
+	if err != nil {
+		return nil, errors.Wrap(err, "encode challenge failed")
+	}
+	body, err := json.Marshal(contractTokenRequest{AuthorizationEntries: encoded})
+	if err != nil {
+		return nil, errors.Wrap(err, "encode request failed")
+	}
+	var response tokenResponse
+	if err := c.do(ctx, http.MethodPost, endpoint.URL, body, &response); err != nil {
+		return nil, err
+	}
+	if response.Token == "" {
+		return nil, errors.New("web auth response has no token")
+	}
+	return ParseToken(response.Token)
+}
+
+// ContractChallenge fetches a SEP-45 challenge for the contract account from
+// endpoint and validates it with sep45.ReadChallenge.
+func (c *Client) ContractChallenge(ctx context.Context, endpoint ContractEndpoint, req ContractRequest) (*sep45.Challenge, error) {
+	server, err := c.endpointURL(endpoint.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	query := server.Query()
+	query.Set("account", req.Account)
+	query.Set("home_domain", endpoint.HomeDomain)
+	if req.ClientDomain != "" {
+		query.Set("client_domain", req.ClientDomain)
+	}
+	challengeURL := *server
+	challengeURL.RawQuery = query.Encode()
+
+	var response contractChallengeResponse
+	if err := c.do(ctx, http.MethodGet, challengeURL.String(), nil, &response); err != nil {
+		return nil, err
+	}
+	if response.NetworkPassphrase != "" && response.NetworkPassphrase != c.NetworkPassphrase {
+		return nil, errors.Errorf("challenge is for network %q", response.NetworkPassphrase)
+	}
+
+	challenge, err := sep45.ReadChallenge(response.AuthorizationEntries, sep45.ReadParams{
+		NetworkPassphrase: c.NetworkPassphrase,
+		WebAuthContract:   endpoint.WebAuthContract,
+		ServerAccount:     endpoint.SigningKey,
+		WebAuthDomain:     server.Host,
+		HomeDomains:       []string{endpoint.HomeDomain},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid challenge")
+	}
+	if challenge.Account != req.Account {
+		return nil, errors.Errorf("challenge is for account %s instead of %s", challenge.Account, req.Account)
+	}
+	if challenge.ClientDomain != req.ClientDomain {
+		return nil, errors.Errorf("challenge is for client domain %q instead of %q", challenge.ClientDomain, req.ClientDomain)
+	}
+	return challenge, nil
+}
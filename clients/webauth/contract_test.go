@@ -0,0 +1,158 @@
+package webauth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stellar/go/clients/stellartoml"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	protocol "github.com/stellar/go/protocols/rpc"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/txnbuild/sep45"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func randomContract(t *testing.T) string {
+	var id [32]byte
+	_, err := rand.Read(id[:])
+	require.NoError(t, err)
+	address, err := strkey.Encode(strkey.VersionByteContract, id[:])
+	require.NoError(t, err)
+	return address
+}
+
+// acceptingSimulator accepts every simulated transaction.
+type acceptingSimulator struct{}
+
+func (acceptingSimulator) SimulateTransaction(context.Context, protocol.SimulateTransactionRequest) (protocol.SimulateTransactionResponse, error) {
+	return protocol.SimulateTransactionResponse{}, nil
+}
+
+func TestAuthenticateContract(t *testing.T) {
+	signingKey := keypair.MustRandom()
+	webAuthContract := randomContract(t)
+	account := randomContract(t)
+	clientDomainKey := keypair.MustRandom()
+
+	var host string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		read := sep45.ReadParams{
+			NetworkPassphrase: network.TestNetworkPassphrase,
+			WebAuthContract:   webAuthContract,
+			ServerAccount:     signingKey.Address(),
+			WebAuthDomain:     host,
+			HomeDomains:       []string{"example.com"},
+		}
+		switch r.Method {
+		case http.MethodGet:
+			params := sep45.BuildParams{
+				NetworkPassphrase: network.TestNetworkPassphrase,
+				WebAuthContract:   webAuthContract,
+				Server:            txnbuild.KeypairSigner{Full: signingKey},
+				Account:           r.URL.Query().Get("account"),
+				HomeDomain:        r.URL.Query().Get("home_domain"),
+				WebAuthDomain:     host,
+				ValidUntilLedger:  100,
+			}
+			if domain := r.URL.Query().Get("client_domain"); domain != "" {
+				params.ClientDomain = domain
+				params.ClientDomainAccount = clientDomainKey.Address()
+			}
+			challenge, err := sep45.BuildChallenge(r.Context(), params)
+			require.NoError(t, err)
+			encoded, err := challenge.Encode()
+			require.NoError(t, err)
+			_ = json.NewEncoder(w).Encode(contractChallengeResponse{
+				AuthorizationEntries: encoded,
+				NetworkPassphrase:    network.TestNetworkPassphrase,
+			})
+		case http.MethodPost:
+			var request contractTokenRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+			challenge, err := sep45.VerifyChallenge(r.Context(), acceptingSimulator{},
+				request.AuthorizationEntries, read, signingKey.Address())
+			if err != nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				_ = json.NewEncoder(w).Encode(errorResponse{Error: err.Error()})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(tokenResponse{Token: encodeToken(t, tokenClaims{
+				Subject:      challenge.Account,
+				ExpiresAt:    time.Now().Add(time.Hour).Unix(),
+				HomeDomain:   challenge.HomeDomain,
+				ClientDomain: challenge.ClientDomain,
+			})})
+		}
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	host = u.Host
+
+	toml := &stellartoml.MockClient{}
+	toml.On("GetStellarToml", "example.com").Return(&stellartoml.Response{
+		WebAuthForContractsEndpoint: server.URL + "/auth",
+		WebAuthContractID:           webAuthContract,
+		SigningKey:                  signingKey.Address(),
+	}, nil)
+	toml.On("GetStellarToml", "sep10only.com").Return(&stellartoml.Response{
+		WebAuthEndpoint: server.URL + "/auth",
+		SigningKey:      signingKey.Address(),
+	}, nil)
+	client := &Client{
+		HTTP:              http.DefaultClient,
+		StellarTOML:       toml,
+		NetworkPassphrase: network.TestNetworkPassphrase,
+		AllowHTTP:         true,
+	}
+	signer := txnbuild.KeypairSigner{Full: keypair.MustRandom()}
+
+	token, err := client.AuthenticateContract(context.Background(), "example.com", ContractRequest{
+		Account:          account,
+		Signers:          []txnbuild.HashSigner{signer},
+		ValidUntilLedger: 200,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, account, token.Subject)
+
+	token, err = client.AuthenticateContract(context.Background(), "example.com", ContractRequest{
+		Account:             account,
+		Signers:             []txnbuild.HashSigner{signer},
+		ClientDomain:        "wallet.com",
+		ClientDomainSigners: []txnbuild.HashSigner{txnbuild.KeypairSigner{Full: clientDomainKey}},
+		ValidUntilLedger:    200,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "wallet.com", token.ClientDomain)
+
+	// A custom sign function which does not sign is rejected by the server.
+	_, err = client.AuthenticateContract(context.Background(), "example.com", ContractRequest{
+		Account: account,
+		Sign:    func(context.Context, *sep45.Challenge) error { return nil },
+	})
+	assert.ErrorContains(t, err, "web auth request failed with status 401")
+
+	_, err = client.AuthenticateContract(context.Background(), "example.com", ContractRequest{Account: account})
+	assert.EqualError(t, err, "signers or a sign function are required")
+
+	_, err = client.AuthenticateContract(context.Background(), "example.com", ContractRequest{
+		Account: account,
+		Signers: []txnbuild.HashSigner{signer},
+	})
+	assert.EqualError(t, err, "valid until ledger is required")
+
+	_, err = client.AuthenticateContract(context.Background(), "sep10only.com", ContractRequest{
+		Account: account,
+		Signers: []txnbuild.HashSigner{signer},
+	})
+	assert.Equal(t, ErrNoContractWebAuth, err)
+}
@@ -0,0 +1,118 @@
+// Package webauth provides a client for SEP-10 web authentication. It
+// discovers the WEB_AUTH_ENDPOINT of a domain, fetches and validates a
+// challenge transaction, signs it and exchanges it for a JWT. Contract
+// accounts authenticate with SEP-45 through AuthenticateContract.
+// https://github.com/stellar/stellar-protocol/blob/master/ecosystem/sep-0010.md
+// https://github.com/stellar/stellar-protocol/blob/master/ecosystem/sep-0045.md
+package webauth
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/stellar/go/clients/stellartoml"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/txnbuild"
+)
+
+// ResponseMaxSize is the maximum size of a response from a web auth server.
+const ResponseMaxSize = 100 * 1024
+
+// DefaultTimeout is the timeout of a web auth request used when
+// Client.Timeout is zero.
+const DefaultTimeout = 30 * time.Second
+
+var (
+	// ErrNoWebAuthEndpoint is returned when the stellar.toml of a domain
+	// has no WEB_AUTH_ENDPOINT.
+	ErrNoWebAuthEndpoint = errors.New("stellar.toml does not specify a WEB_AUTH_ENDPOINT")
+
+	// ErrNoSigningKey is returned when the stellar.toml of a domain has no
+	// SIGNING_KEY.
+	ErrNoSigningKey = errors.New("stellar.toml does not specify a SIGNING_KEY")
+)
+
+// HTTP represents the http client that a web auth client uses to make http
+// requests.
+type HTTP interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Client authenticates accounts with the web auth server of a domain.
+type Client struct {
+	// HTTP is the http client used to query web auth servers.
+	HTTP HTTP
+
+	// StellarTOML resolves the stellar.toml of a domain to find its
+	// WEB_AUTH_ENDPOINT and SIGNING_KEY.
+	StellarTOML stellartoml.ClientInterface
+
+	// NetworkPassphrase is the passphrase of the network the challenges are
+	// signed for.
+	NetworkPassphrase string
+
+	// Timeout bounds every web auth request. DefaultTimeout is used if it is
+	// zero.
+	Timeout time.Duration
+
+	// AllowHTTP allows web auth endpoints using plain HTTP. Useful for
+	// debugging.
+	AllowHTTP bool
+}
+
+// DefaultPublicNetClient is a default client for the public network.
+var DefaultPublicNetClient = &Client{
+	HTTP:              http.DefaultClient,
+	StellarTOML:       stellartoml.DefaultClient,
+	NetworkPassphrase: network.PublicNetworkPassphrase,
+}
+
+// DefaultTestNetClient is a default client for the test network.
+var DefaultTestNetClient = &Client{
+	HTTP:              http.DefaultClient,
+	StellarTOML:       stellartoml.DefaultClient,
+	NetworkPassphrase: network.TestNetworkPassphrase,
+}
+
+// Endpoint is the web auth server of a home domain.
+type Endpoint struct {
+	// HomeDomain is the domain whose stellar.toml lists the endpoint.
+	HomeDomain string
+	// URL is the WEB_AUTH_ENDPOINT.
+	URL string
+	// SigningKey is the SIGNING_KEY which signs challenges.
+	SigningKey string
+}
+
+// Request describes an account to authenticate.
+type Request struct {
+	// Account is the G... or M... address of the account.
+	Account string
+
+	// Memo identifies a user of a shared account. It must be nil if
+	// Account is a muxed account.
+	Memo *txnbuild.MemoID
+
+	// Signers sign the challenge. Their combined weight must meet the
+	// threshold required by the server, or the server will reject the
+	// challenge.
+	Signers []txnbuild.HashSigner
+}
+
+type challengeResponse struct {
+	Transaction       string `json:"transaction"`
+	NetworkPassphrase string `json:"network_passphrase"`
+}
+
+type tokenRequest struct {
+	Transaction string `json:"transaction"`
+}
+
+type tokenResponse struct {
+	Token string `json:"token"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
@@ -0,0 +1,204 @@
+package webauth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/stellar/go/support/errors"
+)
+
+// Token is a JWT issued by a web auth server.
+//
+// The signature of the token is not verified: it is signed with a key known
+// only to the server, which verifies it when the token is presented.
+type Token struct {
+	// Raw is the encoded token, sent in the Authorization header.
+	Raw string
+
+	Issuer       string
+	Subject      string
+	IssuedAt     time.Time
+	ExpiresAt    time.Time
+	HomeDomain   string
+	ClientDomain string
+}
+
+type tokenClaims struct {
+	Issuer       string `json:"iss"`
+	Subject      string `json:"sub"`
+	IssuedAt     int64  `json:"iat"`
+	ExpiresAt    int64  `json:"exp"`
+	HomeDomain   string `json:"home_domain"`
+	ClientDomain string `json:"client_domain"`
+}
+
+// ParseToken decodes the claims of a JWT issued by a web auth server.
+func ParseToken(raw string) (*Token, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, errors.Wrap(err, "decode token payload failed")
+	}
+	var claims tokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, errors.Wrap(err, "decode token claims failed")
+	}
+	if claims.ExpiresAt == 0 {
+		return nil, errors.New("token has no exp claim")
+	}
+
+	token := &Token{
+		Raw:          raw,
+		Issuer:       claims.Issuer,
+		Subject:      claims.Subject,
+		ExpiresAt:    time.Unix(claims.ExpiresAt, 0).UTC(),
+		HomeDomain:   claims.HomeDomain,
+		ClientDomain: claims.ClientDomain,
+	}
+	if claims.IssuedAt != 0 {
+		token.IssuedAt = time.Unix(claims.IssuedAt, 0).UTC()
+	}
+	return token, nil
+}
+
+// Expired returns true if the token expires within margin of now.
+func (t *Token) Expired(now time.Time, margin time.Duration) bool {
+	return !now.Add(margin).Before(t.ExpiresAt)
+}
+
+// DefaultRefreshMargin is the default value of TokenSource.RefreshMargin.
+const DefaultRefreshMargin = time.Minute
+
+// TokenSource returns a valid token for an account, authenticating again
+// whenever the current token is about to expire. It is safe for concurrent
+// use.
+type TokenSource struct {
+	Client     *Client
+	HomeDomain string
+	Request    Request
+
+	// RefreshMargin is how long before its expiry a token is replaced.
+	// DefaultRefreshMargin is used if it is zero.
+	RefreshMargin time.Duration
+
+	lock  sync.Mutex
+	token *Token
+	// host is the host of the web auth endpoint which issued token.
+	host string
+	now  func() time.Time
+}
+
+// Token returns the current token, authenticating if there is none or it is
+// about to expire.
+func (s *TokenSource) Token(ctx context.Context) (*Token, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	margin := s.RefreshMargin
+	if margin == 0 {
+		margin = DefaultRefreshMargin
+	}
+	if s.token != nil && !s.token.Expired(now(), margin) {
+		return s.token, nil
+	}
+
+	endpoint, err := s.Client.Discover(s.HomeDomain)
+	if err != nil {
+		return nil, err
+	}
+	token, err := s.Client.AuthenticateWithEndpoint(ctx, endpoint, s.Request)
+	if err != nil {
+		return nil, err
+	}
+	server, err := url.Parse(endpoint.URL)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse WEB_AUTH_ENDPOINT failed")
+	}
+	s.token = token
+	s.host = server.Host
+	return token, nil
+}
+
+// endpointHost returns the host of the web auth endpoint which issued the
+// current token.
+func (s *TokenSource) endpointHost() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.host
+}
+
+// Invalidate discards the current token, so the next call to Token
+// authenticates again.
+func (s *TokenSource) Invalidate() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.token = nil
+}
+
+// Transport is an http.RoundTripper which adds the token of Source to the
+// Authorization header of requests to trusted hosts. Requests to any other
+// host, for example after a redirect, are sent without the token.
+type Transport struct {
+	Source *TokenSource
+
+	// Hosts are the hosts (host or host:port, as in url.URL.Host) the token
+	// is sent to. If it is empty the token is only sent to the host of the
+	// web auth endpoint which issued it.
+	Hosts []string
+
+	// Base is the RoundTripper making the requests. http.DefaultTransport is
+	// used if it is nil.
+	Base http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if len(t.Hosts) > 0 && !containsHost(t.Hosts, req.URL.Host) {
+		return base.RoundTrip(req)
+	}
+
+	token, err := t.Source.Token(req.Context())
+	if err != nil {
+		return nil, errors.Wrap(err, "web auth failed")
+	}
+	if len(t.Hosts) == 0 && !containsHost([]string{t.Source.endpointHost()}, req.URL.Host) {
+		return base.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token.Raw)
+	return base.RoundTrip(req)
+}
+
+func containsHost(hosts []string, host string) bool {
+	for _, h := range hosts {
+		if strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// HTTPClient returns an http.Client which authenticates its requests to
+// hosts with tokens from the source. If no hosts are given, only requests
+// to the host of the web auth endpoint are authenticated.
+func (s *TokenSource) HTTPClient(hosts ...string) *http.Client {
+	return &http.Client{Transport: &Transport{Source: s, Hosts: hosts}}
+}
@@ -0,0 +1,190 @@
+package orderbook
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+)
+
+// maxArbitrageCycleLength is the maximum number of trades in an arbitrage
+// cycle. A path payment operation supports at most 5 intermediate assets.
+const maxArbitrageCycleLength = 6
+
+var errInvalidCycleLength = errors.New("max cycle length must be between 2 and 6")
+
+// FindArbitrageCycles returns the cycles of at most `maxCycleLength` trades
+// which start by spending `amountToSpend` of `asset` and end with more of
+// `asset` than was spent. No asset other than `asset` appears more than once
+// in a cycle.
+//
+// Every cycle is simulated exactly like a path payment strict send: each hop
+// is filled by whichever of the offers or the liquidity pool (if
+// `includePools` is set) returns more. The returned paths have `asset` as
+// both the source and the destination asset and are sorted by descending
+// profit; shorter cycles come first when the profit is the same.
+//
+// Offers of the account submitting the path payment are not excluded, so
+// cycles crossing them will fail with op_cross_self when submitted.
+func (graph *OrderBookGraph) FindArbitrageCycles(
+	ctx context.Context,
+	maxCycleLength int,
+	asset xdr.Asset,
+	amountToSpend xdr.Int64,
+	includePools bool,
+) ([]Path, uint32, error) {
+	if maxCycleLength < 2 || maxCycleLength > maxArbitrageCycleLength {
+		return nil, 0, errInvalidCycleLength
+	}
+	if amountToSpend <= 0 {
+		return nil, 0, errBadAmount
+	}
+
+	graph.lock.RLock()
+	defer graph.lock.RUnlock()
+
+	assetString := asset.String()
+	assetID, ok := graph.assetStringToID[assetString]
+	if !ok {
+		return []Path{}, graph.lastLedger, nil
+	}
+
+	search := &cycleSearch{
+		ctx:            ctx,
+		graph:          graph,
+		state:          &buyingGraphSearchState{graph: graph, includePools: includePools},
+		maxCycleLength: maxCycleLength,
+		asset:          assetID,
+		amountToSpend:  amountToSpend,
+		interiorNodes:  make([]int32, 0, maxCycleLength-1),
+		cycles:         []Path{},
+	}
+	if err := search.visit(assetID, amountToSpend); err != nil {
+		return nil, graph.lastLedger, errors.Wrap(err, "could not determine arbitrage cycles")
+	}
+
+	cycles := search.cycles
+	sort.SliceStable(cycles, func(i, j int) bool {
+		if cycles[i].DestinationAmount == cycles[j].DestinationAmount {
+			return len(cycles[i].InteriorNodes) < len(cycles[j].InteriorNodes)
+		}
+		return cycles[i].DestinationAmount > cycles[j].DestinationAmount
+	})
+	return cycles, graph.lastLedger, nil
+}
+
+// cycleSearch is a depth first search over graph.venuesForBuyingAsset which
+// enumerates all cycles starting and ending at `asset`.
+type cycleSearch struct {
+	ctx            context.Context
+	graph          *OrderBookGraph
+	state          *buyingGraphSearchState
+	maxCycleLength int
+	asset          int32
+	amountToSpend  xdr.Int64
+	interiorNodes  []int32
+	cycles         []Path
+}
+
+func (s *cycleSearch) visits(asset int32) bool {
+	for _, node := range s.interiorNodes {
+		if node == asset {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *cycleSearch) visit(currentAsset int32, currentAssetAmount xdr.Int64) error {
+	edges := s.state.venues(currentAsset)
+	for i := 0; i < len(edges); i++ {
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
+		nextAsset, venues := edges[i].key, edges[i].value
+		closesCycle := nextAsset == s.asset
+		// The trade closing the cycle is always the last one, so interior
+		// nodes can only be added if there is room for it.
+		if !closesCycle && (len(s.interiorNodes)+2 > s.maxCycleLength || s.visits(nextAsset)) {
+			continue
+		}
+
+		nextAssetAmount, err := processVenues(s.state, currentAsset, currentAssetAmount, venues)
+		if err != nil {
+			return err
+		}
+		if nextAssetAmount <= 0 {
+			continue
+		}
+
+		if closesCycle {
+			if len(s.interiorNodes) > 0 && nextAssetAmount > s.amountToSpend {
+				assetString := s.graph.idToAssetString[s.asset]
+				s.cycles = append(s.cycles, Path{
+					SourceAsset:       assetString,
+					SourceAmount:      s.amountToSpend,
+					DestinationAsset:  assetString,
+					DestinationAmount: nextAssetAmount,
+					InteriorNodes:     assetIDsToAssetStrings(s.graph, s.interiorNodes),
+				})
+			}
+			continue
+		}
+
+		s.interiorNodes = append(s.interiorNodes, nextAsset)
+		err = s.visit(nextAsset, nextAssetAmount)
+		s.interiorNodes = s.interiorNodes[:len(s.interiorNodes)-1]
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PathPaymentStrictSend returns a path payment strict send operation sending
+// p.SourceAmount of p.SourceAsset through the interior nodes of p to
+// `destination`, which must receive at least `destMin` of
+// p.DestinationAsset.
+//
+// For an arbitrage cycle the destination is usually the source account and
+// `destMin` is greater than p.SourceAmount, so the operation fails rather
+// than lose money if the order book has changed.
+func (p Path) PathPaymentStrictSend(destination string, destMin xdr.Int64) (*txnbuild.PathPaymentStrictSend, error) {
+	sendAsset, err := txnbuildAsset(p.SourceAsset)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid source asset %s", p.SourceAsset)
+	}
+	destAsset, err := txnbuildAsset(p.DestinationAsset)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid destination asset %s", p.DestinationAsset)
+	}
+	path := make([]txnbuild.Asset, len(p.InteriorNodes))
+	for i, node := range p.InteriorNodes {
+		if path[i], err = txnbuildAsset(node); err != nil {
+			return nil, errors.Wrapf(err, "invalid interior asset %s", node)
+		}
+	}
+
+	return &txnbuild.PathPaymentStrictSend{
+		SendAsset:   sendAsset,
+		SendAmount:  amount.String(p.SourceAmount),
+		Destination: destination,
+		DestAsset:   destAsset,
+		DestMin:     amount.String(destMin),
+		Path:        path,
+	}, nil
+}
+
+// txnbuildAsset converts an asset string of the graph (see xdr.Asset.String)
+// into a txnbuild.Asset.
+func txnbuildAsset(asset string) (txnbuild.Asset, error) {
+	if parts := strings.Split(asset, "/"); len(parts) == 3 {
+		// type/code/issuer
+		asset = parts[1] + ":" + parts[2]
+	}
+	return txnbuild.ParseAssetString(asset)
+}
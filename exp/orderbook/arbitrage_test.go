@@ -0,0 +1,171 @@
+package orderbook
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+)
+
+func makeArbitrageGraph(t *testing.T, pools ...xdr.LiquidityPoolEntry) *OrderBookGraph {
+	graph := NewOrderBookGraph()
+	graph.AddOffers(
+		// native -> usd at 1 usd per native
+		xdr.OfferEntry{
+			SellerId: issuer,
+			OfferId:  100,
+			Buying:   nativeAsset,
+			Selling:  usdAsset,
+			Price:    xdr.Price{N: 1, D: 1},
+			Amount:   1000,
+		},
+		// usd -> eur at 1 eur per usd
+		xdr.OfferEntry{
+			SellerId: issuer,
+			OfferId:  101,
+			Buying:   usdAsset,
+			Selling:  eurAsset,
+			Price:    xdr.Price{N: 1, D: 1},
+			Amount:   1000,
+		},
+		// eur -> native at 2 native per eur
+		xdr.OfferEntry{
+			SellerId: issuer,
+			OfferId:  102,
+			Buying:   eurAsset,
+			Selling:  nativeAsset,
+			Price:    xdr.Price{N: 1, D: 2},
+			Amount:   10000,
+		},
+		// usd -> native at 4 and 1 native per usd
+		quarterOffer,
+		dollarOffer,
+		// chf is a dead end
+		xdr.OfferEntry{
+			SellerId: issuer,
+			OfferId:  103,
+			Buying:   nativeAsset,
+			Selling:  chfAsset,
+			Price:    xdr.Price{N: 1, D: 1},
+			Amount:   1000,
+		},
+	)
+	graph.AddLiquidityPools(pools...)
+	require.NoError(t, graph.Apply(5))
+	return graph
+}
+
+func TestFindArbitrageCycles(t *testing.T) {
+	graph := makeArbitrageGraph(t)
+	ctx := context.Background()
+
+	cycles, lastLedger, err := graph.FindArbitrageCycles(ctx, 3, nativeAsset, 100, false)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(5), lastLedger)
+	assert.Equal(t, []Path{
+		{
+			SourceAsset:       nativeAsset.String(),
+			SourceAmount:      100,
+			DestinationAsset:  nativeAsset.String(),
+			DestinationAmount: 400,
+			InteriorNodes:     []string{usdAsset.String()},
+		},
+		{
+			SourceAsset:       nativeAsset.String(),
+			SourceAmount:      100,
+			DestinationAsset:  nativeAsset.String(),
+			DestinationAmount: 200,
+			InteriorNodes:     []string{usdAsset.String(), eurAsset.String()},
+		},
+	}, cycles)
+
+	cycles, _, err = graph.FindArbitrageCycles(ctx, 2, nativeAsset, 100, false)
+	require.NoError(t, err)
+	require.Len(t, cycles, 1)
+	assert.Equal(t, []string{usdAsset.String()}, cycles[0].InteriorNodes)
+
+	// Spending more than the quarter offer can fill falls through to the
+	// dollar offer, which leaves only the cycle through eur profitable.
+	cycles, _, err = graph.FindArbitrageCycles(ctx, 3, nativeAsset, 1000, false)
+	require.NoError(t, err)
+	require.Len(t, cycles, 1)
+	assert.Equal(t, xdr.Int64(2000), cycles[0].DestinationAmount)
+
+	cycles, _, err = graph.FindArbitrageCycles(ctx, 3, usdAsset, 100, false)
+	require.NoError(t, err)
+	require.Len(t, cycles, 2)
+	assert.Equal(t, xdr.Int64(400), cycles[0].DestinationAmount)
+	assert.Equal(t, []string{nativeAsset.String()}, cycles[0].InteriorNodes)
+	assert.Equal(t, xdr.Int64(200), cycles[1].DestinationAmount)
+	assert.Equal(t, []string{eurAsset.String(), nativeAsset.String()}, cycles[1].InteriorNodes)
+
+	cycles, _, err = graph.FindArbitrageCycles(ctx, 3, yenAsset, 100, false)
+	require.NoError(t, err)
+	assert.Empty(t, cycles)
+
+	_, _, err = graph.FindArbitrageCycles(ctx, 1, nativeAsset, 100, false)
+	assert.Equal(t, errInvalidCycleLength, err)
+	_, _, err = graph.FindArbitrageCycles(ctx, 7, nativeAsset, 100, false)
+	assert.Equal(t, errInvalidCycleLength, err)
+	_, _, err = graph.FindArbitrageCycles(ctx, 3, nativeAsset, 0, false)
+	assert.Equal(t, errBadAmount, err)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, _, err = graph.FindArbitrageCycles(cancelled, 3, nativeAsset, 100, false)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestFindArbitrageCyclesWithPools(t *testing.T) {
+	// The pool pays out more eur for usd than the offer.
+	pool := makePool(usdAsset, eurAsset, 1000, 2000)
+	graph := makeArbitrageGraph(t, pool)
+	ctx := context.Background()
+
+	cycles, _, err := graph.FindArbitrageCycles(ctx, 3, nativeAsset, 100, false)
+	require.NoError(t, err)
+	require.Len(t, cycles, 2)
+	assert.Equal(t, xdr.Int64(200), cycles[1].DestinationAmount)
+
+	eur, _, ok := CalculatePoolPayout(1000, 2000, 100, pool.Body.ConstantProduct.Params.Fee, false)
+	require.True(t, ok)
+	require.Greater(t, eur, xdr.Int64(100))
+	cycles, _, err = graph.FindArbitrageCycles(ctx, 3, nativeAsset, 100, true)
+	require.NoError(t, err)
+	require.Len(t, cycles, 2)
+	assert.Equal(t, 2*eur, cycles[1].DestinationAmount)
+	assert.Equal(t, []string{usdAsset.String(), eurAsset.String()}, cycles[1].InteriorNodes)
+}
+
+func TestPathPaymentStrictSend(t *testing.T) {
+	graph := makeArbitrageGraph(t)
+	cycles, _, err := graph.FindArbitrageCycles(context.Background(), 3, nativeAsset, 100, false)
+	require.NoError(t, err)
+	require.Len(t, cycles, 2)
+
+	account := keypair.MustRandom().Address()
+	op, err := cycles[1].PathPaymentStrictSend(account, 101)
+	require.NoError(t, err)
+	issuerAddress := issuer.Address()
+	assert.Equal(t, &txnbuild.PathPaymentStrictSend{
+		SendAsset:   txnbuild.NativeAsset{},
+		SendAmount:  "0.0000100",
+		Destination: account,
+		DestAsset:   txnbuild.NativeAsset{},
+		DestMin:     "0.0000101",
+		Path: []txnbuild.Asset{
+			txnbuild.CreditAsset{Code: "usd", Issuer: issuerAddress},
+			txnbuild.CreditAsset{Code: "eur", Issuer: issuerAddress},
+		},
+	}, op)
+	_, err = op.BuildXDR()
+	require.NoError(t, err)
+
+	_, err = Path{SourceAsset: "credit_alphanum4/usd/nope"}.PathPaymentStrictSend(account, 1)
+	assert.ErrorContains(t, err, "invalid source asset")
+}
@@ -0,0 +1,432 @@
+package orderbook
+
+import (
+	"math"
+
+	"github.com/stellar/go/price"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// ErrInsufficientLiquidity is returned by ExpectedFill when neither the offers
+// nor the liquidity pool of a market can fill the requested amount.
+var ErrInsufficientLiquidity = errors.New("not enough liquidity to fill the requested amount")
+
+var errInvalidLevels = errors.New("number of price levels must be positive")
+
+const (
+	// poolLevelStep is the relative price difference between the levels
+	// generated from the curve of a liquidity pool.
+	poolLevelStep = 0.01
+	// maxPoolLevelSteps bounds the number of prices tried when generating
+	// the levels of a pool too small to provide liquidity at every step.
+	maxPoolLevelSteps = 1000
+)
+
+// TradeSide is the direction of a trade in a market, from the point of view
+// of the base asset.
+type TradeSide int
+
+const (
+	// BuyBase buys the base asset with the counter asset, filling asks.
+	BuyBase TradeSide = iota
+	// SellBase sells the base asset for the counter asset, filling bids.
+	SellBase
+)
+
+// PriceLevel is an aggregated price level of one side of a market. Prices are
+// in units of the counter asset per unit of the base asset and amounts are in
+// units of the base asset.
+type PriceLevel struct {
+	Price float64
+	// Amount is the sum of OfferAmount and PoolAmount.
+	Amount xdr.Int64
+	// OfferAmount is the amount offered at exactly this price.
+	OfferAmount xdr.Int64
+	// PoolAmount is the additional amount which can be traded with the
+	// liquidity pool of the market at an average price no worse than Price
+	// (and not already included in a better level).
+	PoolAmount xdr.Int64
+}
+
+// MarketDepth is the aggregated depth of a market.
+type MarketDepth struct {
+	// Bids are sorted by descending price.
+	Bids []PriceLevel
+	// Asks are sorted by ascending price.
+	Asks []PriceLevel
+	// LastLedger is the ledger the depth is accurate up to.
+	LastLedger uint32
+}
+
+// MarketQuote is the top of the book of a market. Prices take liquidity pools
+// into account: the pool price is its marginal price (including its fee) for
+// an infinitesimally small trade.
+type MarketQuote struct {
+	// BestBid is 0 if nobody is buying the base asset.
+	BestBid float64
+	// BestAsk is 0 if nobody is selling the base asset.
+	BestAsk float64
+	// MidPrice is 0 unless there are both bids and asks.
+	MidPrice float64
+	// LastLedger is the ledger the quote is accurate up to.
+	LastLedger uint32
+}
+
+// Fill is the expected result of trading an amount of the base asset in a
+// market.
+type Fill struct {
+	BaseAmount    xdr.Int64
+	CounterAmount xdr.Int64
+	// Price is the average price of the fill.
+	Price float64
+	// Slippage is the relative difference between Price and the best price
+	// on the filled side of the market, e.g. 0.01 means the fill is 1% worse
+	// than the top of the book.
+	Slippage float64
+	// FromPool is true if the trade would be filled by the liquidity pool
+	// rather than by offers. Like the network, ExpectedFill uses whichever
+	// venue gives the better result and never mixes the two.
+	FromPool bool
+	// LastLedger is the ledger the fill is accurate up to.
+	LastLedger uint32
+}
+
+// market holds the venues of a base/counter asset pair.
+type market struct {
+	base, counter int32
+	// asks are the offers selling base for counter, sorted by ascending
+	// price.
+	asks []xdr.OfferEntry
+	// bids are the offers selling counter for base, sorted by descending
+	// price (in terms of counter per base).
+	bids []xdr.OfferEntry
+	pool liquidityPool
+}
+
+func (m market) hasPool() bool {
+	return m.pool.Body.ConstantProduct != nil
+}
+
+// market returns the venues of the given pair. It must be called while
+// holding the graph lock.
+func (graph *OrderBookGraph) market(base, counter xdr.Asset) (market, bool) {
+	baseID, ok := graph.assetStringToID[base.String()]
+	if !ok {
+		return market{}, false
+	}
+	counterID, ok := graph.assetStringToID[counter.String()]
+	if !ok {
+		return market{}, false
+	}
+
+	m := market{base: baseID, counter: counterID}
+	if i := graph.venuesForSellingAsset[baseID].find(counterID); i >= 0 {
+		venues := graph.venuesForSellingAsset[baseID][i].value
+		m.asks, m.pool = venues.offers, venues.pool
+	}
+	if i := graph.venuesForSellingAsset[counterID].find(baseID); i >= 0 {
+		venues := graph.venuesForSellingAsset[counterID][i].value
+		m.bids, m.pool = venues.offers, venues.pool
+	}
+	return m, true
+}
+
+// Depth returns up to `levels` aggregated price levels on each side of the
+// market between the base and counter assets.
+//
+// The levels are the distinct prices of the offers in the market. Liquidity
+// of the market's pool is attributed to the levels according to the average
+// price of trading with the pool (see CalculatePoolPayout and
+// CalculatePoolExpectation). When a side has fewer than `levels` offer
+// prices, including none, the remaining levels are generated from the pool's
+// curve, 1% apart in price, starting from the worst offer price or from the
+// pool's marginal price.
+func (graph *OrderBookGraph) Depth(base, counter xdr.Asset, levels int) (MarketDepth, error) {
+	if levels <= 0 {
+		return MarketDepth{}, errInvalidLevels
+	}
+
+	graph.lock.RLock()
+	defer graph.lock.RUnlock()
+
+	depth := MarketDepth{LastLedger: graph.lastLedger}
+	m, ok := graph.market(base, counter)
+	if !ok {
+		return depth, nil
+	}
+
+	var err error
+	if depth.Asks, err = m.levels(m.asks, BuyBase, levels); err != nil {
+		return MarketDepth{}, errors.Wrap(err, "could not aggregate asks")
+	}
+	if depth.Bids, err = m.levels(m.bids, SellBase, levels); err != nil {
+		return MarketDepth{}, errors.Wrap(err, "could not aggregate bids")
+	}
+	return depth, nil
+}
+
+func (m market) levels(offers []xdr.OfferEntry, side TradeSide, levels int) ([]PriceLevel, error) {
+	var result []PriceLevel
+	var poolTotal xdr.Int64
+	for i := 0; i < len(offers) && len(result) < levels; {
+		level := PriceLevel{Price: offerPrice(offers[i], side)}
+		j := i
+		for ; j < len(offers) && offers[j].Price.Equal(offers[i].Price); j++ {
+			amount, err := offerBaseAmount(offers[j], side)
+			if err != nil {
+				return nil, err
+			}
+			level.OfferAmount += amount
+		}
+		i = j
+
+		if m.hasPool() {
+			cumulative := m.poolDepth(side, level.Price)
+			if cumulative > poolTotal {
+				level.PoolAmount = cumulative - poolTotal
+				poolTotal = cumulative
+			}
+		}
+		level.Amount = level.OfferAmount + level.PoolAmount
+		result = append(result, level)
+	}
+
+	if m.hasPool() && len(result) < levels {
+		result = m.poolLevels(result, side, levels, poolTotal)
+	}
+	return result, nil
+}
+
+// poolLevels appends levels of the pool's liquidity past the last level, or
+// past the pool's marginal price if there is none, until there are `levels`
+// levels. poolTotal is the amount of the pool already in the levels.
+func (m market) poolLevels(result []PriceLevel, side TradeSide, levels int, poolTotal xdr.Int64) []PriceLevel {
+	levelPrice := m.poolPrice(side)
+	if len(result) > 0 {
+		levelPrice = result[len(result)-1].Price
+	}
+	if levelPrice <= 0 {
+		return result
+	}
+
+	baseReserve, _, _ := m.poolReserves()
+	for step := 0; len(result) < levels && step < maxPoolLevelSteps; step++ {
+		if side == BuyBase {
+			// The pool cannot sell its whole base reserve.
+			if poolTotal >= baseReserve-1 {
+				break
+			}
+			levelPrice *= 1 + poolLevelStep
+		} else {
+			levelPrice *= 1 - poolLevelStep
+		}
+
+		cumulative := m.poolDepth(side, levelPrice)
+		if cumulative <= poolTotal {
+			continue
+		}
+		amount := cumulative - poolTotal
+		result = append(result, PriceLevel{Price: levelPrice, Amount: amount, PoolAmount: amount})
+		poolTotal = cumulative
+	}
+	return result
+}
+
+// offerPrice returns the price of an offer in counter per base.
+func offerPrice(offer xdr.OfferEntry, side TradeSide) float64 {
+	if side == BuyBase {
+		return float64(offer.Price.N) / float64(offer.Price.D)
+	}
+	return float64(offer.Price.D) / float64(offer.Price.N)
+}
+
+// offerBaseAmount returns the amount of the base asset an offer trades.
+func offerBaseAmount(offer xdr.OfferEntry, side TradeSide) (xdr.Int64, error) {
+	if side == BuyBase {
+		return offer.Amount, nil
+	}
+	// The offer sells the counter asset at a price of base per counter.
+	amount, err := price.MulFractionRoundDown(int64(offer.Amount), int64(offer.Price.N), int64(offer.Price.D))
+	return xdr.Int64(amount), err
+}
+
+// poolReserves returns the base and counter reserves of the market's pool.
+func (m market) poolReserves() (xdr.Int64, xdr.Int64, xdr.Int32) {
+	details := m.pool.Body.MustConstantProduct()
+	if m.pool.assetA == m.base {
+		return details.ReserveA, details.ReserveB, details.Params.Fee
+	}
+	return details.ReserveB, details.ReserveA, details.Params.Fee
+}
+
+// poolTrade returns the amount of the counter asset paid (when buying) or
+// received (when selling) for `amount` of the base asset.
+func (m market) poolTrade(side TradeSide, amount xdr.Int64) (xdr.Int64, bool) {
+	baseReserve, counterReserve, fee := m.poolReserves()
+	var counterAmount xdr.Int64
+	var ok bool
+	if side == BuyBase {
+		counterAmount, _, ok = CalculatePoolExpectation(counterReserve, baseReserve, amount, fee, false)
+	} else {
+		counterAmount, _, ok = CalculatePoolPayout(baseReserve, counterReserve, amount, fee, false)
+	}
+	return counterAmount, ok && counterAmount > 0
+}
+
+// poolDepth returns the largest amount of the base asset which can be traded
+// with the pool at an average price no worse than `limit`.
+func (m market) poolDepth(side TradeSide, limit float64) xdr.Int64 {
+	baseReserve, _, _ := m.poolReserves()
+	low, high := xdr.Int64(0), baseReserve-1
+	if side == SellBase {
+		high = math.MaxInt64 - baseReserve
+	}
+	// The average price only gets worse as the amount grows, so the largest
+	// acceptable amount can be found with a binary search.
+	for low < high {
+		mid := low + (high-low+1)/2
+		counterAmount, ok := m.poolTrade(side, mid)
+		acceptable := ok
+		if ok {
+			average := float64(counterAmount) / float64(mid)
+			if side == BuyBase {
+				acceptable = average <= limit
+			} else {
+				acceptable = average >= limit
+			}
+		}
+		if acceptable {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+	return low
+}
+
+// poolPrice returns the marginal price of the pool for an infinitesimally
+// small trade, including the pool fee.
+func (m market) poolPrice(side TradeSide) float64 {
+	baseReserve, counterReserve, fee := m.poolReserves()
+	if baseReserve == 0 || counterReserve == 0 {
+		return 0
+	}
+	spot := float64(counterReserve) / float64(baseReserve)
+	remaining := float64(maxBasisPoints-fee) / maxBasisPoints
+	if side == BuyBase {
+		return spot / remaining
+	}
+	return spot * remaining
+}
+
+// bestPrice returns the best price on the given side of the market, or 0 if
+// there is none.
+func (m market) bestPrice(side TradeSide) float64 {
+	offers := m.asks
+	if side == SellBase {
+		offers = m.bids
+	}
+	var best float64
+	if len(offers) > 0 {
+		best = offerPrice(offers[0], side)
+	}
+	if m.hasPool() {
+		if poolPrice := m.poolPrice(side); poolPrice > 0 &&
+			(best == 0 || (side == BuyBase && poolPrice < best) || (side == SellBase && poolPrice > best)) {
+			best = poolPrice
+		}
+	}
+	return best
+}
+
+// Quote returns the best bid, best ask and mid-price of the market between
+// the base and counter assets.
+func (graph *OrderBookGraph) Quote(base, counter xdr.Asset) MarketQuote {
+	graph.lock.RLock()
+	defer graph.lock.RUnlock()
+
+	quote := MarketQuote{LastLedger: graph.lastLedger}
+	m, ok := graph.market(base, counter)
+	if !ok {
+		return quote
+	}
+	quote.BestBid = m.bestPrice(SellBase)
+	quote.BestAsk = m.bestPrice(BuyBase)
+	if quote.BestBid > 0 && quote.BestAsk > 0 {
+		quote.MidPrice = (quote.BestBid + quote.BestAsk) / 2
+	}
+	return quote
+}
+
+// ExpectedFill simulates buying or selling `amount` of the base asset in the
+// market between the base and counter assets, and returns the expected
+// average price and slippage. Offers are crossed the same way the network
+// crosses them in path payments, so offers from all accounts are considered.
+//
+// ErrInsufficientLiquidity is returned if the market cannot fill the amount.
+func (graph *OrderBookGraph) ExpectedFill(
+	base, counter xdr.Asset,
+	side TradeSide,
+	amount xdr.Int64,
+) (Fill, error) {
+	if amount <= 0 {
+		return Fill{}, errBadAmount
+	}
+
+	graph.lock.RLock()
+	defer graph.lock.RUnlock()
+
+	fill := Fill{BaseAmount: amount, LastLedger: graph.lastLedger}
+	m, ok := graph.market(base, counter)
+	if !ok {
+		return fill, ErrInsufficientLiquidity
+	}
+
+	// offerAmount is -1 if the offers cannot fill the amount.
+	offerAmount := xdr.Int64(-1)
+	var err error
+	if side == BuyBase && len(m.asks) > 0 {
+		offerAmount, err = consumeOffersForSellingAsset(m.asks, nil, amount, 0)
+	} else if side == SellBase && len(m.bids) > 0 {
+		offerAmount, err = consumeOffersForBuyingAsset(m.bids, amount)
+	}
+	if err != nil {
+		return fill, errors.Wrap(err, "could not cross offers")
+	}
+	if offerAmount == 0 {
+		offerAmount = -1
+	}
+
+	poolAmount := xdr.Int64(-1)
+	if m.hasPool() {
+		if counterAmount, ok := m.poolTrade(side, amount); ok {
+			poolAmount = counterAmount
+		}
+	}
+
+	switch {
+	case offerAmount < 0 && poolAmount < 0:
+		return fill, ErrInsufficientLiquidity
+	case offerAmount < 0:
+		fill.CounterAmount, fill.FromPool = poolAmount, true
+	case poolAmount < 0:
+		fill.CounterAmount = offerAmount
+	case side == BuyBase:
+		// Pay as little as possible.
+		fill.CounterAmount, fill.FromPool = min(offerAmount, poolAmount), poolAmount < offerAmount
+	default:
+		// Receive as much as possible.
+		fill.CounterAmount, fill.FromPool = max(offerAmount, poolAmount), poolAmount > offerAmount
+	}
+
+	fill.Price = float64(fill.CounterAmount) / float64(amount)
+	if best := m.bestPrice(side); best > 0 {
+		if side == BuyBase {
+			fill.Slippage = (fill.Price - best) / best
+		} else {
+			fill.Slippage = (best - fill.Price) / best
+		}
+	}
+	return fill, nil
+}
@@ -0,0 +1,263 @@
+package orderbook
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/xdr"
+)
+
+func makeMarketGraph(t *testing.T, pools ...xdr.LiquidityPoolEntry) *OrderBookGraph {
+	graph := NewOrderBookGraph()
+	denormalizedFiftyCentsOffer := fiftyCentsOffer
+	denormalizedFiftyCentsOffer.OfferId = 10
+	denormalizedFiftyCentsOffer.Price = xdr.Price{N: 2, D: 4}
+	denormalizedFiftyCentsOffer.Amount = 100
+	graph.AddOffers(
+		quarterOffer,
+		fiftyCentsOffer,
+		denormalizedFiftyCentsOffer,
+		dollarOffer,
+		xdr.OfferEntry{
+			SellerId: issuer,
+			OfferId:  11,
+			Buying:   nativeAsset,
+			Selling:  usdAsset,
+			Price:    xdr.Price{N: 5, D: 1},
+			Amount:   100,
+		},
+		xdr.OfferEntry{
+			SellerId: issuer,
+			OfferId:  12,
+			Buying:   nativeAsset,
+			Selling:  usdAsset,
+			Price:    xdr.Price{N: 10, D: 1},
+			Amount:   50,
+		},
+		eurOffer,
+	)
+	graph.AddLiquidityPools(pools...)
+	require.NoError(t, graph.Apply(7))
+	return graph
+}
+
+func TestDepth(t *testing.T) {
+	graph := makeMarketGraph(t)
+
+	depth, err := graph.Depth(nativeAsset, usdAsset, 2)
+	require.NoError(t, err)
+	assert.Equal(t, MarketDepth{
+		Asks: []PriceLevel{
+			{Price: 0.25, Amount: 500, OfferAmount: 500},
+			{Price: 0.5, Amount: 600, OfferAmount: 600},
+		},
+		Bids: []PriceLevel{
+			{Price: 0.2, Amount: 500, OfferAmount: 500},
+			{Price: 0.1, Amount: 500, OfferAmount: 500},
+		},
+		LastLedger: 7,
+	}, depth)
+
+	depth, err = graph.Depth(nativeAsset, usdAsset, 10)
+	require.NoError(t, err)
+	assert.Len(t, depth.Asks, 3)
+	assert.Len(t, depth.Bids, 2)
+
+	// Swapping base and counter swaps the sides.
+	depth, err = graph.Depth(usdAsset, nativeAsset, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []PriceLevel{{Price: 4, Amount: 125, OfferAmount: 125}}, depth.Bids)
+	assert.Equal(t, []PriceLevel{{Price: 5, Amount: 100, OfferAmount: 100}}, depth.Asks)
+
+	depth, err = graph.Depth(nativeAsset, chfAsset, 2)
+	require.NoError(t, err)
+	assert.Equal(t, MarketDepth{LastLedger: 7}, depth)
+
+	_, err = graph.Depth(nativeAsset, usdAsset, 0)
+	assert.Equal(t, errInvalidLevels, err)
+}
+
+func TestDepthWithPool(t *testing.T) {
+	pool := makePool(nativeAsset, usdAsset, 10000, 2250)
+	graph := makeMarketGraph(t, pool)
+	fee := pool.Body.ConstantProduct.Params.Fee
+
+	depth, err := graph.Depth(nativeAsset, usdAsset, 3)
+	require.NoError(t, err)
+	require.Len(t, depth.Asks, 3)
+	require.Len(t, depth.Bids, 3)
+
+	var total xdr.Int64
+	for _, level := range depth.Asks {
+		assert.Equal(t, level.OfferAmount+level.PoolAmount, level.Amount)
+		total += level.PoolAmount
+		// total is the largest amount which can be bought from the pool at
+		// an average price no worse than the level's price.
+		cost, _, ok := CalculatePoolExpectation(2250, 10000, total, fee, false)
+		require.True(t, ok)
+		assert.LessOrEqual(t, float64(cost)/float64(total), level.Price)
+		cost, _, ok = CalculatePoolExpectation(2250, 10000, total+1, fee, false)
+		require.True(t, ok)
+		assert.Greater(t, float64(cost)/float64(total+1), level.Price)
+	}
+	assert.Positive(t, depth.Asks[0].PoolAmount)
+
+	// The pool pays more than both bids for any amount, so all of its
+	// liquidity down to the best bid's price is attributed to it.
+	assert.Positive(t, depth.Bids[0].PoolAmount)
+	payout, _, ok := CalculatePoolPayout(10000, 2250, depth.Bids[0].PoolAmount, fee, false)
+	require.True(t, ok)
+	assert.GreaterOrEqual(t, float64(payout)/float64(depth.Bids[0].PoolAmount), 0.2)
+
+	// There are only two bid prices, so the last level comes from the pool.
+	assert.InDelta(t, 0.1*(1-poolLevelStep), depth.Bids[2].Price, 1e-9)
+	assert.Zero(t, depth.Bids[2].OfferAmount)
+	assert.Positive(t, depth.Bids[2].PoolAmount)
+}
+
+func TestDepthPoolOnly(t *testing.T) {
+	pool := makePool(nativeAsset, usdAsset, 10000, 2250)
+	fee := pool.Body.ConstantProduct.Params.Fee
+	graph := NewOrderBookGraph()
+	graph.AddLiquidityPools(pool)
+	require.NoError(t, graph.Apply(7))
+	quote := graph.Quote(nativeAsset, usdAsset)
+
+	depth, err := graph.Depth(nativeAsset, usdAsset, 5)
+	require.NoError(t, err)
+	require.Len(t, depth.Asks, 5)
+	require.Len(t, depth.Bids, 5)
+
+	var total xdr.Int64
+	previous := quote.BestAsk
+	for _, level := range depth.Asks {
+		assert.Greater(t, level.Price, previous)
+		previous = level.Price
+		assert.Zero(t, level.OfferAmount)
+		assert.Positive(t, level.PoolAmount)
+		assert.Equal(t, level.PoolAmount, level.Amount)
+		total += level.PoolAmount
+		cost, _, ok := CalculatePoolExpectation(2250, 10000, total, fee, false)
+		require.True(t, ok)
+		assert.LessOrEqual(t, float64(cost)/float64(total), level.Price)
+		cost, _, ok = CalculatePoolExpectation(2250, 10000, total+1, fee, false)
+		require.True(t, ok)
+		assert.Greater(t, float64(cost)/float64(total+1), level.Price)
+	}
+
+	total = 0
+	previous = quote.BestBid
+	for _, level := range depth.Bids {
+		assert.Less(t, level.Price, previous)
+		previous = level.Price
+		assert.Zero(t, level.OfferAmount)
+		assert.Positive(t, level.PoolAmount)
+		assert.Equal(t, level.PoolAmount, level.Amount)
+		total += level.PoolAmount
+		payout, _, ok := CalculatePoolPayout(10000, 2250, total, fee, false)
+		require.True(t, ok)
+		assert.GreaterOrEqual(t, float64(payout)/float64(total), level.Price)
+	}
+
+	// A pool with almost no reserves runs out of levels.
+	graph = NewOrderBookGraph()
+	graph.AddLiquidityPools(makePool(nativeAsset, usdAsset, 3, 3))
+	require.NoError(t, graph.Apply(8))
+	depth, err = graph.Depth(nativeAsset, usdAsset, 10)
+	require.NoError(t, err)
+	assert.Less(t, len(depth.Asks), 10)
+}
+
+func TestQuote(t *testing.T) {
+	graph := makeMarketGraph(t)
+	assert.Equal(t, MarketQuote{
+		BestBid:    0.2,
+		BestAsk:    0.25,
+		MidPrice:   0.225,
+		LastLedger: 7,
+	}, graph.Quote(nativeAsset, usdAsset))
+
+	assert.Equal(t, MarketQuote{
+		BestAsk:    1,
+		LastLedger: 7,
+	}, graph.Quote(nativeAsset, eurAsset))
+
+	assert.Equal(t, MarketQuote{LastLedger: 7}, graph.Quote(nativeAsset, chfAsset))
+
+	// The pool's marginal prices, including its 0.3% fee, are better than
+	// the offers.
+	graph = makeMarketGraph(t, makePool(nativeAsset, usdAsset, 10000, 2250))
+	quote := graph.Quote(nativeAsset, usdAsset)
+	assert.InDelta(t, 0.225*0.997, quote.BestBid, 1e-9)
+	assert.InDelta(t, 0.225/0.997, quote.BestAsk, 1e-9)
+	assert.InDelta(t, (0.225*0.997+0.225/0.997)/2, quote.MidPrice, 1e-9)
+}
+
+func TestExpectedFill(t *testing.T) {
+	graph := makeMarketGraph(t)
+
+	// 500 at 0.25 and 200 at 0.5
+	fill, err := graph.ExpectedFill(nativeAsset, usdAsset, BuyBase, 700)
+	require.NoError(t, err)
+	assert.Equal(t, xdr.Int64(700), fill.BaseAmount)
+	assert.Equal(t, xdr.Int64(225), fill.CounterAmount)
+	assert.InDelta(t, 225.0/700, fill.Price, 1e-9)
+	assert.InDelta(t, (225.0/700-0.25)/0.25, fill.Slippage, 1e-9)
+	assert.False(t, fill.FromPool)
+	assert.Equal(t, uint32(7), fill.LastLedger)
+
+	// 500 at 0.2 and 100 at 0.1
+	fill, err = graph.ExpectedFill(nativeAsset, usdAsset, SellBase, 600)
+	require.NoError(t, err)
+	assert.Equal(t, xdr.Int64(110), fill.CounterAmount)
+	assert.InDelta(t, 110.0/600, fill.Price, 1e-9)
+	assert.InDelta(t, (0.2-110.0/600)/0.2, fill.Slippage, 1e-9)
+
+	fill, err = graph.ExpectedFill(nativeAsset, usdAsset, BuyBase, 100)
+	require.NoError(t, err)
+	assert.Equal(t, xdr.Int64(25), fill.CounterAmount)
+	assert.Zero(t, fill.Slippage)
+
+	_, err = graph.ExpectedFill(nativeAsset, usdAsset, BuyBase, 1601)
+	assert.Equal(t, ErrInsufficientLiquidity, err)
+	_, err = graph.ExpectedFill(nativeAsset, chfAsset, SellBase, 1)
+	assert.Equal(t, ErrInsufficientLiquidity, err)
+	_, err = graph.ExpectedFill(nativeAsset, usdAsset, SellBase, 0)
+	assert.Equal(t, errBadAmount, err)
+}
+
+func TestExpectedFillWithPool(t *testing.T) {
+	pool := makePool(nativeAsset, usdAsset, 10000, 2250)
+	fee := pool.Body.ConstantProduct.Params.Fee
+	graph := makeMarketGraph(t, pool)
+
+	cost, _, ok := CalculatePoolExpectation(2250, 10000, 700, fee, false)
+	require.True(t, ok)
+	require.Less(t, cost, xdr.Int64(225))
+	fill, err := graph.ExpectedFill(nativeAsset, usdAsset, BuyBase, 700)
+	require.NoError(t, err)
+	assert.True(t, fill.FromPool)
+	assert.Equal(t, cost, fill.CounterAmount)
+	best := 0.225 / 0.997
+	assert.InDelta(t, (float64(cost)/700-best)/best, fill.Slippage, 1e-9)
+
+	// The offers cannot fill this amount, but the pool can.
+	cost, _, ok = CalculatePoolExpectation(2250, 10000, 5000, fee, false)
+	require.True(t, ok)
+	fill, err = graph.ExpectedFill(nativeAsset, usdAsset, BuyBase, 5000)
+	require.NoError(t, err)
+	assert.True(t, fill.FromPool)
+	assert.Equal(t, cost, fill.CounterAmount)
+
+	payout, _, ok := CalculatePoolPayout(10000, 2250, 600, fee, false)
+	require.True(t, ok)
+	fill, err = graph.ExpectedFill(nativeAsset, usdAsset, SellBase, 600)
+	require.NoError(t, err)
+	assert.True(t, fill.FromPool)
+	assert.Equal(t, payout, fill.CounterAmount)
+
+	_, err = graph.ExpectedFill(nativeAsset, usdAsset, BuyBase, 10000)
+	assert.Equal(t, ErrInsufficientLiquidity, err)
+}
@@ -476,12 +476,24 @@ func consumeOffersForBuyingAsset(
 	offers []xdr.OfferEntry,
 	currentAssetAmount xdr.Int64,
 ) (xdr.Int64, error) {
+	totalConsumed, _, _, err := crossOffersForBuyingAsset(offers, currentAssetAmount)
+	return totalConsumed, err
+}
+
+// crossOffersForBuyingAsset sells currentAssetAmount to the given offers and
+// returns the amount received, the number of offers which are consumed
+// entirely, and the amount taken from the next (partially consumed) offer.
+// The amount received is -1 if the offers cannot absorb currentAssetAmount.
+func crossOffersForBuyingAsset(
+	offers []xdr.OfferEntry,
+	currentAssetAmount xdr.Int64,
+) (xdr.Int64, int, xdr.Int64, error) {
 	if len(offers) == 0 {
-		return 0, errEmptyOffers
+		return 0, 0, 0, errEmptyOffers
 	}
 
 	if currentAssetAmount == 0 {
-		return 0, errAssetAmountIsZero
+		return 0, 0, 0, errAssetAmountIsZero
 	}
 
 	totalConsumed := xdr.Int64(0)
@@ -495,19 +507,19 @@ func consumeOffersForBuyingAsset(
 		if err == nil {
 			if amountSold == 0 {
 				// not enough of the buying asset to consume the offer
-				return -1, nil
+				return -1, i, 0, nil
 			}
 			if amountSold < 0 {
-				return -1, errSoldTooMuch
+				return -1, i, 0, errSoldTooMuch
 			}
 
 			amountSoldXDR := xdr.Int64(amountSold)
 			if amountSoldXDR <= offers[i].Amount {
 				totalConsumed += amountSoldXDR
-				return totalConsumed, nil
+				return totalConsumed, i, amountSoldXDR, nil
 			}
 		} else if err != price.ErrOverflow {
-			return -1, err
+			return -1, i, 0, err
 		}
 
 		buyingUnitsFromOffer, sellingUnitsFromOffer, err := price.ConvertToBuyingUnits(
@@ -519,23 +531,23 @@ func consumeOffersForBuyingAsset(
 		if err == price.ErrOverflow {
 			// skip paths which would result in overflow errors
 			// but still continue the path finding search
-			return -1, nil
+			return -1, i, 0, nil
 		} else if err != nil {
-			return -1, err
+			return -1, i, 0, err
 		}
 
 		totalConsumed += xdr.Int64(sellingUnitsFromOffer)
 		currentAssetAmount -= xdr.Int64(buyingUnitsFromOffer)
 
 		if currentAssetAmount == 0 {
-			return totalConsumed, nil
+			return totalConsumed, i + 1, 0, nil
 		}
 		if currentAssetAmount < 0 {
-			return -1, errSoldTooMuch
+			return -1, i + 1, 0, errSoldTooMuch
 		}
 	}
 
-	return -1, nil
+	return -1, len(offers), 0, nil
 }
 
 func processVenues(
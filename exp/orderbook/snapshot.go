@@ -0,0 +1,329 @@
+package orderbook
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"sort"
+
+	xdr3 "github.com/stellar/go-xdr/xdr3"
+
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// Snapshots are a sequence of XDR values:
+//
+//	opaque magic[4] = "OBGS"
+//	unsigned int version
+//	unsigned int lastLedger
+//	Hash stateHash
+//	string assets<>       (idToAssetString, vacant ids are empty strings)
+//	int vacantIDs<>
+//	OfferEntry offers<>   (in edge order, so restoring preserves the order of
+//	                       offers with equal prices)
+//	LiquidityPoolEntry pools<>
+const (
+	snapshotMagic   = "OBGS"
+	snapshotVersion = 1
+	// maxAssetStringLength bounds the length of the asset strings read from a
+	// snapshot. The longest asset string is a credit_alphanum12 asset, which is
+	// well below this.
+	maxAssetStringLength = 256
+)
+
+// SnapshotInfo describes a snapshot of an OrderBookGraph.
+type SnapshotInfo struct {
+	// LastLedger is the ledger the graph was accurate up to when the snapshot
+	// was taken.
+	LastLedger uint32
+	// Hash is the StateHash of the offers and liquidity pools in the
+	// snapshot.
+	Hash xdr.Hash
+}
+
+// StateHash returns a SHA-256 hash over the given offers (sorted by offer id)
+// and liquidity pools (sorted by pool id). Two graphs containing the same
+// offers and pools have the same hash, regardless of the order in which they
+// were added.
+func StateHash(offers []xdr.OfferEntry, pools []xdr.LiquidityPoolEntry) (xdr.Hash, error) {
+	offers = append([]xdr.OfferEntry(nil), offers...)
+	sort.Slice(offers, func(i, j int) bool {
+		return offers[i].OfferId < offers[j].OfferId
+	})
+	pools = append([]xdr.LiquidityPoolEntry(nil), pools...)
+	sort.Slice(pools, func(i, j int) bool {
+		return bytes.Compare(pools[i].LiquidityPoolId[:], pools[j].LiquidityPoolId[:]) < 0
+	})
+
+	hasher := sha256.New()
+	encoder := xdr3.NewEncoder(hasher)
+	for i := range offers {
+		if err := offers[i].EncodeTo(encoder); err != nil {
+			return xdr.Hash{}, errors.Wrapf(err, "could not encode offer %d", offers[i].OfferId)
+		}
+	}
+	for i := range pools {
+		if err := pools[i].EncodeTo(encoder); err != nil {
+			return xdr.Hash{}, errors.Wrap(err, "could not encode liquidity pool")
+		}
+	}
+
+	var hash xdr.Hash
+	copy(hash[:], hasher.Sum(nil))
+	return hash, nil
+}
+
+// Hash returns the StateHash of the offers and liquidity pools in the graph,
+// along with the ledger the graph is accurate up to.
+func (graph *OrderBookGraph) Hash() (xdr.Hash, uint32, error) {
+	graph.lock.RLock()
+	defer graph.lock.RUnlock()
+
+	hash, err := StateHash(graph.offersInEdgeOrder(), graph.poolEntries())
+	return hash, graph.lastLedger, err
+}
+
+func (graph *OrderBookGraph) offersInEdgeOrder() []xdr.OfferEntry {
+	offers := make([]xdr.OfferEntry, 0, len(graph.tradingPairForOffer))
+	for _, edges := range graph.venuesForSellingAsset {
+		for _, edge := range edges {
+			offers = append(offers, edge.value.offers...)
+		}
+	}
+	return offers
+}
+
+func (graph *OrderBookGraph) poolEntries() []xdr.LiquidityPoolEntry {
+	pools := make([]xdr.LiquidityPoolEntry, 0, len(graph.liquidityPools))
+	for _, pool := range graph.liquidityPools {
+		pools = append(pools, pool)
+	}
+	return pools
+}
+
+// WriteSnapshot writes the full state of the graph (offers, liquidity pools,
+// asset ids and the last applied ledger) to w. Updates which have been queued
+// but not applied are not included.
+func (graph *OrderBookGraph) WriteSnapshot(w io.Writer) (SnapshotInfo, error) {
+	graph.lock.RLock()
+	defer graph.lock.RUnlock()
+
+	offers, pools := graph.offersInEdgeOrder(), graph.poolEntries()
+	hash, err := StateHash(offers, pools)
+	if err != nil {
+		return SnapshotInfo{}, errors.Wrap(err, "could not hash graph")
+	}
+	info := SnapshotInfo{LastLedger: graph.lastLedger, Hash: hash}
+
+	buffered := bufio.NewWriter(w)
+	encoder := xdr3.NewEncoder(buffered)
+	if _, err = encoder.EncodeFixedOpaque([]byte(snapshotMagic)); err != nil {
+		return SnapshotInfo{}, errors.Wrap(err, "could not write snapshot header")
+	}
+	if _, err = encoder.EncodeUint(snapshotVersion); err != nil {
+		return SnapshotInfo{}, errors.Wrap(err, "could not write snapshot header")
+	}
+	if _, err = encoder.EncodeUint(info.LastLedger); err != nil {
+		return SnapshotInfo{}, errors.Wrap(err, "could not write snapshot header")
+	}
+	if err = info.Hash.EncodeTo(encoder); err != nil {
+		return SnapshotInfo{}, errors.Wrap(err, "could not write snapshot header")
+	}
+
+	if _, err = encoder.EncodeUint(uint32(len(graph.idToAssetString))); err != nil {
+		return SnapshotInfo{}, errors.Wrap(err, "could not write assets")
+	}
+	for _, asset := range graph.idToAssetString {
+		if _, err = encoder.EncodeString(asset); err != nil {
+			return SnapshotInfo{}, errors.Wrap(err, "could not write assets")
+		}
+	}
+
+	if _, err = encoder.EncodeUint(uint32(len(graph.vacantIDs))); err != nil {
+		return SnapshotInfo{}, errors.Wrap(err, "could not write vacant asset ids")
+	}
+	for _, id := range graph.vacantIDs {
+		if _, err = encoder.EncodeInt(id); err != nil {
+			return SnapshotInfo{}, errors.Wrap(err, "could not write vacant asset ids")
+		}
+	}
+
+	if _, err = encoder.EncodeUint(uint32(len(offers))); err != nil {
+		return SnapshotInfo{}, errors.Wrap(err, "could not write offers")
+	}
+	for i := range offers {
+		if err = offers[i].EncodeTo(encoder); err != nil {
+			return SnapshotInfo{}, errors.Wrapf(err, "could not write offer %d", offers[i].OfferId)
+		}
+	}
+
+	if _, err = encoder.EncodeUint(uint32(len(pools))); err != nil {
+		return SnapshotInfo{}, errors.Wrap(err, "could not write liquidity pools")
+	}
+	for i := range pools {
+		if err = pools[i].EncodeTo(encoder); err != nil {
+			return SnapshotInfo{}, errors.Wrap(err, "could not write liquidity pools")
+		}
+	}
+
+	if err = buffered.Flush(); err != nil {
+		return SnapshotInfo{}, errors.Wrap(err, "could not write snapshot")
+	}
+	return info, nil
+}
+
+// ReadSnapshotInfo reads only the header of a snapshot. It can be used to
+// check how stale a snapshot is without restoring it.
+func ReadSnapshotInfo(r io.Reader) (SnapshotInfo, error) {
+	return readSnapshotHeader(xdr3.NewDecoder(r))
+}
+
+func readSnapshotHeader(decoder *xdr3.Decoder) (SnapshotInfo, error) {
+	magic, _, err := decoder.DecodeFixedOpaque(int32(len(snapshotMagic)))
+	if err != nil {
+		return SnapshotInfo{}, errors.Wrap(err, "could not read snapshot header")
+	}
+	if string(magic) != snapshotMagic {
+		return SnapshotInfo{}, errors.New("not an order book graph snapshot")
+	}
+	version, _, err := decoder.DecodeUint()
+	if err != nil {
+		return SnapshotInfo{}, errors.Wrap(err, "could not read snapshot header")
+	}
+	if version != snapshotVersion {
+		return SnapshotInfo{}, errors.Errorf("unsupported snapshot version %d", version)
+	}
+
+	var info SnapshotInfo
+	if info.LastLedger, _, err = decoder.DecodeUint(); err != nil {
+		return SnapshotInfo{}, errors.Wrap(err, "could not read snapshot header")
+	}
+	if _, err = info.Hash.DecodeFrom(decoder, xdr3.DecodeDefaultMaxDepth); err != nil {
+		return SnapshotInfo{}, errors.Wrap(err, "could not read snapshot header")
+	}
+	return info, nil
+}
+
+// RestoreSnapshot replaces the state of the graph with a snapshot written by
+// WriteSnapshot. The offers and liquidity pools read are checked against the
+// hash in the snapshot, and the restored graph is checked with Verify. On
+// error the graph is left unchanged.
+//
+// Any updates queued but not applied are discarded.
+func (graph *OrderBookGraph) RestoreSnapshot(r io.Reader) (SnapshotInfo, error) {
+	decoder := xdr3.NewDecoder(bufio.NewReader(r))
+	info, err := readSnapshotHeader(decoder)
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+
+	count, _, err := decoder.DecodeUint()
+	if err != nil {
+		return SnapshotInfo{}, errors.Wrap(err, "could not read assets")
+	}
+	restored := NewOrderBookGraph()
+	for id := uint32(0); id < count; id++ {
+		asset, _, err := decoder.DecodeString(maxAssetStringLength)
+		if err != nil {
+			return SnapshotInfo{}, errors.Wrap(err, "could not read assets")
+		}
+		restored.idToAssetString = append(restored.idToAssetString, asset)
+		restored.venuesForBuyingAsset = append(restored.venuesForBuyingAsset, nil)
+		restored.venuesForSellingAsset = append(restored.venuesForSellingAsset, nil)
+		if asset != "" {
+			restored.assetStringToID[asset] = int32(id)
+		}
+	}
+
+	if count, _, err = decoder.DecodeUint(); err != nil {
+		return SnapshotInfo{}, errors.Wrap(err, "could not read vacant asset ids")
+	}
+	for i := uint32(0); i < count; i++ {
+		id, _, err := decoder.DecodeInt()
+		if err != nil {
+			return SnapshotInfo{}, errors.Wrap(err, "could not read vacant asset ids")
+		}
+		if id < 0 || int(id) >= len(restored.idToAssetString) || restored.idToAssetString[id] != "" {
+			return SnapshotInfo{}, errors.Errorf("asset id %d is not vacant", id)
+		}
+		restored.vacantIDs = append(restored.vacantIDs, id)
+	}
+
+	if count, _, err = decoder.DecodeUint(); err != nil {
+		return SnapshotInfo{}, errors.Wrap(err, "could not read offers")
+	}
+	var offers []xdr.OfferEntry
+	for i := uint32(0); i < count; i++ {
+		var offer xdr.OfferEntry
+		if _, err = offer.DecodeFrom(decoder, xdr3.DecodeDefaultMaxDepth); err != nil {
+			return SnapshotInfo{}, errors.Wrap(err, "could not read offers")
+		}
+		if _, ok := restored.tradingPairForOffer[offer.OfferId]; ok {
+			return SnapshotInfo{}, errors.Errorf("offer %d is present more than once", offer.OfferId)
+		}
+		if !restored.hasAssets(offer.Buying, offer.Selling) {
+			return SnapshotInfo{}, errors.Errorf("assets of offer %d are missing from the snapshot", offer.OfferId)
+		}
+		if err = restored.addOffer(offer); err != nil {
+			return SnapshotInfo{}, errors.Wrapf(err, "could not restore offer %d", offer.OfferId)
+		}
+		offers = append(offers, offer)
+	}
+
+	if count, _, err = decoder.DecodeUint(); err != nil {
+		return SnapshotInfo{}, errors.Wrap(err, "could not read liquidity pools")
+	}
+	var pools []xdr.LiquidityPoolEntry
+	for i := uint32(0); i < count; i++ {
+		var pool xdr.LiquidityPoolEntry
+		if _, err = pool.DecodeFrom(decoder, xdr3.DecodeDefaultMaxDepth); err != nil {
+			return SnapshotInfo{}, errors.Wrap(err, "could not read liquidity pools")
+		}
+		if pool.Body.ConstantProduct == nil {
+			return SnapshotInfo{}, errBadPoolType
+		}
+		if assetA, assetB := getPoolAssets(pool); !restored.hasAssets(assetA, assetB) {
+			return SnapshotInfo{}, errors.New("assets of liquidity pool are missing from the snapshot")
+		}
+		restored.addPool(pool)
+		pools = append(pools, pool)
+	}
+
+	hash, err := StateHash(offers, pools)
+	if err != nil {
+		return SnapshotInfo{}, errors.Wrap(err, "could not hash snapshot")
+	}
+	if hash != info.Hash {
+		return SnapshotInfo{}, errors.Errorf(
+			"snapshot hash %x does not match the hash of its contents %x", info.Hash, hash,
+		)
+	}
+	if _, _, err = restored.Verify(); err != nil {
+		return SnapshotInfo{}, errors.Wrap(err, "restored graph is inconsistent")
+	}
+
+	graph.lock.Lock()
+	defer graph.lock.Unlock()
+
+	graph.idToAssetString = restored.idToAssetString
+	graph.assetStringToID = restored.assetStringToID
+	graph.vacantIDs = restored.vacantIDs
+	graph.venuesForBuyingAsset = restored.venuesForBuyingAsset
+	graph.venuesForSellingAsset = restored.venuesForSellingAsset
+	graph.liquidityPools = restored.liquidityPools
+	graph.tradingPairForOffer = restored.tradingPairForOffer
+	graph.batchedUpdates = graph.batch()
+	graph.lastLedger = info.LastLedger
+	return info, nil
+}
+
+func (graph *OrderBookGraph) hasAssets(assets ...xdr.Asset) bool {
+	for _, asset := range assets {
+		if _, ok := graph.assetStringToID[asset.String()]; !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package orderbook
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/xdr"
+)
+
+func TestSnapshot(t *testing.T) {
+	graph := NewOrderBookGraph()
+	sameEurPriceOffer := eurOffer
+	sameEurPriceOffer.OfferId = 20
+	graph.AddOffers(dollarOffer, quarterOffer, eurOffer, sameEurPriceOffer, twoEurOffer, threeEurOffer)
+	graph.AddLiquidityPools(eurUsdLiquidityPool, usdChfLiquidityPool, eurYenLiquidityPool)
+	require.NoError(t, graph.Apply(10))
+	// Leave a vacant asset id behind.
+	graph.RemoveLiquidityPool(eurYenLiquidityPool)
+	require.NoError(t, graph.Apply(11))
+	require.NotEmpty(t, graph.vacantIDs)
+
+	var buf bytes.Buffer
+	info, err := graph.WriteSnapshot(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(11), info.LastLedger)
+	hash, lastLedger, err := graph.Hash()
+	require.NoError(t, err)
+	assert.Equal(t, hash, info.Hash)
+	assert.Equal(t, uint32(11), lastLedger)
+
+	header, err := ReadSnapshotInfo(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, info, header)
+
+	restored := NewOrderBookGraph()
+	restored.AddOffers(fiftyCentsOffer)
+	restoredInfo, err := restored.RestoreSnapshot(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Equal(t, info, restoredInfo)
+	assert.Equal(t, uint32(11), restored.lastLedger)
+	assert.Equal(t, graph.idToAssetString, restored.idToAssetString)
+	assert.Equal(t, graph.assetStringToID, restored.assetStringToID)
+	assert.Equal(t, graph.vacantIDs, restored.vacantIDs)
+	assert.Equal(t, graph.tradingPairForOffer, restored.tradingPairForOffer)
+	assert.Equal(t, graph.liquidityPools, restored.liquidityPools)
+	assert.Equal(t, graph.Offers(), restored.Offers())
+
+	// The queued offer was discarded.
+	require.NoError(t, restored.Apply(12))
+	_, _, err = restored.Verify()
+	require.NoError(t, err)
+	restoredHash, _, err := restored.Hash()
+	require.NoError(t, err)
+	assert.Equal(t, info.Hash, restoredHash)
+
+	// The restored graph keeps working.
+	restored.AddOffers(fiftyCentsOffer)
+	restored.RemoveOffer(eurOffer.OfferId)
+	require.NoError(t, restored.Apply(13))
+	_, _, err = restored.Verify()
+	require.NoError(t, err)
+
+	// The hash does not depend on the order of updates.
+	other := NewOrderBookGraph()
+	other.AddLiquidityPools(usdChfLiquidityPool, eurUsdLiquidityPool)
+	other.AddOffers(threeEurOffer, twoEurOffer, sameEurPriceOffer, eurOffer, quarterOffer, dollarOffer)
+	require.NoError(t, other.Apply(5))
+	otherHash, _, err := other.Hash()
+	require.NoError(t, err)
+	assert.Equal(t, info.Hash, otherHash)
+
+	empty, err := StateHash(nil, nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, info.Hash, empty)
+}
+
+func TestRestoreInvalidSnapshot(t *testing.T) {
+	graph := NewOrderBookGraph()
+	graph.AddOffers(dollarOffer, eurOffer)
+	graph.AddLiquidityPools(eurUsdLiquidityPool)
+	require.NoError(t, graph.Apply(10))
+
+	var buf bytes.Buffer
+	_, err := graph.WriteSnapshot(&buf)
+	require.NoError(t, err)
+	snapshot := buf.Bytes()
+
+	restored := NewOrderBookGraph()
+	restored.AddOffers(quarterOffer)
+	require.NoError(t, restored.Apply(3))
+
+	_, err = restored.RestoreSnapshot(bytes.NewReader([]byte("nope")))
+	assert.EqualError(t, err, "not an order book graph snapshot")
+
+	tampered := append([]byte(nil), snapshot...)
+	// The last byte belongs to the liquidity pool reserves.
+	tampered[len(tampered)-1]++
+	_, err = restored.RestoreSnapshot(bytes.NewReader(tampered))
+	assert.ErrorContains(t, err, "does not match the hash of its contents")
+
+	_, err = restored.RestoreSnapshot(bytes.NewReader(snapshot[:len(snapshot)-4]))
+	assert.ErrorContains(t, err, "could not read liquidity pools")
+
+	// The graph is unchanged after a failed restore.
+	assert.Equal(t, []xdr.OfferEntry{quarterOffer}, restored.Offers())
+	assert.Equal(t, uint32(3), restored.lastLedger)
+}
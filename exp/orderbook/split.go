@@ -0,0 +1,336 @@
+package orderbook
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/stellar/go/price"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+)
+
+var errInvalidParts = errors.New("number of parts must be positive")
+
+// SplitPayment is a payment of a fixed amount of a source asset which is
+// split across several routes to a destination asset.
+type SplitPayment struct {
+	// Routes are the paths of the payment. The amounts of every route are
+	// those expected when the routes are executed in order, after the
+	// liquidity consumed by the preceding routes is gone.
+	Routes []Path
+	// SourceAmount is the total amount sent over all routes.
+	SourceAmount xdr.Int64
+	// DestinationAmount is the total amount expected to be received over all
+	// routes.
+	DestinationAmount xdr.Int64
+	// LastLedger is the ledger the payment is accurate up to.
+	LastLedger uint32
+}
+
+// PathPaymentStrictSends returns one path payment strict send operation per
+// route, in order, to be submitted in a single transaction. The minimum
+// amount received by each route is its expected amount reduced by
+// `slippageBips` basis points.
+func (p SplitPayment) PathPaymentStrictSends(destination string, slippageBips int) ([]txnbuild.Operation, error) {
+	if slippageBips < 0 || slippageBips >= maxBasisPoints {
+		return nil, errors.Errorf("slippage must be between 0 and %d basis points", maxBasisPoints-1)
+	}
+	ops := make([]txnbuild.Operation, 0, len(p.Routes))
+	for i, route := range p.Routes {
+		destMin, err := price.MulFractionRoundDown(
+			int64(route.DestinationAmount), int64(maxBasisPoints-slippageBips), maxBasisPoints,
+		)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not compute minimum amount for route %d", i)
+		}
+		op, err := route.PathPaymentStrictSend(destination, max(xdr.Int64(destMin), 1))
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not build operation for route %d", i)
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// FindSplitPayment splits a payment sending `amountToSpend` of `sourceAsset`
+// across several paths of at most `maxPathLength` hops to
+// `destinationAsset`, to maximise the amount received.
+//
+// The amount is divided into `parts` equal parts, which are routed one at a
+// time over the best path for the liquidity left by the previous parts, so
+// offers and pool reserves shared by several paths are only consumed once.
+// Parts routed over the same path and filled by the same venues (offers or
+// pool) at every hop are merged into a single route. Since the network fills
+// each hop of a path payment from only one venue, several routes may share a
+// path. The result is never worse than sending the whole amount over the
+// best single path.
+func (graph *OrderBookGraph) FindSplitPayment(
+	ctx context.Context,
+	maxPathLength int,
+	sourceAsset xdr.Asset,
+	amountToSpend xdr.Int64,
+	destinationAsset xdr.Asset,
+	parts int,
+	includePools bool,
+) (SplitPayment, error) {
+	if parts <= 0 {
+		return SplitPayment{}, errInvalidParts
+	}
+	if amountToSpend <= 0 {
+		return SplitPayment{}, errBadAmount
+	}
+
+	graph.lock.RLock()
+	defer graph.lock.RUnlock()
+
+	sourceAssetID, ok := graph.assetStringToID[sourceAsset.String()]
+	if !ok {
+		return SplitPayment{LastLedger: graph.lastLedger}, ErrInsufficientLiquidity
+	}
+	destinationAssetID, ok := graph.assetStringToID[destinationAsset.String()]
+	if !ok {
+		return SplitPayment{LastLedger: graph.lastLedger}, ErrInsufficientLiquidity
+	}
+
+	finder := &splitPaymentFinder{
+		ctx:           ctx,
+		graph:         graph,
+		maxPathLength: maxPathLength,
+		source:        sourceAssetID,
+		destination:   destinationAssetID,
+		includePools:  includePools,
+	}
+	// The whole amount may be too much for any single path but not for
+	// several of them, so insufficient liquidity is only an error if
+	// splitting does not help either.
+	best, err := finder.find(amountToSpend, 1)
+	if err != nil && errors.Cause(err) != ErrInsufficientLiquidity {
+		return SplitPayment{LastLedger: graph.lastLedger}, err
+	}
+	if parts > 1 {
+		split, splitErr := finder.find(amountToSpend, parts)
+		if splitErr != nil && errors.Cause(splitErr) != ErrInsufficientLiquidity {
+			return SplitPayment{LastLedger: graph.lastLedger}, splitErr
+		}
+		if splitErr == nil && (err != nil || split.DestinationAmount > best.DestinationAmount) {
+			best, err = split, nil
+		}
+	}
+	if err != nil {
+		return SplitPayment{LastLedger: graph.lastLedger}, ErrInsufficientLiquidity
+	}
+	best.LastLedger = graph.lastLedger
+	return best, nil
+}
+
+type splitPaymentFinder struct {
+	ctx           context.Context
+	graph         *OrderBookGraph
+	maxPathLength int
+	source        int32
+	destination   int32
+	includePools  bool
+}
+
+func (f *splitPaymentFinder) find(amountToSpend xdr.Int64, parts int) (SplitPayment, error) {
+	book := newLiquidityOverlay(f.graph, f.includePools)
+	var routes [][]int32
+	var amounts []xdr.Int64
+	routeIndex := map[string]int{}
+
+	part := amountToSpend / xdr.Int64(parts)
+	for remaining := amountToSpend; remaining > 0; {
+		amount := min(part, remaining)
+		if amount == 0 || remaining-amount < part {
+			// The last part includes the remainder of the division.
+			amount = remaining
+		}
+
+		state := &splitSearchState{
+			buyingGraphSearchState: buyingGraphSearchState{
+				graph:        f.graph,
+				targetAssets: map[int32]bool{f.destination: true},
+				includePools: f.includePools,
+			},
+			book: book,
+		}
+		if err := search(f.ctx, state, f.maxPathLength, f.source, amount); err != nil {
+			return SplitPayment{}, errors.Wrap(err, "could not determine paths")
+		}
+		if state.bestPath == nil {
+			return SplitPayment{}, ErrInsufficientLiquidity
+		}
+		_, poolHops, err := book.route(state.bestPath, amount)
+		if err != nil {
+			return SplitPayment{}, err
+		}
+
+		key := fmt.Sprint(state.bestPath, poolHops)
+		if i, ok := routeIndex[key]; ok {
+			amounts[i] += amount
+		} else {
+			routeIndex[key] = len(routes)
+			routes = append(routes, state.bestPath)
+			amounts = append(amounts, amount)
+		}
+		remaining -= amount
+	}
+
+	// Simulate the merged routes in the order they will be executed.
+	book = newLiquidityOverlay(f.graph, f.includePools)
+	payment := SplitPayment{SourceAmount: amountToSpend}
+	for i, route := range routes {
+		received, _, err := book.route(route, amounts[i])
+		if err != nil {
+			return SplitPayment{}, err
+		}
+		payment.Routes = append(payment.Routes, Path{
+			SourceAsset:       f.graph.idToAssetString[f.source],
+			SourceAmount:      amounts[i],
+			DestinationAsset:  f.graph.idToAssetString[f.destination],
+			DestinationAmount: received,
+			InteriorNodes:     assetIDsToAssetStrings(f.graph, route[1:len(route)-1]),
+		})
+		payment.DestinationAmount += received
+	}
+	return payment, nil
+}
+
+// splitSearchState is a buyingGraphSearchState over the liquidity left in a
+// liquidityOverlay which only keeps the best path to its target.
+type splitSearchState struct {
+	buyingGraphSearchState
+	book       *liquidityOverlay
+	bestPath   []int32
+	bestAmount xdr.Int64
+}
+
+func (state *splitSearchState) venues(currentAsset int32) edgeSet {
+	return state.book.venues(currentAsset)
+}
+
+func (state *splitSearchState) appendToPaths(
+	path []int32,
+	currentAsset int32,
+	currentAssetAmount xdr.Int64,
+) {
+	if len(path) > 1 && currentAssetAmount > state.bestAmount {
+		state.bestPath = path
+		state.bestAmount = currentAssetAmount
+	}
+}
+
+// liquidityOverlay tracks the offers and pool reserves consumed by trades
+// without modifying the graph. Modified edge sets are copied on write.
+type liquidityOverlay struct {
+	graph        *OrderBookGraph
+	includePools bool
+	modified     map[int32]edgeSet
+}
+
+func newLiquidityOverlay(graph *OrderBookGraph, includePools bool) *liquidityOverlay {
+	return &liquidityOverlay{
+		graph:        graph,
+		includePools: includePools,
+		modified:     map[int32]edgeSet{},
+	}
+}
+
+// venues returns the venues for selling `asset`, like
+// graph.venuesForBuyingAsset.
+func (o *liquidityOverlay) venues(asset int32) edgeSet {
+	if edges, ok := o.modified[asset]; ok {
+		return edges
+	}
+	return o.graph.venuesForBuyingAsset[asset]
+}
+
+func (o *liquidityOverlay) mutableVenues(asset int32) edgeSet {
+	if edges, ok := o.modified[asset]; ok {
+		return edges
+	}
+	edges := append(edgeSet(nil), o.graph.venuesForBuyingAsset[asset]...)
+	o.modified[asset] = edges
+	return edges
+}
+
+// route trades `amount` of the first asset of the path along the path and
+// returns the amount of the last asset received, along with a bit mask of the
+// hops filled by a pool.
+func (o *liquidityOverlay) route(path []int32, amount xdr.Int64) (xdr.Int64, uint32, error) {
+	var poolHops uint32
+	for i := 0; i+1 < len(path); i++ {
+		var fromPool bool
+		var err error
+		if amount, fromPool, err = o.trade(path[i], path[i+1], amount); err != nil {
+			return 0, 0, errors.Wrapf(err, "could not trade %s for %s",
+				o.graph.idToAssetString[path[i]], o.graph.idToAssetString[path[i+1]])
+		}
+		if fromPool {
+			poolHops |= 1 << i
+		}
+	}
+	return amount, poolHops, nil
+}
+
+// trade sells `amount` of `from` for `to`, using whichever of the offers or
+// the pool pays more (see processVenues), and removes the consumed liquidity.
+// It returns the amount of `to` received and whether the pool was used.
+func (o *liquidityOverlay) trade(from, to int32, amount xdr.Int64) (xdr.Int64, bool, error) {
+	edges := o.venues(from)
+	i := edges.find(to)
+	if i < 0 {
+		return 0, false, ErrInsufficientLiquidity
+	}
+	venues := edges[i].value
+
+	poolAmount := xdr.Int64(0)
+	if pool := venues.pool; o.includePools && pool.Body.ConstantProduct != nil {
+		if received, err := makeTrade(pool, from, tradeTypeDeposit, amount); err == nil {
+			poolAmount = received
+		}
+	}
+	offerAmount, filled, partial := xdr.Int64(-1), 0, xdr.Int64(0)
+	if len(venues.offers) > 0 {
+		var err error
+		offerAmount, filled, partial, err = crossOffersForBuyingAsset(venues.offers, amount)
+		if err != nil && poolAmount == 0 {
+			return 0, false, err
+		}
+	}
+
+	if poolAmount > 0 && poolAmount > offerAmount {
+		pool := venues.pool
+		details := *pool.Body.ConstantProduct
+		if pool.assetA == from {
+			details.ReserveA += amount
+			details.ReserveB -= poolAmount
+		} else {
+			details.ReserveB += amount
+			details.ReserveA -= poolAmount
+		}
+		pool.Body.ConstantProduct = &details
+		for _, pair := range [][2]int32{{from, to}, {to, from}} {
+			edges := o.mutableVenues(pair[0])
+			if j := edges.find(pair[1]); j >= 0 {
+				edges[j].value.pool = pool
+			}
+		}
+		return poolAmount, true, nil
+	}
+	if offerAmount <= 0 {
+		return 0, false, ErrInsufficientLiquidity
+	}
+
+	offers := append([]xdr.OfferEntry(nil), venues.offers[filled:]...)
+	if partial > 0 {
+		offers[0].Amount -= partial
+		if offers[0].Amount == 0 {
+			offers = offers[1:]
+		}
+	}
+	edges = o.mutableVenues(from)
+	edges[i].value.offers = offers
+	return offerAmount, false, nil
+}
@@ -0,0 +1,206 @@
+package orderbook
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+)
+
+func makeSplitGraph(t *testing.T) *OrderBookGraph {
+	graph := NewOrderBookGraph()
+	graph.AddOffers(
+		// native -> usd: 100 usd at 1 native and 1000 usd at 2 native
+		xdr.OfferEntry{
+			SellerId: issuer,
+			OfferId:  200,
+			Buying:   nativeAsset,
+			Selling:  usdAsset,
+			Price:    xdr.Price{N: 1, D: 1},
+			Amount:   100,
+		},
+		xdr.OfferEntry{
+			SellerId: issuer,
+			OfferId:  201,
+			Buying:   nativeAsset,
+			Selling:  usdAsset,
+			Price:    xdr.Price{N: 2, D: 1},
+			Amount:   1000,
+		},
+		// native -> eur -> usd: 60 usd at 1 native
+		xdr.OfferEntry{
+			SellerId: issuer,
+			OfferId:  202,
+			Buying:   nativeAsset,
+			Selling:  eurAsset,
+			Price:    xdr.Price{N: 1, D: 1},
+			Amount:   60,
+		},
+		xdr.OfferEntry{
+			SellerId: issuer,
+			OfferId:  203,
+			Buying:   eurAsset,
+			Selling:  usdAsset,
+			Price:    xdr.Price{N: 1, D: 1},
+			Amount:   60,
+		},
+	)
+	require.NoError(t, graph.Apply(9))
+	return graph
+}
+
+func TestFindSplitPayment(t *testing.T) {
+	graph := makeSplitGraph(t)
+	ctx := context.Background()
+	direct := Path{
+		SourceAsset:      nativeAsset.String(),
+		DestinationAsset: usdAsset.String(),
+		InteriorNodes:    []string{},
+	}
+	viaEur := Path{
+		SourceAsset:      nativeAsset.String(),
+		DestinationAsset: usdAsset.String(),
+		InteriorNodes:    []string{eurAsset.String()},
+	}
+	route := func(path Path, sent, received xdr.Int64) Path {
+		path.SourceAmount, path.DestinationAmount = sent, received
+		return path
+	}
+
+	payment, err := graph.FindSplitPayment(ctx, 3, nativeAsset, 200, usdAsset, 1, false)
+	require.NoError(t, err)
+	assert.Equal(t, SplitPayment{
+		Routes:            []Path{route(direct, 200, 150)},
+		SourceAmount:      200,
+		DestinationAmount: 150,
+		LastLedger:        9,
+	}, payment)
+
+	// Once the cheap direct offer is consumed, the route through eur is
+	// better than the expensive direct offer until eur runs out.
+	payment, err = graph.FindSplitPayment(ctx, 3, nativeAsset, 200, usdAsset, 4, false)
+	require.NoError(t, err)
+	assert.Equal(t, SplitPayment{
+		Routes:            []Path{route(direct, 150, 125), route(viaEur, 50, 50)},
+		SourceAmount:      200,
+		DestinationAmount: 175,
+		LastLedger:        9,
+	}, payment)
+
+	// No single path can carry the whole amount.
+	_, err = graph.FindSplitPayment(ctx, 3, nativeAsset, 2150, usdAsset, 1, false)
+	assert.Equal(t, ErrInsufficientLiquidity, err)
+	payment, err = graph.FindSplitPayment(ctx, 3, nativeAsset, 2150, usdAsset, 43, false)
+	require.NoError(t, err)
+	assert.Equal(t, []Path{route(direct, 2100, 1100), route(viaEur, 50, 50)}, payment.Routes)
+	assert.Equal(t, xdr.Int64(1150), payment.DestinationAmount)
+
+	// Paths are limited to maxPathLength hops.
+	payment, err = graph.FindSplitPayment(ctx, 1, nativeAsset, 200, usdAsset, 4, false)
+	require.NoError(t, err)
+	assert.Equal(t, []Path{route(direct, 200, 150)}, payment.Routes)
+
+	_, err = graph.FindSplitPayment(ctx, 3, nativeAsset, 3000, usdAsset, 10, false)
+	assert.Equal(t, ErrInsufficientLiquidity, err)
+	_, err = graph.FindSplitPayment(ctx, 3, nativeAsset, 200, chfAsset, 4, false)
+	assert.Equal(t, ErrInsufficientLiquidity, err)
+	_, err = graph.FindSplitPayment(ctx, 3, nativeAsset, 200, usdAsset, 0, false)
+	assert.Equal(t, errInvalidParts, err)
+	_, err = graph.FindSplitPayment(ctx, 3, nativeAsset, 0, usdAsset, 4, false)
+	assert.Equal(t, errBadAmount, err)
+
+	// The graph itself is not modified.
+	assert.Len(t, graph.Offers(), 4)
+	_, _, err = graph.Verify()
+	require.NoError(t, err)
+}
+
+func TestFindSplitPaymentWithPool(t *testing.T) {
+	pool := makePool(nativeAsset, usdAsset, 1000, 1000)
+	fee := pool.Body.ConstantProduct.Params.Fee
+	graph := NewOrderBookGraph()
+	graph.AddOffers(xdr.OfferEntry{
+		SellerId: issuer,
+		OfferId:  200,
+		Buying:   nativeAsset,
+		Selling:  usdAsset,
+		Price:    xdr.Price{N: 1, D: 1},
+		Amount:   100,
+	})
+	graph.AddLiquidityPools(pool)
+	require.NoError(t, graph.Apply(9))
+	ctx := context.Background()
+
+	single, _, ok := CalculatePoolPayout(1000, 1000, 200, fee, false)
+	require.True(t, ok)
+	payment, err := graph.FindSplitPayment(ctx, 3, nativeAsset, 200, usdAsset, 1, true)
+	require.NoError(t, err)
+	assert.Equal(t, single, payment.DestinationAmount)
+
+	// The same path is used twice: once filled by the offer and once by the
+	// pool.
+	fromPool, _, ok := CalculatePoolPayout(1000, 1000, 100, fee, false)
+	require.True(t, ok)
+	payment, err = graph.FindSplitPayment(ctx, 3, nativeAsset, 200, usdAsset, 4, true)
+	require.NoError(t, err)
+	require.Len(t, payment.Routes, 2)
+	assert.Equal(t, xdr.Int64(100), payment.Routes[0].SourceAmount)
+	assert.Equal(t, xdr.Int64(100), payment.Routes[0].DestinationAmount)
+	assert.Equal(t, xdr.Int64(100), payment.Routes[1].SourceAmount)
+	assert.Equal(t, fromPool, payment.Routes[1].DestinationAmount)
+	assert.Equal(t, 100+fromPool, payment.DestinationAmount)
+	assert.Greater(t, payment.DestinationAmount, single)
+}
+
+func TestSplitPaymentPathPaymentStrictSends(t *testing.T) {
+	graph := makeSplitGraph(t)
+	payment, err := graph.FindSplitPayment(context.Background(), 3, nativeAsset, 200, usdAsset, 4, false)
+	require.NoError(t, err)
+
+	account := keypair.MustRandom().Address()
+	ops, err := payment.PathPaymentStrictSends(account, 100)
+	require.NoError(t, err)
+	require.Len(t, ops, 2)
+	usd := txnbuild.CreditAsset{Code: "usd", Issuer: issuer.Address()}
+	eur := txnbuild.CreditAsset{Code: "eur", Issuer: issuer.Address()}
+	assert.Equal(t, &txnbuild.PathPaymentStrictSend{
+		SendAsset:   txnbuild.NativeAsset{},
+		SendAmount:  "0.0000150",
+		Destination: account,
+		DestAsset:   usd,
+		DestMin:     "0.0000123",
+		Path:        []txnbuild.Asset{},
+	}, ops[0])
+	assert.Equal(t, &txnbuild.PathPaymentStrictSend{
+		SendAsset:   txnbuild.NativeAsset{},
+		SendAmount:  "0.0000050",
+		Destination: account,
+		DestAsset:   usd,
+		DestMin:     "0.0000049",
+		Path:        []txnbuild.Asset{eur},
+	}, ops[1])
+
+	_, err = payment.PathPaymentStrictSends(account, maxBasisPoints)
+	assert.EqualError(t, err, "slippage must be between 0 and 9999 basis points")
+}
+
+func TestSplitPaymentPathPaymentStrictSendsLargeAmount(t *testing.T) {
+	// Multiplying these amounts by the basis points overflows an int64.
+	payment := SplitPayment{Routes: []Path{{
+		SourceAsset:       nativeAsset.String(),
+		SourceAmount:      1_000_000_000_000_000_000,
+		DestinationAsset:  usdAsset.String(),
+		DestinationAmount: 1_000_000_000_000_000_000,
+	}}}
+
+	account := keypair.MustRandom().Address()
+	ops, err := payment.PathPaymentStrictSends(account, 100)
+	require.NoError(t, err)
+	require.Len(t, ops, 1)
+	assert.Equal(t, "99000000000.0000000", ops[0].(*txnbuild.PathPaymentStrictSend).DestMin)
+}
@@ -0,0 +1,202 @@
+package orderbook
+
+import (
+	"context"
+	"io"
+
+	"github.com/stellar/go/historyarchive"
+	"github.com/stellar/go/ingest"
+	"github.com/stellar/go/ingest/ledgerbackend"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/support/log"
+	"github.com/stellar/go/xdr"
+)
+
+// GraphUpdaterConfig configures a GraphUpdater.
+type GraphUpdaterConfig struct {
+	// Graph is the order book graph which is kept up to date.
+	Graph OBGraph
+	// HistoryArchive is used to bootstrap the graph from a checkpoint.
+	HistoryArchive historyarchive.ArchiveInterface
+	// LedgerBackend provides the ledgers applied after the checkpoint.
+	LedgerBackend ledgerbackend.LedgerBackend
+	// NetworkPassphrase is the passphrase of the network the ledgers belong
+	// to.
+	NetworkPassphrase string
+	// VerifyFrequency is the number of ledgers applied between checks of the
+	// graph's internal consistency (see OrderBookGraph.Verify). Zero disables
+	// the checks.
+	VerifyFrequency uint32
+	// CheckpointReaderOptions are additional options for the
+	// CheckpointChangeReader used to bootstrap the graph.
+	CheckpointReaderOptions []ingest.CheckpointReaderOption
+	// Logger is used to log progress. Defaults to log.DefaultLogger.
+	Logger *log.Entry
+}
+
+// GraphUpdater populates an order book graph with the offers and liquidity
+// pools of a history archive checkpoint and then keeps it up to date by
+// applying the changes of every following ledger.
+type GraphUpdater struct {
+	config             GraphUpdaterConfig
+	lastLedger         uint32
+	appliedSinceVerify uint32
+}
+
+// NewGraphUpdater constructs a GraphUpdater.
+func NewGraphUpdater(config GraphUpdaterConfig) (*GraphUpdater, error) {
+	if config.Graph == nil {
+		return nil, errors.New("graph is not configured")
+	}
+	if config.HistoryArchive == nil {
+		return nil, errors.New("history archive is not configured")
+	}
+	if config.Logger == nil {
+		config.Logger = log.DefaultLogger
+	}
+	return &GraphUpdater{config: config}, nil
+}
+
+// LastLedger returns the sequence of the last ledger applied to the graph, or
+// 0 if the graph has not been bootstrapped yet.
+func (u *GraphUpdater) LastLedger() uint32 {
+	return u.lastLedger
+}
+
+// Resume marks the graph as accurate up to the given ledger, e.g. after it
+// was restored with OrderBookGraph.RestoreSnapshot, so that the next
+// ApplyLedger applies the following ledger instead of requiring a Bootstrap.
+func (u *GraphUpdater) Resume(ledger uint32) {
+	u.lastLedger = ledger
+	u.appliedSinceVerify = 0
+}
+
+// Run bootstraps the graph from the given checkpoint ledger (or the latest
+// checkpoint in the history archive, if it is 0) and then applies ledgers
+// from the ledger backend until the context is cancelled or an error occurs.
+//
+// If the graph has already been populated (see Resume), the checkpoint is
+// ignored and Run continues with the ledger after the last applied one.
+func (u *GraphUpdater) Run(ctx context.Context, checkpoint uint32) error {
+	if u.config.LedgerBackend == nil {
+		return errors.New("ledger backend is not configured")
+	}
+	if u.lastLedger == 0 {
+		if checkpoint == 0 {
+			var err error
+			if checkpoint, err = u.config.HistoryArchive.GetLatestLedgerSequence(); err != nil {
+				return errors.Wrap(err, "could not get latest checkpoint")
+			}
+		}
+		if err := u.Bootstrap(ctx, checkpoint); err != nil {
+			return err
+		}
+	}
+	if err := u.config.LedgerBackend.PrepareRange(ctx, ledgerbackend.UnboundedRange(u.lastLedger+1)); err != nil {
+		return errors.Wrap(err, "could not prepare ledger range")
+	}
+	for ctx.Err() == nil {
+		if err := u.ApplyLedger(ctx); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// Bootstrap clears the graph and populates it with the offers and liquidity
+// pools at the given checkpoint ledger.
+func (u *GraphUpdater) Bootstrap(ctx context.Context, checkpoint uint32) error {
+	u.config.Logger.WithField("ledger", checkpoint).Info("Bootstrapping order book graph from checkpoint")
+	opts := append(
+		[]ingest.CheckpointReaderOption{ingest.WithFilter(isOrderBookEntry, isOrderBookKey)},
+		u.config.CheckpointReaderOptions...,
+	)
+	reader, err := ingest.NewCheckpointChangeReader(ctx, u.config.HistoryArchive, checkpoint, opts...)
+	if err != nil {
+		return errors.Wrap(err, "could not create checkpoint change reader")
+	}
+	defer reader.Close()
+
+	u.config.Graph.Clear()
+	u.lastLedger = 0
+	if err = u.apply(reader, checkpoint); err != nil {
+		return errors.Wrapf(err, "could not bootstrap from checkpoint %d", checkpoint)
+	}
+	u.config.Logger.WithField("ledger", checkpoint).Info("Bootstrapped order book graph")
+	return nil
+}
+
+// ApplyLedger applies the offer and liquidity pool changes of the ledger
+// following the last applied ledger.
+func (u *GraphUpdater) ApplyLedger(ctx context.Context) error {
+	if u.lastLedger == 0 {
+		return errors.New("graph has not been bootstrapped")
+	}
+	if u.config.LedgerBackend == nil {
+		return errors.New("ledger backend is not configured")
+	}
+	sequence := u.lastLedger + 1
+	reader, err := ingest.NewLedgerChangeReader(ctx, u.config.LedgerBackend, u.config.NetworkPassphrase, sequence)
+	if err != nil {
+		return errors.Wrapf(err, "could not create change reader for ledger %d", sequence)
+	}
+	defer reader.Close()
+
+	if err = u.apply(reader, sequence); err != nil {
+		return errors.Wrapf(err, "could not apply ledger %d", sequence)
+	}
+
+	u.appliedSinceVerify++
+	if u.config.VerifyFrequency > 0 && u.appliedSinceVerify >= u.config.VerifyFrequency {
+		u.appliedSinceVerify = 0
+		if _, _, err = u.config.Graph.Verify(); err != nil {
+			return errors.Wrapf(err, "order book graph is inconsistent at ledger %d", sequence)
+		}
+	}
+	return nil
+}
+
+func (u *GraphUpdater) apply(reader ingest.ChangeReader, sequence uint32) error {
+	graph := u.config.Graph
+	for {
+		change, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			graph.Discard()
+			return errors.Wrap(err, "could not read change")
+		}
+
+		switch change.Type {
+		case xdr.LedgerEntryTypeOffer:
+			if change.Post != nil {
+				graph.AddOffers(change.Post.Data.MustOffer())
+			} else {
+				graph.RemoveOffer(change.Pre.Data.MustOffer().OfferId)
+			}
+		case xdr.LedgerEntryTypeLiquidityPool:
+			if change.Post != nil {
+				graph.AddLiquidityPools(change.Post.Data.MustLiquidityPool())
+			} else {
+				graph.RemoveLiquidityPool(change.Pre.Data.MustLiquidityPool())
+			}
+		}
+	}
+
+	if err := graph.Apply(sequence); err != nil {
+		graph.Discard()
+		return errors.Wrap(err, "could not apply updates")
+	}
+	u.lastLedger = sequence
+	return nil
+}
+
+func isOrderBookEntry(entry xdr.LedgerEntry) bool {
+	return entry.Data.Type == xdr.LedgerEntryTypeOffer ||
+		entry.Data.Type == xdr.LedgerEntryTypeLiquidityPool
+}
+
+func isOrderBookKey(key xdr.LedgerKey) bool {
+	return key.Type == xdr.LedgerEntryTypeOffer ||
+		key.Type == xdr.LedgerEntryTypeLiquidityPool
+}
@@ -0,0 +1,228 @@
+package orderbook
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/historyarchive"
+	"github.com/stellar/go/ingest"
+	"github.com/stellar/go/ingest/ledgerbackend"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/xdr"
+)
+
+func offerLedgerEntry(offer xdr.OfferEntry) xdr.LedgerEntry {
+	return xdr.LedgerEntry{
+		Data: xdr.LedgerEntryData{Type: xdr.LedgerEntryTypeOffer, Offer: &offer},
+	}
+}
+
+func poolLedgerEntry(pool xdr.LiquidityPoolEntry) xdr.LedgerEntry {
+	return xdr.LedgerEntry{
+		Data: xdr.LedgerEntryData{Type: xdr.LedgerEntryTypeLiquidityPool, LiquidityPool: &pool},
+	}
+}
+
+// writeTestCheckpoint writes a checkpoint whose bucket list consists of a
+// single bucket with the given entries into a file:// archive.
+func writeTestCheckpoint(t *testing.T, checkpoint uint32, entries ...xdr.LedgerEntry) historyarchive.ArchiveInterface {
+	root := t.TempDir()
+	archive, err := historyarchive.Connect("file://"+root, historyarchive.ArchiveOptions{})
+	require.NoError(t, err)
+
+	var raw bytes.Buffer
+	require.NoError(t, xdr.MarshalFramed(&raw, xdr.BucketEntry{
+		Type:      xdr.BucketEntryTypeMetaentry,
+		MetaEntry: &xdr.BucketMetadata{LedgerVersion: 22},
+	}))
+	for i := range entries {
+		require.NoError(t, xdr.MarshalFramed(&raw, xdr.BucketEntry{
+			Type:      xdr.BucketEntryTypeLiveentry,
+			LiveEntry: &entries[i],
+		}))
+	}
+	hash := historyarchive.Hash(sha256.Sum256(raw.Bytes()))
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	_, err = writer.Write(raw.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	pth := filepath.Join(root, historyarchive.BucketPath(hash))
+	require.NoError(t, os.MkdirAll(filepath.Dir(pth), 0755))
+	require.NoError(t, os.WriteFile(pth, compressed.Bytes(), 0644))
+
+	has := historyarchive.HistoryArchiveState{Version: 1, CurrentLedger: checkpoint}
+	for i := range has.CurrentBuckets {
+		has.CurrentBuckets[i].Curr = historyarchive.Hash{}.String()
+		has.CurrentBuckets[i].Snap = historyarchive.Hash{}.String()
+	}
+	has.CurrentBuckets[0].Curr = hash.String()
+	opts := &historyarchive.CommandOptions{Force: true}
+	require.NoError(t, archive.PutCheckpointHAS(checkpoint, has, opts))
+	require.NoError(t, archive.PutRootHAS(has, opts))
+	return archive
+}
+
+func sortedOffers(offers []xdr.OfferEntry) []xdr.OfferEntry {
+	sort.Slice(offers, func(i, j int) bool {
+		return offers[i].OfferId < offers[j].OfferId
+	})
+	return offers
+}
+
+func TestGraphUpdater(t *testing.T) {
+	ctx := context.Background()
+	archive := writeTestCheckpoint(t, 63,
+		offerLedgerEntry(eurOffer),
+		offerLedgerEntry(dollarOffer),
+		poolLedgerEntry(eurUsdLiquidityPool),
+		xdr.LedgerEntry{Data: xdr.LedgerEntryData{
+			Type:    xdr.LedgerEntryTypeAccount,
+			Account: &xdr.AccountEntry{AccountId: issuer},
+		}},
+	)
+	backend := &ledgerbackend.MockDatabaseBackend{}
+	graph := NewOrderBookGraph()
+	updater, err := NewGraphUpdater(GraphUpdaterConfig{
+		Graph:                   graph,
+		HistoryArchive:          archive,
+		LedgerBackend:           backend,
+		NetworkPassphrase:       network.TestNetworkPassphrase,
+		VerifyFrequency:         1,
+		CheckpointReaderOptions: []ingest.CheckpointReaderOption{ingest.DisableBucketListValidation},
+	})
+	require.NoError(t, err)
+
+	assert.EqualError(t, updater.ApplyLedger(ctx), "graph has not been bootstrapped")
+
+	require.NoError(t, updater.Bootstrap(ctx, 63))
+	assert.Equal(t, uint32(63), updater.LastLedger())
+	assert.Equal(t, []xdr.OfferEntry{dollarOffer, eurOffer}, sortedOffers(graph.Offers()))
+	assert.Equal(t, []xdr.LiquidityPoolEntry{eurUsdLiquidityPool}, graph.LiquidityPools())
+
+	updatedDollarOffer := dollarOffer
+	updatedDollarOffer.Amount = 100
+	eurOfferEntry, poolEntry := offerLedgerEntry(eurOffer), poolLedgerEntry(eurUsdLiquidityPool)
+	eurOfferKey, err := eurOfferEntry.LedgerKey()
+	require.NoError(t, err)
+	poolKey, err := poolEntry.LedgerKey()
+	require.NoError(t, err)
+	state := func(entry xdr.LedgerEntry) xdr.LedgerEntryChange {
+		return xdr.LedgerEntryChange{Type: xdr.LedgerEntryChangeTypeLedgerEntryState, State: &entry}
+	}
+	created := offerLedgerEntry(twoEurOffer)
+	updated := offerLedgerEntry(updatedDollarOffer)
+	backend.On("GetLedger", ctx, uint32(64)).Return(xdr.LedgerCloseMeta{
+		V0: &xdr.LedgerCloseMetaV0{
+			LedgerHeader: xdr.LedgerHeaderHistoryEntry{Header: xdr.LedgerHeader{LedgerSeq: 64}},
+			UpgradesProcessing: []xdr.UpgradeEntryMeta{{
+				Changes: xdr.LedgerEntryChanges{
+					state(offerLedgerEntry(eurOffer)),
+					{Type: xdr.LedgerEntryChangeTypeLedgerEntryRemoved, Removed: &eurOfferKey},
+					state(offerLedgerEntry(dollarOffer)),
+					{Type: xdr.LedgerEntryChangeTypeLedgerEntryUpdated, Updated: &updated},
+					{Type: xdr.LedgerEntryChangeTypeLedgerEntryCreated, Created: &created},
+					state(poolLedgerEntry(eurUsdLiquidityPool)),
+					{Type: xdr.LedgerEntryChangeTypeLedgerEntryRemoved, Removed: &poolKey},
+				},
+			}},
+		},
+	}, nil).Once()
+
+	require.NoError(t, updater.ApplyLedger(ctx))
+	assert.Equal(t, uint32(64), updater.LastLedger())
+	assert.Equal(t, []xdr.OfferEntry{updatedDollarOffer, twoEurOffer}, sortedOffers(graph.Offers()))
+	assert.Empty(t, graph.LiquidityPools())
+
+	backend.On("GetLedger", ctx, uint32(65)).
+		Return(xdr.LedgerCloseMeta{}, fmt.Errorf("backend error")).Once()
+	assert.ErrorContains(t, updater.ApplyLedger(ctx), "could not create change reader for ledger 65")
+	assert.Equal(t, uint32(64), updater.LastLedger())
+	backend.AssertExpectations(t)
+}
+
+func TestGraphUpdaterRun(t *testing.T) {
+	ctx := context.Background()
+	archive := writeTestCheckpoint(t, 127, offerLedgerEntry(eurOffer))
+	backend := &ledgerbackend.MockDatabaseBackend{}
+	graph := NewOrderBookGraph()
+	updater, err := NewGraphUpdater(GraphUpdaterConfig{
+		Graph:                   graph,
+		HistoryArchive:          archive,
+		LedgerBackend:           backend,
+		NetworkPassphrase:       network.TestNetworkPassphrase,
+		CheckpointReaderOptions: []ingest.CheckpointReaderOption{ingest.DisableBucketListValidation},
+	})
+	require.NoError(t, err)
+
+	backend.On("PrepareRange", ctx, ledgerbackend.UnboundedRange(128)).Return(nil).Once()
+	backend.On("GetLedger", ctx, uint32(128)).Return(xdr.LedgerCloseMeta{
+		V0: &xdr.LedgerCloseMetaV0{
+			LedgerHeader: xdr.LedgerHeaderHistoryEntry{Header: xdr.LedgerHeader{LedgerSeq: 128}},
+		},
+	}, nil).Once()
+	backend.On("GetLedger", ctx, uint32(129)).
+		Return(xdr.LedgerCloseMeta{}, fmt.Errorf("backend error")).Once()
+
+	err = updater.Run(ctx, 0)
+	assert.ErrorContains(t, err, "could not create change reader for ledger 129")
+	assert.Equal(t, uint32(128), updater.LastLedger())
+	assert.Equal(t, []xdr.OfferEntry{eurOffer}, graph.Offers())
+	backend.AssertExpectations(t)
+
+	_, err = NewGraphUpdater(GraphUpdaterConfig{HistoryArchive: archive})
+	assert.EqualError(t, err, "graph is not configured")
+	_, err = NewGraphUpdater(GraphUpdaterConfig{Graph: graph})
+	assert.EqualError(t, err, "history archive is not configured")
+}
+
+func TestGraphUpdaterResumeFromSnapshot(t *testing.T) {
+	ctx := context.Background()
+	graph := NewOrderBookGraph()
+	graph.AddOffers(eurOffer)
+	require.NoError(t, graph.Apply(100))
+	var snapshot bytes.Buffer
+	_, err := graph.WriteSnapshot(&snapshot)
+	require.NoError(t, err)
+
+	restored := NewOrderBookGraph()
+	info, err := restored.RestoreSnapshot(&snapshot)
+	require.NoError(t, err)
+
+	backend := &ledgerbackend.MockDatabaseBackend{}
+	updater, err := NewGraphUpdater(GraphUpdaterConfig{
+		Graph:             restored,
+		HistoryArchive:    writeTestCheckpoint(t, 63),
+		LedgerBackend:     backend,
+		NetworkPassphrase: network.TestNetworkPassphrase,
+	})
+	require.NoError(t, err)
+	updater.Resume(info.LastLedger)
+
+	// Run does not bootstrap from the (empty) checkpoint and continues after
+	// the snapshot's ledger.
+	backend.On("PrepareRange", ctx, ledgerbackend.UnboundedRange(101)).Return(nil).Once()
+	backend.On("GetLedger", ctx, uint32(101)).Return(xdr.LedgerCloseMeta{
+		V0: &xdr.LedgerCloseMetaV0{
+			LedgerHeader: xdr.LedgerHeaderHistoryEntry{Header: xdr.LedgerHeader{LedgerSeq: 101}},
+		},
+	}, nil).Once()
+	backend.On("GetLedger", ctx, uint32(102)).
+		Return(xdr.LedgerCloseMeta{}, fmt.Errorf("backend error")).Once()
+
+	err = updater.Run(ctx, 63)
+	assert.ErrorContains(t, err, "could not create change reader for ledger 102")
+	assert.Equal(t, uint32(101), updater.LastLedger())
+	assert.Equal(t, []xdr.OfferEntry{eurOffer}, restored.Offers())
+	backend.AssertExpectations(t)
+}
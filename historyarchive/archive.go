@@ -103,10 +103,18 @@ type Archive struct {
 	actualTxResultSetHashes map[uint32]Hash
 
 	invalidBuckets int
+	badBuckets     map[Hash]bool
+	// bucketReferrers maps each referenced bucket to the first checkpoint
+	// seen referencing it, so a missing bucket can be blamed on a checkpoint.
+	bucketReferrers map[Hash]uint32
+	// knownBuckets holds the buckets recorded as present by a previous scan
+	// (see UseScanState), mapped to whether their contents were verified.
+	knownBuckets map[Hash]bool
 
 	invalidLedgers      int
 	invalidTxSets       int
 	invalidTxResultSets int
+	invalidCheckpoints  map[uint32]bool
 
 	checkpointManager CheckpointManager
 
@@ -236,6 +244,11 @@ func (a *Archive) GetRootHAS() (HistoryArchiveState, error) {
 }
 
 func (a *Archive) GetLedgers(start, end uint32) (map[uint32]*Ledger, error) {
+	return a.getLedgers(context.Background(), start, end)
+}
+
+// getLedgers is like GetLedgers but stops reading once ctx is done.
+func (a *Archive) getLedgers(ctx context.Context, start, end uint32) (map[uint32]*Ledger, error) {
 	if start > end {
 		return nil, errors.Errorf("range is invalid, start: %d end: %d", start, end)
 	}
@@ -244,13 +257,16 @@ func (a *Archive) GetLedgers(start, end uint32) (map[uint32]*Ledger, error) {
 	cache := map[uint32]*Ledger{}
 	for cur := startCheckpoint; cur <= endCheckpoint; cur += a.GetCheckpointManager().GetCheckpointFrequency() {
 		for _, category := range []string{"ledger", "transactions", "results"} {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
 			if exists, err := a.CategoryCheckpointExists(category, cur); err != nil {
 				return nil, errors.Wrap(err, "could not check if category checkpoint exists")
 			} else if !exists {
 				return nil, errors.Errorf("checkpoint %d is not published", cur)
 			}
 
-			if err := a.fetchCategory(cache, category, cur); err != nil {
+			if err := a.fetchCategory(ctx, cache, category, cur); err != nil {
 				return nil, errors.Wrap(err, "could not fetch category checkpoint")
 			}
 		}
@@ -259,7 +275,7 @@ func (a *Archive) GetLedgers(start, end uint32) (map[uint32]*Ledger, error) {
 	return cache, nil
 }
 
-func (a *Archive) fetchCategory(cache map[uint32]*Ledger, category string, checkpointSequence uint32) error {
+func (a *Archive) fetchCategory(ctx context.Context, cache map[uint32]*Ledger, category string, checkpointSequence uint32) error {
 	checkpointPath := CategoryCheckpointPath(category, checkpointSequence)
 	xdrStream, err := a.GetXdrStream(checkpointPath)
 	if err != nil {
@@ -268,6 +284,9 @@ func (a *Archive) fetchCategory(cache map[uint32]*Ledger, category string, check
 	defer xdrStream.Close()
 
 	for {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
 		switch category {
 		case "ledger":
 			var object xdr.LedgerHeaderHistoryEntry
@@ -492,22 +511,7 @@ func (a *Archive) cachedExists(pth string) (bool, error) {
 }
 
 func Connect(u string, opts ArchiveOptions) (*Archive, error) {
-	arch := Archive{
-		networkPassphrase:       opts.NetworkPassphrase,
-		checkpointFiles:         make(map[string](map[uint32]bool)),
-		allBuckets:              make(map[Hash]bool),
-		referencedBuckets:       make(map[Hash]bool),
-		expectLedgerHashes:      make(map[uint32]Hash),
-		actualLedgerHashes:      make(map[uint32]Hash),
-		expectTxSetHashes:       make(map[uint32]Hash),
-		actualTxSetHashes:       make(map[uint32]Hash),
-		expectTxResultSetHashes: make(map[uint32]Hash),
-		actualTxResultSetHashes: make(map[uint32]Hash),
-		checkpointManager:       NewCheckpointManager(opts.CheckpointFrequency),
-	}
-	for _, cat := range Categories() {
-		arch.checkpointFiles[cat] = make(map[uint32]bool)
-	}
+	arch := newArchive(opts)
 
 	if opts.ConnectOptions.Context == nil {
 		opts.ConnectOptions.Context = context.Background()
@@ -516,7 +520,7 @@ func Connect(u string, opts ArchiveOptions) (*Archive, error) {
 	var err error
 	arch.backend, err = ConnectBackend(u, opts.ConnectOptions)
 	if err != nil {
-		return &arch, err
+		return arch, err
 	}
 
 	if opts.CachePath != "" {
@@ -530,14 +534,14 @@ func Connect(u string, opts ArchiveOptions) (*Archive, error) {
 		fs, err := fscache.NewFs(opts.CachePath, 0755 /* drwxr-xr-x */)
 
 		if err != nil {
-			return &arch, errors.Wrapf(err,
+			return arch, errors.Wrapf(err,
 				"creating cache at '%s' with mode 0755 failed",
 				opts.CachePath)
 		}
 
 		cache, err := fscache.NewCacheWithHaunter(fs, haunter)
 		if err != nil {
-			return &arch, errors.Wrapf(err,
+			return arch, errors.Wrapf(err,
 				"creating cache at '%s' failed",
 				opts.CachePath)
 		}
@@ -546,7 +550,33 @@ func Connect(u string, opts ArchiveOptions) (*Archive, error) {
 	}
 
 	arch.stats = archiveStats{backendName: u}
-	return &arch, nil
+	return arch, nil
+}
+
+// newArchive returns an Archive with all of its bookkeeping maps initialized
+// but without a backend.
+func newArchive(opts ArchiveOptions) *Archive {
+	arch := &Archive{
+		networkPassphrase:       opts.NetworkPassphrase,
+		checkpointFiles:         make(map[string](map[uint32]bool)),
+		allBuckets:              make(map[Hash]bool),
+		referencedBuckets:       make(map[Hash]bool),
+		badBuckets:              make(map[Hash]bool),
+		bucketReferrers:         make(map[Hash]uint32),
+		knownBuckets:            make(map[Hash]bool),
+		expectLedgerHashes:      make(map[uint32]Hash),
+		actualLedgerHashes:      make(map[uint32]Hash),
+		expectTxSetHashes:       make(map[uint32]Hash),
+		actualTxSetHashes:       make(map[uint32]Hash),
+		expectTxResultSetHashes: make(map[uint32]Hash),
+		actualTxResultSetHashes: make(map[uint32]Hash),
+		invalidCheckpoints:      make(map[uint32]bool),
+		checkpointManager:       NewCheckpointManager(opts.CheckpointFrequency),
+	}
+	for _, cat := range Categories() {
+		arch.checkpointFiles[cat] = make(map[uint32]bool)
+	}
+	return arch
 }
 
 func ConnectBackend(u string, opts storage.ConnectOptions) (storage.Storage, error) {
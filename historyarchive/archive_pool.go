@@ -7,6 +7,7 @@ package historyarchive
 import (
 	"context"
 	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
@@ -20,10 +21,13 @@ import (
 // An ArchivePool is just a collection of `ArchiveInterface`s so that we can
 // distribute requests fairly throughout the pool.
 type ArchivePool struct {
-	logger  *log.Entry
-	backoff backoff.BackOff
-	pool    []ArchiveInterface
-	curr    int
+	logger *log.Entry
+	// newBackoff returns the backoff strategy of a call.
+	newBackoff func() backoff.BackOff
+	pool       []ArchiveInterface
+
+	mutex sync.Mutex
+	curr  int
 }
 
 // NewArchivePool tries connecting to each of the provided history archive URLs,
@@ -33,22 +37,40 @@ type ArchivePool struct {
 // failed archive. Note that the errors for each individual archive are hard to
 // track if there's success overall.
 func NewArchivePool(archiveURLs []string, opts ArchiveOptions) (ArchiveInterface, error) {
-	return NewArchivePoolWithBackoff(
+	return NewArchivePoolWithBackoffFactory(
 		archiveURLs,
 		opts,
-		backoff.WithMaxRetries(backoff.NewConstantBackOff(250*time.Millisecond), 3),
+		func() backoff.BackOff {
+			return backoff.WithMaxRetries(backoff.NewConstantBackOff(250*time.Millisecond), 3)
+		},
 	)
 }
 
+// NewArchivePoolWithBackoff is like NewArchivePool but retries failed calls
+// with the given strategy.
+//
+// Deprecated: concurrent calls share the strategy and therefore its retry
+// state, so a call may retry more or fewer times than the strategy allows
+// while other calls are in flight. Use NewArchivePoolWithBackoffFactory.
 func NewArchivePoolWithBackoff(archiveURLs []string, opts ArchiveOptions, strategy backoff.BackOff) (ArchiveInterface, error) {
+	shared := &lockedBackOff{BackOff: strategy}
+	return NewArchivePoolWithBackoffFactory(archiveURLs, opts, func() backoff.BackOff {
+		return shared
+	})
+}
+
+// NewArchivePoolWithBackoffFactory is like NewArchivePool but retries each
+// call with a new strategy returned by newStrategy, so concurrent calls do
+// not share their retry state.
+func NewArchivePoolWithBackoffFactory(archiveURLs []string, opts ArchiveOptions, newStrategy func() backoff.BackOff) (ArchiveInterface, error) {
 	if len(archiveURLs) <= 0 {
 		return nil, errors.New("No history archives provided")
 	}
 
 	ap := ArchivePool{
-		pool:    make([]ArchiveInterface, 0, len(archiveURLs)),
-		backoff: strategy,
-		logger:  opts.Logger,
+		pool:       make([]ArchiveInterface, 0, len(archiveURLs)),
+		newBackoff: newStrategy,
+		logger:     opts.Logger,
 	}
 	var lastErr error
 
@@ -71,6 +93,26 @@ func NewArchivePoolWithBackoff(archiveURLs []string, opts ArchiveOptions, strate
 	return &ap, nil
 }
 
+// lockedBackOff allows a single backoff strategy to be used by concurrent
+// calls of a pool created with NewArchivePoolWithBackoff. Note that the calls
+// share the strategy's state (e.g. its number of retries).
+type lockedBackOff struct {
+	mutex sync.Mutex
+	backoff.BackOff
+}
+
+func (b *lockedBackOff) NextBackOff() time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.BackOff.NextBackOff()
+}
+
+func (b *lockedBackOff) Reset() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.BackOff.Reset()
+}
+
 func (pa *ArchivePool) GetStats() []ArchiveStats {
 	stats := []ArchiveStats{}
 	for _, archive := range pa.pool {
@@ -89,6 +131,8 @@ var _ ArchiveInterface = &ArchivePool{}
 // getNextArchive statefully round-robins through the pool
 func (pa *ArchivePool) getNextArchive() ArchiveInterface {
 	// Round-robin through the archives
+	pa.mutex.Lock()
+	defer pa.mutex.Unlock()
 	pa.curr = (pa.curr + 1) % len(pa.pool)
 	return pa.pool[pa.curr]
 }
@@ -117,7 +161,7 @@ func (pa *ArchivePool) runRoundRobin(runner func(ai ArchiveInterface) error) err
 		}
 
 		return err
-	}, pa.backoff)
+	}, pa.newBackoff())
 }
 
 //
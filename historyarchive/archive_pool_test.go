@@ -9,9 +9,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	backoff "github.com/cenkalti/backoff/v4"
+
 	"github.com/stellar/go/support/storage"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -108,3 +111,48 @@ func TestArchivePoolCycles(t *testing.T) {
 		740*time.Millisecond, // some leeway
 		"")
 }
+
+func TestArchivePoolConcurrentUse(t *testing.T) {
+	var mutex sync.Mutex
+	requests := map[string]int{}
+
+	// Every archive fails, so every call uses up all of its retries.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/")
+		mutex.Lock()
+		requests[parts[len(parts)-1]]++
+		mutex.Unlock()
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	pool, err := NewArchivePoolWithBackoffFactory([]string{
+		fmt.Sprintf("%s/%s/%s", server.URL, "fake-archive", "1"),
+		fmt.Sprintf("%s/%s/%s", server.URL, "fake-archive", "2"),
+		fmt.Sprintf("%s/%s/%s", server.URL, "fake-archive", "3"),
+	}, ArchiveOptions{}, func() backoff.BackOff {
+		return backoff.WithMaxRetries(&backoff.ZeroBackOff{}, 3)
+	})
+	require.NoError(t, err)
+
+	const workers, calls = 10, 20
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < calls; j++ {
+				_, err := pool.GetPathHAS(fmt.Sprintf("call-%d-%d", i, j))
+				assert.Error(t, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Each call makes one attempt and retries thrice, whatever the other
+	// calls do.
+	require.Len(t, requests, workers*calls)
+	for path, count := range requests {
+		assert.Equal(t, 4, count, path)
+	}
+}
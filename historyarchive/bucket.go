@@ -0,0 +1,175 @@
+package historyarchive
+
+import (
+	"io"
+	"iter"
+
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// BucketRecordType identifies the kind of record decoded from a bucket file.
+type BucketRecordType string
+
+const (
+	// BucketRecordMeta is the METAENTRY found at the start of buckets written
+	// by protocol 11 or later.
+	BucketRecordMeta BucketRecordType = "meta"
+	// BucketRecordLive is a LIVEENTRY in a live bucket.
+	BucketRecordLive BucketRecordType = "live"
+	// BucketRecordInit is an INITENTRY in a live bucket (see CAP-20).
+	BucketRecordInit BucketRecordType = "init"
+	// BucketRecordDead is a DEADENTRY (tombstone) in a live bucket.
+	BucketRecordDead BucketRecordType = "dead"
+	// BucketRecordArchived is an HOT_ARCHIVE_ARCHIVED entry in a hot archive
+	// bucket.
+	BucketRecordArchived BucketRecordType = "archived"
+	// BucketRecordRestored is an HOT_ARCHIVE_LIVE entry in a hot archive
+	// bucket, marking an entry which has been restored to the live bucket
+	// list.
+	BucketRecordRestored BucketRecordType = "restored"
+)
+
+// BucketRecord is a decoded entry of a live or hot archive bucket.
+type BucketRecord struct {
+	// Index is the position of the record within the bucket.
+	Index int              `json:"index"`
+	Type  BucketRecordType `json:"type"`
+	// Key is set for every record type except BucketRecordMeta.
+	Key *xdr.LedgerKey `json:"key,omitempty"`
+	// KeyXdr is the base64 XDR encoding of Key.
+	KeyXdr string `json:"key_xdr,omitempty"`
+	// Entry is set for BucketRecordLive, BucketRecordInit and
+	// BucketRecordArchived records.
+	Entry *xdr.LedgerEntry `json:"entry,omitempty"`
+	// Meta is set for BucketRecordMeta records.
+	Meta *xdr.BucketMetadata `json:"meta,omitempty"`
+}
+
+// ReadBucket returns an iterator over the records of the bucket with the
+// given hash. The bucket list type is detected from the METAENTRY of the
+// bucket: buckets without a METAENTRY (protocol < 11) or without a bucket list
+// type are decoded as live buckets.
+//
+// The contents of the bucket are verified against the hash once the whole
+// bucket has been read.
+func ReadBucket(archive ArchiveInterface, hash Hash) iter.Seq2[BucketRecord, error] {
+	return func(yield func(BucketRecord, error) bool) {
+		rdr, err := archive.GetXdrStreamForHash(hash)
+		if err != nil {
+			yield(BucketRecord{}, errors.Wrapf(err, "cannot get xdr stream for hash '%s'", hash))
+			return
+		}
+		rdr.SetExpectedHash(hash)
+		closed := false
+		defer func() {
+			if !closed {
+				rdr.Close()
+			}
+		}()
+
+		hotArchive := false
+		for n := 0; ; n++ {
+			var record BucketRecord
+			if hotArchive {
+				var entry xdr.HotArchiveBucketEntry
+				err = rdr.ReadOne(&entry)
+				if err == nil {
+					record, err = hotArchiveBucketRecord(entry)
+				}
+			} else {
+				var entry xdr.BucketEntry
+				err = rdr.ReadOne(&entry)
+				if err == nil {
+					record, err = liveBucketRecord(entry)
+				}
+			}
+			if err == io.EOF {
+				break
+			} else if err != nil {
+				yield(BucketRecord{}, errors.Wrapf(err, "error on XDR record %d of hash '%s'", n, hash))
+				return
+			}
+
+			if record.Type == BucketRecordMeta {
+				if n != 0 {
+					yield(BucketRecord{}, errors.Errorf(
+						"METAENTRY not the first entry (n=%d) in the bucket hash '%s'", n, hash,
+					))
+					return
+				}
+				bucketListType, ok := record.Meta.Ext.GetBucketListType()
+				hotArchive = ok && bucketListType == xdr.BucketListTypeHotArchive
+			}
+
+			record.Index = n
+			if !yield(record, nil) {
+				return
+			}
+		}
+
+		closed = true
+		if err := rdr.Close(); err != nil {
+			yield(BucketRecord{}, errors.Wrapf(err, "error closing xdr stream for hash '%s'", hash))
+		}
+	}
+}
+
+func liveBucketRecord(entry xdr.BucketEntry) (BucketRecord, error) {
+	var record BucketRecord
+	switch entry.Type {
+	case xdr.BucketEntryTypeMetaentry:
+		meta := entry.MustMetaEntry()
+		return BucketRecord{Type: BucketRecordMeta, Meta: &meta}, nil
+	case xdr.BucketEntryTypeLiveentry, xdr.BucketEntryTypeInitentry:
+		record.Type = BucketRecordLive
+		if entry.Type == xdr.BucketEntryTypeInitentry {
+			record.Type = BucketRecordInit
+		}
+		ledgerEntry := entry.MustLiveEntry()
+		record.Entry = &ledgerEntry
+		key, err := ledgerEntry.LedgerKey()
+		if err != nil {
+			return record, errors.Wrap(err, "error generating ledger key")
+		}
+		return record, record.setKey(key)
+	case xdr.BucketEntryTypeDeadentry:
+		record.Type = BucketRecordDead
+		return record, record.setKey(entry.MustDeadEntry())
+	default:
+		return record, errors.Errorf("unknown BucketEntryType=%d", entry.Type)
+	}
+}
+
+func hotArchiveBucketRecord(entry xdr.HotArchiveBucketEntry) (BucketRecord, error) {
+	var record BucketRecord
+	switch entry.Type {
+	case xdr.HotArchiveBucketEntryTypeHotArchiveMetaentry:
+		meta := entry.MustMetaEntry()
+		return BucketRecord{Type: BucketRecordMeta, Meta: &meta}, nil
+	case xdr.HotArchiveBucketEntryTypeHotArchiveArchived:
+		record.Type = BucketRecordArchived
+		ledgerEntry := entry.MustArchivedEntry()
+		record.Entry = &ledgerEntry
+		key, err := ledgerEntry.LedgerKey()
+		if err != nil {
+			return record, errors.Wrap(err, "error generating ledger key")
+		}
+		return record, record.setKey(key)
+	case xdr.HotArchiveBucketEntryTypeHotArchiveLive:
+		record.Type = BucketRecordRestored
+		return record, record.setKey(entry.MustKey())
+	default:
+		return record, errors.Errorf("unknown HotArchiveBucketEntryType=%d", entry.Type)
+	}
+}
+
+func (r *BucketRecord) setKey(key xdr.LedgerKey) error {
+	keyXdr, err := xdr.MarshalBase64(key)
+	if err != nil {
+		return errors.Wrap(err, "error marshaling ledger key")
+	}
+	r.Key = &key
+	r.KeyXdr = keyXdr
+	return nil
+}
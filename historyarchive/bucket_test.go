@@ -0,0 +1,146 @@
+package historyarchive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/xdr"
+)
+
+func putTestBucket(t *testing.T, archive *Archive, entries ...interface{}) Hash {
+	var raw bytes.Buffer
+	for _, entry := range entries {
+		require.NoError(t, xdr.MarshalFramed(&raw, entry))
+	}
+	hash := Hash(sha256.Sum256(raw.Bytes()))
+
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	_, err := writer.Write(raw.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.NoError(t, archive.backend.PutFile(BucketPath(hash), io.NopCloser(&compressed)))
+	return hash
+}
+
+func TestReadLiveBucket(t *testing.T) {
+	archive := GetTestMockArchive()
+	account := xdr.LedgerEntry{
+		Data: xdr.LedgerEntryData{
+			Type: xdr.LedgerEntryTypeAccount,
+			Account: &xdr.AccountEntry{
+				AccountId: xdr.MustAddress("GC3C4AKRBQLHOJ45U4XG35ESVWRDECWO5XLDGYADO6DPR3L7KIDVUMML"),
+				Balance:   100,
+			},
+		},
+	}
+	accountKey, err := account.LedgerKey()
+	require.NoError(t, err)
+	deadKey := xdr.LedgerKey{
+		Type:    xdr.LedgerEntryTypeAccount,
+		Account: &xdr.LedgerKeyAccount{AccountId: xdr.MustAddress("GAAZI4TCR3TY5OJHCTJC2A4QSY6CJWJH5IAJTGKIN2ER7LBNVKOCCWN7")},
+	}
+
+	hash := putTestBucket(t, archive,
+		xdr.BucketEntry{Type: xdr.BucketEntryTypeMetaentry, MetaEntry: &xdr.BucketMetadata{LedgerVersion: 22}},
+		xdr.BucketEntry{Type: xdr.BucketEntryTypeInitentry, LiveEntry: &account},
+		xdr.BucketEntry{Type: xdr.BucketEntryTypeDeadentry, DeadEntry: &deadKey},
+	)
+
+	var records []BucketRecord
+	for record, err := range ReadBucket(archive, hash) {
+		require.NoError(t, err)
+		records = append(records, record)
+	}
+	require.Len(t, records, 3)
+
+	assert.Equal(t, BucketRecordMeta, records[0].Type)
+	assert.Equal(t, xdr.Uint32(22), records[0].Meta.LedgerVersion)
+	assert.Nil(t, records[0].Key)
+
+	assert.Equal(t, 1, records[1].Index)
+	assert.Equal(t, BucketRecordInit, records[1].Type)
+	assert.Equal(t, account, *records[1].Entry)
+	assert.Equal(t, accountKey, *records[1].Key)
+	keyXdr, err := xdr.MarshalBase64(accountKey)
+	require.NoError(t, err)
+	assert.Equal(t, keyXdr, records[1].KeyXdr)
+
+	assert.Equal(t, BucketRecordDead, records[2].Type)
+	assert.Nil(t, records[2].Entry)
+	assert.Equal(t, deadKey, *records[2].Key)
+}
+
+func TestReadHotArchiveBucket(t *testing.T) {
+	archive := GetTestMockArchive()
+	hotArchive := xdr.BucketListTypeHotArchive
+	data := xdr.LedgerEntry{
+		Data: xdr.LedgerEntryData{
+			Type: xdr.LedgerEntryTypeContractCode,
+			ContractCode: &xdr.ContractCodeEntry{
+				Hash: xdr.Hash{1},
+				Code: []byte{1, 2, 3},
+			},
+		},
+	}
+	restoredKey := xdr.LedgerKey{
+		Type:         xdr.LedgerEntryTypeContractCode,
+		ContractCode: &xdr.LedgerKeyContractCode{Hash: xdr.Hash{2}},
+	}
+
+	hash := putTestBucket(t, archive,
+		xdr.HotArchiveBucketEntry{
+			Type: xdr.HotArchiveBucketEntryTypeHotArchiveMetaentry,
+			MetaEntry: &xdr.BucketMetadata{
+				LedgerVersion: 23,
+				Ext:           xdr.BucketMetadataExt{V: 1, BucketListType: &hotArchive},
+			},
+		},
+		xdr.HotArchiveBucketEntry{Type: xdr.HotArchiveBucketEntryTypeHotArchiveArchived, ArchivedEntry: &data},
+		xdr.HotArchiveBucketEntry{Type: xdr.HotArchiveBucketEntryTypeHotArchiveLive, Key: &restoredKey},
+	)
+
+	var types []BucketRecordType
+	for record, err := range ReadBucket(archive, hash) {
+		require.NoError(t, err)
+		types = append(types, record.Type)
+		if record.Type == BucketRecordRestored {
+			assert.Equal(t, restoredKey, *record.Key)
+		}
+	}
+	assert.Equal(t, []BucketRecordType{BucketRecordMeta, BucketRecordArchived, BucketRecordRestored}, types)
+}
+
+func TestReadBucketMisplacedMeta(t *testing.T) {
+	archive := GetTestMockArchive()
+	deadKey := xdr.LedgerKey{
+		Type:    xdr.LedgerEntryTypeAccount,
+		Account: &xdr.LedgerKeyAccount{AccountId: xdr.MustAddress("GAAZI4TCR3TY5OJHCTJC2A4QSY6CJWJH5IAJTGKIN2ER7LBNVKOCCWN7")},
+	}
+	hash := putTestBucket(t, archive,
+		xdr.BucketEntry{Type: xdr.BucketEntryTypeDeadentry, DeadEntry: &deadKey},
+		xdr.BucketEntry{Type: xdr.BucketEntryTypeMetaentry, MetaEntry: &xdr.BucketMetadata{LedgerVersion: 22}},
+	)
+
+	var err error
+	for _, err = range ReadBucket(archive, hash) {
+		if err != nil {
+			break
+		}
+	}
+	assert.EqualError(t, err, "METAENTRY not the first entry (n=1) in the bucket hash '"+hash.String()+"'")
+
+	_, err = func() (BucketRecord, error) {
+		for record, err := range ReadBucket(archive, Hash{1}) {
+			return record, err
+		}
+		return BucketRecord{}, nil
+	}()
+	assert.ErrorContains(t, err, "cannot get xdr stream for hash")
+}
@@ -0,0 +1,304 @@
+package historyarchive
+
+import (
+	"context"
+	"iter"
+	"sync"
+
+	lru "github.com/hashicorp/golang-lru"
+
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// LedgerReaderOptions configures a LedgerReader.
+type LedgerReaderOptions struct {
+	// Prefetch is the number of checkpoints, including the one being
+	// consumed, fetched concurrently while iterating. When the archive is an
+	// ArchivePool the fetches are spread across its members. Defaults to 2.
+	Prefetch int
+	// CacheSize is the number of recently used checkpoints kept in memory.
+	// Defaults to 4.
+	CacheSize int
+	// SkipVerify disables verification of ledger header hashes, the ledger
+	// header chain, and transaction set and result hashes.
+	SkipVerify bool
+}
+
+// LedgerReader reads ledgers (headers, transactions and results) from a
+// history archive by sequence number, hiding which checkpoint files contain
+// them.
+type LedgerReader struct {
+	archive ArchiveInterface
+	manager CheckpointManager
+	opts    LedgerReaderOptions
+
+	mutex    sync.Mutex
+	cache    *lru.Cache
+	inflight map[uint32]*checkpointFetch
+}
+
+type checkpointFetch struct {
+	checkpoint uint32
+	done       chan struct{}
+	ledgers    map[uint32]*Ledger
+	err        error
+}
+
+// NewLedgerReader returns a LedgerReader reading from the given archive,
+// which is typically an ArchivePool.
+func NewLedgerReader(archive ArchiveInterface, opts LedgerReaderOptions) (*LedgerReader, error) {
+	if opts.Prefetch <= 0 {
+		opts.Prefetch = 2
+	}
+	if opts.CacheSize <= 0 {
+		opts.CacheSize = 4
+	}
+	cache, err := lru.New(opts.CacheSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create checkpoint cache")
+	}
+	return &LedgerReader{
+		archive:  archive,
+		manager:  archive.GetCheckpointManager(),
+		opts:     opts,
+		cache:    cache,
+		inflight: make(map[uint32]*checkpointFetch),
+	}, nil
+}
+
+// GetLedger returns a single ledger. The whole checkpoint containing it is
+// fetched and cached, so reading nearby ledgers afterwards is cheap.
+func (r *LedgerReader) GetLedger(ctx context.Context, sequence uint32) (Ledger, error) {
+	ledgers, err := r.wait(ctx, r.fetch(ctx, r.manager.GetCheckpoint(sequence)))
+	if err != nil {
+		return Ledger{}, err
+	}
+	ledger, ok := ledgers[sequence]
+	if !ok {
+		return Ledger{}, errors.Errorf("ledger %d not found in checkpoint %d",
+			sequence, r.manager.GetCheckpoint(sequence))
+	}
+	return *ledger, nil
+}
+
+// Ledgers iterates over the ledgers from `from` to `to` (inclusive) in
+// order. While the ledgers of one checkpoint are being consumed the
+// following checkpoints are fetched in the background, up to Prefetch
+// checkpoints at a time. Fetches which are still running when the iteration
+// stops are cancelled. Unless SkipVerify is set, every ledger is checked to
+// follow the previous one in the header chain.
+//
+// Iteration stops after the first error.
+func (r *LedgerReader) Ledgers(ctx context.Context, from, to uint32) iter.Seq2[Ledger, error] {
+	return func(yield func(Ledger, error) bool) {
+		if from == 0 || from > to {
+			yield(Ledger{}, errors.Errorf("invalid ledger range [%d, %d]", from, to))
+			return
+		}
+
+		var checkpoints []uint32
+		last := r.manager.GetCheckpoint(to)
+		for chk := r.manager.GetCheckpoint(from); ; chk += r.manager.GetCheckpointFrequency() {
+			checkpoints = append(checkpoints, chk)
+			if chk >= last {
+				break
+			}
+		}
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		fetches := make([]*checkpointFetch, len(checkpoints))
+		var previous *Ledger
+		for i, chk := range checkpoints {
+			for j := i; j < len(checkpoints) && j < i+r.opts.Prefetch; j++ {
+				if fetches[j] == nil {
+					fetches[j] = r.fetch(ctx, checkpoints[j])
+				}
+			}
+
+			ledgers, err := r.wait(ctx, fetches[i])
+			fetches[i] = nil
+			if err != nil {
+				yield(Ledger{}, err)
+				return
+			}
+
+			rng := r.manager.GetCheckpointRange(chk)
+			for seq := max(rng.Low, from); seq <= min(rng.High, to); seq++ {
+				ledger, ok := ledgers[seq]
+				if !ok {
+					yield(Ledger{}, errors.Errorf("ledger %d not found in checkpoint %d", seq, chk))
+					return
+				}
+				if !r.opts.SkipVerify && previous != nil &&
+					ledger.Header.Header.PreviousLedgerHash != previous.Header.Hash {
+					yield(Ledger{}, errors.Errorf(
+						"ledger %d previous ledger hash %s does not match ledger %d hash %s",
+						seq, Hash(ledger.Header.Header.PreviousLedgerHash),
+						seq-1, Hash(previous.Header.Hash),
+					))
+					return
+				}
+				previous = ledger
+				if !yield(*ledger, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *LedgerReader) wait(ctx context.Context, fetch *checkpointFetch) (map[uint32]*Ledger, error) {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-fetch.done:
+		}
+		// The fetch may have been started, and cancelled, by another caller.
+		if cause := errors.Cause(fetch.err); ctx.Err() == nil &&
+			(cause == context.Canceled || cause == context.DeadlineExceeded) {
+			fetch = r.fetch(ctx, fetch.checkpoint)
+			continue
+		}
+		return fetch.ledgers, fetch.err
+	}
+}
+
+// fetch returns the cached or in-flight fetch of a checkpoint, starting a
+// new one using ctx if there is none.
+func (r *LedgerReader) fetch(ctx context.Context, checkpoint uint32) *checkpointFetch {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if cached, ok := r.cache.Get(checkpoint); ok {
+		fetch := &checkpointFetch{checkpoint: checkpoint, done: make(chan struct{}), ledgers: cached.(map[uint32]*Ledger)}
+		close(fetch.done)
+		return fetch
+	}
+	if fetch, ok := r.inflight[checkpoint]; ok {
+		return fetch
+	}
+
+	fetch := &checkpointFetch{checkpoint: checkpoint, done: make(chan struct{})}
+	r.inflight[checkpoint] = fetch
+	go func() {
+		fetch.ledgers, fetch.err = r.fetchCheckpoint(ctx, checkpoint)
+
+		r.mutex.Lock()
+		delete(r.inflight, checkpoint)
+		if fetch.err == nil {
+			r.cache.Add(checkpoint, fetch.ledgers)
+		}
+		r.mutex.Unlock()
+		close(fetch.done)
+	}()
+	return fetch
+}
+
+func (r *LedgerReader) fetchCheckpoint(ctx context.Context, checkpoint uint32) (map[uint32]*Ledger, error) {
+	ledgers, err := getLedgers(ctx, r.archive, r.manager.GetCheckpointRange(checkpoint).Low, checkpoint)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not get checkpoint %d", checkpoint)
+	}
+	if r.opts.SkipVerify {
+		return ledgers, nil
+	}
+	for seq, ledger := range ledgers {
+		if err := verifyLedger(ledger); err != nil {
+			return nil, errors.Wrapf(err, "checkpoint %d is invalid", checkpoint)
+		}
+		if previous, ok := ledgers[seq-1]; ok &&
+			ledger.Header.Header.PreviousLedgerHash != previous.Header.Hash {
+			return nil, errors.Errorf(
+				"checkpoint %d is invalid: ledger %d previous ledger hash does not match ledger %d",
+				checkpoint, seq, seq-1,
+			)
+		}
+	}
+	return ledgers, nil
+}
+
+// contextLedgersGetter is implemented by archives which can stop getting
+// ledgers once a context is done, like Archive.
+type contextLedgersGetter interface {
+	getLedgers(ctx context.Context, start, end uint32) (map[uint32]*Ledger, error)
+}
+
+// getLedgers is like archive.GetLedgers but stops downloading once ctx is
+// done, when the archive (or each member of an ArchivePool) supports it.
+func getLedgers(ctx context.Context, archive ArchiveInterface, start, end uint32) (map[uint32]*Ledger, error) {
+	switch a := archive.(type) {
+	case contextLedgersGetter:
+		return a.getLedgers(ctx, start, end)
+	case *ArchivePool:
+		var ledgers map[uint32]*Ledger
+		return ledgers, a.runRoundRobin(func(ai ArchiveInterface) error {
+			var err error
+			ledgers, err = getLedgers(ctx, ai, start, end)
+			return err
+		})
+	default:
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return archive.GetLedgers(start, end)
+	}
+}
+
+// verifyLedger checks the ledger header hash, and the hashes of the
+// transaction set and results against the header.
+func verifyLedger(ledger *Ledger) error {
+	header := ledger.Header.Header
+	seq := uint32(header.LedgerSeq)
+
+	hash, err := xdr.HashXdr(&header)
+	if err != nil {
+		return errors.Wrapf(err, "could not hash ledger %d", seq)
+	}
+	if hash != ledger.Header.Hash {
+		return errors.Errorf("ledger %d expected hash %s, got %s",
+			seq, Hash(ledger.Header.Hash), Hash(hash))
+	}
+
+	// Empty transaction sets and results are not published. The hash of an
+	// empty generalized transaction set cannot be derived from the header, so
+	// only the results are checked in that case.
+	if ledger.Transaction.LedgerSeq != 0 {
+		var txSetHash Hash
+		if ledger.Transaction.Ext.V == 1 {
+			var h xdr.Hash
+			h, err = xdr.HashXdr(ledger.Transaction.Ext.GeneralizedTxSet)
+			txSetHash = Hash(h)
+		} else {
+			// HashTxSet sorts the transactions in place; sort a copy so the
+			// ledger keeps them in apply order.
+			txSet := ledger.Transaction.TxSet
+			txSet.Txs = append([]xdr.TransactionEnvelope(nil), txSet.Txs...)
+			txSetHash, err = HashTxSet(&txSet)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "could not hash transaction set of ledger %d", seq)
+		}
+		if txSetHash != Hash(header.ScpValue.TxSetHash) {
+			return errors.Errorf("ledger %d expected transaction set hash %s, got %s",
+				seq, Hash(header.ScpValue.TxSetHash), txSetHash)
+		}
+	}
+
+	resultHash := EmptyXdrArrayHash()
+	if ledger.TransactionResult.LedgerSeq != 0 {
+		h, err := xdr.HashXdr(&ledger.TransactionResult.TxResultSet)
+		if err != nil {
+			return errors.Wrapf(err, "could not hash transaction results of ledger %d", seq)
+		}
+		resultHash = Hash(h)
+	}
+	if resultHash != Hash(header.TxSetResultHash) {
+		return errors.Errorf("ledger %d expected transaction result set hash %s, got %s",
+			seq, Hash(header.TxSetResultHash), resultHash)
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package historyarchive
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	backoff "github.com/cenkalti/backoff/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/xdr"
+)
+
+func writeTestLedgers(t *testing.T, archive *Archive, metas []xdr.LedgerCloseMeta) {
+	buckets := testBucketList(1)
+	writer, err := NewArchiveWriter(archive.backend, ArchiveWriterOptions{
+		BucketList: func(checkpoint uint32) (BucketList, BucketList, error) {
+			return buckets, BucketList{}, nil
+		},
+	})
+	require.NoError(t, err)
+	for _, meta := range metas {
+		require.NoError(t, writer.AddLedger(meta))
+	}
+}
+
+func TestLedgerReader(t *testing.T) {
+	metas := makeTestLedgerCloseMetas(t, 2, 191, testBucketList(1))
+	archives, err := NewArchivePoolWithBackoffFactory(
+		[]string{"mock://first", "mock://second"},
+		ArchiveOptions{CheckpointFrequency: 64},
+		func() backoff.BackOff {
+			return backoff.WithMaxRetries(backoff.NewConstantBackOff(0), 1)
+		},
+	)
+	require.NoError(t, err)
+	pool := archives.(*ArchivePool)
+	first, second := pool.pool[0].(*Archive), pool.pool[1].(*Archive)
+	writeTestLedgers(t, first, metas)
+	writeTestLedgers(t, second, metas)
+
+	reader, err := NewLedgerReader(pool, LedgerReaderOptions{Prefetch: 1, CacheSize: 3})
+	require.NoError(t, err)
+
+	expected := uint32(60)
+	for ledger, err := range reader.Ledgers(context.Background(), 60, 130) {
+		require.NoError(t, err)
+		require.Equal(t, xdr.Uint32(expected), ledger.Header.Header.LedgerSeq)
+		assertXdrEquals(t, metas[expected-2].LedgerHeaderHistoryEntry(), ledger.Header)
+		expected++
+	}
+	assert.Equal(t, uint32(131), expected)
+
+	// The three checkpoints were fetched from both members of the pool.
+	assert.NotZero(t, first.GetStats()[0].GetRequests())
+	assert.NotZero(t, second.GetStats()[0].GetRequests())
+
+	requests := pool.GetStats()[0].GetRequests() + pool.GetStats()[1].GetRequests()
+	ledger, err := reader.GetLedger(context.Background(), 100)
+	require.NoError(t, err)
+	assert.Equal(t, xdr.Uint32(100), ledger.Transaction.LedgerSeq)
+	assert.Equal(t, requests, pool.GetStats()[0].GetRequests()+pool.GetStats()[1].GetRequests())
+
+	// Stopping early is fine.
+	for ledger, err := range reader.Ledgers(context.Background(), 2, 191) {
+		require.NoError(t, err)
+		if ledger.Header.Header.LedgerSeq == 3 {
+			break
+		}
+	}
+
+	for _, err = range reader.Ledgers(context.Background(), 10, 5) {
+	}
+	assert.EqualError(t, err, "invalid ledger range [10, 5]")
+
+	_, err = reader.GetLedger(context.Background(), 1)
+	assert.EqualError(t, err, "ledger 1 not found in checkpoint 63")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = reader.GetLedger(ctx, 300)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestLedgerReaderVerifiesHeaderChain(t *testing.T) {
+	archive := GetTestMockArchive()
+	writeTestLedgers(t, archive, makeTestLedgerCloseMetas(t, 2, 63, testBucketList(1)))
+	// The second checkpoint belongs to a different chain.
+	writeTestLedgers(t, archive, makeTestLedgerCloseMetas(t, 64, 127, testBucketList(1)))
+
+	reader, err := NewLedgerReader(archive, LedgerReaderOptions{})
+	require.NoError(t, err)
+	for _, err = range reader.Ledgers(context.Background(), 60, 70) {
+		if err != nil {
+			break
+		}
+	}
+	assert.ErrorContains(t, err, "ledger 64 previous ledger hash")
+
+	reader, err = NewLedgerReader(archive, LedgerReaderOptions{SkipVerify: true})
+	require.NoError(t, err)
+	for _, err = range reader.Ledgers(context.Background(), 60, 70) {
+		require.NoError(t, err)
+	}
+
+	// Tampering with a ledger header is detected when its checkpoint is
+	// fetched.
+	metas := makeTestLedgerCloseMetas(t, 2, 63, testBucketList(1))
+	metas[10].V1.LedgerHeader.Header.TxSetResultHash = xdr.Hash{1}
+	archive = GetTestMockArchive()
+	writeTestLedgers(t, archive, metas)
+	reader, err = NewLedgerReader(archive, LedgerReaderOptions{})
+	require.NoError(t, err)
+	_, err = reader.GetLedger(context.Background(), 2)
+	assert.ErrorContains(t, err, "checkpoint 63 is invalid: ledger 12 expected hash")
+}
+
+// blockingLedgersArchive gets the ledgers of the checkpoint first and blocks
+// getting the ledgers of any other checkpoint until it is cancelled.
+type blockingLedgersArchive struct {
+	ArchiveInterface
+	first     uint32
+	mutex     sync.Mutex
+	started   []uint32
+	cancelled chan uint32
+}
+
+func (a *blockingLedgersArchive) getLedgers(ctx context.Context, start, end uint32) (map[uint32]*Ledger, error) {
+	if end == a.first {
+		return a.ArchiveInterface.GetLedgers(start, end)
+	}
+	a.mutex.Lock()
+	a.started = append(a.started, end)
+	a.mutex.Unlock()
+	<-ctx.Done()
+	a.cancelled <- end
+	return nil, ctx.Err()
+}
+
+func TestLedgerReaderCancelsPrefetches(t *testing.T) {
+	archive := GetTestMockArchive()
+	writeTestLedgers(t, archive, makeTestLedgerCloseMetas(t, 2, 191, testBucketList(1)))
+	blocking := &blockingLedgersArchive{
+		ArchiveInterface: archive,
+		first:            63,
+		cancelled:        make(chan uint32, 3),
+	}
+
+	reader, err := NewLedgerReader(blocking, LedgerReaderOptions{Prefetch: 2})
+	require.NoError(t, err)
+	for ledger, err := range reader.Ledgers(context.Background(), 2, 191) {
+		require.NoError(t, err)
+		require.Equal(t, xdr.Uint32(2), ledger.Header.Header.LedgerSeq)
+		break
+	}
+
+	// Only the next checkpoint was prefetched, and stopping the iteration
+	// cancelled it.
+	select {
+	case checkpoint := <-blocking.cancelled:
+		assert.Equal(t, uint32(127), checkpoint)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "prefetch was not cancelled")
+	}
+	blocking.mutex.Lock()
+	assert.Equal(t, []uint32{127}, blocking.started)
+	blocking.mutex.Unlock()
+
+	// A later call fetches the cancelled checkpoint again.
+	blocking.first = 127
+	ledger, err := reader.GetLedger(context.Background(), 100)
+	require.NoError(t, err)
+	assert.Equal(t, xdr.Uint32(100), ledger.Header.Header.LedgerSeq)
+}
@@ -69,10 +69,20 @@ func Mirror(src *Archive, dst *Archive, opts *CommandOptions) error {
 						bucketFetch[bucket] = true
 					}
 					bucketFetchMutex.Unlock()
-					if !alreadyFetching {
-						pth := BucketPath(bucket)
-						err = copyPath(src, dst, pth, opts)
-						atomic.AddUint32(&errs, noteError(err))
+					if alreadyFetching {
+						continue
+					}
+					dst.NoteReferencedBucket(bucket)
+					dst.noteBucketReferrer(bucket, ix)
+					if !opts.Force && dst.isKnownBucket(bucket, false) {
+						dst.NoteExistingBucket(bucket)
+						continue
+					}
+					pth := BucketPath(bucket)
+					err = copyPath(src, dst, pth, opts)
+					atomic.AddUint32(&errs, noteError(err))
+					if err == nil && !opts.DryRun {
+						dst.NoteExistingBucket(bucket)
 					}
 				}
 
@@ -82,6 +92,9 @@ func Mirror(src *Archive, dst *Archive, opts *CommandOptions) error {
 					}
 					pth := CategoryCheckpointPath(cat, ix)
 					err = copyPath(src, dst, pth, opts)
+					if !opts.DryRun {
+						dst.NoteCheckpointFile(cat, ix, err == nil)
+					}
 					if err != nil && !categoryRequired(cat) {
 						continue
 					}
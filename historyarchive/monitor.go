@@ -0,0 +1,387 @@
+package historyarchive
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/stellar/go/support/errors"
+	supportlog "github.com/stellar/go/support/log"
+	"github.com/stellar/go/support/render/health"
+	"github.com/stellar/go/support/render/httpjson"
+)
+
+// ReferenceLedgerFunc returns the latest ledger sequence known to a trusted
+// reference. The lag of monitored archives is computed against it.
+// `ledgerbackend.LedgerBackend.GetLatestLedgerSequence` can be used directly;
+// use ArchiveReference for an ArchiveInterface such as an ArchivePool.
+type ReferenceLedgerFunc func(ctx context.Context) (uint32, error)
+
+// ArchiveReference returns a ReferenceLedgerFunc reporting the latest ledger
+// published to the given archive.
+func ArchiveReference(archive ArchiveInterface) ReferenceLedgerFunc {
+	return func(ctx context.Context) (uint32, error) {
+		return archive.GetLatestLedgerSequence()
+	}
+}
+
+type MonitorOptions struct {
+	// Interval is the time between polls. Defaults to one minute.
+	Interval time.Duration
+	// Reference is used to compute the lag of every archive. If nil, lag is
+	// not reported.
+	Reference ReferenceLedgerFunc
+	// MaxLag is the number of ledgers an archive may lag behind the
+	// reference before it is reported as unhealthy. Defaults to two
+	// checkpoints.
+	MaxLag uint32
+	// InitialScanCheckpoints is the number of checkpoints, ending at the
+	// latest one, scanned for missing files on the first poll of an archive.
+	// Defaults to 1.
+	InitialScanCheckpoints uint32
+	// SkipOptional skips optional (SCP) checkpoint files when scanning.
+	SkipOptional bool
+	// Registry is where the metrics of the monitor are registered. If nil,
+	// metrics are not registered.
+	Registry  *prometheus.Registry
+	Namespace string
+	Logger    *supportlog.Entry
+}
+
+// ArchiveHealth is the state of a monitored archive as of its last poll.
+// ScannedCheckpoint is the last checkpoint scanned for missing files; every
+// checkpoint from the first one scanned up to it was scanned.
+type ArchiveHealth struct {
+	Name              string    `json:"name"`
+	LatestCheckpoint  uint32    `json:"latest_checkpoint"`
+	ScannedCheckpoint uint32    `json:"scanned_checkpoint"`
+	Lag               uint32    `json:"lag"`
+	MissingFiles      int       `json:"missing_files"`
+	LastPoll          time.Time `json:"last_poll"`
+	Error             string    `json:"error,omitempty"`
+	Healthy           bool      `json:"healthy"`
+}
+
+type missingFile struct {
+	category   string
+	checkpoint uint32
+	bucket     Hash
+}
+
+type monitoredArchive struct {
+	name    string
+	archive ArchiveInterface
+	// missing holds files found missing by previous scans. They are checked
+	// again on every poll so repairs are reflected in the metrics.
+	missing map[missingFile]bool
+	// scanned is the last checkpoint whose scan succeeded. It only moves
+	// forward one checkpoint at a time so a failed poll resumes where it
+	// stopped.
+	scanned uint32
+	health  ArchiveHealth
+}
+
+// Monitor continuously polls the root HAS of a set of archives, scans newly
+// published checkpoints for missing files and exports the results as
+// Prometheus metrics and a health endpoint.
+type Monitor struct {
+	opts     MonitorOptions
+	archives []*monitoredArchive
+	mutex    sync.RWMutex
+
+	latestCheckpointGauge *prometheus.GaugeVec
+	lagGauge              *prometheus.GaugeVec
+	referenceGauge        prometheus.Gauge
+	missingFilesGauge     *prometheus.GaugeVec
+	fetchDurationSummary  *prometheus.SummaryVec
+	pollErrorCounter      *prometheus.CounterVec
+}
+
+// NewMonitor returns a Monitor of the given archives, keyed by the name used
+// to label their metrics.
+func NewMonitor(archives map[string]ArchiveInterface, opts MonitorOptions) (*Monitor, error) {
+	if len(archives) == 0 {
+		return nil, errors.New("no history archives provided")
+	}
+	if opts.Interval == 0 {
+		opts.Interval = time.Minute
+	}
+	if opts.InitialScanCheckpoints == 0 {
+		opts.InitialScanCheckpoints = 1
+	}
+	if opts.Logger == nil {
+		opts.Logger = supportlog.DefaultLogger
+	}
+
+	m := &Monitor{opts: opts}
+	for name, archive := range archives {
+		m.archives = append(m.archives, &monitoredArchive{
+			name:    name,
+			archive: archive,
+			missing: map[missingFile]bool{},
+			health:  ArchiveHealth{Name: name},
+		})
+	}
+
+	m.latestCheckpointGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: opts.Namespace, Subsystem: "history_archive", Name: "latest_checkpoint",
+			Help: "latest checkpoint published in the root HAS of the archive",
+		},
+		[]string{"archive"},
+	)
+	m.lagGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: opts.Namespace, Subsystem: "history_archive", Name: "lag_ledgers",
+			Help: "number of ledgers the archive lags behind the reference",
+		},
+		[]string{"archive"},
+	)
+	m.referenceGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: opts.Namespace, Subsystem: "history_archive", Name: "reference_ledger",
+			Help: "latest ledger of the reference",
+		},
+	)
+	m.missingFilesGauge = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: opts.Namespace, Subsystem: "history_archive", Name: "missing_files",
+			Help: "number of files missing from the scanned checkpoints of the archive",
+		},
+		[]string{"archive", "category"},
+	)
+	m.fetchDurationSummary = prometheus.NewSummaryVec(
+		prometheus.SummaryOpts{
+			Namespace: opts.Namespace, Subsystem: "history_archive", Name: "fetch_duration_seconds",
+			Help:       "duration of fetching the root HAS of the archive, sliding window = 10m",
+			Objectives: map[float64]float64{0.5: 0.05, 0.9: 0.01, 0.99: 0.001},
+		},
+		[]string{"archive"},
+	)
+	m.pollErrorCounter = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: opts.Namespace, Subsystem: "history_archive", Name: "poll_errors_total",
+			Help: "number of failed polls of the archive",
+		},
+		[]string{"archive"},
+	)
+
+	if opts.Registry != nil {
+		opts.Registry.MustRegister(
+			m.latestCheckpointGauge,
+			m.lagGauge,
+			m.referenceGauge,
+			m.missingFilesGauge,
+			m.fetchDurationSummary,
+			m.pollErrorCounter,
+		)
+	}
+
+	return m, nil
+}
+
+// Run polls the archives every Interval until the context is canceled.
+func (m *Monitor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		m.Poll(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll polls every archive once, concurrently.
+func (m *Monitor) Poll(ctx context.Context) {
+	var reference uint32
+	var referenceErr error
+	if m.opts.Reference != nil {
+		reference, referenceErr = m.opts.Reference(ctx)
+		if referenceErr != nil {
+			m.opts.Logger.WithError(referenceErr).Warn("Could not get latest ledger of reference")
+		} else {
+			m.referenceGauge.Set(float64(reference))
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, a := range m.archives {
+		wg.Add(1)
+		go func(a *monitoredArchive) {
+			defer wg.Done()
+			m.pollArchive(ctx, a, reference, referenceErr == nil && m.opts.Reference != nil)
+		}(a)
+	}
+	wg.Wait()
+}
+
+func (m *Monitor) pollArchive(ctx context.Context, a *monitoredArchive, reference uint32, haveReference bool) {
+	m.mutex.RLock()
+	previous := a.health
+	m.mutex.RUnlock()
+
+	current := ArchiveHealth{Name: a.name, LastPoll: time.Now()}
+	err := m.scanArchive(ctx, a, &current)
+	if err != nil {
+		m.pollErrorCounter.WithLabelValues(a.name).Inc()
+		m.opts.Logger.WithField("archive", a.name).WithError(err).Warn("Error polling history archive")
+		current.Error = err.Error()
+		if current.LatestCheckpoint == 0 {
+			current.LatestCheckpoint = previous.LatestCheckpoint
+		}
+	}
+
+	current.ScannedCheckpoint = a.scanned
+
+	if haveReference && reference > current.LatestCheckpoint {
+		current.Lag = reference - current.LatestCheckpoint
+	}
+	m.lagGauge.WithLabelValues(a.name).Set(float64(current.Lag))
+
+	counts := map[string]int{}
+	for _, category := range append(Categories(), "bucket") {
+		counts[category] = 0
+	}
+	for file := range a.missing {
+		counts[file.category]++
+	}
+	for category, count := range counts {
+		m.missingFilesGauge.WithLabelValues(a.name, category).Set(float64(count))
+	}
+	current.MissingFiles = len(a.missing)
+
+	maxLag := m.opts.MaxLag
+	if maxLag == 0 {
+		maxLag = 2 * a.archive.GetCheckpointManager().GetCheckpointFrequency()
+	}
+	current.Healthy = current.Error == "" && current.MissingFiles == 0 &&
+		(!haveReference || current.Lag <= maxLag)
+
+	m.mutex.Lock()
+	a.health = current
+	m.mutex.Unlock()
+}
+
+func (m *Monitor) scanArchive(ctx context.Context, a *monitoredArchive, current *ArchiveHealth) error {
+	startTime := time.Now()
+	has, err := a.archive.GetRootHAS()
+	if err != nil {
+		return errors.Wrap(err, "could not get root HAS")
+	}
+	m.fetchDurationSummary.WithLabelValues(a.name).Observe(time.Since(startTime).Seconds())
+	current.LatestCheckpoint = has.CurrentLedger
+	m.latestCheckpointGauge.WithLabelValues(a.name).Set(float64(has.CurrentLedger))
+
+	// Files which were missing before may have been repaired since.
+	for file := range a.missing {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		exists, err := m.fileExists(a.archive, file)
+		if err != nil {
+			return err
+		}
+		if exists {
+			delete(a.missing, file)
+		}
+	}
+
+	manager := a.archive.GetCheckpointManager()
+	freq := manager.GetCheckpointFrequency()
+	low := a.scanned + freq
+	if a.scanned == 0 {
+		span := (m.opts.InitialScanCheckpoints - 1) * freq
+		low = manager.GetCheckpoint(0)
+		if has.CurrentLedger > span {
+			low = max(low, has.CurrentLedger-span)
+		}
+	}
+
+	for checkpoint := low; checkpoint <= has.CurrentLedger; checkpoint += freq {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := m.scanCheckpoint(a, checkpoint); err != nil {
+			return errors.Wrapf(err, "could not scan checkpoint %d", checkpoint)
+		}
+		a.scanned = checkpoint
+	}
+	return nil
+}
+
+func (m *Monitor) scanCheckpoint(a *monitoredArchive, checkpoint uint32) error {
+	for _, category := range Categories() {
+		if m.opts.SkipOptional && !categoryRequired(category) {
+			continue
+		}
+		file := missingFile{category: category, checkpoint: checkpoint}
+		exists, err := m.fileExists(a.archive, file)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			a.missing[file] = true
+		}
+	}
+	if a.missing[missingFile{category: "history", checkpoint: checkpoint}] {
+		return nil
+	}
+
+	has, err := a.archive.GetCheckpointHAS(checkpoint)
+	if err != nil {
+		return errors.Wrap(err, "could not get checkpoint HAS")
+	}
+	buckets, err := has.Buckets()
+	if err != nil {
+		return errors.Wrap(err, "could not get buckets")
+	}
+	for _, bucket := range buckets {
+		file := missingFile{category: "bucket", bucket: bucket}
+		exists, err := m.fileExists(a.archive, file)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			a.missing[file] = true
+		}
+	}
+	return nil
+}
+
+func (m *Monitor) fileExists(archive ArchiveInterface, file missingFile) (bool, error) {
+	if file.category == "bucket" {
+		return archive.BucketExists(file.bucket)
+	}
+	return archive.CategoryCheckpointExists(file.category, file.checkpoint)
+}
+
+// Health returns the state of every archive as of its last poll.
+func (m *Monitor) Health() []ArchiveHealth {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	result := make([]ArchiveHealth, len(m.archives))
+	for i, a := range m.archives {
+		result[i] = a.health
+	}
+	return result
+}
+
+// ServeHTTP implements a health endpoint which passes when every archive was
+// polled successfully, has no missing files and is within MaxLag of the
+// reference.
+func (m *Monitor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	response := health.Response{Status: health.StatusPass}
+	for _, archiveHealth := range m.Health() {
+		if !archiveHealth.Healthy {
+			response.Status = health.StatusFail
+		}
+	}
+	httpjson.Render(w, response, httpjson.HEALTHJSON)
+}
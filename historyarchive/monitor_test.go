@@ -0,0 +1,156 @@
+package historyarchive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMonitor(t *testing.T) {
+	archive := GetRandomPopulatedArchive()
+	backend := archive.backend.(*MockArchiveBackend)
+
+	latest := testRange().High
+	ledgerPath := CategoryCheckpointPath("ledger", latest)
+	ledgerFile := backend.files[ledgerPath]
+	delete(backend.files, ledgerPath)
+
+	reference := uint32(latest + 64)
+	registry := prometheus.NewRegistry()
+	monitor, err := NewMonitor(map[string]ArchiveInterface{"test": archive}, MonitorOptions{
+		Reference: func(ctx context.Context) (uint32, error) {
+			return reference, nil
+		},
+		InitialScanCheckpoints: 2,
+		Registry:               registry,
+		Namespace:              "archivist",
+	})
+	require.NoError(t, err)
+
+	monitor.Poll(context.Background())
+
+	health := monitor.Health()
+	require.Len(t, health, 1)
+	assert.Equal(t, "test", health[0].Name)
+	assert.Equal(t, latest, health[0].LatestCheckpoint)
+	assert.Equal(t, uint32(64), health[0].Lag)
+	assert.Equal(t, 1, health[0].MissingFiles)
+	assert.Empty(t, health[0].Error)
+	assert.False(t, health[0].Healthy)
+
+	assert.Equal(t, float64(latest), testutil.ToFloat64(monitor.latestCheckpointGauge.WithLabelValues("test")))
+	assert.Equal(t, float64(64), testutil.ToFloat64(monitor.lagGauge.WithLabelValues("test")))
+	assert.Equal(t, float64(reference), testutil.ToFloat64(monitor.referenceGauge))
+	assert.Equal(t, float64(1), testutil.ToFloat64(monitor.missingFilesGauge.WithLabelValues("test", "ledger")))
+	assert.Equal(t, float64(0), testutil.ToFloat64(monitor.missingFilesGauge.WithLabelValues("test", "bucket")))
+	assert.Equal(t, 1, testutil.CollectAndCount(monitor.fetchDurationSummary))
+
+	w := httptest.NewRecorder()
+	monitor.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+	assert.JSONEq(t, `{"status":"fail"}`, w.Body.String())
+
+	// Repair the missing file and publish a new checkpoint; only the new
+	// checkpoint is scanned.
+	backend.files[ledgerPath] = ledgerFile
+	require.NoError(t, archive.AddRandomCheckpoint(latest+64))
+	requestsBefore := archive.GetStats()[0].GetRequests()
+
+	monitor.Poll(context.Background())
+
+	health = monitor.Health()
+	assert.Equal(t, latest+64, health[0].LatestCheckpoint)
+	assert.Equal(t, uint32(0), health[0].Lag)
+	assert.Equal(t, 0, health[0].MissingFiles)
+	assert.True(t, health[0].Healthy)
+	assert.Equal(t, float64(0), testutil.ToFloat64(monitor.missingFilesGauge.WithLabelValues("test", "ledger")))
+
+	// root HAS + 1 repaired file + 5 categories + checkpoint HAS + 33 buckets
+	assert.Equal(t, uint32(1+1+5+1+33), archive.GetStats()[0].GetRequests()-requestsBefore)
+
+	w = httptest.NewRecorder()
+	monitor.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+	assert.JSONEq(t, `{"status":"pass"}`, w.Body.String())
+}
+
+func TestMonitorPollError(t *testing.T) {
+	archive := GetTestMockArchive()
+	registry := prometheus.NewRegistry()
+	monitor, err := NewMonitor(map[string]ArchiveInterface{"empty": archive}, MonitorOptions{
+		Registry: registry,
+	})
+	require.NoError(t, err)
+
+	monitor.Poll(context.Background())
+
+	health := monitor.Health()
+	assert.Contains(t, health[0].Error, "could not get root HAS")
+	assert.False(t, health[0].Healthy)
+	assert.Equal(t, float64(1), testutil.ToFloat64(monitor.pollErrorCounter.WithLabelValues("empty")))
+
+	_, err = NewMonitor(nil, MonitorOptions{})
+	assert.EqualError(t, err, "no history archives provided")
+}
+
+// failingCheckpointArchive fails to get the HAS of a checkpoint while fail
+// is set.
+type failingCheckpointArchive struct {
+	ArchiveInterface
+	checkpoint uint32
+	fail       bool
+	requests   int
+}
+
+func (a *failingCheckpointArchive) GetCheckpointHAS(checkpoint uint32) (HistoryArchiveState, error) {
+	if checkpoint == a.checkpoint {
+		a.requests++
+		if a.fail {
+			return HistoryArchiveState{}, errors.New("connection reset")
+		}
+	}
+	return a.ArchiveInterface.GetCheckpointHAS(checkpoint)
+}
+
+func TestMonitorRetriesFailedCheckpoint(t *testing.T) {
+	archive := GetRandomPopulatedArchive()
+	backend := archive.backend.(*MockArchiveBackend)
+	latest := testRange().High
+	failing := &failingCheckpointArchive{ArchiveInterface: archive, checkpoint: latest + 64}
+
+	monitor, err := NewMonitor(map[string]ArchiveInterface{"test": failing}, MonitorOptions{})
+	require.NoError(t, err)
+	monitor.Poll(context.Background())
+	health := monitor.Health()
+	require.Empty(t, health[0].Error)
+	assert.Equal(t, latest, health[0].ScannedCheckpoint)
+
+	// Publish two checkpoints, the second missing its ledger file, and fail
+	// the scan of the first.
+	require.NoError(t, archive.AddRandomCheckpoint(latest+64))
+	require.NoError(t, archive.AddRandomCheckpoint(latest+128))
+	delete(backend.files, CategoryCheckpointPath("ledger", latest+128))
+	failing.fail = true
+
+	monitor.Poll(context.Background())
+	health = monitor.Health()
+	assert.Contains(t, health[0].Error, fmt.Sprintf("could not scan checkpoint %d", latest+64))
+	assert.Equal(t, latest+128, health[0].LatestCheckpoint)
+	assert.Equal(t, latest, health[0].ScannedCheckpoint)
+	assert.Equal(t, 0, health[0].MissingFiles)
+
+	// The next poll scans both checkpoints again and finds the missing file.
+	failing.fail = false
+	monitor.Poll(context.Background())
+	health = monitor.Health()
+	assert.Empty(t, health[0].Error)
+	assert.Equal(t, latest+128, health[0].ScannedCheckpoint)
+	assert.Equal(t, 1, health[0].MissingFiles)
+	assert.Equal(t, 2, failing.requests)
+	assert.False(t, health[0].Healthy)
+}
@@ -130,9 +130,19 @@ func (r Range) Size() uint32 {
 }
 
 func fmtRangeList(vs []uint32, cManager CheckpointManager) string {
+	s := make([]string, 0, 10)
+	for _, r := range collapseRanges(vs, cManager) {
+		s = append(s, r.collapsedString())
+	}
+	return strings.Join(s, ", ")
+}
+
+// collapseRanges sorts the given checkpoints and merges consecutive
+// checkpoints into ranges.
+func collapseRanges(vs []uint32, cManager CheckpointManager) []Range {
 	slices.Sort(vs)
 
-	s := make([]string, 0, 10)
+	ranges := make([]Range, 0, 10)
 	var curr *Range
 
 	for _, t := range vs {
@@ -141,15 +151,15 @@ func fmtRangeList(vs []uint32, cManager CheckpointManager) string {
 				curr.High = t
 				continue
 			} else {
-				s = append(s, curr.collapsedString())
+				ranges = append(ranges, *curr)
 				curr = nil
 			}
 		}
 		curr = &Range{Low: t, High: t}
 	}
 	if curr != nil {
-		s = append(s, curr.collapsedString())
+		ranges = append(ranges, *curr)
 	}
 
-	return strings.Join(s, ", ")
+	return ranges
 }
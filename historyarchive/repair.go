@@ -42,7 +42,11 @@ func Repair(src *Archive, dst *Archive, opts *CommandOptions) error {
 				continue
 			}
 			log.Printf("Repairing %s", pth)
-			errs += noteError(copyPath(src, dst, pth, opts))
+			err = copyPath(src, dst, pth, opts)
+			errs += noteError(err)
+			if err == nil && !opts.DryRun {
+				dst.NoteCheckpointFile(cat, chk, true)
+			}
 			if cat == "history" {
 				repairedHistory = true
 			}
@@ -63,7 +67,11 @@ func Repair(src *Archive, dst *Archive, opts *CommandOptions) error {
 	for bkt := range missingBuckets {
 		pth := BucketPath(bkt)
 		log.Printf("Repairing %s", pth)
-		errs += noteError(copyPath(src, dst, pth, opts))
+		err := copyPath(src, dst, pth, opts)
+		errs += noteError(err)
+		if err == nil && !opts.DryRun {
+			dst.NoteExistingBucket(bkt)
+		}
 	}
 
 	if errs != 0 {
@@ -270,6 +270,13 @@ func (arch *Archive) ScanBuckets(opts *CommandOptions) error {
 					if !new {
 						continue
 					}
+					arch.noteBucketReferrer(bucket, ix)
+					if arch.isKnownBucket(bucket, opts.Verify) {
+						if !doList {
+							arch.NoteExistingBucket(bucket)
+						}
+						continue
+					}
 
 					if !doList || opts.Verify {
 						exists, err := arch.BucketExists(bucket)
@@ -291,6 +298,7 @@ func (arch *Archive) ScanBuckets(opts *CommandOptions) error {
 								if n != 0 {
 									arch.mutex.Lock()
 									arch.invalidBuckets++
+									arch.badBuckets[bucket] = true
 									arch.mutex.Unlock()
 								}
 							}
@@ -320,6 +328,7 @@ func (arch *Archive) ClearCachedInfo() {
 	}
 	arch.allBuckets = make(map[Hash]bool)
 	arch.referencedBuckets = make(map[Hash]bool)
+	arch.bucketReferrers = make(map[Hash]uint32)
 }
 
 func (arch *Archive) ReportCheckpointStats() {
@@ -363,6 +372,23 @@ func (arch *Archive) NoteReferencedBucket(bucket Hash) bool {
 	return true
 }
 
+func (arch *Archive) noteBucketReferrer(bucket Hash, chk uint32) {
+	arch.mutex.Lock()
+	defer arch.mutex.Unlock()
+	if _, ok := arch.bucketReferrers[bucket]; !ok {
+		arch.bucketReferrers[bucket] = chk
+	}
+}
+
+// isKnownBucket returns true if a previous scan recorded the bucket as
+// present and, when verify is set, as having been verified.
+func (arch *Archive) isKnownBucket(bucket Hash, verify bool) bool {
+	arch.mutex.Lock()
+	defer arch.mutex.Unlock()
+	verified, ok := arch.knownBuckets[bucket]
+	return ok && (verified || !verify)
+}
+
 func (arch *Archive) CheckCheckpointFilesMissing(opts *CommandOptions) map[string][]uint32 {
 	arch.mutex.Lock()
 	defer arch.mutex.Unlock()
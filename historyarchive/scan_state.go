@@ -0,0 +1,308 @@
+package historyarchive
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/stellar/go/support/errors"
+)
+
+const scanStateVersion = 1
+
+// ScanState records the results of previous scans, repairs or mirrors of an
+// archive so that later runs only need to examine checkpoints and buckets
+// which were not already found to be good. It is typically persisted to a
+// local file between runs with LoadScanState and Save.
+//
+// A checkpoint is good when all of its required checkpoint files and all of
+// the buckets first referenced by it are present, and (if it was verified)
+// all of its files have their expected hashes.
+type ScanState struct {
+	checkpointFrequency uint32
+	// checkpoints maps each good checkpoint to whether it was verified.
+	checkpoints map[uint32]bool
+	failed      map[uint32]bool
+	// buckets maps each good bucket to whether it was verified.
+	buckets map[Hash]bool
+	// ledgerHashes holds the hashes of the checkpoint ledgers preceding
+	// checkpoints which still need to be verified, so that the ledger
+	// header chain can be checked across runs.
+	ledgerHashes map[uint32]Hash
+}
+
+type scanStateFile struct {
+	Version             int               `json:"version"`
+	CheckpointFrequency uint32            `json:"checkpoint_frequency"`
+	Present             []Range           `json:"present"`
+	Verified            []Range           `json:"verified"`
+	Failed              []Range           `json:"failed"`
+	Buckets             map[string]bool   `json:"buckets"`
+	LedgerHashes        map[uint32]string `json:"ledger_hashes"`
+}
+
+// NewScanState returns an empty ScanState for an archive with the given
+// checkpoint frequency.
+func NewScanState(checkpointFrequency uint32) *ScanState {
+	return &ScanState{
+		checkpointFrequency: NewCheckpointManager(checkpointFrequency).GetCheckpointFrequency(),
+		checkpoints:         make(map[uint32]bool),
+		failed:              make(map[uint32]bool),
+		buckets:             make(map[Hash]bool),
+		ledgerHashes:        make(map[uint32]Hash),
+	}
+}
+
+// LoadScanState reads a ScanState previously written with Save. An empty
+// ScanState is returned if the file does not exist.
+func LoadScanState(path string, checkpointFrequency uint32) (*ScanState, error) {
+	state := NewScanState(checkpointFrequency)
+	buf, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "could not read scan state")
+	}
+
+	var file scanStateFile
+	if err = json.Unmarshal(buf, &file); err != nil {
+		return nil, errors.Wrapf(err, "could not decode scan state %s", path)
+	}
+	if file.Version != scanStateVersion {
+		return nil, errors.Errorf("unsupported scan state version %d", file.Version)
+	}
+	if file.CheckpointFrequency != state.checkpointFrequency {
+		return nil, errors.Errorf(
+			"scan state checkpoint frequency %d does not match %d",
+			file.CheckpointFrequency, state.checkpointFrequency,
+		)
+	}
+
+	manager := NewCheckpointManager(checkpointFrequency)
+	for _, r := range file.Present {
+		for chk := range r.GenerateCheckpoints(manager) {
+			state.checkpoints[chk] = false
+		}
+	}
+	for _, r := range file.Verified {
+		for chk := range r.GenerateCheckpoints(manager) {
+			state.checkpoints[chk] = true
+		}
+	}
+	for _, r := range file.Failed {
+		for chk := range r.GenerateCheckpoints(manager) {
+			state.failed[chk] = true
+		}
+	}
+	for hex, verified := range file.Buckets {
+		hash, err := DecodeHash(hex)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid bucket hash %s in scan state", hex)
+		}
+		state.buckets[hash] = verified
+	}
+	for ledger, hex := range file.LedgerHashes {
+		hash, err := DecodeHash(hex)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid ledger hash %s in scan state", hex)
+		}
+		state.ledgerHashes[ledger] = hash
+	}
+	return state, nil
+}
+
+// Save writes the ScanState to path. The file is replaced atomically so an
+// interrupted run never leaves a truncated state behind.
+func (s *ScanState) Save(path string) error {
+	manager := NewCheckpointManager(s.checkpointFrequency)
+	var present, verified, failed []uint32
+	for chk, isVerified := range s.checkpoints {
+		if isVerified {
+			verified = append(verified, chk)
+		} else {
+			present = append(present, chk)
+		}
+	}
+	for chk := range s.failed {
+		failed = append(failed, chk)
+	}
+
+	file := scanStateFile{
+		Version:             scanStateVersion,
+		CheckpointFrequency: s.checkpointFrequency,
+		Present:             collapseRanges(present, manager),
+		Verified:            collapseRanges(verified, manager),
+		Failed:              collapseRanges(failed, manager),
+		Buckets:             make(map[string]bool, len(s.buckets)),
+		LedgerHashes:        make(map[uint32]string, len(s.ledgerHashes)),
+	}
+	for hash, isVerified := range s.buckets {
+		file.Buckets[hash.String()] = isVerified
+	}
+	for ledger, hash := range s.ledgerHashes {
+		file.LedgerHashes[ledger] = hash.String()
+	}
+
+	buf, err := json.MarshalIndent(file, "", "    ")
+	if err != nil {
+		return errors.Wrap(err, "could not encode scan state")
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return errors.Wrap(err, "could not create scan state")
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(buf); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "could not write scan state")
+	}
+	if err = tmp.Close(); err != nil {
+		return errors.Wrap(err, "could not write scan state")
+	}
+	return errors.Wrap(os.Rename(tmp.Name(), path), "could not replace scan state")
+}
+
+// LastCheckpoint returns the highest checkpoint recorded in the state, or 0
+// if the state is empty.
+func (s *ScanState) LastCheckpoint() uint32 {
+	last := uint32(0)
+	for _, set := range []map[uint32]bool{s.checkpoints, s.failed} {
+		for chk := range set {
+			last = max(last, chk)
+		}
+	}
+	return last
+}
+
+// FailedCheckpoints returns the checkpoints which were missing files or had
+// invalid files when they were last examined, in ascending order.
+func (s *ScanState) FailedCheckpoints() []uint32 {
+	failed := make([]uint32, 0, len(s.failed))
+	for chk := range s.failed {
+		failed = append(failed, chk)
+	}
+	slices.Sort(failed)
+	return failed
+}
+
+// pending returns true if the checkpoint has to be examined again: it has
+// never been examined, it failed, or it has to be verified but was not.
+func (s *ScanState) pending(chk uint32, verify bool) bool {
+	verified, ok := s.checkpoints[chk]
+	return !ok || (verify && !verified)
+}
+
+// PendingCheckpointRanges returns the ranges of checkpoints within
+// opts.Range (clamped to the archive's current range) which are not recorded
+// as good in the state. When opts.Verify is set, checkpoints which were
+// previously only checked for presence are pending as well.
+func (arch *Archive) PendingCheckpointRanges(state *ScanState, opts *CommandOptions) ([]Range, error) {
+	if state.checkpointFrequency != arch.checkpointManager.GetCheckpointFrequency() {
+		return nil, errors.New("scan state checkpoint frequency does not match the archive")
+	}
+	has, err := arch.GetRootHAS()
+	if err != nil {
+		return nil, err
+	}
+	rng := opts.Range.clamp(has.Range(), arch.checkpointManager)
+
+	var pending []uint32
+	for chk := range rng.GenerateCheckpoints(arch.checkpointManager) {
+		if state.pending(chk, opts.Verify) {
+			pending = append(pending, chk)
+		}
+	}
+	return collapseRanges(pending, arch.checkpointManager), nil
+}
+
+// UseScanState seeds the archive with the results of previous runs: buckets
+// recorded as good are not checked (or verified) again, and the recorded
+// checkpoint ledger hashes are used to verify the ledger header chain at the
+// start of a scanned range.
+func (arch *Archive) UseScanState(state *ScanState) {
+	arch.mutex.Lock()
+	defer arch.mutex.Unlock()
+	arch.knownBuckets = make(map[Hash]bool, len(state.buckets))
+	for bucket, verified := range state.buckets {
+		arch.knownBuckets[bucket] = verified
+	}
+	for ledger, hash := range state.ledgerHashes {
+		if _, ok := arch.actualLedgerHashes[ledger]; !ok {
+			arch.actualLedgerHashes[ledger] = hash
+		}
+	}
+}
+
+// ResetScan discards the results of any previous scan of the archive so it
+// can be scanned again, for example over another range.
+func (arch *Archive) ResetScan() {
+	arch.ClearCachedInfo()
+
+	arch.mutex.Lock()
+	defer arch.mutex.Unlock()
+	arch.expectLedgerHashes = make(map[uint32]Hash)
+	arch.actualLedgerHashes = make(map[uint32]Hash)
+	arch.expectTxSetHashes = make(map[uint32]Hash)
+	arch.actualTxSetHashes = make(map[uint32]Hash)
+	arch.expectTxResultSetHashes = make(map[uint32]Hash)
+	arch.actualTxResultSetHashes = make(map[uint32]Hash)
+	arch.badBuckets = make(map[Hash]bool)
+	arch.invalidCheckpoints = make(map[uint32]bool)
+	arch.invalidBuckets = 0
+	arch.invalidLedgers = 0
+	arch.invalidTxSets = 0
+	arch.invalidTxResultSets = 0
+}
+
+// UpdateScanState records the results of scanning (or repairing, or
+// mirroring into) the archive over opts.Range in the state. When opts.Verify
+// is set it must be called after ReportInvalid.
+func (arch *Archive) UpdateScanState(state *ScanState, opts *CommandOptions) {
+	arch.mutex.Lock()
+	defer arch.mutex.Unlock()
+
+	failed := make(map[uint32]bool)
+	for chk := range opts.Range.GenerateCheckpoints(arch.checkpointManager) {
+		for _, cat := range Categories() {
+			if categoryRequired(cat) && !arch.checkpointFiles[cat][chk] {
+				failed[chk] = true
+			}
+		}
+		if opts.Verify && arch.invalidCheckpoints[chk] {
+			failed[chk] = true
+		}
+	}
+
+	for bucket := range arch.referencedBuckets {
+		if !arch.allBuckets[bucket] || arch.badBuckets[bucket] {
+			delete(state.buckets, bucket)
+			if chk, ok := arch.bucketReferrers[bucket]; ok {
+				failed[chk] = true
+			}
+			continue
+		}
+		state.buckets[bucket] = state.buckets[bucket] || opts.Verify
+	}
+
+	for chk := range opts.Range.GenerateCheckpoints(arch.checkpointManager) {
+		if failed[chk] {
+			delete(state.checkpoints, chk)
+			state.failed[chk] = true
+			continue
+		}
+		delete(state.failed, chk)
+		state.checkpoints[chk] = state.checkpoints[chk] || opts.Verify
+		if hash, ok := arch.actualLedgerHashes[chk]; ok && opts.Verify {
+			state.ledgerHashes[chk] = hash
+		}
+	}
+
+	// Only the hashes needed to continue verification on a later run are
+	// kept.
+	for ledger := range state.ledgerHashes {
+		if !state.pending(ledger+state.checkpointFrequency, true) {
+			delete(state.ledgerHashes, ledger)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package historyarchive
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanState(t *testing.T) {
+	defer cleanup()
+	arch := GetRandomPopulatedArchive()
+	backend := arch.backend.(*MockArchiveBackend)
+
+	ledgerPath := CategoryCheckpointPath("ledger", 0x1bf)
+	ledgerFile := backend.files[ledgerPath]
+	delete(backend.files, ledgerPath)
+
+	has, err := arch.GetCheckpointHAS(0x2bf)
+	require.NoError(t, err)
+	bucketPath := BucketPath(MustDecodeHash(has.CurrentBuckets[0].Curr))
+	bucketFile := backend.files[bucketPath]
+	delete(backend.files, bucketPath)
+
+	state := NewScanState(64)
+	opts := testOptions()
+	arch.UseScanState(state)
+	require.NoError(t, arch.Scan(opts))
+	arch.UpdateScanState(state, opts)
+
+	assert.Equal(t, []uint32{0x1bf, 0x2bf}, state.FailedCheckpoints())
+	assert.Equal(t, uint32(0x3bf), state.LastCheckpoint())
+
+	pth := filepath.Join(t.TempDir(), "state.json")
+	require.NoError(t, state.Save(pth))
+	loaded, err := LoadScanState(pth, 64)
+	require.NoError(t, err)
+	assert.Equal(t, state, loaded)
+
+	_, err = LoadScanState(pth, 128)
+	assert.EqualError(t, err, "scan state checkpoint frequency 64 does not match 128")
+
+	missing, err := LoadScanState(filepath.Join(t.TempDir(), "missing.json"), 64)
+	require.NoError(t, err)
+	assert.Equal(t, NewScanState(64), missing)
+
+	backend.files[ledgerPath] = ledgerFile
+	backend.files[bucketPath] = bucketFile
+	require.NoError(t, arch.AddRandomCheckpoint(0x3ff))
+
+	opts = &CommandOptions{Range: Range{Low: 0, High: 0xffffffff}, Concurrency: 16}
+	pending, err := arch.PendingCheckpointRanges(loaded, opts)
+	require.NoError(t, err)
+	assert.Equal(t, []Range{{0x1bf, 0x1bf}, {0x2bf, 0x2bf}, {0x3ff, 0x3ff}}, pending)
+
+	// Nothing has been verified yet, so everything is pending when verifying.
+	opts.Verify = true
+	pending, err = arch.PendingCheckpointRanges(loaded, opts)
+	require.NoError(t, err)
+	assert.Equal(t, []Range{{0x3f, 0x3ff}}, pending)
+	opts.Verify = false
+
+	// Buckets recorded as good are trusted and not checked again.
+	has, err = arch.GetCheckpointHAS(0x1bf)
+	require.NoError(t, err)
+	delete(backend.files, BucketPath(MustDecodeHash(has.CurrentBuckets[0].Curr)))
+
+	pending, err = arch.PendingCheckpointRanges(loaded, opts)
+	require.NoError(t, err)
+	for _, rng := range pending {
+		arch.ResetScan()
+		arch.UseScanState(loaded)
+		rangeOpts := *opts
+		rangeOpts.Range = rng
+		require.NoError(t, arch.Scan(&rangeOpts))
+		arch.UpdateScanState(loaded, &rangeOpts)
+	}
+
+	assert.Empty(t, loaded.FailedCheckpoints())
+	assert.Equal(t, uint32(0x3ff), loaded.LastCheckpoint())
+	pending, err = arch.PendingCheckpointRanges(loaded, opts)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+}
+
+func TestMirrorScanState(t *testing.T) {
+	defer cleanup()
+	opts := testOptions()
+	src := GetRandomPopulatedArchive()
+	dst := GetTestArchive()
+
+	state := NewScanState(64)
+	dst.UseScanState(state)
+	require.NoError(t, Mirror(src, dst, opts))
+	dst.UpdateScanState(state, opts)
+	assert.Empty(t, state.FailedCheckpoints())
+
+	require.NoError(t, src.AddRandomCheckpoint(0x3ff))
+	opts.Range.High = 0xffffffff
+	pending, err := src.PendingCheckpointRanges(state, opts)
+	require.NoError(t, err)
+	assert.Equal(t, []Range{{0x3ff, 0x3ff}}, pending)
+}
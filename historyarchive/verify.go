@@ -252,21 +252,27 @@ func reportValidity(ty string, nbad int, total int) {
 }
 
 func compareHashMaps(expect map[uint32]Hash, actual map[uint32]Hash, ty string,
-	passOn func(eledger uint32, ehash Hash) bool) int {
-	n := 0
+	passOn func(eledger uint32, ehash Hash) bool) []uint32 {
+	var mismatched []uint32
 	for eledger, ehash := range expect {
 		ahash, ok := actual[eledger]
 		if !ok && passOn(eledger, ehash) {
 			continue
 		}
 		if ahash != ehash {
-			n++
+			mismatched = append(mismatched, eledger)
 			log.Errorf("Error: mismatched hash on %s 0x%8.8x: expected %s, got %s",
 				ty, eledger, ehash, ahash)
 		}
 	}
-	reportValidity(ty, n, len(expect))
-	return n
+	reportValidity(ty, len(mismatched), len(expect))
+	return mismatched
+}
+
+func (arch *Archive) noteInvalidLedgers(ledgers []uint32) {
+	for _, ledger := range ledgers {
+		arch.invalidCheckpoints[arch.checkpointManager.GetCheckpoint(ledger)] = true
+	}
 }
 
 func (arch *Archive) ReportInvalid(opts *CommandOptions) (bool, error) {
@@ -284,30 +290,41 @@ func (arch *Archive) ReportInvalid(opts *CommandOptions) (bool, error) {
 		}
 	}
 
-	arch.invalidLedgers = compareHashMaps(arch.expectLedgerHashes,
+	invalidLedgers := compareHashMaps(arch.expectLedgerHashes,
 		arch.actualLedgerHashes, "ledger header",
 		func(eledger uint32, ehash Hash) bool {
 			// We will never have the lowest expected ledger, because
 			// it's one-before the first checkpoint we scanned.
 			return eledger == lowest
 		})
+	arch.invalidLedgers = len(invalidLedgers)
+	// The expected hash of a ledger comes from the header of the ledger
+	// after it, so either checkpoint may be at fault.
+	arch.noteInvalidLedgers(invalidLedgers)
+	for _, ledger := range invalidLedgers {
+		arch.invalidCheckpoints[arch.checkpointManager.GetCheckpoint(ledger+1)] = true
+	}
 
-	arch.invalidTxSets = compareHashMaps(arch.expectTxSetHashes,
+	invalidTxSets := compareHashMaps(arch.expectTxSetHashes,
 		arch.actualTxSetHashes, "transaction set",
 		func(eledger uint32, ehash Hash) bool {
 			// When there was an empty txset, it produces just the hash of
 			// the previous ledger header followed by nothing.
 			return ehash == HashEmptyTxSet(arch.expectLedgerHashes[eledger-1])
 		})
+	arch.invalidTxSets = len(invalidTxSets)
+	arch.noteInvalidLedgers(invalidTxSets)
 
 	emptyXdrArrayHash := EmptyXdrArrayHash()
-	arch.invalidTxResultSets = compareHashMaps(arch.expectTxResultSetHashes,
+	invalidTxResultSets := compareHashMaps(arch.expectTxResultSetHashes,
 		arch.actualTxResultSetHashes, "transaction result set",
 		func(eledger uint32, ehash Hash) bool {
 			// When there was an empty txresultset, it produces just the hash of
 			// the 4-zero-byte "0 entries" XDR array.
 			return ehash == emptyXdrArrayHash
 		})
+	arch.invalidTxResultSets = len(invalidTxResultSets)
+	arch.noteInvalidLedgers(invalidTxResultSets)
 
 	reportValidity("bucket", arch.invalidBuckets, len(arch.referencedBuckets))
 
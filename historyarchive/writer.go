@@ -0,0 +1,271 @@
+package historyarchive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/support/storage"
+	"github.com/stellar/go/xdr"
+)
+
+// BucketListProvider returns the live and hot archive bucket lists as of the
+// given checkpoint ledger. The hot archive bucket list is only recorded for
+// ledgers at protocol 23 or later. The bucket files referenced by the lists
+// are not uploaded by the ArchiveWriter; callers are responsible for making
+// them available in the archive.
+type BucketListProvider func(checkpoint uint32) (live BucketList, hotArchive BucketList, err error)
+
+type ArchiveWriterOptions struct {
+	// NetworkPassphrase is recorded in every HAS written to the archive.
+	NetworkPassphrase string
+	// CheckpointFrequency is the number of ledgers between checkpoints
+	// if unset, DefaultCheckpointFrequency will be used
+	CheckpointFrequency uint32
+	// Server is recorded in the "server" field of every HAS written to the
+	// archive.
+	Server string
+	// BucketList supplies the bucket lists recorded in the HAS of every
+	// checkpoint. It must not be nil.
+	BucketList BucketListProvider
+	// SkipBucketListHashCheck disables comparing the hash of the supplied
+	// bucket lists against BucketListHash in the checkpoint ledger header.
+	SkipBucketListHashCheck bool
+	// Force overwrites checkpoint files which already exist in the archive.
+	Force bool
+}
+
+// ArchiveWriter publishes history archive checkpoints from a stream of
+// LedgerCloseMeta. Ledgers are buffered in memory until a checkpoint ledger
+// is added, at which point the ledger, transactions, results and (if present)
+// scp category files are written to the backend, followed by the checkpoint
+// HAS and the root HAS.
+//
+// ArchiveWriter is not safe for concurrent use.
+type ArchiveWriter struct {
+	archive *Archive
+	opts    ArchiveWriterOptions
+
+	nextLedger   uint32
+	previousHash xdr.Hash
+
+	// pending holds the entries of each category which have not been
+	// written yet.
+	pending map[string][]interface{}
+}
+
+// NewArchiveWriter returns an ArchiveWriter which writes to the given
+// backend.
+func NewArchiveWriter(backend storage.Storage, opts ArchiveWriterOptions) (*ArchiveWriter, error) {
+	if backend == nil {
+		return nil, errors.New("backend is nil")
+	}
+	if opts.BucketList == nil {
+		return nil, errors.New("bucket list provider is nil")
+	}
+
+	archive := newArchive(ArchiveOptions{
+		NetworkPassphrase:   opts.NetworkPassphrase,
+		CheckpointFrequency: opts.CheckpointFrequency,
+	})
+	archive.backend = backend
+	archive.stats = archiveStats{backendName: "writer"}
+
+	return &ArchiveWriter{
+		archive: archive,
+		opts:    opts,
+		pending: make(map[string][]interface{}),
+	}, nil
+}
+
+// GetCheckpointManager returns the CheckpointManager used to determine
+// checkpoint boundaries.
+func (w *ArchiveWriter) GetCheckpointManager() CheckpointManager {
+	return w.archive.checkpointManager
+}
+
+// GetStats returns the request and upload statistics of the writer.
+func (w *ArchiveWriter) GetStats() []ArchiveStats {
+	return w.archive.GetStats()
+}
+
+// AddLedger buffers the given ledger and, if it is a checkpoint ledger, writes
+// the checkpoint to the archive. Ledgers must be added in order without gaps.
+// The first ledger added must be the first ledger of a checkpoint (ledger 2
+// for the first checkpoint, since the genesis ledger has no meta) so that no
+// partial checkpoints are published.
+func (w *ArchiveWriter) AddLedger(lcm xdr.LedgerCloseMeta) error {
+	header := lcm.LedgerHeaderHistoryEntry()
+	seq := lcm.LedgerSequence()
+	manager := w.archive.checkpointManager
+
+	if w.nextLedger == 0 {
+		first := manager.GetCheckpointRange(seq).Low
+		if first == 1 {
+			first = 2
+		}
+		if seq != first {
+			return errors.Errorf(
+				"first ledger %d is not the start of checkpoint %d (expected %d)",
+				seq, manager.GetCheckpoint(seq), first,
+			)
+		}
+	} else {
+		if seq != w.nextLedger {
+			return errors.Errorf("unexpected ledger %d (expected %d)", seq, w.nextLedger)
+		}
+		if lcm.PreviousLedgerHash() != w.previousHash {
+			return errors.Errorf(
+				"previous ledger hash of ledger %d does not match: expected %s, got %s",
+				seq, Hash(w.previousHash), Hash(lcm.PreviousLedgerHash()),
+			)
+		}
+	}
+
+	w.pending["ledger"] = append(w.pending["ledger"], header)
+	if lcm.CountTransactions() > 0 {
+		// stellar-core omits empty transaction sets from the transactions
+		// and results categories.
+		w.pending["transactions"] = append(w.pending["transactions"], transactionHistoryEntry(lcm))
+		w.pending["results"] = append(w.pending["results"], transactionHistoryResultEntry(lcm))
+	}
+	for _, entry := range scpInfo(lcm) {
+		w.pending["scp"] = append(w.pending["scp"], entry)
+	}
+
+	w.nextLedger = seq + 1
+	w.previousHash = lcm.LedgerHash()
+
+	if manager.IsCheckpoint(seq) {
+		return w.writeCheckpoint(header)
+	}
+	return nil
+}
+
+func (w *ArchiveWriter) writeCheckpoint(header xdr.LedgerHeaderHistoryEntry) error {
+	checkpoint := uint32(header.Header.LedgerSeq)
+
+	live, hotArchive, err := w.opts.BucketList(checkpoint)
+	if err != nil {
+		return errors.Wrapf(err, "could not get bucket list for checkpoint %d", checkpoint)
+	}
+
+	has := HistoryArchiveState{
+		Version:           1,
+		Server:            w.opts.Server,
+		CurrentLedger:     checkpoint,
+		NetworkPassphrase: w.opts.NetworkPassphrase,
+		CurrentBuckets:    live,
+	}
+	if header.Header.LedgerVersion >= 23 {
+		has.Version = HistoryArchiveStateVersionForProtocol23
+		has.HotArchiveBuckets = hotArchive
+	}
+
+	if !w.opts.SkipBucketListHashCheck {
+		hash, err := has.BucketListHash()
+		if err != nil {
+			return errors.Wrapf(err, "could not hash bucket list for checkpoint %d", checkpoint)
+		}
+		if hash != header.Header.BucketListHash {
+			return errors.Errorf(
+				"bucket list hash of checkpoint %d does not match ledger header: expected %s, got %s",
+				checkpoint, Hash(header.Header.BucketListHash), Hash(hash),
+			)
+		}
+	}
+
+	for _, category := range Categories() {
+		if category == "history" {
+			continue
+		}
+		if !categoryRequired(category) && len(w.pending[category]) == 0 {
+			continue
+		}
+		if err := w.putCategory(category, checkpoint, w.pending[category]); err != nil {
+			return errors.Wrapf(err, "could not write %s file for checkpoint %d", category, checkpoint)
+		}
+	}
+
+	opts := &CommandOptions{Force: w.opts.Force}
+	if err := w.archive.PutCheckpointHAS(checkpoint, has, opts); err != nil {
+		return errors.Wrapf(err, "could not write HAS for checkpoint %d", checkpoint)
+	}
+	if err := w.archive.PutRootHAS(has, opts); err != nil {
+		return errors.Wrap(err, "could not write root HAS")
+	}
+
+	log.WithField("checkpoint", checkpoint).Info("Published checkpoint")
+
+	w.pending = make(map[string][]interface{})
+	return nil
+}
+
+func (w *ArchiveWriter) putCategory(category string, checkpoint uint32, entries []interface{}) error {
+	pth := CategoryCheckpointPath(category, checkpoint)
+
+	exists, err := w.archive.backend.Exists(pth)
+	w.archive.stats.incrementRequests()
+	if err != nil {
+		return err
+	}
+	if exists && !w.opts.Force {
+		log.Printf("skipping existing %s", pth)
+		return nil
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	for _, entry := range entries {
+		if err := xdr.MarshalFramed(gz, entry); err != nil {
+			return err
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	w.archive.stats.incrementUploads()
+	return w.archive.backend.PutFile(pth, io.NopCloser(&buf))
+}
+
+func transactionHistoryEntry(lcm xdr.LedgerCloseMeta) xdr.TransactionHistoryEntry {
+	entry := xdr.TransactionHistoryEntry{LedgerSeq: xdr.Uint32(lcm.LedgerSequence())}
+	switch lcm.V {
+	case 0:
+		entry.TxSet = lcm.MustV0().TxSet
+	case 1:
+		txSet := lcm.MustV1().TxSet
+		entry.Ext = xdr.TransactionHistoryEntryExt{V: 1, GeneralizedTxSet: &txSet}
+	case 2:
+		txSet := lcm.MustV2().TxSet
+		entry.Ext = xdr.TransactionHistoryEntryExt{V: 1, GeneralizedTxSet: &txSet}
+	}
+	return entry
+}
+
+func transactionHistoryResultEntry(lcm xdr.LedgerCloseMeta) xdr.TransactionHistoryResultEntry {
+	results := make([]xdr.TransactionResultPair, lcm.CountTransactions())
+	for i := range results {
+		results[i] = lcm.TransactionResultPair(i)
+	}
+	return xdr.TransactionHistoryResultEntry{
+		LedgerSeq:   xdr.Uint32(lcm.LedgerSequence()),
+		TxResultSet: xdr.TransactionResultSet{Results: results},
+	}
+}
+
+func scpInfo(lcm xdr.LedgerCloseMeta) []xdr.ScpHistoryEntry {
+	switch lcm.V {
+	case 0:
+		return lcm.MustV0().ScpInfo
+	case 1:
+		return lcm.MustV1().ScpInfo
+	case 2:
+		return lcm.MustV2().ScpInfo
+	}
+	return nil
+}
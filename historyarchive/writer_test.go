@@ -0,0 +1,180 @@
+package historyarchive
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/xdr"
+)
+
+func testBucketList(seed byte) BucketList {
+	var list BucketList
+	for i := range list {
+		list[i].Curr = Hash{seed, byte(i), 1}.String()
+		list[i].Snap = Hash{seed, byte(i), 2}.String()
+	}
+	return list
+}
+
+func makeTestLedgerCloseMetas(t *testing.T, from, to uint32, buckets BucketList) []xdr.LedgerCloseMeta {
+	var metas []xdr.LedgerCloseMeta
+	previous := xdr.Hash{0xff}
+	bucketListHash, err := buckets.Hash()
+	require.NoError(t, err)
+
+	for seq := from; seq <= to; seq++ {
+		var txProcessing []xdr.TransactionResultMeta
+		var results xdr.TransactionResultSet
+		if seq%2 == 0 {
+			txProcessing = []xdr.TransactionResultMeta{{
+				Result: xdr.TransactionResultPair{
+					TransactionHash: xdr.Hash{byte(seq)},
+					Result: xdr.TransactionResult{
+						Result: xdr.TransactionResultResult{
+							Code:    xdr.TransactionResultCodeTxSuccess,
+							Results: &[]xdr.OperationResult{},
+						},
+					},
+				},
+			}}
+			results.Results = []xdr.TransactionResultPair{txProcessing[0].Result}
+		}
+		txSet := xdr.GeneralizedTransactionSet{
+			V: 1,
+			V1TxSet: &xdr.TransactionSetV1{
+				PreviousLedgerHash: previous,
+				Phases:             []xdr.TransactionPhase{},
+			},
+		}
+		txSetHash, err := xdr.HashXdr(txSet)
+		require.NoError(t, err)
+		resultsHash, err := xdr.HashXdr(results)
+		require.NoError(t, err)
+
+		header := xdr.LedgerHeader{
+			LedgerVersion:      22,
+			PreviousLedgerHash: previous,
+			ScpValue:           xdr.StellarValue{TxSetHash: txSetHash},
+			TxSetResultHash:    resultsHash,
+			LedgerSeq:          xdr.Uint32(seq),
+			BucketListHash:     bucketListHash,
+		}
+		hash, err := xdr.HashXdr(header)
+		require.NoError(t, err)
+
+		metas = append(metas, xdr.LedgerCloseMeta{
+			V: 1,
+			V1: &xdr.LedgerCloseMetaV1{
+				LedgerHeader: xdr.LedgerHeaderHistoryEntry{Hash: hash, Header: header},
+				TxSet:        txSet,
+				TxProcessing: txProcessing,
+			},
+		})
+		previous = hash
+	}
+	return metas
+}
+
+func TestArchiveWriter(t *testing.T) {
+	archive := GetTestMockArchive()
+	buckets := testBucketList(1)
+
+	writer, err := NewArchiveWriter(archive.backend, ArchiveWriterOptions{
+		NetworkPassphrase: network.TestNetworkPassphrase,
+		Server:            "test",
+		BucketList: func(checkpoint uint32) (BucketList, BucketList, error) {
+			return buckets, BucketList{}, nil
+		},
+	})
+	require.NoError(t, err)
+
+	metas := makeTestLedgerCloseMetas(t, 2, 130, buckets)
+	for _, meta := range metas {
+		require.NoError(t, writer.AddLedger(meta))
+	}
+
+	root, err := archive.GetRootHAS()
+	require.NoError(t, err)
+	assert.Equal(t, uint32(127), root.CurrentLedger)
+	assert.Equal(t, network.TestNetworkPassphrase, root.NetworkPassphrase)
+	assert.Equal(t, buckets, root.CurrentBuckets)
+
+	has, err := archive.GetCheckpointHAS(63)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(63), has.CurrentLedger)
+	assert.Equal(t, 1, has.Version)
+
+	// ledgers 128-130 are buffered until checkpoint 191
+	exists, err := archive.CategoryCheckpointExists("ledger", 191)
+	require.NoError(t, err)
+	assert.False(t, exists)
+	exists, err = archive.CategoryCheckpointExists("scp", 63)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	ledgers, err := archive.GetLedgers(2, 127)
+	require.NoError(t, err)
+	assert.Len(t, ledgers, 126)
+	for _, meta := range metas[:126] {
+		ledger := ledgers[meta.LedgerSequence()]
+		assertXdrEquals(t, meta.LedgerHeaderHistoryEntry(), ledger.Header)
+		if meta.CountTransactions() == 0 {
+			assert.Equal(t, xdr.Uint32(0), ledger.Transaction.LedgerSeq)
+			continue
+		}
+		assert.Equal(t, xdr.Uint32(meta.LedgerSequence()), ledger.Transaction.LedgerSeq)
+		assertXdrEquals(t, meta.V1.TxSet, *ledger.Transaction.Ext.GeneralizedTxSet)
+		require.Len(t, ledger.TransactionResult.TxResultSet.Results, 1)
+		assertXdrEquals(t, meta.TransactionResultPair(0), ledger.TransactionResult.TxResultSet.Results[0])
+	}
+
+	for _, checkpoint := range []uint32{63, 127} {
+		require.NoError(t, archive.VerifyCategoryCheckpoint("ledger", checkpoint))
+	}
+}
+
+func TestArchiveWriterRejectsInvalidLedgers(t *testing.T) {
+	archive := GetTestMockArchive()
+	buckets := testBucketList(2)
+	opts := ArchiveWriterOptions{
+		BucketList: func(checkpoint uint32) (BucketList, BucketList, error) {
+			return buckets, BucketList{}, nil
+		},
+	}
+
+	_, err := NewArchiveWriter(archive.backend, ArchiveWriterOptions{})
+	assert.EqualError(t, err, "bucket list provider is nil")
+
+	metas := makeTestLedgerCloseMetas(t, 2, 70, buckets)
+
+	writer, err := NewArchiveWriter(archive.backend, opts)
+	require.NoError(t, err)
+	assert.EqualError(t, writer.AddLedger(metas[10]),
+		"first ledger 12 is not the start of checkpoint 63 (expected 2)")
+
+	require.NoError(t, writer.AddLedger(metas[0]))
+	assert.EqualError(t, writer.AddLedger(metas[2]), "unexpected ledger 4 (expected 3)")
+
+	forked := makeTestLedgerCloseMetas(t, 2, 3, testBucketList(3))
+	forked[1].V1.LedgerHeader.Header.PreviousLedgerHash = xdr.Hash{1}
+	assert.ErrorContains(t, writer.AddLedger(forked[1]), "previous ledger hash of ledger 3 does not match")
+
+	writer, err = NewArchiveWriter(archive.backend, ArchiveWriterOptions{
+		BucketList: func(checkpoint uint32) (BucketList, BucketList, error) {
+			return testBucketList(4), BucketList{}, nil
+		},
+	})
+	require.NoError(t, err)
+	for _, meta := range metas[:61] {
+		require.NoError(t, writer.AddLedger(meta))
+	}
+	assert.ErrorContains(t, writer.AddLedger(metas[61]),
+		"bucket list hash of checkpoint 63 does not match ledger header")
+
+	exists, err := archive.CategoryCheckpointExists("ledger", 63)
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
@@ -124,13 +124,25 @@ func NewHotArchiveIterator(
 	archive historyarchive.ArchiveInterface,
 	sequence uint32,
 	opts ...CheckpointReaderOption,
+) iter.Seq2[xdr.LedgerEntry, error] {
+	return newBucketListIterator(ctx, archive, sequence, xdr.BucketListTypeHotArchive, opts...)
+}
+
+// newBucketListIterator constructs an iterator which enumerates the merged
+// ledger entries of the given bucket list at a checkpoint ledger.
+func newBucketListIterator(
+	ctx context.Context,
+	archive historyarchive.ArchiveInterface,
+	sequence uint32,
+	bucketListType xdr.BucketListType,
+	opts ...CheckpointReaderOption,
 ) iter.Seq2[xdr.LedgerEntry, error] {
 	return func(yield func(xdr.LedgerEntry, error) bool) {
 		r, err := newCheckpointChangeReaderWithBucketList(
 			ctx,
 			archive,
 			sequence,
-			xdr.BucketListTypeHotArchive,
+			bucketListType,
 			opts...,
 		)
 		if err != nil {
@@ -140,6 +152,9 @@ func NewHotArchiveIterator(
 		r.streamWaitGroup.Add(1)
 		go r.streamBucketList()
 		defer func() {
+			// stop the streamBucketList go routine in case the
+			// caller stopped iterating before the end of the stream
+			r.cancel(errors.New("iterator is closed"))
 			// the streamBucketList go routine writes to readChan
 			// so it is only safe to close it once that go routine
 			// terminates
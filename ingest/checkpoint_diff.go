@@ -0,0 +1,165 @@
+package ingest
+
+import (
+	"context"
+	"crypto/sha256"
+	"sort"
+
+	"github.com/stellar/go/historyarchive"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// CheckpointDiffType describes how a ledger entry differs between two
+// checkpoints.
+type CheckpointDiffType string
+
+const (
+	CheckpointDiffCreated CheckpointDiffType = "created"
+	CheckpointDiffUpdated CheckpointDiffType = "updated"
+	CheckpointDiffRemoved CheckpointDiffType = "removed"
+)
+
+// CheckpointDiffEntry is a single ledger key whose merged state differs
+// between two checkpoints.
+type CheckpointDiffEntry struct {
+	Type CheckpointDiffType `json:"type"`
+	Key  xdr.LedgerKey      `json:"key"`
+	// KeyXdr is the base64 XDR encoding of Key.
+	KeyXdr string `json:"key_xdr"`
+	// Pre is the ledger entry at the first checkpoint. It is nil for created
+	// entries.
+	Pre *xdr.LedgerEntry `json:"pre,omitempty"`
+	// Post is the ledger entry at the second checkpoint. It is nil for
+	// removed entries.
+	Post *xdr.LedgerEntry `json:"post,omitempty"`
+}
+
+// DiffCheckpoints computes a key-level diff between the merged state of the
+// given bucket list at the checkpoint ledgers `from` and `to`. Bucket entries
+// are merged using the same semantics as CheckpointChangeReader, and any
+// options (for example WithFilter) are applied to both checkpoints.
+//
+// Only a hash of every entry at `from` is kept in memory. The full entries
+// of keys which were updated or removed are read in a second pass over the
+// `from` checkpoint, so memory usage is proportional to the number of keys
+// in the state plus the number of changed entries.
+//
+// The returned entries are sorted by key.
+func DiffCheckpoints(
+	ctx context.Context,
+	archive historyarchive.ArchiveInterface,
+	bucketListType xdr.BucketListType,
+	from, to uint32,
+	opts ...CheckpointReaderOption,
+) ([]CheckpointDiffEntry, error) {
+	encodingBuffer := xdr.NewEncodingBuffer()
+	keyOf := func(entry xdr.LedgerEntry) (xdr.LedgerKey, string, error) {
+		key, err := entry.LedgerKey()
+		if err != nil {
+			return key, "", errors.Wrap(err, "error generating ledger key")
+		}
+		keyBytes, err := encodingBuffer.LedgerKeyUnsafeMarshalBinaryCompress(key)
+		if err != nil {
+			return key, "", errors.Wrap(err, "error marshaling ledger key")
+		}
+		return key, string(keyBytes), nil
+	}
+	hashOf := func(entry xdr.LedgerEntry) ([sha256.Size]byte, error) {
+		entryBytes, err := encodingBuffer.UnsafeMarshalBinary(&entry)
+		if err != nil {
+			return [sha256.Size]byte{}, errors.Wrap(err, "error marshaling ledger entry")
+		}
+		return sha256.Sum256(entryBytes), nil
+	}
+
+	previous := map[string][sha256.Size]byte{}
+	for entry, err := range newBucketListIterator(ctx, archive, from, bucketListType, opts...) {
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading checkpoint %d", from)
+		}
+		_, key, err := keyOf(entry)
+		if err != nil {
+			return nil, err
+		}
+		if previous[key], err = hashOf(entry); err != nil {
+			return nil, err
+		}
+	}
+
+	changes := map[string]*CheckpointDiffEntry{}
+	for entry, err := range newBucketListIterator(ctx, archive, to, bucketListType, opts...) {
+		if err != nil {
+			return nil, errors.Wrapf(err, "error reading checkpoint %d", to)
+		}
+		key, keyString, err := keyOf(entry)
+		if err != nil {
+			return nil, err
+		}
+		hash, err := hashOf(entry)
+		if err != nil {
+			return nil, err
+		}
+
+		previousHash, ok := previous[keyString]
+		delete(previous, keyString)
+		if ok && previousHash == hash {
+			continue
+		}
+		diffType := CheckpointDiffCreated
+		if ok {
+			diffType = CheckpointDiffUpdated
+		}
+		post := entry
+		changes[keyString] = &CheckpointDiffEntry{Type: diffType, Key: key, Post: &post}
+	}
+
+	// Whatever is left in previous has been removed. Read `from` again to
+	// fill in the previous state of removed and updated entries.
+	pending := len(previous)
+	for _, change := range changes {
+		if change.Type == CheckpointDiffUpdated {
+			pending++
+		}
+	}
+	if pending > 0 {
+		for entry, err := range newBucketListIterator(ctx, archive, from, bucketListType, opts...) {
+			if err != nil {
+				return nil, errors.Wrapf(err, "error reading checkpoint %d", from)
+			}
+			key, keyString, err := keyOf(entry)
+			if err != nil {
+				return nil, err
+			}
+			pre := entry
+			if _, ok := previous[keyString]; ok {
+				changes[keyString] = &CheckpointDiffEntry{Type: CheckpointDiffRemoved, Key: key, Pre: &pre}
+			} else if change, ok := changes[keyString]; ok && change.Type == CheckpointDiffUpdated {
+				change.Pre = &pre
+			} else {
+				continue
+			}
+			if pending--; pending == 0 {
+				break
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(changes))
+	for key := range changes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]CheckpointDiffEntry, 0, len(keys))
+	for _, key := range keys {
+		change := changes[key]
+		keyXdr, err := xdr.MarshalBase64(change.Key)
+		if err != nil {
+			return nil, errors.Wrap(err, "error marshaling ledger key")
+		}
+		change.KeyXdr = keyXdr
+		result = append(result, *change)
+	}
+	return result, nil
+}
@@ -0,0 +1,96 @@
+package ingest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/historyarchive"
+	"github.com/stellar/go/xdr"
+)
+
+func writeTestBucket(t *testing.T, root string, entries ...interface{}) historyarchive.Hash {
+	var raw bytes.Buffer
+	for _, entry := range entries {
+		require.NoError(t, xdr.MarshalFramed(&raw, entry))
+	}
+	hash := historyarchive.Hash(sha256.Sum256(raw.Bytes()))
+
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	_, err := writer.Write(raw.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	pth := filepath.Join(root, historyarchive.BucketPath(hash))
+	require.NoError(t, os.MkdirAll(filepath.Dir(pth), 0755))
+	require.NoError(t, os.WriteFile(pth, compressed.Bytes(), 0644))
+	return hash
+}
+
+func testHAS(checkpoint uint32, curr, snap historyarchive.Hash) historyarchive.HistoryArchiveState {
+	has := historyarchive.HistoryArchiveState{Version: 1, CurrentLedger: checkpoint}
+	for i := range has.CurrentBuckets {
+		has.CurrentBuckets[i].Curr = historyarchive.Hash{}.String()
+		has.CurrentBuckets[i].Snap = historyarchive.Hash{}.String()
+	}
+	has.CurrentBuckets[0].Curr = curr.String()
+	has.CurrentBuckets[0].Snap = snap.String()
+	return has
+}
+
+func TestDiffCheckpoints(t *testing.T) {
+	root := t.TempDir()
+	archive, err := historyarchive.Connect("file://"+root, historyarchive.ArchiveOptions{})
+	require.NoError(t, err)
+
+	unchanged := entryOffer(xdr.BucketEntryTypeLiveentry, "GAAZI4TCR3TY5OJHCTJC2A4QSY6CJWJH5IAJTGKIN2ER7LBNVKOCCWN7", 1)
+	updatedPre := entryAccount(xdr.BucketEntryTypeLiveentry, "GC3C4AKRBQLHOJ45U4XG35ESVWRDECWO5XLDGYADO6DPR3L7KIDVUMML", 1)
+	updatedPost := entryAccount(xdr.BucketEntryTypeLiveentry, "GC3C4AKRBQLHOJ45U4XG35ESVWRDECWO5XLDGYADO6DPR3L7KIDVUMML", 2)
+	removedPre := entryAccount(xdr.BucketEntryTypeLiveentry, "GAAZI4TCR3TY5OJHCTJC2A4QSY6CJWJH5IAJTGKIN2ER7LBNVKOCCWN7", 1)
+	removed := entryAccount(xdr.BucketEntryTypeDeadentry, "GAAZI4TCR3TY5OJHCTJC2A4QSY6CJWJH5IAJTGKIN2ER7LBNVKOCCWN7", 0)
+	created := entryCB(xdr.BucketEntryTypeInitentry, xdr.Hash{1}, 10)
+
+	first := writeTestBucket(t, root, metaEntry(22), updatedPre, removedPre, unchanged)
+	second := writeTestBucket(t, root, metaEntry(22), updatedPost, removed, created)
+
+	opts := &historyarchive.CommandOptions{Force: true}
+	require.NoError(t, archive.PutCheckpointHAS(63, testHAS(63, first, historyarchive.Hash{}), opts))
+	require.NoError(t, archive.PutCheckpointHAS(127, testHAS(127, second, first), opts))
+
+	diff, err := DiffCheckpoints(context.Background(), archive, xdr.BucketListTypeLive, 63, 127)
+	require.NoError(t, err)
+	require.Len(t, diff, 3)
+
+	byType := map[CheckpointDiffType]CheckpointDiffEntry{}
+	for _, entry := range diff {
+		byType[entry.Type] = entry
+		keyXdr, err := xdr.MarshalBase64(entry.Key)
+		require.NoError(t, err)
+		assert.Equal(t, keyXdr, entry.KeyXdr)
+	}
+
+	assert.Nil(t, byType[CheckpointDiffCreated].Pre)
+	assert.Equal(t, *created.LiveEntry, *byType[CheckpointDiffCreated].Post)
+
+	assert.Equal(t, *updatedPre.LiveEntry, *byType[CheckpointDiffUpdated].Pre)
+	assert.Equal(t, *updatedPost.LiveEntry, *byType[CheckpointDiffUpdated].Post)
+
+	assert.Equal(t, *removedPre.LiveEntry, *byType[CheckpointDiffRemoved].Pre)
+	assert.Nil(t, byType[CheckpointDiffRemoved].Post)
+	assert.Equal(t, *removed.DeadEntry, byType[CheckpointDiffRemoved].Key)
+
+	diff, err = DiffCheckpoints(context.Background(), archive, xdr.BucketListTypeLive, 63, 63)
+	require.NoError(t, err)
+	assert.Empty(t, diff)
+
+	_, err = DiffCheckpoints(context.Background(), archive, xdr.BucketListTypeLive, 63, 100)
+	assert.ErrorContains(t, err, "100 is not a checkpoint ledger")
+}
@@ -123,6 +123,21 @@ func (c *captiveCoreTomlValues) QuorumSetIsConfigured() bool {
 	return len(c.QuorumSetEntries) > 0 || len(c.Validators) > 0
 }
 
+// QuorumSets returns the [QUORUM_SET] tables of the configuration keyed by
+// their original table name, for example "QUORUM_SET" or "QUORUM_SET.inner".
+func (c *CaptiveCoreToml) QuorumSets() map[string]QuorumSet {
+	quorumSets := map[string]QuorumSet{}
+	for key, qs := range c.QuorumSetEntries {
+		if c.tablePlaceholders != nil {
+			if original, ok := c.tablePlaceholders.get(key); ok {
+				key = original
+			}
+		}
+		quorumSets[key] = qs
+	}
+	return quorumSets
+}
+
 // HistoryIsConfigured returns true if the history archive locations are configured.
 func (c *captiveCoreTomlValues) HistoryIsConfigured() bool {
 	if len(c.HistoryEntries) > 0 {
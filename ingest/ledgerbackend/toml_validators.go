@@ -0,0 +1,230 @@
+package ledgerbackend
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pelletier/go-toml"
+
+	"github.com/stellar/go/clients/stellartoml"
+	"github.com/stellar/go/support/errors"
+)
+
+// minHighQualityValidators is the number of validators stellar-core requires
+// from HIGH and CRITICAL quality organizations.
+const minHighQualityValidators = 3
+
+// validatorsTomlValues holds the entries written by GenerateCaptiveCoreToml.
+type validatorsTomlValues struct {
+	HomeDomains []HomeDomain `toml:"HOME_DOMAINS"`
+	Validators  []Validator  `toml:"VALIDATORS"`
+}
+
+// GenerateCaptiveCoreToml generates a captive core configuration with the
+// validators listed in the stellar.toml files of the given home domains,
+// each with the quality of its [[HOME_DOMAINS]] entry. The validators'
+// history archives come from their HISTORY field.
+//
+// It fails like stellar-core would if a HIGH or CRITICAL quality
+// organization lists less than 3 validators or validators which do not
+// publish a history archive.
+func GenerateCaptiveCoreToml(
+	client stellartoml.ClientInterface,
+	homeDomains []HomeDomain,
+	params CaptiveCoreTomlParams,
+) (*CaptiveCoreToml, error) {
+	if len(homeDomains) == 0 {
+		return nil, errors.New("no home domains")
+	}
+	values := validatorsTomlValues{HomeDomains: homeDomains}
+	for _, hd := range homeDomains {
+		resp, err := client.GetStellarToml(hd.HomeDomain)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not fetch stellar.toml of %s", hd.HomeDomain)
+		}
+		validators, err := tomlValidators(hd, resp, params.NetworkPassphrase)
+		if err != nil {
+			return nil, err
+		}
+		values.Validators = append(values.Validators, validators...)
+	}
+
+	var sb strings.Builder
+	if err := toml.NewEncoder(&sb).Encode(values); err != nil {
+		return nil, errors.Wrap(err, "could not encode toml file")
+	}
+	return NewCaptiveCoreTomlFromData([]byte(sb.String()), params)
+}
+
+// tomlValidators returns the [[VALIDATORS]] entries of the validators listed
+// in the stellar.toml file of the home domain.
+func tomlValidators(hd HomeDomain, resp *stellartoml.Response, networkPassphrase string) ([]Validator, error) {
+	if resp.NetworkPassphrase != "" && resp.NetworkPassphrase != networkPassphrase {
+		return nil, fmt.Errorf(
+			"stellar.toml of %s is for another network: %s",
+			hd.HomeDomain,
+			resp.NetworkPassphrase,
+		)
+	}
+	if len(resp.Validators) == 0 {
+		return nil, fmt.Errorf("stellar.toml of %s does not list any validators", hd.HomeDomain)
+	}
+	highQuality := hd.Quality == "HIGH" || hd.Quality == "CRITICAL"
+	if highQuality && len(resp.Validators) < minHighQualityValidators {
+		return nil, fmt.Errorf(
+			"%s quality home domain %s must list at least %d validators",
+			hd.Quality,
+			hd.HomeDomain,
+			minHighQualityValidators,
+		)
+	}
+
+	validators := make([]Validator, 0, len(resp.Validators))
+	for i, v := range resp.Validators {
+		name := v.Alias
+		if name == "" {
+			name = fmt.Sprintf("%s_%d", strings.ReplaceAll(hd.HomeDomain, ".", "_"), i+1)
+		}
+		if highQuality && v.History == "" {
+			return nil, fmt.Errorf(
+				"validator %s of %s quality home domain %s must publish a history archive",
+				name,
+				hd.Quality,
+				hd.HomeDomain,
+			)
+		}
+		validator := Validator{
+			Name:       name,
+			HomeDomain: hd.HomeDomain,
+			PublicKey:  v.PublicKey,
+			Address:    v.Host,
+		}
+		if v.History != "" {
+			validator.History = fmt.Sprintf("curl -sf %s/{0} -o {1}", strings.TrimSuffix(v.History, "/"))
+		}
+		validators = append(validators, validator)
+	}
+	return validators, nil
+}
+
+// ValidatorChange is a validator whose entry differs between two
+// configurations.
+type ValidatorChange struct {
+	Old Validator
+	New Validator
+}
+
+// HomeDomainChange is a home domain whose quality differs between two
+// configurations.
+type HomeDomainChange struct {
+	Old HomeDomain
+	New HomeDomain
+}
+
+// ValidatorsDiff describes how the [[HOME_DOMAINS]] and [[VALIDATORS]]
+// entries of two captive core configurations differ. Home domains are
+// matched by HOME_DOMAIN and validators by PUBLIC_KEY.
+type ValidatorsDiff struct {
+	AddedHomeDomains   []HomeDomain
+	RemovedHomeDomains []HomeDomain
+	ChangedHomeDomains []HomeDomainChange
+	AddedValidators    []Validator
+	RemovedValidators  []Validator
+	ChangedValidators  []ValidatorChange
+}
+
+// DiffValidators returns the changes to the home domains and validators of
+// c which make up those of other.
+func (c *CaptiveCoreToml) DiffValidators(other *CaptiveCoreToml) ValidatorsDiff {
+	var diff ValidatorsDiff
+
+	homeDomains := map[string]HomeDomain{}
+	for _, hd := range c.HomeDomains {
+		homeDomains[hd.HomeDomain] = hd
+	}
+	for _, hd := range other.HomeDomains {
+		old, ok := homeDomains[hd.HomeDomain]
+		switch {
+		case !ok:
+			diff.AddedHomeDomains = append(diff.AddedHomeDomains, hd)
+		case old != hd:
+			diff.ChangedHomeDomains = append(diff.ChangedHomeDomains, HomeDomainChange{Old: old, New: hd})
+		}
+		delete(homeDomains, hd.HomeDomain)
+	}
+	for _, hd := range c.HomeDomains {
+		if _, ok := homeDomains[hd.HomeDomain]; ok {
+			diff.RemovedHomeDomains = append(diff.RemovedHomeDomains, hd)
+		}
+	}
+
+	validators := map[string]Validator{}
+	for _, v := range c.Validators {
+		validators[v.PublicKey] = v
+	}
+	for _, v := range other.Validators {
+		old, ok := validators[v.PublicKey]
+		switch {
+		case !ok:
+			diff.AddedValidators = append(diff.AddedValidators, v)
+		case old != v:
+			diff.ChangedValidators = append(diff.ChangedValidators, ValidatorChange{Old: old, New: v})
+		}
+		delete(validators, v.PublicKey)
+	}
+	for _, v := range c.Validators {
+		if _, ok := validators[v.PublicKey]; ok {
+			diff.RemovedValidators = append(diff.RemovedValidators, v)
+		}
+	}
+	return diff
+}
+
+// IsEmpty returns true if both configurations have the same home domains
+// and validators.
+func (d ValidatorsDiff) IsEmpty() bool {
+	return len(d.AddedHomeDomains) == 0 &&
+		len(d.RemovedHomeDomains) == 0 &&
+		len(d.ChangedHomeDomains) == 0 &&
+		len(d.AddedValidators) == 0 &&
+		len(d.RemovedValidators) == 0 &&
+		len(d.ChangedValidators) == 0
+}
+
+// String returns the changes one per line, prefixed with +, - or ~ for
+// added, removed and changed entries.
+func (d ValidatorsDiff) String() string {
+	var sb strings.Builder
+	for _, hd := range d.AddedHomeDomains {
+		fmt.Fprintf(&sb, "+ HOME_DOMAIN %s QUALITY=%s\n", hd.HomeDomain, hd.Quality)
+	}
+	for _, hd := range d.RemovedHomeDomains {
+		fmt.Fprintf(&sb, "- HOME_DOMAIN %s QUALITY=%s\n", hd.HomeDomain, hd.Quality)
+	}
+	for _, change := range d.ChangedHomeDomains {
+		fmt.Fprintf(&sb, "~ HOME_DOMAIN %s QUALITY=%s -> %s\n",
+			change.New.HomeDomain, change.Old.Quality, change.New.Quality)
+	}
+	for _, v := range d.AddedValidators {
+		fmt.Fprintf(&sb, "+ VALIDATOR %s %s HOME_DOMAIN=%s\n", v.Name, v.PublicKey, v.HomeDomain)
+	}
+	for _, v := range d.RemovedValidators {
+		fmt.Fprintf(&sb, "- VALIDATOR %s %s HOME_DOMAIN=%s\n", v.Name, v.PublicKey, v.HomeDomain)
+	}
+	for _, change := range d.ChangedValidators {
+		fmt.Fprintf(&sb, "~ VALIDATOR %s %s", change.New.Name, change.New.PublicKey)
+		for _, field := range []struct{ name, old, new string }{
+			{"NAME", change.Old.Name, change.New.Name},
+			{"QUALITY", change.Old.Quality, change.New.Quality},
+			{"HOME_DOMAIN", change.Old.HomeDomain, change.New.HomeDomain},
+			{"ADDRESS", change.Old.Address, change.New.Address},
+			{"HISTORY", change.Old.History, change.New.History},
+		} {
+			if field.old != field.new {
+				fmt.Fprintf(&sb, " %s=%q -> %q", field.name, field.old, field.new)
+			}
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,240 @@
+package ledgerbackend
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/clients/stellartoml"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+)
+
+func stellarTomlValidators(domain string, count int, history bool) []stellartoml.Validator {
+	validators := make([]stellartoml.Validator, 0, count)
+	for i := 0; i < count; i++ {
+		v := stellartoml.Validator{
+			PublicKey: keypair.MustRandom().Address(),
+			Host:      domain + ":11625",
+		}
+		if history {
+			v.History = "https://history." + domain + "/node/"
+		}
+		validators = append(validators, v)
+	}
+	return validators
+}
+
+func TestGenerateCaptiveCoreToml(t *testing.T) {
+	high := stellarTomlValidators("high.example.com", 3, true)
+	high[0].Alias = "first"
+	medium := stellarTomlValidators("medium.example.com", 1, false)
+
+	client := &stellartoml.MockClient{}
+	client.On("GetStellarToml", "high.example.com").Return(&stellartoml.Response{
+		NetworkPassphrase: network.PublicNetworkPassphrase,
+		Validators:        high,
+	}, nil)
+	client.On("GetStellarToml", "medium.example.com").Return(&stellartoml.Response{
+		Validators: medium,
+	}, nil)
+	homeDomains := []HomeDomain{
+		{HomeDomain: "high.example.com", Quality: "HIGH"},
+		{HomeDomain: "medium.example.com", Quality: "MEDIUM"},
+	}
+
+	captiveCoreToml, err := GenerateCaptiveCoreToml(client, homeDomains, CaptiveCoreTomlParams{
+		NetworkPassphrase: network.PublicNetworkPassphrase,
+	})
+	require.NoError(t, err)
+	client.AssertExpectations(t)
+
+	assert.Equal(t, homeDomains, captiveCoreToml.HomeDomains)
+	assert.Equal(t, []Validator{
+		{
+			Name:       "first",
+			HomeDomain: "high.example.com",
+			PublicKey:  high[0].PublicKey,
+			Address:    "high.example.com:11625",
+			History:    "curl -sf https://history.high.example.com/node/{0} -o {1}",
+		},
+		{
+			Name:       "high_example_com_2",
+			HomeDomain: "high.example.com",
+			PublicKey:  high[1].PublicKey,
+			Address:    "high.example.com:11625",
+			History:    "curl -sf https://history.high.example.com/node/{0} -o {1}",
+		},
+		{
+			Name:       "high_example_com_3",
+			HomeDomain: "high.example.com",
+			PublicKey:  high[2].PublicKey,
+			Address:    "high.example.com:11625",
+			History:    "curl -sf https://history.high.example.com/node/{0} -o {1}",
+		},
+		{
+			Name:       "medium_example_com_1",
+			HomeDomain: "medium.example.com",
+			PublicKey:  medium[0].PublicKey,
+			Address:    "medium.example.com:11625",
+		},
+	}, captiveCoreToml.Validators)
+	assert.True(t, captiveCoreToml.QuorumSetIsConfigured())
+	assert.True(t, captiveCoreToml.HistoryIsConfigured())
+
+	// The generated configuration can be read back.
+	data, err := captiveCoreToml.Marshal()
+	require.NoError(t, err)
+	parsed, err := NewCaptiveCoreTomlFromData(data, CaptiveCoreTomlParams{
+		NetworkPassphrase: network.PublicNetworkPassphrase,
+		Strict:            true,
+	})
+	require.NoError(t, err)
+	assert.True(t, captiveCoreToml.DiffValidators(parsed).IsEmpty())
+}
+
+func TestGenerateCaptiveCoreTomlErrors(t *testing.T) {
+	params := CaptiveCoreTomlParams{NetworkPassphrase: network.PublicNetworkPassphrase}
+	for _, testCase := range []struct {
+		name          string
+		quality       string
+		resp          *stellartoml.Response
+		err           error
+		expectedError string
+	}{
+		{
+			name:          "fetch error",
+			quality:       "MEDIUM",
+			resp:          &stellartoml.Response{},
+			err:           errors.New("connection refused"),
+			expectedError: "could not fetch stellar.toml of example.com: connection refused",
+		},
+		{
+			name:    "other network",
+			quality: "MEDIUM",
+			resp: &stellartoml.Response{
+				NetworkPassphrase: network.TestNetworkPassphrase,
+				Validators:        stellarTomlValidators("example.com", 1, true),
+			},
+			expectedError: "stellar.toml of example.com is for another network: " + network.TestNetworkPassphrase,
+		},
+		{
+			name:          "no validators",
+			quality:       "LOW",
+			resp:          &stellartoml.Response{},
+			expectedError: "stellar.toml of example.com does not list any validators",
+		},
+		{
+			name:          "too few high quality validators",
+			quality:       "HIGH",
+			resp:          &stellartoml.Response{Validators: stellarTomlValidators("example.com", 2, true)},
+			expectedError: "HIGH quality home domain example.com must list at least 3 validators",
+		},
+		{
+			name:          "high quality validator without history",
+			quality:       "CRITICAL",
+			resp:          &stellartoml.Response{Validators: stellarTomlValidators("example.com", 3, false)},
+			expectedError: "validator example_com_1 of CRITICAL quality home domain example.com must publish a history archive",
+		},
+		{
+			name:    "invalid public key",
+			quality: "LOW",
+			resp: &stellartoml.Response{Validators: []stellartoml.Validator{
+				{Alias: "bogus", PublicKey: "GBOGUS"},
+			}},
+			expectedError: "invalid captive core toml: found invalid validator entry which has an invalid PUBLIC_KEY : bogus",
+		},
+		{
+			name:          "invalid quality",
+			quality:       "BEST",
+			resp:          &stellartoml.Response{Validators: stellarTomlValidators("example.com", 1, true)},
+			expectedError: "invalid captive core toml: found invalid home domain entry which has an invalid QUALITY value: example.com",
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			client := &stellartoml.MockClient{}
+			client.On("GetStellarToml", "example.com").Return(testCase.resp, testCase.err)
+			_, err := GenerateCaptiveCoreToml(
+				client,
+				[]HomeDomain{{HomeDomain: "example.com", Quality: testCase.quality}},
+				params,
+			)
+			assert.EqualError(t, err, testCase.expectedError)
+		})
+	}
+
+	_, err := GenerateCaptiveCoreToml(&stellartoml.MockClient{}, nil, params)
+	assert.EqualError(t, err, "no home domains")
+}
+
+func TestDiffValidators(t *testing.T) {
+	params := CaptiveCoreTomlParams{NetworkPassphrase: network.PublicNetworkPassphrase}
+	existing, err := NewCaptiveCoreTomlFromData([]byte(`
+[[HOME_DOMAINS]]
+HOME_DOMAIN="a.example.com"
+QUALITY="MEDIUM"
+
+[[HOME_DOMAINS]]
+HOME_DOMAIN="b.example.com"
+QUALITY="LOW"
+
+[[VALIDATORS]]
+NAME="a1"
+HOME_DOMAIN="a.example.com"
+PUBLIC_KEY="GCGB2S2KGYARPVIA37HYZXVRM2YZUEXA6S33ZU5BUDC6THSB62LZSTYH"
+ADDRESS="a1.example.com:11625"
+
+[[VALIDATORS]]
+NAME="b1"
+HOME_DOMAIN="b.example.com"
+PUBLIC_KEY="GCM6QMP3DLRPTAZW2UZPCPX2LF3SXWXKPMP3GKFZBDSF3QZGV2G5QSTK"
+`), params)
+	require.NoError(t, err)
+	updated, err := NewCaptiveCoreTomlFromData([]byte(`
+[[HOME_DOMAINS]]
+HOME_DOMAIN="a.example.com"
+QUALITY="HIGH"
+
+[[HOME_DOMAINS]]
+HOME_DOMAIN="c.example.com"
+QUALITY="LOW"
+
+[[VALIDATORS]]
+NAME="a1"
+HOME_DOMAIN="a.example.com"
+PUBLIC_KEY="GCGB2S2KGYARPVIA37HYZXVRM2YZUEXA6S33ZU5BUDC6THSB62LZSTYH"
+ADDRESS="a1.example.com:11626"
+HISTORY="curl -sf https://a1.example.com/{0} -o {1}"
+
+[[VALIDATORS]]
+NAME="c1"
+HOME_DOMAIN="c.example.com"
+PUBLIC_KEY="GABMKJM6I25XI4K7U6XWMULOUQIQ27BCTMLS6BYYSOWKTBUXVRJSXHYQ"
+`), params)
+	require.NoError(t, err)
+
+	diff := existing.DiffValidators(updated)
+	assert.False(t, diff.IsEmpty())
+	assert.Equal(t, []HomeDomain{{HomeDomain: "c.example.com", Quality: "LOW"}}, diff.AddedHomeDomains)
+	assert.Equal(t, []HomeDomain{{HomeDomain: "b.example.com", Quality: "LOW"}}, diff.RemovedHomeDomains)
+	assert.Len(t, diff.ChangedHomeDomains, 1)
+	assert.Len(t, diff.AddedValidators, 1)
+	assert.Len(t, diff.RemovedValidators, 1)
+	assert.Len(t, diff.ChangedValidators, 1)
+	assert.Equal(t,
+		"+ HOME_DOMAIN c.example.com QUALITY=LOW\n"+
+			"- HOME_DOMAIN b.example.com QUALITY=LOW\n"+
+			"~ HOME_DOMAIN a.example.com QUALITY=MEDIUM -> HIGH\n"+
+			"+ VALIDATOR c1 GABMKJM6I25XI4K7U6XWMULOUQIQ27BCTMLS6BYYSOWKTBUXVRJSXHYQ HOME_DOMAIN=c.example.com\n"+
+			"- VALIDATOR b1 GCM6QMP3DLRPTAZW2UZPCPX2LF3SXWXKPMP3GKFZBDSF3QZGV2G5QSTK HOME_DOMAIN=b.example.com\n"+
+			"~ VALIDATOR a1 GCGB2S2KGYARPVIA37HYZXVRM2YZUEXA6S33ZU5BUDC6THSB62LZSTYH"+
+			` ADDRESS="a1.example.com:11625" -> "a1.example.com:11626"`+
+			` HISTORY="" -> "curl -sf https://a1.example.com/{0} -o {1}"`+"\n",
+		diff.String(),
+	)
+
+	assert.True(t, existing.DiffValidators(existing).IsEmpty())
+	assert.Empty(t, existing.DiffValidators(existing).String())
+}
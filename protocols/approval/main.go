@@ -0,0 +1,72 @@
+// Package approval contains the request and response types of the SEP-8
+// regulated assets approval protocol.
+// https://github.com/stellar/stellar-protocol/blob/master/ecosystem/sep-0008.md
+package approval
+
+// Statuses of an approval server response.
+const (
+	// StatusSuccess means the transaction was approved and signed as is.
+	StatusSuccess = "success"
+	// StatusRevised means the transaction was revised to be compliant, for
+	// example by adding authorization operations, and signed.
+	StatusRevised = "revised"
+	// StatusPending means the issuer could not decide yet and the same
+	// transaction should be submitted again after Timeout milliseconds.
+	StatusPending = "pending"
+	// StatusActionRequired means the user must complete an action, like
+	// providing KYC information, before the transaction can be approved.
+	StatusActionRequired = "action_required"
+	// StatusRejected means the transaction was rejected.
+	StatusRejected = "rejected"
+)
+
+// Request is the json request to an approval server.
+type Request struct {
+	// Tx is the base64 encoded transaction envelope to approve.
+	Tx string `json:"tx"`
+}
+
+// Response is the json response of an approval server. Servers respond with
+// 400 to rejected transactions and 200 otherwise.
+type Response struct {
+	Status string `json:"status"`
+
+	// Tx is the approved transaction envelope of success and revised
+	// responses.
+	Tx string `json:"tx,omitempty"`
+
+	// Message is a human readable explanation of the response.
+	Message string `json:"message,omitempty"`
+
+	// Timeout is the number of milliseconds to wait before submitting a
+	// pending transaction again, or zero if it is unknown.
+	Timeout int64 `json:"timeout,omitempty"`
+
+	// ActionURL, ActionMethod and ActionFields describe the action of an
+	// action_required response. ActionMethod is GET or POST; with GET the
+	// user opens ActionURL in a browser.
+	ActionURL    string   `json:"action_url,omitempty"`
+	ActionMethod string   `json:"action_method,omitempty"`
+	ActionFields []string `json:"action_fields,omitempty"`
+
+	// Error is the reason a transaction was rejected.
+	Error string `json:"error,omitempty"`
+}
+
+// Results of a POST to the action URL of an action_required response.
+const (
+	// ActionResultNoFurtherActionRequired means the transaction can be
+	// submitted for approval again.
+	ActionResultNoFurtherActionRequired = "no_further_action_required"
+	// ActionResultFollowNextURL means the user must complete the action in
+	// a browser at NextURL.
+	ActionResultFollowNextURL = "follow_next_url"
+)
+
+// ActionResponse is the json response of an approval server to a POST to
+// the action URL.
+type ActionResponse struct {
+	Result  string `json:"result"`
+	NextURL string `json:"next_url,omitempty"`
+	Message string `json:"message,omitempty"`
+}
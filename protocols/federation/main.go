@@ -0,0 +1,63 @@
+// Package federation contains the request and response types of the SEP-2
+// federation protocol.
+// https://github.com/stellar/stellar-protocol/blob/master/ecosystem/sep-0002.md
+package federation
+
+import "encoding/json"
+
+// Lookup types accepted in the type query parameter of a federation request.
+const (
+	TypeName    = "name"
+	TypeID      = "id"
+	TypeTxID    = "txid"
+	TypeForward = "forward"
+)
+
+// Memo types of a federation response.
+const (
+	MemoTypeText = "text"
+	MemoTypeID   = "id"
+	MemoTypeHash = "hash"
+)
+
+// Response is the json response of a federation server to a name or id
+// lookup.
+type Response struct {
+	StellarAddress string `json:"stellar_address,omitempty"`
+	AccountID      string `json:"account_id"`
+	MemoType       string `json:"memo_type,omitempty"`
+	Memo           Memo   `json:"memo"`
+}
+
+// Memo is the memo value of a federation response. SEP-2 requires it to be
+// a string, but some servers send id memos as numbers, which are accepted
+// too.
+type Memo struct {
+	Value string
+}
+
+// MarshalJSON implements json.Marshaler.
+func (m Memo) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.Value)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (m *Memo) UnmarshalJSON(data []byte) error {
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err == nil {
+		m.Value = number.String()
+		return nil
+	}
+	return json.Unmarshal(data, &m.Value)
+}
+
+// String returns the memo value.
+func (m Memo) String() string {
+	return m.Value
+}
+
+// ErrorResponse is the json response of a federation server to a request
+// which failed.
+type ErrorResponse struct {
+	Detail string `json:"detail"`
+}
@@ -0,0 +1,40 @@
+package stellarcore
+
+// Modes of stellar-core's /generateload endpoint.
+const (
+	GenerateLoadModeCreate        = "create"
+	GenerateLoadModePay           = "pay"
+	GenerateLoadModePretend       = "pretend"
+	GenerateLoadModeMixedClassic  = "mixed_classic"
+	GenerateLoadModeSorobanUpload = "soroban_upload"
+	GenerateLoadModeSorobanInvoke = "soroban_invoke"
+)
+
+// GenerateLoadRequest holds the parameters of stellar-core's /generateload
+// endpoint, which is only available on nodes built with tests enabled and
+// ARTIFICIALLY_GENERATE_LOAD_FOR_TESTING set. Zero values are omitted so
+// stellar-core's defaults apply.
+type GenerateLoadRequest struct {
+	Mode string
+	// Accounts is the number of accounts created or used.
+	Accounts uint32
+	// Offset is the index of the first account used.
+	Offset uint32
+	// Txs is the number of transactions submitted.
+	Txs uint32
+	// TxRate is the number of transactions submitted per second.
+	TxRate uint32
+	// SpikeSize and SpikeInterval add bursts of SpikeSize transactions
+	// every SpikeInterval seconds.
+	SpikeSize     uint32
+	SpikeInterval uint32
+	// MaxFeeRate randomizes the fee rate of transactions up to it.
+	MaxFeeRate    uint32
+	SkipLowFeeTxs bool
+}
+
+// GenerateLoadResponse is the json response returned from stellar-core's
+// /generateload endpoint.
+type GenerateLoadResponse struct {
+	Status string `json:"status"`
+}
@@ -0,0 +1,75 @@
+package stellarcore
+
+// SurveyTopologyRequest holds the parameters of stellar-core's legacy
+// /surveytopology endpoint.
+type SurveyTopologyRequest struct {
+	// Node is the node to survey.
+	Node string
+	// Duration is the number of seconds the survey runs.
+	Duration int
+}
+
+// SurveyTopologyTimeSlicedRequest holds the parameters of stellar-core's
+// /surveytopologytimesliced endpoint. Peers are reported in pages starting
+// at the given indexes.
+type SurveyTopologyTimeSlicedRequest struct {
+	// Node is the node to survey.
+	Node              string
+	InboundPeerIndex  int
+	OutboundPeerIndex int
+}
+
+// SurveyResultResponse is the json response returned from stellar-core's
+// /getsurveyresult endpoint.
+type SurveyResultResponse struct {
+	SurveyInProgress bool `json:"surveyInProgress"`
+	// Backlog lists the nodes which have not been surveyed yet.
+	Backlog []string `json:"backlog"`
+	// BadResponseNodes lists the nodes which responded with invalid data.
+	BadResponseNodes []string `json:"badResponseNodes"`
+	// Topology holds the results of the surveyed nodes by node id.
+	Topology map[string]SurveyNode `json:"topology"`
+}
+
+// SurveyNode is the survey result of a node. The totals are set by the
+// legacy survey; the time sliced survey sets the other statistics.
+type SurveyNode struct {
+	InboundPeers  []SurveyPeer `json:"inboundPeers"`
+	OutboundPeers []SurveyPeer `json:"outboundPeers"`
+
+	NumTotalInboundPeers  int `json:"numTotalInboundPeers,omitempty"`
+	NumTotalOutboundPeers int `json:"numTotalOutboundPeers,omitempty"`
+
+	TotalInboundPeerCount      int    `json:"totalInboundPeerCount,omitempty"`
+	TotalOutboundPeerCount     int    `json:"totalOutboundPeerCount,omitempty"`
+	MaxInboundPeerCount        int    `json:"maxInboundPeerCount,omitempty"`
+	MaxOutboundPeerCount       int    `json:"maxOutboundPeerCount,omitempty"`
+	AddedAuthenticatedPeers    int    `json:"addedAuthenticatedPeers,omitempty"`
+	DroppedAuthenticatedPeers  int    `json:"droppedAuthenticatedPeers,omitempty"`
+	P75SCPFirstToSelfLatencyMs uint64 `json:"p75SCPFirstToSelfLatencyMs,omitempty"`
+	P75SCPSelfToOtherLatencyMs uint64 `json:"p75SCPSelfToOtherLatencyMs,omitempty"`
+	LostSyncCount              int    `json:"lostSyncCount,omitempty"`
+	IsValidator                bool   `json:"isValidator,omitempty"`
+}
+
+// SurveyPeer is a peer connection of a surveyed node.
+type SurveyPeer struct {
+	NodeID           string `json:"nodeId"`
+	Version          string `json:"version"`
+	SecondsConnected uint64 `json:"secondsConnected"`
+	AverageLatencyMs uint64 `json:"averageLatencyMs,omitempty"`
+
+	MessagesRead    uint64 `json:"messagesRead"`
+	MessagesWritten uint64 `json:"messagesWritten"`
+	BytesRead       uint64 `json:"bytesRead"`
+	BytesWritten    uint64 `json:"bytesWritten"`
+
+	UniqueFloodBytesRecv      uint64 `json:"uniqueFloodBytesRecv"`
+	DuplicateFloodBytesRecv   uint64 `json:"duplicateFloodBytesRecv"`
+	UniqueFetchBytesRecv      uint64 `json:"uniqueFetchBytesRecv"`
+	DuplicateFetchBytesRecv   uint64 `json:"duplicateFetchBytesRecv"`
+	UniqueFloodMessageRecv    uint64 `json:"uniqueFloodMessageRecv"`
+	DuplicateFloodMessageRecv uint64 `json:"duplicateFloodMessageRecv"`
+	UniqueFetchMessageRecv    uint64 `json:"uniqueFetchMessageRecv"`
+	DuplicateFetchMessageRecv uint64 `json:"duplicateFetchMessageRecv"`
+}
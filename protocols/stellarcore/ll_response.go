@@ -0,0 +1,25 @@
+package stellarcore
+
+// Log levels of stellar-core's /ll endpoint.
+const (
+	LogLevelFatal   = "FATAL"
+	LogLevelError   = "ERROR"
+	LogLevelWarning = "WARNING"
+	LogLevelInfo    = "INFO"
+	LogLevelDebug   = "DEBUG"
+	LogLevelTrace   = "TRACE"
+)
+
+// LogLevelRequest holds the parameters of stellar-core's /ll endpoint to
+// change a log level.
+type LogLevelRequest struct {
+	Level string
+	// Partition is the log partition to change, for example "Overlay". All
+	// partitions are changed if it is empty.
+	Partition string
+}
+
+// LogLevelsResponse is the json response returned from stellar-core's /ll
+// endpoint, mapping log partitions to their level. The global level is
+// under the "Global" partition.
+type LogLevelsResponse map[string]string
@@ -0,0 +1,46 @@
+package stellarcore
+
+// Types of the metrics of stellar-core's /metrics response.
+const (
+	MetricTypeCounter   = "counter"
+	MetricTypeMeter     = "meter"
+	MetricTypeTimer     = "timer"
+	MetricTypeHistogram = "histogram"
+	MetricTypeBuckets   = "buckets"
+)
+
+// MetricsResponse is the json response returned from stellar-core's
+// /metrics endpoint.
+type MetricsResponse struct {
+	// Metrics is keyed by metric name, for example "ledger.ledger.close".
+	Metrics map[string]Metric `json:"metrics"`
+}
+
+// Metric is a single metric of stellar-core. Which fields are set depends on
+// Type: counters only have Count, meters add the rates, and timers and
+// histograms add the distribution.
+type Metric struct {
+	Type  string `json:"type"`
+	Count int64  `json:"count"`
+
+	EventType      string  `json:"event_type,omitempty"`
+	RateUnit       string  `json:"rate_unit,omitempty"`
+	MeanRate       float64 `json:"mean_rate,omitempty"`
+	OneMinRate     float64 `json:"1_min_rate,omitempty"`
+	FiveMinRate    float64 `json:"5_min_rate,omitempty"`
+	FifteenMinRate float64 `json:"15_min_rate,omitempty"`
+
+	DurationUnit string  `json:"duration_unit,omitempty"`
+	Min          float64 `json:"min,omitempty"`
+	Max          float64 `json:"max,omitempty"`
+	Mean         float64 `json:"mean,omitempty"`
+	StdDev       float64 `json:"stddev,omitempty"`
+	Sum          float64 `json:"sum,omitempty"`
+	Median       float64 `json:"median,omitempty"`
+	P75          float64 `json:"75%,omitempty"`
+	P95          float64 `json:"95%,omitempty"`
+	P98          float64 `json:"98%,omitempty"`
+	P99          float64 `json:"99%,omitempty"`
+	P999         float64 `json:"99.9%,omitempty"`
+	P100         float64 `json:"100%,omitempty"`
+}
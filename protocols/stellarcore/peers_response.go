@@ -0,0 +1,50 @@
+package stellarcore
+
+// PeersRequest holds the parameters of stellar-core's /peers endpoint.
+type PeersRequest struct {
+	// FullKeys requests full node ids instead of abbreviated ones.
+	FullKeys bool
+	// Compact omits the per peer flow control and message statistics.
+	Compact bool
+}
+
+// PeersResponse is the json response returned from stellar-core's /peers
+// endpoint.
+type PeersResponse struct {
+	AuthenticatedPeers AuthenticatedPeers `json:"authenticated_peers"`
+	PendingPeers       PendingPeers       `json:"pending_peers"`
+}
+
+// AuthenticatedPeers lists the peers which completed the handshake with
+// stellar-core.
+type AuthenticatedPeers struct {
+	Inbound  []Peer `json:"inbound"`
+	Outbound []Peer `json:"outbound"`
+}
+
+// PendingPeers lists the addresses of the peers which are still connecting
+// to stellar-core.
+type PendingPeers struct {
+	Inbound  []string `json:"inbound"`
+	Outbound []string `json:"outbound"`
+}
+
+// Peer is an authenticated peer of stellar-core's /peers response.
+type Peer struct {
+	Address string `json:"address"`
+	ID      string `json:"id"`
+	// Elapsed is the number of seconds the peer has been connected.
+	Elapsed int64 `json:"elapsed"`
+	// Latency is the round trip time to the peer in milliseconds.
+	Latency         int64  `json:"latency"`
+	Version         string `json:"ver"`
+	OverlayVersion  int    `json:"olver"`
+	MessageRead     int64  `json:"message_read,omitempty"`
+	MessageWrite    int64  `json:"message_write,omitempty"`
+	ByteRead        int64  `json:"byte_read,omitempty"`
+	ByteWrite       int64  `json:"byte_write,omitempty"`
+	UniqueFloodRecv int64  `json:"unique_flood_bytes_recv,omitempty"`
+	DupFloodRecv    int64  `json:"duplicate_flood_bytes_recv,omitempty"`
+	UniqueFetchRecv int64  `json:"unique_fetch_bytes_recv,omitempty"`
+	DupFetchRecv    int64  `json:"duplicate_fetch_bytes_recv,omitempty"`
+}
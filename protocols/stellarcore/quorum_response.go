@@ -0,0 +1,121 @@
+package stellarcore
+
+import (
+	"encoding/json"
+
+	"github.com/stellar/go/support/errors"
+)
+
+// QuorumRequest holds the parameters of stellar-core's /quorum endpoint.
+type QuorumRequest struct {
+	// Node is the node whose quorum set is returned. The local node is used
+	// if it is empty.
+	Node string
+	// FullKeys requests full node ids instead of abbreviated ones.
+	FullKeys bool
+	// Transitive adds the result of the transitive quorum intersection
+	// analysis of the network to the response.
+	Transitive bool
+}
+
+// QuorumResponse is the json response returned from stellar-core's /quorum
+// endpoint.
+type QuorumResponse struct {
+	Node string     `json:"node"`
+	QSet QuorumInfo `json:"qset"`
+	// Transitive is only present if requested with QuorumRequest.Transitive.
+	Transitive *TransitiveQuorumInfo `json:"transitive,omitempty"`
+}
+
+// QuorumInfo is the state of the quorum set of a node for the last ledger
+// it closed.
+type QuorumInfo struct {
+	Ledger    uint32 `json:"ledger"`
+	Phase     string `json:"phase"`
+	Hash      string `json:"hash"`
+	Validated bool   `json:"validated"`
+	// LagMs is the time in milliseconds each node of the quorum set lagged
+	// behind the local node, by node id. stellar-core only reports it this
+	// way for non-compact requests, which the client always makes.
+	LagMs map[string]int64 `json:"lag_ms"`
+	// Agree is the number of nodes agreeing with the local node.
+	Agree    int      `json:"agree"`
+	Delayed  []string `json:"delayed"`
+	Disagree []string `json:"disagree"`
+	Missing  []string `json:"missing"`
+	// FailAt is the number of nodes which can fail before the quorum set
+	// is blocked, for example FailWith.
+	FailAt   int       `json:"fail_at"`
+	FailWith []string  `json:"fail_with"`
+	Value    QuorumSet `json:"value"`
+}
+
+// QuorumSet is a quorum set in stellar-core's json format, where validators
+// and inner sets share the "v" list.
+type QuorumSet struct {
+	Threshold  int
+	Validators []string
+	InnerSets  []QuorumSet
+}
+
+type quorumSetJSON struct {
+	Threshold int               `json:"t"`
+	Values    []json.RawMessage `json:"v"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (q *QuorumSet) UnmarshalJSON(data []byte) error {
+	var raw quorumSetJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*q = QuorumSet{Threshold: raw.Threshold}
+	for _, value := range raw.Values {
+		var validator string
+		if err := json.Unmarshal(value, &validator); err == nil {
+			q.Validators = append(q.Validators, validator)
+			continue
+		}
+		var inner QuorumSet
+		if err := json.Unmarshal(value, &inner); err != nil {
+			return errors.Wrap(err, "quorum set value is neither a validator nor a quorum set")
+		}
+		q.InnerSets = append(q.InnerSets, inner)
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (q QuorumSet) MarshalJSON() ([]byte, error) {
+	values := make([]interface{}, 0, len(q.Validators)+len(q.InnerSets))
+	for _, validator := range q.Validators {
+		values = append(values, validator)
+	}
+	for _, inner := range q.InnerSets {
+		values = append(values, inner)
+	}
+	return json.Marshal(struct {
+		Threshold int           `json:"t"`
+		Values    []interface{} `json:"v"`
+	}{q.Threshold, values})
+}
+
+// TransitiveQuorumInfo is the result of stellar-core's analysis of the
+// transitive closure of the quorum sets of the network.
+type TransitiveQuorumInfo struct {
+	// Intersection reports whether all the quorums of the network
+	// intersect.
+	Intersection        bool   `json:"intersection"`
+	NodeCount           int    `json:"node_count"`
+	LastCheckLedger     uint32 `json:"last_check_ledger"`
+	LastCheckInputsHash string `json:"last_check_inputs_hash"`
+	// LastGoodLedger is the last ledger at which the network had quorum
+	// intersection, set when Intersection is false.
+	LastGoodLedger uint32 `json:"last_good_ledger,omitempty"`
+	// PotentialSplit is a pair of disjoint quorums, set when Intersection
+	// is false.
+	PotentialSplit [][]string `json:"potential_split,omitempty"`
+	// Critical lists the groups of nodes which, if misconfigured, could
+	// cause the network to lose quorum intersection.
+	Critical [][]string `json:"critical,omitempty"`
+}
@@ -0,0 +1,42 @@
+package stellarcore
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuorumSetJSON(t *testing.T) {
+	raw := `{"t":2,"v":["GA","GB",{"t":1,"v":["GC",{"t":1,"v":["GD"]}]}]}`
+
+	var qset QuorumSet
+	require.NoError(t, json.Unmarshal([]byte(raw), &qset))
+	assert.Equal(t, QuorumSet{
+		Threshold:  2,
+		Validators: []string{"GA", "GB"},
+		InnerSets: []QuorumSet{{
+			Threshold:  1,
+			Validators: []string{"GC"},
+			InnerSets:  []QuorumSet{{Threshold: 1, Validators: []string{"GD"}}},
+		}},
+	}, qset)
+
+	encoded, err := json.Marshal(qset)
+	require.NoError(t, err)
+	assert.JSONEq(t, raw, string(encoded))
+
+	assert.Error(t, json.Unmarshal([]byte(`{"t":1,"v":[1]}`), &qset))
+}
+
+func TestSCPResponseJSON(t *testing.T) {
+	var resp SCPResponse
+	require.NoError(t, json.Unmarshal([]byte(`{"you":"GA","41":{"index":41},"42":{"index":42,"validated":true}}`), &resp))
+	assert.Equal(t, "GA", resp.You)
+	assert.Len(t, resp.Slots, 2)
+	assert.Equal(t, uint64(41), resp.Slots[41].Index)
+	assert.True(t, resp.Slots[42].Validated)
+
+	assert.EqualError(t, json.Unmarshal([]byte(`{"you":"GA","me":{}}`), &resp), `unexpected scp field "me"`)
+}
@@ -0,0 +1,65 @@
+package stellarcore
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/stellar/go/support/errors"
+)
+
+// SCPRequest holds the parameters of stellar-core's /scp endpoint.
+type SCPRequest struct {
+	// Limit is the number of most recent slots returned. stellar-core
+	// defaults to 2 if it is zero.
+	Limit int
+	// FullKeys requests full node ids instead of abbreviated ones.
+	FullKeys bool
+}
+
+// SCPResponse is the json response returned from stellar-core's /scp
+// endpoint.
+type SCPResponse struct {
+	// You is the id of the local node.
+	You string
+	// Slots holds the SCP state of the most recent slots by ledger
+	// sequence.
+	Slots map[uint32]SCPSlot
+}
+
+// SCPSlot is the SCP state of a slot. The nomination and ballot protocol
+// states are left as raw json since their format depends on the phase.
+type SCPSlot struct {
+	Index          uint64          `json:"index"`
+	Validated      bool            `json:"validated"`
+	Nomination     json.RawMessage `json:"nomination,omitempty"`
+	BallotProtocol json.RawMessage `json:"ballotProtocol,omitempty"`
+	Statements     json.RawMessage `json:"statements,omitempty"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler. stellar-core keys the slots by
+// their ledger sequence next to the "you" field.
+func (r *SCPResponse) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*r = SCPResponse{Slots: map[uint32]SCPSlot{}}
+	for key, value := range raw {
+		if key == "you" {
+			if err := json.Unmarshal(value, &r.You); err != nil {
+				return errors.Wrap(err, "invalid you field")
+			}
+			continue
+		}
+		seq, err := strconv.ParseUint(key, 10, 32)
+		if err != nil {
+			return errors.Errorf("unexpected scp field %q", key)
+		}
+		var slot SCPSlot
+		if err := json.Unmarshal(value, &slot); err != nil {
+			return errors.Wrapf(err, "invalid slot %s", key)
+		}
+		r.Slots[uint32(seq)] = slot
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package stellarcore
+
+// SorobanInfoResponse is the json response returned from stellar-core's
+// /sorobaninfo endpoint in the basic format.
+type SorobanInfoResponse struct {
+	MaxContractSize          uint32 `json:"max_contract_size"`
+	MaxContractDataKeySize   uint32 `json:"max_contract_data_key_size"`
+	MaxContractDataEntrySize uint32 `json:"max_contract_data_entry_size"`
+
+	Tx     SorobanTxLimits     `json:"tx"`
+	Ledger SorobanLedgerLimits `json:"ledger"`
+
+	FeeRatePerInstructionsIncrement int64 `json:"fee_rate_per_instructions_increment"`
+	FeeReadLedgerEntry              int64 `json:"fee_read_ledger_entry"`
+	FeeWriteLedgerEntry             int64 `json:"fee_write_ledger_entry"`
+	FeeRead1KB                      int64 `json:"fee_read_1kb"`
+	FeeWrite1KB                     int64 `json:"fee_write_1kb"`
+	FeeHistorical1KB                int64 `json:"fee_historical_1kb"`
+	FeeContractEvents1KB            int64 `json:"fee_contract_events_size_1kb"`
+	FeeTransactionSize1KB           int64 `json:"fee_transaction_size_1kb"`
+
+	StateArchival SorobanStateArchival `json:"state_archival"`
+}
+
+// SorobanTxLimits are the resource limits of a single Soroban transaction.
+type SorobanTxLimits struct {
+	MaxInstructions            int64  `json:"max_instructions"`
+	MemoryLimit                uint32 `json:"memory_limit"`
+	MaxReadLedgerEntries       uint32 `json:"max_read_ledger_entries"`
+	MaxReadBytes               uint32 `json:"max_read_bytes"`
+	MaxWriteLedgerEntries      uint32 `json:"max_write_ledger_entries"`
+	MaxWriteBytes              uint32 `json:"max_write_bytes"`
+	MaxContractEventsSizeBytes uint32 `json:"max_contract_events_size_bytes"`
+	MaxSizeBytes               uint32 `json:"max_size_bytes"`
+}
+
+// SorobanLedgerLimits are the resource limits of the Soroban transactions
+// of a ledger.
+type SorobanLedgerLimits struct {
+	MaxInstructions       int64  `json:"max_instructions"`
+	MaxReadLedgerEntries  uint32 `json:"max_read_ledger_entries"`
+	MaxReadBytes          uint32 `json:"max_read_bytes"`
+	MaxWriteLedgerEntries uint32 `json:"max_write_ledger_entries"`
+	MaxWriteBytes         uint32 `json:"max_write_bytes"`
+	MaxTxSizeBytes        uint32 `json:"max_tx_size_bytes"`
+	MaxTxCount            uint32 `json:"max_tx_count"`
+}
+
+// SorobanStateArchival are the state archival settings of the network.
+type SorobanStateArchival struct {
+	MaxEntryTTL                    uint32 `json:"max_entry_ttl"`
+	MinTemporaryTTL                uint32 `json:"min_temporary_ttl"`
+	MinPersistentTTL               uint32 `json:"min_persistent_ttl"`
+	PersistentRentRateDenominator  int64  `json:"persistent_rent_rate_denominator"`
+	TempRentRateDenominator        int64  `json:"temp_rent_rate_denominator"`
+	MaxEntriesToArchive            uint32 `json:"max_entries_to_archive"`
+	BucketListSizeWindowSampleSize uint32 `json:"bucketlist_size_window_sample_size"`
+	EvictionScanSize               uint64 `json:"eviction_scan_size"`
+	StartingEvictionScanLevel      uint32 `json:"starting_eviction_scan_level"`
+}
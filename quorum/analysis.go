@@ -0,0 +1,477 @@
+package quorum
+
+import (
+	"math/bits"
+	"sort"
+	"strings"
+
+	"github.com/stellar/go/support/errors"
+)
+
+// Options configures the analysis of a network.
+type Options struct {
+	// MaxSetSize is the number of organizations of the largest blocking and
+	// splitting sets searched. Zero searches sets of any size.
+	MaxSetSize int
+}
+
+// Report is the result of the analysis of a network.
+type Report struct {
+	// Intersection is true if every two quorums of the network intersect.
+	Intersection bool `json:"intersection"`
+
+	// Split holds the names of the validators of two disjoint quorums when
+	// Intersection is false.
+	Split [][]string `json:"split,omitempty"`
+
+	// Quorum holds the names of the validators of the largest quorum of the
+	// network. It is empty when the network cannot reach consensus.
+	Quorum []string `json:"quorum"`
+
+	// BlockingSets holds the minimal sets of organizations whose failure
+	// leaves the network without any quorum, halting it.
+	BlockingSets [][]string `json:"blocking_sets"`
+
+	// SplittingSets holds the minimal sets of organizations which, by
+	// acting maliciously, can make two quorums without any other validator
+	// in common, forking the network. An empty set means the network can
+	// fork without any malicious organization.
+	SplittingSets [][]string `json:"splitting_sets"`
+}
+
+// MinBlockingSetSize returns the number of organizations of the smallest
+// blocking set, or zero if none was found.
+func (r *Report) MinBlockingSetSize() int {
+	return minSize(r.BlockingSets)
+}
+
+// MinSplittingSetSize returns the number of organizations of the smallest
+// splitting set, or zero if none was found.
+func (r *Report) MinSplittingSetSize() int {
+	return minSize(r.SplittingSets)
+}
+
+func minSize(sets [][]string) int {
+	size := 0
+	for i, set := range sets {
+		if i == 0 || len(set) < size {
+			size = len(set)
+		}
+	}
+	return size
+}
+
+// Analyze checks the quorum intersection of the network and finds its
+// minimal blocking and splitting sets of organizations.
+func (n *Network) Analyze(options Options) (*Report, error) {
+	if options.MaxSetSize < 0 {
+		return nil, errors.New("max set size must not be negative")
+	}
+	a, err := newAnalysis(n)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		Quorum:        []string{},
+		BlockingSets:  [][]string{},
+		SplittingSets: [][]string{},
+	}
+	empty := a.newSet()
+	q1, q2, split := a.findSplit(empty)
+	report.Intersection = !split
+	if split {
+		report.Split = [][]string{a.names(q1), a.names(q2)}
+	}
+
+	quorum := a.maxQuorum(a.all, empty)
+	report.Quorum = a.names(quorum)
+	if quorum.empty() {
+		// Nothing needs to fail to halt a network without quorums and it
+		// cannot fork.
+		report.BlockingSets = [][]string{{}}
+		return report, nil
+	}
+
+	// Only the organizations of the largest quorum, and those the quorum
+	// sets of its validators depend on, can block or split the network.
+	candidates := a.orgsOf(quorum)
+	for _, v := range quorum.members() {
+		if a.qsets[v] != nil {
+			a.qsets[v].addValidators(candidates)
+		}
+	}
+	orgs := a.orgIndexes(candidates)
+
+	blocking := minimalSets(len(orgs), options.MaxSetSize, func(combination []int) bool {
+		failed := a.orgNodes(orgs, combination)
+		return a.maxQuorum(a.all.minus(failed), empty).empty()
+	})
+	for _, set := range blocking {
+		report.BlockingSets = append(report.BlockingSets, a.orgNames(orgs, set))
+	}
+
+	splitting := minimalSets(len(orgs), options.MaxSetSize, func(combination []int) bool {
+		_, _, split := a.findSplit(a.orgNodes(orgs, combination))
+		return split
+	})
+	for _, set := range splitting {
+		report.SplittingSets = append(report.SplittingSets, a.orgNames(orgs, set))
+	}
+	return report, nil
+}
+
+// minimalSets returns the combinations of n elements, by increasing size up
+// to maxSize if positive, for which test is true and which do not contain a
+// smaller combination for which it is.
+func minimalSets(n, maxSize int, test func([]int) bool) [][]int {
+	if maxSize <= 0 || maxSize > n {
+		maxSize = n
+	}
+	var found [][]int
+	for size := 0; size <= maxSize; size++ {
+		combination := make([]int, size)
+		for i := range combination {
+			combination[i] = i
+		}
+		for {
+			if !containsAny(combination, found) && test(combination) {
+				found = append(found, append([]int{}, combination...))
+			}
+			// Advance to the next combination in lexicographic order.
+			i := size - 1
+			for i >= 0 && combination[i] == n-size+i {
+				i--
+			}
+			if i < 0 {
+				break
+			}
+			combination[i]++
+			for j := i + 1; j < size; j++ {
+				combination[j] = combination[j-1] + 1
+			}
+		}
+	}
+	return found
+}
+
+// containsAny returns whether the sorted combination contains one of sets.
+func containsAny(combination []int, sets [][]int) bool {
+	for _, set := range sets {
+		i := 0
+		for _, c := range combination {
+			if i < len(set) && set[i] == c {
+				i++
+			}
+		}
+		if i == len(set) {
+			return true
+		}
+	}
+	return false
+}
+
+// nodeSet is a set of validators by index.
+type nodeSet []uint64
+
+func (s nodeSet) add(i int)      { s[i/64] |= 1 << (i % 64) }
+func (s nodeSet) has(i int) bool { return s[i/64]&(1<<(i%64)) != 0 }
+
+func (s nodeSet) remove(i int) { s[i/64] &^= 1 << (i % 64) }
+
+func (s nodeSet) union(o nodeSet) nodeSet {
+	r := make(nodeSet, len(s))
+	for i := range s {
+		r[i] = s[i] | o[i]
+	}
+	return r
+}
+
+func (s nodeSet) minus(o nodeSet) nodeSet {
+	r := make(nodeSet, len(s))
+	for i := range s {
+		r[i] = s[i] &^ o[i]
+	}
+	return r
+}
+
+func (s nodeSet) equal(o nodeSet) bool {
+	for i := range s {
+		if s[i] != o[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s nodeSet) empty() bool {
+	for _, w := range s {
+		if w != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (s nodeSet) members() []int {
+	var members []int
+	for i, w := range s {
+		for w != 0 {
+			b := bits.TrailingZeros64(w)
+			members = append(members, i*64+b)
+			w &^= 1 << b
+		}
+	}
+	return members
+}
+
+// qset is a quorum set with validators by index.
+type qset struct {
+	threshold  int
+	validators []int
+	inner      []*qset
+}
+
+func (q *qset) satisfiedBy(s nodeSet) bool {
+	count := 0
+	for _, v := range q.validators {
+		if s.has(v) {
+			count++
+		}
+	}
+	for _, inner := range q.inner {
+		if count >= q.threshold {
+			break
+		}
+		if inner.satisfiedBy(s) {
+			count++
+		}
+	}
+	return count >= q.threshold
+}
+
+func (q *qset) addValidators(s nodeSet) {
+	for _, v := range q.validators {
+		s.add(v)
+	}
+	for _, inner := range q.inner {
+		inner.addValidators(s)
+	}
+}
+
+// analysis holds a network compiled for the analysis.
+type analysis struct {
+	network *Network
+	nodes   []string
+	// qsets holds the quorum set of each node, nil for nodes without one.
+	qsets []*qset
+	all   nodeSet
+	// orgs holds the sorted organizations and org the index of the
+	// organization of each node.
+	orgs []string
+	org  []int
+}
+
+func newAnalysis(n *Network) (*analysis, error) {
+	a := &analysis{network: n, nodes: n.nodes()}
+	index := make(map[string]int, len(a.nodes))
+	for i, node := range a.nodes {
+		index[node] = i
+	}
+
+	a.all = a.newSet()
+	a.qsets = make([]*qset, len(a.nodes))
+	for i, node := range a.nodes {
+		a.all.add(i)
+		if q, ok := n.QuorumSets[node]; ok {
+			compiled, err := compile(q, index)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid quorum set of %s", node)
+			}
+			a.qsets[i] = compiled
+		}
+	}
+
+	orgIndex := map[string]int{}
+	for _, node := range a.nodes {
+		orgIndex[n.organization(node)] = 0
+	}
+	for org := range orgIndex {
+		a.orgs = append(a.orgs, org)
+	}
+	sort.Strings(a.orgs)
+	for i, org := range a.orgs {
+		orgIndex[org] = i
+	}
+	a.org = make([]int, len(a.nodes))
+	for i, node := range a.nodes {
+		a.org[i] = orgIndex[n.organization(node)]
+	}
+	return a, nil
+}
+
+func compile(q QuorumSet, index map[string]int) (*qset, error) {
+	size := len(q.Validators) + len(q.InnerSets)
+	if q.Threshold < 1 || q.Threshold > size {
+		return nil, errors.Errorf("threshold %d out of range for %d members", q.Threshold, size)
+	}
+	compiled := &qset{threshold: q.Threshold}
+	for _, validator := range q.Validators {
+		compiled.validators = append(compiled.validators, index[validator])
+	}
+	for _, inner := range q.InnerSets {
+		innerSet, err := compile(inner, index)
+		if err != nil {
+			return nil, err
+		}
+		compiled.inner = append(compiled.inner, innerSet)
+	}
+	return compiled, nil
+}
+
+func (a *analysis) newSet() nodeSet {
+	return make(nodeSet, (len(a.nodes)+63)/64)
+}
+
+// maxQuorum returns the largest quorum within candidates, which is empty if
+// there is none. The byzantine validators are not part of the quorum but
+// satisfy the quorum sets of the others.
+func (a *analysis) maxQuorum(candidates, byzantine nodeSet) nodeSet {
+	quorum := candidates.minus(byzantine)
+	for {
+		present := quorum.union(byzantine)
+		changed := false
+		for _, v := range quorum.members() {
+			if a.qsets[v] == nil || !a.qsets[v].satisfiedBy(present) {
+				quorum.remove(v)
+				changed = true
+			}
+		}
+		if !changed {
+			return quorum
+		}
+	}
+}
+
+// findSplit looks for two quorums without any common validator other than
+// the byzantine ones.
+func (a *analysis) findSplit(byzantine nodeSet) (nodeSet, nodeSet, bool) {
+	return a.searchSplit(a.newSet(), a.maxQuorum(a.all, byzantine), byzantine)
+}
+
+// searchSplit looks for a quorum containing committed and contained in
+// committed and remaining, and a second quorum disjoint from it. It relies
+// on every quorum being a union of minimal quorums, so that only the
+// minimal quorums need to be checked for a disjoint quorum.
+func (a *analysis) searchSplit(committed, remaining, byzantine nodeSet) (nodeSet, nodeSet, bool) {
+	candidates := a.maxQuorum(committed.union(remaining), byzantine)
+	if !committed.minus(candidates).empty() {
+		return nil, nil, false
+	}
+	remaining = candidates.minus(committed)
+
+	if !committed.empty() && a.maxQuorum(committed, byzantine).equal(committed) {
+		other := a.maxQuorum(a.all.minus(committed), byzantine)
+		if !other.empty() {
+			return committed, other, true
+		}
+		// Supersets of a quorum intersecting every other quorum do too.
+		return nil, nil, false
+	}
+
+	members := remaining.members()
+	if len(members) == 0 {
+		return nil, nil, false
+	}
+	v := members[0]
+	withV := committed.union(a.newSet())
+	withV.add(v)
+	withoutV := remaining.minus(withV)
+	if q1, q2, ok := a.searchSplit(withV, withoutV, byzantine); ok {
+		return q1, q2, ok
+	}
+	return a.searchSplit(committed, withoutV, byzantine)
+}
+
+func (a *analysis) names(s nodeSet) []string {
+	names := []string{}
+	for _, v := range s.members() {
+		names = append(names, a.network.name(a.nodes[v]))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// orgsOf returns the validators of the organizations of the validators of s.
+func (a *analysis) orgsOf(s nodeSet) nodeSet {
+	orgs := map[int]bool{}
+	for _, v := range s.members() {
+		orgs[a.org[v]] = true
+	}
+	r := a.newSet()
+	for v := range a.nodes {
+		if orgs[a.org[v]] {
+			r.add(v)
+		}
+	}
+	return r
+}
+
+// orgIndexes returns the sorted indexes of the organizations of s.
+func (a *analysis) orgIndexes(s nodeSet) []int {
+	seen := map[int]bool{}
+	var orgs []int
+	for _, v := range s.members() {
+		if !seen[a.org[v]] {
+			seen[a.org[v]] = true
+			orgs = append(orgs, a.org[v])
+		}
+	}
+	sort.Ints(orgs)
+	return orgs
+}
+
+// orgNodes returns the validators of the organizations of the combination.
+func (a *analysis) orgNodes(orgs, combination []int) nodeSet {
+	selected := map[int]bool{}
+	for _, i := range combination {
+		selected[orgs[i]] = true
+	}
+	r := a.newSet()
+	for v := range a.nodes {
+		if selected[a.org[v]] {
+			r.add(v)
+		}
+	}
+	return r
+}
+
+func (a *analysis) orgNames(orgs, combination []int) []string {
+	names := []string{}
+	for _, i := range combination {
+		names = append(names, a.orgs[orgs[i]])
+	}
+	return names
+}
+
+// String returns a summary of the report.
+func (r *Report) String() string {
+	var b strings.Builder
+	if r.Intersection {
+		b.WriteString("quorum intersection: yes\n")
+	} else {
+		b.WriteString("quorum intersection: no, disjoint quorums:\n")
+		for _, quorum := range r.Split {
+			b.WriteString("  " + strings.Join(quorum, ", ") + "\n")
+		}
+	}
+	b.WriteString("minimal blocking sets:\n")
+	for _, set := range r.BlockingSets {
+		b.WriteString("  {" + strings.Join(set, ", ") + "}\n")
+	}
+	b.WriteString("minimal splitting sets:\n")
+	for _, set := range r.SplittingSets {
+		b.WriteString("  {" + strings.Join(set, ", ") + "}\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,141 @@
+package quorum
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tieredNetwork returns a network of orgs organizations with three
+// validators each, which all require threshold organizations.
+func tieredNetwork(orgs, threshold int) *Network {
+	network := NewNetwork()
+	qset := QuorumSet{Threshold: threshold}
+	for i := 0; i < orgs; i++ {
+		org := QuorumSet{Threshold: 2}
+		for j := 0; j < 3; j++ {
+			node := fmt.Sprintf("%c%d", 'a'+i, j)
+			org.Validators = append(org.Validators, node)
+			network.Organizations[node] = fmt.Sprintf("%c.example.com", 'a'+i)
+		}
+		qset.InnerSets = append(qset.InnerSets, org)
+	}
+	for _, node := range qset.validators() {
+		network.QuorumSets[node] = qset
+	}
+	return network
+}
+
+func TestAnalyzeTieredNetwork(t *testing.T) {
+	report, err := tieredNetwork(4, 3).Analyze(Options{})
+	require.NoError(t, err)
+
+	assert.True(t, report.Intersection)
+	assert.Nil(t, report.Split)
+	assert.Len(t, report.Quorum, 12)
+	pairs := [][]string{
+		{"a.example.com", "b.example.com"},
+		{"a.example.com", "c.example.com"},
+		{"a.example.com", "d.example.com"},
+		{"b.example.com", "c.example.com"},
+		{"b.example.com", "d.example.com"},
+		{"c.example.com", "d.example.com"},
+	}
+	assert.Equal(t, pairs, report.BlockingSets)
+	assert.Equal(t, pairs, report.SplittingSets)
+	assert.Equal(t, 2, report.MinBlockingSetSize())
+	assert.Equal(t, 2, report.MinSplittingSetSize())
+}
+
+func TestAnalyzeMaxSetSize(t *testing.T) {
+	report, err := tieredNetwork(4, 3).Analyze(Options{MaxSetSize: 1})
+	require.NoError(t, err)
+	assert.True(t, report.Intersection)
+	assert.Empty(t, report.BlockingSets)
+	assert.Empty(t, report.SplittingSets)
+
+	_, err = tieredNetwork(4, 3).Analyze(Options{MaxSetSize: -1})
+	assert.EqualError(t, err, "max set size must not be negative")
+}
+
+func TestAnalyzeSplitNetwork(t *testing.T) {
+	// Each half of the network only requires one organization of the other.
+	network := tieredNetwork(4, 2)
+	report, err := network.Analyze(Options{})
+	require.NoError(t, err)
+
+	assert.False(t, report.Intersection)
+	require.Len(t, report.Split, 2)
+	assert.NotEmpty(t, report.Split[0])
+	assert.NotEmpty(t, report.Split[1])
+	assert.NotContains(t, report.Split[1], report.Split[0][0])
+	assert.Equal(t, [][]string{{}}, report.SplittingSets)
+	assert.Equal(t, 0, report.MinSplittingSetSize())
+	assert.Len(t, report.BlockingSets, 4)
+	assert.Equal(t, 3, report.MinBlockingSetSize())
+}
+
+func TestAnalyzeMissingValidators(t *testing.T) {
+	network := NewNetwork()
+	network.Names["a"] = "alpha"
+	network.QuorumSets["a"] = QuorumSet{Threshold: 2, Validators: []string{"a", "b", "c"}}
+	network.QuorumSets["b"] = QuorumSet{Threshold: 2, Validators: []string{"a", "b", "c"}}
+
+	// c has no quorum set so it never takes part in a quorum, but it
+	// splits a from b when it lies to them.
+	report, err := network.Analyze(Options{})
+	require.NoError(t, err)
+	assert.True(t, report.Intersection)
+	assert.Equal(t, []string{"alpha", "b"}, report.Quorum)
+	assert.Equal(t, [][]string{{"alpha"}, {"b"}}, report.BlockingSets)
+	assert.Equal(t, [][]string{{"c"}}, report.SplittingSets)
+
+	// Without b the network cannot reach consensus at all.
+	delete(network.QuorumSets, "b")
+	report, err = network.Analyze(Options{})
+	require.NoError(t, err)
+	assert.True(t, report.Intersection)
+	assert.Empty(t, report.Quorum)
+	assert.Equal(t, [][]string{{}}, report.BlockingSets)
+	assert.Empty(t, report.SplittingSets)
+}
+
+func TestAnalyzeInvalidThreshold(t *testing.T) {
+	network := NewNetwork()
+	network.QuorumSets["a"] = QuorumSet{Threshold: 2, Validators: []string{"a"}}
+
+	_, err := network.Analyze(Options{})
+	assert.EqualError(t, err, "invalid quorum set of a: threshold 2 out of range for 1 members")
+}
+
+func TestMinimalSets(t *testing.T) {
+	// Sets containing 1, or both 0 and 2.
+	sets := minimalSets(4, 0, func(combination []int) bool {
+		has := map[int]bool{}
+		for _, i := range combination {
+			has[i] = true
+		}
+		return has[1] || (has[0] && has[2])
+	})
+	assert.Equal(t, [][]int{{1}, {0, 2}}, sets)
+
+	assert.Equal(t, [][]int{{}}, minimalSets(3, 0, func([]int) bool { return true }))
+	assert.Empty(t, minimalSets(3, 2, func(c []int) bool { return len(c) == 3 }))
+}
+
+func TestReportString(t *testing.T) {
+	report := &Report{
+		Split:         [][]string{{"a"}, {"b"}},
+		BlockingSets:  [][]string{{"a", "b"}},
+		SplittingSets: [][]string{{}},
+	}
+	assert.Equal(t, "quorum intersection: no, disjoint quorums:\n"+
+		"  a\n"+
+		"  b\n"+
+		"minimal blocking sets:\n"+
+		"  {a, b}\n"+
+		"minimal splitting sets:\n"+
+		"  {}\n", report.String())
+}
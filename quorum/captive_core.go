@@ -0,0 +1,192 @@
+package quorum
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/stellar/go/ingest/ledgerbackend"
+	"github.com/stellar/go/support/errors"
+)
+
+// defaultThresholdPercent is the threshold of [QUORUM_SET] tables without
+// THRESHOLD_PERCENT.
+const defaultThresholdPercent = 67
+
+// qualities lists the validator qualities from the highest.
+var qualities = []string{"CRITICAL", "HIGH", "MEDIUM", "LOW"}
+
+// CaptiveCoreQuorumSet returns the quorum set of a captive-core
+// configuration. It is built from the [QUORUM_SET] tables if there are any,
+// otherwise it is generated from the [[VALIDATORS]] entries like
+// stellar-core does.
+func CaptiveCoreQuorumSet(c *ledgerbackend.CaptiveCoreToml) (QuorumSet, error) {
+	tables := c.QuorumSets()
+	if len(tables) > 0 {
+		aliases := map[string]string{}
+		for _, entry := range c.NodeNames {
+			fields := strings.Fields(entry)
+			if len(fields) == 2 {
+				aliases[fields[1]] = fields[0]
+			}
+		}
+		for _, v := range c.Validators {
+			aliases[v.Name] = v.PublicKey
+		}
+		return tableQuorumSet("QUORUM_SET", tables, aliases)
+	}
+	if len(c.Validators) == 0 {
+		return QuorumSet{}, errors.New("quorum set is not configured")
+	}
+	return generateQuorumSet(c)
+}
+
+// NetworkFromCaptiveCoreToml returns the network of the validators of a
+// captive-core configuration, assuming they all use its quorum set.
+// Organizations are the home domains of the [[VALIDATORS]] entries.
+func NetworkFromCaptiveCoreToml(c *ledgerbackend.CaptiveCoreToml) (*Network, error) {
+	qset, err := CaptiveCoreQuorumSet(c)
+	if err != nil {
+		return nil, err
+	}
+	network := NewNetwork()
+	for _, validator := range qset.validators() {
+		network.QuorumSets[validator] = qset
+	}
+	for _, entry := range c.NodeNames {
+		fields := strings.Fields(entry)
+		if len(fields) == 2 {
+			network.Names[fields[0]] = fields[1]
+		}
+	}
+	for _, v := range c.Validators {
+		network.Names[v.PublicKey] = v.Name
+		network.Organizations[v.PublicKey] = v.HomeDomain
+	}
+	return network, nil
+}
+
+// tableQuorumSet builds the quorum set of the [QUORUM_SET] table name,
+// whose inner sets are the tables named after it, like [QUORUM_SET.inner].
+func tableQuorumSet(name string, tables map[string]ledgerbackend.QuorumSet, aliases map[string]string) (QuorumSet, error) {
+	table := tables[name]
+	qset := QuorumSet{}
+	for _, validator := range table.Validators {
+		if alias, ok := strings.CutPrefix(validator, "$"); ok {
+			if alias == "self" {
+				return QuorumSet{}, errors.Errorf("%s: $self is not supported", name)
+			}
+			key, ok := aliases[alias]
+			if !ok {
+				return QuorumSet{}, errors.Errorf("%s: unknown validator %s", name, validator)
+			}
+			validator = key
+		}
+		qset.Validators = append(qset.Validators, validator)
+	}
+
+	var inner []string
+	for table := range tables {
+		if child, ok := strings.CutPrefix(table, name+"."); ok && !strings.Contains(child, ".") {
+			inner = append(inner, table)
+		}
+	}
+	sort.Strings(inner)
+	for _, table := range inner {
+		innerSet, err := tableQuorumSet(table, tables, aliases)
+		if err != nil {
+			return QuorumSet{}, err
+		}
+		qset.InnerSets = append(qset.InnerSets, innerSet)
+	}
+
+	percent := table.ThresholdPercent
+	if percent == 0 {
+		percent = defaultThresholdPercent
+	}
+	if percent < 0 || percent > 100 {
+		return QuorumSet{}, errors.Errorf("%s: invalid THRESHOLD_PERCENT %d", name, percent)
+	}
+	qset.Threshold = thresholdPercent(len(qset.Validators)+len(qset.InnerSets), percent)
+	return qset, nil
+}
+
+// thresholdPercent returns the number of members of a set of the given size
+// which make up the given percentage, rounded up like stellar-core does.
+func thresholdPercent(size, percent int) int {
+	if size == 0 {
+		return 0
+	}
+	return 1 + (size*percent-1)/100
+}
+
+// generateQuorumSet generates the quorum set of the [[VALIDATORS]] entries
+// like stellar-core: each organization is an inner set requiring a simple
+// majority of its validators, and each quality level requires two thirds of
+// its organizations and of the level below, nested as one more inner set.
+// The LOW level only requires a simple majority.
+func generateQuorumSet(c *ledgerbackend.CaptiveCoreToml) (QuorumSet, error) {
+	homeDomainQuality := map[string]string{}
+	for _, hd := range c.HomeDomains {
+		homeDomainQuality[hd.HomeDomain] = hd.Quality
+	}
+
+	// orgs holds the validators of each organization of each quality.
+	orgs := map[string]map[string][]string{}
+	for _, v := range c.Validators {
+		quality := v.Quality
+		if quality == "" {
+			quality = homeDomainQuality[v.HomeDomain]
+		}
+		if quality == "" {
+			return QuorumSet{}, errors.Errorf("validator %s is missing a QUALITY value", v.Name)
+		}
+		if orgs[quality] == nil {
+			orgs[quality] = map[string][]string{}
+		}
+		orgs[quality][v.HomeDomain] = append(orgs[quality][v.HomeDomain], v.PublicKey)
+	}
+
+	var levels []QuorumSet
+	for _, quality := range qualities {
+		if len(orgs[quality]) == 0 {
+			continue
+		}
+		level := QuorumSet{}
+		for _, homeDomain := range sortedKeys(orgs[quality]) {
+			validators := orgs[quality][homeDomain]
+			if (quality == "CRITICAL" || quality == "HIGH") && len(validators) < 3 {
+				return QuorumSet{}, errors.Errorf(
+					"%s quality organization %s must have at least 3 validators", quality, homeDomain,
+				)
+			}
+			level.InnerSets = append(level.InnerSets, QuorumSet{
+				Threshold:  1 + len(validators)/2,
+				Validators: validators,
+			})
+		}
+		levels = append(levels, level)
+	}
+
+	// Nest each level in the level above, from the lowest.
+	for i := len(levels) - 1; i >= 0; i-- {
+		if i < len(levels)-1 {
+			levels[i].InnerSets = append(levels[i].InnerSets, levels[i+1])
+		}
+		size := len(levels[i].InnerSets)
+		if i == len(levels)-1 && len(orgs["LOW"]) > 0 {
+			levels[i].Threshold = 1 + size/2
+		} else {
+			levels[i].Threshold = thresholdPercent(size, defaultThresholdPercent)
+		}
+	}
+	return levels[0], nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
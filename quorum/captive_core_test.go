@@ -0,0 +1,146 @@
+package quorum
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/ingest/ledgerbackend"
+	"github.com/stellar/go/keypair"
+)
+
+func captiveCoreToml(t *testing.T, text string) *ledgerbackend.CaptiveCoreToml {
+	c, err := ledgerbackend.NewCaptiveCoreTomlFromData([]byte(text), ledgerbackend.CaptiveCoreTomlParams{
+		NetworkPassphrase: "Test SDF Network ; September 2015",
+	})
+	require.NoError(t, err)
+	return c
+}
+
+// validators returns [[VALIDATORS]] entries of count validators of an
+// organization and their public keys.
+func validators(homeDomain, quality string, count int) (string, []string) {
+	var b strings.Builder
+	var keys []string
+	for i := 0; i < count; i++ {
+		key := keypair.MustRandom().Address()
+		keys = append(keys, key)
+		fmt.Fprintf(&b, "[[VALIDATORS]]\nNAME=%q\nHOME_DOMAIN=%q\nPUBLIC_KEY=%q\n", fmt.Sprintf("%s_%d", homeDomain, i), homeDomain, key)
+		if quality != "" {
+			fmt.Fprintf(&b, "QUALITY=%q\n", quality)
+		}
+	}
+	return b.String(), keys
+}
+
+func TestCaptiveCoreQuorumSetGenerated(t *testing.T) {
+	a, aKeys := validators("a.example.com", "HIGH", 3)
+	b, bKeys := validators("b.example.com", "HIGH", 3)
+	c, cKeys := validators("c.example.com", "", 3)
+	d, dKeys := validators("d.example.com", "MEDIUM", 1)
+	e, eKeys := validators("e.example.com", "LOW", 2)
+	config := captiveCoreToml(t, `
+[[HOME_DOMAINS]]
+HOME_DOMAIN="c.example.com"
+QUALITY="HIGH"
+`+a+b+c+d+e)
+
+	qset, err := CaptiveCoreQuorumSet(config)
+	require.NoError(t, err)
+	assert.Equal(t, QuorumSet{
+		Threshold: 3,
+		InnerSets: []QuorumSet{
+			{Threshold: 2, Validators: aKeys},
+			{Threshold: 2, Validators: bKeys},
+			{Threshold: 2, Validators: cKeys},
+			{
+				Threshold: 2,
+				InnerSets: []QuorumSet{
+					{Threshold: 1, Validators: dKeys},
+					{
+						Threshold: 1,
+						InnerSets: []QuorumSet{{Threshold: 2, Validators: eKeys}},
+					},
+				},
+			},
+		},
+	}, qset)
+
+	network, err := NetworkFromCaptiveCoreToml(config)
+	require.NoError(t, err)
+	assert.Len(t, network.QuorumSets, 12)
+	assert.Equal(t, "a.example.com_0", network.Names[aKeys[0]])
+
+	report, err := network.Analyze(Options{})
+	require.NoError(t, err)
+	assert.True(t, report.Intersection)
+	assert.Contains(t, report.BlockingSets, []string{"a.example.com", "b.example.com"})
+	assert.Contains(t, report.BlockingSets, []string{"a.example.com", "d.example.com"})
+	assert.Equal(t, 2, report.MinBlockingSetSize())
+}
+
+func TestCaptiveCoreQuorumSetGeneratedInvalid(t *testing.T) {
+	a, _ := validators("a.example.com", "CRITICAL", 2)
+	_, err := CaptiveCoreQuorumSet(captiveCoreToml(t, a))
+	assert.EqualError(t, err, "CRITICAL quality organization a.example.com must have at least 3 validators")
+
+	_, err = CaptiveCoreQuorumSet(captiveCoreToml(t, ""))
+	assert.EqualError(t, err, "quorum set is not configured")
+}
+
+func TestCaptiveCoreQuorumSetTables(t *testing.T) {
+	a := keypair.MustRandom().Address()
+	b := keypair.MustRandom().Address()
+	c := keypair.MustRandom().Address()
+	d := keypair.MustRandom().Address()
+	config := captiveCoreToml(t, fmt.Sprintf(`
+NODE_NAMES=["%s alice", "%s bob"]
+
+[QUORUM_SET]
+VALIDATORS=["$alice", "$bob"]
+
+[QUORUM_SET.inner]
+THRESHOLD_PERCENT=100
+VALIDATORS=["%s", "%s"]
+`, a, b, c, d))
+
+	qset, err := CaptiveCoreQuorumSet(config)
+	require.NoError(t, err)
+	assert.Equal(t, QuorumSet{
+		Threshold:  3,
+		Validators: []string{a, b},
+		InnerSets:  []QuorumSet{{Threshold: 2, Validators: []string{c, d}}},
+	}, qset)
+
+	network, err := NetworkFromCaptiveCoreToml(config)
+	require.NoError(t, err)
+	assert.Len(t, network.QuorumSets, 4)
+	assert.Equal(t, "alice", network.Names[a])
+
+	config = captiveCoreToml(t, `
+[QUORUM_SET]
+VALIDATORS=["$self", "$carol"]
+`)
+	_, err = CaptiveCoreQuorumSet(config)
+	assert.EqualError(t, err, "QUORUM_SET: $self is not supported")
+}
+
+func TestThresholdPercent(t *testing.T) {
+	for _, tc := range []struct {
+		size, percent, threshold int
+	}{
+		{0, 67, 0},
+		{1, 67, 1},
+		{3, 67, 3},
+		{4, 67, 3},
+		{5, 67, 4},
+		{4, 51, 3},
+		{4, 50, 2},
+		{7, 100, 7},
+	} {
+		assert.Equal(t, tc.threshold, thresholdPercent(tc.size, tc.percent), "%d%% of %d", tc.percent, tc.size)
+	}
+}
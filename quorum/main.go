@@ -0,0 +1,191 @@
+// Package quorum analyses the quorum sets of the validators of a network. It
+// checks whether all the quorums of the network intersect, which is what
+// keeps the network from forking, and finds the minimal sets of
+// organizations whose failure would halt the network (blocking sets) or
+// could fork it (splitting sets).
+//
+// Quorum sets can be loaded from a captive-core configuration, from the
+// response of stellar-core's quorum endpoint for each validator, or from
+// the SCP messages in history archives.
+//
+// The analysis is exponential in the number of validators and organizations
+// of the network. It is fast for networks of the size of the top tier of the
+// public network, and Options.MaxSetSize bounds it for larger ones.
+package quorum
+
+import (
+	"sort"
+
+	"github.com/stellar/go/hash"
+	proto "github.com/stellar/go/protocols/stellarcore"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// QuorumSet is the quorum set of a validator: it is satisfied by a set of
+// validators if at least Threshold of its Validators and InnerSets are.
+type QuorumSet struct {
+	Threshold  int         `json:"threshold"`
+	Validators []string    `json:"validators,omitempty"`
+	InnerSets  []QuorumSet `json:"inner_sets,omitempty"`
+}
+
+// validators returns all the validators of q and of its inner sets.
+func (q QuorumSet) validators() []string {
+	validators := append([]string{}, q.Validators...)
+	for _, inner := range q.InnerSets {
+		validators = append(validators, inner.validators()...)
+	}
+	return validators
+}
+
+// Network holds the quorum sets of the validators of a network.
+type Network struct {
+	// QuorumSets holds the quorum set of each validator by node id.
+	// Validators which appear in quorum sets without their own are
+	// considered failed.
+	QuorumSets map[string]QuorumSet
+
+	// Organizations maps node ids to the name of the organization running
+	// them, usually their home domain. Validators without an organization
+	// are their own organization.
+	Organizations map[string]string
+
+	// Names maps node ids to human readable names used in reports.
+	Names map[string]string
+}
+
+// NewNetwork returns an empty network.
+func NewNetwork() *Network {
+	return &Network{
+		QuorumSets:    map[string]QuorumSet{},
+		Organizations: map[string]string{},
+		Names:         map[string]string{},
+	}
+}
+
+// organization returns the organization of a node.
+func (n *Network) organization(node string) string {
+	if org, ok := n.Organizations[node]; ok && org != "" {
+		return org
+	}
+	return n.name(node)
+}
+
+// name returns the name of a node for reports.
+func (n *Network) name(node string) string {
+	if name, ok := n.Names[node]; ok && name != "" {
+		return name
+	}
+	return node
+}
+
+// nodes returns the sorted ids of all the validators of the network,
+// including those only known from the quorum sets of others.
+func (n *Network) nodes() []string {
+	seen := map[string]bool{}
+	for node, qset := range n.QuorumSets {
+		seen[node] = true
+		for _, validator := range qset.validators() {
+			seen[validator] = true
+		}
+	}
+	nodes := make([]string, 0, len(seen))
+	for node := range seen {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// FromScpQuorumSet converts an XDR quorum set.
+func FromScpQuorumSet(q xdr.ScpQuorumSet) (QuorumSet, error) {
+	qset := QuorumSet{Threshold: int(q.Threshold)}
+	for _, validator := range q.Validators {
+		address, err := validator.GetAddress()
+		if err != nil {
+			return QuorumSet{}, errors.Wrap(err, "invalid validator")
+		}
+		qset.Validators = append(qset.Validators, address)
+	}
+	for _, inner := range q.InnerSets {
+		innerSet, err := FromScpQuorumSet(inner)
+		if err != nil {
+			return QuorumSet{}, err
+		}
+		qset.InnerSets = append(qset.InnerSets, innerSet)
+	}
+	return qset, nil
+}
+
+// FromCoreQuorumSet converts a quorum set of stellar-core's quorum endpoint.
+func FromCoreQuorumSet(q proto.QuorumSet) QuorumSet {
+	qset := QuorumSet{Threshold: q.Threshold}
+	qset.Validators = append(qset.Validators, q.Validators...)
+	for _, inner := range q.InnerSets {
+		qset.InnerSets = append(qset.InnerSets, FromCoreQuorumSet(inner))
+	}
+	return qset
+}
+
+// NetworkFromCoreQuorum returns the network of the quorum sets of the
+// responses of stellar-core's quorum endpoint, usually one per validator of
+// the network. The responses must be requested with full keys.
+func NetworkFromCoreQuorum(responses ...*proto.QuorumResponse) *Network {
+	network := NewNetwork()
+	for _, response := range responses {
+		network.QuorumSets[response.Node] = FromCoreQuorumSet(response.QSet.Value)
+	}
+	return network
+}
+
+// NetworkFromSCPHistory returns the network of the quorum sets the
+// validators used in SCP history entries, as found in history archives.
+// When a validator changed its quorum set, the one of its last message is
+// used.
+func NetworkFromSCPHistory(entries []xdr.ScpHistoryEntry) (*Network, error) {
+	qsets := map[xdr.Hash]xdr.ScpQuorumSet{}
+	network := NewNetwork()
+	for _, entry := range entries {
+		v0, ok := entry.GetV0()
+		if !ok {
+			return nil, errors.Errorf("unsupported scp history entry version %d", entry.V)
+		}
+		for _, qset := range v0.QuorumSets {
+			data, err := qset.MarshalBinary()
+			if err != nil {
+				return nil, errors.Wrap(err, "could not encode quorum set")
+			}
+			qsets[hash.Hash(data)] = qset
+		}
+
+		for _, envelope := range v0.LedgerMessages.Messages {
+			node, err := envelope.Statement.NodeId.GetAddress()
+			if err != nil {
+				return nil, errors.Wrap(err, "invalid node id")
+			}
+			qset, ok := qsets[statementQuorumSetHash(envelope.Statement.Pledges)]
+			if !ok {
+				return nil, errors.Errorf("quorum set of %s in ledger %d is missing", node, v0.LedgerMessages.LedgerSeq)
+			}
+			network.QuorumSets[node], err = FromScpQuorumSet(qset)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid quorum set of %s", node)
+			}
+		}
+	}
+	return network, nil
+}
+
+func statementQuorumSetHash(pledges xdr.ScpStatementPledges) xdr.Hash {
+	switch pledges.Type {
+	case xdr.ScpStatementTypeScpStPrepare:
+		return pledges.MustPrepare().QuorumSetHash
+	case xdr.ScpStatementTypeScpStConfirm:
+		return pledges.MustConfirm().QuorumSetHash
+	case xdr.ScpStatementTypeScpStExternalize:
+		return pledges.MustExternalize().CommitQuorumSetHash
+	default:
+		return pledges.MustNominate().QuorumSetHash
+	}
+}
@@ -0,0 +1,99 @@
+package quorum
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/hash"
+	"github.com/stellar/go/keypair"
+	proto "github.com/stellar/go/protocols/stellarcore"
+	"github.com/stellar/go/xdr"
+)
+
+func TestNetworkFromCoreQuorum(t *testing.T) {
+	var responses []*proto.QuorumResponse
+	for _, node := range []string{"a", "b"} {
+		var response proto.QuorumResponse
+		require.NoError(t, json.Unmarshal([]byte(`{
+			"node": "`+node+`",
+			"qset": {"agree": 2, "value": {"t": 2, "v": ["a", "b", {"t": 1, "v": ["c"]}]}}
+		}`), &response))
+		responses = append(responses, &response)
+	}
+
+	network := NetworkFromCoreQuorum(responses...)
+	expected := QuorumSet{
+		Threshold:  2,
+		Validators: []string{"a", "b"},
+		InnerSets:  []QuorumSet{{Threshold: 1, Validators: []string{"c"}}},
+	}
+	assert.Equal(t, map[string]QuorumSet{"a": expected, "b": expected}, network.QuorumSets)
+	assert.Equal(t, []string{"a", "b", "c"}, network.nodes())
+}
+
+func nodeID(kp *keypair.Full) xdr.NodeId {
+	return xdr.NodeId(xdr.MustAddress(kp.Address()))
+}
+
+func TestNetworkFromSCPHistory(t *testing.T) {
+	a := keypair.MustRandom()
+	b := keypair.MustRandom()
+	both := xdr.ScpQuorumSet{
+		Threshold:  2,
+		Validators: []xdr.NodeId{nodeID(a), nodeID(b)},
+	}
+	alone := xdr.ScpQuorumSet{Threshold: 1, Validators: []xdr.NodeId{nodeID(a)}}
+	qsetHash := func(q xdr.ScpQuorumSet) xdr.Hash {
+		data, err := q.MarshalBinary()
+		require.NoError(t, err)
+		return hash.Hash(data)
+	}
+	envelope := func(node *keypair.Full, pledges xdr.ScpStatementPledges) xdr.ScpEnvelope {
+		return xdr.ScpEnvelope{Statement: xdr.ScpStatement{
+			NodeId:  nodeID(node),
+			Pledges: pledges,
+		}}
+	}
+	entry := func(qsets []xdr.ScpQuorumSet, envelopes ...xdr.ScpEnvelope) xdr.ScpHistoryEntry {
+		return xdr.ScpHistoryEntry{V0: &xdr.ScpHistoryEntryV0{
+			QuorumSets:     qsets,
+			LedgerMessages: xdr.LedgerScpMessages{LedgerSeq: 2, Messages: envelopes},
+		}}
+	}
+
+	entries := []xdr.ScpHistoryEntry{
+		entry(
+			[]xdr.ScpQuorumSet{both, alone},
+			envelope(a, xdr.ScpStatementPledges{
+				Type:        xdr.ScpStatementTypeScpStExternalize,
+				Externalize: &xdr.ScpStatementExternalize{CommitQuorumSetHash: qsetHash(alone)},
+			}),
+			envelope(b, xdr.ScpStatementPledges{
+				Type:    xdr.ScpStatementTypeScpStConfirm,
+				Confirm: &xdr.ScpStatementConfirm{QuorumSetHash: qsetHash(alone)},
+			}),
+		),
+		// b changed its quorum set and the quorum set is known from the
+		// previous entry.
+		entry(nil, envelope(b, xdr.ScpStatementPledges{
+			Type:     xdr.ScpStatementTypeScpStNominate,
+			Nominate: &xdr.ScpNomination{QuorumSetHash: qsetHash(both)},
+		})),
+	}
+	network, err := NetworkFromSCPHistory(entries)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]QuorumSet{
+		a.Address(): {Threshold: 1, Validators: []string{a.Address()}},
+		b.Address(): {Threshold: 2, Validators: []string{a.Address(), b.Address()}},
+	}, network.QuorumSets)
+
+	entries = append(entries, entry(nil, envelope(a, xdr.ScpStatementPledges{
+		Type:    xdr.ScpStatementTypeScpStPrepare,
+		Prepare: &xdr.ScpStatementPrepare{},
+	})))
+	_, err = NetworkFromSCPHistory(entries)
+	assert.EqualError(t, err, "quorum set of "+a.Address()+" in ledger 2 is missing")
+}
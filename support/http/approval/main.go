@@ -0,0 +1,175 @@
+// Package approval provides an http.Handler which serves SEP-8 approval
+// requests for regulated assets using a pluggable Policy.
+// https://github.com/stellar/stellar-protocol/blob/master/ecosystem/sep-0008.md
+package approval
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"mime"
+	"net/http"
+
+	proto "github.com/stellar/go/protocols/approval"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/support/log"
+	"github.com/stellar/go/support/render/httpjson"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+)
+
+// Policy decides whether to approve transactions.
+type Policy interface {
+	// Approve returns the response to a request to approve tx. Success and
+	// revised responses must include the transaction signed by the issuer.
+	Approve(ctx context.Context, tx *txnbuild.Transaction) (*proto.Response, error)
+}
+
+// PolicyFunc is an adapter to use a function as a Policy.
+type PolicyFunc func(ctx context.Context, tx *txnbuild.Transaction) (*proto.Response, error)
+
+// Approve calls f(ctx, tx).
+func (f PolicyFunc) Approve(ctx context.Context, tx *txnbuild.Transaction) (*proto.Response, error) {
+	return f(ctx, tx)
+}
+
+// Handler serves approval requests. The tx parameter is read from a json or
+// form encoded body, decoded and passed to Policy. Rejected responses are
+// sent with 400, other responses with 200.
+type Handler struct {
+	Policy Policy
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Wallets call approval servers from browsers.
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	if r.Method != http.MethodPost {
+		httpjson.RenderStatus(w, http.StatusMethodNotAllowed, proto.Response{
+			Status: proto.StatusRejected,
+			Error:  "method not allowed",
+		}, httpjson.JSON)
+		return
+	}
+
+	envelope, err := requestTransaction(r)
+	if err != nil {
+		reject(w, err.Error())
+		return
+	}
+	if envelope == "" {
+		reject(w, `missing parameter "tx"`)
+		return
+	}
+	parsed, err := txnbuild.TransactionFromXDR(envelope)
+	if err != nil {
+		reject(w, `invalid parameter "tx"`)
+		return
+	}
+	tx, ok := parsed.Transaction()
+	if !ok {
+		reject(w, "fee bump transactions are not supported")
+		return
+	}
+
+	response, err := h.Policy.Approve(r.Context(), tx)
+	if err != nil {
+		log.Ctx(r.Context()).WithStack(err).WithError(err).Error("approval policy failed")
+		httpjson.RenderStatus(w, http.StatusInternalServerError, proto.Response{
+			Status: proto.StatusRejected,
+			Error:  "internal server error",
+		}, httpjson.JSON)
+		return
+	}
+
+	status := http.StatusOK
+	if response.Status == proto.StatusRejected {
+		status = http.StatusBadRequest
+	}
+	httpjson.RenderStatus(w, status, response, httpjson.JSON)
+}
+
+func requestTransaction(r *http.Request) (string, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/json" {
+		var request proto.Request
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			return "", errors.New("invalid json body")
+		}
+		return request.Tx, nil
+	}
+	if err := r.ParseForm(); err != nil {
+		return "", errors.New("invalid form body")
+	}
+	return r.PostForm.Get("tx"), nil
+}
+
+func reject(w http.ResponseWriter, message string) {
+	httpjson.RenderStatus(w, http.StatusBadRequest, proto.Response{
+		Status: proto.StatusRejected,
+		Error:  message,
+	}, httpjson.JSON)
+}
+
+// Revise returns a revision of tx for policies which approve transactions
+// by authorizing their accounts only for the duration of the transaction.
+// The operations of tx are placed between operations of the issuer of
+// asset authorizing accounts to hold it, and operations reverting them to
+// only being authorized to maintain liabilities. The fee per operation is
+// unchanged. The revision is unsigned; policies sign it with the issuer's
+// key before responding.
+func Revise(tx *txnbuild.Transaction, asset txnbuild.CreditAsset, accounts ...string) (*txnbuild.Transaction, error) {
+	env := tx.ToXDR()
+	if env.Type != xdr.EnvelopeTypeEnvelopeTypeTx {
+		return nil, errors.Errorf("transaction envelope type %s is not supported", env.Type)
+	}
+
+	var before, after []xdr.Operation
+	for i := range accounts {
+		authorize, err := (&txnbuild.SetTrustLineFlags{
+			Trustor:       accounts[i],
+			Asset:         asset,
+			SetFlags:      []txnbuild.TrustLineFlag{txnbuild.TrustLineAuthorized},
+			SourceAccount: asset.Issuer,
+		}).BuildXDR()
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not build authorization of %s", accounts[i])
+		}
+		before = append(before, authorize)
+
+		deauthorize, err := (&txnbuild.SetTrustLineFlags{
+			Trustor:       accounts[len(accounts)-1-i],
+			Asset:         asset,
+			SetFlags:      []txnbuild.TrustLineFlag{txnbuild.TrustLineAuthorizedToMaintainLiabilities},
+			ClearFlags:    []txnbuild.TrustLineFlag{txnbuild.TrustLineAuthorized},
+			SourceAccount: asset.Issuer,
+		}).BuildXDR()
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not build deauthorization of %s", accounts[len(accounts)-1-i])
+		}
+		after = append(after, deauthorize)
+	}
+
+	revised := env.V1.Tx
+	revised.Operations = append(append(append([]xdr.Operation{}, before...), revised.Operations...), after...)
+	fee := tx.BaseFee() * int64(len(revised.Operations))
+	if fee > math.MaxUint32 {
+		return nil, errors.Errorf("revised transaction fee %d overflows", fee)
+	}
+	revised.Fee = xdr.Uint32(fee)
+
+	encoded, err := xdr.MarshalBase64(xdr.TransactionEnvelope{
+		Type: xdr.EnvelopeTypeEnvelopeTypeTx,
+		V1:   &xdr.TransactionV1Envelope{Tx: revised},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not encode revised transaction")
+	}
+	parsed, err := txnbuild.TransactionFromXDR(encoded)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse revised transaction")
+	}
+	result, _ := parsed.Transaction()
+	return result, nil
+}
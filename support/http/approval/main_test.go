@@ -0,0 +1,241 @@
+package approval
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	proto "github.com/stellar/go/protocols/approval"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testTransaction(t *testing.T, issuer, source string) *txnbuild.Transaction {
+	tx, err := txnbuild.NewTransaction(txnbuild.TransactionParams{
+		SourceAccount:        &txnbuild.SimpleAccount{AccountID: source, Sequence: 41},
+		IncrementSequenceNum: true,
+		Operations: []txnbuild.Operation{&txnbuild.Payment{
+			Destination: issuer,
+			Amount:      "10",
+			Asset:       txnbuild.CreditAsset{Code: "USD", Issuer: issuer},
+		}},
+		BaseFee:       txnbuild.MinBaseFee,
+		Memo:          txnbuild.MemoText("invoice"),
+		Preconditions: txnbuild.Preconditions{TimeBounds: txnbuild.NewInfiniteTimeout()},
+	})
+	require.NoError(t, err)
+	return tx
+}
+
+func TestHandler(t *testing.T) {
+	issuer := keypair.MustRandom()
+	source := keypair.MustRandom().Address()
+	tx := testTransaction(t, issuer.Address(), source)
+	envelope, err := tx.Base64()
+	require.NoError(t, err)
+	feeBump, err := txnbuild.NewFeeBumpTransaction(txnbuild.FeeBumpTransactionParams{
+		Inner:      tx,
+		FeeAccount: source,
+		BaseFee:    txnbuild.MinBaseFee,
+	})
+	require.NoError(t, err)
+	feeBumpEnvelope, err := feeBump.Base64()
+	require.NoError(t, err)
+
+	handler := &Handler{
+		Policy: PolicyFunc(func(_ context.Context, tx *txnbuild.Transaction) (*proto.Response, error) {
+			switch memo := tx.Memo().(txnbuild.MemoText); memo {
+			case "invoice":
+				signed, err := tx.Sign(network.TestNetworkPassphrase, issuer)
+				if err != nil {
+					return nil, err
+				}
+				approved, err := signed.Base64()
+				if err != nil {
+					return nil, err
+				}
+				return &proto.Response{Status: proto.StatusSuccess, Tx: approved}, nil
+			case "broken":
+				return nil, errors.New("database is down")
+			default:
+				return &proto.Response{Status: proto.StatusRejected, Error: "unknown memo"}, nil
+			}
+		}),
+	}
+
+	rejected, err := txnbuild.NewTransaction(txnbuild.TransactionParams{
+		SourceAccount:        &txnbuild.SimpleAccount{AccountID: source, Sequence: 41},
+		IncrementSequenceNum: true,
+		Operations:           []txnbuild.Operation{&txnbuild.BumpSequence{}},
+		BaseFee:              txnbuild.MinBaseFee,
+		Memo:                 txnbuild.MemoText("unknown"),
+		Preconditions:        txnbuild.Preconditions{TimeBounds: txnbuild.NewInfiniteTimeout()},
+	})
+	require.NoError(t, err)
+	rejectedEnvelope, err := rejected.Base64()
+	require.NoError(t, err)
+	broken, err := txnbuild.NewTransaction(txnbuild.TransactionParams{
+		SourceAccount:        &txnbuild.SimpleAccount{AccountID: source, Sequence: 41},
+		IncrementSequenceNum: true,
+		Operations:           []txnbuild.Operation{&txnbuild.BumpSequence{}},
+		BaseFee:              txnbuild.MinBaseFee,
+		Memo:                 txnbuild.MemoText("broken"),
+		Preconditions:        txnbuild.Preconditions{TimeBounds: txnbuild.NewInfiniteTimeout()},
+	})
+	require.NoError(t, err)
+	brokenEnvelope, err := broken.Base64()
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		status      int
+		response    proto.Response
+	}{
+		{
+			name:        "json",
+			contentType: "application/json",
+			body:        `{"tx":"` + envelope + `"}`,
+			status:      http.StatusOK,
+			response:    proto.Response{Status: proto.StatusSuccess},
+		},
+		{
+			name:        "form",
+			contentType: "application/x-www-form-urlencoded",
+			body:        url.Values{"tx": {envelope}}.Encode(),
+			status:      http.StatusOK,
+			response:    proto.Response{Status: proto.StatusSuccess},
+		},
+		{
+			name:        "rejected by policy",
+			contentType: "application/json",
+			body:        `{"tx":"` + rejectedEnvelope + `"}`,
+			status:      http.StatusBadRequest,
+			response:    proto.Response{Status: proto.StatusRejected, Error: "unknown memo"},
+		},
+		{
+			name:        "policy error",
+			contentType: "application/json",
+			body:        `{"tx":"` + brokenEnvelope + `"}`,
+			status:      http.StatusInternalServerError,
+			response:    proto.Response{Status: proto.StatusRejected, Error: "internal server error"},
+		},
+		{
+			name:        "missing tx",
+			contentType: "application/json",
+			body:        `{}`,
+			status:      http.StatusBadRequest,
+			response:    proto.Response{Status: proto.StatusRejected, Error: `missing parameter "tx"`},
+		},
+		{
+			name:        "invalid json",
+			contentType: "application/json",
+			body:        `{`,
+			status:      http.StatusBadRequest,
+			response:    proto.Response{Status: proto.StatusRejected, Error: "invalid json body"},
+		},
+		{
+			name:        "invalid tx",
+			contentType: "application/json",
+			body:        `{"tx":"AAAA"}`,
+			status:      http.StatusBadRequest,
+			response:    proto.Response{Status: proto.StatusRejected, Error: `invalid parameter "tx"`},
+		},
+		{
+			name:        "fee bump",
+			contentType: "application/json",
+			body:        `{"tx":"` + feeBumpEnvelope + `"}`,
+			status:      http.StatusBadRequest,
+			response:    proto.Response{Status: proto.StatusRejected, Error: "fee bump transactions are not supported"},
+		},
+		{
+			name:     "get",
+			method:   http.MethodGet,
+			status:   http.StatusMethodNotAllowed,
+			response: proto.Response{Status: proto.StatusRejected, Error: "method not allowed"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			method := tc.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			r := httptest.NewRequest(method, "/tx_approve", strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				r.Header.Set("Content-Type", tc.contentType)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			assert.Equal(t, tc.status, w.Code)
+			assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+			var response proto.Response
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			if tc.response.Status == proto.StatusSuccess {
+				approved, err := txnbuild.TransactionFromXDR(response.Tx)
+				require.NoError(t, err)
+				approvedTx, ok := approved.Transaction()
+				require.True(t, ok)
+				assert.Len(t, approvedTx.Signatures(), 1)
+				response.Tx = ""
+			}
+			assert.Equal(t, tc.response, response)
+		})
+	}
+}
+
+func TestRevise(t *testing.T) {
+	issuer := keypair.MustRandom().Address()
+	source := keypair.MustRandom().Address()
+	destination := keypair.MustRandom().Address()
+	tx := testTransaction(t, issuer, source)
+	asset := txnbuild.CreditAsset{Code: "USD", Issuer: issuer}
+
+	revised, err := Revise(tx, asset, source, destination)
+	require.NoError(t, err)
+
+	originalEnv, revisedEnv := tx.ToXDR(), revised.ToXDR()
+	assert.Equal(t, originalEnv.SourceAccount(), revisedEnv.SourceAccount())
+	assert.Equal(t, originalEnv.SeqNum(), revisedEnv.SeqNum())
+	assert.Equal(t, originalEnv.Memo(), revisedEnv.Memo())
+	assert.Equal(t, originalEnv.Preconditions(), revisedEnv.Preconditions())
+	assert.Equal(t, int64(5*txnbuild.MinBaseFee), revised.MaxFee())
+	assert.Empty(t, revised.Signatures())
+
+	ops := revisedEnv.Operations()
+	require.Len(t, ops, 5)
+	assert.Equal(t, originalEnv.Operations()[0], ops[2])
+	for i, expected := range []struct {
+		trustor    string
+		setFlags   xdr.Uint32
+		clearFlags xdr.Uint32
+	}{
+		{source, xdr.Uint32(xdr.TrustLineFlagsAuthorizedFlag), 0},
+		{destination, xdr.Uint32(xdr.TrustLineFlagsAuthorizedFlag), 0},
+		{},
+		{destination, xdr.Uint32(xdr.TrustLineFlagsAuthorizedToMaintainLiabilitiesFlag), xdr.Uint32(xdr.TrustLineFlagsAuthorizedFlag)},
+		{source, xdr.Uint32(xdr.TrustLineFlagsAuthorizedToMaintainLiabilitiesFlag), xdr.Uint32(xdr.TrustLineFlagsAuthorizedFlag)},
+	} {
+		if expected.trustor == "" {
+			continue
+		}
+		require.NotNil(t, ops[i].SourceAccount)
+		assert.Equal(t, issuer, ops[i].SourceAccount.ToAccountId().Address())
+		flags := ops[i].Body.MustSetTrustLineFlagsOp()
+		assert.Equal(t, expected.trustor, flags.Trustor.Address())
+		assert.Equal(t, "USD:"+issuer, flags.Asset.StringCanonical())
+		assert.Equal(t, expected.setFlags, flags.SetFlags)
+		assert.Equal(t, expected.clearFlags, flags.ClearFlags)
+	}
+}
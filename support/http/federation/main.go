@@ -0,0 +1,121 @@
+// Package federation provides an http.Handler which serves SEP-2 federation
+// requests using a pluggable Lookup.
+// https://github.com/stellar/stellar-protocol/blob/master/ecosystem/sep-0002.md
+package federation
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/stellar/go/address"
+	proto "github.com/stellar/go/protocols/federation"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/support/log"
+	"github.com/stellar/go/support/render/httpjson"
+)
+
+// ErrNotFound is returned by a Lookup when it has no record for the
+// request. The handler responds with 404.
+var ErrNotFound = errors.New("not found")
+
+// Lookup finds federation records.
+type Lookup interface {
+	// LookupByName returns the record of the stellar address name*domain.
+	LookupByName(ctx context.Context, name, domain string) (*proto.Response, error)
+	// LookupByAccountID returns the record of the account, which must
+	// include its stellar address.
+	LookupByAccountID(ctx context.Context, accountID string) (*proto.Response, error)
+}
+
+// Handler serves federation requests. Name and id lookups are delegated to
+// Lookup; other lookup types are answered with 501.
+type Handler struct {
+	Lookup Lookup
+
+	// Domains lists the domains served by the handler. Name lookups for
+	// other domains are answered with 404. All domains are served if it is
+	// empty.
+	Domains []string
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// SEP-2 requires federation servers to allow cross-origin requests.
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	if r.Method != http.MethodGet {
+		renderError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	q := query.Get("q")
+	if q == "" {
+		renderError(w, http.StatusBadRequest, "q parameter is required")
+		return
+	}
+
+	var (
+		response *proto.Response
+		err      error
+	)
+	switch query.Get("type") {
+	case proto.TypeName:
+		name, domain, splitErr := address.Split(q)
+		if splitErr != nil {
+			renderError(w, http.StatusBadRequest, splitErr.Error())
+			return
+		}
+		if !h.servesDomain(domain) {
+			renderError(w, http.StatusNotFound, "not found")
+			return
+		}
+		response, err = h.Lookup.LookupByName(r.Context(), name, domain)
+		if err == nil && response.StellarAddress == "" {
+			response.StellarAddress = q
+		}
+	case proto.TypeID:
+		if !strkey.IsValidEd25519PublicKey(q) {
+			renderError(w, http.StatusBadRequest, "q is not a valid account ID")
+			return
+		}
+		response, err = h.Lookup.LookupByAccountID(r.Context(), q)
+		if err == nil && response.AccountID == "" {
+			response.AccountID = q
+		}
+	case proto.TypeTxID, proto.TypeForward:
+		renderError(w, http.StatusNotImplemented, "lookup type is not supported")
+		return
+	default:
+		renderError(w, http.StatusBadRequest, "type parameter must be name, id, txid or forward")
+		return
+	}
+
+	switch {
+	case err == nil:
+		httpjson.Render(w, response, httpjson.JSON)
+	case errors.Cause(err) == ErrNotFound:
+		renderError(w, http.StatusNotFound, "not found")
+	default:
+		log.Ctx(r.Context()).WithStack(err).WithError(err).Error("federation lookup failed")
+		renderError(w, http.StatusInternalServerError, "internal server error")
+	}
+}
+
+func (h *Handler) servesDomain(domain string) bool {
+	if len(h.Domains) == 0 {
+		return true
+	}
+	for _, served := range h.Domains {
+		if strings.EqualFold(served, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+func renderError(w http.ResponseWriter, status int, detail string) {
+	httpjson.RenderStatus(w, status, proto.ErrorResponse{Detail: detail}, httpjson.JSON)
+}
@@ -0,0 +1,150 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	proto "github.com/stellar/go/protocols/federation"
+	"github.com/stellar/go/support/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAccountID = "GCCOBXW2XQNUSL467IEILE6MMCNRR66SSVL4YQADUNYYNUVREF3FIV2Z"
+
+type mapLookup map[string]proto.Response
+
+func (m mapLookup) LookupByName(_ context.Context, name, domain string) (*proto.Response, error) {
+	if name == "broken" {
+		return nil, errors.New("database is down")
+	}
+	response, ok := m[name+"*"+domain]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &response, nil
+}
+
+func (m mapLookup) LookupByAccountID(_ context.Context, accountID string) (*proto.Response, error) {
+	for addr, response := range m {
+		if response.AccountID == accountID {
+			response.StellarAddress = addr
+			return &response, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func TestHandler(t *testing.T) {
+	handler := &Handler{
+		Lookup: mapLookup{
+			"alice*example.com": {AccountID: testAccountID, MemoType: proto.MemoTypeID, Memo: proto.Memo{Value: "42"}},
+		},
+		Domains: []string{"example.com"},
+	}
+
+	for _, tc := range []struct {
+		name     string
+		method   string
+		query    string
+		status   int
+		response interface{}
+	}{
+		{
+			name:   "name lookup",
+			query:  "type=name&q=alice*example.com",
+			status: http.StatusOK,
+			response: proto.Response{
+				StellarAddress: "alice*example.com",
+				AccountID:      testAccountID,
+				MemoType:       proto.MemoTypeID,
+				Memo:           proto.Memo{Value: "42"},
+			},
+		},
+		{
+			name:   "id lookup",
+			query:  "type=id&q=" + testAccountID,
+			status: http.StatusOK,
+			response: proto.Response{
+				StellarAddress: "alice*example.com",
+				AccountID:      testAccountID,
+				MemoType:       proto.MemoTypeID,
+				Memo:           proto.Memo{Value: "42"},
+			},
+		},
+		{
+			name:     "unknown name",
+			query:    "type=name&q=bob*example.com",
+			status:   http.StatusNotFound,
+			response: proto.ErrorResponse{Detail: "not found"},
+		},
+		{
+			name:     "other domain",
+			query:    "type=name&q=alice*example.org",
+			status:   http.StatusNotFound,
+			response: proto.ErrorResponse{Detail: "not found"},
+		},
+		{
+			name:     "invalid address",
+			query:    "type=name&q=alice",
+			status:   http.StatusBadRequest,
+			response: proto.ErrorResponse{Detail: "invalid address"},
+		},
+		{
+			name:     "invalid account",
+			query:    "type=id&q=GABC",
+			status:   http.StatusBadRequest,
+			response: proto.ErrorResponse{Detail: "q is not a valid account ID"},
+		},
+		{
+			name:     "missing q",
+			query:    "type=name",
+			status:   http.StatusBadRequest,
+			response: proto.ErrorResponse{Detail: "q parameter is required"},
+		},
+		{
+			name:     "unknown type",
+			query:    "type=other&q=alice*example.com",
+			status:   http.StatusBadRequest,
+			response: proto.ErrorResponse{Detail: "type parameter must be name, id, txid or forward"},
+		},
+		{
+			name:     "txid",
+			query:    "type=txid&q=abc",
+			status:   http.StatusNotImplemented,
+			response: proto.ErrorResponse{Detail: "lookup type is not supported"},
+		},
+		{
+			name:     "lookup error",
+			query:    "type=name&q=broken*example.com",
+			status:   http.StatusInternalServerError,
+			response: proto.ErrorResponse{Detail: "internal server error"},
+		},
+		{
+			name:     "post",
+			method:   http.MethodPost,
+			query:    "type=name&q=alice*example.com",
+			status:   http.StatusMethodNotAllowed,
+			response: proto.ErrorResponse{Detail: "method not allowed"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			method := tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			r := httptest.NewRequest(method, "/federation?"+tc.query, nil)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			assert.Equal(t, tc.status, w.Code)
+			assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+			expected, err := json.Marshal(tc.response)
+			require.NoError(t, err)
+			assert.JSONEq(t, string(expected), w.Body.String())
+		})
+	}
+}
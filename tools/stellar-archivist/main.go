@@ -5,16 +5,24 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"strconv"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/spf13/cobra"
 	"github.com/stellar/go/historyarchive"
+	"github.com/stellar/go/ingest"
 	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
 )
 
 const checkpointFrequency = uint32(64)
@@ -42,7 +50,19 @@ func status(a string, opts *Options) {
 	fmt.Printf("\n")
 }
 
+type MonitorOptions struct {
+	Listen            string
+	Interval          time.Duration
+	ReferenceArchives []string
+	MaxLag            uint32
+	InitialScan       uint32
+}
+
 type Options struct {
+	Monitor     MonitorOptions
+	StateFile   string
+	SinceLast   bool
+	HotArchive  bool
 	Low         int
 	High        uint32
 	Last        int
@@ -119,20 +139,81 @@ func logArchive(a string, opts *Options) {
 	arch.Log(&opts.CommandOpts)
 }
 
+// LoadScanState loads the --state-file, if one was given. It returns nil
+// when no state file is used.
+func (opts *Options) LoadScanState() *historyarchive.ScanState {
+	if opts.StateFile == "" {
+		if opts.SinceLast {
+			log.Fatal("--since-last requires --state-file")
+		}
+		return nil
+	}
+	state, err := historyarchive.LoadScanState(opts.StateFile, checkpointFrequency)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return state
+}
+
+// SaveScanState writes the state back to the --state-file.
+func (opts *Options) SaveScanState(state *historyarchive.ScanState) {
+	if state == nil || opts.CommandOpts.DryRun {
+		return
+	}
+	if err := state.Save(opts.StateFile); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("saved scan state to %s, %d failed checkpoints\n",
+		opts.StateFile, len(state.FailedCheckpoints()))
+}
+
+// Ranges returns the ranges to act on: the configured range or, with
+// --since-last, only the checkpoints in it which are not recorded as good in
+// the state.
+func (opts *Options) Ranges(arch *historyarchive.Archive, state *historyarchive.ScanState) []historyarchive.Range {
+	if !opts.SinceLast {
+		return []historyarchive.Range{opts.CommandOpts.Range}
+	}
+	ranges, err := arch.PendingCheckpointRanges(state, &opts.CommandOpts)
+	if err != nil {
+		log.Fatal(errors.Wrap(err, "Error computing pending checkpoints"))
+	}
+	log.Printf("%d pending ranges since last checkpoint 0x%8.8x\n", len(ranges), state.LastCheckpoint())
+	return ranges
+}
+
 func scan(a string, opts *Options) {
 	arch := historyarchive.MustConnect(a, opts.ConnectOpts)
 	opts.SetRange(arch, nil)
-	e1 := arch.Scan(&opts.CommandOpts)
-	missing, e2 := arch.ReportMissing(&opts.CommandOpts)
-	invalid, e3 := arch.ReportInvalid(&opts.CommandOpts)
-	if e1 != nil {
-		log.Fatal(e1)
-	}
-	if e2 != nil {
-		log.Fatal(e2)
+	state := opts.LoadScanState()
+	var errs []error
+	var missing, invalid bool
+	for i, rng := range opts.Ranges(arch, state) {
+		cmdOpts := opts.CommandOpts
+		cmdOpts.Range = rng
+		if i > 0 {
+			arch.ResetScan()
+		}
+		if state != nil {
+			arch.UseScanState(state)
+		}
+		e1 := arch.Scan(&cmdOpts)
+		m, e2 := arch.ReportMissing(&cmdOpts)
+		inv, e3 := arch.ReportInvalid(&cmdOpts)
+		if state != nil {
+			arch.UpdateScanState(state, &cmdOpts)
+		}
+		for _, e := range []error{e1, e2, e3} {
+			if e != nil {
+				errs = append(errs, e)
+			}
+		}
+		missing = missing || m
+		invalid = invalid || inv
 	}
-	if e3 != nil {
-		log.Fatal(e3)
+	opts.SaveScanState(state)
+	for _, e := range errs {
+		log.Fatal(e)
 	}
 	if missing {
 		log.Fatal("Some objects were missing")
@@ -146,8 +227,26 @@ func mirror(src string, dst string, opts *Options) {
 	srcArch := historyarchive.MustConnect(src, opts.ConnectOpts)
 	dstArch := historyarchive.MustConnect(dst, opts.ConnectOpts)
 	opts.SetRange(srcArch, dstArch)
+	state := opts.LoadScanState()
 	log.Printf("mirroring %v -> %v\n", src, dst)
-	e := historyarchive.Mirror(srcArch, dstArch, &opts.CommandOpts)
+	var e error
+	for i, rng := range opts.Ranges(srcArch, state) {
+		cmdOpts := opts.CommandOpts
+		cmdOpts.Range = rng
+		if i > 0 {
+			dstArch.ResetScan()
+		}
+		if state != nil {
+			dstArch.UseScanState(state)
+		}
+		if err := historyarchive.Mirror(srcArch, dstArch, &cmdOpts); err != nil {
+			e = err
+		}
+		if state != nil {
+			dstArch.UpdateScanState(state, &cmdOpts)
+		}
+	}
+	opts.SaveScanState(state)
 	if e != nil {
 		log.Fatal(e)
 	}
@@ -157,13 +256,118 @@ func repair(src string, dst string, opts *Options) {
 	srcArch := historyarchive.MustConnect(src, opts.ConnectOpts)
 	dstArch := historyarchive.MustConnect(dst, opts.ConnectOpts)
 	opts.SetRange(srcArch, dstArch)
+	state := opts.LoadScanState()
 	log.Printf("repairing %v -> %v\n", src, dst)
-	e := historyarchive.Repair(srcArch, dstArch, &opts.CommandOpts)
+	var e error
+	for i, rng := range opts.Ranges(dstArch, state) {
+		cmdOpts := opts.CommandOpts
+		cmdOpts.Range = rng
+		if i > 0 {
+			dstArch.ResetScan()
+		}
+		if state != nil {
+			dstArch.UseScanState(state)
+		}
+		if err := historyarchive.Repair(srcArch, dstArch, &cmdOpts); err != nil {
+			e = err
+		}
+		if state != nil {
+			dstArch.UpdateScanState(state, &cmdOpts)
+		}
+	}
+	opts.SaveScanState(state)
 	if e != nil {
 		log.Fatal(e)
 	}
 }
 
+func dumpBucket(a string, hash string, opts *Options) {
+	arch := historyarchive.MustConnect(a, opts.ConnectOpts)
+	h, err := historyarchive.DecodeHash(hash)
+	if err != nil {
+		log.Fatal(errors.Wrap(err, "Error decoding bucket hash"))
+	}
+	enc := json.NewEncoder(os.Stdout)
+	for record, err := range historyarchive.ReadBucket(arch, h) {
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err = enc.Encode(record); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+func diff(a string, from string, to string, opts *Options) {
+	arch := historyarchive.MustConnect(a, opts.ConnectOpts)
+	fromSeq, err := strconv.ParseUint(from, 10, 32)
+	if err != nil {
+		log.Fatal(errors.Wrap(err, "Error parsing first checkpoint"))
+	}
+	toSeq, err := strconv.ParseUint(to, 10, 32)
+	if err != nil {
+		log.Fatal(errors.Wrap(err, "Error parsing second checkpoint"))
+	}
+	bucketListType := xdr.BucketListTypeLive
+	if opts.HotArchive {
+		bucketListType = xdr.BucketListTypeHotArchive
+	}
+	log.Printf("diffing checkpoints %d -> %d of %v\n", fromSeq, toSeq, a)
+	entries, err := ingest.DiffCheckpoints(
+		context.Background(), arch, bucketListType, uint32(fromSeq), uint32(toSeq),
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	for _, entry := range entries {
+		if err = enc.Encode(entry); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+func monitor(archives []string, opts *Options) {
+	pool := map[string]historyarchive.ArchiveInterface{}
+	for _, a := range archives {
+		pool[a] = historyarchive.MustConnect(a, opts.ConnectOpts)
+	}
+
+	registry := prometheus.NewRegistry()
+	monitorOpts := historyarchive.MonitorOptions{
+		Interval:               opts.Monitor.Interval,
+		MaxLag:                 opts.Monitor.MaxLag,
+		InitialScanCheckpoints: opts.Monitor.InitialScan,
+		SkipOptional:           opts.CommandOpts.SkipOptional,
+		Registry:               registry,
+		Namespace:              "stellar_archivist",
+	}
+	if len(opts.Monitor.ReferenceArchives) > 0 {
+		reference, err := historyarchive.NewArchivePool(opts.Monitor.ReferenceArchives, opts.ConnectOpts)
+		if err != nil {
+			log.Fatal(errors.Wrap(err, "Error connecting to reference archives"))
+		}
+		monitorOpts.Reference = historyarchive.ArchiveReference(reference)
+	}
+	m, err := historyarchive.NewMonitor(pool, monitorOpts)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	mux.Handle("/health", m)
+	go func() {
+		log.Printf("serving metrics and health on %s\n", opts.Monitor.Listen)
+		log.Fatal(http.ListenAndServe(opts.Monitor.Listen, mux))
+	}()
+
+	log.Printf("monitoring %d archives every %s\n", len(pool), opts.Monitor.Interval)
+	if err := m.Run(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+}
+
 func main() {
 
 	var opts Options
@@ -304,16 +508,18 @@ func main() {
 		},
 	})
 
-	rootCmd.AddCommand(&cobra.Command{
+	scanCmd := &cobra.Command{
 		Use: "scan",
 		Run: func(cmd *cobra.Command, args []string) {
 			opts.SetupLogging()
 			opts.MaybeProfile()
 			scan(firstArg(args), &opts)
 		},
-	})
+	}
+	addStateFlags(scanCmd, &opts)
+	rootCmd.AddCommand(scanCmd)
 
-	rootCmd.AddCommand(&cobra.Command{
+	mirrorCmd := &cobra.Command{
 		Use: "mirror",
 		Run: func(cmd *cobra.Command, args []string) {
 			opts.SetupLogging()
@@ -321,9 +527,11 @@ func main() {
 			src, dst := srcDst(args)
 			mirror(src, dst, &opts)
 		},
-	})
+	}
+	addStateFlags(mirrorCmd, &opts)
+	rootCmd.AddCommand(mirrorCmd)
 
-	rootCmd.AddCommand(&cobra.Command{
+	repairCmd := &cobra.Command{
 		Use: "repair",
 		Run: func(cmd *cobra.Command, args []string) {
 			opts.SetupLogging()
@@ -331,7 +539,9 @@ func main() {
 			src, dst := srcDst(args)
 			repair(src, dst, &opts)
 		},
-	})
+	}
+	addStateFlags(repairCmd, &opts)
+	rootCmd.AddCommand(repairCmd)
 
 	rootCmd.AddCommand(&cobra.Command{
 		Use: "dumpxdr",
@@ -344,9 +554,100 @@ func main() {
 		},
 	})
 
+	rootCmd.AddCommand(&cobra.Command{
+		Use:   "dumpbucket <archive> <hash>",
+		Short: "print the entries of a bucket as JSON, one per line",
+		Run: func(cmd *cobra.Command, args []string) {
+			opts.SetupLogging()
+			if len(args) != 2 {
+				log.Fatal("require exactly 2 arguments")
+			}
+			dumpBucket(args[0], args[1], &opts)
+		},
+	})
+
+	diffCmd := &cobra.Command{
+		Use:   "diff <archive> <from-checkpoint> <to-checkpoint>",
+		Short: "print the ledger entries which differ between two checkpoints as JSON, one per line",
+		Run: func(cmd *cobra.Command, args []string) {
+			opts.SetupLogging()
+			opts.MaybeProfile()
+			if len(args) != 3 {
+				log.Fatal("require exactly 3 arguments")
+			}
+			diff(args[0], args[1], args[2], &opts)
+		},
+	}
+	diffCmd.Flags().BoolVar(
+		&opts.HotArchive,
+		"hot-archive",
+		false,
+		"diff the hot archive bucket list instead of the live bucket list",
+	)
+	rootCmd.AddCommand(diffCmd)
+
+	monitorCmd := &cobra.Command{
+		Use:   "monitor <archive>...",
+		Short: "continuously monitor archives, serving Prometheus metrics and a health endpoint",
+		Run: func(cmd *cobra.Command, args []string) {
+			opts.SetupLogging()
+			opts.MaybeProfile()
+			if len(args) == 0 {
+				log.Fatal("require at least 1 argument")
+			}
+			monitor(args, &opts)
+		},
+	}
+	monitorCmd.Flags().StringVar(
+		&opts.Monitor.Listen,
+		"listen",
+		":6061",
+		"address to serve /metrics and /health on",
+	)
+	monitorCmd.Flags().DurationVar(
+		&opts.Monitor.Interval,
+		"interval",
+		time.Minute,
+		"time between polls of the archives",
+	)
+	monitorCmd.Flags().StringSliceVar(
+		&opts.Monitor.ReferenceArchives,
+		"reference",
+		nil,
+		"archives used as the reference to compute lag (may be repeated)",
+	)
+	monitorCmd.Flags().Uint32Var(
+		&opts.Monitor.MaxLag,
+		"max-lag",
+		0,
+		"ledgers an archive may lag behind the reference before it is unhealthy (default two checkpoints)",
+	)
+	monitorCmd.Flags().Uint32Var(
+		&opts.Monitor.InitialScan,
+		"initial-scan",
+		1,
+		"number of recent checkpoints to scan for missing files on startup",
+	)
+	rootCmd.AddCommand(monitorCmd)
+
 	rootCmd.Execute()
 }
 
+func addStateFlags(cmd *cobra.Command, opts *Options) {
+	cmd.Flags().StringVar(
+		&opts.StateFile,
+		"state-file",
+		"",
+		"file recording the results of previous runs against the (destination) archive",
+	)
+	cmd.Flags().BoolVar(
+		&opts.SinceLast,
+		"since-last",
+		false,
+		"only act on checkpoints not recorded as good in --state-file",
+	)
+}
+
 func firstArg(args []string) string {
 	if len(args) == 0 {
 		return ""
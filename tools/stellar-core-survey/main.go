@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stellar/go/clients/stellarcore"
+	"github.com/stellar/go/clients/stellarcore/survey"
+)
+
+func main() {
+	exitCode := run(os.Args[1:], os.Stdout, os.Stderr)
+	os.Exit(exitCode)
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	cmd := &cobra.Command{
+		Use:   "stellar-core-survey",
+		Short: "Run a time sliced overlay survey through a stellar-core node and print the peer graph of the network.",
+		Args:  cobra.NoArgs,
+	}
+	cmd.SetArgs(args)
+	cmd.SetOutput(stderr)
+
+	coreURL := "http://localhost:11626"
+	skipCollect := false
+	nonce := uint32(0)
+	collectDuration := survey.DefaultCollectDuration
+	pollInterval := survey.DefaultPollInterval
+	settle := survey.DefaultSettle
+	format := "json"
+	output := ""
+	cmd.Flags().StringVar(&coreURL, "core-url", coreURL, "URL of the HTTP port of the stellar-core node running the survey")
+	cmd.Flags().BoolVar(&skipCollect, "skip-collect", skipCollect, "Skip the collecting phase and only collect the results of a survey that already collected")
+	cmd.Flags().Uint32Var(&nonce, "nonce", nonce, "Nonce of the survey, random if zero")
+	cmd.Flags().DurationVar(&collectDuration, "collect-duration", collectDuration, "Duration of the collecting phase")
+	cmd.Flags().DurationVar(&pollInterval, "poll-interval", pollInterval, "Interval between fetches of the survey results")
+	cmd.Flags().DurationVar(&settle, "settle", settle, "End the reporting phase after this long without new results")
+	cmd.Flags().StringVar(&format, "format", format, "Output format: json or dot")
+	cmd.Flags().StringVar(&output, "output", output, "Write the graph to this file instead of stdout")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if format != "json" && format != "dot" {
+			return fmt.Errorf("unknown format %q", format)
+		}
+		cmd.SilenceUsage = true
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		surveyor := &survey.Surveyor{
+			Core: &stellarcore.Client{
+				HTTP: &http.Client{Timeout: 30 * time.Second},
+				URL:  coreURL,
+			},
+			Nonce:           nonce,
+			CollectDuration: collectDuration,
+			PollInterval:    pollInterval,
+			Settle:          settle,
+		}
+		if !skipCollect {
+			if err := surveyor.Collect(ctx); err != nil {
+				return err
+			}
+		}
+		// An interrupted report still writes the graph assembled so far.
+		graph, err := surveyor.Report(ctx)
+		if graph == nil {
+			return err
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "survey interrupted: %v\n", err)
+		}
+
+		w := stdout
+		if output != "" {
+			f, err := os.Create(output)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		if format == "dot" {
+			err = graph.WriteDOT(w)
+		} else {
+			err = graph.WriteJSON(w)
+		}
+		if err != nil {
+			return err
+		}
+
+		stats := graph.Stats()
+		fmt.Fprintf(stderr, "%d nodes (%d responded, %d validators), %d connections\n",
+			stats.Nodes, stats.RespondedNodes, stats.Validators, stats.Edges)
+		return nil
+	}
+
+	err := cmd.Execute()
+	if err != nil {
+		return 1
+	}
+	return 0
+}
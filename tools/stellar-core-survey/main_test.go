@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCore serves the survey commands of a stellar-core node with one peer
+// which is connected to a node that does not respond.
+func newCore(t *testing.T) (*httptest.Server, *[]string) {
+	var lock sync.Mutex
+	var commands []string
+	surveyed := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+		commands = append(commands, strings.TrimPrefix(r.URL.Path, "/"))
+		switch r.URL.Path {
+		case "/startsurveycollecting", "/stopsurveycollecting":
+			fmt.Fprint(w, "Done")
+		case "/peers":
+			fmt.Fprint(w, `{"authenticated_peers":{"inbound":null,"outbound":[{"id":"GPEER"}]},"pending_peers":{}}`)
+		case "/surveytopologytimesliced":
+			if r.URL.Query().Get("node") == "GPEER" {
+				surveyed = true
+			}
+			fmt.Fprint(w, "Adding node.")
+		case "/getsurveyresult":
+			if !surveyed {
+				fmt.Fprint(w, `{"surveyInProgress":true,"topology":{}}`)
+				return
+			}
+			fmt.Fprint(w, `{"surveyInProgress":true,"topology":{"GPEER":{
+				"isValidator":true,"totalInboundPeerCount":1,"totalOutboundPeerCount":1,
+				"inboundPeers":[{"nodeId":"GLOCAL","version":"v21.0.0","averageLatencyMs":30}],
+				"outboundPeers":[{"nodeId":"GFAR","version":"v20.0.0","averageLatencyMs":120}]}}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &commands
+}
+
+func TestRunJSON(t *testing.T) {
+	core, commands := newCore(t)
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+
+	exitCode := run([]string{
+		"--core-url", core.URL,
+		"--nonce", "42",
+		"--collect-duration", "1ms",
+		"--poll-interval", "1ms",
+		"--settle", "20ms",
+	}, &stdout, &stderr)
+	require.Equal(t, 0, exitCode, stderr.String())
+	assert.Contains(t, stderr.String(), "3 nodes (1 responded, 1 validators), 2 connections\n")
+	assert.Equal(t, []string{"startsurveycollecting", "stopsurveycollecting", "peers", "surveytopologytimesliced"}, (*commands)[:4])
+
+	var graph struct {
+		Nodes []struct {
+			ID        string `json:"id"`
+			Version   string `json:"version"`
+			Responded bool   `json:"responded"`
+			Degree    int    `json:"degree"`
+		} `json:"nodes"`
+		Edges []struct {
+			From string `json:"from"`
+			To   string `json:"to"`
+		} `json:"edges"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(stdout.String()), &graph))
+	require.Len(t, graph.Nodes, 3)
+	assert.Equal(t, "GFAR", graph.Nodes[0].ID)
+	assert.Equal(t, "v20.0.0", graph.Nodes[0].Version)
+	assert.True(t, graph.Nodes[2].Responded)
+	assert.Equal(t, 2, graph.Nodes[2].Degree)
+	assert.Len(t, graph.Edges, 2)
+}
+
+func TestRunDOTSkipCollect(t *testing.T) {
+	core, commands := newCore(t)
+	output := filepath.Join(t.TempDir(), "survey.dot")
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+
+	exitCode := run([]string{
+		"--core-url", core.URL,
+		"--skip-collect",
+		"--format", "dot",
+		"--output", output,
+		"--poll-interval", "1ms",
+		"--settle", "20ms",
+	}, &stdout, &stderr)
+	require.Equal(t, 0, exitCode, stderr.String())
+	assert.Empty(t, stdout.String())
+	assert.Equal(t, "peers", (*commands)[0])
+
+	dot, err := os.ReadFile(output)
+	require.NoError(t, err)
+	assert.Contains(t, string(dot), `"GPEER" -> "GFAR" [label="120ms"];`)
+	assert.Contains(t, string(dot), `"GFAR" [label="GFAR\nv20.0.0", shape=ellipse, style=dashed];`)
+}
+
+func TestRunInvalidFormat(t *testing.T) {
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+
+	exitCode := run([]string{"--format", "svg"}, &stdout, &stderr)
+	assert.Equal(t, 1, exitCode)
+	assert.Contains(t, stderr.String(), `unknown format "svg"`)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stellar/go/clients/horizonclient"
+	"github.com/stellar/go/clients/stellartoml"
+)
+
+func main() {
+	exitCode := run(os.Args[1:], os.Stdout, os.Stderr)
+	os.Exit(exitCode)
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	cmd := &cobra.Command{
+		Use:   "stellar-toml-validate <domain>",
+		Short: "Validate the stellar.toml of a domain against SEP-1.",
+		Args:  cobra.ExactArgs(1),
+	}
+	cmd.SetArgs(args)
+	cmd.SetOutput(stderr)
+
+	file := ""
+	horizonURL := horizonclient.DefaultPublicNetClient.HorizonURL
+	skipIssuers := false
+	useHTTP := false
+	outJSON := false
+	strict := false
+	cmd.Flags().StringVar(&file, "file", file, "Validate a local stellar.toml file instead of fetching it from the domain")
+	cmd.Flags().StringVar(&horizonURL, "horizon-url", horizonURL, "Horizon used to check the home domain of currency issuers")
+	cmd.Flags().BoolVar(&skipIssuers, "skip-issuers", skipIssuers, "Do not check the home domain of currency issuers")
+	cmd.Flags().BoolVar(&useHTTP, "http", useHTTP, "Fetch the stellar.toml over http instead of https, for local testing")
+	cmd.Flags().BoolVar(&outJSON, "json", outJSON, "Print the report as JSON")
+	cmd.Flags().BoolVar(&strict, "strict", strict, "Fail on warnings as well as errors")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		domain := args[0]
+		httpClient := &http.Client{Timeout: 30 * time.Second}
+
+		var lookup stellartoml.AccountLookup
+		if !skipIssuers {
+			lookup = horizonLookup{Client: &horizonclient.Client{
+				HorizonURL: horizonURL,
+				HTTP:       httpClient,
+			}}
+		}
+
+		var report *stellartoml.Report
+		if file != "" {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			report = &stellartoml.Report{
+				Domain: domain,
+				Issues: stellartoml.ValidateFile(domain, data, lookup),
+			}
+		} else {
+			client := &stellartoml.Client{HTTP: httpClient, UseHTTP: useHTTP}
+			var err error
+			report, err = client.Validate(domain, lookup)
+			if err != nil {
+				return err
+			}
+		}
+
+		if outJSON {
+			if report.Issues == nil {
+				report.Issues = []stellartoml.Issue{}
+			}
+			enc := json.NewEncoder(stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(report); err != nil {
+				return err
+			}
+		} else {
+			for _, issue := range report.Issues {
+				fmt.Fprintln(stdout, issue)
+			}
+			fmt.Fprintf(stdout, "%s: %d errors, %d warnings\n",
+				domain, len(report.Errors()), len(report.Warnings()))
+		}
+
+		if !report.Valid() || (strict && len(report.Warnings()) > 0) {
+			cmd.SilenceUsage = true
+			return errInvalid
+		}
+		return nil
+	}
+
+	err := cmd.Execute()
+	if err != nil {
+		return 1
+	}
+	return 0
+}
+
+var errInvalid = errors.New("stellar.toml is not valid")
+
+// horizonLookup loads the home domain of accounts from Horizon.
+type horizonLookup struct {
+	Client horizonclient.ClientInterface
+}
+
+func (l horizonLookup) HomeDomain(accountID string) (string, error) {
+	account, err := l.Client.AccountDetail(horizonclient.AccountRequest{AccountID: accountID})
+	if err != nil {
+		return "", err
+	}
+	return account.HomeDomain, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stellar/go/clients/stellartoml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const issuer = "GCZJM35NKGVK47BB4SPBDV25477PZYIYPVVG453LPYFNXLS3FGHDXOCM"
+
+const validToml = `
+VERSION="2.0.0"
+NETWORK_PASSPHRASE="Public Global Stellar Network ; September 2015"
+ACCOUNTS=["` + issuer + `"]
+
+[[CURRENCIES]]
+code="USD"
+issuer="` + issuer + `"
+`
+
+// newServer serves a stellar.toml and the Horizon account of issuer with the
+// server's host as home domain.
+func newServer(t *testing.T, toml string) (*httptest.Server, string) {
+	var host string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/stellar.toml":
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprint(w, toml)
+		case "/accounts/" + issuer:
+			w.Header().Set("Content-Type", "application/hal+json")
+			fmt.Fprintf(w, `{"id":%q,"account_id":%q,"home_domain":%q}`, issuer, issuer, host)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	host = u.Host
+	return server, host
+}
+
+func TestRun_valid(t *testing.T) {
+	server, domain := newServer(t, validToml)
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+
+	exitCode := run([]string{domain, "--http", "--horizon-url", server.URL}, &stdout, &stderr)
+
+	assert.Equal(t, 0, exitCode)
+	assert.Equal(t, domain+": 0 errors, 0 warnings\n", stdout.String())
+	assert.Equal(t, "", stderr.String())
+}
+
+func TestRun_invalid(t *testing.T) {
+	server, domain := newServer(t, strings.Replace(validToml, `ACCOUNTS=["`+issuer, `ACCOUNTS=["GBAD`, 1))
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+
+	exitCode := run([]string{domain, "--http", "--horizon-url", server.URL}, &stdout, &stderr)
+
+	assert.Equal(t, 1, exitCode)
+	assert.Contains(t, stdout.String(), `error: ACCOUNTS[0]: "GBAD" is not a valid account ID`)
+	assert.Contains(t, stdout.String(), domain+": 1 errors, 0 warnings\n")
+	assert.Equal(t, "Error: stellar.toml is not valid\n", stderr.String())
+}
+
+func TestRun_issuerHomeDomain(t *testing.T) {
+	server, _ := newServer(t, validToml)
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "stellar.toml")
+	require.NoError(t, os.WriteFile(file, []byte(validToml), 0o600))
+
+	exitCode := run([]string{"example.com", "--file", file, "--horizon-url", server.URL}, &stdout, &stderr)
+
+	assert.Equal(t, 1, exitCode)
+	assert.Contains(t, stdout.String(), `error: CURRENCIES[0].issuer: issuer home_domain is "`)
+
+	// Skipping issuers ignores the mismatched home domain.
+	stdout.Reset()
+	stderr.Reset()
+	exitCode = run([]string{"example.com", "--file", file, "--skip-issuers"}, &stdout, &stderr)
+	assert.Equal(t, 0, exitCode)
+	assert.Equal(t, "example.com: 0 errors, 0 warnings\n", stdout.String())
+}
+
+func TestRun_jsonAndStrict(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "stellar.toml")
+	require.NoError(t, os.WriteFile(file, []byte(`ACCOUNTS=["`+issuer+`"]`), 0o600))
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+
+	exitCode := run([]string{"example.com", "--file", file, "--json"}, &stdout, &stderr)
+
+	assert.Equal(t, 0, exitCode)
+	var report stellartoml.Report
+	require.NoError(t, json.Unmarshal([]byte(stdout.String()), &report))
+	assert.Equal(t, "example.com", report.Domain)
+	assert.Len(t, report.Warnings(), 2)
+
+	stdout.Reset()
+	exitCode = run([]string{"example.com", "--file", file, "--strict"}, &stdout, &stderr)
+	assert.Equal(t, 1, exitCode)
+	assert.Contains(t, stdout.String(), "example.com: 0 errors, 2 warnings\n")
+}
+
+func TestRun_missingDomain(t *testing.T) {
+	stdout := strings.Builder{}
+	stderr := strings.Builder{}
+
+	exitCode := run([]string{}, &stdout, &stderr)
+
+	assert.Equal(t, 1, exitCode)
+	assert.Equal(t, "", stdout.String())
+	assert.Contains(t, stderr.String(), "accepts 1 arg(s), received 0")
+}
@@ -0,0 +1,174 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/txnbuild"
+)
+
+const (
+	// maxOperations is the maximum number of operations in a transaction.
+	maxOperations = 100
+	// maxMergesPerTransaction is the number of channels merged by a single
+	// transaction, leaving one of the 20 signatures for the funder.
+	maxMergesPerTransaction = 19
+	// setupTimeout is the timeout, in seconds, of the transactions built by
+	// Funder.
+	setupTimeout = 300
+)
+
+// Funder creates, funds and merges channel accounts on behalf of the account
+// which pays for them. It submits its transactions one at a time.
+type Funder struct {
+	Backend           Backend
+	NetworkPassphrase string
+	// Account is the funding account. It is the source of every transaction
+	// built by Funder and receives the balance of merged channels.
+	Account *keypair.Full
+	// BaseFee is the base fee of the transactions. txnbuild.MinBaseFee is
+	// used if it is zero.
+	BaseFee int64
+}
+
+// CreateChannels creates count channel accounts with random keys, each
+// funded with startingBalance lumens. It returns the keys of the channels
+// created so far along with any error. If a transaction fails to submit, for
+// example because ctx is done while it is pending, the keys of its channels
+// are returned as well since it may still be applied; the caller should
+// check which of the channels exist before discarding any key.
+func (f Funder) CreateChannels(ctx context.Context, count int, startingBalance string) ([]*keypair.Full, error) {
+	var created, pending []*keypair.Full
+	err := f.batches(ctx, count, maxOperations, func(source *txnbuild.SimpleAccount, n int) (*txnbuild.Transaction, error) {
+		pending = make([]*keypair.Full, 0, n)
+		ops := make([]txnbuild.Operation, 0, n)
+		for i := 0; i < n; i++ {
+			kp, err := keypair.Random()
+			if err != nil {
+				return nil, fmt.Errorf("could not generate channel key: %w", err)
+			}
+			pending = append(pending, kp)
+			ops = append(ops, &txnbuild.CreateAccount{
+				Destination: kp.Address(),
+				Amount:      startingBalance,
+			})
+		}
+		return f.build(source, ops)
+	}, func(int) { created = append(created, pending...) })
+	var unconfirmed unconfirmedError
+	if errors.As(err, &unconfirmed) {
+		created = append(created, pending...)
+	}
+	return created, err
+}
+
+// FundChannels pays amount lumens to each of the channels.
+func (f Funder) FundChannels(ctx context.Context, channels []string, amount string) error {
+	offset := 0
+	return f.batches(ctx, len(channels), maxOperations, func(source *txnbuild.SimpleAccount, n int) (*txnbuild.Transaction, error) {
+		ops := make([]txnbuild.Operation, 0, n)
+		for _, channel := range channels[offset : offset+n] {
+			ops = append(ops, &txnbuild.Payment{
+				Destination: channel,
+				Amount:      amount,
+				Asset:       txnbuild.NativeAsset{},
+			})
+		}
+		return f.build(source, ops)
+	}, func(n int) { offset += n })
+}
+
+// MergeChannels merges the channels into the funding account. The channels
+// must hold no trust lines, offers or other subentries.
+func (f Funder) MergeChannels(ctx context.Context, channels []*keypair.Full) error {
+	offset := 0
+	return f.batches(ctx, len(channels), maxMergesPerTransaction, func(source *txnbuild.SimpleAccount, n int) (*txnbuild.Transaction, error) {
+		batch := channels[offset : offset+n]
+		ops := make([]txnbuild.Operation, 0, n)
+		for _, channel := range batch {
+			ops = append(ops, &txnbuild.AccountMerge{
+				Destination:   f.Account.Address(),
+				SourceAccount: channel.Address(),
+			})
+		}
+		tx, err := f.build(source, ops)
+		if err != nil {
+			return nil, err
+		}
+		return tx.Sign(f.NetworkPassphrase, batch...)
+	}, func(n int) { offset += n })
+}
+
+// unconfirmedError is returned by batches when submitting a transaction
+// fails. The transaction may still be applied.
+type unconfirmedError struct {
+	err error
+}
+
+func (e unconfirmedError) Error() string {
+	return "could not submit transaction: " + e.err.Error()
+}
+
+func (e unconfirmedError) Unwrap() error {
+	return e.err
+}
+
+// batches submits the transactions returned by build, each covering at most
+// size of the total items, until all items are covered. done is called with
+// the number of items of every successfully submitted transaction.
+func (f Funder) batches(
+	ctx context.Context,
+	total, size int,
+	build func(source *txnbuild.SimpleAccount, n int) (*txnbuild.Transaction, error),
+	done func(n int),
+) error {
+	if total == 0 {
+		return nil
+	}
+	sequence, err := f.Backend.SequenceNumber(ctx, f.Account.Address())
+	if err != nil {
+		return fmt.Errorf("could not load sequence number of %s: %w", f.Account.Address(), err)
+	}
+	source := &txnbuild.SimpleAccount{AccountID: f.Account.Address(), Sequence: sequence}
+
+	for remaining := total; remaining > 0; {
+		n := remaining
+		if n > size {
+			n = size
+		}
+		tx, err := build(source, n)
+		if err != nil {
+			return err
+		}
+		tx, err = tx.Sign(f.NetworkPassphrase, f.Account)
+		if err != nil {
+			return fmt.Errorf("could not sign transaction: %w", err)
+		}
+		if _, err := f.Backend.Submit(ctx, tx); err != nil {
+			return unconfirmedError{err}
+		}
+		done(n)
+		remaining -= n
+	}
+	return nil
+}
+
+func (f Funder) build(source *txnbuild.SimpleAccount, ops []txnbuild.Operation) (*txnbuild.Transaction, error) {
+	baseFee := f.BaseFee
+	if baseFee == 0 {
+		baseFee = txnbuild.MinBaseFee
+	}
+	tx, err := txnbuild.NewTransaction(txnbuild.TransactionParams{
+		SourceAccount:        source,
+		IncrementSequenceNum: true,
+		Operations:           ops,
+		BaseFee:              baseFee,
+		Preconditions:        txnbuild.Preconditions{TimeBounds: txnbuild.NewTimeout(setupTimeout)},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not build transaction: %w", err)
+	}
+	return tx, nil
+}
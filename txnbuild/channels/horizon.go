@@ -0,0 +1,55 @@
+package channels
+
+import (
+	"context"
+
+	"github.com/stellar/go/clients/horizonclient"
+	"github.com/stellar/go/txnbuild"
+)
+
+// HorizonBackend is a Backend which loads accounts from and submits
+// transactions to Horizon.
+type HorizonBackend struct {
+	Client horizonclient.ClientInterface
+	// SkipMemoRequiredCheck disables the SEP-29 check that destination
+	// accounts requiring a memo receive one. Only set it if every
+	// transaction submitted through the backend is known to be safe without
+	// the check.
+	SkipMemoRequiredCheck bool
+}
+
+// SequenceNumber implements Backend.
+func (b HorizonBackend) SequenceNumber(_ context.Context, address string) (int64, error) {
+	account, err := b.Client.AccountDetail(horizonclient.AccountRequest{AccountID: address})
+	if err != nil {
+		return 0, err
+	}
+	return account.GetSequenceNumber()
+}
+
+// Submit implements Backend. Horizon submits transactions synchronously, so
+// the context is not used.
+func (b HorizonBackend) Submit(_ context.Context, tx *txnbuild.Transaction) (string, error) {
+	response, err := b.Client.SubmitTransactionWithOptions(tx, horizonclient.SubmitTxOpts{
+		SkipMemoRequiredCheck: b.SkipMemoRequiredCheck,
+	})
+	if err != nil {
+		if isHorizonBadSequence(err) {
+			return "", badSequenceError{err}
+		}
+		return "", err
+	}
+	return response.Hash, nil
+}
+
+func isHorizonBadSequence(err error) bool {
+	herr := horizonclient.GetError(err)
+	if herr == nil {
+		return false
+	}
+	codes, err := herr.ResultCodes()
+	if err != nil || codes == nil {
+		return false
+	}
+	return codes.TransactionCode == "tx_bad_seq" || codes.InnerTransactionCode == "tx_bad_seq"
+}
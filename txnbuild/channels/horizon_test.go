@@ -0,0 +1,80 @@
+package channels
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stellar/go/clients/horizonclient"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	hProtocol "github.com/stellar/go/protocols/horizon"
+	"github.com/stellar/go/support/render/problem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func horizonFailure(code, inner string) error {
+	codes := map[string]interface{}{"transaction": code}
+	if inner != "" {
+		codes["inner_transaction"] = inner
+	}
+	return &horizonclient.Error{Problem: problem.P{
+		Title:  "Transaction Failed",
+		Type:   "transaction_failed",
+		Status: 400,
+		Extras: map[string]interface{}{"result_codes": codes},
+	}}
+}
+
+func TestHorizonBackend(t *testing.T) {
+	client := &horizonclient.MockClient{}
+	backend := HorizonBackend{Client: client}
+	channel := keypair.MustRandom()
+	payer := keypair.MustRandom()
+
+	client.On("AccountDetail", horizonclient.AccountRequest{AccountID: channel.Address()}).
+		Return(hProtocol.Account{AccountID: channel.Address(), Sequence: 4294967296}, nil)
+	sequence, err := backend.SequenceNumber(context.Background(), channel.Address())
+	require.NoError(t, err)
+	assert.Equal(t, int64(4294967296), sequence)
+
+	pool, err := NewPool(backend, network.TestNetworkPassphrase, channel)
+	require.NoError(t, err)
+	pool.MaxRetries = 0
+
+	// The SEP-29 memo required check runs unless it is skipped explicitly.
+	opts := horizonclient.SubmitTxOpts{}
+	client.On("SubmitTransactionWithOptions", mock.Anything, opts).
+		Return(hProtocol.Transaction{Hash: "abc"}, nil).Once()
+	hash, err := pool.Submit(context.Background(), payment(payer))
+	require.NoError(t, err)
+	assert.Equal(t, "abc", hash)
+
+	for _, failure := range []error{
+		horizonFailure("tx_bad_seq", ""),
+		horizonFailure("tx_fee_bump_inner_failed", "tx_bad_seq"),
+	} {
+		client.On("SubmitTransactionWithOptions", mock.Anything, opts).
+			Return(hProtocol.Transaction{}, failure).Once()
+		_, err = pool.Submit(context.Background(), payment(payer))
+		assert.ErrorIs(t, err, ErrBadSequence)
+		assert.Equal(t, failure, horizonclient.GetError(err))
+	}
+
+	client.On("SubmitTransactionWithOptions", mock.Anything, opts).
+		Return(hProtocol.Transaction{}, horizonFailure("tx_failed", "")).Once()
+	_, err = pool.Submit(context.Background(), payment(payer))
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrBadSequence)
+
+	skipping, err := NewPool(HorizonBackend{Client: client, SkipMemoRequiredCheck: true}, network.TestNetworkPassphrase, channel)
+	require.NoError(t, err)
+	client.On("SubmitTransactionWithOptions", mock.Anything, horizonclient.SubmitTxOpts{SkipMemoRequiredCheck: true}).
+		Return(hProtocol.Transaction{Hash: "def"}, nil).Once()
+	hash, err = skipping.Submit(context.Background(), payment(payer))
+	require.NoError(t, err)
+	assert.Equal(t, "def", hash)
+
+	client.AssertExpectations(t)
+}
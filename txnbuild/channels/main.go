@@ -0,0 +1,269 @@
+// Package channels manages a pool of channel accounts used as the source
+// accounts of transactions, so that many transactions paid for by the same
+// account can be submitted concurrently.
+//
+// A transaction built with a channel as its source consumes the channel's
+// sequence number instead of the payer's. The operations of the transaction
+// must therefore set their source account to the payer, and the transaction
+// must be signed by both the payer and the channel. The pool tracks the
+// sequence number of every channel locally and reloads it from the network
+// when a submission is rejected with tx_bad_seq.
+package channels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/txnbuild"
+)
+
+var (
+	// ErrBadSequence is matched by the errors a Backend returns when a
+	// transaction is rejected because of its sequence number.
+	ErrBadSequence = errors.New("bad sequence number")
+
+	// ErrNotSubmitted can be passed to Lease.Release when the transaction
+	// built with the lease was never submitted, so the channel keeps its
+	// sequence number.
+	ErrNotSubmitted = errors.New("transaction not submitted")
+
+	// ErrMissingOperationSource is returned by Pool.Submit when an operation
+	// does not set its source account. Such an operation would be applied to
+	// the channel instead of the payer.
+	ErrMissingOperationSource = errors.New("operation has no source account")
+)
+
+// badSequenceError is the error returned by backends for transactions
+// rejected with tx_bad_seq. It matches ErrBadSequence and exposes the
+// original error through both Unwrap and Cause, so that helpers such as
+// horizonclient.GetError keep working.
+type badSequenceError struct {
+	err error
+}
+
+func (e badSequenceError) Error() string {
+	return ErrBadSequence.Error() + ": " + e.err.Error()
+}
+
+func (e badSequenceError) Is(target error) bool {
+	return target == ErrBadSequence
+}
+
+func (e badSequenceError) Unwrap() error {
+	return e.err
+}
+
+func (e badSequenceError) Cause() error {
+	return e.err
+}
+
+// Backend loads sequence numbers and submits transactions. HorizonBackend
+// and RPCBackend implement it.
+type Backend interface {
+	// SequenceNumber returns the current sequence number of the account.
+	SequenceNumber(ctx context.Context, address string) (int64, error)
+	// Submit submits the transaction and waits until it is included in a
+	// ledger. It returns the transaction hash on success. Errors caused by a
+	// bad sequence number must match ErrBadSequence with errors.Is.
+	Submit(ctx context.Context, tx *txnbuild.Transaction) (string, error)
+}
+
+// DefaultMaxRetries is the default value of Pool.MaxRetries.
+const DefaultMaxRetries = 1
+
+// Pool leases channel accounts to concurrent callers. It is safe for
+// concurrent use.
+type Pool struct {
+	// MaxRetries is the number of times Submit rebuilds and resubmits a
+	// transaction rejected with a bad sequence number.
+	MaxRetries int
+
+	backend           Backend
+	networkPassphrase string
+	channels          []*channel
+	idle              chan *channel
+}
+
+type channel struct {
+	keypair  *keypair.Full
+	sequence int64
+	synced   bool
+}
+
+// NewPool returns a Pool of the given channel accounts. The accounts must
+// exist on the network; Funder.CreateChannels creates them. Sequence numbers are
+// loaded when a channel is first leased.
+func NewPool(backend Backend, networkPassphrase string, channels ...*keypair.Full) (*Pool, error) {
+	if len(channels) == 0 {
+		return nil, errors.New("at least one channel is required")
+	}
+	pool := &Pool{
+		MaxRetries:        DefaultMaxRetries,
+		backend:           backend,
+		networkPassphrase: networkPassphrase,
+		idle:              make(chan *channel, len(channels)),
+	}
+	seen := map[string]bool{}
+	for _, kp := range channels {
+		if seen[kp.Address()] {
+			return nil, fmt.Errorf("channel %s is listed more than once", kp.Address())
+		}
+		seen[kp.Address()] = true
+		ch := &channel{keypair: kp}
+		pool.channels = append(pool.channels, ch)
+		pool.idle <- ch
+	}
+	return pool, nil
+}
+
+// Size returns the number of channels in the pool.
+func (p *Pool) Size() int {
+	return len(p.channels)
+}
+
+// Addresses returns the addresses of the channels in the pool.
+func (p *Pool) Addresses() []string {
+	addresses := make([]string, 0, len(p.channels))
+	for _, ch := range p.channels {
+		addresses = append(addresses, ch.keypair.Address())
+	}
+	return addresses
+}
+
+// Lease waits until a channel is idle and leases it to the caller, who must
+// call Release once the transaction built with it has been submitted.
+func (p *Pool) Lease(ctx context.Context) (*Lease, error) {
+	var ch *channel
+	select {
+	case ch = <-p.idle:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if !ch.synced {
+		sequence, err := p.backend.SequenceNumber(ctx, ch.keypair.Address())
+		if err != nil {
+			p.idle <- ch
+			return nil, fmt.Errorf("could not load sequence number of channel %s: %w", ch.keypair.Address(), err)
+		}
+		ch.sequence = sequence
+		ch.synced = true
+	}
+
+	return &Lease{
+		Account: &txnbuild.SimpleAccount{
+			AccountID: ch.keypair.Address(),
+			Sequence:  ch.sequence,
+		},
+		pool:    p,
+		channel: ch,
+	}, nil
+}
+
+// Lease is a channel leased from a Pool. It must not be used by more than one
+// goroutine at a time.
+type Lease struct {
+	// Account is the channel to use as the source account of the
+	// transaction, with IncrementSequenceNum set in the
+	// TransactionParams.
+	Account *txnbuild.SimpleAccount
+
+	pool    *Pool
+	channel *channel
+	once    sync.Once
+}
+
+// Address returns the address of the leased channel.
+func (l *Lease) Address() string {
+	return l.channel.keypair.Address()
+}
+
+// Sign signs the transaction with the key of the leased channel.
+func (l *Lease) Sign(tx *txnbuild.Transaction) (*txnbuild.Transaction, error) {
+	return tx.Sign(l.pool.networkPassphrase, l.channel.keypair)
+}
+
+// Release returns the channel to the pool. err is the result of submitting
+// the transaction built with the lease. If it is nil the sequence number of
+// Account is kept; if it is ErrNotSubmitted the channel keeps its previous
+// sequence number; otherwise the sequence number is reloaded from the
+// backend when the channel is next leased. Release may be called more than
+// once; calls after the first have no effect.
+func (l *Lease) Release(err error) {
+	l.once.Do(func() {
+		switch {
+		case err == nil:
+			l.channel.sequence = l.Account.Sequence
+		case errors.Is(err, ErrNotSubmitted):
+		default:
+			l.channel.synced = false
+		}
+		l.pool.idle <- l.channel
+	})
+}
+
+// BuildFunc builds and signs a transaction with source as its source
+// account. Every operation must set its source account, usually to the
+// account paying for the operation.
+type BuildFunc func(source *txnbuild.SimpleAccount) (*txnbuild.Transaction, error)
+
+// Submit leases a channel, builds the transaction with build, signs it with
+// the channel and submits it. If the transaction is rejected with a bad
+// sequence number it is rebuilt on another lease, up to MaxRetries times.
+// It returns the hash of the transaction.
+func (p *Pool) Submit(ctx context.Context, build BuildFunc) (string, error) {
+	for attempt := 0; ; attempt++ {
+		hash, err := p.submit(ctx, build)
+		if err == nil {
+			return hash, nil
+		}
+		if !errors.Is(err, ErrBadSequence) || attempt >= p.MaxRetries {
+			return "", err
+		}
+	}
+}
+
+func (p *Pool) submit(ctx context.Context, build BuildFunc) (string, error) {
+	lease, err := p.Lease(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := build(lease.Account)
+	if err != nil {
+		lease.Release(ErrNotSubmitted)
+		return "", fmt.Errorf("could not build transaction: %w", err)
+	}
+	if err := checkTransaction(tx, lease.Address()); err != nil {
+		lease.Release(ErrNotSubmitted)
+		return "", err
+	}
+	tx, err = lease.Sign(tx)
+	if err != nil {
+		lease.Release(ErrNotSubmitted)
+		return "", fmt.Errorf("could not sign transaction: %w", err)
+	}
+
+	hash, err := p.backend.Submit(ctx, tx)
+	lease.Release(err)
+	return hash, err
+}
+
+// checkTransaction verifies that tx was built with the channel as its
+// source account and that none of its operations fall back to the channel.
+func checkTransaction(tx *txnbuild.Transaction, channel string) error {
+	source := tx.SourceAccount()
+	if source.AccountID != channel {
+		return fmt.Errorf("transaction source account is %s, not channel %s", source.AccountID, channel)
+	}
+	for i, op := range tx.Operations() {
+		opSource := op.GetSourceAccount()
+		if opSource == "" {
+			return fmt.Errorf("operation %d: %w", i, ErrMissingOperationSource)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,276 @@
+package channels
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBackend is a Backend which keeps sequence numbers in memory and
+// applies a transaction if its sequence number is the next one.
+type fakeBackend struct {
+	lock      sync.Mutex
+	sequences map[string]int64
+	loads     map[string]int
+	submitted []*txnbuild.Transaction
+	// fail is returned by the next call to Submit if set.
+	fail error
+}
+
+func newFakeBackend() *fakeBackend {
+	return &fakeBackend{sequences: map[string]int64{}, loads: map[string]int{}}
+}
+
+func (b *fakeBackend) SequenceNumber(_ context.Context, address string) (int64, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	sequence, ok := b.sequences[address]
+	if !ok {
+		return 0, fmt.Errorf("account %s not found", address)
+	}
+	b.loads[address]++
+	return sequence, nil
+}
+
+func (b *fakeBackend) Submit(_ context.Context, tx *txnbuild.Transaction) (string, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.fail != nil {
+		err := b.fail
+		b.fail = nil
+		return "", err
+	}
+	source := tx.SourceAccount()
+	if b.sequences[source.AccountID]+1 != source.Sequence {
+		return "", fmt.Errorf("%w: tx_bad_seq", ErrBadSequence)
+	}
+	b.sequences[source.AccountID] = source.Sequence
+	b.submitted = append(b.submitted, tx)
+	for _, op := range tx.Operations() {
+		if create, ok := op.(*txnbuild.CreateAccount); ok {
+			b.sequences[create.Destination] = 1 << 32
+		}
+	}
+	return tx.HashHex(network.TestNetworkPassphrase)
+}
+
+func payment(payer *keypair.Full) BuildFunc {
+	return func(source *txnbuild.SimpleAccount) (*txnbuild.Transaction, error) {
+		tx, err := txnbuild.NewTransaction(txnbuild.TransactionParams{
+			SourceAccount:        source,
+			IncrementSequenceNum: true,
+			Operations: []txnbuild.Operation{&txnbuild.Payment{
+				Destination:   keypair.MustRandom().Address(),
+				Amount:        "1",
+				Asset:         txnbuild.NativeAsset{},
+				SourceAccount: payer.Address(),
+			}},
+			BaseFee:       txnbuild.MinBaseFee,
+			Preconditions: txnbuild.Preconditions{TimeBounds: txnbuild.NewInfiniteTimeout()},
+		})
+		if err != nil {
+			return nil, err
+		}
+		return tx.Sign(network.TestNetworkPassphrase, payer)
+	}
+}
+
+func newTestPool(t *testing.T, backend *fakeBackend, n int) (*Pool, []*keypair.Full) {
+	channels := make([]*keypair.Full, n)
+	for i := range channels {
+		channels[i] = keypair.MustRandom()
+		backend.sequences[channels[i].Address()] = int64(i+1) << 32
+	}
+	pool, err := NewPool(backend, network.TestNetworkPassphrase, channels...)
+	require.NoError(t, err)
+	return pool, channels
+}
+
+func TestNewPool(t *testing.T) {
+	_, err := NewPool(newFakeBackend(), network.TestNetworkPassphrase)
+	assert.EqualError(t, err, "at least one channel is required")
+
+	kp := keypair.MustRandom()
+	_, err = NewPool(newFakeBackend(), network.TestNetworkPassphrase, kp, kp)
+	assert.EqualError(t, err, fmt.Sprintf("channel %s is listed more than once", kp.Address()))
+
+	pool, channels := newTestPool(t, newFakeBackend(), 3)
+	assert.Equal(t, 3, pool.Size())
+	assert.Equal(t, []string{channels[0].Address(), channels[1].Address(), channels[2].Address()}, pool.Addresses())
+}
+
+func TestPoolSubmitConcurrently(t *testing.T) {
+	backend := newFakeBackend()
+	pool, channels := newTestPool(t, backend, 4)
+	payer := keypair.MustRandom()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	for i := 0; i < 40; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := pool.Submit(context.Background(), payment(payer))
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	require.Len(t, backend.submitted, 40)
+	total := int64(0)
+	for i, channel := range channels {
+		// Sequence numbers are loaded once and then tracked locally.
+		assert.Equal(t, 1, backend.loads[channel.Address()])
+		total += backend.sequences[channel.Address()] - int64(i+1)<<32
+	}
+	assert.Equal(t, int64(40), total)
+
+	for _, tx := range backend.submitted {
+		assert.Len(t, tx.Signatures(), 2)
+	}
+}
+
+func TestPoolResyncsOnBadSequence(t *testing.T) {
+	backend := newFakeBackend()
+	pool, channels := newTestPool(t, backend, 1)
+	payer := keypair.MustRandom()
+	address := channels[0].Address()
+
+	_, err := pool.Submit(context.Background(), payment(payer))
+	require.NoError(t, err)
+
+	// The channel is used elsewhere, so its local sequence number is stale.
+	backend.sequences[address] += 5
+	_, err = pool.Submit(context.Background(), payment(payer))
+	require.NoError(t, err)
+	assert.Equal(t, 2, backend.loads[address])
+	assert.Equal(t, int64(1)<<32+7, backend.sequences[address])
+
+	// Without retries the bad sequence number is returned.
+	pool.MaxRetries = 0
+	backend.sequences[address] += 5
+	_, err = pool.Submit(context.Background(), payment(payer))
+	assert.ErrorIs(t, err, ErrBadSequence)
+	_, err = pool.Submit(context.Background(), payment(payer))
+	require.NoError(t, err)
+	assert.Equal(t, 3, backend.loads[address])
+}
+
+func TestPoolSubmitErrors(t *testing.T) {
+	backend := newFakeBackend()
+	pool, channels := newTestPool(t, backend, 1)
+	payer := keypair.MustRandom()
+	address := channels[0].Address()
+
+	_, err := pool.Submit(context.Background(), func(source *txnbuild.SimpleAccount) (*txnbuild.Transaction, error) {
+		return txnbuild.NewTransaction(txnbuild.TransactionParams{
+			SourceAccount:        source,
+			IncrementSequenceNum: true,
+			Operations:           []txnbuild.Operation{&txnbuild.BumpSequence{BumpTo: 10}},
+			BaseFee:              txnbuild.MinBaseFee,
+			Preconditions:        txnbuild.Preconditions{TimeBounds: txnbuild.NewInfiniteTimeout()},
+		})
+	})
+	assert.ErrorIs(t, err, ErrMissingOperationSource)
+
+	_, err = pool.Submit(context.Background(), func(*txnbuild.SimpleAccount) (*txnbuild.Transaction, error) {
+		return nil, fmt.Errorf("boom")
+	})
+	assert.EqualError(t, err, "could not build transaction: boom")
+
+	// Transactions which were not submitted do not consume the sequence number.
+	assert.Empty(t, backend.submitted)
+	assert.Equal(t, 1, backend.loads[address])
+
+	backend.fail = fmt.Errorf("timeout")
+	_, err = pool.Submit(context.Background(), payment(payer))
+	assert.EqualError(t, err, "timeout")
+	_, err = pool.Submit(context.Background(), payment(payer))
+	require.NoError(t, err)
+	assert.Equal(t, 2, backend.loads[address])
+}
+
+func TestLease(t *testing.T) {
+	backend := newFakeBackend()
+	pool, channels := newTestPool(t, backend, 1)
+
+	lease, err := pool.Lease(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, channels[0].Address(), lease.Address())
+	assert.Equal(t, int64(1)<<32, lease.Account.Sequence)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = pool.Lease(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	lease.Account.Sequence++
+	lease.Release(nil)
+	lease.Release(nil)
+
+	lease, err = pool.Lease(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1)<<32+1, lease.Account.Sequence)
+	lease.Account.Sequence++
+	lease.Release(ErrNotSubmitted)
+
+	lease, err = pool.Lease(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1)<<32+1, lease.Account.Sequence)
+	assert.Equal(t, 1, backend.loads[channels[0].Address()])
+	lease.Release(nil)
+}
+
+func TestFunder(t *testing.T) {
+	backend := newFakeBackend()
+	funder := Funder{
+		Backend:           backend,
+		NetworkPassphrase: network.TestNetworkPassphrase,
+		Account:           keypair.MustRandom(),
+	}
+	backend.sequences[funder.Account.Address()] = 1 << 32
+
+	channels, err := funder.CreateChannels(context.Background(), 150, "2")
+	require.NoError(t, err)
+	assert.Len(t, channels, 150)
+	require.Len(t, backend.submitted, 2)
+	assert.Len(t, backend.submitted[0].Operations(), 100)
+	assert.Len(t, backend.submitted[1].Operations(), 50)
+
+	addresses := make([]string, len(channels))
+	for i, channel := range channels {
+		addresses[i] = channel.Address()
+		assert.Contains(t, backend.sequences, channel.Address())
+	}
+	require.NoError(t, funder.FundChannels(context.Background(), addresses, "1"))
+	require.Len(t, backend.submitted, 4)
+	assert.Len(t, backend.submitted[3].Operations(), 50)
+
+	require.NoError(t, funder.MergeChannels(context.Background(), channels[:20]))
+	require.Len(t, backend.submitted, 6)
+	merge := backend.submitted[4]
+	assert.Len(t, merge.Operations(), 19)
+	assert.Len(t, merge.Signatures(), 20)
+	assert.Equal(t, channels[0].Address(), merge.Operations()[0].GetSourceAccount())
+	assert.Equal(t, int64(1)<<32+6, backend.sequences[funder.Account.Address()])
+
+	// The keys of an unconfirmed transaction are returned, as it may still
+	// create the channels.
+	backend.fail = fmt.Errorf("transaction abc is pending: %w", context.DeadlineExceeded)
+	created, err := funder.CreateChannels(context.Background(), 150, "2")
+	assert.EqualError(t, err, "could not submit transaction: transaction abc is pending: context deadline exceeded")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Len(t, created, 100)
+}
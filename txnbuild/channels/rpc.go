@@ -0,0 +1,130 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	rpc "github.com/stellar/go/clients/rpcclient"
+	protocol "github.com/stellar/go/protocols/rpc"
+	"github.com/stellar/go/protocols/stellarcore"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+)
+
+const (
+	// DefaultPollInterval is the default value of RPCBackend.PollInterval.
+	DefaultPollInterval = time.Second
+	// DefaultPendingTimeout is the default value of
+	// RPCBackend.PendingTimeout.
+	DefaultPendingTimeout = 5 * time.Minute
+)
+
+// ErrNotIncluded is matched by the error RPCBackend.Submit returns when it
+// stops waiting for a transaction which is still not found, because its
+// time bounds expired or PendingTimeout passed. Like any other submission
+// error, it makes the pool reload the channel's sequence number.
+var ErrNotIncluded = errors.New("transaction was not included in a ledger")
+
+// RPCBackend is a Backend which loads accounts from and submits transactions
+// to an RPC server.
+type RPCBackend struct {
+	Client *rpc.Client
+	// PollInterval is the interval at which Submit polls getTransaction
+	// until the transaction is included in a ledger. DefaultPollInterval is
+	// used if it is zero.
+	PollInterval time.Duration
+	// PendingTimeout is how long Submit polls getTransaction for a
+	// transaction which is not found. Submit stops earlier once the latest
+	// ledger closed after the transaction's maximum time bound.
+	// DefaultPendingTimeout is used if it is zero.
+	PendingTimeout time.Duration
+}
+
+// SequenceNumber implements Backend.
+func (b RPCBackend) SequenceNumber(ctx context.Context, address string) (int64, error) {
+	account, err := b.Client.LoadAccount(ctx, address)
+	if err != nil {
+		return 0, err
+	}
+	return account.GetSequenceNumber()
+}
+
+// Submit implements Backend. It returns when the transaction is included in
+// a ledger, when it can no longer be included (see ErrNotIncluded) or when
+// ctx is done.
+func (b RPCBackend) Submit(ctx context.Context, tx *txnbuild.Transaction) (string, error) {
+	encoded, err := tx.Base64()
+	if err != nil {
+		return "", fmt.Errorf("could not encode transaction: %w", err)
+	}
+	response, err := b.Client.SendTransaction(ctx, protocol.SendTransactionRequest{Transaction: encoded})
+	if err != nil {
+		return "", fmt.Errorf("sendTransaction failed: %w", err)
+	}
+
+	switch response.Status {
+	case stellarcore.TXStatusPending, stellarcore.TXStatusDuplicate:
+	case stellarcore.TXStatusError:
+		return "", resultError(response.Hash, response.ErrorResultXDR)
+	default:
+		return "", fmt.Errorf("transaction %s was not accepted: %s", response.Hash, response.Status)
+	}
+
+	interval := b.PollInterval
+	if interval == 0 {
+		interval = DefaultPollInterval
+	}
+	timeout := b.PendingTimeout
+	if timeout == 0 {
+		timeout = DefaultPendingTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	maxTime := tx.Timebounds().MaxTime
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		result, err := b.Client.GetTransaction(ctx, protocol.GetTransactionRequest{Hash: response.Hash})
+		if err != nil {
+			return "", fmt.Errorf("getTransaction failed: %w", err)
+		}
+		switch result.Status {
+		case protocol.TransactionStatusSuccess:
+			return response.Hash, nil
+		case protocol.TransactionStatusFailed:
+			return "", resultError(response.Hash, result.ResultXDR)
+		}
+		if maxTime != 0 && result.LatestLedgerCloseTime > maxTime {
+			return "", fmt.Errorf("transaction %s expired at %d: %w", response.Hash, maxTime, ErrNotIncluded)
+		}
+		if !time.Now().Before(deadline) {
+			return "", fmt.Errorf("transaction %s was not found after %s: %w", response.Hash, timeout, ErrNotIncluded)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return "", fmt.Errorf("transaction %s is pending: %w", response.Hash, ctx.Err())
+		}
+	}
+}
+
+// resultError returns the error describing the failed transaction result,
+// wrapping ErrBadSequence if the transaction or the inner transaction of a
+// fee bump failed with txBAD_SEQ.
+func resultError(hash, resultXDR string) error {
+	var result xdr.TransactionResult
+	if err := xdr.SafeUnmarshalBase64(resultXDR, &result); err != nil {
+		return fmt.Errorf("transaction %s failed with an undecodable result: %w", hash, err)
+	}
+	code := result.Result.Code
+	if inner, ok := result.Result.GetInnerResultPair(); ok {
+		code = inner.Result.Result.Code
+	}
+	err := fmt.Errorf("transaction %s failed: %s", hash, code)
+	if code == xdr.TransactionResultCodeTxBadSeq {
+		return badSequenceError{err}
+	}
+	return err
+}
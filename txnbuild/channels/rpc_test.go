@@ -0,0 +1,128 @@
+package channels
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	rpc "github.com/stellar/go/clients/rpcclient"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	protocol "github.com/stellar/go/protocols/rpc"
+	"github.com/stellar/go/protocols/stellarcore"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newFakeRPC returns a client of a JSON-RPC server which accepts every
+// transaction and never finds it, reporting latestLedgerCloseTime as the
+// close time of the latest ledger.
+func newFakeRPC(t *testing.T, latestLedgerCloseTime int64) *rpc.Client {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+
+		var result interface{}
+		switch request.Method {
+		case protocol.SendTransactionMethodName:
+			result = protocol.SendTransactionResponse{Hash: "abc", Status: stellarcore.TXStatusPending}
+		case protocol.GetTransactionMethodName:
+			result = protocol.GetTransactionResponse{
+				LatestLedgerCloseTime: latestLedgerCloseTime,
+				TransactionDetails:    protocol.TransactionDetails{Status: protocol.TransactionStatusNotFound},
+			}
+		default:
+			t.Errorf("unexpected method %s", request.Method)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      request.ID,
+			"result":  result,
+		}))
+	}))
+	t.Cleanup(server.Close)
+	client := rpc.NewClient(server.URL, nil)
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func newTimeBoundTransaction(t *testing.T, maxTime int64) *txnbuild.Transaction {
+	source := keypair.MustRandom()
+	tx, err := txnbuild.NewTransaction(txnbuild.TransactionParams{
+		SourceAccount:        &txnbuild.SimpleAccount{AccountID: source.Address(), Sequence: 1},
+		IncrementSequenceNum: true,
+		Operations:           []txnbuild.Operation{&txnbuild.BumpSequence{BumpTo: 5}},
+		BaseFee:              txnbuild.MinBaseFee,
+		Preconditions:        txnbuild.Preconditions{TimeBounds: txnbuild.NewTimebounds(0, maxTime)},
+	})
+	require.NoError(t, err)
+	tx, err = tx.Sign(network.TestNetworkPassphrase, source)
+	require.NoError(t, err)
+	return tx
+}
+
+func TestRPCBackendStopsPolling(t *testing.T) {
+	backend := RPCBackend{
+		Client:         newFakeRPC(t, 1000),
+		PollInterval:   time.Millisecond,
+		PendingTimeout: time.Hour,
+	}
+
+	// The transaction can no longer be included once a ledger closed after
+	// its maximum time bound.
+	_, err := backend.Submit(context.Background(), newTimeBoundTransaction(t, 999))
+	assert.ErrorIs(t, err, ErrNotIncluded)
+	assert.EqualError(t, err, "transaction abc expired at 999: transaction was not included in a ledger")
+
+	// Without time bounds, polling stops after PendingTimeout.
+	backend.PendingTimeout = 50 * time.Millisecond
+	_, err = backend.Submit(context.Background(), newTimeBoundTransaction(t, 0))
+	assert.ErrorIs(t, err, ErrNotIncluded)
+	assert.EqualError(t, err, "transaction abc was not found after 50ms: transaction was not included in a ledger")
+}
+
+func encodeResult(t *testing.T, result xdr.TransactionResult) string {
+	encoded, err := xdr.MarshalBase64(result)
+	require.NoError(t, err)
+	return encoded
+}
+
+func TestResultError(t *testing.T) {
+	badSeq := xdr.TransactionResult{
+		FeeCharged: 100,
+		Result:     xdr.TransactionResultResult{Code: xdr.TransactionResultCodeTxBadSeq},
+	}
+	err := resultError("abc", encodeResult(t, badSeq))
+	assert.ErrorIs(t, err, ErrBadSequence)
+	assert.EqualError(t, err, "bad sequence number: transaction abc failed: TransactionResultCodeTxBadSeq")
+
+	feeBump := xdr.TransactionResult{
+		FeeCharged: 200,
+		Result: xdr.TransactionResultResult{
+			Code: xdr.TransactionResultCodeTxFeeBumpInnerFailed,
+			InnerResultPair: &xdr.InnerTransactionResultPair{
+				Result: xdr.InnerTransactionResult{
+					Result: xdr.InnerTransactionResultResult{Code: xdr.TransactionResultCodeTxBadSeq},
+				},
+			},
+		},
+	}
+	assert.ErrorIs(t, resultError("abc", encodeResult(t, feeBump)), ErrBadSequence)
+
+	insufficientFee := xdr.TransactionResult{
+		Result: xdr.TransactionResultResult{Code: xdr.TransactionResultCodeTxInsufficientFee},
+	}
+	err = resultError("abc", encodeResult(t, insufficientFee))
+	assert.NotErrorIs(t, err, ErrBadSequence)
+
+	assert.Error(t, resultError("abc", "not xdr"))
+}
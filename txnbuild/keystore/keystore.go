@@ -0,0 +1,319 @@
+// Package keystore stores Stellar secret keys encrypted on disk. Keys are
+// encrypted with ChaCha20-Poly1305 using a key derived from a passphrase with
+// either scrypt or argon2id, and can be unlocked into a txnbuild.HashSigner.
+package keystore
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/txnbuild"
+)
+
+// KDF is a key derivation function used to derive the encryption key of a
+// stored key from its passphrase.
+type KDF string
+
+const (
+	// Scrypt derives keys with scrypt (N=2^15, r=8, p=1).
+	Scrypt KDF = "scrypt"
+	// Argon2id derives keys with argon2id (3 passes, 64 MiB, 4 threads).
+	Argon2id KDF = "argon2id"
+)
+
+const (
+	version      = 1
+	saltLength   = 32
+	fileMode     = 0600
+	keyExtension = ".json"
+)
+
+// Limits on the cost of the key derivation parameters read from a key file,
+// so that a crafted file cannot make Decrypt use unbounded memory or time.
+const (
+	maxKDFMemory  = 256 * 1024 * 1024
+	maxScryptP    = 16
+	maxArgon2Time = 16
+)
+
+// ErrWrongPassphrase is returned when a key cannot be decrypted with the
+// given passphrase.
+var ErrWrongPassphrase = errors.New("wrong passphrase or corrupted key")
+
+// ErrNotFound is returned when the keystore has no key for an address.
+var ErrNotFound = errors.New("key not found")
+
+// KDFParams are the parameters of the key derivation function of a key.
+type KDFParams struct {
+	Salt []byte `json:"salt"`
+	// Scrypt parameters.
+	N int `json:"n,omitempty"`
+	R int `json:"r,omitempty"`
+	P int `json:"p,omitempty"`
+	// Argon2id parameters, the memory is in KiB.
+	Time    uint32 `json:"time,omitempty"`
+	Memory  uint32 `json:"memory,omitempty"`
+	Threads uint8  `json:"threads,omitempty"`
+}
+
+// Key is an encrypted secret key, as stored on disk.
+type Key struct {
+	Version    int       `json:"version"`
+	Address    string    `json:"address"`
+	KDF        KDF       `json:"kdf"`
+	KDFParams  KDFParams `json:"kdf_params"`
+	Nonce      []byte    `json:"nonce"`
+	Ciphertext []byte    `json:"ciphertext"`
+}
+
+// Encrypt encrypts the secret key of kp with a key derived from passphrase
+// using kdf.
+func Encrypt(kp *keypair.Full, passphrase []byte, kdf KDF) (*Key, error) {
+	key := &Key{
+		Version: version,
+		Address: kp.Address(),
+		KDF:     kdf,
+		KDFParams: KDFParams{
+			Salt: make([]byte, saltLength),
+		},
+		Nonce: make([]byte, chacha20poly1305.NonceSize),
+	}
+	switch kdf {
+	case Scrypt:
+		key.KDFParams.N, key.KDFParams.R, key.KDFParams.P = 1<<15, 8, 1
+	case Argon2id:
+		key.KDFParams.Time, key.KDFParams.Memory, key.KDFParams.Threads = 3, 64*1024, 4
+	default:
+		return nil, errors.Errorf("unknown key derivation function %q", kdf)
+	}
+	if _, err := io.ReadFull(rand.Reader, key.KDFParams.Salt); err != nil {
+		return nil, errors.Wrap(err, "could not generate salt")
+	}
+	if _, err := io.ReadFull(rand.Reader, key.Nonce); err != nil {
+		return nil, errors.Wrap(err, "could not generate nonce")
+	}
+
+	aead, err := key.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := strkey.Decode(strkey.VersionByteSeed, kp.Seed())
+	if err != nil {
+		return nil, err
+	}
+	key.Ciphertext = aead.Seal(nil, key.Nonce, seed, []byte(key.Address))
+	return key, nil
+}
+
+// Decrypt returns the keypair encrypted in k.
+func (k *Key) Decrypt(passphrase []byte) (*keypair.Full, error) {
+	if k.Version != version {
+		return nil, errors.Errorf("unsupported key version %d", k.Version)
+	}
+	if len(k.Nonce) != chacha20poly1305.NonceSize {
+		return nil, errors.New("invalid nonce")
+	}
+	aead, err := k.aead(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := aead.Open(nil, k.Nonce, k.Ciphertext, []byte(k.Address))
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	if len(seed) != 32 {
+		return nil, errors.New("invalid seed length")
+	}
+
+	var rawSeed [32]byte
+	copy(rawSeed[:], seed)
+	kp, err := keypair.FromRawSeed(rawSeed)
+	if err != nil {
+		return nil, err
+	}
+	if kp.Address() != k.Address {
+		return nil, errors.New("decrypted key does not match the address")
+	}
+	return kp, nil
+}
+
+// Signer decrypts k and returns a signer using the decrypted keypair.
+func (k *Key) Signer(passphrase []byte) (txnbuild.HashSigner, error) {
+	kp, err := k.Decrypt(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return txnbuild.KeypairSigner{Full: kp}, nil
+}
+
+func (k *Key) aead(passphrase []byte) (cipher.AEAD, error) {
+	var derived []byte
+	var err error
+	params := k.KDFParams
+	switch k.KDF {
+	case Scrypt:
+		if params.N <= 0 || params.R <= 0 || params.P <= 0 {
+			return nil, errors.New("invalid scrypt parameters")
+		}
+		// scrypt uses 128*N*r bytes of memory.
+		if params.R > maxKDFMemory/128 || params.N > maxKDFMemory/(128*params.R) || params.P > maxScryptP {
+			return nil, errors.Errorf("scrypt parameters N=%d, r=%d, p=%d exceed the limits", params.N, params.R, params.P)
+		}
+		derived, err = scrypt.Key(passphrase, params.Salt, params.N, params.R, params.P, chacha20poly1305.KeySize)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not derive key")
+		}
+	case Argon2id:
+		if params.Time == 0 || params.Memory == 0 || params.Threads == 0 {
+			return nil, errors.New("invalid argon2id parameters")
+		}
+		if params.Memory > maxKDFMemory/1024 || params.Time > maxArgon2Time {
+			return nil, errors.Errorf("argon2id parameters time=%d, memory=%d exceed the limits", params.Time, params.Memory)
+		}
+		derived = argon2.IDKey(passphrase, params.Salt, params.Time, params.Memory, params.Threads, chacha20poly1305.KeySize)
+	default:
+		return nil, errors.Errorf("unknown key derivation function %q", k.KDF)
+	}
+	return chacha20poly1305.New(derived)
+}
+
+// Keystore is a directory of encrypted keys, stored in one file per address.
+type Keystore struct {
+	dir string
+}
+
+// New returns a keystore using the directory dir, which is created if it
+// does not exist when a key is stored.
+func New(dir string) *Keystore {
+	return &Keystore{dir: dir}
+}
+
+func (ks *Keystore) path(address string) (string, error) {
+	if !strkey.IsValidEd25519PublicKey(address) {
+		return "", errors.Errorf("invalid address %s", address)
+	}
+	return filepath.Join(ks.dir, address+keyExtension), nil
+}
+
+// Store encrypts kp with passphrase and writes it to the keystore,
+// replacing any key stored for the same address.
+func (ks *Keystore) Store(kp *keypair.Full, passphrase []byte, kdf KDF) error {
+	key, err := Encrypt(kp, passphrase, kdf)
+	if err != nil {
+		return err
+	}
+	path, err := ks.path(key.Address)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(key, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "could not encode key")
+	}
+
+	if err := os.MkdirAll(ks.dir, 0700); err != nil {
+		return errors.Wrap(err, "could not create keystore directory")
+	}
+	// Write to a temporary file first so an existing key is never left
+	// half written.
+	tmp, err := os.CreateTemp(ks.dir, key.Address+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "could not create key file")
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(fileMode); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "could not set key file permissions")
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return errors.Wrap(err, "could not write key file")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "could not write key file")
+	}
+	return errors.Wrap(os.Rename(tmp.Name(), path), "could not write key file")
+}
+
+// Load reads the encrypted key of address.
+func (ks *Keystore) Load(address string) (*Key, error) {
+	path, err := ks.path(address)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, errors.Wrap(err, "could not read key file")
+	}
+
+	key := &Key{}
+	if err := json.Unmarshal(data, key); err != nil {
+		return nil, errors.Wrap(err, "could not decode key file")
+	}
+	if key.Address != address {
+		return nil, errors.Errorf("key file of %s contains the key of %s", address, key.Address)
+	}
+	return key, nil
+}
+
+// Signer loads and decrypts the key of address and returns a signer using
+// it.
+func (ks *Keystore) Signer(address string, passphrase []byte) (txnbuild.HashSigner, error) {
+	key, err := ks.Load(address)
+	if err != nil {
+		return nil, err
+	}
+	return key.Signer(passphrase)
+}
+
+// Delete removes the key of address from the keystore.
+func (ks *Keystore) Delete(address string) error {
+	path, err := ks.path(address)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return errors.Wrap(err, "could not delete key file")
+}
+
+// Addresses returns the sorted addresses of the keys in the keystore.
+func (ks *Keystore) Addresses() ([]string, error) {
+	entries, err := os.ReadDir(ks.dir)
+	if os.IsNotExist(err) {
+		return []string{}, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "could not read keystore directory")
+	}
+
+	addresses := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, keyExtension) {
+			continue
+		}
+		address := strings.TrimSuffix(name, keyExtension)
+		if strkey.IsValidEd25519PublicKey(address) {
+			addresses = append(addresses, address)
+		}
+	}
+	sort.Strings(addresses)
+	return addresses, nil
+}
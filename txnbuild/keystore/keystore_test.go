@@ -0,0 +1,136 @@
+package keystore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/txnbuild"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	kp := keypair.MustRandom()
+	for _, kdf := range []KDF{Scrypt, Argon2id} {
+		t.Run(string(kdf), func(t *testing.T) {
+			key, err := Encrypt(kp, []byte("correct horse"), kdf)
+			require.NoError(t, err)
+			assert.Equal(t, kp.Address(), key.Address)
+			assert.Equal(t, kdf, key.KDF)
+
+			decrypted, err := key.Decrypt([]byte("correct horse"))
+			require.NoError(t, err)
+			assert.True(t, kp.Equal(decrypted))
+
+			_, err = key.Decrypt([]byte("battery staple"))
+			assert.Equal(t, ErrWrongPassphrase, err)
+
+			// The address is authenticated along with the secret key.
+			tampered := *key
+			tampered.Address = keypair.MustRandom().Address()
+			_, err = tampered.Decrypt([]byte("correct horse"))
+			assert.Equal(t, ErrWrongPassphrase, err)
+		})
+	}
+
+	_, err := Encrypt(kp, []byte("correct horse"), "pbkdf2")
+	assert.EqualError(t, err, `unknown key derivation function "pbkdf2"`)
+}
+
+func TestDecryptKDFLimits(t *testing.T) {
+	kp := keypair.MustRandom()
+	scryptKey, err := Encrypt(kp, []byte("correct horse"), Scrypt)
+	require.NoError(t, err)
+	argon2Key, err := Encrypt(kp, []byte("correct horse"), Argon2id)
+	require.NoError(t, err)
+
+	for _, testCase := range []struct {
+		name   string
+		key    *Key
+		params func(params *KDFParams)
+		error  string
+	}{
+		{"scrypt n", scryptKey, func(params *KDFParams) { params.N = 1 << 30 }, "scrypt parameters N=1073741824, r=8, p=1 exceed the limits"},
+		{"scrypt r", scryptKey, func(params *KDFParams) { params.R = 1 << 30 }, "scrypt parameters N=32768, r=1073741824, p=1 exceed the limits"},
+		{"scrypt p", scryptKey, func(params *KDFParams) { params.P = 1 << 20 }, "scrypt parameters N=32768, r=8, p=1048576 exceed the limits"},
+		{"scrypt zero", scryptKey, func(params *KDFParams) { params.R = 0 }, "invalid scrypt parameters"},
+		{"argon2id memory", argon2Key, func(params *KDFParams) { params.Memory = 1 << 30 }, "argon2id parameters time=3, memory=1073741824 exceed the limits"},
+		{"argon2id time", argon2Key, func(params *KDFParams) { params.Time = 1 << 20 }, "argon2id parameters time=1048576, memory=65536 exceed the limits"},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			key := *testCase.key
+			testCase.params(&key.KDFParams)
+			_, err := key.Decrypt([]byte("correct horse"))
+			assert.EqualError(t, err, testCase.error)
+		})
+	}
+}
+
+func TestKeystore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "keys")
+	ks := New(dir)
+
+	addresses, err := ks.Addresses()
+	require.NoError(t, err)
+	assert.Empty(t, addresses)
+
+	kp0, kp1 := keypair.MustRandom(), keypair.MustRandom()
+	require.NoError(t, ks.Store(kp0, []byte("zero"), Scrypt))
+	require.NoError(t, ks.Store(kp1, []byte("one"), Argon2id))
+	// Unrelated files are ignored.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.json"), []byte("{}"), 0600))
+
+	info, err := os.Stat(filepath.Join(dir, kp0.Address()+".json"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	addresses, err = ks.Addresses()
+	require.NoError(t, err)
+	expected := []string{kp0.Address(), kp1.Address()}
+	if expected[0] > expected[1] {
+		expected[0], expected[1] = expected[1], expected[0]
+	}
+	assert.Equal(t, expected, addresses)
+
+	signer, err := ks.Signer(kp1.Address(), []byte("one"))
+	require.NoError(t, err)
+	assert.Equal(t, kp1.Address(), signer.Address())
+
+	source := txnbuild.NewSimpleAccount(kp1.Address(), 1)
+	tx, err := txnbuild.NewTransaction(txnbuild.TransactionParams{
+		SourceAccount: &source,
+		Operations:    []txnbuild.Operation{&txnbuild.BumpSequence{BumpTo: 10}},
+		BaseFee:       txnbuild.MinBaseFee,
+		Preconditions: txnbuild.Preconditions{TimeBounds: txnbuild.NewInfiniteTimeout()},
+	})
+	require.NoError(t, err)
+	expectedTx, err := tx.Sign(network.TestNetworkPassphrase, kp1)
+	require.NoError(t, err)
+	tx, err = tx.SignWith(context.Background(), network.TestNetworkPassphrase, signer)
+	require.NoError(t, err)
+	assert.Equal(t, expectedTx.Signatures(), tx.Signatures())
+
+	_, err = ks.Signer(kp0.Address(), []byte("one"))
+	assert.Equal(t, ErrWrongPassphrase, err)
+
+	// Storing a key again replaces it.
+	require.NoError(t, ks.Store(kp0, []byte("new"), Argon2id))
+	_, err = ks.Signer(kp0.Address(), []byte("new"))
+	require.NoError(t, err)
+
+	require.NoError(t, ks.Delete(kp0.Address()))
+	_, err = ks.Load(kp0.Address())
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, ErrNotFound, ks.Delete(kp0.Address()))
+	addresses, err = ks.Addresses()
+	require.NoError(t, err)
+	assert.Equal(t, []string{kp1.Address()}, addresses)
+
+	_, err = ks.Load("../secret")
+	assert.EqualError(t, err, "invalid address ../secret")
+}
@@ -0,0 +1,481 @@
+package txnbuild
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"sort"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// maxSignerWeight is the maximum weight a signer contributes to a threshold,
+// larger weights are capped by the network.
+const maxSignerWeight = 255
+
+// mergeSignatures returns signatures followed by the signatures of others
+// which are not already included.
+func mergeSignatures(signatures []xdr.DecoratedSignature, others ...[]xdr.DecoratedSignature) []xdr.DecoratedSignature {
+	merged := make([]xdr.DecoratedSignature, 0, len(signatures))
+	isDuplicate := func(sig xdr.DecoratedSignature) bool {
+		for _, existing := range merged {
+			if existing.Hint == sig.Hint && bytes.Equal(existing.Signature, sig.Signature) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, sigs := range append([][]xdr.DecoratedSignature{signatures}, others...) {
+		for _, sig := range sigs {
+			if !isDuplicate(sig) {
+				merged = append(merged, sig)
+			}
+		}
+	}
+	return merged
+}
+
+// MergeSignatures returns a new Transaction instance which extends the current instance
+// with the signatures of the given copies of the same transaction, which may
+// have been signed by different parties. Signatures present in several copies
+// are only included once. An error is returned if any of the copies is not
+// the same transaction, i.e. does not have the same hash on the network.
+func (t *Transaction) MergeSignatures(network string, others ...*Transaction) (*Transaction, error) {
+	hash, err := t.Hash(network)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to hash transaction")
+	}
+	signatures := make([][]xdr.DecoratedSignature, 0, len(others))
+	for i, other := range others {
+		otherHash, err := other.Hash(network)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to hash transaction %d", i)
+		}
+		if otherHash != hash {
+			return nil, errors.Errorf("transaction %d has a different hash", i)
+		}
+		signatures = append(signatures, other.Signatures())
+	}
+
+	return t.clone(mergeSignatures(t.Signatures(), signatures...)), nil
+}
+
+// MergeSignatures returns a new FeeBumpTransaction instance which extends the current instance
+// with the signatures of the given copies of the same fee bump transaction.
+// Only the signatures of the fee bump transaction are merged, the inner
+// transaction must be signed before it is wrapped. An error is returned if
+// any of the copies is not the same transaction.
+func (t *FeeBumpTransaction) MergeSignatures(network string, others ...*FeeBumpTransaction) (*FeeBumpTransaction, error) {
+	hash, err := t.Hash(network)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to hash transaction")
+	}
+	signatures := make([][]xdr.DecoratedSignature, 0, len(others))
+	for i, other := range others {
+		otherHash, err := other.Hash(network)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to hash transaction %d", i)
+		}
+		if otherHash != hash {
+			return nil, errors.Errorf("transaction %d has a different hash", i)
+		}
+		signatures = append(signatures, other.Signatures())
+	}
+
+	return t.clone(mergeSignatures(t.Signatures(), signatures...)), nil
+}
+
+// MergeEnvelopes parses the given base64 encoded transaction envelopes, which
+// must be copies of the same transaction, and returns the transaction with
+// the signatures of all of them.
+func MergeEnvelopes(network string, envelopes ...string) (*GenericTransaction, error) {
+	if len(envelopes) == 0 {
+		return nil, errors.New("no envelopes provided")
+	}
+	txs := make([]*GenericTransaction, 0, len(envelopes))
+	for i, envelope := range envelopes {
+		tx, err := TransactionFromXDR(envelope)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse envelope %d", i)
+		}
+		txs = append(txs, tx)
+	}
+
+	if first, ok := txs[0].Transaction(); ok {
+		others := make([]*Transaction, 0, len(txs)-1)
+		for i, tx := range txs[1:] {
+			other, ok := tx.Transaction()
+			if !ok {
+				return nil, errors.Errorf("envelope %d is a fee bump transaction", i+1)
+			}
+			others = append(others, other)
+		}
+		merged, err := first.MergeSignatures(network, others...)
+		if err != nil {
+			return nil, err
+		}
+		return merged.ToGenericTransaction(), nil
+	}
+
+	first, _ := txs[0].FeeBump()
+	others := make([]*FeeBumpTransaction, 0, len(txs)-1)
+	for i, tx := range txs[1:] {
+		other, ok := tx.FeeBump()
+		if !ok {
+			return nil, errors.Errorf("envelope %d is not a fee bump transaction", i+1)
+		}
+		others = append(others, other)
+	}
+	merged, err := first.MergeSignatures(network, others...)
+	if err != nil {
+		return nil, err
+	}
+	return merged.ToGenericTransaction(), nil
+}
+
+// ThresholdCategory is the category of threshold (low, medium or high) of an
+// account that an operation requires.
+// See https://developers.stellar.org/docs/learn/encyclopedia/security/signatures-multisig#thresholds
+type ThresholdCategory int
+
+const (
+	// ThresholdLow is required by the transaction source account to pay the
+	// fee and consume a sequence number, and by a few operations.
+	ThresholdLow ThresholdCategory = iota
+	// ThresholdMedium is required by most operations.
+	ThresholdMedium
+	// ThresholdHigh is required by account merges and by set options
+	// operations changing signers or thresholds.
+	ThresholdHigh
+)
+
+// String returns the name of the category.
+func (c ThresholdCategory) String() string {
+	switch c {
+	case ThresholdLow:
+		return "low"
+	case ThresholdMedium:
+		return "medium"
+	case ThresholdHigh:
+		return "high"
+	default:
+		return "unknown"
+	}
+}
+
+// AccountThresholds are the signers and thresholds of an account, as
+// returned by Horizon.
+type AccountThresholds struct {
+	// Signers maps the keys of the signers of the account (G..., T..., X...
+	// or P... strkeys) to their weights. The master key of the account is
+	// included with its address as key, unless its weight is 0.
+	Signers SignerSummary
+	Low     Threshold
+	Medium  Threshold
+	High    Threshold
+}
+
+func (a AccountThresholds) threshold(category ThresholdCategory) Threshold {
+	switch category {
+	case ThresholdLow:
+		return a.Low
+	case ThresholdMedium:
+		return a.Medium
+	default:
+		return a.High
+	}
+}
+
+// ThresholdStatus is the result of checking the signatures of a transaction
+// against the threshold an account requires for an operation.
+type ThresholdStatus struct {
+	// Operation is the index of the operation, or -1 for the transaction
+	// itself, which needs the low threshold of its source account (or of the
+	// fee account for fee bump transactions).
+	Operation     int
+	SourceAccount string
+	Category      ThresholdCategory
+	// Threshold is the weight required.
+	Threshold Threshold
+	// Weight is the sum of the weights of the signers of the account which
+	// signed the transaction.
+	Weight int32
+	// Signers are the signers of the account which signed the transaction,
+	// sorted.
+	Signers []string
+	// MissingWeight is the additional weight required to meet the threshold.
+	MissingWeight int32
+}
+
+// Met returns true if the threshold is met.
+func (s ThresholdStatus) Met() bool {
+	return s.MissingWeight == 0
+}
+
+// ThresholdReport is the result of evaluating the signatures of a
+// transaction against the thresholds of the accounts it involves.
+type ThresholdReport struct {
+	// Transaction is the status of the transaction (or fee) source account.
+	Transaction ThresholdStatus
+	// Operations are the statuses of the operations, in order.
+	Operations []ThresholdStatus
+	// MissingExtraSigners are the extra signers required by the transaction's
+	// preconditions which have not signed it.
+	MissingExtraSigners []string
+	// UnusedSignatures are the indexes of the signatures which do not match
+	// any signer of the accounts involved. The network rejects transactions
+	// with unused signatures.
+	UnusedSignatures []int
+}
+
+// Authorized returns true if all thresholds are met, all extra signers have
+// signed and there are no unused signatures.
+func (r ThresholdReport) Authorized() bool {
+	if !r.Transaction.Met() || len(r.MissingExtraSigners) > 0 || len(r.UnusedSignatures) > 0 {
+		return false
+	}
+	for _, op := range r.Operations {
+		if !op.Met() {
+			return false
+		}
+	}
+	return true
+}
+
+// operationThresholdCategory returns the threshold category an operation
+// requires from its source account.
+func operationThresholdCategory(op xdr.Operation) ThresholdCategory {
+	switch op.Body.Type {
+	case xdr.OperationTypeAllowTrust,
+		xdr.OperationTypeSetTrustLineFlags,
+		xdr.OperationTypeBumpSequence,
+		xdr.OperationTypeClaimClaimableBalance,
+		xdr.OperationTypeInflation,
+		xdr.OperationTypeExtendFootprintTtl,
+		xdr.OperationTypeRestoreFootprint:
+		return ThresholdLow
+	case xdr.OperationTypeAccountMerge:
+		return ThresholdHigh
+	case xdr.OperationTypeSetOptions:
+		setOptions := op.Body.SetOptionsOp
+		if setOptions.MasterWeight != nil || setOptions.LowThreshold != nil ||
+			setOptions.MedThreshold != nil || setOptions.HighThreshold != nil ||
+			setOptions.Signer != nil {
+			return ThresholdHigh
+		}
+		return ThresholdMedium
+	default:
+		return ThresholdMedium
+	}
+}
+
+// signatureVerifier matches the signatures of a transaction to signer keys.
+type signatureVerifier struct {
+	hash       [32]byte
+	signatures []xdr.DecoratedSignature
+	used       []bool
+}
+
+// signed returns true if the transaction is authorized by the signer key,
+// marking the signature which matches it as used.
+func (v *signatureVerifier) signed(signerKey string) (bool, error) {
+	version, err := strkey.Version(signerKey)
+	if err != nil {
+		return false, errors.Wrapf(err, "invalid signer %s", signerKey)
+	}
+
+	var matches func(sig xdr.DecoratedSignature) bool
+	switch version {
+	case strkey.VersionByteAccountID:
+		kp, err := keypair.ParseAddress(signerKey)
+		if err != nil {
+			return false, errors.Wrapf(err, "invalid signer %s", signerKey)
+		}
+		matches = func(sig xdr.DecoratedSignature) bool {
+			return sig.Hint == xdr.SignatureHint(kp.Hint()) && kp.Verify(v.hash[:], sig.Signature) == nil
+		}
+	case strkey.VersionByteHashTx:
+		preAuthTx, err := strkey.Decode(strkey.VersionByteHashTx, signerKey)
+		if err != nil {
+			return false, errors.Wrapf(err, "invalid signer %s", signerKey)
+		}
+		// Pre-authorized transactions do not need a signature.
+		return bytes.Equal(preAuthTx, v.hash[:]), nil
+	case strkey.VersionByteHashX:
+		hashX, err := strkey.Decode(strkey.VersionByteHashX, signerKey)
+		if err != nil {
+			return false, errors.Wrapf(err, "invalid signer %s", signerKey)
+		}
+		var hint xdr.SignatureHint
+		copy(hint[:], hashX[len(hashX)-len(hint):])
+		matches = func(sig xdr.DecoratedSignature) bool {
+			preimageHash := sha256.Sum256(sig.Signature)
+			return sig.Hint == hint && bytes.Equal(preimageHash[:], hashX)
+		}
+	case strkey.VersionByteSignedPayload:
+		signedPayload, err := strkey.DecodeSignedPayload(signerKey)
+		if err != nil {
+			return false, errors.Wrapf(err, "invalid signer %s", signerKey)
+		}
+		kp, err := keypair.ParseAddress(signedPayload.Signer())
+		if err != nil {
+			return false, errors.Wrapf(err, "invalid signer %s", signerKey)
+		}
+		hint := xdr.NewDecoratedSignatureForPayload(nil, kp.Hint(), signedPayload.Payload()).Hint
+		matches = func(sig xdr.DecoratedSignature) bool {
+			return sig.Hint == hint && kp.Verify(signedPayload.Payload(), sig.Signature) == nil
+		}
+	default:
+		return false, errors.Errorf("invalid signer %s", signerKey)
+	}
+
+	for i, sig := range v.signatures {
+		if matches(sig) {
+			v.used[i] = true
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// status evaluates the signatures against the threshold of category of the
+// account.
+func (v *signatureVerifier) status(
+	operation int,
+	account string,
+	category ThresholdCategory,
+	accounts map[string]AccountThresholds,
+) (ThresholdStatus, error) {
+	thresholds, ok := accounts[account]
+	if !ok {
+		return ThresholdStatus{}, errors.Errorf("thresholds of account %s are missing", account)
+	}
+	status := ThresholdStatus{
+		Operation:     operation,
+		SourceAccount: account,
+		Category:      category,
+		Threshold:     thresholds.threshold(category),
+		Signers:       []string{},
+	}
+
+	for signerKey, weight := range thresholds.Signers {
+		if weight <= 0 {
+			continue
+		}
+		signed, err := v.signed(signerKey)
+		if err != nil {
+			return ThresholdStatus{}, err
+		}
+		if signed {
+			status.Weight += min(weight, maxSignerWeight)
+			status.Signers = append(status.Signers, signerKey)
+		}
+	}
+	sort.Strings(status.Signers)
+
+	// The network requires at least one signature, even when the threshold
+	// is 0.
+	required := max(int32(status.Threshold), 1)
+	status.MissingWeight = max(required-status.Weight, 0)
+	return status, nil
+}
+
+func (v *signatureVerifier) unusedSignatures() []int {
+	unused := []int{}
+	for i, used := range v.used {
+		if !used {
+			unused = append(unused, i)
+		}
+	}
+	return unused
+}
+
+func newSignatureVerifier(hash [32]byte, signatures []xdr.DecoratedSignature) *signatureVerifier {
+	return &signatureVerifier{
+		hash:       hash,
+		signatures: signatures,
+		used:       make([]bool, len(signatures)),
+	}
+}
+
+// EvaluateThresholds checks the signatures of the transaction against the
+// signers and thresholds of the accounts involved in it, keyed by address,
+// and reports, for the transaction source account and for every operation,
+// the weight of the signatures and the weight missing to meet the required
+// threshold. Ed25519, pre-authorized transaction, hash-x and signed payload
+// signers are supported.
+//
+// An error is returned if the thresholds of the source account of the
+// transaction or of an operation are not provided.
+func (t *Transaction) EvaluateThresholds(network string, accounts map[string]AccountThresholds) (ThresholdReport, error) {
+	hash, err := t.Hash(network)
+	if err != nil {
+		return ThresholdReport{}, errors.Wrap(err, "failed to hash transaction")
+	}
+	verifier := newSignatureVerifier(hash, t.Signatures())
+
+	sourceAccount := t.envelope.SourceAccount().ToAccountId()
+	report := ThresholdReport{}
+	report.Transaction, err = verifier.status(-1, sourceAccount.Address(), ThresholdLow, accounts)
+	if err != nil {
+		return ThresholdReport{}, err
+	}
+
+	operations := t.envelope.Operations()
+	report.Operations = make([]ThresholdStatus, 0, len(operations))
+	for i, op := range operations {
+		account := sourceAccount
+		if op.SourceAccount != nil {
+			account = op.SourceAccount.ToAccountId()
+		}
+		status, err := verifier.status(i, account.Address(), operationThresholdCategory(op), accounts)
+		if err != nil {
+			return ThresholdReport{}, errors.Wrapf(err, "operation %d", i)
+		}
+		report.Operations = append(report.Operations, status)
+	}
+
+	report.MissingExtraSigners = []string{}
+	for _, extraSigner := range t.envelope.ExtraSigners() {
+		signerKey, err := extraSigner.GetAddress()
+		if err != nil {
+			return ThresholdReport{}, errors.Wrap(err, "invalid extra signer")
+		}
+		signed, err := verifier.signed(signerKey)
+		if err != nil {
+			return ThresholdReport{}, err
+		}
+		if !signed {
+			report.MissingExtraSigners = append(report.MissingExtraSigners, signerKey)
+		}
+	}
+
+	report.UnusedSignatures = verifier.unusedSignatures()
+	return report, nil
+}
+
+// EvaluateThresholds checks the signatures of the fee bump transaction
+// against the low threshold of the fee account, see
+// Transaction.EvaluateThresholds. The inner transaction is evaluated
+// separately, with InnerTransaction().EvaluateThresholds().
+func (t *FeeBumpTransaction) EvaluateThresholds(network string, accounts map[string]AccountThresholds) (ThresholdReport, error) {
+	hash, err := t.Hash(network)
+	if err != nil {
+		return ThresholdReport{}, errors.Wrap(err, "failed to hash transaction")
+	}
+	verifier := newSignatureVerifier(hash, t.Signatures())
+
+	feeAccount := t.envelope.FeeBumpAccount().ToAccountId()
+	report := ThresholdReport{
+		Operations:          []ThresholdStatus{},
+		MissingExtraSigners: []string{},
+	}
+	report.Transaction, err = verifier.status(-1, feeAccount.Address(), ThresholdLow, accounts)
+	if err != nil {
+		return ThresholdReport{}, err
+	}
+	report.UnusedSignatures = verifier.unusedSignatures()
+	return report, nil
+}
@@ -0,0 +1,255 @@
+package txnbuild
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/strkey"
+)
+
+func newMultisigTransaction(t *testing.T, source string, ops ...Operation) *Transaction {
+	sourceAccount := NewSimpleAccount(source, 1)
+	tx, err := NewTransaction(
+		TransactionParams{
+			SourceAccount: &sourceAccount,
+			Operations:    ops,
+			BaseFee:       MinBaseFee,
+			Preconditions: Preconditions{TimeBounds: NewInfiniteTimeout()},
+		},
+	)
+	require.NoError(t, err)
+	return tx
+}
+
+func TestMergeSignatures(t *testing.T) {
+	kp0, kp1, kp2 := newKeypair0(), newKeypair1(), newKeypair2()
+	tx := newMultisigTransaction(t, kp0.Address(), &BumpSequence{BumpTo: 5})
+
+	signed0, err := tx.Sign(network.TestNetworkPassphrase, kp0)
+	require.NoError(t, err)
+	signed01, err := tx.Sign(network.TestNetworkPassphrase, kp0, kp1)
+	require.NoError(t, err)
+	signed2, err := tx.Sign(network.TestNetworkPassphrase, kp2)
+	require.NoError(t, err)
+	expected, err := tx.Sign(network.TestNetworkPassphrase, kp0, kp1, kp2)
+	require.NoError(t, err)
+
+	merged, err := signed0.MergeSignatures(network.TestNetworkPassphrase, signed01, signed2)
+	require.NoError(t, err)
+	assert.Equal(t, expected.Signatures(), merged.Signatures())
+	assert.Len(t, signed0.Signatures(), 1)
+
+	other := newMultisigTransaction(t, kp0.Address(), &BumpSequence{BumpTo: 6})
+	_, err = signed0.MergeSignatures(network.TestNetworkPassphrase, signed2, other)
+	assert.EqualError(t, err, "transaction 1 has a different hash")
+
+	envelope0, err := signed0.Base64()
+	require.NoError(t, err)
+	envelope2, err := signed2.Base64()
+	require.NoError(t, err)
+	generic, err := MergeEnvelopes(network.TestNetworkPassphrase, envelope0, envelope2)
+	require.NoError(t, err)
+	mergedTx, ok := generic.Transaction()
+	require.True(t, ok)
+	assert.Len(t, mergedTx.Signatures(), 2)
+
+	feeBump, err := NewFeeBumpTransaction(FeeBumpTransactionParams{
+		FeeAccount: kp1.Address(),
+		BaseFee:    2 * MinBaseFee,
+		Inner:      expected,
+	})
+	require.NoError(t, err)
+	feeBump1, err := feeBump.Sign(network.TestNetworkPassphrase, kp1)
+	require.NoError(t, err)
+	feeBump2, err := feeBump.Sign(network.TestNetworkPassphrase, kp2)
+	require.NoError(t, err)
+	mergedFeeBump, err := feeBump1.MergeSignatures(network.TestNetworkPassphrase, feeBump2, feeBump1)
+	require.NoError(t, err)
+	assert.Len(t, mergedFeeBump.Signatures(), 2)
+
+	feeBumpEnvelope, err := feeBump1.Base64()
+	require.NoError(t, err)
+	_, err = MergeEnvelopes(network.TestNetworkPassphrase, envelope0, feeBumpEnvelope)
+	assert.EqualError(t, err, "envelope 1 is a fee bump transaction")
+}
+
+func TestEvaluateThresholds(t *testing.T) {
+	kp0, kp1, kp2 := newKeypair0(), newKeypair1(), newKeypair2()
+	tx := newMultisigTransaction(t, kp0.Address(),
+		&Payment{Destination: kp1.Address(), Amount: "10", Asset: NativeAsset{}},
+		&AccountMerge{Destination: kp1.Address()},
+		&BumpSequence{BumpTo: 5, SourceAccount: kp2.Address()},
+	)
+	accounts := map[string]AccountThresholds{
+		kp0.Address(): {
+			Signers: SignerSummary{kp0.Address(): 1, kp1.Address(): 2},
+			Low:     1,
+			Medium:  2,
+			High:    3,
+		},
+		kp2.Address(): {Signers: SignerSummary{kp2.Address(): 1}},
+	}
+
+	signed, err := tx.Sign(network.TestNetworkPassphrase, kp0)
+	require.NoError(t, err)
+	report, err := signed.EvaluateThresholds(network.TestNetworkPassphrase, accounts)
+	require.NoError(t, err)
+	assert.Equal(t, ThresholdStatus{
+		Operation:     -1,
+		SourceAccount: kp0.Address(),
+		Category:      ThresholdLow,
+		Threshold:     1,
+		Weight:        1,
+		Signers:       []string{kp0.Address()},
+	}, report.Transaction)
+	require.Len(t, report.Operations, 3)
+	assert.Equal(t, ThresholdMedium, report.Operations[0].Category)
+	assert.Equal(t, int32(1), report.Operations[0].MissingWeight)
+	assert.Equal(t, ThresholdHigh, report.Operations[1].Category)
+	assert.Equal(t, int32(2), report.Operations[1].MissingWeight)
+	// A threshold of 0 still requires a signature.
+	assert.Equal(t, ThresholdStatus{
+		Operation:     2,
+		SourceAccount: kp2.Address(),
+		Category:      ThresholdLow,
+		Signers:       []string{},
+		MissingWeight: 1,
+	}, report.Operations[2])
+	assert.Empty(t, report.UnusedSignatures)
+	assert.False(t, report.Authorized())
+
+	stranger := keypair.MustRandom()
+	signed, err = signed.Sign(network.TestNetworkPassphrase, kp1, kp2, stranger)
+	require.NoError(t, err)
+	report, err = signed.EvaluateThresholds(network.TestNetworkPassphrase, accounts)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), report.Transaction.Weight)
+	assert.Equal(t, []string{kp1.Address(), kp0.Address()}, report.Operations[1].Signers)
+	for _, op := range report.Operations {
+		assert.True(t, op.Met())
+	}
+	assert.Equal(t, []int{3}, report.UnusedSignatures)
+	assert.False(t, report.Authorized())
+
+	withoutStranger, err := signed.ClearSignatures()
+	require.NoError(t, err)
+	withoutStranger, err = withoutStranger.AddSignatureDecorated(signed.Signatures()[:3]...)
+	require.NoError(t, err)
+	report, err = withoutStranger.EvaluateThresholds(network.TestNetworkPassphrase, accounts)
+	require.NoError(t, err)
+	assert.True(t, report.Authorized())
+
+	delete(accounts, kp2.Address())
+	_, err = withoutStranger.EvaluateThresholds(network.TestNetworkPassphrase, accounts)
+	assert.EqualError(t, err, "operation 2: thresholds of account "+kp2.Address()+" are missing")
+}
+
+func TestOperationThresholdCategoryExtendFootprintTtl(t *testing.T) {
+	op, err := (&ExtendFootprintTtl{ExtendTo: 100}).BuildXDR()
+	require.NoError(t, err)
+	assert.Equal(t, ThresholdLow, operationThresholdCategory(op))
+}
+
+func TestOperationThresholdCategoryRestoreFootprint(t *testing.T) {
+	op, err := (&RestoreFootprint{}).BuildXDR()
+	require.NoError(t, err)
+	assert.Equal(t, ThresholdLow, operationThresholdCategory(op))
+}
+
+func TestEvaluateThresholdsSignerTypes(t *testing.T) {
+	kp0, kp1 := newKeypair0(), newKeypair1()
+	tx := newMultisigTransaction(t, kp0.Address(), &BumpSequence{BumpTo: 5})
+	hash, err := tx.Hash(network.TestNetworkPassphrase)
+	require.NoError(t, err)
+
+	preAuthTx, err := strkey.Encode(strkey.VersionByteHashTx, hash[:])
+	require.NoError(t, err)
+	preimage := []byte("open sesame")
+	preimageHash := sha256.Sum256(preimage)
+	hashX, err := strkey.Encode(strkey.VersionByteHashX, preimageHash[:])
+	require.NoError(t, err)
+	payload := []byte("some payload")
+	signedPayload, err := strkey.NewSignedPayload(kp1.Address(), payload)
+	require.NoError(t, err)
+	signedPayloadSigner, err := signedPayload.Encode()
+	require.NoError(t, err)
+
+	accounts := map[string]AccountThresholds{
+		kp0.Address(): {
+			Signers: SignerSummary{
+				preAuthTx:           1,
+				hashX:               2,
+				signedPayloadSigner: 4,
+			},
+			Low: 7,
+		},
+	}
+
+	// The pre-authorized transaction does not need a signature.
+	report, err := tx.EvaluateThresholds(network.TestNetworkPassphrase, accounts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{preAuthTx}, report.Transaction.Signers)
+	assert.Equal(t, int32(6), report.Transaction.MissingWeight)
+
+	signed, err := tx.SignHashX(preimage)
+	require.NoError(t, err)
+	payloadSignature, err := kp1.SignPayloadDecorated(payload)
+	require.NoError(t, err)
+	signed, err = signed.AddSignatureDecorated(payloadSignature)
+	require.NoError(t, err)
+	report, err = signed.EvaluateThresholds(network.TestNetworkPassphrase, accounts)
+	require.NoError(t, err)
+	assert.Equal(t, int32(7), report.Transaction.Weight)
+	assert.Len(t, report.Transaction.Signers, 3)
+	assert.True(t, report.Authorized())
+
+	// A signature of kp1 over the transaction does not satisfy the signed
+	// payload signer.
+	signed, err = tx.SignHashX(preimage)
+	require.NoError(t, err)
+	signed, err = signed.Sign(network.TestNetworkPassphrase, kp1)
+	require.NoError(t, err)
+	report, err = signed.EvaluateThresholds(network.TestNetworkPassphrase, accounts)
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), report.Transaction.Weight)
+	assert.Equal(t, []int{1}, report.UnusedSignatures)
+}
+
+func TestEvaluateThresholdsExtraSigners(t *testing.T) {
+	kp0, kp1 := newKeypair0(), newKeypair1()
+	sourceAccount := NewSimpleAccount(kp0.Address(), 1)
+	tx, err := NewTransaction(
+		TransactionParams{
+			SourceAccount: &sourceAccount,
+			Operations:    []Operation{&BumpSequence{BumpTo: 5}},
+			BaseFee:       MinBaseFee,
+			Preconditions: Preconditions{
+				TimeBounds:   NewInfiniteTimeout(),
+				ExtraSigners: []string{kp1.Address()},
+			},
+		},
+	)
+	require.NoError(t, err)
+	accounts := map[string]AccountThresholds{
+		kp0.Address(): {Signers: SignerSummary{kp0.Address(): 1}},
+	}
+
+	signed, err := tx.Sign(network.TestNetworkPassphrase, kp0)
+	require.NoError(t, err)
+	report, err := signed.EvaluateThresholds(network.TestNetworkPassphrase, accounts)
+	require.NoError(t, err)
+	assert.Equal(t, []string{kp1.Address()}, report.MissingExtraSigners)
+	assert.False(t, report.Authorized())
+
+	signed, err = signed.Sign(network.TestNetworkPassphrase, kp1)
+	require.NoError(t, err)
+	report, err = signed.EvaluateThresholds(network.TestNetworkPassphrase, accounts)
+	require.NoError(t, err)
+	assert.Empty(t, report.MissingExtraSigners)
+	assert.True(t, report.Authorized())
+}
@@ -0,0 +1,120 @@
+package remotesigner
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// maxResponseSize is the maximum size of a response body read by the client.
+const maxResponseSize = 64 * 1024
+
+// HTTP represents the http client that a Client uses to make http requests.
+type HTTP interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Client is a txnbuild.PayloadSigner signing with a key held by a remote
+// signing service.
+type Client struct {
+	// URL is the signing endpoint of the service.
+	URL string
+	// Key is the G... address of the key used by the service.
+	Key string
+	// HTTP is the http client used to send requests. http.DefaultClient is
+	// used if it is nil.
+	HTTP HTTP
+	// Header contains additional headers sent with every request, e.g. an
+	// Authorization header.
+	Header http.Header
+}
+
+// Address returns the address of the key used by the service.
+func (c *Client) Address() string {
+	return c.Key
+}
+
+// SignHash asks the service to sign hash without providing the payload it
+// is the hash of. Services may refuse to sign hashes blindly.
+func (c *Client) SignHash(ctx context.Context, hash [32]byte) (xdr.DecoratedSignature, error) {
+	return c.sign(ctx, SignRequest{
+		Address: c.Key,
+		Hash:    hex.EncodeToString(hash[:]),
+	})
+}
+
+// SignPayload asks the service to sign the SHA-256 hash of payload.
+func (c *Client) SignPayload(ctx context.Context, payload []byte) (xdr.DecoratedSignature, error) {
+	hash := sha256.Sum256(payload)
+	return c.sign(ctx, SignRequest{
+		Address: c.Key,
+		Hash:    hex.EncodeToString(hash[:]),
+		Payload: base64.StdEncoding.EncodeToString(payload),
+	})
+}
+
+func (c *Client) sign(ctx context.Context, request SignRequest) (xdr.DecoratedSignature, error) {
+	kp, err := keypair.ParseAddress(c.Key)
+	if err != nil {
+		return xdr.DecoratedSignature{}, errors.Wrapf(err, "invalid key %s", c.Key)
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return xdr.DecoratedSignature{}, errors.Wrap(err, "could not encode request")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return xdr.DecoratedSignature{}, errors.Wrap(err, "could not create request")
+	}
+	for name, values := range c.Header {
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := c.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return xdr.DecoratedSignature{}, errors.Wrap(err, "signing request failed")
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return xdr.DecoratedSignature{}, errors.Wrap(err, "could not read response")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp ErrorResponse
+		if json.Unmarshal(respBody, &errResp) == nil && errResp.Error != "" {
+			return xdr.DecoratedSignature{}, errors.Errorf("signing request failed with status %d: %s", resp.StatusCode, errResp.Error)
+		}
+		return xdr.DecoratedSignature{}, errors.Errorf("signing request failed with status %d", resp.StatusCode)
+	}
+
+	var signResp SignResponse
+	if err := json.Unmarshal(respBody, &signResp); err != nil {
+		return xdr.DecoratedSignature{}, errors.Wrap(err, "could not decode response")
+	}
+	signature, err := base64.StdEncoding.DecodeString(signResp.Signature)
+	if err != nil {
+		return xdr.DecoratedSignature{}, errors.Wrap(err, "could not decode signature")
+	}
+	return xdr.DecoratedSignature{
+		Hint:      xdr.SignatureHint(kp.Hint()),
+		Signature: signature,
+	}, nil
+}
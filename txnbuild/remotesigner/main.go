@@ -0,0 +1,34 @@
+// Package remotesigner implements a txnbuild.HashSigner which asks a remote
+// HTTP service to sign, along with a reference implementation of that
+// service, so secret keys can be kept away from the hosts building
+// transactions.
+//
+// The client sends a JSON encoded SignRequest in a POST request to the
+// service, which responds with a JSON encoded SignResponse, or an
+// ErrorResponse with a non 200 status code. Requests include the XDR payload
+// being signed whenever it is known, so the service can apply policies to
+// what it signs.
+package remotesigner
+
+// SignRequest is the body of a signing request.
+type SignRequest struct {
+	// Address is the G... address of the key to sign with.
+	Address string `json:"address"`
+	// Hash is the hex encoded 32 byte hash to sign.
+	Hash string `json:"hash"`
+	// Payload is the base64 encoded XDR payload whose SHA-256 hash is Hash,
+	// either a xdr.TransactionSignaturePayload or a xdr.HashIdPreimage. It is
+	// empty when only the hash is known.
+	Payload string `json:"payload,omitempty"`
+}
+
+// SignResponse is the body of a successful signing response.
+type SignResponse struct {
+	// Signature is the base64 encoded ed25519 signature of the hash.
+	Signature string `json:"signature"`
+}
+
+// ErrorResponse is the body of a failed signing response.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
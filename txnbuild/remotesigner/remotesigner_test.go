@@ -0,0 +1,189 @@
+package remotesigner
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+)
+
+func newTestTransaction(t *testing.T, source string) *txnbuild.Transaction {
+	account := txnbuild.NewSimpleAccount(source, 1)
+	tx, err := txnbuild.NewTransaction(txnbuild.TransactionParams{
+		SourceAccount: &account,
+		Operations:    []txnbuild.Operation{&txnbuild.BumpSequence{BumpTo: 10}},
+		BaseFee:       txnbuild.MinBaseFee,
+		Preconditions: txnbuild.Preconditions{TimeBounds: txnbuild.NewInfiniteTimeout()},
+	})
+	require.NoError(t, err)
+	return tx
+}
+
+func TestRemoteSigner(t *testing.T) {
+	kp := keypair.MustRandom()
+	var requests []Request
+	recordRequests := func(ctx context.Context, req Request) error {
+		requests = append(requests, req)
+		return nil
+	}
+	handler, err := NewHandler(
+		[]txnbuild.HashSigner{txnbuild.KeypairSigner{Full: kp}},
+		recordRequests,
+		AllowNetworks(network.TestNetworkPassphrase),
+	)
+	require.NoError(t, err)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client := &Client{
+		URL:    server.URL,
+		Key:    kp.Address(),
+		Header: http.Header{"Authorization": []string{"Bearer token"}},
+	}
+	tx := newTestTransaction(t, kp.Address())
+	expected, err := tx.Sign(network.TestNetworkPassphrase, kp)
+	require.NoError(t, err)
+	signed, err := tx.SignWith(context.Background(), network.TestNetworkPassphrase, client)
+	require.NoError(t, err)
+	assert.Equal(t, expected.Signatures(), signed.Signatures())
+
+	require.Len(t, requests, 1)
+	require.NotNil(t, requests[0].Transaction)
+	assert.Nil(t, requests[0].Authorization)
+	assert.Equal(t, tx.ToXDR().V1.Tx, *requests[0].Transaction.TaggedTransaction.Tx)
+	hash, err := tx.Hash(network.TestNetworkPassphrase)
+	require.NoError(t, err)
+	assert.Equal(t, hash, requests[0].Hash)
+
+	_, err = tx.SignWith(context.Background(), network.PublicNetworkPassphrase, client)
+	assert.ErrorContains(t, err, "signing request failed with status 403: network is not allowed")
+
+	_, err = client.SignHash(context.Background(), hash)
+	assert.EqualError(t, err, "signing request failed with status 403: payload is required")
+
+	unknown := &Client{URL: server.URL, Key: keypair.MustRandom().Address()}
+	_, err = tx.SignWith(context.Background(), network.TestNetworkPassphrase, unknown)
+	assert.ErrorContains(t, err, "signing request failed with status 404: unknown key "+unknown.Key)
+}
+
+func TestRemoteSignerAuthEntry(t *testing.T) {
+	kp := keypair.MustRandom()
+	var authorizations []xdr.HashIdPreimageSorobanAuthorization
+	handler, err := NewHandler(
+		[]txnbuild.HashSigner{txnbuild.KeypairSigner{Full: kp}},
+		func(ctx context.Context, req Request) error {
+			if req.Authorization == nil {
+				return errors.New("only authorization entries are signed")
+			}
+			authorizations = append(authorizations, *req.Authorization)
+			return nil
+		},
+	)
+	require.NoError(t, err)
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	accountID := xdr.MustAddress(kp.Address())
+	entry := xdr.SorobanAuthorizationEntry{
+		Credentials: xdr.SorobanCredentials{
+			Type: xdr.SorobanCredentialsTypeSorobanCredentialsAddress,
+			Address: &xdr.SorobanAddressCredentials{
+				Address: xdr.ScAddress{
+					Type:      xdr.ScAddressTypeScAddressTypeAccount,
+					AccountId: &accountID,
+				},
+				Nonce: 7,
+			},
+		},
+		RootInvocation: xdr.SorobanAuthorizedInvocation{
+			Function: xdr.SorobanAuthorizedFunction{
+				Type: xdr.SorobanAuthorizedFunctionTypeSorobanAuthorizedFunctionTypeCreateContractHostFn,
+				CreateContractHostFn: &xdr.CreateContractArgs{
+					ContractIdPreimage: xdr.ContractIdPreimage{
+						Type:      xdr.ContractIdPreimageTypeContractIdPreimageFromAsset,
+						FromAsset: &xdr.Asset{Type: xdr.AssetTypeAssetTypeNative},
+					},
+					Executable: xdr.ContractExecutable{Type: xdr.ContractExecutableTypeContractExecutableStellarAsset},
+				},
+			},
+		},
+	}
+
+	client := &Client{URL: server.URL, Key: kp.Address()}
+	signed, err := txnbuild.SignAuthEntry(context.Background(), network.TestNetworkPassphrase, entry, 100, client)
+	require.NoError(t, err)
+	assert.Equal(t, xdr.Uint32(100), signed.Credentials.Address.SignatureExpirationLedger)
+	require.Len(t, authorizations, 1)
+	assert.Equal(t, xdr.Int64(7), authorizations[0].Nonce)
+	assert.Equal(t, entry.RootInvocation, authorizations[0].Invocation)
+
+	tx := newTestTransaction(t, kp.Address())
+	_, err = tx.SignWith(context.Background(), network.TestNetworkPassphrase, client)
+	assert.ErrorContains(t, err, "status 403: only authorization entries are signed")
+}
+
+func TestHandlerInvalidRequests(t *testing.T) {
+	kp := keypair.MustRandom()
+	handler, err := NewHandler([]txnbuild.HashSigner{txnbuild.KeypairSigner{Full: kp}}, AllowAll)
+	require.NoError(t, err)
+
+	for _, testCase := range []struct {
+		name   string
+		method string
+		body   string
+		status int
+		error  string
+	}{
+		{"get", http.MethodGet, "", http.StatusMethodNotAllowed, "method not allowed"},
+		{"not json", http.MethodPost, "nope", http.StatusBadRequest, "could not decode request"},
+		{"bad hash", http.MethodPost, `{"address":"` + kp.Address() + `","hash":"abcd"}`, http.StatusBadRequest, "invalid hash"},
+		{
+			"mismatched payload", http.MethodPost,
+			`{"address":"` + kp.Address() + `","hash":"` + strings.Repeat("00", 32) + `","payload":"AAAA"}`,
+			http.StatusBadRequest, "hash does not match the payload",
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			req := httptest.NewRequest(testCase.method, "/sign", strings.NewReader(testCase.body))
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			assert.Equal(t, testCase.status, w.Code)
+			assert.Contains(t, w.Body.String(), testCase.error)
+		})
+	}
+
+	// Hashes are signed blindly when explicitly allowed.
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/sign", strings.NewReader(
+		`{"address":"`+kp.Address()+`","hash":"`+strings.Repeat("00", 32)+`"}`,
+	)))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestHandlerRequiresPolicy(t *testing.T) {
+	kp := keypair.MustRandom()
+	signers := []txnbuild.HashSigner{txnbuild.KeypairSigner{Full: kp}}
+	_, err := NewHandler(signers)
+	assert.Equal(t, errNoPolicy, err)
+	_, err = NewHandler(signers, nil)
+	assert.EqualError(t, err, "signing policy is nil")
+
+	// A handler which was not created with NewHandler signs nothing.
+	handler := &Handler{signers: map[string]txnbuild.HashSigner{kp.Address(): signers[0]}}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/sign", strings.NewReader(
+		`{"address":"`+kp.Address()+`","hash":"`+strings.Repeat("00", 32)+`"}`,
+	)))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), errNoPolicy.Error())
+}
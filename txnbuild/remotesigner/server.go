@@ -0,0 +1,229 @@
+package remotesigner
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+)
+
+// maxRequestSize is the maximum size of a request body accepted by Handler.
+const maxRequestSize = 1024 * 1024
+
+// Request is a decoded signing request, as given to policies.
+type Request struct {
+	// Address is the address of the key to sign with.
+	Address string
+	// Hash is the hash to sign.
+	Hash [32]byte
+	// Payload is the payload whose hash is Hash, or nil if the client only
+	// sent the hash.
+	Payload []byte
+	// Transaction is the decoded payload when it is a transaction signature
+	// payload.
+	Transaction *xdr.TransactionSignaturePayload
+	// Authorization is the decoded payload when it is the preimage of a
+	// Soroban authorization entry.
+	Authorization *xdr.HashIdPreimageSorobanAuthorization
+}
+
+// NetworkID returns the id of the network of the payload, or false if the
+// payload is not known.
+func (r Request) NetworkID() ([32]byte, bool) {
+	switch {
+	case r.Transaction != nil:
+		return r.Transaction.NetworkId, true
+	case r.Authorization != nil:
+		return r.Authorization.NetworkId, true
+	default:
+		return [32]byte{}, false
+	}
+}
+
+// Policy decides whether a request may be signed. A request is rejected
+// with the error returned, if any.
+type Policy func(ctx context.Context, req Request) error
+
+// AllowAll is a Policy accepting every request, including requests for bare
+// hashes whose payload is unknown. It must be given to NewHandler explicitly
+// to sign without any restriction.
+func AllowAll(ctx context.Context, req Request) error {
+	return nil
+}
+
+// RequirePayload is a Policy rejecting requests which do not include the
+// payload they sign.
+func RequirePayload(ctx context.Context, req Request) error {
+	if req.Payload == nil {
+		return errors.New("payload is required")
+	}
+	return nil
+}
+
+// AllowNetworks returns a Policy rejecting requests whose payload is not
+// for one of the networks with the given passphrases. Requests without a
+// payload are rejected.
+func AllowNetworks(passphrases ...string) Policy {
+	allowed := map[[32]byte]bool{}
+	for _, passphrase := range passphrases {
+		allowed[network.ID(passphrase)] = true
+	}
+	return func(ctx context.Context, req Request) error {
+		id, ok := req.NetworkID()
+		if !ok {
+			return errors.New("payload is required")
+		}
+		if !allowed[id] {
+			return errors.New("network is not allowed")
+		}
+		return nil
+	}
+}
+
+// Handler is an http.Handler serving signing requests with a set of
+// signers. Every request must be accepted by all its policies to be signed.
+type Handler struct {
+	signers  map[string]txnbuild.HashSigner
+	policies []Policy
+}
+
+// errNoPolicy is returned when a Handler is created or used without any
+// policy. Use AllowAll to sign every request.
+var errNoPolicy = errors.New("at least one signing policy is required")
+
+// NewHandler returns a Handler signing with the given signers after
+// checking requests against policies. At least one policy is required, so
+// that a handler never signs arbitrary hashes by accident.
+func NewHandler(signers []txnbuild.HashSigner, policies ...Policy) (*Handler, error) {
+	if len(policies) == 0 {
+		return nil, errNoPolicy
+	}
+	for _, policy := range policies {
+		if policy == nil {
+			return nil, errors.New("signing policy is nil")
+		}
+	}
+	h := &Handler{
+		signers:  map[string]txnbuild.HashSigner{},
+		policies: policies,
+	}
+	for _, signer := range signers {
+		h.signers[signer.Address()] = signer
+	}
+	return h, nil
+}
+
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e httpError) Error() string {
+	return e.err.Error()
+}
+
+func badRequest(err error) error {
+	return httpError{status: http.StatusBadRequest, err: err}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: "method not allowed"})
+		return
+	}
+
+	resp, err := h.sign(r)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if httpErr, ok := err.(httpError); ok {
+			status = httpErr.status
+		}
+		writeJSON(w, status, ErrorResponse{Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) sign(r *http.Request) (SignResponse, error) {
+	ctx := r.Context()
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestSize+1))
+	if err != nil {
+		return SignResponse{}, badRequest(errors.Wrap(err, "could not read request"))
+	}
+	if len(body) > maxRequestSize {
+		return SignResponse{}, httpError{status: http.StatusRequestEntityTooLarge, err: errors.New("request too large")}
+	}
+	var signReq SignRequest
+	if err := json.Unmarshal(body, &signReq); err != nil {
+		return SignResponse{}, badRequest(errors.Wrap(err, "could not decode request"))
+	}
+	req, err := decodeRequest(signReq)
+	if err != nil {
+		return SignResponse{}, badRequest(err)
+	}
+
+	if len(h.policies) == 0 {
+		return SignResponse{}, errNoPolicy
+	}
+	signer, ok := h.signers[req.Address]
+	if !ok {
+		return SignResponse{}, httpError{status: http.StatusNotFound, err: errors.Errorf("unknown key %s", req.Address)}
+	}
+	for _, policy := range h.policies {
+		if err := policy(ctx, req); err != nil {
+			return SignResponse{}, httpError{status: http.StatusForbidden, err: err}
+		}
+	}
+
+	sig, err := signer.SignHash(ctx, req.Hash)
+	if err != nil {
+		return SignResponse{}, errors.New("signing failed")
+	}
+	return SignResponse{Signature: base64.StdEncoding.EncodeToString(sig.Signature)}, nil
+}
+
+func decodeRequest(signReq SignRequest) (Request, error) {
+	req := Request{Address: signReq.Address}
+	hash, err := hex.DecodeString(signReq.Hash)
+	if err != nil || len(hash) != len(req.Hash) {
+		return Request{}, errors.New("invalid hash")
+	}
+	copy(req.Hash[:], hash)
+	if signReq.Payload == "" {
+		return req, nil
+	}
+
+	req.Payload, err = base64.StdEncoding.DecodeString(signReq.Payload)
+	if err != nil {
+		return Request{}, errors.New("invalid payload")
+	}
+	if sha256.Sum256(req.Payload) != req.Hash {
+		return Request{}, errors.New("hash does not match the payload")
+	}
+
+	var preimage xdr.HashIdPreimage
+	if err := xdr.SafeUnmarshal(req.Payload, &preimage); err == nil && preimage.Type == xdr.EnvelopeTypeEnvelopeTypeSorobanAuthorization {
+		req.Authorization = preimage.SorobanAuthorization
+		return req, nil
+	}
+	var payload xdr.TransactionSignaturePayload
+	if err := xdr.SafeUnmarshal(req.Payload, &payload); err != nil {
+		return Request{}, errors.New("payload is neither a transaction signature payload nor an authorization preimage")
+	}
+	req.Transaction = &payload
+	return req, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
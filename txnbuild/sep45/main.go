@@ -0,0 +1,281 @@
+// Package sep45 implements SEP-45 web authentication for contract (C...)
+// accounts. A challenge is a list of Soroban authorization entries for a call
+// to the web_auth_verify function of the server's web auth contract: one
+// entry signed by the server, one the client account must sign, and
+// optionally one for the client domain. The server verifies the signed
+// entries by simulating the call, which runs the __check_auth function of the
+// client's account contract.
+// https://github.com/stellar/stellar-protocol/blob/master/ecosystem/sep-0045.md
+package sep45
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+)
+
+// FunctionName is the function of the web auth contract invoked by the
+// challenge.
+const FunctionName = "web_auth_verify"
+
+// Keys of the map passed to web_auth_verify.
+const (
+	ArgAccount              = "account"
+	ArgHomeDomain           = "home_domain"
+	ArgWebAuthDomain        = "web_auth_domain"
+	ArgWebAuthDomainAccount = "web_auth_domain_account"
+	ArgNonce                = "nonce"
+	ArgClientDomain         = "client_domain"
+	ArgClientDomainAccount  = "client_domain_account"
+)
+
+// Challenge is a SEP-45 challenge.
+type Challenge struct {
+	// WebAuthContract is the C... address of the web auth contract.
+	WebAuthContract string
+
+	// Account is the C... address of the client account.
+	Account              string
+	HomeDomain           string
+	WebAuthDomain        string
+	WebAuthDomainAccount string
+	Nonce                string
+	ClientDomain         string
+	ClientDomainAccount  string
+
+	// Entries are the authorization entries of the challenge.
+	Entries []xdr.SorobanAuthorizationEntry
+}
+
+// BuildParams are the parameters of BuildChallenge.
+type BuildParams struct {
+	NetworkPassphrase string
+	// WebAuthContract is the C... address of the web auth contract.
+	WebAuthContract string
+	// Server signs the server's entry. Its address is the SIGNING_KEY of the
+	// home domain and is used as web_auth_domain_account.
+	Server txnbuild.HashSigner
+	// Account is the C... address of the client account.
+	Account       string
+	HomeDomain    string
+	WebAuthDomain string
+	// ClientDomain and ClientDomainAccount are set when the client asks to
+	// prove the client domain; ClientDomainAccount is the SIGNING_KEY of
+	// the client domain.
+	ClientDomain        string
+	ClientDomainAccount string
+	// ValidUntilLedger is the last ledger in which the server's signature,
+	// and so the challenge, is valid. It is required.
+	ValidUntilLedger uint32
+}
+
+// BuildChallenge returns a challenge for the account with the server's entry
+// signed.
+func BuildChallenge(ctx context.Context, params BuildParams) (*Challenge, error) {
+	if params.Server == nil {
+		return nil, errors.New("server signer is required")
+	}
+	if !strkey.IsValidContractAddress(params.Account) {
+		return nil, errors.Errorf("account %s is not a contract address", params.Account)
+	}
+	if params.HomeDomain == "" || params.WebAuthDomain == "" {
+		return nil, errors.New("home domain and web auth domain are required")
+	}
+	if (params.ClientDomain == "") != (params.ClientDomainAccount == "") {
+		return nil, errors.New("client domain and client domain account must be set together")
+	}
+	if params.ClientDomainAccount != "" && !strkey.IsValidEd25519PublicKey(params.ClientDomainAccount) {
+		return nil, errors.Errorf("client domain account %s is not a valid account ID", params.ClientDomainAccount)
+	}
+	if params.ValidUntilLedger == 0 {
+		return nil, errors.New("valid until ledger is required")
+	}
+
+	nonce := make([]byte, 48)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "generate nonce failed")
+	}
+	challenge := &Challenge{
+		WebAuthContract:      params.WebAuthContract,
+		Account:              params.Account,
+		HomeDomain:           params.HomeDomain,
+		WebAuthDomain:        params.WebAuthDomain,
+		WebAuthDomainAccount: params.Server.Address(),
+		Nonce:                base64.StdEncoding.EncodeToString(nonce),
+		ClientDomain:         params.ClientDomain,
+		ClientDomainAccount:  params.ClientDomainAccount,
+	}
+	invocation, err := challenge.invocation()
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := []string{challenge.WebAuthDomainAccount, challenge.Account}
+	if challenge.ClientDomainAccount != "" {
+		addresses = append(addresses, challenge.ClientDomainAccount)
+	}
+	for _, address := range addresses {
+		entry, err := newEntry(address, invocation)
+		if err != nil {
+			return nil, err
+		}
+		challenge.Entries = append(challenge.Entries, entry)
+	}
+
+	challenge.Entries[0], err = txnbuild.SignAuthEntry(
+		ctx, params.NetworkPassphrase, challenge.Entries[0], params.ValidUntilLedger, params.Server,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "sign server entry failed")
+	}
+	return challenge, nil
+}
+
+// Encode returns the base64 XDR encoding of the challenge's entries, as sent
+// in the authorization_entries field.
+func (c *Challenge) Encode() (string, error) {
+	return xdr.MarshalBase64(xdr.SorobanAuthorizationEntries(c.Entries))
+}
+
+// Entry returns the index of the entry of the address.
+func (c *Challenge) Entry(address string) (int, bool) {
+	for i, entry := range c.Entries {
+		if entryAddress(entry) == address {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// args returns the map passed to web_auth_verify.
+func (c *Challenge) args() map[string]string {
+	args := map[string]string{
+		ArgAccount:              c.Account,
+		ArgHomeDomain:           c.HomeDomain,
+		ArgWebAuthDomain:        c.WebAuthDomain,
+		ArgWebAuthDomainAccount: c.WebAuthDomainAccount,
+		ArgNonce:                c.Nonce,
+	}
+	if c.ClientDomain != "" {
+		args[ArgClientDomain] = c.ClientDomain
+		args[ArgClientDomainAccount] = c.ClientDomainAccount
+	}
+	return args
+}
+
+// invocation returns the web_auth_verify invocation authorized by the
+// entries.
+func (c *Challenge) invocation() (xdr.SorobanAuthorizedInvocation, error) {
+	contract, err := contractAddress(c.WebAuthContract)
+	if err != nil {
+		return xdr.SorobanAuthorizedInvocation{}, err
+	}
+	return xdr.SorobanAuthorizedInvocation{
+		Function: xdr.SorobanAuthorizedFunction{
+			Type: xdr.SorobanAuthorizedFunctionTypeSorobanAuthorizedFunctionTypeContractFn,
+			ContractFn: &xdr.InvokeContractArgs{
+				ContractAddress: contract,
+				FunctionName:    FunctionName,
+				Args:            []xdr.ScVal{encodeArgs(c.args())},
+			},
+		},
+		SubInvocations: []xdr.SorobanAuthorizedInvocation{},
+	}, nil
+}
+
+func newEntry(address string, invocation xdr.SorobanAuthorizedInvocation) (xdr.SorobanAuthorizationEntry, error) {
+	var scAddress xdr.ScAddress
+	var err error
+	if strkey.IsValidContractAddress(address) {
+		scAddress, err = contractAddress(address)
+	} else {
+		var accountID xdr.AccountId
+		accountID, err = xdr.AddressToAccountId(address)
+		scAddress = xdr.ScAddress{Type: xdr.ScAddressTypeScAddressTypeAccount, AccountId: &accountID}
+	}
+	if err != nil {
+		return xdr.SorobanAuthorizationEntry{}, errors.Wrapf(err, "invalid address %s", address)
+	}
+
+	var nonce [8]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return xdr.SorobanAuthorizationEntry{}, errors.Wrap(err, "generate nonce failed")
+	}
+	return xdr.SorobanAuthorizationEntry{
+		Credentials: xdr.SorobanCredentials{
+			Type: xdr.SorobanCredentialsTypeSorobanCredentialsAddress,
+			Address: &xdr.SorobanAddressCredentials{
+				Address:   scAddress,
+				Nonce:     xdr.Int64(int64(binary.BigEndian.Uint64(nonce[:]) >> 1)),
+				Signature: xdr.ScVal{Type: xdr.ScValTypeScvVoid},
+			},
+		},
+		RootInvocation: invocation,
+	}, nil
+}
+
+func contractAddress(address string) (xdr.ScAddress, error) {
+	decoded, err := strkey.Decode(strkey.VersionByteContract, address)
+	if err != nil {
+		return xdr.ScAddress{}, errors.Wrapf(err, "invalid contract address %s", address)
+	}
+	var contractID xdr.ContractId
+	copy(contractID[:], decoded)
+	return xdr.ScAddress{Type: xdr.ScAddressTypeScAddressTypeContract, ContractId: &contractID}, nil
+}
+
+// entryAddress returns the strkey of the address of an entry's credentials,
+// or an empty string if it has none.
+func entryAddress(entry xdr.SorobanAuthorizationEntry) string {
+	if entry.Credentials.Address == nil {
+		return ""
+	}
+	address, err := entry.Credentials.Address.Address.String()
+	if err != nil {
+		return ""
+	}
+	return address
+}
+
+func encodeArgs(args map[string]string) xdr.ScVal {
+	entries := make(xdr.ScMap, 0, len(args))
+	for _, key := range sortedKeys(args) {
+		symbol := xdr.ScSymbol(key)
+		value := xdr.ScString(args[key])
+		entries = append(entries, xdr.ScMapEntry{
+			Key: xdr.ScVal{Type: xdr.ScValTypeScvSymbol, Sym: &symbol},
+			Val: xdr.ScVal{Type: xdr.ScValTypeScvString, Str: &value},
+		})
+	}
+	entriesPtr := &entries
+	return xdr.ScVal{Type: xdr.ScValTypeScvMap, Map: &entriesPtr}
+}
+
+func decodeArgs(val xdr.ScVal) (map[string]string, error) {
+	scMap, ok := val.GetMap()
+	if !ok || scMap == nil {
+		return nil, errors.New("web_auth_verify argument is not a map")
+	}
+	args := map[string]string{}
+	for _, entry := range *scMap {
+		key, ok := entry.Key.GetSym()
+		if !ok {
+			return nil, errors.New("web_auth_verify argument keys must be symbols")
+		}
+		value, ok := entry.Val.GetStr()
+		if !ok {
+			return nil, errors.Errorf("web_auth_verify argument %s is not a string", key)
+		}
+		if _, ok := args[string(key)]; ok {
+			return nil, errors.Errorf("web_auth_verify argument %s is repeated", key)
+		}
+		args[string(key)] = string(value)
+	}
+	return args, nil
+}
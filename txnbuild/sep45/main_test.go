@@ -0,0 +1,248 @@
+package sep45
+
+import (
+	"context"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	protocol "github.com/stellar/go/protocols/rpc"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func randomContract(t *testing.T) string {
+	var id [32]byte
+	_, err := rand.Read(id[:])
+	require.NoError(t, err)
+	address, err := strkey.Encode(strkey.VersionByteContract, id[:])
+	require.NoError(t, err)
+	return address
+}
+
+type fixture struct {
+	server   *keypair.Full
+	contract string
+	account  string
+	params   BuildParams
+	read     ReadParams
+}
+
+func newFixture(t *testing.T) fixture {
+	f := fixture{
+		server:   keypair.MustRandom(),
+		contract: randomContract(t),
+		account:  randomContract(t),
+	}
+	f.params = BuildParams{
+		NetworkPassphrase: network.TestNetworkPassphrase,
+		WebAuthContract:   f.contract,
+		Server:            txnbuild.KeypairSigner{Full: f.server},
+		Account:           f.account,
+		HomeDomain:        "example.com",
+		WebAuthDomain:     "auth.example.com",
+		ValidUntilLedger:  1000,
+	}
+	f.read = ReadParams{
+		NetworkPassphrase: network.TestNetworkPassphrase,
+		WebAuthContract:   f.contract,
+		ServerAccount:     f.server.Address(),
+		WebAuthDomain:     "auth.example.com",
+		HomeDomains:       []string{"example.com"},
+	}
+	return f
+}
+
+func (f fixture) build(t *testing.T) string {
+	challenge, err := BuildChallenge(context.Background(), f.params)
+	require.NoError(t, err)
+	encoded, err := challenge.Encode()
+	require.NoError(t, err)
+	return encoded
+}
+
+func TestBuildAndReadChallenge(t *testing.T) {
+	f := newFixture(t)
+	clientDomain := keypair.MustRandom()
+	f.params.ClientDomain = "wallet.com"
+	f.params.ClientDomainAccount = clientDomain.Address()
+
+	challenge, err := ReadChallenge(f.build(t), f.read)
+	require.NoError(t, err)
+	assert.Equal(t, f.contract, challenge.WebAuthContract)
+	assert.Equal(t, f.account, challenge.Account)
+	assert.Equal(t, "example.com", challenge.HomeDomain)
+	assert.Equal(t, "auth.example.com", challenge.WebAuthDomain)
+	assert.Equal(t, f.server.Address(), challenge.WebAuthDomainAccount)
+	assert.Equal(t, "wallet.com", challenge.ClientDomain)
+	assert.Equal(t, clientDomain.Address(), challenge.ClientDomainAccount)
+	assert.Len(t, challenge.Nonce, 64)
+	require.Len(t, challenge.Entries, 3)
+
+	i, ok := challenge.Entry(f.account)
+	require.True(t, ok)
+	assert.Equal(t, xdr.ScValTypeScvVoid, challenge.Entries[i].Credentials.Address.Signature.Type)
+	assert.Equal(t, xdr.Uint32(1000), challenge.Entries[0].Credentials.Address.SignatureExpirationLedger)
+
+	f.params.ValidUntilLedger = 0
+	_, err = BuildChallenge(context.Background(), f.params)
+	assert.EqualError(t, err, "valid until ledger is required")
+}
+
+func TestReadChallengeRejects(t *testing.T) {
+	f := newFixture(t)
+	encoded := f.build(t)
+
+	for _, tc := range []struct {
+		name   string
+		modify func(read *ReadParams)
+		err    string
+	}{
+		{
+			name:   "other server",
+			modify: func(read *ReadParams) { read.ServerAccount = keypair.MustRandom().Address() },
+			err:    "web_auth_domain_account is",
+		},
+		{
+			name:   "other contract",
+			modify: func(read *ReadParams) { read.WebAuthContract = randomContract(t) },
+			err:    "challenge calls contract",
+		},
+		{
+			name:   "other web auth domain",
+			modify: func(read *ReadParams) { read.WebAuthDomain = "evil.com" },
+			err:    `web_auth_domain is "auth.example.com" instead of "evil.com"`,
+		},
+		{
+			name:   "other home domain",
+			modify: func(read *ReadParams) { read.HomeDomains = []string{"evil.com"} },
+			err:    `home_domain "example.com" does not match any home domains [evil.com]`,
+		},
+		{
+			name:   "other network",
+			modify: func(read *ReadParams) { read.NetworkPassphrase = network.PublicNetworkPassphrase },
+			err:    "invalid server signature",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			read := f.read
+			tc.modify(&read)
+			_, err := ReadChallenge(encoded, read)
+			assert.ErrorContains(t, err, tc.err)
+		})
+	}
+
+	// The server entry must carry a valid signature of the server.
+	challenge, err := BuildChallenge(context.Background(), f.params)
+	require.NoError(t, err)
+	vec, _ := challenge.Entries[0].Credentials.Address.Signature.GetVec()
+	sigMap, _ := (*vec)[0].GetMap()
+	signature, _ := (*sigMap)[1].Val.GetBytes()
+	signature[0] ^= 0xff
+	_, err = ReadChallenge(mustEncode(t, challenge), f.read)
+	assert.EqualError(t, err, "invalid server signature: entry is not signed by "+f.server.Address())
+
+	// Entries must authorize the same invocation.
+	challenge, err = BuildChallenge(context.Background(), newFixture(t).params)
+	require.NoError(t, err)
+	other, err := BuildChallenge(context.Background(), newFixture(t).params)
+	require.NoError(t, err)
+	challenge.Entries[1].RootInvocation = other.Entries[1].RootInvocation
+	_, err = ReadChallenge(mustEncode(t, challenge), f.read)
+	assert.EqualError(t, err, "authorization entries authorize different invocations")
+
+	// Every expected entry must be present, once.
+	challenge, err = BuildChallenge(context.Background(), f.params)
+	require.NoError(t, err)
+	challenge.Entries = challenge.Entries[:1]
+	_, err = ReadChallenge(mustEncode(t, challenge), f.read)
+	assert.EqualError(t, err, "challenge is missing authorization entries")
+	challenge.Entries = append(challenge.Entries, challenge.Entries[0])
+	_, err = ReadChallenge(mustEncode(t, challenge), f.read)
+	assert.ErrorContains(t, err, "repeated authorization entry for")
+
+	_, err = ReadChallenge("AAAAAA==", f.read)
+	assert.EqualError(t, err, "challenge has no authorization entries")
+}
+
+func mustEncode(t *testing.T, challenge *Challenge) string {
+	encoded, err := challenge.Encode()
+	require.NoError(t, err)
+	return encoded
+}
+
+type fakeSimulator struct {
+	request  protocol.SimulateTransactionRequest
+	response protocol.SimulateTransactionResponse
+}
+
+func (s *fakeSimulator) SimulateTransaction(_ context.Context, request protocol.SimulateTransactionRequest) (protocol.SimulateTransactionResponse, error) {
+	s.request = request
+	return s.response, nil
+}
+
+func TestSignAndVerifyChallenge(t *testing.T) {
+	f := newFixture(t)
+	encoded := f.build(t)
+	simulator := &fakeSimulator{}
+
+	_, err := VerifyChallenge(context.Background(), simulator, encoded, f.read, f.server.Address())
+	assert.EqualError(t, err, "authorization entry for "+f.account+" is not signed")
+
+	challenge, err := ReadChallenge(encoded, f.read)
+	require.NoError(t, err)
+	signer := keypair.MustRandom()
+	err = SignChallenge(context.Background(), challenge, network.TestNetworkPassphrase, f.account, 1200,
+		txnbuild.KeypairSigner{Full: signer})
+	require.NoError(t, err)
+	i, _ := challenge.Entry(f.account)
+	assert.Equal(t, xdr.Uint32(1200), challenge.Entries[i].Credentials.Address.SignatureExpirationLedger)
+
+	err = SignChallenge(context.Background(), challenge, network.TestNetworkPassphrase, randomContract(t), 1200,
+		txnbuild.KeypairSigner{Full: signer})
+	assert.ErrorContains(t, err, "challenge has no authorization entry for")
+
+	err = SignChallenge(context.Background(), challenge, network.TestNetworkPassphrase, f.account, 0,
+		txnbuild.KeypairSigner{Full: signer})
+	assert.EqualError(t, err, "valid until ledger is required")
+
+	signed := mustEncode(t, challenge)
+	verified, err := VerifyChallenge(context.Background(), simulator, signed, f.read, f.server.Address())
+	require.NoError(t, err)
+	assert.Equal(t, challenge.Nonce, verified.Nonce)
+
+	assert.Equal(t, protocol.AuthModeEnforce, simulator.request.AuthMode)
+	parsed, err := txnbuild.TransactionFromXDR(simulator.request.Transaction)
+	require.NoError(t, err)
+	tx, ok := parsed.Transaction()
+	require.True(t, ok)
+	require.Len(t, tx.Operations(), 1)
+	invoke, ok := tx.Operations()[0].(*txnbuild.InvokeHostFunction)
+	require.True(t, ok)
+	assert.Equal(t, FunctionName, string(invoke.HostFunction.InvokeContract.FunctionName))
+	assert.Len(t, invoke.Auth, 2)
+
+	simulator.response.Error = "HostError: Error(Auth, InvalidAction)"
+	_, err = VerifyChallenge(context.Background(), simulator, signed, f.read, f.server.Address())
+	assert.EqualError(t, err, "challenge verification failed: HostError: Error(Auth, InvalidAction)")
+}
+
+func TestAuthorizationHash(t *testing.T) {
+	f := newFixture(t)
+	challenge, err := BuildChallenge(context.Background(), f.params)
+	require.NoError(t, err)
+
+	hash, err := AuthorizationHash(network.TestNetworkPassphrase, challenge.Entries[0], 1000)
+	require.NoError(t, err)
+	vec, _ := challenge.Entries[0].Credentials.Address.Signature.GetVec()
+	sigMap, _ := (*vec)[0].GetMap()
+	signature, _ := (*sigMap)[1].Val.GetBytes()
+	assert.NoError(t, f.server.Verify(hash[:], signature))
+
+	_, err = AuthorizationHash(network.TestNetworkPassphrase, xdr.SorobanAuthorizationEntry{}, 1000)
+	assert.EqualError(t, err, "authorization entry does not have address credentials")
+}
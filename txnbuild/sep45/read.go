@@ -0,0 +1,249 @@
+package sep45
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"sort"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// ReadParams are the values a challenge is checked against by ReadChallenge.
+type ReadParams struct {
+	NetworkPassphrase string
+	// WebAuthContract is the C... address of the web auth contract, the
+	// WEB_AUTH_CONTRACT_ID of the home domain.
+	WebAuthContract string
+	// ServerAccount is the SIGNING_KEY of the home domain.
+	ServerAccount string
+	// WebAuthDomain is the host of the WEB_AUTH_FOR_CONTRACTS_ENDPOINT.
+	WebAuthDomain string
+	// HomeDomains lists the accepted home domains.
+	HomeDomains []string
+}
+
+// ReadChallenge decodes a challenge from the base64 XDR of its authorization
+// entries and validates it. Every entry must authorize the same call to
+// web_auth_verify on the web auth contract, and nothing else; the arguments
+// must match params; there must be exactly one entry for the server, the
+// client account and, if given, the client domain account; and the server's
+// entry must carry a valid signature of the server account.
+//
+// ReadChallenge does not check the signatures of the client's entries; use
+// VerifyChallenge to do so. Servers must also check that they issued the
+// nonce and that it has not been used before.
+func ReadChallenge(encoded string, params ReadParams) (*Challenge, error) {
+	var entries xdr.SorobanAuthorizationEntries
+	if err := xdr.SafeUnmarshalBase64(encoded, &entries); err != nil {
+		return nil, errors.Wrap(err, "could not parse authorization entries")
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("challenge has no authorization entries")
+	}
+
+	first := entries[0].RootInvocation
+	for _, entry := range entries {
+		if entry.Credentials.Type != xdr.SorobanCredentialsTypeSorobanCredentialsAddress ||
+			entry.Credentials.Address == nil {
+			return nil, errors.New("authorization entries must have address credentials")
+		}
+		same, err := sameInvocation(entry.RootInvocation, first)
+		if err != nil {
+			return nil, err
+		}
+		if !same {
+			return nil, errors.New("authorization entries authorize different invocations")
+		}
+	}
+
+	fn := first.Function
+	if fn.Type != xdr.SorobanAuthorizedFunctionTypeSorobanAuthorizedFunctionTypeContractFn || fn.ContractFn == nil {
+		return nil, errors.New("authorization entries must authorize a contract call")
+	}
+	if len(first.SubInvocations) != 0 {
+		return nil, errors.New("authorization entries must not authorize sub-invocations")
+	}
+	contract, err := fn.ContractFn.ContractAddress.String()
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid contract address")
+	}
+	if contract != params.WebAuthContract {
+		return nil, errors.Errorf("challenge calls contract %s instead of %s", contract, params.WebAuthContract)
+	}
+	if string(fn.ContractFn.FunctionName) != FunctionName {
+		return nil, errors.Errorf("challenge calls %s instead of %s", fn.ContractFn.FunctionName, FunctionName)
+	}
+	if len(fn.ContractFn.Args) != 1 {
+		return nil, errors.Errorf("%s must be called with a single argument", FunctionName)
+	}
+	args, err := decodeArgs(fn.ContractFn.Args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	challenge := &Challenge{
+		WebAuthContract:      contract,
+		Account:              args[ArgAccount],
+		HomeDomain:           args[ArgHomeDomain],
+		WebAuthDomain:        args[ArgWebAuthDomain],
+		WebAuthDomainAccount: args[ArgWebAuthDomainAccount],
+		Nonce:                args[ArgNonce],
+		ClientDomain:         args[ArgClientDomain],
+		ClientDomainAccount:  args[ArgClientDomainAccount],
+		Entries:              entries,
+	}
+	if len(args) != len(challenge.args()) {
+		return nil, errors.Errorf("%s has unexpected arguments", FunctionName)
+	}
+	if err := challenge.validate(params); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+func (c *Challenge) validate(params ReadParams) error {
+	if !strkey.IsValidContractAddress(c.Account) {
+		return errors.Errorf("account %q is not a contract address", c.Account)
+	}
+	if c.WebAuthDomainAccount != params.ServerAccount {
+		return errors.Errorf("web_auth_domain_account is %s instead of %s", c.WebAuthDomainAccount, params.ServerAccount)
+	}
+	if c.WebAuthDomain != params.WebAuthDomain {
+		return errors.Errorf("web_auth_domain is %q instead of %q", c.WebAuthDomain, params.WebAuthDomain)
+	}
+	homeDomain := false
+	for _, domain := range params.HomeDomains {
+		if c.HomeDomain == domain {
+			homeDomain = true
+			break
+		}
+	}
+	if !homeDomain {
+		return errors.Errorf("home_domain %q does not match any home domains %v", c.HomeDomain, params.HomeDomains)
+	}
+	if c.Nonce == "" {
+		return errors.New("nonce is empty")
+	}
+	if (c.ClientDomain == "") != (c.ClientDomainAccount == "") {
+		return errors.New("client_domain and client_domain_account must be set together")
+	}
+
+	expected := map[string]bool{c.WebAuthDomainAccount: true, c.Account: true}
+	if c.ClientDomainAccount != "" {
+		expected[c.ClientDomainAccount] = true
+	}
+	seen := map[string]bool{}
+	for _, entry := range c.Entries {
+		address := entryAddress(entry)
+		if !expected[address] {
+			return errors.Errorf("unexpected authorization entry for %s", address)
+		}
+		if seen[address] {
+			return errors.Errorf("repeated authorization entry for %s", address)
+		}
+		seen[address] = true
+	}
+	if len(seen) != len(expected) {
+		return errors.New("challenge is missing authorization entries")
+	}
+
+	server, _ := c.Entry(c.WebAuthDomainAccount)
+	if err := verifyAccountSignature(c.Entries[server], params.NetworkPassphrase, c.WebAuthDomainAccount); err != nil {
+		return errors.Wrap(err, "invalid server signature")
+	}
+	return nil
+}
+
+// verifyAccountSignature checks that the entry carries a valid signature of
+// the G... account, in the signature format of Stellar accounts.
+func verifyAccountSignature(entry xdr.SorobanAuthorizationEntry, networkPassphrase, account string) error {
+	kp, err := keypair.ParseAddress(account)
+	if err != nil {
+		return err
+	}
+	rawKey, err := strkey.Decode(strkey.VersionByteAccountID, account)
+	if err != nil {
+		return err
+	}
+	hash, err := AuthorizationHash(networkPassphrase, entry, uint32(entry.Credentials.Address.SignatureExpirationLedger))
+	if err != nil {
+		return err
+	}
+
+	vec, ok := entry.Credentials.Address.Signature.GetVec()
+	if !ok || vec == nil {
+		return errors.New("entry is not signed")
+	}
+	for _, sig := range *vec {
+		sigMap, ok := sig.GetMap()
+		if !ok || sigMap == nil {
+			continue
+		}
+		var key, signature []byte
+		for _, field := range *sigMap {
+			name, _ := field.Key.GetSym()
+			value, ok := field.Val.GetBytes()
+			if !ok {
+				continue
+			}
+			switch name {
+			case "public_key":
+				key = value
+			case "signature":
+				signature = value
+			}
+		}
+		if bytes.Equal(key, rawKey) && kp.Verify(hash[:], signature) == nil {
+			return nil
+		}
+	}
+	return errors.Errorf("entry is not signed by %s", account)
+}
+
+// AuthorizationHash returns the hash an account signs to authorize the entry
+// until validUntilLedger. Wallets whose account contracts expect a custom
+// signature format sign it and set the entry's signature themselves.
+func AuthorizationHash(networkPassphrase string, entry xdr.SorobanAuthorizationEntry, validUntilLedger uint32) ([32]byte, error) {
+	if entry.Credentials.Address == nil {
+		return [32]byte{}, errors.New("authorization entry does not have address credentials")
+	}
+	preimage := xdr.HashIdPreimage{
+		Type: xdr.EnvelopeTypeEnvelopeTypeSorobanAuthorization,
+		SorobanAuthorization: &xdr.HashIdPreimageSorobanAuthorization{
+			NetworkId:                 network.ID(networkPassphrase),
+			Nonce:                     entry.Credentials.Address.Nonce,
+			SignatureExpirationLedger: xdr.Uint32(validUntilLedger),
+			Invocation:                entry.RootInvocation,
+		},
+	}
+	payload, err := preimage.MarshalBinary()
+	if err != nil {
+		return [32]byte{}, errors.Wrap(err, "failed to marshal authorization preimage")
+	}
+	return sha256.Sum256(payload), nil
+}
+
+func sameInvocation(a, b xdr.SorobanAuthorizedInvocation) (bool, error) {
+	encodedA, err := a.MarshalBinary()
+	if err != nil {
+		return false, errors.Wrap(err, "could not encode invocation")
+	}
+	encodedB, err := b.MarshalBinary()
+	if err != nil {
+		return false, errors.Wrap(err, "could not encode invocation")
+	}
+	return bytes.Equal(encodedA, encodedB), nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
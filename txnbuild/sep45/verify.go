@@ -0,0 +1,101 @@
+package sep45
+
+import (
+	"context"
+
+	protocol "github.com/stellar/go/protocols/rpc"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+)
+
+// SignChallenge signs the entry of address, the client account or the
+// client domain account, with signers in the signature format of Stellar
+// accounts, which account contracts delegating to ed25519 keys commonly
+// use. The signature is valid until validUntilLedger. Wallets with other
+// signature formats sign the AuthorizationHash of the entry instead.
+func SignChallenge(
+	ctx context.Context,
+	challenge *Challenge,
+	networkPassphrase, address string,
+	validUntilLedger uint32,
+	signers ...txnbuild.HashSigner,
+) error {
+	if validUntilLedger == 0 {
+		return errors.New("valid until ledger is required")
+	}
+	i, ok := challenge.Entry(address)
+	if !ok {
+		return errors.Errorf("challenge has no authorization entry for %s", address)
+	}
+	signed, err := txnbuild.SignAuthEntry(ctx, networkPassphrase, challenge.Entries[i], validUntilLedger, signers...)
+	if err != nil {
+		return err
+	}
+	challenge.Entries[i] = signed
+	return nil
+}
+
+// Simulator simulates transactions. It is implemented by the RPC client
+// in clients/rpcclient.
+type Simulator interface {
+	SimulateTransaction(ctx context.Context, request protocol.SimulateTransactionRequest) (protocol.SimulateTransactionResponse, error)
+}
+
+// VerifyChallenge reads a signed challenge with ReadChallenge and verifies
+// the signatures of the client's entries by simulating the call to
+// web_auth_verify with the entries in enforcing auth mode, which runs the
+// __check_auth function of the client account. sourceAccount is the source
+// account of the simulated transaction; it must exist on the network and
+// is usually the server account.
+func VerifyChallenge(
+	ctx context.Context,
+	simulator Simulator,
+	encoded string,
+	params ReadParams,
+	sourceAccount string,
+) (*Challenge, error) {
+	challenge, err := ReadChallenge(encoded, params)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range challenge.Entries {
+		if entry.Credentials.Address.Signature.Type == xdr.ScValTypeScvVoid {
+			return nil, errors.Errorf("authorization entry for %s is not signed", entryAddress(entry))
+		}
+	}
+
+	invocation := challenge.Entries[0].RootInvocation.Function.ContractFn
+	tx, err := txnbuild.NewTransaction(txnbuild.TransactionParams{
+		SourceAccount:        &txnbuild.SimpleAccount{AccountID: sourceAccount},
+		IncrementSequenceNum: true,
+		Operations: []txnbuild.Operation{&txnbuild.InvokeHostFunction{
+			HostFunction: xdr.HostFunction{
+				Type:           xdr.HostFunctionTypeHostFunctionTypeInvokeContract,
+				InvokeContract: invocation,
+			},
+			Auth: challenge.Entries,
+		}},
+		BaseFee:       txnbuild.MinBaseFee,
+		Preconditions: txnbuild.Preconditions{TimeBounds: txnbuild.NewInfiniteTimeout()},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not build verification transaction")
+	}
+	b64, err := tx.Base64()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not encode verification transaction")
+	}
+
+	response, err := simulator.SimulateTransaction(ctx, protocol.SimulateTransactionRequest{
+		Transaction: b64,
+		AuthMode:    protocol.AuthModeEnforce,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "simulateTransaction failed")
+	}
+	if response.Error != "" {
+		return nil, errors.Errorf("challenge verification failed: %s", response.Error)
+	}
+	return challenge, nil
+}
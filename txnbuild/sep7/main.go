@@ -0,0 +1,396 @@
+// Package sep7 builds and parses SEP-7 `web+stellar:` URIs, which delegate
+// the signing of a transaction (`tx` operation) or the building and signing
+// of a payment (`pay` operation) to a wallet.
+// See https://github.com/stellar/stellar-protocol/blob/master/ecosystem/sep-0007.md
+package sep7
+
+import (
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/txnbuild"
+)
+
+// Scheme is the scheme of SEP-7 URIs.
+const Scheme = "web+stellar"
+
+// MaxMessageLength is the maximum length of the msg field.
+const MaxMessageLength = 300
+
+// callbackPrefix prefixes callback URLs.
+const callbackPrefix = "url:"
+
+// Operation is the operation of a SEP-7 URI.
+type Operation string
+
+const (
+	// OperationTx requests the signing of a transaction.
+	OperationTx Operation = "tx"
+	// OperationPay requests a payment.
+	OperationPay Operation = "pay"
+)
+
+// MemoType is the type of the memo of a pay request.
+type MemoType string
+
+const (
+	MemoText   MemoType = "MEMO_TEXT"
+	MemoID     MemoType = "MEMO_ID"
+	MemoHash   MemoType = "MEMO_HASH"
+	MemoReturn MemoType = "MEMO_RETURN"
+)
+
+// Options are the fields common to all requests.
+type Options struct {
+	// Callback is the URL the signed transaction is posted to instead of
+	// being submitted to the network by the wallet.
+	Callback string
+	// Message is shown to the user, at most MaxMessageLength characters.
+	Message string
+	// NetworkPassphrase is the passphrase of the network of the request,
+	// the public network if it is empty.
+	NetworkPassphrase string
+	// OriginDomain is the domain of the request's originator. Requests with
+	// an origin domain must be signed with the URI_REQUEST_SIGNING_KEY of the
+	// domain's stellar.toml, see Sign and VerifyOriginDomain.
+	OriginDomain string
+	// Signature is the base64 encoded signature of the request. It is set
+	// when parsing a signed URI and ignored when encoding, use Sign instead.
+	Signature string
+}
+
+// Replacement identifies a field of the transaction of a tx request which
+// the wallet should replace, using SEP-11 Txrep paths.
+type Replacement struct {
+	// Path is the Txrep path of the field, e.g. `sourceAccount` or
+	// `operations[0].sourceAccount`.
+	Path string
+	// ID identifies the value to use for the field. Fields with the same ID
+	// are replaced with the same value.
+	ID string
+	// Hint describes the value to the user.
+	Hint string
+}
+
+// Request is a parsed SEP-7 request, either a *TransactionRequest or a
+// *PayRequest.
+type Request interface {
+	// Operation returns the operation of the request.
+	Operation() Operation
+	// Encode returns the URI of the request, without signature.
+	Encode() (string, error)
+}
+
+// TransactionRequest is a request to sign a transaction.
+type TransactionRequest struct {
+	Transaction *txnbuild.GenericTransaction
+	// Replace lists the fields of the transaction the wallet should replace.
+	Replace []Replacement
+	// PublicKey is the account which should sign the transaction.
+	PublicKey string
+	// Chain is a signed URI which this request is a response to.
+	Chain string
+	Options
+}
+
+// PayRequest is a request to pay to a destination.
+type PayRequest struct {
+	Destination string
+	// Amount is optional, the wallet asks the user for it if it is empty.
+	Amount string
+	// AssetCode and AssetIssuer are those of the asset to pay, which is the
+	// native asset if both are empty.
+	AssetCode   string
+	AssetIssuer string
+	Memo        string
+	// MemoType is the type of Memo, MemoText if it is empty. The memo of
+	// MemoHash and MemoReturn memos is base64 encoded.
+	MemoType MemoType
+	Options
+}
+
+// Operation returns OperationTx.
+func (r *TransactionRequest) Operation() Operation {
+	return OperationTx
+}
+
+// Operation returns OperationPay.
+func (r *PayRequest) Operation() Operation {
+	return OperationPay
+}
+
+// Encode returns the URI of the request, without signature.
+func (r *TransactionRequest) Encode() (string, error) {
+	if r.Transaction == nil {
+		return "", errors.New("transaction is required")
+	}
+	if err := r.validate(); err != nil {
+		return "", err
+	}
+	envelope, err := r.Transaction.MarshalText()
+	if err != nil {
+		return "", errors.Wrap(err, "could not encode transaction")
+	}
+
+	params := &params{}
+	params.add("xdr", string(envelope))
+	params.add("replace", encodeReplacements(r.Replace))
+	params.add("pubkey", r.PublicKey)
+	params.add("chain", r.Chain)
+	r.Options.encode(params)
+	return params.uri(OperationTx), nil
+}
+
+func (r *TransactionRequest) validate() error {
+	if r.PublicKey != "" && !strkey.IsValidEd25519PublicKey(r.PublicKey) {
+		return errors.Errorf("invalid pubkey %s", r.PublicKey)
+	}
+	for _, replacement := range r.Replace {
+		if replacement.Path == "" || replacement.ID == "" {
+			return errors.New("replacements must have a path and an id")
+		}
+		if strings.ContainsAny(replacement.Path+replacement.ID, ":,;") ||
+			strings.ContainsAny(replacement.Hint, ",;") {
+			return errors.New("replacements must not contain ':', ',' or ';'")
+		}
+	}
+	return r.Options.validate()
+}
+
+// Encode returns the URI of the request, without signature.
+func (r *PayRequest) Encode() (string, error) {
+	if err := r.validate(); err != nil {
+		return "", err
+	}
+
+	params := &params{}
+	params.add("destination", r.Destination)
+	params.add("amount", r.Amount)
+	params.add("asset_code", r.AssetCode)
+	params.add("asset_issuer", r.AssetIssuer)
+	params.add("memo", r.Memo)
+	params.add("memo_type", string(r.MemoType))
+	r.Options.encode(params)
+	return params.uri(OperationPay), nil
+}
+
+func (r *PayRequest) validate() error {
+	if !strkey.IsValidEd25519PublicKey(r.Destination) &&
+		!strkey.IsValidMuxedAccountEd25519PublicKey(r.Destination) &&
+		!strkey.IsValidContractAddress(r.Destination) {
+		return errors.Errorf("invalid destination %q", r.Destination)
+	}
+	if r.Amount != "" {
+		if value, err := amount.Parse(r.Amount); err != nil || value <= 0 {
+			return errors.Errorf("invalid amount %q", r.Amount)
+		}
+	}
+	if r.AssetIssuer != "" || r.AssetCode != "" {
+		asset := txnbuild.CreditAsset{Code: r.AssetCode, Issuer: r.AssetIssuer}
+		if _, err := asset.ToXDR(); err != nil {
+			return errors.Wrap(err, "invalid asset")
+		}
+	}
+
+	switch r.MemoType {
+	case "", MemoText:
+		if len(r.Memo) > 28 {
+			return errors.New("text memo must be at most 28 bytes")
+		}
+	case MemoID:
+		if _, err := strconv.ParseUint(r.Memo, 10, 64); err != nil {
+			return errors.Errorf("invalid id memo %q", r.Memo)
+		}
+	case MemoHash, MemoReturn:
+		hash, err := base64.StdEncoding.DecodeString(r.Memo)
+		if err != nil || len(hash) != 32 {
+			return errors.Errorf("%s memo must be 32 base64 encoded bytes", r.MemoType)
+		}
+	default:
+		return errors.Errorf("invalid memo type %q", r.MemoType)
+	}
+	if r.MemoType != "" && r.Memo == "" {
+		return errors.New("memo type without memo")
+	}
+	return r.Options.validate()
+}
+
+func (o Options) validate() error {
+	if len([]rune(o.Message)) > MaxMessageLength {
+		return errors.Errorf("msg must be at most %d characters", MaxMessageLength)
+	}
+	if o.Callback != "" {
+		if _, err := url.ParseRequestURI(o.Callback); err != nil {
+			return errors.Wrap(err, "invalid callback")
+		}
+	}
+	return nil
+}
+
+func (o Options) encode(params *params) {
+	if o.Callback != "" {
+		params.add("callback", callbackPrefix+o.Callback)
+	}
+	params.add("msg", o.Message)
+	params.add("network_passphrase", o.NetworkPassphrase)
+	params.add("origin_domain", o.OriginDomain)
+}
+
+// params is an ordered list of query parameters.
+type params struct {
+	query strings.Builder
+}
+
+// add adds the parameter if the value is not empty.
+func (p *params) add(key, value string) {
+	if value == "" {
+		return
+	}
+	if p.query.Len() > 0 {
+		p.query.WriteByte('&')
+	}
+	p.query.WriteString(key)
+	p.query.WriteByte('=')
+	p.query.WriteString(encodeComponent(value))
+}
+
+func (p *params) uri(operation Operation) string {
+	return Scheme + ":" + string(operation) + "?" + p.query.String()
+}
+
+// encodeComponent escapes s like javascript's encodeURIComponent, which is
+// what SEP-7 URIs are usually generated with.
+func encodeComponent(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func encodeReplacements(replacements []Replacement) string {
+	if len(replacements) == 0 {
+		return ""
+	}
+	fields := make([]string, 0, len(replacements))
+	hints := []string{}
+	hinted := map[string]bool{}
+	for _, replacement := range replacements {
+		fields = append(fields, replacement.Path+":"+replacement.ID)
+		if replacement.Hint != "" && !hinted[replacement.ID] {
+			hints = append(hints, replacement.ID+":"+replacement.Hint)
+			hinted[replacement.ID] = true
+		}
+	}
+	encoded := strings.Join(fields, ",")
+	if len(hints) > 0 {
+		encoded += ";" + strings.Join(hints, ",")
+	}
+	return encoded
+}
+
+func parseReplacements(encoded string) ([]Replacement, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+	fieldsPart, hintsPart, _ := strings.Cut(encoded, ";")
+	hints := map[string]string{}
+	if hintsPart != "" {
+		for _, hint := range strings.Split(hintsPart, ",") {
+			id, text, ok := strings.Cut(hint, ":")
+			if !ok || id == "" {
+				return nil, errors.Errorf("invalid replacement hint %q", hint)
+			}
+			hints[id] = text
+		}
+	}
+
+	var replacements []Replacement
+	for _, field := range strings.Split(fieldsPart, ",") {
+		path, id, ok := strings.Cut(field, ":")
+		if !ok || path == "" || id == "" {
+			return nil, errors.Errorf("invalid replacement %q", field)
+		}
+		replacements = append(replacements, Replacement{Path: path, ID: id, Hint: hints[id]})
+	}
+	return replacements, nil
+}
+
+// Parse parses a SEP-7 URI. The signature of the URI, if any, is not
+// verified, see Verify and VerifyOriginDomain.
+func Parse(uri string) (Request, error) {
+	operation, rawQuery, err := splitURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid query")
+	}
+
+	options := Options{
+		Message:           query.Get("msg"),
+		NetworkPassphrase: query.Get("network_passphrase"),
+		OriginDomain:      query.Get("origin_domain"),
+		Signature:         query.Get("signature"),
+	}
+	if callback := query.Get("callback"); callback != "" {
+		if !strings.HasPrefix(callback, callbackPrefix) {
+			return nil, errors.Errorf("callback must start with %q", callbackPrefix)
+		}
+		options.Callback = strings.TrimPrefix(callback, callbackPrefix)
+	}
+
+	switch operation {
+	case OperationTx:
+		envelope := query.Get("xdr")
+		if envelope == "" {
+			return nil, errors.New("xdr is required")
+		}
+		tx, err := txnbuild.TransactionFromXDR(envelope)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid xdr")
+		}
+		replacements, err := parseReplacements(query.Get("replace"))
+		if err != nil {
+			return nil, err
+		}
+		request := &TransactionRequest{
+			Transaction: tx,
+			Replace:     replacements,
+			PublicKey:   query.Get("pubkey"),
+			Chain:       query.Get("chain"),
+			Options:     options,
+		}
+		if err := request.validate(); err != nil {
+			return nil, err
+		}
+		return request, nil
+	case OperationPay:
+		request := &PayRequest{
+			Destination: query.Get("destination"),
+			Amount:      query.Get("amount"),
+			AssetCode:   query.Get("asset_code"),
+			AssetIssuer: query.Get("asset_issuer"),
+			Memo:        query.Get("memo"),
+			MemoType:    MemoType(query.Get("memo_type")),
+			Options:     options,
+		}
+		if err := request.validate(); err != nil {
+			return nil, err
+		}
+		return request, nil
+	default:
+		return nil, errors.Errorf("unknown operation %q", operation)
+	}
+}
+
+func splitURI(uri string) (Operation, string, error) {
+	rest, ok := strings.CutPrefix(uri, Scheme+":")
+	if !ok {
+		return "", "", errors.Errorf("uri must start with %s:", Scheme)
+	}
+	operation, rawQuery, _ := strings.Cut(rest, "?")
+	return Operation(operation), rawQuery, nil
+}
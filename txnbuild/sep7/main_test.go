@@ -0,0 +1,138 @@
+package sep7
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/txnbuild"
+)
+
+func newTestTransaction(t *testing.T, source string) *txnbuild.Transaction {
+	account := txnbuild.NewSimpleAccount(source, 1)
+	tx, err := txnbuild.NewTransaction(txnbuild.TransactionParams{
+		SourceAccount: &account,
+		Operations: []txnbuild.Operation{&txnbuild.ChangeTrust{
+			Line: txnbuild.ChangeTrustAssetWrapper{Asset: txnbuild.CreditAsset{
+				Code:   "USD",
+				Issuer: "GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO",
+			}},
+		}},
+		BaseFee:       txnbuild.MinBaseFee,
+		Preconditions: txnbuild.Preconditions{TimeBounds: txnbuild.NewInfiniteTimeout()},
+	})
+	require.NoError(t, err)
+	return tx
+}
+
+func TestTransactionRequest(t *testing.T) {
+	source := keypair.MustRandom().Address()
+	tx := newTestTransaction(t, source)
+	request := &TransactionRequest{
+		Transaction: tx.ToGenericTransaction(),
+		Replace: []Replacement{
+			{Path: "sourceAccount", ID: "X", Hint: "account paying the fees"},
+			{Path: "operations[0].sourceAccount", ID: "X"},
+		},
+		PublicKey: source,
+		Options: Options{
+			Callback:          "https://example.com/sign?id=1",
+			Message:           "add a trustline & more",
+			NetworkPassphrase: network.TestNetworkPassphrase,
+			OriginDomain:      "example.com",
+		},
+	}
+	uri, err := request.Encode()
+	require.NoError(t, err)
+
+	envelope, err := tx.Base64()
+	require.NoError(t, err)
+	assert.Equal(t, "web+stellar:tx?xdr="+encodeComponent(envelope)+
+		"&replace=sourceAccount%3AX%2Coperations%5B0%5D.sourceAccount%3AX%3BX%3Aaccount%20paying%20the%20fees"+
+		"&pubkey="+source+
+		"&callback=url%3Ahttps%3A%2F%2Fexample.com%2Fsign%3Fid%3D1"+
+		"&msg=add%20a%20trustline%20%26%20more"+
+		"&network_passphrase=Test%20SDF%20Network%20%3B%20September%202015"+
+		"&origin_domain=example.com", uri)
+
+	parsed, err := Parse(uri)
+	require.NoError(t, err)
+	require.Equal(t, OperationTx, parsed.Operation())
+	parsedRequest := parsed.(*TransactionRequest)
+	parsedTx, ok := parsedRequest.Transaction.Transaction()
+	require.True(t, ok)
+	parsedEnvelope, err := parsedTx.Base64()
+	require.NoError(t, err)
+	assert.Equal(t, envelope, parsedEnvelope)
+	assert.Equal(t, []Replacement{
+		{Path: "sourceAccount", ID: "X", Hint: "account paying the fees"},
+		{Path: "operations[0].sourceAccount", ID: "X", Hint: "account paying the fees"},
+	}, parsedRequest.Replace)
+	assert.Equal(t, request.Options, parsedRequest.Options)
+	assert.Equal(t, source, parsedRequest.PublicKey)
+
+	reencoded, err := parsedRequest.Encode()
+	require.NoError(t, err)
+	assert.Equal(t, uri, reencoded)
+}
+
+func TestPayRequest(t *testing.T) {
+	request := &PayRequest{
+		Destination: "GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO",
+		Amount:      "120.1234567",
+		Memo:        "skdjfasf",
+		MemoType:    MemoText,
+		Options: Options{
+			Message: "pay me with lumens",
+		},
+	}
+	uri, err := request.Encode()
+	require.NoError(t, err)
+	assert.Equal(t, "web+stellar:pay?destination=GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO"+
+		"&amount=120.1234567&memo=skdjfasf&memo_type=MEMO_TEXT&msg=pay%20me%20with%20lumens", uri)
+
+	parsed, err := Parse(uri)
+	require.NoError(t, err)
+	assert.Equal(t, request, parsed)
+
+	parsed, err = Parse("web+stellar:pay?destination=GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO" +
+		"&asset_code=USD&asset_issuer=GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO" +
+		"&memo=MTIzNDU2Nzg5MDEyMzQ1Njc4OTAxMjM0NTY3ODkwMTI%3D&memo_type=MEMO_HASH")
+	require.NoError(t, err)
+	assert.Equal(t, &PayRequest{
+		Destination: "GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO",
+		AssetCode:   "USD",
+		AssetIssuer: "GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO",
+		Memo:        "MTIzNDU2Nzg5MDEyMzQ1Njc4OTAxMjM0NTY3ODkwMTI=",
+		MemoType:    MemoHash,
+	}, parsed)
+}
+
+func TestParseInvalid(t *testing.T) {
+	const destination = "destination=GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO"
+	for _, testCase := range []struct {
+		uri   string
+		error string
+	}{
+		{"https://example.com", "uri must start with web+stellar:"},
+		{"web+stellar:send?" + destination, `unknown operation "send"`},
+		{"web+stellar:tx?msg=hi", "xdr is required"},
+		{"web+stellar:tx?xdr=AAAA", "invalid xdr"},
+		{"web+stellar:pay?destination=nope", `invalid destination "nope"`},
+		{"web+stellar:pay?" + destination + "&amount=-1", `invalid amount "-1"`},
+		{"web+stellar:pay?" + destination + "&memo=abc&memo_type=MEMO_ID", `invalid id memo "abc"`},
+		{"web+stellar:pay?" + destination + "&memo=abc&memo_type=MEMO_RETURN", "MEMO_RETURN memo must be 32 base64 encoded bytes"},
+		{"web+stellar:pay?" + destination + "&asset_issuer=GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO", "invalid asset"},
+		{"web+stellar:pay?" + destination + "&callback=https%3A%2F%2Fexample.com", `callback must start with "url:"`},
+		{"web+stellar:pay?" + destination + "&msg=" + strings.Repeat("a", MaxMessageLength+1), "msg must be at most 300 characters"},
+	} {
+		t.Run(testCase.uri, func(t *testing.T) {
+			_, err := Parse(testCase.uri)
+			assert.ErrorContains(t, err, testCase.error)
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package sep7
+
+import (
+	"encoding/base64"
+	"net/url"
+	"strings"
+
+	"github.com/stellar/go/clients/stellartoml"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/support/errors"
+)
+
+// signaturePrefix precedes the URI in the signed payload: 35 zero bytes
+// followed by 4.
+var signaturePrefix = append(make([]byte, 35), 4)
+
+const signaturePayloadPrefix = "stellar.sep.7 - URI Scheme"
+
+const signatureParam = "signature"
+
+func signaturePayload(uri string) []byte {
+	payload := make([]byte, 0, len(signaturePrefix)+len(signaturePayloadPrefix)+len(uri))
+	payload = append(payload, signaturePrefix...)
+	payload = append(payload, signaturePayloadPrefix...)
+	return append(payload, uri...)
+}
+
+// splitSignature returns the URI without its signature, which must be the
+// last parameter, and the decoded signature. The signature is nil if the URI
+// is not signed.
+func splitSignature(uri string) (string, []byte, error) {
+	_, rawQuery, err := splitURI(uri)
+	if err != nil {
+		return "", nil, err
+	}
+	params := strings.Split(rawQuery, "&")
+	var signature string
+	for i, param := range params {
+		key, value, _ := strings.Cut(param, "=")
+		if key != signatureParam {
+			continue
+		}
+		if i != len(params)-1 {
+			return "", nil, errors.New("signature must be the last parameter")
+		}
+		signature, err = url.QueryUnescape(value)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "invalid signature")
+		}
+	}
+	if signature == "" {
+		return uri, nil, nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "invalid signature")
+	}
+	unsigned := uri[:strings.LastIndex(uri, "&"+signatureParam+"=")]
+	return unsigned, decoded, nil
+}
+
+// Sign signs the URI with kp, which should be the URI_REQUEST_SIGNING_KEY of
+// the URI's origin domain, and returns the signed URI.
+func Sign(uri string, kp *keypair.Full) (string, error) {
+	if _, err := Parse(uri); err != nil {
+		return "", err
+	}
+	_, signature, err := splitSignature(uri)
+	if err != nil {
+		return "", err
+	}
+	if signature != nil {
+		return "", errors.New("uri is already signed")
+	}
+
+	sig, err := kp.Sign(signaturePayload(uri))
+	if err != nil {
+		return "", errors.Wrap(err, "could not sign uri")
+	}
+	return uri + "&" + signatureParam + "=" + encodeComponent(base64.StdEncoding.EncodeToString(sig)), nil
+}
+
+// Verify verifies the signature of the URI with the signing key, a G...
+// address.
+func Verify(uri string, signingKey string) error {
+	kp, err := keypair.ParseAddress(signingKey)
+	if err != nil {
+		return errors.Wrap(err, "invalid signing key")
+	}
+	unsigned, signature, err := splitSignature(uri)
+	if err != nil {
+		return err
+	}
+	if signature == nil {
+		return errors.New("uri is not signed")
+	}
+	if err := kp.Verify(signaturePayload(unsigned), signature); err != nil {
+		return errors.Wrap(err, "invalid signature")
+	}
+	return nil
+}
+
+// VerifyOriginDomain verifies the signature of a URI with an origin domain
+// using the URI_REQUEST_SIGNING_KEY of the domain's stellar.toml, and
+// returns the parsed request. An error is returned if the URI has no origin
+// domain.
+func VerifyOriginDomain(uri string, client stellartoml.ClientInterface) (Request, error) {
+	request, err := Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	var domain string
+	switch r := request.(type) {
+	case *TransactionRequest:
+		domain = r.OriginDomain
+	case *PayRequest:
+		domain = r.OriginDomain
+	}
+	if domain == "" {
+		return nil, errors.New("uri has no origin domain")
+	}
+
+	toml, err := client.GetStellarToml(domain)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not fetch stellar.toml of %s", domain)
+	}
+	if toml.UriRequestSigningKey == "" {
+		return nil, errors.Errorf("stellar.toml of %s has no URI_REQUEST_SIGNING_KEY", domain)
+	}
+	if err := Verify(uri, toml.UriRequestSigningKey); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
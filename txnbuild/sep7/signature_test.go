@@ -0,0 +1,80 @@
+package sep7
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/clients/stellartoml"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/support/errors"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	signingKey := keypair.MustRandom()
+	request := &PayRequest{
+		Destination: "GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO",
+		Amount:      "10",
+		Options: Options{
+			Message:      "pay me with lumens",
+			OriginDomain: "example.com",
+		},
+	}
+	uri, err := request.Encode()
+	require.NoError(t, err)
+
+	signed, err := Sign(uri, signingKey)
+	require.NoError(t, err)
+	assert.Contains(t, signed, uri+"&signature=")
+	require.NoError(t, Verify(signed, signingKey.Address()))
+
+	parsed, err := Parse(signed)
+	require.NoError(t, err)
+	assert.NotEmpty(t, parsed.(*PayRequest).Signature)
+	// The signature is not part of the encoded request.
+	reencoded, err := parsed.Encode()
+	require.NoError(t, err)
+	assert.Equal(t, uri, reencoded)
+
+	_, err = Sign(signed, signingKey)
+	assert.EqualError(t, err, "uri is already signed")
+
+	assert.EqualError(t, Verify(uri, signingKey.Address()), "uri is not signed")
+	assert.ErrorContains(t, Verify(signed, keypair.MustRandom().Address()), "invalid signature")
+	tampered := "web+stellar:pay?destination=GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO&amount=100" +
+		signed[len("web+stellar:pay?destination=GCALNQQBXAPZ2WIRSDDBMSTAKCUH5SG6U76YBFLQLIXJTF7FE5AX7AOO&amount=10"):]
+	assert.ErrorContains(t, Verify(tampered, signingKey.Address()), "invalid signature")
+	assert.EqualError(t, Verify(signed+"&msg=hi", signingKey.Address()), "signature must be the last parameter")
+
+	client := &stellartoml.MockClient{}
+	client.On("GetStellarToml", "example.com").
+		Return(&stellartoml.Response{UriRequestSigningKey: signingKey.Address()}, nil).Once()
+	verified, err := VerifyOriginDomain(signed, client)
+	require.NoError(t, err)
+	assert.Equal(t, parsed, verified)
+
+	client.On("GetStellarToml", "example.com").
+		Return(&stellartoml.Response{UriRequestSigningKey: keypair.MustRandom().Address()}, nil).Once()
+	_, err = VerifyOriginDomain(signed, client)
+	assert.ErrorContains(t, err, "invalid signature")
+
+	client.On("GetStellarToml", "example.com").
+		Return(&stellartoml.Response{}, nil).Once()
+	_, err = VerifyOriginDomain(signed, client)
+	assert.EqualError(t, err, "stellar.toml of example.com has no URI_REQUEST_SIGNING_KEY")
+
+	client.On("GetStellarToml", "example.com").
+		Return((*stellartoml.Response)(nil), errors.New("timeout")).Once()
+	_, err = VerifyOriginDomain(signed, client)
+	assert.EqualError(t, err, "could not fetch stellar.toml of example.com: timeout")
+	client.AssertExpectations(t)
+
+	request.OriginDomain = ""
+	uri, err = request.Encode()
+	require.NoError(t, err)
+	signed, err = Sign(uri, signingKey)
+	require.NoError(t, err)
+	_, err = VerifyOriginDomain(signed, client)
+	assert.EqualError(t, err, "uri has no origin domain")
+}
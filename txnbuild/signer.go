@@ -0,0 +1,259 @@
+package txnbuild
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"sort"
+
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// HashSigner produces ed25519 signatures on behalf of a Stellar account without
+// requiring its secret key to be held by the caller. Implementations may keep
+// keys in an encrypted keystore, a hardware module or a remote service.
+type HashSigner interface {
+	// Address returns the G... address of the key producing the signatures.
+	Address() string
+	// SignHash signs the given 32 byte payload hash.
+	SignHash(ctx context.Context, hash [32]byte) (xdr.DecoratedSignature, error)
+}
+
+// PayloadSigner is a HashSigner which can be given the XDR encoded payload whose
+// SHA-256 hash is signed, either a xdr.TransactionSignaturePayload or a
+// xdr.HashIdPreimage of a Soroban authorization entry. Signers which enforce
+// policies on what they sign, like remote signers, implement it so they can
+// inspect the payload. When a HashSigner implements PayloadSigner, SignPayload is
+// called instead of SignHash.
+type PayloadSigner interface {
+	HashSigner
+	SignPayload(ctx context.Context, payload []byte) (xdr.DecoratedSignature, error)
+}
+
+// KeypairSigner is a HashSigner using a keypair held in memory.
+type KeypairSigner struct {
+	*keypair.Full
+}
+
+// SignHash signs the given hash with the keypair.
+func (s KeypairSigner) SignHash(ctx context.Context, hash [32]byte) (xdr.DecoratedSignature, error) {
+	return s.SignDecorated(hash[:])
+}
+
+// signPayload signs payload with signer and verifies the signature returned
+// matches the signer's address.
+func signPayload(ctx context.Context, signer HashSigner, payload []byte) (xdr.DecoratedSignature, error) {
+	kp, err := keypair.ParseAddress(signer.Address())
+	if err != nil {
+		return xdr.DecoratedSignature{}, errors.Wrapf(err, "invalid signer address %s", signer.Address())
+	}
+
+	hash := sha256.Sum256(payload)
+	var sig xdr.DecoratedSignature
+	if payloadSigner, ok := signer.(PayloadSigner); ok {
+		sig, err = payloadSigner.SignPayload(ctx, payload)
+	} else {
+		sig, err = signer.SignHash(ctx, hash)
+	}
+	if err != nil {
+		return xdr.DecoratedSignature{}, errors.Wrapf(err, "signer %s failed to sign", kp.Address())
+	}
+
+	if sig.Hint != xdr.SignatureHint(kp.Hint()) {
+		return xdr.DecoratedSignature{}, errors.Errorf("signature hint does not match signer %s", kp.Address())
+	}
+	if err := kp.Verify(hash[:], sig.Signature); err != nil {
+		return xdr.DecoratedSignature{}, errors.Wrapf(err, "invalid signature from signer %s", kp.Address())
+	}
+	return sig, nil
+}
+
+// transactionSignaturePayload returns the XDR encoded
+// xdr.TransactionSignaturePayload of the transaction in the envelope, whose
+// hash is network.HashTransactionInEnvelope.
+func transactionSignaturePayload(e xdr.TransactionEnvelope, networkStr string) ([]byte, error) {
+	if networkStr == "" {
+		return nil, errors.New("empty network passphrase")
+	}
+
+	payload := xdr.TransactionSignaturePayload{NetworkId: network.ID(networkStr)}
+	switch e.Type {
+	case xdr.EnvelopeTypeEnvelopeTypeTx:
+		payload.TaggedTransaction = xdr.TransactionSignaturePayloadTaggedTransaction{
+			Type: xdr.EnvelopeTypeEnvelopeTypeTx,
+			Tx:   &e.V1.Tx,
+		}
+	case xdr.EnvelopeTypeEnvelopeTypeTxV0:
+		// V0 transactions are signed as the equivalent V1 transaction, see
+		// network.HashTransactionV0.
+		sourceAccount, err := xdr.NewMuxedAccount(xdr.CryptoKeyTypeKeyTypeEd25519, e.V0.Tx.SourceAccountEd25519)
+		if err != nil {
+			return nil, err
+		}
+		payload.TaggedTransaction = xdr.TransactionSignaturePayloadTaggedTransaction{
+			Type: xdr.EnvelopeTypeEnvelopeTypeTx,
+			Tx: &xdr.Transaction{
+				SourceAccount: sourceAccount,
+				Fee:           e.V0.Tx.Fee,
+				Memo:          e.V0.Tx.Memo,
+				Operations:    e.V0.Tx.Operations,
+				SeqNum:        e.V0.Tx.SeqNum,
+				Cond:          xdr.NewPreconditionsWithTimeBounds(e.V0.Tx.TimeBounds),
+			},
+		}
+	case xdr.EnvelopeTypeEnvelopeTypeTxFeeBump:
+		payload.TaggedTransaction = xdr.TransactionSignaturePayloadTaggedTransaction{
+			Type:    xdr.EnvelopeTypeEnvelopeTypeTxFeeBump,
+			FeeBump: &e.FeeBump.Tx,
+		}
+	default:
+		return nil, errors.New("invalid transaction type")
+	}
+
+	return payload.MarshalBinary()
+}
+
+func concatSigners(
+	ctx context.Context,
+	e xdr.TransactionEnvelope,
+	networkStr string,
+	signatures []xdr.DecoratedSignature,
+	signers ...HashSigner,
+) ([]xdr.DecoratedSignature, error) {
+	payload, err := transactionSignaturePayload(e, networkStr)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build transaction signature payload")
+	}
+
+	extended := make(
+		[]xdr.DecoratedSignature,
+		len(signatures),
+		len(signatures)+len(signers),
+	)
+	copy(extended, signatures)
+	for _, signer := range signers {
+		sig, err := signPayload(ctx, signer, payload)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to sign transaction")
+		}
+		extended = append(extended, sig)
+	}
+	return extended, nil
+}
+
+// SignWith returns a new Transaction instance which extends the current instance
+// with additional signatures produced by the given signers.
+func (t *Transaction) SignWith(ctx context.Context, network string, signers ...HashSigner) (*Transaction, error) {
+	extendedSignatures, err := concatSigners(ctx, t.envelope, network, t.Signatures(), signers...)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.clone(extendedSignatures), nil
+}
+
+// SignWith returns a new FeeBumpTransaction instance which extends the current instance
+// with additional signatures produced by the given signers.
+func (t *FeeBumpTransaction) SignWith(ctx context.Context, network string, signers ...HashSigner) (*FeeBumpTransaction, error) {
+	extendedSignatures, err := concatSigners(ctx, t.envelope, network, t.Signatures(), signers...)
+	if err != nil {
+		return nil, err
+	}
+
+	return t.clone(extendedSignatures), nil
+}
+
+// SignAuthEntry signs a Soroban authorization entry with address credentials
+// (as returned by transaction simulation) with the given signers, which must
+// be able to authorize on behalf of the credentials' address, and returns the
+// signed entry. The signatures are valid until (and including) the ledger
+// `validUntilLedger`.
+//
+// Any signature already present in the entry is replaced, so all the signers
+// required to authorize the entry must be given at once.
+func SignAuthEntry(
+	ctx context.Context,
+	networkPassphrase string,
+	entry xdr.SorobanAuthorizationEntry,
+	validUntilLedger uint32,
+	signers ...HashSigner,
+) (xdr.SorobanAuthorizationEntry, error) {
+	if entry.Credentials.Type != xdr.SorobanCredentialsTypeSorobanCredentialsAddress || entry.Credentials.Address == nil {
+		return xdr.SorobanAuthorizationEntry{}, errors.New("authorization entry does not have address credentials")
+	}
+	if networkPassphrase == "" {
+		return xdr.SorobanAuthorizationEntry{}, errors.New("empty network passphrase")
+	}
+	if len(signers) == 0 {
+		return xdr.SorobanAuthorizationEntry{}, errors.New("no signers provided")
+	}
+
+	credentials := *entry.Credentials.Address
+	preimage := xdr.HashIdPreimage{
+		Type: xdr.EnvelopeTypeEnvelopeTypeSorobanAuthorization,
+		SorobanAuthorization: &xdr.HashIdPreimageSorobanAuthorization{
+			NetworkId:                 network.ID(networkPassphrase),
+			Nonce:                     credentials.Nonce,
+			SignatureExpirationLedger: xdr.Uint32(validUntilLedger),
+			Invocation:                entry.RootInvocation,
+		},
+	}
+	payload, err := preimage.MarshalBinary()
+	if err != nil {
+		return xdr.SorobanAuthorizationEntry{}, errors.Wrap(err, "failed to marshal authorization preimage")
+	}
+
+	type accountSignature struct {
+		publicKey []byte
+		signature []byte
+	}
+	accountSignatures := make([]accountSignature, 0, len(signers))
+	for _, signer := range signers {
+		sig, err := signPayload(ctx, signer, payload)
+		if err != nil {
+			return xdr.SorobanAuthorizationEntry{}, errors.Wrap(err, "failed to sign authorization entry")
+		}
+		publicKey, err := strkey.Decode(strkey.VersionByteAccountID, signer.Address())
+		if err != nil {
+			return xdr.SorobanAuthorizationEntry{}, err
+		}
+		accountSignatures = append(accountSignatures, accountSignature{publicKey, sig.Signature})
+	}
+	// The host requires account signatures to be sorted by public key.
+	sort.Slice(accountSignatures, func(i, j int) bool {
+		return bytes.Compare(accountSignatures[i].publicKey, accountSignatures[j].publicKey) < 0
+	})
+
+	publicKeySymbol, signatureSymbol := xdr.ScSymbol("public_key"), xdr.ScSymbol("signature")
+	vec := make(xdr.ScVec, 0, len(accountSignatures))
+	for i, sig := range accountSignatures {
+		if i > 0 && bytes.Equal(sig.publicKey, accountSignatures[i-1].publicKey) {
+			return xdr.SorobanAuthorizationEntry{}, errors.New("duplicate signer")
+		}
+		publicKey, signature := xdr.ScBytes(sig.publicKey), xdr.ScBytes(sig.signature)
+		sigMap := &xdr.ScMap{
+			{
+				Key: xdr.ScVal{Type: xdr.ScValTypeScvSymbol, Sym: &publicKeySymbol},
+				Val: xdr.ScVal{Type: xdr.ScValTypeScvBytes, Bytes: &publicKey},
+			},
+			{
+				Key: xdr.ScVal{Type: xdr.ScValTypeScvSymbol, Sym: &signatureSymbol},
+				Val: xdr.ScVal{Type: xdr.ScValTypeScvBytes, Bytes: &signature},
+			},
+		}
+		vec = append(vec, xdr.ScVal{Type: xdr.ScValTypeScvMap, Map: &sigMap})
+	}
+	vecPtr := &vec
+
+	credentials.SignatureExpirationLedger = xdr.Uint32(validUntilLedger)
+	credentials.Signature = xdr.ScVal{Type: xdr.ScValTypeScvVec, Vec: &vecPtr}
+	entry.Credentials = xdr.SorobanCredentials{
+		Type:    xdr.SorobanCredentialsTypeSorobanCredentialsAddress,
+		Address: &credentials,
+	}
+	return entry, nil
+}
@@ -0,0 +1,208 @@
+package txnbuild
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/network"
+	"github.com/stellar/go/strkey"
+	"github.com/stellar/go/xdr"
+)
+
+// payloadRecorder is a PayloadSigner recording the payloads it signs.
+type payloadRecorder struct {
+	KeypairSigner
+	payloads [][]byte
+}
+
+func (s *payloadRecorder) SignPayload(ctx context.Context, payload []byte) (xdr.DecoratedSignature, error) {
+	s.payloads = append(s.payloads, payload)
+	return s.SignHash(ctx, sha256.Sum256(payload))
+}
+
+// wrongKeySigner claims to sign for one address but uses another key.
+type wrongKeySigner struct {
+	KeypairSigner
+	address string
+}
+
+func (s wrongKeySigner) Address() string {
+	return s.address
+}
+
+func TestSignWith(t *testing.T) {
+	kp0, kp1 := newKeypair0(), newKeypair1()
+	sourceAccount := NewSimpleAccount(kp0.Address(), 1)
+
+	tx, err := NewTransaction(
+		TransactionParams{
+			SourceAccount: &sourceAccount,
+			Operations:    []Operation{&Inflation{}},
+			BaseFee:       MinBaseFee,
+			Preconditions: Preconditions{TimeBounds: NewInfiniteTimeout()},
+		},
+	)
+	require.NoError(t, err)
+	expected, err := tx.Sign(network.TestNetworkPassphrase, kp0, kp1)
+	require.NoError(t, err)
+
+	recorder := &payloadRecorder{KeypairSigner: KeypairSigner{kp1}}
+	signed, err := tx.SignWith(context.Background(), network.TestNetworkPassphrase, KeypairSigner{kp0}, recorder)
+	require.NoError(t, err)
+	assert.Equal(t, expected.Signatures(), signed.Signatures())
+	assert.Empty(t, tx.Signatures())
+
+	require.Len(t, recorder.payloads, 1)
+	hash, err := tx.Hash(network.TestNetworkPassphrase)
+	require.NoError(t, err)
+	assert.Equal(t, hash, sha256.Sum256(recorder.payloads[0]))
+
+	feeBumpTx, err := NewFeeBumpTransaction(
+		FeeBumpTransactionParams{
+			FeeAccount: kp1.Address(),
+			BaseFee:    2 * MinBaseFee,
+			Inner:      signed,
+		},
+	)
+	require.NoError(t, err)
+	expectedFeeBump, err := feeBumpTx.Sign(network.TestNetworkPassphrase, kp1)
+	require.NoError(t, err)
+	signedFeeBump, err := feeBumpTx.SignWith(context.Background(), network.TestNetworkPassphrase, recorder)
+	require.NoError(t, err)
+	assert.Equal(t, expectedFeeBump.Signatures(), signedFeeBump.Signatures())
+	require.Len(t, recorder.payloads, 2)
+	hash, err = feeBumpTx.Hash(network.TestNetworkPassphrase)
+	require.NoError(t, err)
+	assert.Equal(t, hash, sha256.Sum256(recorder.payloads[1]))
+
+	_, err = tx.SignWith(context.Background(), network.TestNetworkPassphrase, wrongKeySigner{KeypairSigner{kp0}, kp1.Address()})
+	assert.EqualError(t, err, "failed to sign transaction: signature hint does not match signer "+kp1.Address())
+
+	_, err = tx.SignWith(context.Background(), "", KeypairSigner{kp0})
+	assert.EqualError(t, err, "failed to build transaction signature payload: empty network passphrase")
+}
+
+func TestSignWithV0Transaction(t *testing.T) {
+	kp0 := newKeypair0()
+	sourceAccount := NewSimpleAccount(kp0.Address(), 1)
+
+	tx, err := NewTransaction(
+		TransactionParams{
+			SourceAccount: &sourceAccount,
+			Operations:    []Operation{&Inflation{}},
+			BaseFee:       MinBaseFee,
+			Preconditions: Preconditions{TimeBounds: NewInfiniteTimeout()},
+		},
+	)
+	require.NoError(t, err)
+
+	v1 := tx.ToXDR()
+	v0 := xdr.TransactionEnvelope{
+		Type: xdr.EnvelopeTypeEnvelopeTypeTxV0,
+		V0: &xdr.TransactionV0Envelope{
+			Tx: xdr.TransactionV0{
+				SourceAccountEd25519: *v1.V1.Tx.SourceAccount.Ed25519,
+				Fee:                  v1.V1.Tx.Fee,
+				SeqNum:               v1.V1.Tx.SeqNum,
+				TimeBounds:           v1.V1.Tx.Cond.TimeBounds,
+				Memo:                 v1.V1.Tx.Memo,
+				Operations:           v1.V1.Tx.Operations,
+			},
+		},
+	}
+	payload, err := transactionSignaturePayload(v0, network.TestNetworkPassphrase)
+	require.NoError(t, err)
+	hash, err := network.HashTransactionInEnvelope(v0, network.TestNetworkPassphrase)
+	require.NoError(t, err)
+	assert.Equal(t, hash, sha256.Sum256(payload))
+}
+
+func TestSignAuthEntry(t *testing.T) {
+	kp0, kp1 := newKeypair0(), newKeypair1()
+	accountID := xdr.MustAddress(kp0.Address())
+	contractID := xdr.Hash{1}
+	entry := xdr.SorobanAuthorizationEntry{
+		Credentials: xdr.SorobanCredentials{
+			Type: xdr.SorobanCredentialsTypeSorobanCredentialsAddress,
+			Address: &xdr.SorobanAddressCredentials{
+				Address: xdr.ScAddress{
+					Type:      xdr.ScAddressTypeScAddressTypeAccount,
+					AccountId: &accountID,
+				},
+				Nonce:     42,
+				Signature: xdr.ScVal{Type: xdr.ScValTypeScvVoid},
+			},
+		},
+		RootInvocation: xdr.SorobanAuthorizedInvocation{
+			Function: xdr.SorobanAuthorizedFunction{
+				Type: xdr.SorobanAuthorizedFunctionTypeSorobanAuthorizedFunctionTypeContractFn,
+				ContractFn: &xdr.InvokeContractArgs{
+					ContractAddress: xdr.ScAddress{
+						Type:       xdr.ScAddressTypeScAddressTypeContract,
+						ContractId: (*xdr.ContractId)(&contractID),
+					},
+					FunctionName: "transfer",
+				},
+			},
+		},
+	}
+
+	recorder := &payloadRecorder{KeypairSigner: KeypairSigner{kp1}}
+	signed, err := SignAuthEntry(context.Background(), network.TestNetworkPassphrase, entry, 1000, KeypairSigner{kp0}, recorder)
+	require.NoError(t, err)
+	assert.Equal(t, xdr.ScValTypeScvVoid, entry.Credentials.Address.Signature.Type)
+	assert.Equal(t, entry.RootInvocation, signed.RootInvocation)
+	credentials := signed.Credentials.Address
+	assert.Equal(t, xdr.Uint32(1000), credentials.SignatureExpirationLedger)
+	assert.Equal(t, xdr.Int64(42), credentials.Nonce)
+
+	require.Len(t, recorder.payloads, 1)
+	var preimage xdr.HashIdPreimage
+	require.NoError(t, preimage.UnmarshalBinary(recorder.payloads[0]))
+	assert.Equal(t, xdr.HashIdPreimageSorobanAuthorization{
+		NetworkId:                 network.ID(network.TestNetworkPassphrase),
+		Nonce:                     42,
+		SignatureExpirationLedger: 1000,
+		Invocation:                entry.RootInvocation,
+	}, *preimage.SorobanAuthorization)
+	hash := sha256.Sum256(recorder.payloads[0])
+
+	vec, ok := credentials.Signature.GetVec()
+	require.True(t, ok)
+	require.NotNil(t, vec)
+	require.Len(t, *vec, 2)
+	var previous []byte
+	for _, sig := range *vec {
+		sigMap, ok := sig.GetMap()
+		require.True(t, ok)
+		require.Len(t, *sigMap, 2)
+		assert.Equal(t, xdr.ScSymbol("public_key"), *(*sigMap)[0].Key.Sym)
+		assert.Equal(t, xdr.ScSymbol("signature"), *(*sigMap)[1].Key.Sym)
+		publicKey := []byte(*(*sigMap)[0].Val.Bytes)
+		signature := []byte(*(*sigMap)[1].Val.Bytes)
+		assert.Positive(t, bytes.Compare(publicKey, previous))
+		previous = publicKey
+
+		address, err := strkey.Encode(strkey.VersionByteAccountID, publicKey)
+		require.NoError(t, err)
+		if address == kp0.Address() {
+			assert.NoError(t, kp0.Verify(hash[:], signature))
+		} else {
+			assert.Equal(t, kp1.Address(), address)
+			assert.NoError(t, kp1.Verify(hash[:], signature))
+		}
+	}
+
+	_, err = SignAuthEntry(context.Background(), network.TestNetworkPassphrase, entry, 1000, KeypairSigner{kp0}, KeypairSigner{kp0})
+	assert.EqualError(t, err, "duplicate signer")
+
+	sourceEntry := entry
+	sourceEntry.Credentials = xdr.SorobanCredentials{Type: xdr.SorobanCredentialsTypeSorobanCredentialsSourceAccount}
+	_, err = SignAuthEntry(context.Background(), network.TestNetworkPassphrase, sourceEntry, 1000, KeypairSigner{kp0})
+	assert.EqualError(t, err, "authorization entry does not have address credentials")
+}
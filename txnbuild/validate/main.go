@@ -0,0 +1,387 @@
+// Package validate predicts the outcome of classic transactions before they
+// are submitted. A Validator applies the semantics of classic operations to
+// the account, trust line, offer, data and claimable balance entries of a
+// StateSource, such as a Snapshot built from a checkpoint or an RPC server,
+// and returns the transaction and operation result codes stellar-core would
+// be expected to produce, with an explanation of each failure.
+//
+// Validation is a prediction, not a guarantee: the ledger may change before
+// the transaction is applied, offers are assumed not to cross the order book,
+// path payment conversions and Soroban operations are not simulated, and
+// reserves are computed without taking sponsorships into account. Operation
+// results which depend on such state are marked as partial.
+package validate
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+)
+
+const (
+	// DefaultBaseFee is the base fee in stroops used when LedgerInfo does not
+	// specify one.
+	DefaultBaseFee = 100
+	// DefaultBaseReserve is the base reserve in stroops used when LedgerInfo
+	// does not specify one.
+	DefaultBaseReserve = 5000000
+)
+
+// StateSource provides the ledger entries transactions are validated
+// against.
+type StateSource interface {
+	// GetLedgerEntries returns the entries of the given keys which exist.
+	// Keys of missing entries are skipped.
+	GetLedgerEntries(ctx context.Context, keys ...xdr.LedgerKey) ([]xdr.LedgerEntry, error)
+}
+
+// LedgerInfo describes the ledger in which the transaction is expected to be
+// applied.
+type LedgerInfo struct {
+	// Sequence is the sequence number of the ledger. It is used to check
+	// ledger bounds and the sequence numbers of new accounts.
+	Sequence uint32
+	// CloseTime is the close time of the ledger. The current time is used if
+	// it is zero.
+	CloseTime time.Time
+	// BaseFee is the base fee in stroops. DefaultBaseFee is used if it is
+	// zero.
+	BaseFee uint32
+	// BaseReserve is the base reserve in stroops. DefaultBaseReserve is used
+	// if it is zero.
+	BaseReserve uint32
+}
+
+// LedgerInfoFromHeader returns the LedgerInfo of the ledger following the
+// given ledger header.
+func LedgerInfoFromHeader(header xdr.LedgerHeader) LedgerInfo {
+	return LedgerInfo{
+		Sequence:    uint32(header.LedgerSeq) + 1,
+		CloseTime:   time.Unix(int64(header.ScpValue.CloseTime), 0).UTC(),
+		BaseFee:     uint32(header.BaseFee),
+		BaseReserve: uint32(header.BaseReserve),
+	}
+}
+
+// Validator validates transactions against the entries of a StateSource.
+type Validator struct {
+	Source StateSource
+	Ledger LedgerInfo
+}
+
+// OperationResult is the predicted result of an operation.
+type OperationResult struct {
+	// Result is the predicted result of the operation.
+	Result xdr.OperationResult
+	// Explanation describes why the operation is expected to fail or, for
+	// partial results, what was not checked.
+	Explanation string
+	// Partial is set if the result depends on state which is not simulated,
+	// for instance the order book. A successful partial result only means
+	// that none of the checks the validator can perform failed.
+	Partial bool
+}
+
+// Successful returns true if the operation is expected to succeed.
+func (r OperationResult) Successful() bool {
+	if r.Result.Code != xdr.OperationResultCodeOpInner || r.Result.Tr == nil {
+		return false
+	}
+	// Every operation result is a union on a result code which is zero on
+	// success.
+	arm, ok := r.Result.Tr.ArmForSwitch(int32(r.Result.Tr.Type))
+	if !ok {
+		return false
+	}
+	result := reflect.ValueOf(*r.Result.Tr).FieldByName(arm)
+	return !result.IsNil() && result.Elem().FieldByName("Code").Int() == 0
+}
+
+// Result is the predicted result of a transaction.
+type Result struct {
+	// Code is the predicted result code of the transaction.
+	Code xdr.TransactionResultCode
+	// Explanation describes why the transaction is expected to fail.
+	Explanation string
+	// FeeCharged is the fee expected to be charged, assuming no surge
+	// pricing. It is zero if the transaction is expected to be rejected.
+	FeeCharged int64
+	// Operations holds the predicted results of the operations. It is empty
+	// if the transaction is expected to be rejected before the operations
+	// are applied.
+	Operations []OperationResult
+}
+
+// Successful returns true if the transaction is expected to succeed.
+func (r Result) Successful() bool {
+	return r.Code == xdr.TransactionResultCodeTxSuccess
+}
+
+// Partial returns true if the result of any operation is partial.
+func (r Result) Partial() bool {
+	for _, op := range r.Operations {
+		if op.Partial {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *Validator) ledger() LedgerInfo {
+	ledger := v.Ledger
+	if ledger.CloseTime.IsZero() {
+		ledger.CloseTime = time.Now()
+	}
+	if ledger.BaseFee == 0 {
+		ledger.BaseFee = DefaultBaseFee
+	}
+	if ledger.BaseReserve == 0 {
+		ledger.BaseReserve = DefaultBaseReserve
+	}
+	return ledger
+}
+
+// Validate predicts the result of applying tx. Signatures are not checked,
+// see txnbuild.Transaction.EvaluateThresholds for that. An error is only
+// returned if the entries cannot be fetched from the StateSource.
+func (v *Validator) Validate(ctx context.Context, tx *txnbuild.Transaction) (Result, error) {
+	envelope := tx.ToXDR()
+	validation := &validation{
+		ledger:   v.ledger(),
+		state:    newState(ctx, v.Source),
+		envelope: envelope,
+		source:   envelope.SourceAccount().ToAccountId(),
+	}
+	if err := validation.state.prefetch(prefetchKeys(validation.source, envelope.Operations())); err != nil {
+		return Result{}, err
+	}
+	return validation.run()
+}
+
+// validation holds the state of a single transaction validation.
+type validation struct {
+	ledger   LedgerInfo
+	state    *state
+	envelope xdr.TransactionEnvelope
+	source   xdr.AccountId
+}
+
+func (v *validation) baseReserve() int64 {
+	return int64(v.ledger.BaseReserve)
+}
+
+func (v *validation) run() (Result, error) {
+	code, explanation, err := v.checkTransaction()
+	if err != nil || code != xdr.TransactionResultCodeTxSuccess {
+		return Result{Code: code, Explanation: explanation}, err
+	}
+
+	// The fee is charged and the sequence number consumed even if the
+	// operations fail.
+	source, err := v.state.account(v.source)
+	if err != nil {
+		return Result{}, err
+	}
+	fee := int64(v.envelope.Fee())
+	if minFee := int64(v.ledger.BaseFee) * int64(len(v.envelope.Operations())); minFee < fee {
+		fee = minFee
+	}
+	source.Balance -= xdr.Int64(fee)
+	source.SeqNum = xdr.SequenceNumber(v.envelope.SeqNum())
+
+	result := Result{
+		Code:       xdr.TransactionResultCodeTxSuccess,
+		FeeCharged: fee,
+	}
+	// stellar-core applies each operation in a nested ledger transaction
+	// which is only committed while all the operations succeed, so every
+	// operation is applied to a copy of the state which is discarded from
+	// the first failure on.
+	committed := v.state
+	for i, op := range v.envelope.Operations() {
+		opState, err := committed.clone()
+		if err != nil {
+			return Result{}, errors.Wrapf(err, "operation %d", i)
+		}
+		v.state = opState
+		opResult, err := v.applyOperation(i, op)
+		if err != nil {
+			return Result{}, errors.Wrapf(err, "operation %d", i)
+		}
+		if opResult.Successful() && result.Code == xdr.TransactionResultCodeTxSuccess {
+			committed = opState
+		}
+		if !opResult.Successful() && result.Code == xdr.TransactionResultCodeTxSuccess {
+			result.Code = xdr.TransactionResultCodeTxFailed
+			result.Explanation = fmt.Sprintf("operation %d failed: %s", i, opResult.Explanation)
+		}
+		result.Operations = append(result.Operations, opResult)
+	}
+	v.state = committed
+	return result, nil
+}
+
+// checkTransaction performs the checks stellar-core performs before applying
+// the operations of a transaction.
+func (v *validation) checkTransaction() (xdr.TransactionResultCode, string, error) {
+	closeTime := uint64(v.ledger.CloseTime.Unix())
+	if timeBounds := v.envelope.TimeBounds(); timeBounds != nil {
+		if uint64(timeBounds.MinTime) > closeTime {
+			return xdr.TransactionResultCodeTxTooEarly, "the minimum time bound is after the ledger close time", nil
+		}
+		if timeBounds.MaxTime != 0 && uint64(timeBounds.MaxTime) < closeTime {
+			return xdr.TransactionResultCodeTxTooLate, "the maximum time bound is before the ledger close time", nil
+		}
+	}
+	if ledgerBounds := v.envelope.LedgerBounds(); ledgerBounds != nil && v.ledger.Sequence != 0 {
+		if uint32(ledgerBounds.MinLedger) > v.ledger.Sequence {
+			return xdr.TransactionResultCodeTxTooEarly, "the minimum ledger bound is after the ledger", nil
+		}
+		if ledgerBounds.MaxLedger != 0 && uint32(ledgerBounds.MaxLedger) <= v.ledger.Sequence {
+			return xdr.TransactionResultCodeTxTooLate, "the maximum ledger bound is not after the ledger", nil
+		}
+	}
+
+	operations := v.envelope.Operations()
+	if len(operations) == 0 {
+		return xdr.TransactionResultCodeTxMissingOperation, "the transaction has no operations", nil
+	}
+	if minFee := int64(v.ledger.BaseFee) * int64(len(operations)); int64(v.envelope.Fee()) < minFee {
+		return xdr.TransactionResultCodeTxInsufficientFee,
+			fmt.Sprintf("the fee %d is below the minimum fee %d", v.envelope.Fee(), minFee), nil
+	}
+
+	source, err := v.state.account(v.source)
+	if err != nil {
+		return 0, "", err
+	}
+	if source == nil {
+		return xdr.TransactionResultCodeTxNoAccount, "the source account does not exist", nil
+	}
+
+	seqNum := v.envelope.SeqNum()
+	if minSeqNum := v.envelope.MinSeqNum(); minSeqNum != nil {
+		if int64(source.SeqNum) < *minSeqNum || int64(source.SeqNum) >= seqNum {
+			return xdr.TransactionResultCodeTxBadSeq, fmt.Sprintf(
+				"the source account sequence number %d is not in the range [%d, %d)",
+				source.SeqNum, *minSeqNum, seqNum,
+			), nil
+		}
+	} else if int64(source.SeqNum)+1 != seqNum {
+		return xdr.TransactionResultCodeTxBadSeq, fmt.Sprintf(
+			"the sequence number %d is not the successor of the source account sequence number %d",
+			seqNum, source.SeqNum,
+		), nil
+	}
+	if minSeqAge := v.envelope.MinSeqAge(); minSeqAge != nil && *minSeqAge > 0 {
+		if closeTime < uint64(source.SeqTime())+uint64(*minSeqAge) {
+			return xdr.TransactionResultCodeTxBadMinSeqAgeOrGap, "the minimum sequence age has not been reached", nil
+		}
+	}
+	if minSeqLedgerGap := v.envelope.MinSeqLedgerGap(); minSeqLedgerGap != nil && *minSeqLedgerGap > 0 {
+		if v.ledger.Sequence < uint32(source.SeqLedger())+uint32(*minSeqLedgerGap) {
+			return xdr.TransactionResultCodeTxBadMinSeqAgeOrGap, "the minimum sequence ledger gap has not been reached", nil
+		}
+	}
+
+	if available := v.availableNative(source); available < int64(v.envelope.Fee()) {
+		return xdr.TransactionResultCodeTxInsufficientBalance, fmt.Sprintf(
+			"the available balance %d of the source account does not cover the fee %d",
+			available, v.envelope.Fee(),
+		), nil
+	}
+	return xdr.TransactionResultCodeTxSuccess, "", nil
+}
+
+func (v *validation) applyOperation(index int, op xdr.Operation) (OperationResult, error) {
+	source := v.source
+	if op.SourceAccount != nil {
+		source = op.SourceAccount.ToAccountId()
+	}
+	account, err := v.state.account(source)
+	if err != nil {
+		return OperationResult{}, err
+	}
+	if account == nil {
+		return OperationResult{
+			Result:      xdr.OperationResult{Code: xdr.OperationResultCodeOpNoAccount},
+			Explanation: fmt.Sprintf("source account %s does not exist", source.Address()),
+		}, nil
+	}
+
+	o := &operation{validation: v, index: index, source: source, account: account}
+	var out outcome
+	switch op.Body.Type {
+	case xdr.OperationTypeCreateAccount:
+		out, err = o.createAccount(op.Body.MustCreateAccountOp())
+	case xdr.OperationTypePayment:
+		out, err = o.payment(op.Body.MustPaymentOp())
+	case xdr.OperationTypePathPaymentStrictReceive:
+		out, err = o.pathPaymentStrictReceive(op.Body.MustPathPaymentStrictReceiveOp())
+	case xdr.OperationTypePathPaymentStrictSend:
+		out, err = o.pathPaymentStrictSend(op.Body.MustPathPaymentStrictSendOp())
+	case xdr.OperationTypeManageSellOffer:
+		sellOffer := op.Body.MustManageSellOfferOp()
+		out, err = o.manageOffer(offerParams{
+			selling: sellOffer.Selling, buying: sellOffer.Buying,
+			amount: int64(sellOffer.Amount), price: sellOffer.Price, offerID: int64(sellOffer.OfferId),
+		})
+	case xdr.OperationTypeManageBuyOffer:
+		buyOffer := op.Body.MustManageBuyOfferOp()
+		out, err = o.manageOffer(offerParams{
+			selling: buyOffer.Selling, buying: buyOffer.Buying,
+			amount: int64(buyOffer.BuyAmount), price: buyOffer.Price, offerID: int64(buyOffer.OfferId), buy: true,
+		})
+	case xdr.OperationTypeCreatePassiveSellOffer:
+		passiveOffer := op.Body.MustCreatePassiveSellOfferOp()
+		out, err = o.manageOffer(offerParams{
+			selling: passiveOffer.Selling, buying: passiveOffer.Buying,
+			amount: int64(passiveOffer.Amount), price: passiveOffer.Price, passive: true,
+		})
+	case xdr.OperationTypeSetOptions:
+		out, err = o.setOptions(op.Body.MustSetOptionsOp())
+	case xdr.OperationTypeChangeTrust:
+		out, err = o.changeTrust(op.Body.MustChangeTrustOp())
+	case xdr.OperationTypeAllowTrust:
+		out, err = o.allowTrust(op.Body.MustAllowTrustOp())
+	case xdr.OperationTypeSetTrustLineFlags:
+		out, err = o.setTrustLineFlags(op.Body.MustSetTrustLineFlagsOp())
+	case xdr.OperationTypeAccountMerge:
+		out, err = o.accountMerge(op.Body.MustDestination())
+	case xdr.OperationTypeInflation:
+		return OperationResult{
+			Result:      xdr.OperationResult{Code: xdr.OperationResultCodeOpNotSupported},
+			Explanation: "inflation is no longer supported",
+		}, nil
+	case xdr.OperationTypeManageData:
+		out, err = o.manageData(op.Body.MustManageDataOp())
+	case xdr.OperationTypeBumpSequence:
+		out, err = o.bumpSequence(op.Body.MustBumpSequenceOp())
+	case xdr.OperationTypeCreateClaimableBalance:
+		out, err = o.createClaimableBalance(op.Body.MustCreateClaimableBalanceOp())
+	case xdr.OperationTypeClaimClaimableBalance:
+		out, err = o.claimClaimableBalance(op.Body.MustClaimClaimableBalanceOp())
+	case xdr.OperationTypeClawback:
+		out, err = o.clawback(op.Body.MustClawbackOp())
+	default:
+		out, err = notSimulated(op.Body.Type)
+	}
+	if err != nil {
+		return OperationResult{}, err
+	}
+
+	tr, err := xdr.NewOperationResultTr(op.Body.Type, out.result)
+	if err != nil {
+		return OperationResult{}, errors.Wrap(err, "could not build operation result")
+	}
+	return OperationResult{
+		Result:      xdr.OperationResult{Code: xdr.OperationResultCodeOpInner, Tr: &tr},
+		Explanation: out.explanation,
+		Partial:     out.partial,
+	}, nil
+}
@@ -0,0 +1,1271 @@
+package validate
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/stellar/go/amount"
+	"github.com/stellar/go/protocols/horizon/operations"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// maxSigners is the maximum number of signers of an account.
+const maxSigners = 20
+
+// outcome is the result of applying an operation. result holds the
+// operation specific result, e.g. an xdr.PaymentResult.
+type outcome struct {
+	result      interface{}
+	explanation string
+	partial     bool
+}
+
+func success(result interface{}) (outcome, error) {
+	return outcome{result: result}, nil
+}
+
+func failure(result interface{}, format string, args ...interface{}) (outcome, error) {
+	return outcome{result: result, explanation: fmt.Sprintf(format, args...)}, nil
+}
+
+// operation holds the state of an operation being applied.
+type operation struct {
+	*validation
+	index   int
+	source  xdr.AccountId
+	account *xdr.AccountEntry
+}
+
+func formatAmount(value int64) string {
+	return amount.StringFromInt64(value)
+}
+
+func notSimulated(opType xdr.OperationType) (outcome, error) {
+	out := outcome{
+		explanation: fmt.Sprintf("%s operations are not simulated", operations.TypeNames[opType]),
+		partial:     true,
+	}
+	switch opType {
+	case xdr.OperationTypeBeginSponsoringFutureReserves:
+		out.result = xdr.BeginSponsoringFutureReservesResult{}
+	case xdr.OperationTypeEndSponsoringFutureReserves:
+		out.result = xdr.EndSponsoringFutureReservesResult{}
+	case xdr.OperationTypeRevokeSponsorship:
+		out.result = xdr.RevokeSponsorshipResult{}
+	case xdr.OperationTypeClawbackClaimableBalance:
+		out.result = xdr.ClawbackClaimableBalanceResult{}
+	case xdr.OperationTypeLiquidityPoolDeposit:
+		out.result = xdr.LiquidityPoolDepositResult{}
+	case xdr.OperationTypeLiquidityPoolWithdraw:
+		out.result = xdr.LiquidityPoolWithdrawResult{}
+	case xdr.OperationTypeInvokeHostFunction:
+		out.result = xdr.InvokeHostFunctionResult{Success: &xdr.Hash{}}
+		out.explanation += ", use the simulateTransaction method of RPC instead"
+	case xdr.OperationTypeExtendFootprintTtl:
+		out.result = xdr.ExtendFootprintTtlResult{}
+		out.explanation += ", use the simulateTransaction method of RPC instead"
+	case xdr.OperationTypeRestoreFootprint:
+		out.result = xdr.RestoreFootprintResult{}
+		out.explanation += ", use the simulateTransaction method of RPC instead"
+	default:
+		return outcome{}, errors.Errorf("unknown operation type %d", opType)
+	}
+	return out, nil
+}
+
+func (o *operation) createAccount(op xdr.CreateAccountOp) (outcome, error) {
+	result := func(code xdr.CreateAccountResultCode) xdr.CreateAccountResult {
+		return xdr.CreateAccountResult{Code: code}
+	}
+	if op.StartingBalance < 0 {
+		return failure(result(xdr.CreateAccountResultCodeCreateAccountMalformed), "the starting balance is negative")
+	}
+	if op.Destination.Equals(o.source) {
+		return failure(result(xdr.CreateAccountResultCodeCreateAccountMalformed), "the destination is the source account")
+	}
+
+	destination, err := o.state.account(op.Destination)
+	if err != nil {
+		return outcome{}, err
+	}
+	if destination != nil {
+		return failure(result(xdr.CreateAccountResultCodeCreateAccountAlreadyExist),
+			"account %s already exists", op.Destination.Address())
+	}
+	if minBalance := 2 * o.baseReserve(); int64(op.StartingBalance) < minBalance {
+		return failure(result(xdr.CreateAccountResultCodeCreateAccountLowReserve),
+			"the starting balance %s is below the minimum balance %s",
+			formatAmount(int64(op.StartingBalance)), formatAmount(minBalance))
+	}
+	if available := o.availableNative(o.account); available < int64(op.StartingBalance) {
+		return failure(result(xdr.CreateAccountResultCodeCreateAccountUnderfunded),
+			"the available balance %s does not cover the starting balance %s",
+			formatAmount(available), formatAmount(int64(op.StartingBalance)))
+	}
+
+	o.account.Balance -= op.StartingBalance
+	err = o.state.put(xdr.LedgerEntry{
+		LastModifiedLedgerSeq: xdr.Uint32(o.ledger.Sequence),
+		Data: xdr.LedgerEntryData{
+			Type: xdr.LedgerEntryTypeAccount,
+			Account: &xdr.AccountEntry{
+				AccountId:  op.Destination,
+				Balance:    op.StartingBalance,
+				SeqNum:     xdr.SequenceNumber(int64(o.ledger.Sequence) << 32),
+				Thresholds: xdr.Thresholds{1, 0, 0, 0},
+			},
+		},
+	})
+	if err != nil {
+		return outcome{}, err
+	}
+	return success(result(xdr.CreateAccountResultCodeCreateAccountSuccess))
+}
+
+// transferCode is the reason a transfer between two accounts failed. Each
+// payment operation maps it to its own result codes.
+type transferCode int
+
+const (
+	transferSuccess transferCode = iota
+	transferNoDestination
+	transferNoTrust
+	transferNotAuthorized
+	transferLineFull
+	transferSrcNoTrust
+	transferSrcNotAuthorized
+	transferUnderfunded
+)
+
+// transfer credits destAmount of destAsset to the destination and debits
+// sendAmount of sendAsset from the source. A sendAmount of zero means the
+// amount sent is unknown, in which case only a positive available balance
+// is required and nothing is debited. Either both sides are applied or none.
+func (o *operation) transfer(
+	destination xdr.AccountId,
+	sendAsset xdr.Asset,
+	sendAmount int64,
+	destAsset xdr.Asset,
+	destAmount int64,
+) (transferCode, string, error) {
+	var receiver *holding
+	destinationAccount, err := o.state.account(destination)
+	if err != nil {
+		return 0, "", err
+	}
+	// Assets can be sent back to an issuer which no longer exists.
+	burn := !destAsset.IsNative() && destAsset.GetIssuer() == destination.Address()
+	if destinationAccount == nil && !burn {
+		return transferNoDestination, fmt.Sprintf("destination account %s does not exist", destination.Address()), nil
+	}
+	if destinationAccount != nil {
+		receiver, err = o.holding(destination, destAsset)
+		if err != nil {
+			return 0, "", err
+		}
+		if receiver == nil {
+			return transferNoTrust, fmt.Sprintf(
+				"destination account %s does not trust %s", destination.Address(), destAsset.StringCanonical(),
+			), nil
+		}
+		if !receiver.authorized() {
+			return transferNotAuthorized, fmt.Sprintf(
+				"destination account %s is not authorized to hold %s", destination.Address(), destAsset.StringCanonical(),
+			), nil
+		}
+		if capacity := receiver.capacity(); capacity < destAmount {
+			return transferLineFull, fmt.Sprintf(
+				"destination account %s can only receive %s %s", destination.Address(),
+				formatAmount(capacity), destAsset.StringCanonical(),
+			), nil
+		}
+	}
+
+	sender, err := o.holding(o.source, sendAsset)
+	if err != nil {
+		return 0, "", err
+	}
+	if sender == nil {
+		return transferSrcNoTrust, fmt.Sprintf(
+			"source account %s does not trust %s", o.source.Address(), sendAsset.StringCanonical(),
+		), nil
+	}
+	if !sender.authorized() {
+		return transferSrcNotAuthorized, fmt.Sprintf(
+			"source account %s is not authorized to hold %s", o.source.Address(), sendAsset.StringCanonical(),
+		), nil
+	}
+	available := o.available(sender)
+	if available < sendAmount || available <= 0 {
+		return transferUnderfunded, fmt.Sprintf(
+			"the available balance of the source account is %s %s", formatAmount(available), sendAsset.StringCanonical(),
+		), nil
+	}
+
+	if receiver != nil {
+		receiver.add(destAmount)
+	}
+	sender.add(-sendAmount)
+	return transferSuccess, "", nil
+}
+
+func (o *operation) payment(op xdr.PaymentOp) (outcome, error) {
+	if op.Amount <= 0 {
+		return failure(xdr.PaymentResult{Code: xdr.PaymentResultCodePaymentMalformed}, "the amount must be positive")
+	}
+	destination := op.Destination.ToAccountId()
+	// Sending lumens to oneself always succeeds.
+	if destination.Equals(o.source) && op.Asset.IsNative() {
+		return success(xdr.PaymentResult{Code: xdr.PaymentResultCodePaymentSuccess})
+	}
+
+	code, explanation, err := o.transfer(destination, op.Asset, int64(op.Amount), op.Asset, int64(op.Amount))
+	if err != nil {
+		return outcome{}, err
+	}
+	return outcome{
+		result: xdr.PaymentResult{Code: map[transferCode]xdr.PaymentResultCode{
+			transferSuccess:          xdr.PaymentResultCodePaymentSuccess,
+			transferNoDestination:    xdr.PaymentResultCodePaymentNoDestination,
+			transferNoTrust:          xdr.PaymentResultCodePaymentNoTrust,
+			transferNotAuthorized:    xdr.PaymentResultCodePaymentNotAuthorized,
+			transferLineFull:         xdr.PaymentResultCodePaymentLineFull,
+			transferSrcNoTrust:       xdr.PaymentResultCodePaymentSrcNoTrust,
+			transferSrcNotAuthorized: xdr.PaymentResultCodePaymentSrcNotAuthorized,
+			transferUnderfunded:      xdr.PaymentResultCodePaymentUnderfunded,
+		}[code]},
+		explanation: explanation,
+	}, nil
+}
+
+func conversionExplanation(sendAsset, destAsset xdr.Asset) string {
+	return fmt.Sprintf(
+		"the conversion of %s to %s through the order book and liquidity pools is not simulated",
+		sendAsset.StringCanonical(), destAsset.StringCanonical(),
+	)
+}
+
+func (o *operation) pathPaymentStrictReceive(op xdr.PathPaymentStrictReceiveOp) (outcome, error) {
+	result := func(code xdr.PathPaymentStrictReceiveResultCode) xdr.PathPaymentStrictReceiveResult {
+		return xdr.PathPaymentStrictReceiveResult{Code: code}
+	}
+	if op.SendMax <= 0 || op.DestAmount <= 0 {
+		return failure(result(xdr.PathPaymentStrictReceiveResultCodePathPaymentStrictReceiveMalformed),
+			"the send maximum and the destination amount must be positive")
+	}
+	direct := len(op.Path) == 0 && op.SendAsset.Equals(op.DestAsset)
+	if direct && op.DestAmount > op.SendMax {
+		return failure(result(xdr.PathPaymentStrictReceiveResultCodePathPaymentStrictReceiveOverSendmax),
+			"the destination amount exceeds the send maximum")
+	}
+	var sendAmount int64
+	if direct {
+		sendAmount = int64(op.DestAmount)
+	}
+
+	destination := op.Destination.ToAccountId()
+	code, explanation, err := o.transfer(destination, op.SendAsset, sendAmount, op.DestAsset, int64(op.DestAmount))
+	if err != nil {
+		return outcome{}, err
+	}
+	out := outcome{
+		result: result(map[transferCode]xdr.PathPaymentStrictReceiveResultCode{
+			transferSuccess:          xdr.PathPaymentStrictReceiveResultCodePathPaymentStrictReceiveSuccess,
+			transferNoDestination:    xdr.PathPaymentStrictReceiveResultCodePathPaymentStrictReceiveNoDestination,
+			transferNoTrust:          xdr.PathPaymentStrictReceiveResultCodePathPaymentStrictReceiveNoTrust,
+			transferNotAuthorized:    xdr.PathPaymentStrictReceiveResultCodePathPaymentStrictReceiveNotAuthorized,
+			transferLineFull:         xdr.PathPaymentStrictReceiveResultCodePathPaymentStrictReceiveLineFull,
+			transferSrcNoTrust:       xdr.PathPaymentStrictReceiveResultCodePathPaymentStrictReceiveSrcNoTrust,
+			transferSrcNotAuthorized: xdr.PathPaymentStrictReceiveResultCodePathPaymentStrictReceiveSrcNotAuthorized,
+			transferUnderfunded:      xdr.PathPaymentStrictReceiveResultCodePathPaymentStrictReceiveUnderfunded,
+		}[code]),
+		explanation: explanation,
+	}
+	if code == transferSuccess {
+		r := out.result.(xdr.PathPaymentStrictReceiveResult)
+		r.Success = &xdr.PathPaymentStrictReceiveResultSuccess{
+			Last: xdr.SimplePaymentResult{Destination: destination, Asset: op.DestAsset, Amount: op.DestAmount},
+		}
+		out.result = r
+		if !direct {
+			out.partial = true
+			out.explanation = conversionExplanation(op.SendAsset, op.DestAsset) +
+				", the amount sent is not deducted from the source account"
+		}
+	}
+	return out, nil
+}
+
+func (o *operation) pathPaymentStrictSend(op xdr.PathPaymentStrictSendOp) (outcome, error) {
+	result := func(code xdr.PathPaymentStrictSendResultCode) xdr.PathPaymentStrictSendResult {
+		return xdr.PathPaymentStrictSendResult{Code: code}
+	}
+	if op.SendAmount <= 0 || op.DestMin <= 0 {
+		return failure(result(xdr.PathPaymentStrictSendResultCodePathPaymentStrictSendMalformed),
+			"the send amount and the destination minimum must be positive")
+	}
+	direct := len(op.Path) == 0 && op.SendAsset.Equals(op.DestAsset)
+	if direct && op.SendAmount < op.DestMin {
+		return failure(result(xdr.PathPaymentStrictSendResultCodePathPaymentStrictSendUnderDestmin),
+			"the send amount is below the destination minimum")
+	}
+	destAmount := op.DestMin
+	if direct {
+		destAmount = op.SendAmount
+	}
+
+	destination := op.Destination.ToAccountId()
+	code, explanation, err := o.transfer(destination, op.SendAsset, int64(op.SendAmount), op.DestAsset, int64(destAmount))
+	if err != nil {
+		return outcome{}, err
+	}
+	out := outcome{
+		result: result(map[transferCode]xdr.PathPaymentStrictSendResultCode{
+			transferSuccess:          xdr.PathPaymentStrictSendResultCodePathPaymentStrictSendSuccess,
+			transferNoDestination:    xdr.PathPaymentStrictSendResultCodePathPaymentStrictSendNoDestination,
+			transferNoTrust:          xdr.PathPaymentStrictSendResultCodePathPaymentStrictSendNoTrust,
+			transferNotAuthorized:    xdr.PathPaymentStrictSendResultCodePathPaymentStrictSendNotAuthorized,
+			transferLineFull:         xdr.PathPaymentStrictSendResultCodePathPaymentStrictSendLineFull,
+			transferSrcNoTrust:       xdr.PathPaymentStrictSendResultCodePathPaymentStrictSendSrcNoTrust,
+			transferSrcNotAuthorized: xdr.PathPaymentStrictSendResultCodePathPaymentStrictSendSrcNotAuthorized,
+			transferUnderfunded:      xdr.PathPaymentStrictSendResultCodePathPaymentStrictSendUnderfunded,
+		}[code]),
+		explanation: explanation,
+	}
+	if code == transferSuccess {
+		r := out.result.(xdr.PathPaymentStrictSendResult)
+		r.Success = &xdr.PathPaymentStrictSendResultSuccess{
+			Last: xdr.SimplePaymentResult{Destination: destination, Asset: op.DestAsset, Amount: destAmount},
+		}
+		out.result = r
+		if !direct {
+			out.partial = true
+			out.explanation = conversionExplanation(op.SendAsset, op.DestAsset) +
+				", the destination is assumed to receive the destination minimum"
+		}
+	}
+	return out, nil
+}
+
+// offerParams are the parameters shared by the offer operations.
+type offerParams struct {
+	selling xdr.Asset
+	buying  xdr.Asset
+	// amount is the amount sold, or bought if buy is set.
+	amount int64
+	// price is the price of selling in terms of buying, or of buying in
+	// terms of selling if buy is set.
+	price   xdr.Price
+	offerID int64
+	buy     bool
+	passive bool
+}
+
+// offerLiabilities returns the selling and buying liabilities of an offer
+// selling the given amount at the given price. ok is false if the buying
+// liabilities overflow.
+func offerLiabilities(selling int64, price xdr.Price) (int64, int64, bool) {
+	buying := new(big.Int).Mul(big.NewInt(selling), big.NewInt(int64(price.N)))
+	buying.Add(buying, big.NewInt(int64(price.D)-1))
+	buying.Quo(buying, big.NewInt(int64(price.D)))
+	if !buying.IsInt64() {
+		return 0, 0, false
+	}
+	return selling, buying.Int64(), true
+}
+
+func addLiabilities(h *holding, buying, selling int64) {
+	liabilities := h.liabilities()
+	liabilities.Buying += xdr.Int64(buying)
+	liabilities.Selling += xdr.Int64(selling)
+	h.setLiabilities(liabilities)
+}
+
+func (o *operation) manageOffer(p offerParams) (outcome, error) {
+	result := func(code xdr.ManageSellOfferResultCode, success *xdr.ManageOfferSuccessResult) interface{} {
+		if p.buy {
+			// Both result codes share the same values.
+			return xdr.ManageBuyOfferResult{Code: xdr.ManageBuyOfferResultCode(code), Success: success}
+		}
+		return xdr.ManageSellOfferResult{Code: code, Success: success}
+	}
+	fail := func(code xdr.ManageSellOfferResultCode, format string, args ...interface{}) (outcome, error) {
+		return failure(result(code, nil), format, args...)
+	}
+
+	if p.selling.Equals(p.buying) {
+		return fail(xdr.ManageSellOfferResultCodeManageSellOfferMalformed, "the selling and buying assets are the same")
+	}
+	if p.amount < 0 || p.price.N <= 0 || p.price.D <= 0 {
+		return fail(xdr.ManageSellOfferResultCodeManageSellOfferMalformed, "the amount is negative or the price is not positive")
+	}
+	if p.amount == 0 && p.offerID == 0 {
+		return fail(xdr.ManageSellOfferResultCodeManageSellOfferMalformed, "a new offer must have a positive amount")
+	}
+
+	var existing *xdr.OfferEntry
+	if p.offerID != 0 {
+		entry, err := o.state.get(offerKey(o.source, p.offerID))
+		if err != nil {
+			return outcome{}, err
+		}
+		if entry == nil {
+			return fail(xdr.ManageSellOfferResultCodeManageSellOfferNotFound,
+				"offer %d of %s does not exist", p.offerID, o.source.Address())
+		}
+		existing = entry.Data.Offer
+	}
+
+	// release removes the liabilities of the existing offer.
+	release := func() error {
+		if existing == nil {
+			return nil
+		}
+		selling, buying, _ := offerLiabilities(int64(existing.Amount), existing.Price)
+		for _, side := range []struct {
+			asset xdr.Asset
+			delta func(h *holding)
+		}{
+			{existing.Selling, func(h *holding) { addLiabilities(h, 0, -selling) }},
+			{existing.Buying, func(h *holding) { addLiabilities(h, -buying, 0) }},
+		} {
+			h, err := o.holding(o.source, side.asset)
+			if err != nil {
+				return err
+			}
+			if h != nil {
+				side.delta(h)
+			}
+		}
+		return nil
+	}
+
+	if p.amount == 0 {
+		if err := release(); err != nil {
+			return outcome{}, err
+		}
+		if err := o.state.remove(offerKey(o.source, p.offerID)); err != nil {
+			return outcome{}, err
+		}
+		o.account.NumSubEntries--
+		return success(result(xdr.ManageSellOfferResultCodeManageSellOfferSuccess, &xdr.ManageOfferSuccessResult{
+			Offer: xdr.ManageOfferSuccessResultOffer{Effect: xdr.ManageOfferEffectManageOfferDeleted},
+		}))
+	}
+
+	selling, err := o.holding(o.source, p.selling)
+	if err != nil {
+		return outcome{}, err
+	}
+	if selling == nil {
+		return fail(xdr.ManageSellOfferResultCodeManageSellOfferSellNoTrust,
+			"source account does not trust %s", p.selling.StringCanonical())
+	}
+	if !selling.authorized() {
+		return fail(xdr.ManageSellOfferResultCodeManageSellOfferSellNotAuthorized,
+			"source account is not authorized to sell %s", p.selling.StringCanonical())
+	}
+	buying, err := o.holding(o.source, p.buying)
+	if err != nil {
+		return outcome{}, err
+	}
+	if buying == nil {
+		return fail(xdr.ManageSellOfferResultCodeManageSellOfferBuyNoTrust,
+			"source account does not trust %s", p.buying.StringCanonical())
+	}
+	if !buying.authorized() {
+		return fail(xdr.ManageSellOfferResultCodeManageSellOfferBuyNotAuthorized,
+			"source account is not authorized to buy %s", p.buying.StringCanonical())
+	}
+
+	// Offers are stored as sell offers.
+	sellAmount, price := p.amount, p.price
+	if p.buy {
+		price = xdr.Price{N: p.price.D, D: p.price.N}
+		var ok bool
+		if _, sellAmount, ok = offerLiabilities(p.amount, p.price); !ok {
+			return fail(xdr.ManageSellOfferResultCodeManageSellOfferMalformed, "the offer amount overflows")
+		}
+	}
+	sellingLiabilities, buyingLiabilities, ok := offerLiabilities(sellAmount, price)
+	if !ok {
+		return fail(xdr.ManageSellOfferResultCodeManageSellOfferLineFull, "the offer buying amount overflows")
+	}
+
+	available, capacity := o.available(selling), buying.capacity()
+	if existing != nil {
+		oldSelling, oldBuying, _ := offerLiabilities(int64(existing.Amount), existing.Price)
+		if existing.Selling.Equals(p.selling) {
+			available += oldSelling
+		}
+		if existing.Buying.Equals(p.buying) {
+			capacity += oldBuying
+		}
+	}
+	if available < sellingLiabilities {
+		return fail(xdr.ManageSellOfferResultCodeManageSellOfferUnderfunded,
+			"the available balance %s %s does not cover the offer amount %s",
+			formatAmount(available), p.selling.StringCanonical(), formatAmount(sellingLiabilities))
+	}
+	if capacity < buyingLiabilities {
+		return fail(xdr.ManageSellOfferResultCodeManageSellOfferLineFull,
+			"source account can only receive %s %s but the offer buys %s",
+			formatAmount(capacity), p.buying.StringCanonical(), formatAmount(buyingLiabilities))
+	}
+	if existing == nil && !o.canAddSubEntries(o.account, 1) {
+		return fail(xdr.ManageSellOfferResultCodeManageSellOfferLowReserve,
+			"source account cannot afford the reserve of a new offer")
+	}
+
+	if err := release(); err != nil {
+		return outcome{}, err
+	}
+	addLiabilities(selling, 0, sellingLiabilities)
+	addLiabilities(buying, buyingLiabilities, 0)
+	offer := xdr.OfferEntry{
+		SellerId: o.source,
+		OfferId:  xdr.Int64(p.offerID),
+		Selling:  p.selling,
+		Buying:   p.buying,
+		Amount:   xdr.Int64(sellAmount),
+		Price:    price,
+	}
+	effect := xdr.ManageOfferEffectManageOfferUpdated
+	if existing == nil {
+		effect = xdr.ManageOfferEffectManageOfferCreated
+		o.account.NumSubEntries++
+	} else {
+		offer.Flags = existing.Flags
+	}
+	if p.passive {
+		offer.Flags |= xdr.Uint32(xdr.OfferEntryFlagsPassiveFlag)
+	}
+	// New offers get their ID when they are applied, they are only kept to
+	// account for their liabilities and reserve.
+	err = o.state.put(xdr.LedgerEntry{
+		LastModifiedLedgerSeq: xdr.Uint32(o.ledger.Sequence),
+		Data:                  xdr.LedgerEntryData{Type: xdr.LedgerEntryTypeOffer, Offer: &offer},
+	})
+	if err != nil {
+		return outcome{}, err
+	}
+
+	out, err := success(result(xdr.ManageSellOfferResultCodeManageSellOfferSuccess, &xdr.ManageOfferSuccessResult{
+		Offer: xdr.ManageOfferSuccessResultOffer{Effect: effect, Offer: &offer},
+	}))
+	out.partial = true
+	out.explanation = "crossing the order book is not simulated, the offer is assumed not to cross any other offer"
+	return out, err
+}
+
+func (o *operation) setOptions(op xdr.SetOptionsOp) (outcome, error) {
+	fail := func(code xdr.SetOptionsResultCode, format string, args ...interface{}) (outcome, error) {
+		return failure(xdr.SetOptionsResult{Code: code}, format, args...)
+	}
+	const knownFlags = xdr.Uint32(xdr.AccountFlagsAuthRequiredFlag | xdr.AccountFlagsAuthRevocableFlag |
+		xdr.AccountFlagsAuthImmutableFlag | xdr.AccountFlagsAuthClawbackEnabledFlag)
+
+	var setFlags, clearFlags xdr.Uint32
+	if op.SetFlags != nil {
+		setFlags = *op.SetFlags
+	}
+	if op.ClearFlags != nil {
+		clearFlags = *op.ClearFlags
+	}
+	if setFlags&clearFlags != 0 {
+		return fail(xdr.SetOptionsResultCodeSetOptionsBadFlags, "the same flags are set and cleared")
+	}
+	if (setFlags|clearFlags)&^knownFlags != 0 {
+		return fail(xdr.SetOptionsResultCodeSetOptionsUnknownFlag, "unknown flags")
+	}
+	for _, threshold := range []*xdr.Uint32{op.MasterWeight, op.LowThreshold, op.MedThreshold, op.HighThreshold} {
+		if threshold != nil && *threshold > math.MaxUint8 {
+			return fail(xdr.SetOptionsResultCodeSetOptionsThresholdOutOfRange, "thresholds and weights must be at most 255")
+		}
+	}
+	if op.Signer != nil {
+		if op.Signer.Key.Address() == o.source.Address() {
+			return fail(xdr.SetOptionsResultCodeSetOptionsBadSigner, "the master key cannot be added as a signer")
+		}
+		if op.Signer.Weight > math.MaxUint8 {
+			return fail(xdr.SetOptionsResultCodeSetOptionsBadSigner, "signer weights must be at most 255")
+		}
+	}
+
+	if op.InflationDest != nil {
+		inflationDest, err := o.state.account(*op.InflationDest)
+		if err != nil {
+			return outcome{}, err
+		}
+		if inflationDest == nil {
+			return fail(xdr.SetOptionsResultCodeSetOptionsInvalidInflation,
+				"inflation destination %s does not exist", op.InflationDest.Address())
+		}
+	}
+	flags := xdr.AccountFlags(o.account.Flags)
+	if setFlags|clearFlags != 0 && flags.IsAuthImmutable() {
+		return fail(xdr.SetOptionsResultCodeSetOptionsCantChange, "the flags of an immutable account cannot be changed")
+	}
+	flags = xdr.AccountFlags((xdr.Uint32(flags) &^ clearFlags) | setFlags)
+	if flags.IsAuthClawbackEnabled() && !flags.IsAuthRevocable() {
+		return fail(xdr.SetOptionsResultCodeSetOptionsAuthRevocableRequired, "clawback requires the revocable flag")
+	}
+
+	signerIndex := -1
+	if op.Signer != nil {
+		for i, signer := range o.account.Signers {
+			if signer.Key.Equals(op.Signer.Key) {
+				signerIndex = i
+				break
+			}
+		}
+		if signerIndex < 0 && op.Signer.Weight > 0 {
+			if len(o.account.Signers) >= maxSigners {
+				return fail(xdr.SetOptionsResultCodeSetOptionsTooManySigners,
+					"accounts can have at most %d signers", maxSigners)
+			}
+			if !o.canAddSubEntries(o.account, 1) {
+				return fail(xdr.SetOptionsResultCodeSetOptionsLowReserve,
+					"source account cannot afford the reserve of a new signer")
+			}
+		}
+	}
+
+	o.account.Flags = xdr.Uint32(flags)
+	if op.InflationDest != nil {
+		inflationDest := *op.InflationDest
+		o.account.InflationDest = &inflationDest
+	}
+	for i, threshold := range []*xdr.Uint32{op.MasterWeight, op.LowThreshold, op.MedThreshold, op.HighThreshold} {
+		if threshold != nil {
+			o.account.Thresholds[i] = byte(*threshold)
+		}
+	}
+	if op.HomeDomain != nil {
+		o.account.HomeDomain = *op.HomeDomain
+	}
+	if op.Signer != nil {
+		var sponsorships *[]xdr.SponsorshipDescriptor
+		if o.account.Ext.V1 != nil && o.account.Ext.V1.Ext.V2 != nil {
+			sponsorships = &o.account.Ext.V1.Ext.V2.SignerSponsoringIDs
+		}
+		switch {
+		case signerIndex >= 0 && op.Signer.Weight == 0:
+			o.account.Signers = append(o.account.Signers[:signerIndex], o.account.Signers[signerIndex+1:]...)
+			if sponsorships != nil {
+				*sponsorships = append((*sponsorships)[:signerIndex], (*sponsorships)[signerIndex+1:]...)
+			}
+			o.account.NumSubEntries--
+		case signerIndex >= 0:
+			o.account.Signers[signerIndex].Weight = op.Signer.Weight
+		case op.Signer.Weight > 0:
+			o.account.Signers = append(o.account.Signers, *op.Signer)
+			if sponsorships != nil {
+				*sponsorships = append(*sponsorships, nil)
+			}
+			o.account.NumSubEntries++
+		}
+	}
+	return success(xdr.SetOptionsResult{Code: xdr.SetOptionsResultCodeSetOptionsSuccess})
+}
+
+func (o *operation) changeTrust(op xdr.ChangeTrustOp) (outcome, error) {
+	fail := func(code xdr.ChangeTrustResultCode, format string, args ...interface{}) (outcome, error) {
+		return failure(xdr.ChangeTrustResult{Code: code}, format, args...)
+	}
+	if op.Line.Type == xdr.AssetTypeAssetTypePoolShare {
+		return outcome{
+			result:      xdr.ChangeTrustResult{Code: xdr.ChangeTrustResultCodeChangeTrustSuccess},
+			explanation: "liquidity pool share trust lines are not simulated",
+			partial:     true,
+		}, nil
+	}
+	asset, ok := changeTrustAsset(op.Line)
+	if !ok {
+		return fail(xdr.ChangeTrustResultCodeChangeTrustMalformed, "lumens do not need a trust line")
+	}
+	if op.Limit < 0 {
+		return fail(xdr.ChangeTrustResultCodeChangeTrustMalformed, "the limit is negative")
+	}
+	if asset.GetIssuer() == o.source.Address() {
+		return fail(xdr.ChangeTrustResultCodeChangeTrustMalformed, "the issuer of an asset cannot trust it")
+	}
+
+	trustLine, err := o.state.trustLine(o.source, asset)
+	if err != nil {
+		return outcome{}, err
+	}
+	if trustLine != nil {
+		if op.Limit == 0 {
+			if trustLine.Balance != 0 || trustLine.Liabilities().Buying != 0 {
+				return fail(xdr.ChangeTrustResultCodeChangeTrustInvalidLimit,
+					"the trust line of %s has a balance of %s and cannot be removed",
+					asset.StringCanonical(), formatAmount(int64(trustLine.Balance)))
+			}
+			if err := o.state.remove(trustLineKey(o.source, asset.ToTrustLineAsset())); err != nil {
+				return outcome{}, err
+			}
+			o.account.NumSubEntries--
+			return success(xdr.ChangeTrustResult{Code: xdr.ChangeTrustResultCodeChangeTrustSuccess})
+		}
+		if minLimit := int64(trustLine.Balance) + int64(trustLine.Liabilities().Buying); int64(op.Limit) < minLimit {
+			return fail(xdr.ChangeTrustResultCodeChangeTrustInvalidLimit,
+				"the limit is below the balance and buying liabilities %s", formatAmount(minLimit))
+		}
+		trustLine.Limit = op.Limit
+		return success(xdr.ChangeTrustResult{Code: xdr.ChangeTrustResultCodeChangeTrustSuccess})
+	}
+
+	if op.Limit == 0 {
+		return fail(xdr.ChangeTrustResultCodeChangeTrustInvalidLimit,
+			"source account has no trust line of %s to remove", asset.StringCanonical())
+	}
+	issuerID, err := asset.GetIssuerAccountId()
+	if err != nil {
+		return outcome{}, errors.Wrap(err, "invalid issuer")
+	}
+	issuer, err := o.state.account(issuerID)
+	if err != nil {
+		return outcome{}, err
+	}
+	if issuer == nil {
+		return fail(xdr.ChangeTrustResultCodeChangeTrustNoIssuer, "issuer %s does not exist", issuerID.Address())
+	}
+	if !o.canAddSubEntries(o.account, 1) {
+		return fail(xdr.ChangeTrustResultCodeChangeTrustLowReserve,
+			"source account cannot afford the reserve of a new trust line")
+	}
+
+	var flags xdr.TrustLineFlags
+	issuerFlags := xdr.AccountFlags(issuer.Flags)
+	if !issuerFlags.IsAuthRequired() {
+		flags |= xdr.TrustLineFlagsAuthorizedFlag
+	}
+	if issuerFlags.IsAuthClawbackEnabled() {
+		flags |= xdr.TrustLineFlagsTrustlineClawbackEnabledFlag
+	}
+	err = o.state.put(xdr.LedgerEntry{
+		LastModifiedLedgerSeq: xdr.Uint32(o.ledger.Sequence),
+		Data: xdr.LedgerEntryData{
+			Type: xdr.LedgerEntryTypeTrustline,
+			TrustLine: &xdr.TrustLineEntry{
+				AccountId: o.source,
+				Asset:     asset.ToTrustLineAsset(),
+				Limit:     op.Limit,
+				Flags:     xdr.Uint32(flags),
+			},
+		},
+	})
+	if err != nil {
+		return outcome{}, err
+	}
+	o.account.NumSubEntries++
+	return success(xdr.ChangeTrustResult{Code: xdr.ChangeTrustResultCodeChangeTrustSuccess})
+}
+
+// authorizationLevel orders trust line authorization flags from not
+// authorized to fully authorized.
+func authorizationLevel(flags xdr.TrustLineFlags) int {
+	switch {
+	case flags.IsAuthorized():
+		return 2
+	case flags.IsAuthorizedToMaintainLiabilitiesFlag():
+		return 1
+	default:
+		return 0
+	}
+}
+
+// updateTrustLineFlags loads the trust line of the trustor and returns its
+// updated flags, without applying them. revoke is set if the update reduces
+// the authorization of the trust line. The trust line is nil if it does not
+// exist.
+func (o *operation) updateTrustLineFlags(
+	trustor xdr.AccountId,
+	asset xdr.Asset,
+	update func(xdr.TrustLineFlags) xdr.TrustLineFlags,
+) (trustLine *xdr.TrustLineEntry, flags xdr.TrustLineFlags, revoke bool, err error) {
+	trustLine, err = o.state.trustLine(trustor, asset)
+	if err != nil || trustLine == nil {
+		return nil, 0, false, err
+	}
+	flags = update(xdr.TrustLineFlags(trustLine.Flags))
+	revoke = authorizationLevel(flags) < authorizationLevel(xdr.TrustLineFlags(trustLine.Flags))
+	return trustLine, flags, revoke, nil
+}
+
+func (o *operation) allowTrust(op xdr.AllowTrustOp) (outcome, error) {
+	fail := func(code xdr.AllowTrustResultCode, format string, args ...interface{}) (outcome, error) {
+		return failure(xdr.AllowTrustResult{Code: code}, format, args...)
+	}
+	const authorizationFlags = xdr.TrustLineFlagsAuthorizedFlag | xdr.TrustLineFlagsAuthorizedToMaintainLiabilitiesFlag
+	authorize := xdr.TrustLineFlags(op.Authorize)
+	if authorize&^authorizationFlags != 0 || authorize == authorizationFlags {
+		return fail(xdr.AllowTrustResultCodeAllowTrustMalformed, "invalid authorization flags")
+	}
+	if op.Trustor.Equals(o.source) {
+		return fail(xdr.AllowTrustResultCodeAllowTrustMalformed, "the trustor is the source account")
+	}
+
+	asset := xdr.Asset{Type: op.Asset.Type}
+	switch op.Asset.Type {
+	case xdr.AssetTypeAssetTypeCreditAlphanum4:
+		asset.AlphaNum4 = &xdr.AlphaNum4{AssetCode: *op.Asset.AssetCode4, Issuer: o.source}
+	case xdr.AssetTypeAssetTypeCreditAlphanum12:
+		asset.AlphaNum12 = &xdr.AlphaNum12{AssetCode: *op.Asset.AssetCode12, Issuer: o.source}
+	default:
+		return fail(xdr.AllowTrustResultCodeAllowTrustMalformed, "invalid asset code")
+	}
+
+	trustLine, flags, revoke, err := o.updateTrustLineFlags(op.Trustor, asset,
+		func(flags xdr.TrustLineFlags) xdr.TrustLineFlags {
+			return flags&^authorizationFlags | authorize
+		})
+	if err != nil {
+		return outcome{}, err
+	}
+	if trustLine == nil {
+		return fail(xdr.AllowTrustResultCodeAllowTrustNoTrustLine,
+			"account %s does not trust %s", op.Trustor.Address(), asset.StringCanonical())
+	}
+	if revoke && !xdr.AccountFlags(o.account.Flags).IsAuthRevocable() {
+		return fail(xdr.AllowTrustResultCodeAllowTrustCantRevoke, "the issuer is not revocable")
+	}
+
+	trustLine.Flags = xdr.Uint32(flags)
+	out, err := success(xdr.AllowTrustResult{Code: xdr.AllowTrustResultCodeAllowTrustSuccess})
+	if authorizationLevel(flags) == 0 && revoke {
+		out.partial = true
+		out.explanation = "the removal of the offers of the trustor is not simulated"
+	}
+	return out, err
+}
+
+func (o *operation) setTrustLineFlags(op xdr.SetTrustLineFlagsOp) (outcome, error) {
+	fail := func(code xdr.SetTrustLineFlagsResultCode, format string, args ...interface{}) (outcome, error) {
+		return failure(xdr.SetTrustLineFlagsResult{Code: code}, format, args...)
+	}
+	const knownFlags = xdr.Uint32(xdr.TrustLineFlagsAuthorizedFlag | xdr.TrustLineFlagsAuthorizedToMaintainLiabilitiesFlag |
+		xdr.TrustLineFlagsTrustlineClawbackEnabledFlag)
+	if op.Asset.IsNative() || op.Asset.GetIssuer() != o.source.Address() {
+		return fail(xdr.SetTrustLineFlagsResultCodeSetTrustLineFlagsMalformed, "the source account is not the issuer")
+	}
+	if op.Trustor.Equals(o.source) {
+		return fail(xdr.SetTrustLineFlagsResultCodeSetTrustLineFlagsMalformed, "the trustor is the source account")
+	}
+	if op.SetFlags&op.ClearFlags != 0 || (op.SetFlags|op.ClearFlags)&^knownFlags != 0 ||
+		xdr.TrustLineFlags(op.SetFlags).IsClawbackEnabledFlag() {
+		return fail(xdr.SetTrustLineFlagsResultCodeSetTrustLineFlagsMalformed, "invalid flags")
+	}
+
+	trustLine, flags, revoke, err := o.updateTrustLineFlags(op.Trustor, op.Asset,
+		func(flags xdr.TrustLineFlags) xdr.TrustLineFlags {
+			return xdr.TrustLineFlags(xdr.Uint32(flags)&^op.ClearFlags | op.SetFlags)
+		})
+	if err != nil {
+		return outcome{}, err
+	}
+	if trustLine == nil {
+		return fail(xdr.SetTrustLineFlagsResultCodeSetTrustLineFlagsNoTrustLine,
+			"account %s does not trust %s", op.Trustor.Address(), op.Asset.StringCanonical())
+	}
+	if flags.IsAuthorized() && flags.IsAuthorizedToMaintainLiabilitiesFlag() {
+		return fail(xdr.SetTrustLineFlagsResultCodeSetTrustLineFlagsInvalidState,
+			"a trust line cannot be both authorized and authorized to maintain liabilities")
+	}
+	if revoke && !xdr.AccountFlags(o.account.Flags).IsAuthRevocable() {
+		return fail(xdr.SetTrustLineFlagsResultCodeSetTrustLineFlagsCantRevoke, "the issuer is not revocable")
+	}
+
+	trustLine.Flags = xdr.Uint32(flags)
+	out, err := success(xdr.SetTrustLineFlagsResult{Code: xdr.SetTrustLineFlagsResultCodeSetTrustLineFlagsSuccess})
+	if authorizationLevel(flags) == 0 && revoke {
+		out.partial = true
+		out.explanation = "the removal of the offers of the trustor is not simulated"
+	}
+	return out, err
+}
+
+func (o *operation) accountMerge(destination xdr.MuxedAccount) (outcome, error) {
+	fail := func(code xdr.AccountMergeResultCode, format string, args ...interface{}) (outcome, error) {
+		return failure(xdr.AccountMergeResult{Code: code}, format, args...)
+	}
+	destinationID := destination.ToAccountId()
+	if destinationID.Equals(o.source) {
+		return fail(xdr.AccountMergeResultCodeAccountMergeMalformed, "an account cannot be merged into itself")
+	}
+	destinationAccount, err := o.state.account(destinationID)
+	if err != nil {
+		return outcome{}, err
+	}
+	if destinationAccount == nil {
+		return fail(xdr.AccountMergeResultCodeAccountMergeNoAccount,
+			"destination account %s does not exist", destinationID.Address())
+	}
+	if xdr.AccountFlags(o.account.Flags).IsAuthImmutable() {
+		return fail(xdr.AccountMergeResultCodeAccountMergeImmutableSet, "the source account is immutable")
+	}
+	if int(o.account.NumSubEntries) != len(o.account.Signers) {
+		return fail(xdr.AccountMergeResultCodeAccountMergeHasSubEntries,
+			"the source account has %d subentries besides its signers", int(o.account.NumSubEntries)-len(o.account.Signers))
+	}
+	if o.ledger.Sequence != 0 && int64(o.account.SeqNum) >= int64(o.ledger.Sequence)<<32 {
+		return fail(xdr.AccountMergeResultCodeAccountMergeSeqnumTooFar,
+			"the sequence number of the source account is too far in the future")
+	}
+	if o.account.NumSponsoring() > 0 {
+		return fail(xdr.AccountMergeResultCodeAccountMergeIsSponsor, "the source account sponsors other entries")
+	}
+	balance := o.account.Balance
+	if capacity := (&holding{account: destinationAccount}).capacity(); capacity < int64(balance) {
+		return fail(xdr.AccountMergeResultCodeAccountMergeDestFull,
+			"destination account can only receive %s lumens", formatAmount(capacity))
+	}
+
+	destinationAccount.Balance += balance
+	if err := o.state.remove(accountKey(o.source)); err != nil {
+		return outcome{}, err
+	}
+	return success(xdr.AccountMergeResult{
+		Code:                 xdr.AccountMergeResultCodeAccountMergeSuccess,
+		SourceAccountBalance: &balance,
+	})
+}
+
+func validDataName(name xdr.String64) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r < 0x20 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func (o *operation) manageData(op xdr.ManageDataOp) (outcome, error) {
+	fail := func(code xdr.ManageDataResultCode, format string, args ...interface{}) (outcome, error) {
+		return failure(xdr.ManageDataResult{Code: code}, format, args...)
+	}
+	if !validDataName(op.DataName) {
+		return fail(xdr.ManageDataResultCodeManageDataInvalidName, "invalid data name %q", op.DataName)
+	}
+	key := dataKey(o.source, op.DataName)
+	existing, err := o.state.get(key)
+	if err != nil {
+		return outcome{}, err
+	}
+
+	switch {
+	case op.DataValue == nil && existing == nil:
+		return fail(xdr.ManageDataResultCodeManageDataNameNotFound, "data entry %q does not exist", op.DataName)
+	case op.DataValue == nil:
+		if err := o.state.remove(key); err != nil {
+			return outcome{}, err
+		}
+		o.account.NumSubEntries--
+	case existing == nil:
+		if !o.canAddSubEntries(o.account, 1) {
+			return fail(xdr.ManageDataResultCodeManageDataLowReserve,
+				"source account cannot afford the reserve of a new data entry")
+		}
+		err := o.state.put(xdr.LedgerEntry{
+			LastModifiedLedgerSeq: xdr.Uint32(o.ledger.Sequence),
+			Data: xdr.LedgerEntryData{
+				Type: xdr.LedgerEntryTypeData,
+				Data: &xdr.DataEntry{AccountId: o.source, DataName: op.DataName, DataValue: *op.DataValue},
+			},
+		})
+		if err != nil {
+			return outcome{}, err
+		}
+		o.account.NumSubEntries++
+	default:
+		existing.Data.Data.DataValue = *op.DataValue
+	}
+	return success(xdr.ManageDataResult{Code: xdr.ManageDataResultCodeManageDataSuccess})
+}
+
+func (o *operation) bumpSequence(op xdr.BumpSequenceOp) (outcome, error) {
+	if op.BumpTo < 0 {
+		return failure(xdr.BumpSequenceResult{Code: xdr.BumpSequenceResultCodeBumpSequenceBadSeq},
+			"the sequence number is negative")
+	}
+	if op.BumpTo > o.account.SeqNum {
+		o.account.SeqNum = op.BumpTo
+	}
+	return success(xdr.BumpSequenceResult{Code: xdr.BumpSequenceResultCodeBumpSequenceSuccess})
+}
+
+// absolutePredicate converts the relative times of a predicate to absolute
+// times, as done when claimable balances are created.
+func absolutePredicate(predicate xdr.ClaimPredicate, closeTime int64) xdr.ClaimPredicate {
+	switch predicate.Type {
+	case xdr.ClaimPredicateTypeClaimPredicateAnd, xdr.ClaimPredicateTypeClaimPredicateOr:
+		predicates := predicate.AndPredicates
+		if predicate.Type == xdr.ClaimPredicateTypeClaimPredicateOr {
+			predicates = predicate.OrPredicates
+		}
+		converted := make([]xdr.ClaimPredicate, len(*predicates))
+		for i, p := range *predicates {
+			converted[i] = absolutePredicate(p, closeTime)
+		}
+		if predicate.Type == xdr.ClaimPredicateTypeClaimPredicateOr {
+			return xdr.ClaimPredicate{Type: predicate.Type, OrPredicates: &converted}
+		}
+		return xdr.ClaimPredicate{Type: predicate.Type, AndPredicates: &converted}
+	case xdr.ClaimPredicateTypeClaimPredicateNot:
+		if predicate.NotPredicate == nil || *predicate.NotPredicate == nil {
+			return predicate
+		}
+		converted := absolutePredicate(**predicate.NotPredicate, closeTime)
+		convertedPtr := &converted
+		return xdr.ClaimPredicate{Type: predicate.Type, NotPredicate: &convertedPtr}
+	case xdr.ClaimPredicateTypeClaimPredicateBeforeRelativeTime:
+		before := xdr.Int64(math.MaxInt64)
+		if int64(*predicate.RelBefore) <= math.MaxInt64-closeTime {
+			before = xdr.Int64(closeTime) + *predicate.RelBefore
+		}
+		return xdr.ClaimPredicate{Type: xdr.ClaimPredicateTypeClaimPredicateBeforeAbsoluteTime, AbsBefore: &before}
+	default:
+		return predicate
+	}
+}
+
+// predicateSatisfied evaluates a predicate with absolute times.
+func predicateSatisfied(predicate xdr.ClaimPredicate, closeTime int64) bool {
+	switch predicate.Type {
+	case xdr.ClaimPredicateTypeClaimPredicateUnconditional:
+		return true
+	case xdr.ClaimPredicateTypeClaimPredicateAnd:
+		for _, p := range *predicate.AndPredicates {
+			if !predicateSatisfied(p, closeTime) {
+				return false
+			}
+		}
+		return true
+	case xdr.ClaimPredicateTypeClaimPredicateOr:
+		for _, p := range *predicate.OrPredicates {
+			if predicateSatisfied(p, closeTime) {
+				return true
+			}
+		}
+		return false
+	case xdr.ClaimPredicateTypeClaimPredicateNot:
+		return predicate.NotPredicate != nil && *predicate.NotPredicate != nil &&
+			!predicateSatisfied(**predicate.NotPredicate, closeTime)
+	case xdr.ClaimPredicateTypeClaimPredicateBeforeAbsoluteTime:
+		return closeTime < int64(*predicate.AbsBefore)
+	default:
+		return false
+	}
+}
+
+// claimableBalanceID returns the ID of the claimable balance created by the
+// operation.
+func (o *operation) claimableBalanceID() (xdr.ClaimableBalanceId, error) {
+	preimage := xdr.HashIdPreimage{
+		Type: xdr.EnvelopeTypeEnvelopeTypeOpId,
+		OperationId: &xdr.HashIdPreimageOperationId{
+			SourceAccount: o.validation.source,
+			SeqNum:        xdr.SequenceNumber(o.envelope.SeqNum()),
+			OpNum:         xdr.Uint32(o.index),
+		},
+	}
+	raw, err := preimage.MarshalBinary()
+	if err != nil {
+		return xdr.ClaimableBalanceId{}, errors.Wrap(err, "could not encode operation id")
+	}
+	hash := xdr.Hash(sha256.Sum256(raw))
+	return xdr.ClaimableBalanceId{Type: xdr.ClaimableBalanceIdTypeClaimableBalanceIdTypeV0, V0: &hash}, nil
+}
+
+func (o *operation) createClaimableBalance(op xdr.CreateClaimableBalanceOp) (outcome, error) {
+	fail := func(code xdr.CreateClaimableBalanceResultCode, format string, args ...interface{}) (outcome, error) {
+		return failure(xdr.CreateClaimableBalanceResult{Code: code}, format, args...)
+	}
+	if op.Amount <= 0 {
+		return fail(xdr.CreateClaimableBalanceResultCodeCreateClaimableBalanceMalformed, "the amount must be positive")
+	}
+	if len(op.Claimants) == 0 {
+		return fail(xdr.CreateClaimableBalanceResultCodeCreateClaimableBalanceMalformed, "there are no claimants")
+	}
+	destinations := map[string]bool{}
+	for _, claimant := range op.Claimants {
+		destination := claimant.MustV0().Destination.Address()
+		if destinations[destination] {
+			return fail(xdr.CreateClaimableBalanceResultCodeCreateClaimableBalanceMalformed,
+				"claimant %s is listed twice", destination)
+		}
+		destinations[destination] = true
+	}
+
+	sender, err := o.holding(o.source, op.Asset)
+	if err != nil {
+		return outcome{}, err
+	}
+	if sender == nil {
+		return fail(xdr.CreateClaimableBalanceResultCodeCreateClaimableBalanceNoTrust,
+			"source account does not trust %s", op.Asset.StringCanonical())
+	}
+	if !sender.authorized() {
+		return fail(xdr.CreateClaimableBalanceResultCodeCreateClaimableBalanceNotAuthorized,
+			"source account is not authorized to hold %s", op.Asset.StringCanonical())
+	}
+	if available := o.available(sender); available < int64(op.Amount) {
+		return fail(xdr.CreateClaimableBalanceResultCodeCreateClaimableBalanceUnderfunded,
+			"the available balance %s %s does not cover the amount %s",
+			formatAmount(available), op.Asset.StringCanonical(), formatAmount(int64(op.Amount)))
+	}
+	reserve := int64(len(op.Claimants)) * o.baseReserve()
+	available := o.availableNative(o.account)
+	if op.Asset.IsNative() {
+		available -= int64(op.Amount)
+	}
+	if available < reserve {
+		return fail(xdr.CreateClaimableBalanceResultCodeCreateClaimableBalanceLowReserve,
+			"source account cannot afford the reserve %s of the claimable balance", formatAmount(reserve))
+	}
+
+	id, err := o.claimableBalanceID()
+	if err != nil {
+		return outcome{}, err
+	}
+	closeTime := o.ledger.CloseTime.Unix()
+	claimants := make([]xdr.Claimant, len(op.Claimants))
+	for i, claimant := range op.Claimants {
+		v0 := claimant.MustV0()
+		claimants[i] = xdr.Claimant{
+			Type: xdr.ClaimantTypeClaimantTypeV0,
+			V0: &xdr.ClaimantV0{
+				Destination: v0.Destination,
+				Predicate:   absolutePredicate(v0.Predicate, closeTime),
+			},
+		}
+	}
+	entry := &xdr.ClaimableBalanceEntry{
+		BalanceId: id,
+		Claimants: claimants,
+		Asset:     op.Asset,
+		Amount:    op.Amount,
+	}
+	if sender.trustLine != nil && xdr.TrustLineFlags(sender.trustLine.Flags).IsClawbackEnabledFlag() {
+		entry.Ext = xdr.ClaimableBalanceEntryExt{
+			V: 1,
+			V1: &xdr.ClaimableBalanceEntryExtensionV1{
+				Flags: xdr.Uint32(xdr.ClaimableBalanceFlagsClaimableBalanceClawbackEnabledFlag),
+			},
+		}
+	}
+	sponsor := o.source
+	err = o.state.put(xdr.LedgerEntry{
+		LastModifiedLedgerSeq: xdr.Uint32(o.ledger.Sequence),
+		Data:                  xdr.LedgerEntryData{Type: xdr.LedgerEntryTypeClaimableBalance, ClaimableBalance: entry},
+		Ext: xdr.LedgerEntryExt{
+			V:  1,
+			V1: &xdr.LedgerEntryExtensionV1{SponsoringId: &sponsor},
+		},
+	})
+	if err != nil {
+		return outcome{}, err
+	}
+	sender.add(-int64(op.Amount))
+	addNumSponsoring(o.account, int64(len(op.Claimants)))
+	return success(xdr.CreateClaimableBalanceResult{
+		Code:      xdr.CreateClaimableBalanceResultCodeCreateClaimableBalanceSuccess,
+		BalanceId: &id,
+	})
+}
+
+func (o *operation) claimClaimableBalance(op xdr.ClaimClaimableBalanceOp) (outcome, error) {
+	fail := func(code xdr.ClaimClaimableBalanceResultCode, format string, args ...interface{}) (outcome, error) {
+		return failure(xdr.ClaimClaimableBalanceResult{Code: code}, format, args...)
+	}
+	key := claimableBalanceKey(op.BalanceId)
+	ledgerEntry, err := o.state.get(key)
+	if err != nil {
+		return outcome{}, err
+	}
+	if ledgerEntry == nil {
+		return fail(xdr.ClaimClaimableBalanceResultCodeClaimClaimableBalanceDoesNotExist,
+			"the claimable balance does not exist")
+	}
+	entry := ledgerEntry.Data.ClaimableBalance
+
+	claimable := false
+	for _, claimant := range entry.Claimants {
+		v0 := claimant.MustV0()
+		if v0.Destination.Equals(o.source) {
+			claimable = predicateSatisfied(v0.Predicate, o.ledger.CloseTime.Unix())
+			break
+		}
+	}
+	if !claimable {
+		return fail(xdr.ClaimClaimableBalanceResultCodeClaimClaimableBalanceCannotClaim,
+			"source account is not a claimant or its predicate is not satisfied")
+	}
+
+	receiver, err := o.holding(o.source, entry.Asset)
+	if err != nil {
+		return outcome{}, err
+	}
+	if receiver == nil {
+		return fail(xdr.ClaimClaimableBalanceResultCodeClaimClaimableBalanceNoTrust,
+			"source account does not trust %s", entry.Asset.StringCanonical())
+	}
+	if !receiver.authorized() {
+		return fail(xdr.ClaimClaimableBalanceResultCodeClaimClaimableBalanceNotAuthorized,
+			"source account is not authorized to hold %s", entry.Asset.StringCanonical())
+	}
+	if capacity := receiver.capacity(); capacity < int64(entry.Amount) {
+		return fail(xdr.ClaimClaimableBalanceResultCodeClaimClaimableBalanceLineFull,
+			"source account can only receive %s %s", formatAmount(capacity), entry.Asset.StringCanonical())
+	}
+
+	receiver.add(int64(entry.Amount))
+	if sponsor := ledgerEntry.SponsoringID(); sponsor != nil {
+		sponsorAccount, err := o.state.account(*sponsor)
+		if err != nil {
+			return outcome{}, err
+		}
+		if sponsorAccount != nil {
+			addNumSponsoring(sponsorAccount, -int64(len(entry.Claimants)))
+		}
+	}
+	if err := o.state.remove(key); err != nil {
+		return outcome{}, err
+	}
+	return success(xdr.ClaimClaimableBalanceResult{Code: xdr.ClaimClaimableBalanceResultCodeClaimClaimableBalanceSuccess})
+}
+
+func (o *operation) clawback(op xdr.ClawbackOp) (outcome, error) {
+	fail := func(code xdr.ClawbackResultCode, format string, args ...interface{}) (outcome, error) {
+		return failure(xdr.ClawbackResult{Code: code}, format, args...)
+	}
+	from := op.From.ToAccountId()
+	if op.Amount <= 0 || op.Asset.IsNative() || op.Asset.GetIssuer() != o.source.Address() || from.Equals(o.source) {
+		return fail(xdr.ClawbackResultCodeClawbackMalformed,
+			"the amount must be positive and the source account must be the issuer of the asset")
+	}
+	trustLine, err := o.state.trustLine(from, op.Asset)
+	if err != nil {
+		return outcome{}, err
+	}
+	if trustLine == nil {
+		return fail(xdr.ClawbackResultCodeClawbackNoTrust,
+			"account %s does not trust %s", from.Address(), op.Asset.StringCanonical())
+	}
+	if !xdr.TrustLineFlags(trustLine.Flags).IsClawbackEnabledFlag() {
+		return fail(xdr.ClawbackResultCodeClawbackNotClawbackEnabled, "clawback is not enabled on the trust line")
+	}
+	if available := int64(trustLine.Balance) - int64(trustLine.Liabilities().Selling); available < int64(op.Amount) {
+		return fail(xdr.ClawbackResultCodeClawbackUnderfunded,
+			"the available balance of the trust line is %s", formatAmount(available))
+	}
+	trustLine.Balance -= op.Amount
+	return success(xdr.ClawbackResult{Code: xdr.ClawbackResultCodeClawbackSuccess})
+}
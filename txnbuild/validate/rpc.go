@@ -0,0 +1,89 @@
+package validate
+
+import (
+	"context"
+
+	rpc "github.com/stellar/go/clients/rpcclient"
+	protocol "github.com/stellar/go/protocols/rpc"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// maxRPCKeys is the maximum number of keys RPC accepts in a single
+// getLedgerEntries request.
+const maxRPCKeys = 200
+
+// RPCSource is a StateSource which fetches entries from an RPC server with
+// the getLedgerEntries method.
+type RPCSource struct {
+	Client *rpc.Client
+}
+
+// GetLedgerEntries implements StateSource.
+func (s RPCSource) GetLedgerEntries(ctx context.Context, keys ...xdr.LedgerKey) ([]xdr.LedgerEntry, error) {
+	var entries []xdr.LedgerEntry
+	for start := 0; start < len(keys); start += maxRPCKeys {
+		end := start + maxRPCKeys
+		if end > len(keys) {
+			end = len(keys)
+		}
+		request := protocol.GetLedgerEntriesRequest{Keys: make([]string, 0, end-start)}
+		for _, key := range keys[start:end] {
+			encoded, err := encodeKey(key)
+			if err != nil {
+				return nil, err
+			}
+			request.Keys = append(request.Keys, encoded)
+		}
+
+		response, err := s.Client.GetLedgerEntries(ctx, request)
+		if err != nil {
+			return nil, errors.Wrap(err, "getLedgerEntries failed")
+		}
+		for _, result := range response.Entries {
+			var key xdr.LedgerKey
+			if err := xdr.SafeUnmarshalBase64(result.KeyXDR, &key); err != nil {
+				return nil, errors.Wrap(err, "could not decode ledger key")
+			}
+			var data xdr.LedgerEntryData
+			if err := xdr.SafeUnmarshalBase64(result.DataXDR, &data); err != nil {
+				return nil, errors.Wrap(err, "could not decode ledger entry")
+			}
+			entry := xdr.LedgerEntry{
+				LastModifiedLedgerSeq: xdr.Uint32(result.LastModifiedLedger),
+				Data:                  data,
+			}
+			if result.ExtensionXDR != "" {
+				if err := xdr.SafeUnmarshalBase64(result.ExtensionXDR, &entry.Ext); err != nil {
+					return nil, errors.Wrap(err, "could not decode ledger entry extension")
+				}
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// LedgerInfo returns the LedgerInfo of the ledger following the latest
+// ledger known to the RPC server.
+func (s RPCSource) LedgerInfo(ctx context.Context) (LedgerInfo, error) {
+	latest, err := s.Client.GetLatestLedger(ctx)
+	if err != nil {
+		return LedgerInfo{}, errors.Wrap(err, "getLatestLedger failed")
+	}
+	response, err := s.Client.GetLedgers(ctx, protocol.GetLedgersRequest{
+		StartLedger: latest.Sequence,
+		Pagination:  &protocol.LedgerPaginationOptions{Limit: 1},
+	})
+	if err != nil {
+		return LedgerInfo{}, errors.Wrap(err, "getLedgers failed")
+	}
+	if len(response.Ledgers) == 0 {
+		return LedgerInfo{}, errors.Errorf("ledger %d not found", latest.Sequence)
+	}
+	var header xdr.LedgerHeaderHistoryEntry
+	if err := xdr.SafeUnmarshalBase64(response.Ledgers[0].LedgerHeader, &header); err != nil {
+		return LedgerInfo{}, errors.Wrap(err, "could not decode ledger header")
+	}
+	return LedgerInfoFromHeader(header.Header), nil
+}
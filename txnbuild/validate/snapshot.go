@@ -0,0 +1,120 @@
+package validate
+
+import (
+	"context"
+	"io"
+
+	"github.com/stellar/go/ingest"
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// Snapshot is an in-memory StateSource. It can be filled with entries
+// fetched elsewhere or loaded from a checkpoint with Load.
+type Snapshot struct {
+	entries map[string]xdr.LedgerEntry
+}
+
+// NewSnapshot returns a Snapshot holding the given entries.
+func NewSnapshot(entries ...xdr.LedgerEntry) (*Snapshot, error) {
+	snapshot := &Snapshot{entries: map[string]xdr.LedgerEntry{}}
+	if err := snapshot.Add(entries...); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// Add adds entries to the snapshot, replacing existing entries with the same
+// key.
+func (s *Snapshot) Add(entries ...xdr.LedgerEntry) error {
+	for _, entry := range entries {
+		key, err := entry.LedgerKey()
+		if err != nil {
+			return errors.Wrap(err, "could not derive ledger key")
+		}
+		encoded, err := encodeKey(key)
+		if err != nil {
+			return err
+		}
+		s.entries[encoded] = entry
+	}
+	return nil
+}
+
+// Remove removes the entry of the key from the snapshot.
+func (s *Snapshot) Remove(key xdr.LedgerKey) error {
+	encoded, err := encodeKey(key)
+	if err != nil {
+		return err
+	}
+	delete(s.entries, encoded)
+	return nil
+}
+
+// Len returns the number of entries in the snapshot.
+func (s *Snapshot) Len() int {
+	return len(s.entries)
+}
+
+// Load applies the changes read from reader, for example an
+// ingest.CheckpointChangeReader, until io.EOF. Only entries for which keep
+// returns true are added; all entries are added if keep is nil. The reader
+// is not closed.
+func (s *Snapshot) Load(reader ingest.ChangeReader, keep func(xdr.LedgerEntry) bool) error {
+	for {
+		change, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err, "could not read change")
+		}
+
+		if change.Post == nil {
+			key, err := change.Pre.LedgerKey()
+			if err != nil {
+				return errors.Wrap(err, "could not derive ledger key")
+			}
+			if err := s.Remove(key); err != nil {
+				return err
+			}
+			continue
+		}
+		if keep != nil && !keep(*change.Post) {
+			continue
+		}
+		if err := s.Add(*change.Post); err != nil {
+			return err
+		}
+	}
+}
+
+// GetLedgerEntries implements StateSource.
+func (s *Snapshot) GetLedgerEntries(_ context.Context, keys ...xdr.LedgerKey) ([]xdr.LedgerEntry, error) {
+	entries := make([]xdr.LedgerEntry, 0, len(keys))
+	for _, key := range keys {
+		encoded, err := encodeKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if entry, ok := s.entries[encoded]; ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// ClassicEntries is a filter for Snapshot.Load which keeps the entry types
+// used to validate classic transactions.
+func ClassicEntries(entry xdr.LedgerEntry) bool {
+	switch entry.Data.Type {
+	case xdr.LedgerEntryTypeAccount,
+		xdr.LedgerEntryTypeTrustline,
+		xdr.LedgerEntryTypeOffer,
+		xdr.LedgerEntryTypeData,
+		xdr.LedgerEntryTypeClaimableBalance:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,404 @@
+package validate
+
+import (
+	"context"
+	"math"
+
+	"github.com/stellar/go/support/errors"
+	"github.com/stellar/go/xdr"
+)
+
+// state holds the entries read and written by a validation. Entries are
+// loaded lazily from the source and copied, so the validation can modify
+// them in place.
+type state struct {
+	ctx    context.Context
+	source StateSource
+	// entries maps base64 encoded ledger keys to entries. A nil entry is
+	// known not to exist.
+	entries map[string]*xdr.LedgerEntry
+}
+
+func newState(ctx context.Context, source StateSource) *state {
+	return &state{ctx: ctx, source: source, entries: map[string]*xdr.LedgerEntry{}}
+}
+
+func encodeKey(key xdr.LedgerKey) (string, error) {
+	encoded, err := key.MarshalBinaryBase64()
+	if err != nil {
+		return "", errors.Wrap(err, "could not encode ledger key")
+	}
+	return encoded, nil
+}
+
+// prefetch loads the entries of the keys which have not been loaded yet.
+func (s *state) prefetch(keys []xdr.LedgerKey) error {
+	missing := make([]xdr.LedgerKey, 0, len(keys))
+	seen := map[string]bool{}
+	for _, key := range keys {
+		encoded, err := encodeKey(key)
+		if err != nil {
+			return err
+		}
+		if _, ok := s.entries[encoded]; ok || seen[encoded] {
+			continue
+		}
+		seen[encoded] = true
+		missing = append(missing, key)
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	entries, err := s.source.GetLedgerEntries(s.ctx, missing...)
+	if err != nil {
+		return errors.Wrap(err, "could not load ledger entries")
+	}
+	for encoded := range seen {
+		s.entries[encoded] = nil
+	}
+	for _, entry := range entries {
+		// Copy the entry so modifications do not leak into the source.
+		copied, err := copyEntry(entry)
+		if err != nil {
+			return err
+		}
+		if err := s.put(copied); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clone returns a deep copy of the state, so that the changes made to the
+// copy can be discarded.
+func (s *state) clone() (*state, error) {
+	c := newState(s.ctx, s.source)
+	for encoded, entry := range s.entries {
+		if entry == nil {
+			c.entries[encoded] = nil
+			continue
+		}
+		copied, err := copyEntry(*entry)
+		if err != nil {
+			return nil, err
+		}
+		c.entries[encoded] = &copied
+	}
+	return c, nil
+}
+
+func copyEntry(entry xdr.LedgerEntry) (xdr.LedgerEntry, error) {
+	raw, err := entry.MarshalBinary()
+	if err != nil {
+		return xdr.LedgerEntry{}, errors.Wrap(err, "could not encode ledger entry")
+	}
+	var copied xdr.LedgerEntry
+	if err := xdr.SafeUnmarshal(raw, &copied); err != nil {
+		return xdr.LedgerEntry{}, errors.Wrap(err, "could not decode ledger entry")
+	}
+	return copied, nil
+}
+
+// get returns the entry of the key or nil if it does not exist.
+func (s *state) get(key xdr.LedgerKey) (*xdr.LedgerEntry, error) {
+	encoded, err := encodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if entry, ok := s.entries[encoded]; ok {
+		return entry, nil
+	}
+	if err := s.prefetch([]xdr.LedgerKey{key}); err != nil {
+		return nil, err
+	}
+	return s.entries[encoded], nil
+}
+
+func (s *state) put(entry xdr.LedgerEntry) error {
+	key, err := entry.LedgerKey()
+	if err != nil {
+		return errors.Wrap(err, "could not derive ledger key")
+	}
+	encoded, err := encodeKey(key)
+	if err != nil {
+		return err
+	}
+	s.entries[encoded] = &entry
+	return nil
+}
+
+func (s *state) remove(key xdr.LedgerKey) error {
+	encoded, err := encodeKey(key)
+	if err != nil {
+		return err
+	}
+	s.entries[encoded] = nil
+	return nil
+}
+
+func accountKey(id xdr.AccountId) xdr.LedgerKey {
+	return xdr.LedgerKey{
+		Type:    xdr.LedgerEntryTypeAccount,
+		Account: &xdr.LedgerKeyAccount{AccountId: id},
+	}
+}
+
+func trustLineKey(id xdr.AccountId, asset xdr.TrustLineAsset) xdr.LedgerKey {
+	return xdr.LedgerKey{
+		Type:      xdr.LedgerEntryTypeTrustline,
+		TrustLine: &xdr.LedgerKeyTrustLine{AccountId: id, Asset: asset},
+	}
+}
+
+func offerKey(seller xdr.AccountId, offerID int64) xdr.LedgerKey {
+	return xdr.LedgerKey{
+		Type:  xdr.LedgerEntryTypeOffer,
+		Offer: &xdr.LedgerKeyOffer{SellerId: seller, OfferId: xdr.Int64(offerID)},
+	}
+}
+
+func dataKey(id xdr.AccountId, name xdr.String64) xdr.LedgerKey {
+	return xdr.LedgerKey{
+		Type: xdr.LedgerEntryTypeData,
+		Data: &xdr.LedgerKeyData{AccountId: id, DataName: name},
+	}
+}
+
+func claimableBalanceKey(id xdr.ClaimableBalanceId) xdr.LedgerKey {
+	return xdr.LedgerKey{
+		Type:             xdr.LedgerEntryTypeClaimableBalance,
+		ClaimableBalance: &xdr.LedgerKeyClaimableBalance{BalanceId: id},
+	}
+}
+
+func (s *state) account(id xdr.AccountId) (*xdr.AccountEntry, error) {
+	entry, err := s.get(accountKey(id))
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	return entry.Data.Account, nil
+}
+
+func (s *state) trustLine(id xdr.AccountId, asset xdr.Asset) (*xdr.TrustLineEntry, error) {
+	entry, err := s.get(trustLineKey(id, asset.ToTrustLineAsset()))
+	if err != nil || entry == nil {
+		return nil, err
+	}
+	return entry.Data.TrustLine, nil
+}
+
+// prefetchKeys returns the keys of the accounts and trust lines most
+// operations need, so they can be loaded with a single request.
+func prefetchKeys(source xdr.AccountId, ops []xdr.Operation) []xdr.LedgerKey {
+	keys := []xdr.LedgerKey{accountKey(source)}
+	holds := func(id xdr.AccountId, assets ...xdr.Asset) {
+		keys = append(keys, accountKey(id))
+		for _, asset := range assets {
+			if !asset.IsNative() {
+				keys = append(keys, trustLineKey(id, asset.ToTrustLineAsset()))
+			}
+		}
+	}
+	for _, op := range ops {
+		opSource := source
+		if op.SourceAccount != nil {
+			opSource = op.SourceAccount.ToAccountId()
+		}
+		keys = append(keys, accountKey(opSource))
+		switch op.Body.Type {
+		case xdr.OperationTypeCreateAccount:
+			holds(op.Body.MustCreateAccountOp().Destination)
+		case xdr.OperationTypePayment:
+			payment := op.Body.MustPaymentOp()
+			holds(opSource, payment.Asset)
+			holds(payment.Destination.ToAccountId(), payment.Asset)
+		case xdr.OperationTypePathPaymentStrictReceive:
+			pathPayment := op.Body.MustPathPaymentStrictReceiveOp()
+			holds(opSource, pathPayment.SendAsset)
+			holds(pathPayment.Destination.ToAccountId(), pathPayment.DestAsset)
+		case xdr.OperationTypePathPaymentStrictSend:
+			pathPayment := op.Body.MustPathPaymentStrictSendOp()
+			holds(opSource, pathPayment.SendAsset)
+			holds(pathPayment.Destination.ToAccountId(), pathPayment.DestAsset)
+		case xdr.OperationTypeManageSellOffer:
+			offer := op.Body.MustManageSellOfferOp()
+			holds(opSource, offer.Selling, offer.Buying)
+		case xdr.OperationTypeManageBuyOffer:
+			offer := op.Body.MustManageBuyOfferOp()
+			holds(opSource, offer.Selling, offer.Buying)
+		case xdr.OperationTypeCreatePassiveSellOffer:
+			offer := op.Body.MustCreatePassiveSellOfferOp()
+			holds(opSource, offer.Selling, offer.Buying)
+		case xdr.OperationTypeChangeTrust:
+			if asset, ok := changeTrustAsset(op.Body.MustChangeTrustOp().Line); ok {
+				holds(opSource, asset)
+			}
+		case xdr.OperationTypeAccountMerge:
+			holds(op.Body.MustDestination().ToAccountId())
+		}
+	}
+	return keys
+}
+
+func changeTrustAsset(line xdr.ChangeTrustAsset) (xdr.Asset, bool) {
+	switch line.Type {
+	case xdr.AssetTypeAssetTypeCreditAlphanum4:
+		return xdr.Asset{Type: line.Type, AlphaNum4: line.AlphaNum4}, true
+	case xdr.AssetTypeAssetTypeCreditAlphanum12:
+		return xdr.Asset{Type: line.Type, AlphaNum12: line.AlphaNum12}, true
+	}
+	return xdr.Asset{}, false
+}
+
+// minBalance returns the minimum balance of the account. Sponsorships are
+// only taken into account through the counters stored in the account.
+func (v *validation) minBalance(account *xdr.AccountEntry) int64 {
+	entries := 2 + int64(account.NumSubEntries) + int64(account.NumSponsoring()) - int64(account.NumSponsored())
+	return entries * v.baseReserve()
+}
+
+// availableNative returns the amount of lumens the account can spend.
+func (v *validation) availableNative(account *xdr.AccountEntry) int64 {
+	return int64(account.Balance) - v.minBalance(account) - int64(account.Liabilities().Selling)
+}
+
+// canAddSubEntries returns true if the account can afford the reserve of n
+// additional subentries.
+func (v *validation) canAddSubEntries(account *xdr.AccountEntry, n int64) bool {
+	return v.availableNative(account) >= n*v.baseReserve()
+}
+
+func setAccountLiabilities(account *xdr.AccountEntry, liabilities xdr.Liabilities) {
+	if account.Ext.V1 == nil {
+		account.Ext = xdr.AccountEntryExt{V: 1, V1: &xdr.AccountEntryExtensionV1{}}
+	}
+	account.Ext.V1.Liabilities = liabilities
+}
+
+func setTrustLineLiabilities(trustLine *xdr.TrustLineEntry, liabilities xdr.Liabilities) {
+	if trustLine.Ext.V1 == nil {
+		trustLine.Ext = xdr.TrustLineEntryExt{V: 1, V1: &xdr.TrustLineEntryV1{}}
+	}
+	trustLine.Ext.V1.Liabilities = liabilities
+}
+
+func accountExtensionV2(account *xdr.AccountEntry) *xdr.AccountEntryExtensionV2 {
+	if account.Ext.V1 == nil {
+		account.Ext = xdr.AccountEntryExt{V: 1, V1: &xdr.AccountEntryExtensionV1{}}
+	}
+	if account.Ext.V1.Ext.V2 == nil {
+		account.Ext.V1.Ext = xdr.AccountEntryExtensionV1Ext{
+			V: 2,
+			V2: &xdr.AccountEntryExtensionV2{
+				SignerSponsoringIDs: make([]xdr.SponsorshipDescriptor, len(account.Signers)),
+			},
+		}
+	}
+	return account.Ext.V1.Ext.V2
+}
+
+func addNumSponsoring(account *xdr.AccountEntry, n int64) {
+	ext := accountExtensionV2(account)
+	ext.NumSponsoring = xdr.Uint32(int64(ext.NumSponsoring) + n)
+}
+
+// holding is the balance of an asset held by an account: its native
+// balance, a trust line or, if the account is the issuer of the asset, an
+// unlimited balance.
+type holding struct {
+	asset     xdr.Asset
+	account   *xdr.AccountEntry
+	trustLine *xdr.TrustLineEntry
+	issuer    bool
+}
+
+// holding returns the holding of the asset by the account. The holding is
+// nil if the account or its trust line do not exist.
+func (v *validation) holding(id xdr.AccountId, asset xdr.Asset) (*holding, error) {
+	account, err := v.state.account(id)
+	if err != nil || account == nil {
+		return nil, err
+	}
+	h := &holding{asset: asset, account: account}
+	if asset.IsNative() {
+		return h, nil
+	}
+	if asset.GetIssuer() == id.Address() {
+		h.issuer = true
+		return h, nil
+	}
+	h.trustLine, err = v.state.trustLine(id, asset)
+	if err != nil || h.trustLine == nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// authorized returns true if the holding can send and receive payments.
+func (h *holding) authorized() bool {
+	return h.trustLine == nil || xdr.TrustLineFlags(h.trustLine.Flags).IsAuthorized()
+}
+
+// authorizedToMaintainLiabilities returns true if the holding can be used by
+// offers.
+func (h *holding) authorizedToMaintainLiabilities() bool {
+	return h.trustLine == nil || xdr.TrustLineFlags(h.trustLine.Flags).IsAuthorizedToMaintainLiabilitiesFlag() ||
+		xdr.TrustLineFlags(h.trustLine.Flags).IsAuthorized()
+}
+
+func (h *holding) liabilities() xdr.Liabilities {
+	switch {
+	case h.issuer:
+		return xdr.Liabilities{}
+	case h.trustLine != nil:
+		return h.trustLine.Liabilities()
+	default:
+		return h.account.Liabilities()
+	}
+}
+
+func (h *holding) setLiabilities(liabilities xdr.Liabilities) {
+	switch {
+	case h.issuer:
+	case h.trustLine != nil:
+		setTrustLineLiabilities(h.trustLine, liabilities)
+	default:
+		setAccountLiabilities(h.account, liabilities)
+	}
+}
+
+// available returns the amount the holding can send.
+func (v *validation) available(h *holding) int64 {
+	switch {
+	case h.issuer:
+		return math.MaxInt64
+	case h.trustLine != nil:
+		return int64(h.trustLine.Balance) - int64(h.trustLine.Liabilities().Selling)
+	default:
+		return v.availableNative(h.account)
+	}
+}
+
+// capacity returns the amount the holding can receive.
+func (h *holding) capacity() int64 {
+	switch {
+	case h.issuer:
+		return math.MaxInt64
+	case h.trustLine != nil:
+		return int64(h.trustLine.Limit) - int64(h.trustLine.Balance) - int64(h.trustLine.Liabilities().Buying)
+	default:
+		return math.MaxInt64 - int64(h.account.Balance) - int64(h.account.Liabilities().Buying)
+	}
+}
+
+// add adds amount, which may be negative, to the balance of the holding.
+func (h *holding) add(amount int64) {
+	switch {
+	case h.issuer:
+	case h.trustLine != nil:
+		h.trustLine.Balance += xdr.Int64(amount)
+	default:
+		h.account.Balance += xdr.Int64(amount)
+	}
+}
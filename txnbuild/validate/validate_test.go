@@ -0,0 +1,547 @@
+package validate
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/stellar/go/ingest"
+	"github.com/stellar/go/keypair"
+	"github.com/stellar/go/txnbuild"
+	"github.com/stellar/go/xdr"
+)
+
+const lumen = 10000000
+
+var testLedger = LedgerInfo{
+	Sequence:  100,
+	CloseTime: time.Unix(1700000000, 0),
+}
+
+func accountEntry(kp keypair.KP, balance int64, subEntries uint32) xdr.LedgerEntry {
+	return xdr.LedgerEntry{
+		Data: xdr.LedgerEntryData{
+			Type: xdr.LedgerEntryTypeAccount,
+			Account: &xdr.AccountEntry{
+				AccountId:     xdr.MustAddress(kp.Address()),
+				Balance:       xdr.Int64(balance),
+				SeqNum:        1,
+				NumSubEntries: xdr.Uint32(subEntries),
+				Thresholds:    xdr.Thresholds{1, 0, 0, 0},
+			},
+		},
+	}
+}
+
+func trustLineEntry(kp keypair.KP, asset txnbuild.CreditAsset, balance, limit int64, flags xdr.TrustLineFlags) xdr.LedgerEntry {
+	xdrAsset, err := asset.ToXDR()
+	if err != nil {
+		panic(err)
+	}
+	return xdr.LedgerEntry{
+		Data: xdr.LedgerEntryData{
+			Type: xdr.LedgerEntryTypeTrustline,
+			TrustLine: &xdr.TrustLineEntry{
+				AccountId: xdr.MustAddress(kp.Address()),
+				Asset:     xdrAsset.ToTrustLineAsset(),
+				Balance:   xdr.Int64(balance),
+				Limit:     xdr.Int64(limit),
+				Flags:     xdr.Uint32(flags),
+			},
+		},
+	}
+}
+
+func newTransaction(t *testing.T, source keypair.KP, seq int64, ops ...txnbuild.Operation) *txnbuild.Transaction {
+	account := txnbuild.NewSimpleAccount(source.Address(), seq-1)
+	tx, err := txnbuild.NewTransaction(txnbuild.TransactionParams{
+		SourceAccount:        &account,
+		IncrementSequenceNum: true,
+		Operations:           ops,
+		BaseFee:              txnbuild.MinBaseFee,
+		Preconditions:        txnbuild.Preconditions{TimeBounds: txnbuild.NewInfiniteTimeout()},
+	})
+	require.NoError(t, err)
+	return tx
+}
+
+func validate(t *testing.T, snapshot *Snapshot, tx *txnbuild.Transaction) Result {
+	validator := &Validator{Source: snapshot, Ledger: testLedger}
+	result, err := validator.Validate(context.Background(), tx)
+	require.NoError(t, err)
+	return result
+}
+
+func operationCode(t *testing.T, result OperationResult) int32 {
+	require.NotNil(t, result.Result.Tr)
+	arm, ok := result.Result.Tr.ArmForSwitch(int32(result.Result.Tr.Type))
+	require.True(t, ok)
+	switch arm {
+	case "PaymentResult":
+		return int32(result.Result.Tr.PaymentResult.Code)
+	case "CreateAccountResult":
+		return int32(result.Result.Tr.CreateAccountResult.Code)
+	case "ManageSellOfferResult":
+		return int32(result.Result.Tr.ManageSellOfferResult.Code)
+	case "ChangeTrustResult":
+		return int32(result.Result.Tr.ChangeTrustResult.Code)
+	case "AccountMergeResult":
+		return int32(result.Result.Tr.AccountMergeResult.Code)
+	case "CreateClaimableBalanceResult":
+		return int32(result.Result.Tr.CreateClaimableBalanceResult.Code)
+	case "ClaimClaimableBalanceResult":
+		return int32(result.Result.Tr.ClaimClaimableBalanceResult.Code)
+	case "PathPaymentStrictSendResult":
+		return int32(result.Result.Tr.PathPaymentStrictSendResult.Code)
+	}
+	t.Fatalf("unexpected result %s", arm)
+	return 0
+}
+
+func TestValidateTransaction(t *testing.T) {
+	source, other := keypair.MustRandom(), keypair.MustRandom()
+	snapshot, err := NewSnapshot(
+		accountEntry(source, 100*lumen, 0),
+		accountEntry(other, lumen+lumen/2, 0),
+	)
+	require.NoError(t, err)
+	payment := &txnbuild.Payment{Destination: other.Address(), Amount: "10", Asset: txnbuild.NativeAsset{}}
+
+	result := validate(t, snapshot, newTransaction(t, source, 2, payment))
+	assert.True(t, result.Successful())
+	assert.Equal(t, int64(100), result.FeeCharged)
+	require.Len(t, result.Operations, 1)
+	assert.True(t, result.Operations[0].Successful())
+	assert.False(t, result.Partial())
+
+	result = validate(t, snapshot, newTransaction(t, source, 5, payment))
+	assert.Equal(t, xdr.TransactionResultCodeTxBadSeq, result.Code)
+	assert.Equal(t, "the sequence number 5 is not the successor of the source account sequence number 1", result.Explanation)
+	assert.Empty(t, result.Operations)
+
+	result = validate(t, snapshot, newTransaction(t, keypair.MustRandom(), 2, payment))
+	assert.Equal(t, xdr.TransactionResultCodeTxNoAccount, result.Code)
+
+	result = validate(t, snapshot, newTransaction(t, other, 2,
+		&txnbuild.Payment{Destination: source.Address(), Amount: "10", Asset: txnbuild.NativeAsset{}},
+	))
+	assert.Equal(t, xdr.TransactionResultCodeTxFailed, result.Code)
+	assert.Equal(t, int32(xdr.PaymentResultCodePaymentUnderfunded), operationCode(t, result.Operations[0]))
+	assert.Equal(t, "operation 0 failed: the available balance of the source account is 0.4999900 native", result.Explanation)
+
+	poor := keypair.MustRandom()
+	require.NoError(t, snapshot.Add(accountEntry(poor, lumen+50, 0)))
+	result = validate(t, snapshot, newTransaction(t, poor, 2, payment))
+	assert.Equal(t, xdr.TransactionResultCodeTxInsufficientBalance, result.Code)
+
+	account := txnbuild.NewSimpleAccount(source.Address(), 1)
+	late, err := txnbuild.NewTransaction(txnbuild.TransactionParams{
+		SourceAccount:        &account,
+		IncrementSequenceNum: true,
+		Operations:           []txnbuild.Operation{payment},
+		BaseFee:              txnbuild.MinBaseFee,
+		Preconditions:        txnbuild.Preconditions{TimeBounds: txnbuild.NewTimebounds(0, testLedger.CloseTime.Unix()-1)},
+	})
+	require.NoError(t, err)
+	result = validate(t, snapshot, late)
+	assert.Equal(t, xdr.TransactionResultCodeTxTooLate, result.Code)
+}
+
+func TestValidatePayments(t *testing.T) {
+	source, issuer, holder, stranger := keypair.MustRandom(), keypair.MustRandom(), keypair.MustRandom(), keypair.MustRandom()
+	usd := txnbuild.CreditAsset{Code: "USD", Issuer: issuer.Address()}
+	eur := txnbuild.CreditAsset{Code: "EUR", Issuer: issuer.Address()}
+	snapshot, err := NewSnapshot(
+		accountEntry(source, 100*lumen, 2),
+		accountEntry(issuer, 100*lumen, 0),
+		accountEntry(holder, 100*lumen, 2),
+		accountEntry(stranger, 100*lumen, 0),
+		trustLineEntry(source, usd, 50*lumen, 1000*lumen, xdr.TrustLineFlagsAuthorizedFlag),
+		trustLineEntry(source, eur, 50*lumen, 1000*lumen, 0),
+		trustLineEntry(holder, usd, 0, 20*lumen, xdr.TrustLineFlagsAuthorizedFlag),
+		trustLineEntry(holder, eur, 0, 1000*lumen, 0),
+	)
+	require.NoError(t, err)
+
+	for _, testCase := range []struct {
+		name        string
+		payment     *txnbuild.Payment
+		code        xdr.PaymentResultCode
+		explanation string
+	}{
+		{
+			"success",
+			&txnbuild.Payment{Destination: holder.Address(), Amount: "20", Asset: usd},
+			xdr.PaymentResultCodePaymentSuccess, "",
+		},
+		{
+			"line full",
+			&txnbuild.Payment{Destination: holder.Address(), Amount: "20.0000001", Asset: usd},
+			xdr.PaymentResultCodePaymentLineFull,
+			"destination account " + holder.Address() + " can only receive 20.0000000 USD:" + issuer.Address(),
+		},
+		{
+			"no trust",
+			&txnbuild.Payment{Destination: stranger.Address(), Amount: "1", Asset: usd},
+			xdr.PaymentResultCodePaymentNoTrust,
+			"destination account " + stranger.Address() + " does not trust USD:" + issuer.Address(),
+		},
+		{
+			"not authorized",
+			&txnbuild.Payment{Destination: holder.Address(), Amount: "1", Asset: eur},
+			xdr.PaymentResultCodePaymentNotAuthorized,
+			"destination account " + holder.Address() + " is not authorized to hold EUR:" + issuer.Address(),
+		},
+		{
+			"no destination",
+			&txnbuild.Payment{Destination: keypair.MustRandom().Address(), Amount: "1", Asset: usd},
+			xdr.PaymentResultCodePaymentNoDestination, "",
+		},
+		{
+			"source no trust",
+			&txnbuild.Payment{
+				Destination: holder.Address(), Amount: "1",
+				Asset: txnbuild.CreditAsset{Code: "GBP", Issuer: issuer.Address()},
+			},
+			xdr.PaymentResultCodePaymentNoTrust, "",
+		},
+		{
+			"underfunded",
+			&txnbuild.Payment{Destination: issuer.Address(), Amount: "50.0000001", Asset: usd},
+			xdr.PaymentResultCodePaymentUnderfunded,
+			"the available balance of the source account is 50.0000000 USD:" + issuer.Address(),
+		},
+		{
+			"burn",
+			&txnbuild.Payment{Destination: issuer.Address(), Amount: "50", Asset: usd},
+			xdr.PaymentResultCodePaymentSuccess, "",
+		},
+		{
+			"native reserve",
+			&txnbuild.Payment{Destination: holder.Address(), Amount: "98", Asset: txnbuild.NativeAsset{}},
+			xdr.PaymentResultCodePaymentUnderfunded,
+			"the available balance of the source account is 97.9999900 native",
+		},
+	} {
+		t.Run(testCase.name, func(t *testing.T) {
+			result := validate(t, snapshot, newTransaction(t, source, 2, testCase.payment))
+			require.Len(t, result.Operations, 1)
+			assert.Equal(t, int32(testCase.code), operationCode(t, result.Operations[0]))
+			if testCase.explanation != "" {
+				assert.Equal(t, testCase.explanation, result.Operations[0].Explanation)
+			}
+		})
+	}
+
+	// The source account does not trust GBP either.
+	result := validate(t, snapshot, newTransaction(t, holder, 2, &txnbuild.Payment{
+		Destination: source.Address(), Amount: "1", Asset: txnbuild.CreditAsset{Code: "GBP", Issuer: issuer.Address()},
+	}))
+	assert.Equal(t, int32(xdr.PaymentResultCodePaymentNoTrust), operationCode(t, result.Operations[0]))
+	result = validate(t, snapshot, newTransaction(t, stranger, 2, &txnbuild.Payment{
+		Destination: holder.Address(), Amount: "1", Asset: usd,
+	}))
+	assert.Equal(t, int32(xdr.PaymentResultCodePaymentSrcNoTrust), operationCode(t, result.Operations[0]))
+
+	// Later operations see the effects of earlier ones.
+	result = validate(t, snapshot, newTransaction(t, source, 2,
+		&txnbuild.Payment{Destination: holder.Address(), Amount: "15", Asset: usd},
+		&txnbuild.Payment{Destination: holder.Address(), Amount: "15", Asset: usd},
+	))
+	assert.Equal(t, xdr.TransactionResultCodeTxFailed, result.Code)
+	assert.True(t, result.Operations[0].Successful())
+	assert.Equal(t, int32(xdr.PaymentResultCodePaymentLineFull), operationCode(t, result.Operations[1]))
+
+	// Path payments between different assets are partial.
+	result = validate(t, snapshot, newTransaction(t, source, 2, &txnbuild.PathPaymentStrictSend{
+		SendAsset: usd, SendAmount: "10", Destination: stranger.Address(), DestAsset: txnbuild.NativeAsset{}, DestMin: "1",
+	}))
+	assert.True(t, result.Successful())
+	assert.True(t, result.Operations[0].Partial)
+	assert.Contains(t, result.Operations[0].Explanation, "not simulated")
+
+	// The snapshot is not modified.
+	entries, err := snapshot.GetLedgerEntries(context.Background(), accountKey(xdr.MustAddress(source.Address())))
+	require.NoError(t, err)
+	assert.Equal(t, xdr.Int64(100*lumen), entries[0].Data.Account.Balance)
+}
+
+func TestValidateAccounts(t *testing.T) {
+	source, issuer := keypair.MustRandom(), keypair.MustRandom()
+	usd := txnbuild.CreditAsset{Code: "USD", Issuer: issuer.Address()}
+	snapshot, err := NewSnapshot(
+		accountEntry(source, 100*lumen, 1),
+		accountEntry(issuer, 10*lumen, 0),
+		trustLineEntry(source, usd, 5*lumen, 1000*lumen, xdr.TrustLineFlagsAuthorizedFlag),
+	)
+	require.NoError(t, err)
+	newAccount := keypair.MustRandom()
+
+	result := validate(t, snapshot, newTransaction(t, source, 2,
+		&txnbuild.CreateAccount{Destination: newAccount.Address(), Amount: "1.2"},
+		&txnbuild.ChangeTrust{
+			Line:          txnbuild.ChangeTrustAssetWrapper{Asset: usd},
+			SourceAccount: newAccount.Address(),
+		},
+		&txnbuild.Payment{Destination: newAccount.Address(), Amount: "5", Asset: usd},
+	))
+	assert.Equal(t, xdr.TransactionResultCodeTxFailed, result.Code)
+	assert.True(t, result.Operations[0].Successful())
+	assert.Equal(t, int32(xdr.ChangeTrustResultCodeChangeTrustLowReserve), operationCode(t, result.Operations[1]))
+	assert.Equal(t, int32(xdr.PaymentResultCodePaymentNoTrust), operationCode(t, result.Operations[2]))
+
+	result = validate(t, snapshot, newTransaction(t, source, 2,
+		&txnbuild.CreateAccount{Destination: newAccount.Address(), Amount: "2"},
+		&txnbuild.ChangeTrust{
+			Line:          txnbuild.ChangeTrustAssetWrapper{Asset: usd},
+			SourceAccount: newAccount.Address(),
+		},
+		&txnbuild.Payment{Destination: newAccount.Address(), Amount: "5", Asset: usd},
+	))
+	assert.True(t, result.Successful())
+
+	result = validate(t, snapshot, newTransaction(t, source, 2,
+		&txnbuild.CreateAccount{Destination: newAccount.Address(), Amount: "0.9"},
+	))
+	assert.Equal(t, int32(xdr.CreateAccountResultCodeCreateAccountLowReserve), operationCode(t, result.Operations[0]))
+	result = validate(t, snapshot, newTransaction(t, source, 2,
+		&txnbuild.CreateAccount{Destination: issuer.Address(), Amount: "2"},
+	))
+	assert.Equal(t, int32(xdr.CreateAccountResultCodeCreateAccountAlreadyExist), operationCode(t, result.Operations[0]))
+
+	result = validate(t, snapshot, newTransaction(t, source, 2,
+		&txnbuild.ChangeTrust{Line: txnbuild.ChangeTrustAssetWrapper{Asset: usd}, Limit: "0"},
+	))
+	assert.Equal(t, int32(xdr.ChangeTrustResultCodeChangeTrustInvalidLimit), operationCode(t, result.Operations[0]))
+
+	result = validate(t, snapshot, newTransaction(t, source, 2,
+		&txnbuild.AccountMerge{Destination: issuer.Address()},
+	))
+	assert.Equal(t, int32(xdr.AccountMergeResultCodeAccountMergeHasSubEntries), operationCode(t, result.Operations[0]))
+
+	// Once the trust line is emptied and removed, the account can be merged.
+	result = validate(t, snapshot, newTransaction(t, source, 2,
+		&txnbuild.Payment{Destination: issuer.Address(), Amount: "5", Asset: usd},
+		&txnbuild.ChangeTrust{Line: txnbuild.ChangeTrustAssetWrapper{Asset: usd}, Limit: "0"},
+		&txnbuild.AccountMerge{Destination: issuer.Address()},
+		&txnbuild.BumpSequence{BumpTo: 10},
+	))
+	require.Len(t, result.Operations, 4)
+	for _, op := range result.Operations[:3] {
+		assert.True(t, op.Successful())
+	}
+	balance := *result.Operations[2].Result.Tr.AccountMergeResult.SourceAccountBalance
+	assert.Equal(t, xdr.Int64(100*lumen-100*4), balance)
+	// The merged account no longer exists.
+	assert.Equal(t, xdr.OperationResultCodeOpNoAccount, result.Operations[3].Result.Code)
+}
+
+func TestValidateAfterFailedOperation(t *testing.T) {
+	source, other := keypair.MustRandom(), keypair.MustRandom()
+	snapshot, err := NewSnapshot(
+		accountEntry(source, 100*lumen, 0),
+		accountEntry(other, 2*lumen, 0),
+	)
+	require.NoError(t, err)
+	newAccount := keypair.MustRandom()
+
+	// The network rolls back the whole transaction once an operation fails,
+	// so the account created by the second operation does not exist for the
+	// third one.
+	result := validate(t, snapshot, newTransaction(t, source, 2,
+		&txnbuild.Payment{Destination: source.Address(), Amount: "10", Asset: txnbuild.NativeAsset{}, SourceAccount: other.Address()},
+		&txnbuild.CreateAccount{Destination: newAccount.Address(), Amount: "2"},
+		&txnbuild.Payment{Destination: newAccount.Address(), Amount: "1", Asset: txnbuild.NativeAsset{}},
+	))
+	assert.Equal(t, xdr.TransactionResultCodeTxFailed, result.Code)
+	require.Len(t, result.Operations, 3)
+	assert.Equal(t, int32(xdr.PaymentResultCodePaymentUnderfunded), operationCode(t, result.Operations[0]))
+	assert.True(t, result.Operations[1].Successful())
+	assert.Equal(t, int32(xdr.PaymentResultCodePaymentNoDestination), operationCode(t, result.Operations[2]))
+
+	// The changes of the operations before the failure are kept.
+	result = validate(t, snapshot, newTransaction(t, source, 2,
+		&txnbuild.CreateAccount{Destination: newAccount.Address(), Amount: "2"},
+		&txnbuild.Payment{Destination: source.Address(), Amount: "10", Asset: txnbuild.NativeAsset{}, SourceAccount: other.Address()},
+		&txnbuild.Payment{Destination: newAccount.Address(), Amount: "1", Asset: txnbuild.NativeAsset{}},
+	))
+	assert.Equal(t, xdr.TransactionResultCodeTxFailed, result.Code)
+	assert.True(t, result.Operations[0].Successful())
+	assert.True(t, result.Operations[2].Successful())
+}
+
+func TestValidateOffers(t *testing.T) {
+	source, issuer := keypair.MustRandom(), keypair.MustRandom()
+	usd := txnbuild.CreditAsset{Code: "USD", Issuer: issuer.Address()}
+	snapshot, err := NewSnapshot(
+		accountEntry(source, 10*lumen, 1),
+		accountEntry(issuer, 10*lumen, 0),
+		trustLineEntry(source, usd, 5*lumen, 10*lumen, xdr.TrustLineFlagsAuthorizedFlag),
+	)
+	require.NoError(t, err)
+
+	result := validate(t, snapshot, newTransaction(t, source, 2,
+		&txnbuild.ManageSellOffer{Selling: usd, Buying: txnbuild.NativeAsset{}, Amount: "4", Price: xdr.Price{N: 1, D: 1}},
+		&txnbuild.Payment{Destination: issuer.Address(), Amount: "2", Asset: usd},
+	))
+	assert.Equal(t, xdr.TransactionResultCodeTxFailed, result.Code)
+	assert.True(t, result.Operations[0].Successful())
+	assert.True(t, result.Operations[0].Partial)
+	// The offer holds 4 USD in selling liabilities.
+	assert.Equal(t, int32(xdr.PaymentResultCodePaymentUnderfunded), operationCode(t, result.Operations[1]))
+
+	result = validate(t, snapshot, newTransaction(t, source, 2,
+		&txnbuild.ManageSellOffer{Selling: usd, Buying: txnbuild.NativeAsset{}, Amount: "6", Price: xdr.Price{N: 1, D: 1}},
+	))
+	assert.Equal(t, int32(xdr.ManageSellOfferResultCodeManageSellOfferUnderfunded), operationCode(t, result.Operations[0]))
+
+	result = validate(t, snapshot, newTransaction(t, source, 2,
+		&txnbuild.ManageSellOffer{Selling: txnbuild.NativeAsset{}, Buying: usd, Amount: "3", Price: xdr.Price{N: 2, D: 1}},
+	))
+	assert.Equal(t, int32(xdr.ManageSellOfferResultCodeManageSellOfferLineFull), operationCode(t, result.Operations[0]))
+
+	result = validate(t, snapshot, newTransaction(t, source, 2,
+		&txnbuild.ManageBuyOffer{Selling: usd, Buying: txnbuild.NativeAsset{}, Amount: "1", Price: xdr.Price{N: 1, D: 1}, OfferID: 42},
+	))
+	assert.Equal(t, xdr.ManageBuyOfferResultCodeManageBuyOfferNotFound, result.Operations[0].Result.Tr.ManageBuyOfferResult.Code)
+
+	// Each offer needs a reserve.
+	poor := keypair.MustRandom()
+	require.NoError(t, snapshot.Add(
+		accountEntry(poor, 15*lumen/10+1000, 1),
+		trustLineEntry(poor, usd, 5*lumen, 10*lumen, xdr.TrustLineFlagsAuthorizedFlag),
+	))
+	result = validate(t, snapshot, newTransaction(t, poor, 2,
+		&txnbuild.ManageSellOffer{Selling: usd, Buying: txnbuild.NativeAsset{}, Amount: "1", Price: xdr.Price{N: 1, D: 1}},
+	))
+	assert.Equal(t, int32(xdr.ManageSellOfferResultCodeManageSellOfferLowReserve), operationCode(t, result.Operations[0]))
+}
+
+func TestValidateClaimableBalances(t *testing.T) {
+	source, claimant := keypair.MustRandom(), keypair.MustRandom()
+	snapshot, err := NewSnapshot(
+		accountEntry(source, 10*lumen, 0),
+		accountEntry(claimant, 10*lumen, 0),
+	)
+	require.NoError(t, err)
+
+	tx := newTransaction(t, source, 2,
+		&txnbuild.CreateClaimableBalance{
+			Amount:       "5",
+			Asset:        txnbuild.NativeAsset{},
+			Destinations: []txnbuild.Claimant{txnbuild.NewClaimant(claimant.Address(), nil)},
+		},
+	)
+	balanceID, err := tx.ClaimableBalanceID(0)
+	require.NoError(t, err)
+	tx = newTransaction(t, source, 2,
+		&txnbuild.CreateClaimableBalance{
+			Amount:       "5",
+			Asset:        txnbuild.NativeAsset{},
+			Destinations: []txnbuild.Claimant{txnbuild.NewClaimant(claimant.Address(), nil)},
+		},
+		&txnbuild.ClaimClaimableBalance{BalanceID: balanceID, SourceAccount: claimant.Address()},
+		&txnbuild.ClaimClaimableBalance{BalanceID: balanceID, SourceAccount: claimant.Address()},
+	)
+	result := validate(t, snapshot, tx)
+	require.Len(t, result.Operations, 3)
+	assert.True(t, result.Operations[0].Successful())
+	id, err := xdr.MarshalHex(result.Operations[0].Result.Tr.CreateClaimableBalanceResult.BalanceId)
+	require.NoError(t, err)
+	assert.Equal(t, balanceID, id)
+	assert.True(t, result.Operations[1].Successful())
+	assert.Equal(t, int32(xdr.ClaimClaimableBalanceResultCodeClaimClaimableBalanceDoesNotExist),
+		operationCode(t, result.Operations[2]))
+
+	// Only claimants whose predicate is satisfied can claim.
+	expired := txnbuild.BeforeAbsoluteTimePredicate(testLedger.CloseTime.Unix() - 1)
+	tx = newTransaction(t, source, 2,
+		&txnbuild.CreateClaimableBalance{
+			Amount:       "5",
+			Asset:        txnbuild.NativeAsset{},
+			Destinations: []txnbuild.Claimant{txnbuild.NewClaimant(claimant.Address(), &expired)},
+		},
+	)
+	balanceID, err = tx.ClaimableBalanceID(0)
+	require.NoError(t, err)
+	tx = newTransaction(t, source, 2,
+		&txnbuild.CreateClaimableBalance{
+			Amount:       "5",
+			Asset:        txnbuild.NativeAsset{},
+			Destinations: []txnbuild.Claimant{txnbuild.NewClaimant(claimant.Address(), &expired)},
+		},
+		&txnbuild.ClaimClaimableBalance{BalanceID: balanceID, SourceAccount: claimant.Address()},
+	)
+	result = validate(t, snapshot, tx)
+	assert.True(t, result.Operations[0].Successful())
+	assert.Equal(t, int32(xdr.ClaimClaimableBalanceResultCodeClaimClaimableBalanceCannotClaim),
+		operationCode(t, result.Operations[1]))
+
+	// The balance and the reserve of the claimable balance must be covered.
+	result = validate(t, snapshot, newTransaction(t, source, 2,
+		&txnbuild.CreateClaimableBalance{
+			Amount:       "8.5",
+			Asset:        txnbuild.NativeAsset{},
+			Destinations: []txnbuild.Claimant{txnbuild.NewClaimant(claimant.Address(), nil)},
+		},
+	))
+	assert.Equal(t, int32(xdr.CreateClaimableBalanceResultCodeCreateClaimableBalanceLowReserve),
+		operationCode(t, result.Operations[0]))
+}
+
+func TestValidateNotSimulated(t *testing.T) {
+	source, sponsored := keypair.MustRandom(), keypair.MustRandom()
+	snapshot, err := NewSnapshot(accountEntry(source, 10*lumen, 0), accountEntry(sponsored, 10*lumen, 0))
+	require.NoError(t, err)
+	result := validate(t, snapshot, newTransaction(t, source, 2,
+		&txnbuild.BeginSponsoringFutureReserves{SponsoredID: sponsored.Address()},
+		&txnbuild.EndSponsoringFutureReserves{SourceAccount: sponsored.Address()},
+	))
+	assert.True(t, result.Successful())
+	assert.True(t, result.Partial())
+	assert.Equal(t, "begin_sponsoring_future_reserves operations are not simulated", result.Operations[0].Explanation)
+}
+
+type changeReader struct {
+	changes []ingest.Change
+}
+
+func (r *changeReader) Read() (ingest.Change, error) {
+	if len(r.changes) == 0 {
+		return ingest.Change{}, io.EOF
+	}
+	change := r.changes[0]
+	r.changes = r.changes[1:]
+	return change, nil
+}
+
+func (r *changeReader) Close() error {
+	return nil
+}
+
+func TestSnapshotLoad(t *testing.T) {
+	kp0, kp1 := keypair.MustRandom(), keypair.MustRandom()
+	account0, account1 := accountEntry(kp0, lumen, 0), accountEntry(kp1, lumen, 0)
+	trustLine := trustLineEntry(kp0, txnbuild.CreditAsset{Code: "USD", Issuer: kp1.Address()}, 0, lumen, 0)
+
+	snapshot, err := NewSnapshot(account1)
+	require.NoError(t, err)
+	err = snapshot.Load(&changeReader{changes: []ingest.Change{
+		{Type: xdr.LedgerEntryTypeAccount, Post: &account0},
+		{Type: xdr.LedgerEntryTypeTrustline, Post: &trustLine},
+		{Type: xdr.LedgerEntryTypeAccount, Pre: &account1},
+	}}, func(entry xdr.LedgerEntry) bool {
+		return entry.Data.Type == xdr.LedgerEntryTypeAccount
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, snapshot.Len())
+
+	entries, err := snapshot.GetLedgerEntries(context.Background(),
+		accountKey(xdr.MustAddress(kp0.Address())), accountKey(xdr.MustAddress(kp1.Address())))
+	require.NoError(t, err)
+	assert.Equal(t, []xdr.LedgerEntry{account0}, entries)
+}